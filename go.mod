@@ -3,7 +3,6 @@ module github.com/liftbridge-io/liftbridge
 go 1.13
 
 require (
-	github.com/Workiva/go-datastructures v1.0.52
 	github.com/dustin/go-humanize v1.0.0
 	github.com/golang/protobuf v1.3.5
 	github.com/hako/durafmt v0.0.0-20191009132224-3f39dc1ed9f4
@@ -23,6 +22,6 @@ require (
 	github.com/stretchr/testify v1.4.0
 	github.com/urfave/cli v1.22.3
 	golang.org/x/sys v0.0.0-20200327173247-9dae0f8f5775 // indirect
-	google.golang.org/genproto v0.0.0-20200330113809-af700f360a68 // indirect
+	google.golang.org/genproto v0.0.0-20200330113809-af700f360a68
 	google.golang.org/grpc v1.28.0
 )
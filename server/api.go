@@ -1,13 +1,19 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nuid"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	grpcMetadata "google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	client "github.com/liftbridge-io/liftbridge-api/go"
@@ -17,6 +23,424 @@ import (
 
 const raftApplyTimeout = 30 * time.Second
 
+// KeyFilterMetadataKey is the gRPC request metadata key used to filter a
+// subscription to messages with the given key. Offsets still advance past
+// messages that do not match, but only matching messages are delivered.
+const KeyFilterMetadataKey = "liftbridge-key-filter-bin"
+
+// ReplicaServersMetadataKey is the gRPC request metadata key used to pin the
+// replicas of a stream created with CreateStream to the given server IDs.
+// Each value is a server ID, and there must be at least as many servers as
+// the stream's replication factor.
+const ReplicaServersMetadataKey = "liftbridge-replica-servers"
+
+// ObserverServersMetadataKey is the gRPC request metadata key used to add
+// observers to each partition of a stream created with CreateStream. Each
+// value is a server ID. Observers replicate the partition like followers, e.g.
+// to serve analytics reads, but never join the ISR, so publishes don't wait
+// on them, and are never elected leader. Observers cannot also be replicas.
+const ObserverServersMetadataKey = "liftbridge-observer-servers"
+
+// ReplicaMaxLagTimeMetadataKey is the gRPC request metadata key used to
+// override the server's ReplicaMaxLagTime for a stream created with
+// CreateStream. The value is a positive duration string, e.g. "5s".
+const ReplicaMaxLagTimeMetadataKey = "liftbridge-replica-max-lag-time"
+
+// ReplicaMaxLagOffsetsMetadataKey is the gRPC request metadata key used to
+// override the server's ReplicaMaxLagOffsets for a stream created with
+// CreateStream. The value is a positive integer.
+const ReplicaMaxLagOffsetsMetadataKey = "liftbridge-replica-max-lag-offsets"
+
+// ReplicaFetchTimeoutMetadataKey is the gRPC request metadata key used to
+// override the server's ReplicaFetchTimeout for a stream created with
+// CreateStream. The value is a positive duration string, e.g. "500ms".
+const ReplicaFetchTimeoutMetadataKey = "liftbridge-replica-fetch-timeout"
+
+// RequireKeyMetadataKey is the gRPC request metadata key used to make a stream
+// created with CreateStream reject messages without a key. The value must be
+// "true". This guarantees every message can be compacted by key.
+const RequireKeyMetadataKey = "liftbridge-require-key"
+
+// EmptyValueMetadataKey is the gRPC request metadata key used to set how a
+// stream created with CreateStream handles messages with an empty value. The
+// value must be "store", which stores them like any other message and is the
+// default, "reject", which rejects them, or "tombstone", which stores them
+// with the TombstoneHeader header marking the deletion of their key on
+// compacted streams. Nil and zero-length values are treated the same.
+const EmptyValueMetadataKey = "liftbridge-empty-value"
+
+// SchemaMetadataKey is the gRPC request metadata key used to make a stream
+// created with CreateStream validate messages with one of the server's
+// configured SchemaValidators. The value is the name the validator is
+// registered with. Messages failing validation are rejected.
+const SchemaMetadataKey = "liftbridge-schema"
+
+// IdleDeleteTimeMetadataKey is the gRPC request metadata key used to override
+// the server's IdleDeleteTime for a stream created with CreateStream. The
+// stream is deleted once it goes this long without publishes or subscribers.
+// The value is a positive duration string, e.g. "10m".
+const IdleDeleteTimeMetadataKey = "liftbridge-idle-delete-time"
+
+// RetentionMaxAgeMetadataKey is the gRPC request metadata key used to override
+// the server's RetentionMaxAge for a stream created with CreateStream. Log
+// segments whose newest message is older than this are deleted. The value is
+// a positive duration string, e.g. "24h".
+const RetentionMaxAgeMetadataKey = "liftbridge-retention-max-age"
+
+// QuotaMaxBytesMetadataKey is the gRPC request metadata key used to override
+// the server's QuotaMaxBytes for a stream created with CreateStream. The value
+// is a positive integer number of bytes.
+const QuotaMaxBytesMetadataKey = "liftbridge-quota-max-bytes"
+
+// RetentionMaxSegmentsMetadataKey is the gRPC request metadata key used to
+// override the server's RetentionMaxSegments for a stream created with
+// CreateStream. The oldest log segments beyond this number are deleted. The
+// value is a positive integer.
+const RetentionMaxSegmentsMetadataKey = "liftbridge-retention-max-segments"
+
+// MaxCommitWaitersMetadataKey is the gRPC request metadata key used to
+// override the server's MaxCommitWaiters for a stream created with
+// CreateStream. The value is a positive integer.
+const MaxCommitWaitersMetadataKey = "liftbridge-max-commit-waiters"
+
+// CompressionThresholdMetadataKey is the gRPC request metadata key used to
+// compress the values of messages on a stream created with CreateStream. The
+// value is a positive number of bytes. The partition leader compresses values
+// larger than this, leaving small ones uncompressed to avoid the overhead, and
+// marks them with the CodecHeader header. Subscriptions decompress them.
+const CompressionThresholdMetadataKey = "liftbridge-compression-threshold"
+
+// BatchBestEffortMetadataKey is the request metadata key used to make
+// Server.ImportMessages append the messages of a batch which are valid even if
+// others are not, rather than rejecting the whole batch, which is the default.
+// The value must be "true". The rejected messages are reported in a
+// *BatchError.
+const BatchBestEffortMetadataKey = "liftbridge-batch-best-effort"
+
+// TryPublishMetadataKey is the gRPC request metadata key used to make a
+// Publish fail fast with ResourceExhausted, rather than queueing the message,
+// if the partition leader is busy. The value must be "true". The request must
+// be sent to the partition leader, otherwise it fails with NOT_LEADER.
+const TryPublishMetadataKey = "liftbridge-try-publish"
+
+// PositionMetadataKey is the gRPC request metadata key used to include the
+// subscription's position in each message delivered on a Subscribe. When set
+// to "true", each message carries the DeliveredOffsetHeader and
+// HighWatermarkHeader headers so consumers can compute their lag without
+// additional requests.
+const PositionMetadataKey = "liftbridge-position"
+
+// OffsetOutOfRangeMetadataKey is the gRPC request metadata key used to control
+// the behavior of a Subscribe whose start offset is beyond the end of the log.
+// With "wait", the default, the subscription waits at the end of the log and
+// receives new messages as they are written. With "error", the Subscribe fails
+// with an OutOfRange status.
+const OffsetOutOfRangeMetadataKey = "liftbridge-offset-out-of-range"
+
+// OffsetResetMetadataKey is the gRPC request metadata key used to set the
+// policy applied when a Subscribe's start offset is before the oldest offset
+// of the log, e.g. a durable consumer resuming from an offset that retention
+// has since removed. With "earliest", the subscription starts at the oldest
+// offset. With "latest", it starts after the newest offset and receives only
+// new messages. Either way, a control message with the ControlOffsetReset
+// ControlHeader is delivered first so the reset is not silent. Without a
+// policy, the subscription starts at the oldest offset without notice.
+const OffsetResetMetadataKey = "liftbridge-offset-reset"
+
+// ConsumerMetadataKey is the gRPC request metadata key used to subscribe as a
+// durable consumer, which resumes where it left off. The value is the
+// consumer's name. The subscription starts at the consumer's cursor, the offset
+// after the last message delivered to it, or at the request's start position
+// if the consumer is new. The cursor is stored on the partition leader, so a
+// consumer resumes from the request's start position after a leader change.
+// Use Server.SetConsumerOffset to move it. A consumer can only have one open
+// subscription, can't follow multiple streams, and can't be combined with
+// StartTimeDeltaMetadataKey.
+const ConsumerMetadataKey = "liftbridge-consumer"
+
+// ManualAckMetadataKey is the gRPC request metadata key used to make a durable
+// consumer's cursor advance only as messages are acknowledged with Server.Ack,
+// rather than as they are delivered, so messages the consumer failed to
+// process are redelivered when it resubscribes. The value must be "true" and
+// ConsumerMetadataKey must be set.
+const ManualAckMetadataKey = "liftbridge-manual-ack"
+
+// MaxUnackedMetadataKey is the gRPC request metadata key used to bound the
+// number of messages delivered to a manual-ack consumer but not yet
+// acknowledged. The value is a positive integer. Delivery pauses once the
+// window is full and resumes as messages are acknowledged. It requires
+// ManualAckMetadataKey.
+const MaxUnackedMetadataKey = "liftbridge-max-unacked"
+
+// StartTimeDeltaMetadataKey is the gRPC request metadata key used to start a
+// Subscribe at the first message received within the given duration of the
+// server's current time, e.g. "5m" to tail the last five minutes. Since the
+// window is resolved against the server's clock, clients don't depend on their
+// own. The value is a positive duration string and overrides the request's
+// start position. If no messages fall in the window, the subscription waits
+// for new messages.
+const StartTimeDeltaMetadataKey = "liftbridge-start-time-delta"
+
+// SubscribeStreamsMetadataKey is the gRPC request metadata key used to follow
+// additional streams on a Subscribe. Each value is a stream name whose
+// partition with the requested partition ID is followed from the requested
+// start position, along with the requested stream. Messages from all of the
+// streams are delivered on the one subscription, each tagged with its source
+// stream in the StreamHeader header. Ordering is only guaranteed within each
+// stream. This server must be the leader of every followed partition. Since
+// offsets are per stream, a subscription following multiple streams cannot be
+// resumed from a single offset.
+const SubscribeStreamsMetadataKey = "liftbridge-subscribe-streams"
+
+// CatchUpMetadataKey is the gRPC request metadata key used to signal when a
+// Subscribe has caught up with the partition. When set to "true", a marker
+// message with the CaughtUpHeader header is delivered once every message up
+// to the high watermark at the time of the Subscribe has been delivered, i.e.
+// when the subscription switches from replaying history to live tailing. The
+// marker is not a message in the log. Its offset is that high watermark and
+// it has no value.
+const CatchUpMetadataKey = "liftbridge-catch-up"
+
+// SnapshotMetadataKey is the gRPC request metadata key used to subscribe to a
+// snapshot of the latest message per key, e.g. to materialize a compacted
+// stream as a table. When set to "true", only the latest message for each key
+// among the messages from the start position up to the high watermark at the
+// time of the Subscribe is delivered, as if the log were fully compacted. The
+// messages after it are delivered as they are written. Messages without a key
+// are always delivered, as they are by compaction. Combine it with
+// CatchUpMetadataKey to be signaled when the snapshot is complete.
+const SnapshotMetadataKey = "liftbridge-snapshot"
+
+// ControlMetadataKey is the gRPC request metadata key used to deliver control
+// messages on a Subscribe when the partition's leader changes or its log is
+// truncated, so long-lived consumers can react. When set to "true", such
+// changes deliver a message with the ControlHeader header identifying the
+// kind of change. Control messages are not messages in the log. Their offset
+// is that of the last message delivered on the subscription and they have no
+// value.
+const ControlMetadataKey = "liftbridge-control"
+
+// MaxRateMetadataKey is the gRPC request metadata key used to limit the rate
+// messages are delivered on a Subscribe, e.g. to throttle replaying a large
+// history. The value is a positive number of messages per second. Deliveries
+// are spread evenly. Control and caught-up markers are not limited.
+const MaxRateMetadataKey = "liftbridge-max-rate"
+
+// MaxInflightBytesMetadataKey is the gRPC request metadata key used to bound
+// the memory a Subscribe uses for messages not yet consumed by the client,
+// e.g. when messages are large. The value is a positive number of bytes of
+// message keys and values. Delivery pauses once the budget is used up and
+// resumes as the client consumes messages. A message larger than the budget
+// is still delivered, by itself.
+const MaxInflightBytesMetadataKey = "liftbridge-max-inflight-bytes"
+
+// ReadUncommittedMetadataKey is the gRPC request metadata key used to read
+// messages past the high watermark on a Subscribe, e.g. for tooling debugging
+// replication. When set to "true", messages are delivered as soon as the
+// partition leader appends them rather than once they are committed, and
+// those not yet committed when delivered carry the UncommittedHeader header.
+// This is unsafe for normal consumers: uncommitted messages may be truncated
+// on a leader failover and never committed, and their offsets reused.
+const ReadUncommittedMetadataKey = "liftbridge-read-uncommitted"
+
+// PassThroughMetadataKey is the gRPC request metadata key used to receive
+// messages on a Subscribe in the form they are stored, e.g. for a proxy
+// forwarding them as-is. When set to "true", compressed values are delivered
+// without being decompressed, along with the CodecHeader header naming their
+// codec.
+const PassThroughMetadataKey = "liftbridge-pass-through"
+
+// ConsistentReadMetadataKey is the gRPC request metadata key used to ensure a
+// Subscribe is served by the current partition leader, e.g. to read a message
+// right after publishing it with AckPolicy_ALL. When set to "true", the server
+// confirms with the metadata leader that it is still the leader for the
+// partition's current leader epoch before subscribing, so a deposed leader
+// with stale metadata can't serve a read missing messages committed by its
+// successor. The subscription fails with NOT_LEADER if it isn't. This adds a
+// round trip to the metadata leader and can't be combined with
+// ReadUncommittedMetadataKey.
+const ConsistentReadMetadataKey = "liftbridge-consistent-read"
+
+const (
+	// MetadataLeaderMetadataKey is the gRPC response header set on
+	// FetchMetadata containing the ID of the server leading the metadata Raft
+	// group, as seen by the responding server. It is empty if there is no
+	// known leader.
+	MetadataLeaderMetadataKey = "liftbridge-metadata-leader"
+
+	// MetadataFollowersMetadataKey is the gRPC response header set on
+	// FetchMetadata containing the IDs of the metadata Raft group members
+	// other than the leader, one value per server.
+	MetadataFollowersMetadataKey = "liftbridge-metadata-followers"
+
+	// MetadataTermMetadataKey is the gRPC response header set on
+	// FetchMetadata containing the responding server's current metadata Raft
+	// term, as a decimal string.
+	MetadataTermMetadataKey = "liftbridge-metadata-term"
+
+	// FencingEpochMetadataKey is the gRPC response header set on
+	// FetchMetadata containing the highest fencing token the cluster has
+	// accepted, as seen by the responding server, as a decimal string.
+	FencingEpochMetadataKey = "liftbridge-fencing-epoch"
+
+	// UnderReplicatedStreamsMetadataKey is the gRPC response header set on
+	// FetchMetadata containing the number of streams with at least one
+	// partition whose ISR is smaller than its replication factor, as a
+	// decimal string.
+	UnderReplicatedStreamsMetadataKey = "liftbridge-under-replicated-streams"
+
+	// MetadataTTLMetadataKey is the gRPC response header set on
+	// FetchMetadata containing how long clients should cache the metadata
+	// before fetching it again, as a duration string, so a client which
+	// isn't told about a leader change, e.g. because it only publishes
+	// through NATS, still picks it up. It's only set if the server is
+	// configured with a MetadataClientTTL. Clients should also invalidate
+	// the metadata of a partition on a NOT_LEADER error, which carries the
+	// partition's leader if known, see GetErrorLeader.
+	MetadataTTLMetadataKey = "liftbridge-metadata-ttl"
+)
+
+// FencingTokenMetadataKey is the gRPC request metadata key used to fence an
+// admin operation, e.g. DeleteStream, so that an external controller which
+// has been superseded can't apply it. The value is a positive integer which
+// controllers should derive from their own leadership, e.g. an election
+// epoch, so that newer controllers use higher tokens. The operation fails
+// with Aborted if the token is below the cluster's fencing epoch, which is
+// the highest token accepted so far, and otherwise raises the fencing epoch
+// to the token. Operations without a token are not fenced.
+const FencingTokenMetadataKey = "liftbridge-fencing-token"
+
+const (
+	// DeliveredOffsetHeader is the message header containing the highest
+	// offset delivered on the subscription, as a decimal string.
+	DeliveredOffsetHeader = "deliveredOffset"
+
+	// HighWatermarkHeader is the message header containing the partition's
+	// high watermark at the time the message was delivered, as a decimal
+	// string.
+	HighWatermarkHeader = "highWatermark"
+
+	// DeliverAtHeader is the message header containing the time, in Unix
+	// nanoseconds as a decimal string, before which a message is not
+	// delivered to subscribers. Subscriptions hold the message, and the
+	// messages after it, until then so that partition order is preserved.
+	// Delivery is never held longer than the configured max delivery delay.
+	DeliverAtHeader = "deliverAt"
+
+	// MinFollowersHeader is the message header containing the number of
+	// followers, not counting the leader, as a decimal string, which must
+	// have the message before the partition leader commits it. The messages
+	// after it are not committed until it is.
+	MinFollowersHeader = "minFollowers"
+
+	// SourceSubjectHeader is the message header containing the NATS subject
+	// a message imported with StartImport was received on.
+	SourceSubjectHeader = "sourceSubject"
+
+	// MessageIDHeader is the message header containing the ID generated by
+	// the configured MessageIDFunc. This header is reserved and any value set
+	// by the publisher is overwritten when a MessageIDFunc is configured.
+	MessageIDHeader = "messageID"
+
+	// StreamHeader is the message header containing the name of the stream a
+	// message was read from on a subscription following multiple streams.
+	StreamHeader = "stream"
+
+	// CaughtUpHeader is the message header, with the value "true", marking
+	// the message delivered on a subscription when it catches up with the
+	// partition's high watermark at the time it was created.
+	CaughtUpHeader = "caughtUp"
+
+	// UncommittedHeader is the message header, with the value "true",
+	// marking a message delivered on a subscription created with
+	// ReadUncommittedMetadataKey which was not yet committed when it was
+	// delivered.
+	UncommittedHeader = "uncommitted"
+
+	// DeliveryAttemptHeader is the message header containing the number of
+	// times a message has been delivered to a manual-ack durable consumer,
+	// including this delivery, as a decimal string. It's 1 on the first
+	// delivery and incremented each time the message is redelivered because
+	// it wasn't acknowledged, so consumers can set aside messages they
+	// repeatedly fail to process.
+	DeliveryAttemptHeader = "deliveryAttempt"
+
+	// TombstoneHeader is the message header, with the value "true", marking
+	// a message with an empty value as a tombstone on streams created with
+	// the "tombstone" EmptyValueMetadataKey policy. A tombstone indicates its
+	// key was deleted, and compaction retains it as the key's latest message
+	// in place of its previous values.
+	TombstoneHeader = "tombstone"
+
+	// ControlHeader is the message header identifying a control message
+	// delivered on a subscription created with ControlMetadataKey. Its value
+	// is ControlLeaderChanged, ControlTruncated, or ControlOffsetReset.
+	ControlHeader = "control"
+
+	// LeaderHeader is the control message header containing the ID of the
+	// partition's new leader.
+	LeaderHeader = "leader"
+
+	// LeaderEpochHeader is the control message header containing the
+	// partition's new leader epoch as a decimal string.
+	LeaderEpochHeader = "leaderEpoch"
+
+	// RequestedOffsetHeader is the control message header containing the
+	// start offset requested by a subscription whose offset was reset as a
+	// decimal string.
+	RequestedOffsetHeader = "requestedOffset"
+
+	// OldestOffsetHeader is the control message header containing the oldest
+	// offset of the partition's log after a truncation as a decimal string.
+	OldestOffsetHeader = "oldestOffset"
+
+	// NewestOffsetHeader is the control message header containing the newest
+	// offset of the partition's log after a truncation as a decimal string.
+	NewestOffsetHeader = "newestOffset"
+)
+
+// ProducerSessionMetadataKey is the gRPC request metadata key used to order
+// publishes from a producer. Publishes sent to the same server with the same
+// session ID are appended in the order of their ProducerSequenceMetadataKey,
+// even if they are sent concurrently.
+const ProducerSessionMetadataKey = "liftbridge-producer-session"
+
+// ProducerSequenceMetadataKey is the gRPC request metadata key carrying the
+// sequence number of a publish within its producer session. Sequence numbers
+// start at 0 for each session and increase by one with each publish. It must
+// be set if ProducerSessionMetadataKey is.
+const ProducerSequenceMetadataKey = "liftbridge-producer-sequence"
+
+// DeliverAtMetadataKey is the gRPC request metadata key used to schedule a
+// message sent with Publish. The value is a time in Unix nanoseconds as a
+// positive decimal integer and is stored on the message as the
+// DeliverAtHeader. Publish fails with InvalidArgument if it is invalid or
+// further out than the configured max delivery delay.
+const DeliverAtMetadataKey = "liftbridge-deliver-at"
+
+// DeliverAt returns a copy of the Context which schedules a message published
+// with it to be delivered to subscribers no earlier than the given time. The
+// message is stored and replicated immediately.
+func DeliverAt(ctx context.Context, t time.Time) context.Context {
+	return grpcMetadata.AppendToOutgoingContext(
+		ctx, DeliverAtMetadataKey, strconv.FormatInt(t.UnixNano(), 10))
+}
+
+// AckMinFollowersMetadataKey is the gRPC request metadata key used to require
+// that a message sent with Publish is replicated to at least the given number
+// of followers, not counting the leader, before it's committed and acked. The
+// value is a positive integer no greater than the stream's replication factor
+// minus one and is stored on the message as the MinFollowersHeader.
+const AckMinFollowersMetadataKey = "liftbridge-ack-min-followers"
+
+// AckMinFollowers returns a copy of the Context which requires a message
+// published with it to be replicated to at least the given number of
+// followers, not counting the leader, before it's committed and acked.
+func AckMinFollowers(ctx context.Context, followers int) context.Context {
+	return grpcMetadata.AppendToOutgoingContext(
+		ctx, AckMinFollowersMetadataKey, strconv.Itoa(followers))
+}
+
 // apiServer implements the gRPC server interface clients interact with.
 type apiServer struct {
 	*Server
@@ -29,47 +453,163 @@ func (a *apiServer) CreateStream(ctx context.Context, req *client.CreateStreamRe
 	*client.CreateStreamResponse, error) {
 
 	resp := &client.CreateStreamResponse{}
+	setCreateStreamDefaults(req)
+	a.logger.Debugf("api: CreateStream [subject=%s, name=%s, partitions=%d, replicationFactor=%d]",
+		req.Subject, req.Name, req.Partitions, req.ReplicationFactor)
+
+	op, st := newCreateStreamOp(ctx, req)
+	if st == nil {
+		schema, _ := getMetadataValue(ctx, SchemaMetadataKey)
+		st = a.checkSchema(schema)
+	}
+	if st != nil {
+		a.logger.Errorf("api: Failed to create stream: %v", st.Message())
+		return nil, st.Err()
+	}
+	applied := func(st *status.Status) bool {
+		return st.Code() == codes.AlreadyExists && a.streamCreatedBy(op)
+	}
+	if e := retryMetadataOp(ctx, applied, func() *status.Status {
+		return a.metadata.CreateStream(ctx, op)
+	}); e != nil {
+		if e.Code() != codes.AlreadyExists {
+			a.logger.Errorf("api: Failed to create stream %v: %v", req.Name, e.Err())
+		}
+		return nil, withDefaultErrorCode(e).Err()
+	}
+
+	return resp, nil
+}
+
+// streamCreatedBy indicates if the stream the CreateStreamOp creates exists
+// with the same subject, group, replication factor, and number of partitions,
+// i.e. it was created by an earlier attempt of the same request rather than by
+// a different request for the same name.
+func (a *apiServer) streamCreatedBy(op *proto.CreateStreamOp) bool {
+	if len(op.Partitions) == 0 {
+		return false
+	}
+	stream := a.metadata.GetStream(op.Partitions[0].Stream)
+	if stream == nil {
+		return false
+	}
+	partitions := stream.GetPartitions()
+	if len(partitions) != len(op.Partitions) {
+		return false
+	}
+	for _, want := range op.Partitions {
+		p, ok := partitions[want.Id]
+		if !ok {
+			return false
+		}
+		p.mu.RLock()
+		matches := p.Subject == want.Subject && p.Group == want.Group &&
+			p.ReplicationFactor == want.ReplicationFactor
+		p.mu.RUnlock()
+		if !matches {
+			return false
+		}
+	}
+	return true
+}
+
+// setCreateStreamDefaults sets the replication factor and number of
+// partitions of the CreateStreamRequest to 1 if they are not set.
+func setCreateStreamDefaults(req *client.CreateStreamRequest) {
 	if req.ReplicationFactor == 0 {
 		req.ReplicationFactor = 1
 	}
 	if req.Partitions == 0 {
 		req.Partitions = 1
 	}
-	a.logger.Debugf("api: CreateStream [subject=%s, name=%s, partitions=%d, replicationFactor=%d]",
-		req.Subject, req.Name, req.Partitions, req.ReplicationFactor)
+}
+
+// newCreateStreamOp validates the CreateStreamRequest and returns the
+// CreateStreamOp for it, including any stream settings from the request
+// metadata. The request must already have its defaults set with
+// setCreateStreamDefaults. An InvalidArgument status is returned if the
+// request is invalid.
+func newCreateStreamOp(ctx context.Context, req *client.CreateStreamRequest) (
+	*proto.CreateStreamOp, *status.Status) {
 
 	if req.Name == "" {
-		a.logger.Errorf("api: Failed to create stream: name cannot be empty")
-		return nil, status.Error(codes.InvalidArgument, "Name cannot be empty")
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, "Name cannot be empty")
 	}
 	// TODO: Check if valid NATS subject?
 	if req.Subject == "" {
-		a.logger.Errorf("api: Failed to create stream: subject cannot be empty")
-		return nil, status.Error(codes.InvalidArgument, "Subject cannot be empty")
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, "Subject cannot be empty")
 	}
 
-	var err error
+	maxLagTime, err := getMetadataDuration(ctx, ReplicaMaxLagTimeMetadataKey)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+	maxLagOffsets, err := getMetadataInt(ctx, ReplicaMaxLagOffsetsMetadataKey, 64)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+	fetchTimeout, err := getMetadataDuration(ctx, ReplicaFetchTimeoutMetadataKey)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+	emptyValue, err := getEmptyValuePolicy(ctx)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+	idleDeleteTime, err := getMetadataDuration(ctx, IdleDeleteTimeMetadataKey)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+	maxCommitWaiters, err := getMetadataInt(ctx, MaxCommitWaitersMetadataKey, 32)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+	retentionMaxAge, err := getMetadataDuration(ctx, RetentionMaxAgeMetadataKey)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+	quotaMaxBytes, err := getMetadataInt(ctx, QuotaMaxBytesMetadataKey, 64)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+	retentionMaxSegments, err := getMetadataInt(ctx, RetentionMaxSegmentsMetadataKey, 32)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+	compressionThreshold, err := getMetadataInt(ctx, CompressionThresholdMetadataKey, 64)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+	schema, _ := getMetadataValue(ctx, SchemaMetadataKey)
+
+	partitions := make([]*proto.Partition, req.Partitions)
 	for i := int32(0); i < req.Partitions; i++ {
-		if e := a.metadata.CreatePartition(ctx, &proto.CreatePartitionOp{
-			Partition: &proto.Partition{
-				Subject:           req.Subject,
-				Stream:            req.Name,
-				Group:             req.Group,
-				ReplicationFactor: req.ReplicationFactor,
-				Id:                i,
-			},
-		}); e != nil {
-			// In the case of partial failure, let the caller retry.
-			if e.Code() == codes.AlreadyExists {
-				err = e.Err()
-			} else {
-				a.logger.Errorf("api: Failed to create stream partition %d: %v", i, e.Err())
-				return nil, e.Err()
-			}
+		partitions[i] = &proto.Partition{
+			Subject:              req.Subject,
+			Stream:               req.Name,
+			Group:                req.Group,
+			ReplicationFactor:    req.ReplicationFactor,
+			Id:                   i,
+			ReplicaMaxLagTime:    int64(maxLagTime),
+			ReplicaMaxLagOffsets: maxLagOffsets,
+			ReplicaFetchTimeout:  int64(fetchTimeout),
+			RequireKey:           getMetadataFlag(ctx, RequireKeyMetadataKey),
+			EmptyValue:           emptyValue,
+			Schema:               schema,
+			IdleDeleteTime:       int64(idleDeleteTime),
+			MaxCommitWaiters:     int32(maxCommitWaiters),
+			RetentionMaxAge:      int64(retentionMaxAge),
+			QuotaMaxBytes:        quotaMaxBytes,
+			RetentionMaxSegments: int32(retentionMaxSegments),
+			CompressionThreshold: compressionThreshold,
 		}
 	}
 
-	return resp, err
+	return &proto.CreateStreamOp{
+		Partitions: partitions,
+		Servers:    getMetadataValues(ctx, ReplicaServersMetadataKey),
+		Observers:  getMetadataValues(ctx, ObserverServersMetadataKey),
+	}, nil
 }
 
 // DeleteStream deletes a stream attached to a NATS subject.
@@ -80,11 +620,15 @@ func (a *apiServer) DeleteStream(ctx context.Context, req *client.DeleteStreamRe
 	a.logger.Debugf("api: DeleteStream [name=%s]",
 		req.Name)
 
-	if e := a.metadata.DeleteStream(ctx, &proto.DeleteStreamOp{
-		Stream: req.Name,
+	op := &proto.DeleteStreamOp{Stream: req.Name}
+	deleted := func(st *status.Status) bool {
+		return st.Code() == codes.NotFound
+	}
+	if e := retryMetadataOp(ctx, deleted, func() *status.Status {
+		return a.metadata.DeleteStream(ctx, op)
 	}); e != nil {
 		a.logger.Errorf("api: Failed to delete stream %v: %v", req.Name, e.Err())
-		return nil, e.Err()
+		return nil, withDefaultErrorCode(e).Err()
 	}
 
 	return resp, nil
@@ -100,13 +644,16 @@ func (a *apiServer) PauseStream(ctx context.Context, req *client.PauseStreamRequ
 	a.logger.Debugf("api: PauseStream [name=%s, partitions=%v, resumeAll=%v]",
 		req.Name, req.Partitions, req.ResumeAll)
 
-	if e := a.metadata.PauseStream(ctx, &proto.PauseStreamOp{
+	op := &proto.PauseStreamOp{
 		Stream:     req.Name,
 		Partitions: req.Partitions,
 		ResumeAll:  req.ResumeAll,
+	}
+	if e := retryMetadataOp(ctx, nil, func() *status.Status {
+		return a.metadata.PauseStream(ctx, op)
 	}); e != nil {
 		a.logger.Errorf("api: Failed to pause stream %v: %v", req.Name, e.Err())
-		return nil, e.Err()
+		return nil, withDefaultErrorCode(e).Err()
 	}
 
 	return resp, nil
@@ -120,26 +667,47 @@ func (a *apiServer) Subscribe(req *client.SubscribeRequest, out client.API_Subsc
 	a.logger.Debugf("api: Subscribe [stream=%s, partition=%d, start=%s, offset=%d, timestamp=%d]",
 		req.Stream, req.Partition, req.StartPosition, req.StartOffset, req.StartTimestamp)
 
-	partition := a.metadata.GetPartition(req.Stream, req.Partition)
-	if partition == nil {
-		a.logger.Errorf("api: Failed to subscribe to partition "+
-			"[stream=%s, partition=%d]: no such partition",
-			req.Stream, req.Partition)
-		return status.Error(codes.NotFound, "No such partition")
+	partitions, err := a.getSubscribePartitions(out.Context(), req)
+	if err != nil {
+		return apiError(err)
+	}
+
+	if getMetadataFlag(out.Context(), ConsistentReadMetadataKey) {
+		if st := a.confirmSubscribeLeaders(out.Context(), partitions); st != nil {
+			return st.Err()
+		}
+	}
+
+	consumer, st := a.acquireConsumer(out.Context(), partitions)
+	if st != nil {
+		return st.Err()
+	}
+	if consumer != nil {
+		defer consumer.release()
+		req = consumer.resume(req)
 	}
 
-	leader, _ := partition.GetLeader()
-	if leader != a.config.Clustering.ServerID {
-		a.logger.Errorf("api: Failed to subscribe to partition %s: server not stream leader", partition)
-		return status.Error(codes.FailedPrecondition, "Server not partition leader")
+	maxInflight, inflightErr := getMetadataInt(out.Context(), MaxInflightBytesMetadataKey, 64)
+	if inflightErr != nil {
+		return newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, inflightErr.Error()).Err()
 	}
+	// The budget is shared by all the partitions of the subscription.
+	budget := newInflightBudget(maxInflight)
 
 	cancel := make(chan struct{})
 	defer close(cancel)
-	ch, errCh, err := a.subscribe(out.Context(), partition, req, cancel)
-	if err != nil {
-		a.logger.Errorf("api: Failed to subscribe to partition %s: %v", partition, err.Err())
-		return err.Err()
+	var (
+		ch    <-chan *client.Message
+		errCh <-chan *status.Status
+	)
+	if len(partitions) == 1 {
+		ch, errCh, st = a.subscribe(out.Context(), partitions[0], req, budget, cancel)
+	} else {
+		ch, errCh, st = a.subscribeMultiplexed(out.Context(), partitions, req, budget, cancel)
+	}
+	if st != nil {
+		a.logger.Errorf("api: Failed to subscribe to partition %s: %v", partitions[0], st.Err())
+		return withDefaultErrorCode(st).Err()
 	}
 
 	// Send an empty message which signals the subscription was successfully
@@ -153,11 +721,20 @@ func (a *apiServer) Subscribe(req *client.SubscribeRequest, out client.API_Subsc
 		case <-out.Context().Done():
 			return nil
 		case m := <-ch:
+			// Manual-ack consumers wait for room in their window.
+			if consumer != nil && !consumer.deliver(out.Context(), m) {
+				return nil
+			}
+			// Send blocks while the client isn't consuming messages.
 			if err := out.Send(m); err != nil {
 				return err
 			}
+			budget.release(inflightSize(m))
+			if consumer != nil {
+				consumer.delivered(m)
+			}
 		case err := <-errCh:
-			return err.Err()
+			return withDefaultErrorCode(err).Err()
 		}
 	}
 }
@@ -171,7 +748,31 @@ func (a *apiServer) FetchMetadata(ctx context.Context, req *client.FetchMetadata
 	resp, err := a.metadata.FetchMetadata(ctx, req)
 	if err != nil {
 		a.logger.Errorf("api: Failed to fetch metadata: %v", err.Err())
-		return nil, err.Err()
+		return nil, withDefaultErrorCode(err).Err()
+	}
+
+	// The client API has no fields for Raft state or replication health, so
+	// report the metadata leader, followers, and term and the number of
+	// under-replicated streams in the response headers.
+	leader, followers, term, roleErr := a.metadata.getMetadataRoles()
+	if roleErr != nil {
+		a.logger.Errorf("api: Failed to fetch metadata: %v", roleErr)
+		return nil, newStatus(codes.Internal, ErrorCodeInternal, roleErr.Error()).Err()
+	}
+	md := grpcMetadata.Pairs(
+		MetadataLeaderMetadataKey, leader,
+		MetadataTermMetadataKey, strconv.FormatUint(term, 10),
+		FencingEpochMetadataKey, strconv.FormatUint(a.metadata.GetFencingEpoch(), 10),
+		UnderReplicatedStreamsMetadataKey, strconv.Itoa(a.metadata.UnderReplicatedStreams()),
+	)
+	for _, follower := range followers {
+		md.Append(MetadataFollowersMetadataKey, follower)
+	}
+	if ttl := a.config.MetadataClientTTL; ttl > 0 {
+		md.Append(MetadataTTLMetadataKey, ttl.String())
+	}
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		a.logger.Warnf("api: Failed to set metadata role headers: %v", err)
 	}
 
 	return resp, nil
@@ -180,9 +781,27 @@ func (a *apiServer) FetchMetadata(ctx context.Context, req *client.FetchMetadata
 // Publish a new message to a subject. If the AckPolicy is not NONE and a
 // deadline is provided, this will synchronously block until the ack is
 // received. If the ack is not received in time, a DeadlineExceeded status code
-// is returned.
+// is returned. Every error carries an ErrorCode.
 func (a *apiServer) Publish(ctx context.Context, req *client.PublishRequest) (
 	*client.PublishResponse, error) {
+	resp, err := a.publish(ctx, req)
+	return resp, apiError(err)
+}
+
+// publish implements Publish.
+func (a *apiServer) publish(ctx context.Context, req *client.PublishRequest) (
+	*client.PublishResponse, error) {
+	session, seq, err := getProducerSequence(ctx)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error()).Err()
+	}
+	if session != "" {
+		// Mark the sequence number used if the publish fails before it's
+		// forwarded so that the session's next publish doesn't wait on it.
+		// This does nothing once it was let through.
+		defer a.producerSequencer.skip(session, seq)
+	}
+
 	subject, err := a.getPublishSubject(req)
 	if err != nil {
 		return nil, err
@@ -194,10 +813,108 @@ func (a *apiServer) Publish(ctx context.Context, req *client.PublishRequest) (
 		return nil, err
 	}
 
+	// Look up the partition once for the checks below. It's nil if the
+	// publish targets a subject or the partition doesn't exist.
+	var partition *partition
+	if req.Stream != "" {
+		partition = a.metadata.GetPartition(req.Stream, req.Partition)
+	}
+
+	if len(req.Key) == 0 && partition != nil && partition.GetRequireKey() {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+			fmt.Sprintf("Stream %s requires a message key", req.Stream)).Err()
+	}
+
+	if len(req.Value) == 0 && partition != nil &&
+		partition.GetEmptyValue() == proto.EmptyValue_REJECT {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+			fmt.Sprintf("Stream %s rejects messages with an empty value", req.Stream)).Err()
+	}
+
+	if partition != nil {
+		if err := partition.validateSchema(req.Key, req.Value); err != nil {
+			return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+				fmt.Sprintf("Message rejected by stream %s: %v", req.Stream, err)).Err()
+		}
+	}
+
+	if req.AckPolicy == client.AckPolicy_ALL && partition != nil {
+		if isrSize, minISR := partition.ISRSize(), a.config.Clustering.MinISR; isrSize < minISR {
+			return nil, newStatus(codes.FailedPrecondition, ErrorCodeInsufficientISR,
+				fmt.Sprintf("Partition %s ISR size (%d) below minimum (%d)",
+					partition, isrSize, minISR)).Err()
+		}
+		if partition.PendingAcksFull() {
+			return nil, newStatus(codes.ResourceExhausted, ErrorCodeResourceExhausted,
+				fmt.Sprintf("Partition %s has too many pending acks", partition)).Err()
+		}
+		if partition.AppendLatencyExceeded() {
+			return nil, newStatus(codes.ResourceExhausted, ErrorCodeResourceExhausted,
+				fmt.Sprintf("Partition %s append latency exceeds %s",
+					partition, a.config.Streams.MaxAppendLatency)).Err()
+		}
+	}
+
+	if partition != nil && partition.QuotaExceeded() {
+		return nil, newStatus(codes.ResourceExhausted, ErrorCodeResourceExhausted,
+			fmt.Sprintf("Partition %s has reached its quota of %d bytes",
+				partition, partition.quotaMaxBytes())).Err()
+	}
+
+	if getMetadataFlag(ctx, TryPublishMetadataKey) {
+		if err := a.checkTryPublish(req, partition); err != nil {
+			return nil, err
+		}
+	}
+
+	if partition != nil && partition.IsElectingLeader() {
+		if err := a.waitForLeaderElection(ctx, partition); err != nil {
+			return nil, err
+		}
+	}
+
+	followers, err := getMetadataInt(ctx, AckMinFollowersMetadataKey, 32)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error()).Err()
+	}
+	deliverAt, err := getMetadataInt(ctx, DeliverAtMetadataKey, 64)
+	if err != nil {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error()).Err()
+	}
+	if deliverAt > 0 {
+		if maxDelay := a.config.Streams.MaxDeliveryDelay; time.Until(time.Unix(0, deliverAt)) > maxDelay {
+			return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+				fmt.Sprintf("Delivery time is more than %s in the future", maxDelay)).Err()
+		}
+	}
+	if followers > 0 && partition != nil && int(followers) > len(partition.GetReplicas())-1 {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+			fmt.Sprintf("Stream %s has %d followers, cannot require %d",
+				req.Stream, len(partition.GetReplicas())-1, followers)).Err()
+	}
+
 	if req.AckInbox == "" {
 		req.AckInbox = nuid.Next()
 	}
 
+	// The reserved headers are only set from their validated metadata keys.
+	delete(req.Headers, DeliverAtHeader)
+	delete(req.Headers, MinFollowersHeader)
+
+	if deliverAt > 0 {
+		if req.Headers == nil {
+			req.Headers = make(map[string][]byte)
+		}
+		req.Headers[DeliverAtHeader] = []byte(strconv.FormatInt(deliverAt, 10))
+	}
+
+	if followers > 0 {
+		if req.Headers == nil {
+			req.Headers = make(map[string][]byte)
+		}
+		req.Headers[MinFollowersHeader] = []byte(strconv.FormatInt(followers, 10))
+	}
+
 	msg := &client.Message{
 		Key:           req.Key,
 		Value:         req.Value,
@@ -216,14 +933,46 @@ func (a *apiServer) Publish(ctx context.Context, req *client.PublishRequest) (
 		return nil, err
 	}
 
-	// If AckPolicy is NONE or a timeout isn't specified, then we will fire and
-	// forget.
+	// Bound the publishes waiting on the message to be committed for the
+	// stream. This is done before waiting on the producer session so that a
+	// blocked publish doesn't hold up the session.
 	var (
-		resp           = new(client.PublishResponse)
 		_, hasDeadline = ctx.Deadline()
+		waits          = req.AckPolicy != client.AckPolicy_NONE && hasDeadline
 	)
-	if req.AckPolicy == client.AckPolicy_NONE || !hasDeadline {
-		if err := a.ncPublishes.Publish(subject, buf); err != nil {
+	if waits && req.AckPolicy == client.AckPolicy_ALL && partition != nil {
+		done, err := a.commitWaiters.acquire(ctx, req.Stream,
+			partition.maxCommitWaiters(), a.config.Streams.CommitWaitersPolicy)
+		if err == errCommitWaitersFull {
+			return nil, newStatus(codes.ResourceExhausted, ErrorCodeResourceExhausted,
+				fmt.Sprintf("Stream %s has too many publishes waiting on commit", req.Stream)).Err()
+		}
+		if err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		defer done()
+	}
+
+	// Wait for the producer session's preceding publishes to be forwarded.
+	release := func() {}
+	if session != "" {
+		release, err = a.producerSequencer.acquire(ctx, session, seq)
+		if err == errSequenceTooLow {
+			return nil, newStatus(codes.FailedPrecondition, ErrorCodeSequenceTooLow,
+				fmt.Sprintf("Producer session %s sequence %d: %v", session, seq, err)).Err()
+		}
+		if err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+	}
+
+	// If AckPolicy is NONE or a timeout isn't specified, then we will fire and
+	// forget.
+	resp := new(client.PublishResponse)
+	if !waits {
+		err := a.ncPublishes.Publish(subject, buf)
+		release()
+		if err != nil {
 			a.logger.Errorf("api: Failed to publish message: %v", err)
 			return nil, err
 		}
@@ -231,23 +980,69 @@ func (a *apiServer) Publish(ctx context.Context, req *client.PublishRequest) (
 	}
 
 	// Otherwise we need to publish and wait for the ack.
-	resp.Ack, err = a.publishSync(ctx, subject, req.AckInbox, buf)
+	resp.Ack, err = a.publishSync(ctx, subject, req.AckInbox, buf, release)
 	return resp, err
 }
 
+// checkTryPublish returns an error if a publish to the given partition, nil if
+// it doesn't exist, which should fail fast can't be accepted without waiting.
+// Whether the partition leader is busy is only known to the leader itself, so
+// the publish fails with NOT_LEADER if it wasn't sent to it.
+func (a *apiServer) checkTryPublish(req *client.PublishRequest, partition *partition) error {
+	if req.Stream == "" {
+		return newStatus(codes.FailedPrecondition, ErrorCodeNotLeader,
+			"TryPublish requires a stream").Err()
+	}
+	if partition == nil {
+		return newStatus(codes.NotFound, ErrorCodePartitionNotFound,
+			fmt.Sprintf("No such partition: %d", req.Partition)).Err()
+	}
+	if leader, _ := partition.GetLeader(); leader != a.config.Clustering.ServerID {
+		return newNotLeaderStatus(fmt.Sprintf("Server not leader of partition %s", partition), leader).Err()
+	}
+	if partition.IsBusy() {
+		return newStatus(codes.ResourceExhausted, ErrorCodeBusy,
+			fmt.Sprintf("Partition %s is busy", partition)).Err()
+	}
+	return nil
+}
+
+// waitForLeaderElection handles a publish to a partition which is electing a
+// new leader according to the PublishElectionPolicy. It returns an error if
+// the publish should fail rather than be sent to the new leader.
+func (a *apiServer) waitForLeaderElection(ctx context.Context, partition *partition) error {
+	if a.config.Clustering.PublishElectionPolicy == PublishElectionFail {
+		return newStatus(codes.FailedPrecondition, ErrorCodeLeaderElection,
+			fmt.Sprintf("Partition %s is electing a new leader", partition)).Err()
+	}
+	wctx, cancel := context.WithTimeout(ctx, a.config.Clustering.PublishElectionTimeout)
+	defer cancel()
+	if partition.waitForLeaderElection(wctx) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return newStatus(codes.FailedPrecondition, ErrorCodeLeaderElection,
+		fmt.Sprintf("Partition %s did not elect a new leader within %s",
+			partition, a.config.Clustering.PublishElectionTimeout)).Err()
+}
+
 func (a *apiServer) resumeStream(ctx context.Context, streamName string, partitionID int32) error {
 	if streamName == "" {
 		return nil
 	}
 	stream := a.metadata.GetStream(streamName)
 	if stream == nil {
-		return status.Error(codes.NotFound, fmt.Sprintf("No such stream: %s", streamName))
+		return newStatus(codes.NotFound, ErrorCodeStreamNotFound,
+			fmt.Sprintf("No such stream: %s", streamName)).Err()
 	}
 	if !stream.GetResumeAll() {
 		// Just resume the partition being published to if it's paused.
 		partition := stream.GetPartition(partitionID)
 		if partition == nil {
-			return status.Error(codes.NotFound, fmt.Sprintf("No such partition: %d", partitionID))
+			return newStatus(codes.NotFound, ErrorCodePartitionNotFound,
+				fmt.Sprintf("No such partition: %d", partitionID)).Err()
 		}
 		if partition.IsPaused() {
 			if e := a.unpausePartition(ctx, partition.Partition); e != nil {
@@ -284,11 +1079,13 @@ func (a *apiServer) getPublishSubject(req *client.PublishRequest) (string, error
 		return req.Subject, nil
 	}
 	if req.Stream == "" {
-		return "", status.Error(codes.InvalidArgument, "No stream or subject provided")
+		return "", newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+			"No stream or subject provided").Err()
 	}
 	stream := a.metadata.GetStream(req.Stream)
 	if stream == nil {
-		return "", status.Error(codes.NotFound, fmt.Sprintf("No such stream: %s", req.Stream))
+		return "", newStatus(codes.NotFound, ErrorCodeStreamNotFound,
+			fmt.Sprintf("No such stream: %s", req.Stream)).Err()
 	}
 	subject := stream.GetSubject()
 	if req.Partition > 0 {
@@ -298,19 +1095,23 @@ func (a *apiServer) getPublishSubject(req *client.PublishRequest) (string, error
 }
 
 func (a *apiServer) publishSync(ctx context.Context, subject,
-	ackInbox string, msg []byte) (*client.Ack, error) {
+	ackInbox string, msg []byte, published func()) (*client.Ack, error) {
 
 	sub, err := a.ncPublishes.SubscribeSync(ackInbox)
 	if err != nil {
+		published()
 		a.logger.Errorf("api: Failed to subscribe to ack inbox: %v", err)
 		return nil, err
 	}
 	if err := sub.AutoUnsubscribe(1); err != nil {
+		published()
 		a.logger.Errorf("api: Failed to auto unsubscribe from ack inbox: %v", err)
 		return nil, err
 	}
 
-	if err := a.ncPublishes.Publish(subject, msg); err != nil {
+	err = a.ncPublishes.Publish(subject, msg)
+	published()
+	if err != nil {
 		a.logger.Errorf("api: Failed to publish message: %v", err)
 		return nil, err
 	}
@@ -319,7 +1120,7 @@ func (a *apiServer) publishSync(ctx context.Context, subject,
 	if err != nil {
 		if err == nats.ErrTimeout {
 			a.logger.Errorf("api: Ack for publish timed out")
-			err = status.Error(codes.DeadlineExceeded, err.Error())
+			err = newStatus(codes.DeadlineExceeded, ErrorCodeTimeout, err.Error()).Err()
 		} else {
 			a.logger.Errorf("api: Failed to get ack for publish: %v", err)
 		}
@@ -328,71 +1129,352 @@ func (a *apiServer) publishSync(ctx context.Context, subject,
 
 	ack, err := proto.UnmarshalAck(ackMsg.Data)
 	if err != nil {
+		if rejection, e := proto.UnmarshalPublishRejection(ackMsg.Data); e == nil {
+			code := codes.Code(rejection.Code)
+			if code == codes.OK {
+				code = codes.ResourceExhausted
+			}
+			return nil, newStatus(code, statusErrorCodes[code],
+				fmt.Sprintf("Message rejected by partition %d of stream %s: %s",
+					rejection.Partition, rejection.Stream, rejection.Reason)).Err()
+		}
 		a.logger.Errorf("api: Invalid ack for publish: %v", err)
 		return nil, err
 	}
 	return ack, nil
 }
 
+// getSubscribePartitions returns the partitions followed by a Subscribe: the
+// requested stream partition followed by the same partition of any additional
+// streams set in the request metadata. An error is returned if any of them
+// does not exist or is not led by this server.
+func (a *apiServer) getSubscribePartitions(ctx context.Context, req *client.SubscribeRequest) (
+	[]*partition, error) {
+
+	var (
+		streams    = append([]string{req.Stream}, getMetadataValues(ctx, SubscribeStreamsMetadataKey)...)
+		partitions = make([]*partition, 0, len(streams))
+		seen       = make(map[string]struct{}, len(streams))
+	)
+	for _, stream := range streams {
+		if _, ok := seen[stream]; ok {
+			continue
+		}
+		seen[stream] = struct{}{}
+
+		partition := a.metadata.GetPartition(stream, req.Partition)
+		if partition == nil {
+			a.logger.Errorf("api: Failed to subscribe to partition "+
+				"[stream=%s, partition=%d]: no such partition",
+				stream, req.Partition)
+			return nil, newStatus(codes.NotFound, ErrorCodePartitionNotFound, "No such partition").Err()
+		}
+
+		leader, _ := partition.GetLeader()
+		if leader != a.config.Clustering.ServerID {
+			a.logger.Errorf("api: Failed to subscribe to partition %s: server not stream leader", partition)
+			return nil, newNotLeaderStatus("Server not partition leader", leader).Err()
+		}
+
+		if partition.IsPaused() {
+			if a.config.Streams.PausedRejectSubscriptions {
+				a.logger.Errorf("api: Failed to subscribe to partition %s: partition is paused", partition)
+				return nil, newStatus(codes.FailedPrecondition, ErrorCodePaused, "Partition is paused").Err()
+			}
+			if err := partition.OpenPaused(); err != nil {
+				a.logger.Errorf("api: Failed to open paused partition %s: %v", partition, err)
+				return nil, newStatus(codes.Internal, ErrorCodeInternal, err.Error()).Err()
+			}
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions, nil
+}
+
+// confirmSubscribeLeaders confirms with the metadata leader that this server
+// is still the leader of each of the given partitions for its current leader
+// epoch. A Status is returned if it isn't or if the confirmation failed.
+func (a *apiServer) confirmSubscribeLeaders(ctx context.Context, partitions []*partition) *status.Status {
+	if getMetadataFlag(ctx, ReadUncommittedMetadataKey) {
+		return newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+			"Consistent read can't be combined with read uncommitted")
+	}
+	for _, partition := range partitions {
+		leader, epoch := partition.GetLeader()
+		st := a.metadata.ConfirmLeader(ctx, &proto.ConfirmLeaderOp{
+			Stream:      partition.Stream,
+			Partition:   partition.Id,
+			Leader:      leader,
+			LeaderEpoch: epoch,
+		})
+		if st == nil {
+			continue
+		}
+		a.logger.Errorf("api: Failed to confirm leadership of partition %s: %v", partition, st.Err())
+		if st.Code() == codes.FailedPrecondition {
+			return withErrorCode(st, ErrorCodeNotLeader)
+		}
+		return withDefaultErrorCode(st)
+	}
+	return nil
+}
+
+// subscribeMultiplexed sets up a subscription on each of the given partitions
+// and merges their messages onto the returned channel, tagging each message
+// with its source stream. Ordering is only preserved within each partition.
+// The first asynchronous error from any of the subscriptions is sent on the
+// status channel. The subscriptions run until the cancel channel is closed.
+func (a *apiServer) subscribeMultiplexed(ctx context.Context, partitions []*partition,
+	req *client.SubscribeRequest, budget *inflightBudget, cancel chan struct{}) (
+	<-chan *client.Message, <-chan *status.Status, *status.Status) {
+
+	var (
+		merged = make(chan *client.Message)
+		errCh  = make(chan *status.Status)
+	)
+	for _, partition := range partitions {
+		ch, partitionErrCh, st := a.subscribe(ctx, partition, req, budget, cancel)
+		if st != nil {
+			return nil, nil, st
+		}
+		a.startGoroutine(func() {
+			for {
+				select {
+				case m := <-ch:
+					if m.Headers == nil {
+						m.Headers = make(map[string][]byte)
+					}
+					m.Headers[StreamHeader] = []byte(m.Stream)
+					select {
+					case merged <- m:
+					case <-cancel:
+						return
+					}
+				case st := <-partitionErrCh:
+					select {
+					case errCh <- st:
+					case <-cancel:
+					}
+					return
+				case <-cancel:
+					return
+				}
+			}
+		})
+	}
+	return merged, errCh, nil
+}
+
 // subscribe sets up a subscription on the given partition and begins sending
 // messages on the returned channel. The subscription will run until the cancel
 // channel is closed, the context is canceled, or an error is returned
-// asynchronously on the status channel.
+// asynchronously on the status channel. Messages sent count against the given
+// budget until the receiver releases them.
 func (a *apiServer) subscribe(ctx context.Context, partition *partition,
-	req *client.SubscribeRequest, cancel chan struct{}) (
+	req *client.SubscribeRequest, budget *inflightBudget, cancel chan struct{}) (
 	<-chan *client.Message, <-chan *status.Status, *status.Status) {
 
-	startOffset, st := getStartOffset(req, partition.log)
+	startOffset, st := getStartOffset(ctx, req, partition.log)
 	if st != nil {
 		return nil, nil, st
 	}
+	if newest := partition.log.NewestOffset(); startOffset > newest+1 && isOffsetOutOfRangeError(ctx) {
+		return nil, nil, newStatus(codes.OutOfRange, ErrorCodeOffsetOutOfRange,
+			fmt.Sprintf("Start offset %d is beyond the end of the log, newest offset %d", startOffset, newest))
+	}
+	resetPolicy, resetErr := getOffsetResetPolicy(ctx)
+	if resetErr != nil {
+		return nil, nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, resetErr.Error())
+	}
+	var offsetReset *client.Message
+	if oldest := partition.log.OldestOffset(); resetPolicy != "" && startOffset < oldest {
+		newest := partition.log.NewestOffset()
+		offsetReset = partition.controlMessage(ControlOffsetReset, map[string][]byte{
+			RequestedOffsetHeader: []byte(strconv.FormatInt(startOffset, 10)),
+			OldestOffsetHeader:    []byte(strconv.FormatInt(oldest, 10)),
+			NewestOffsetHeader:    []byte(strconv.FormatInt(newest, 10)),
+		})
+		if resetPolicy == "latest" {
+			startOffset = newest + 1
+		} else {
+			startOffset = oldest
+		}
+	}
+	maxRate, rateErr := getMetadataInt(ctx, MaxRateMetadataKey, 64)
+	if rateErr != nil {
+		return nil, nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, rateErr.Error())
+	}
+	limiter := newDeliveryLimiter(maxRate)
+	keyFilter, filterKeys := getKeyFilter(ctx)
+	includePosition := getMetadataFlag(ctx, PositionMetadataKey)
+	// Capture the HW before creating the reader so the subscription catches
+	// up to the history that existed when it was created. A snapshot covers
+	// the same history.
+	catchUp, caughtUpOffset := getMetadataFlag(ctx, CatchUpMetadataKey), partition.log.HighWatermark()
+	snapshot := getMetadataFlag(ctx, SnapshotMetadataKey)
+	readUncommitted := getMetadataFlag(ctx, ReadUncommittedMetadataKey)
+	passThrough := getMetadataFlag(ctx, PassThroughMetadataKey)
 
 	var (
 		ch          = make(chan *client.Message)
 		errCh       = make(chan *status.Status)
-		reader, err = partition.log.NewReader(startOffset, false)
+		reader, err = partition.log.NewReader(startOffset, readUncommitted)
 	)
 	if err != nil {
-		return nil, nil, status.New(
-			codes.Internal, fmt.Sprintf("Failed to create stream reader: %v", err))
+		return nil, nil, newStatus(codes.Internal, ErrorCodeInternal,
+			fmt.Sprintf("Failed to create stream reader: %v", err))
+	}
+
+	// send delivers the message and returns false if the subscription was
+	// canceled. Sends are serialized so control messages can carry the offset
+	// of the last message delivered, which clients resume from.
+	var (
+		sendMu     sync.Mutex
+		lastOffset = startOffset - 1
+	)
+	send := func(msg *client.Message) bool {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		if _, ok := msg.Headers[ControlHeader]; ok {
+			msg.Offset = lastOffset
+		}
+		if !budget.acquire(inflightSize(msg), cancel) {
+			return false
+		}
+		select {
+		case ch <- msg:
+			lastOffset = msg.Offset
+			return true
+		case <-cancel:
+			return false
+		}
+	}
+
+	if getMetadataFlag(ctx, ControlMetadataKey) {
+		a.startGoroutine(func() { partition.watchChanges(cancel, send) })
 	}
 
 	a.startGoroutine(func() {
+		defer reader.Close()
+		atomic.AddInt32(&partition.subscribers, 1)
+		defer atomic.AddInt32(&partition.subscribers, -1)
+
+		if offsetReset != nil && !send(offsetReset) {
+			return
+		}
+
+		// sendCaughtUp delivers the marker signaling the subscription has
+		// caught up and returns false if the subscription was canceled.
+		sendCaughtUp := func() bool {
+			catchUp = false
+			return send(&client.Message{
+				Stream:    partition.Stream,
+				Partition: partition.Id,
+				Offset:    caughtUpOffset,
+				Headers:   map[string][]byte{CaughtUpHeader: []byte("true")},
+			})
+		}
+		// There is no history to replay if the subscription starts after the
+		// HW.
+		if catchUp && startOffset > caughtUpOffset && !sendCaughtUp() {
+			return
+		}
+
+		// Find the latest message for each key in the snapshot so the older
+		// ones can be skipped.
+		var latestKeyOffsets map[string]int64
+		if snapshot {
+			var err error
+			latestKeyOffsets, err = scanLatestKeyOffsets(ctx, partition.log, startOffset, caughtUpOffset)
+			if err != nil {
+				select {
+				case errCh <- readerErrorStatus(err):
+				case <-cancel:
+				}
+				return
+			}
+		}
+
 		headersBuf := make([]byte, 28)
 		for {
 			// TODO: this could be more efficient.
 			m, offset, timestamp, _, err := reader.ReadMessage(ctx, headersBuf)
 			if err != nil {
-				var s *status.Status
-				if err == commitlog.ErrCommitLogDeleted {
-					s = status.New(codes.NotFound, err.Error())
-				} else {
-					s = status.Convert(err)
-				}
-
 				select {
-				case errCh <- s:
+				case errCh <- readerErrorStatus(err):
 				case <-cancel:
 				}
 				return
 			}
+			// The message at the HW may have been removed, e.g. by
+			// compaction, in which case this is the first live message.
+			if catchUp && offset > caughtUpOffset && !sendCaughtUp() {
+				return
+			}
+			superseded := false
+			if snapshot && offset <= caughtUpOffset && m.Key() != nil {
+				superseded = latestKeyOffsets[string(m.Key())] != offset
+			}
+			if superseded || (filterKeys && !bytes.Equal(m.Key(), keyFilter)) {
+				if catchUp && offset == caughtUpOffset && !sendCaughtUp() {
+					return
+				}
+				continue
+			}
 			headers := m.Headers()
+			// Hold scheduled messages, and the messages after them, until
+			// their delivery time.
+			if delay := deliveryDelay(headers, a.config.Streams.MaxDeliveryDelay); delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					select {
+					case errCh <- status.FromContextError(ctx.Err()):
+					case <-cancel:
+					}
+					return
+				case <-cancel:
+					timer.Stop()
+					return
+				}
+			}
+			if includePosition {
+				headers[DeliveredOffsetHeader] = []byte(strconv.FormatInt(offset, 10))
+				headers[HighWatermarkHeader] = []byte(strconv.FormatInt(partition.log.HighWatermark(), 10))
+			}
+			if readUncommitted && offset > partition.log.HighWatermark() {
+				headers[UncommittedHeader] = []byte("true")
+			}
+			value := m.Value()
+			if !passThrough {
+				if value, err = decompressValue(headers, value); err != nil {
+					select {
+					case errCh <- newStatus(codes.Internal, ErrorCodeInternal,
+						fmt.Sprintf("Failed to decompress message at offset %d: %v", offset, err)):
+					case <-cancel:
+					}
+					return
+				}
+			}
 			var (
 				msg = &client.Message{
 					Stream:       partition.Stream,
 					Partition:    partition.Id,
 					Offset:       offset,
 					Key:          m.Key(),
-					Value:        m.Value(),
+					Value:        value,
 					Timestamp:    timestamp,
 					Headers:      headers,
 					Subject:      string(headers["subject"]),
 					ReplySubject: string(headers["reply"]),
 				}
 			)
-			select {
-			case ch <- msg:
-			case <-cancel:
+			if !limiter.wait(cancel) || !send(msg) {
+				return
+			}
+			if catchUp && offset == caughtUpOffset && !sendCaughtUp() {
 				return
 			}
 		}
@@ -401,27 +1483,336 @@ func (a *apiServer) subscribe(ctx context.Context, partition *partition,
 	return ch, errCh, nil
 }
 
-func getStartOffset(req *client.SubscribeRequest, log commitlog.CommitLog) (int64, *status.Status) {
+// scanLatestKeyOffsets returns the offset of the latest message for each key
+// in the log from the start offset through the end offset. Messages without a
+// key are not included.
+func scanLatestKeyOffsets(ctx context.Context, log commitlog.CommitLog, start, end int64) (
+	map[string]int64, error) {
+
+	offsets := make(map[string]int64)
+	if start > end {
+		return offsets, nil
+	}
+	// Read uncommitted messages so the scan doesn't block if the message at
+	// the end offset was removed, e.g. by compaction.
+	reader, err := log.NewReader(start, true)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	headersBuf := make([]byte, 28)
+	for {
+		m, offset, _, _, err := reader.ReadMessage(ctx, headersBuf)
+		if err != nil {
+			return nil, err
+		}
+		if offset > end {
+			return offsets, nil
+		}
+		if key := m.Key(); key != nil {
+			offsets[string(key)] = offset
+		}
+		if offset == end {
+			return offsets, nil
+		}
+	}
+}
+
+// readerErrorStatus returns the status for an error reading a partition's log
+// on a subscription.
+func readerErrorStatus(err error) *status.Status {
+	switch err {
+	case commitlog.ErrCommitLogDeleted:
+		return newStatus(codes.NotFound, ErrorCodeStreamNotFound, err.Error())
+	case commitlog.ErrOffsetTruncated:
+		return newStatus(codes.OutOfRange, ErrorCodeOffsetOutOfRange, err.Error())
+	default:
+		return status.Convert(err)
+	}
+}
+
+// retryMetadataOp invokes the given metadata operation, retrying with
+// exponential backoff while it fails with an Unavailable status, which
+// indicates there was no metadata leader to apply it, e.g. due to a leader
+// election. Retries stop once the Context is done or, if it has no deadline,
+// after defaultMetadataRetryTimeout.
+//
+// An operation which was forwarded to a metadata leader which was replaced
+// before responding may have been applied anyway. If it's then retried and
+// fails with a status the given applied func accepts, e.g. AlreadyExists when
+// creating a stream which matches the request, it's taken to have been
+// applied by the earlier attempt and nil is returned. Idempotent operations
+// pass a nil applied func.
+func retryMetadataOp(ctx context.Context, applied func(*status.Status) bool,
+	op func() *status.Status) *status.Status {
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultMetadataRetryTimeout)
+	}
+	var (
+		backoff = minMetadataRetryBackoff
+		unknown = false
+	)
+	for {
+		st := op()
+		if unknown && applied != nil && st != nil && applied(st) {
+			return nil
+		}
+		if st == nil || st.Code() != codes.Unavailable {
+			return st
+		}
+		if isOutcomeUnknown(st) {
+			unknown = true
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return st
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return st
+		}
+		backoff *= 2
+		if backoff > maxMetadataRetryBackoff {
+			backoff = maxMetadataRetryBackoff
+		}
+	}
+}
+
+// isOutcomeUnknown indicates if a metadata operation which failed with the
+// given Status may have been applied, i.e. it was forwarded to a metadata
+// leader which was replaced before responding.
+func isOutcomeUnknown(st *status.Status) bool {
+	return st != nil && st.Code() == codes.Unavailable && st.Message() == errMetadataLeaderChanged
+}
+
+// getMetadataValue returns the first value set for the given key in the
+// request metadata and whether the key is set.
+func getMetadataValue(ctx context.Context, key string) (string, bool) {
+	vals := getMetadataValues(ctx, key)
+	if len(vals) == 0 {
+		return "", false
+	}
+	return vals[0], true
+}
+
+// getMetadataValues returns all of the values set for the given key in the
+// request metadata.
+func getMetadataValues(ctx context.Context, key string) []string {
+	md, ok := grpcMetadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	return md.Get(key)
+}
+
+// getMetadataFlag indicates if the given key is set to "true" in the request
+// metadata.
+func getMetadataFlag(ctx context.Context, key string) bool {
+	val, _ := getMetadataValue(ctx, key)
+	return val == "true"
+}
+
+// getMetadataInt returns the positive integer set for the given key in the
+// request metadata, or zero if it's not set. An error is returned if the value
+// is not a positive integer which fits in bitSize bits.
+func getMetadataInt(ctx context.Context, key string, bitSize int) (int64, error) {
+	val, ok := getMetadataValue(ctx, key)
+	if !ok {
+		return 0, nil
+	}
+	i, err := strconv.ParseInt(val, 10, bitSize)
+	if err != nil || i <= 0 {
+		return 0, fmt.Errorf("Invalid %s %q: must be a positive integer", key, val)
+	}
+	return i, nil
+}
+
+// getMetadataDuration returns the positive duration set for the given key in
+// the request metadata, or zero if it's not set. An error is returned if the
+// value is not a positive duration.
+func getMetadataDuration(ctx context.Context, key string) (time.Duration, error) {
+	val, ok := getMetadataValue(ctx, key)
+	if !ok {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("Invalid %s %q: must be a positive duration", key, val)
+	}
+	return d, nil
+}
+
+// getKeyFilter returns the message key the subscription is filtered on, if
+// any, from the request metadata.
+func getKeyFilter(ctx context.Context) ([]byte, bool) {
+	key, ok := getMetadataValue(ctx, KeyFilterMetadataKey)
+	if !ok {
+		return nil, false
+	}
+	return []byte(key), true
+}
+
+// getFencingToken returns the fencing token of an admin operation from the
+// request metadata, or zero if it's not set. An error is returned if the value
+// is invalid.
+func getFencingToken(ctx context.Context) (uint64, error) {
+	val, ok := getMetadataValue(ctx, FencingTokenMetadataKey)
+	if !ok {
+		return 0, nil
+	}
+	token, err := strconv.ParseUint(val, 10, 64)
+	if err != nil || token == 0 {
+		return 0, fmt.Errorf("Invalid %s %q: must be a positive integer",
+			FencingTokenMetadataKey, val)
+	}
+	return token, nil
+}
+
+// getEmptyValuePolicy returns how the stream being created should handle
+// messages with an empty value based on the request metadata. It returns an
+// error if the policy is invalid.
+func getEmptyValuePolicy(ctx context.Context) (proto.EmptyValue, error) {
+	val, ok := getMetadataValue(ctx, EmptyValueMetadataKey)
+	if !ok {
+		return proto.EmptyValue_STORE, nil
+	}
+	switch val {
+	case "store":
+		return proto.EmptyValue_STORE, nil
+	case "reject":
+		return proto.EmptyValue_REJECT, nil
+	case "tombstone":
+		return proto.EmptyValue_TOMBSTONE, nil
+	default:
+		return proto.EmptyValue_STORE, fmt.Errorf("Invalid %s %q", EmptyValueMetadataKey, val)
+	}
+}
+
+// checkSchema returns an InvalidArgument status if the given schema is set
+// but no validator is registered for it.
+func (a *apiServer) checkSchema(schema string) *status.Status {
+	if schema == "" {
+		return nil
+	}
+	if _, ok := a.config.Streams.SchemaValidators[schema]; !ok {
+		return newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, fmt.Sprintf("Unknown schema %q", schema))
+	}
+	return nil
+}
+
+// getProducerSequence returns the producer session ID and sequence number of a
+// publish from the request metadata. The session ID is empty if the publish is
+// not part of a producer session. An error is returned if the sequence number
+// is missing or invalid.
+func getProducerSequence(ctx context.Context) (string, uint64, error) {
+	session, _ := getMetadataValue(ctx, ProducerSessionMetadataKey)
+	if session == "" {
+		return "", 0, nil
+	}
+	val, ok := getMetadataValue(ctx, ProducerSequenceMetadataKey)
+	if !ok {
+		return "", 0, fmt.Errorf("Missing %s for producer session", ProducerSequenceMetadataKey)
+	}
+	seq, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid %s %q", ProducerSequenceMetadataKey, val)
+	}
+	return session, seq, nil
+}
+
+// minFollowers returns the number of followers required to have a message
+// with the given headers before it's committed based on its
+// MinFollowersHeader. It returns zero if there is no valid requirement.
+func minFollowers(headers map[string][]byte) int {
+	followers, err := strconv.Atoi(string(headers[MinFollowersHeader]))
+	if err != nil || followers < 0 {
+		return 0
+	}
+	return followers
+}
+
+// deliveryDelay returns how long to wait before delivering a message with the
+// given headers based on its DeliverAtHeader, capped at maxDelay. It returns
+// zero if the message is not scheduled, the header is invalid, or the delivery
+// time has passed.
+func deliveryDelay(headers map[string][]byte, maxDelay time.Duration) time.Duration {
+	deliverAt, ok := headers[DeliverAtHeader]
+	if !ok {
+		return 0
+	}
+	nanos, err := strconv.ParseInt(string(deliverAt), 10, 64)
+	if err != nil {
+		return 0
+	}
+	if delay := time.Until(time.Unix(0, nanos)); delay < maxDelay {
+		return delay
+	}
+	return maxDelay
+}
+
+// isOffsetOutOfRangeError indicates if a Subscribe starting beyond the end of
+// the log should fail rather than wait based on the request metadata.
+func isOffsetOutOfRangeError(ctx context.Context) bool {
+	val, _ := getMetadataValue(ctx, OffsetOutOfRangeMetadataKey)
+	return val == "error"
+}
+
+// getOffsetResetPolicy returns the policy applied when the subscription's
+// start offset is before the oldest offset of the log from the request
+// metadata, or an empty string if there is none. An error is returned if the
+// policy is invalid.
+func getOffsetResetPolicy(ctx context.Context) (string, error) {
+	val, ok := getMetadataValue(ctx, OffsetResetMetadataKey)
+	if !ok {
+		return "", nil
+	}
+	switch val {
+	case "earliest", "latest":
+		return val, nil
+	default:
+		return "", fmt.Errorf("Invalid %s %q: must be earliest or latest", OffsetResetMetadataKey, val)
+	}
+}
+
+// getStartOffset returns the offset a Subscribe starts at based on its start
+// position, or on the StartTimeDeltaMetadataKey in the request metadata if
+// it's set.
+func getStartOffset(ctx context.Context, req *client.SubscribeRequest, log commitlog.CommitLog) (
+	int64, *status.Status) {
+
+	delta, err := getMetadataDuration(ctx, StartTimeDeltaMetadataKey)
+	if err != nil {
+		return 0, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+	}
+
 	var startOffset int64
-	switch req.StartPosition {
-	case client.StartPosition_OFFSET:
+	switch {
+	case delta > 0:
+		offset, err := log.OffsetForTimestamp(timestamp() - int64(delta))
+		if err != nil {
+			return startOffset, newStatus(codes.Internal, ErrorCodeInternal,
+				fmt.Sprintf("Failed to lookup offset for timestamp: %v", err))
+		}
+		startOffset = offset
+	case req.StartPosition == client.StartPosition_OFFSET:
 		startOffset = req.StartOffset
-	case client.StartPosition_TIMESTAMP:
+	case req.StartPosition == client.StartPosition_TIMESTAMP:
 		offset, err := log.OffsetForTimestamp(req.StartTimestamp)
 		if err != nil {
-			return startOffset, status.New(
-				codes.Internal, fmt.Sprintf("Failed to lookup offset for timestamp: %v", err))
+			return startOffset, newStatus(codes.Internal, ErrorCodeInternal,
+				fmt.Sprintf("Failed to lookup offset for timestamp: %v", err))
 		}
 		startOffset = offset
-	case client.StartPosition_EARLIEST:
+	case req.StartPosition == client.StartPosition_EARLIEST:
 		startOffset = log.OldestOffset()
-	case client.StartPosition_LATEST:
+	case req.StartPosition == client.StartPosition_LATEST:
 		startOffset = log.NewestOffset()
-	case client.StartPosition_NEW_ONLY:
+	case req.StartPosition == client.StartPosition_NEW_ONLY:
 		startOffset = log.NewestOffset() + 1
 	default:
-		return startOffset, status.New(
-			codes.InvalidArgument,
+		return startOffset, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
 			fmt.Sprintf("Unknown StartPosition %s", req.StartPosition))
 	}
 
@@ -1,20 +1,30 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strconv"
 	"testing"
 	"time"
 
 	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	grpcMetadata "google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	lift "github.com/liftbridge-io/go-liftbridge"
 	proto "github.com/liftbridge-io/liftbridge-api/go"
+
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
+	internal "github.com/liftbridge-io/liftbridge/server/protocol"
 )
 
 type message struct {
@@ -55,6 +65,19 @@ func TestCreateStream(t *testing.T) {
 	// Creating the same stream returns ErrStreamExists.
 	err = client.CreateStream(context.Background(), "foo", "bar")
 	require.Equal(t, lift.ErrStreamExists, err)
+
+	// Only a retry of the same request is taken to have created the stream.
+	api := &apiServer{s1}
+	req := &proto.CreateStreamRequest{Subject: "foo", Name: "bar"}
+	setCreateStreamDefaults(req)
+	op, st := newCreateStreamOp(context.Background(), req)
+	require.Nil(t, st)
+	require.True(t, api.streamCreatedBy(op))
+	req = &proto.CreateStreamRequest{Subject: "baz", Name: "bar"}
+	setCreateStreamDefaults(req)
+	op, st = newCreateStreamOp(context.Background(), req)
+	require.Nil(t, st)
+	require.False(t, api.streamCreatedBy(op))
 }
 
 // Ensure creating a stream works when we send the request to the metadata
@@ -127,6 +150,132 @@ func TestCreateStreamNoMetadataLeader(t *testing.T) {
 	require.Equal(t, codes.Internal, st.Code())
 }
 
+// Ensure creating a stream is retried when the metadata leader fails while
+// the request is in flight.
+func TestCreateStreamMetadataLeaderFailover(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Use a dedicated namespace so that no servers other than this test's
+	// can handle the forwarded request.
+	namespace := "failover"
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Clustering.Namespace = namespace
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Clustering.Namespace = namespace
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Configure third server.
+	s3Config := getTestConfig("c", false, 5052)
+	s3Config.Clustering.Namespace = namespace
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := map[*Server]*Config{s1: s1Config, s2: s2Config, s3: s3Config}
+	leader := getMetadataLeader(t, 10*time.Second, s1, s2, s3)
+	followers := []*Server{}
+	for s := range servers {
+		if s != leader {
+			followers = append(followers, s)
+		}
+	}
+
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", servers[followers[0]].Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// Kill the leader and immediately send the request to a follower.
+	// Depending on whether the follower has detected the failure yet, the
+	// request is either forwarded to the failed leader, abandoned once a new
+	// leader is elected, and retried, or held until a new leader is elected.
+	// Either way it succeeds. The retry itself is covered by
+	// TestRetryMetadataOp.
+	leader.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err = apiClient.CreateStream(ctx, &proto.CreateStreamRequest{
+		Subject: "foo",
+		Name:    "foo",
+	})
+	require.NoError(t, err)
+
+	waitForPartition(t, 5*time.Second, "foo", 0, followers...)
+}
+
+// Ensure retryMetadataOp retries Unavailable statuses and treats statuses the
+// applied func accepts as success only after an attempt which may have been
+// applied.
+func TestRetryMetadataOp(t *testing.T) {
+	var (
+		unavailable   = status.New(codes.Unavailable, "no leader")
+		leaderChanged = status.New(codes.Unavailable, errMetadataLeaderChanged)
+		exists        = status.New(codes.AlreadyExists, "exists")
+		matches       = true
+		applied       = func(st *status.Status) bool {
+			return st.Code() == codes.AlreadyExists && matches
+		}
+	)
+	run := func(applied func(*status.Status) bool, results ...*status.Status) (*status.Status, int) {
+		attempts := 0
+		st := retryMetadataOp(context.Background(), applied, func() *status.Status {
+			st := results[attempts]
+			attempts++
+			return st
+		})
+		return st, attempts
+	}
+
+	// Unavailable statuses are retried until the operation succeeds.
+	st, attempts := run(applied, unavailable, leaderChanged, nil)
+	require.Nil(t, st)
+	require.Equal(t, 3, attempts)
+
+	// Other statuses are returned without retrying.
+	st, attempts = run(applied, exists)
+	require.Equal(t, exists, st)
+	require.Equal(t, 1, attempts)
+
+	// Applied statuses are only success after an attempt which may have been
+	// applied.
+	st, attempts = run(applied, unavailable, exists)
+	require.Equal(t, exists, st)
+	require.Equal(t, 2, attempts)
+	st, attempts = run(applied, leaderChanged, exists)
+	require.Nil(t, st)
+	require.Equal(t, 2, attempts)
+
+	// A status the applied func rejects, e.g. a different stream with the
+	// same name, is returned even after such an attempt.
+	matches = false
+	st, attempts = run(applied, leaderChanged, exists)
+	require.Equal(t, exists, st)
+	require.Equal(t, 2, attempts)
+
+	// Idempotent operations have no applied func.
+	st, attempts = run(nil, leaderChanged, exists)
+	require.Equal(t, exists, st)
+	require.Equal(t, 2, attempts)
+
+	// Retries stop at the Context deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	st = retryMetadataOp(ctx, applied, func() *status.Status {
+		return unavailable
+	})
+	require.Equal(t, unavailable, st)
+}
+
 // Ensure creating a stream fails when the replication factor is greater than
 // the number of servers in the cluster.
 func TestCreateStreamInsufficientReplicas(t *testing.T) {
@@ -152,64 +301,121 @@ func TestCreateStreamInsufficientReplicas(t *testing.T) {
 	require.Error(t, err)
 }
 
-// Ensure when a partitioned stream is created the correct number of partitions
-// are made.
-func TestCreateStreamPartitioned(t *testing.T) {
+// Ensure a stream's replicas are only placed on the servers it is pinned to.
+func TestCreateStreamPinnedServers(t *testing.T) {
 	defer cleanupStorage(t)
 
 	// Use a central NATS server.
 	ns := natsdTest.RunDefaultServer()
 	defer ns.Shutdown()
 
-	// Configure server.
+	// Configure first server.
 	s1Config := getTestConfig("a", true, 5050)
 	s1 := runServerWithConfig(t, s1Config)
 	defer s1.Stop()
 
-	getMetadataLeader(t, 10*time.Second, s1)
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Configure third server.
+	s3Config := getTestConfig("c", false, 5052)
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := []*Server{s1, s2, s3}
+	getMetadataLeader(t, 10*time.Second, servers...)
 
 	client, err := lift.Connect([]string{"localhost:5050"})
 	require.NoError(t, err)
 	defer client.Close()
 
-	err = client.CreateStream(context.Background(), "foo", "foo", lift.Partitions(3))
+	// Pinning to an unknown server fails.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		ReplicaServersMetadataKey, "a", ReplicaServersMetadataKey, "d")
+	err = client.CreateStream(ctx, "foo", "foo")
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Pinning to fewer servers than the replication factor fails.
+	ctx = grpcMetadata.AppendToOutgoingContext(context.Background(),
+		ReplicaServersMetadataKey, "a", ReplicaServersMetadataKey, "c")
+	err = client.CreateStream(ctx, "foo", "foo", lift.ReplicationFactor(3))
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Replicas of every partition are placed on the pinned servers.
+	err = client.CreateStream(ctx, "foo", "foo", lift.ReplicationFactor(2),
+		lift.Partitions(3))
 	require.NoError(t, err)
 
-	stream := s1.metadata.GetStream("foo")
-	require.NotNil(t, stream)
-	require.Len(t, stream.partitions, 3)
+	for i := int32(0); i < 3; i++ {
+		waitForPartition(t, 5*time.Second, "foo", i, servers...)
+		for _, s := range servers {
+			partition := s.metadata.GetPartition("foo", i)
+			require.ElementsMatch(t, []string{"a", "c"}, partition.GetReplicas())
+		}
+	}
 }
 
-// Ensure subscribing to a non-existent stream returns an error.
-func TestSubscribeStreamNoSuchStream(t *testing.T) {
+// Ensure replicas are not placed on servers which have reached the maximum
+// number of replicas and stream creation fails once no server has capacity.
+func TestCreateStreamServerMaxReplicas(t *testing.T) {
 	defer cleanupStorage(t)
 
 	// Use a central NATS server.
 	ns := natsdTest.RunDefaultServer()
 	defer ns.Shutdown()
 
-	// Configure server.
+	// Configure first server.
 	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Clustering.ServerMaxReplicas = 1
 	s1 := runServerWithConfig(t, s1Config)
 	defer s1.Stop()
 
-	getMetadataLeader(t, 10*time.Second, s1)
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Clustering.ServerMaxReplicas = 1
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
 
-	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	servers := []*Server{s1, s2}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
 	require.NoError(t, err)
-	defer conn.Close()
-	apiClient := proto.NewAPIClient(conn)
+	defer client.Close()
 
-	stream, err := apiClient.Subscribe(context.Background(),
-		&proto.SubscribeRequest{Stream: "foo"})
+	// Fill the first server to capacity.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		ReplicaServersMetadataKey, "a")
+	err = client.CreateStream(ctx, "foo", "foo")
 	require.NoError(t, err)
-	_, err = stream.Recv()
+
+	// A stream which could be placed on either server avoids the full one.
+	err = client.CreateStream(context.Background(), "bar", "bar")
+	require.NoError(t, err)
+	waitForPartition(t, 5*time.Second, "bar", 0, servers...)
+	for _, s := range servers {
+		require.Equal(t, []string{"b"}, s.metadata.GetPartition("bar", 0).GetReplicas())
+	}
+
+	// No server has capacity left.
+	err = client.CreateStream(context.Background(), "baz", "baz")
 	require.Error(t, err)
-	require.Contains(t, err.Error(), "No such partition")
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Deleting a stream frees capacity.
+	err = client.DeleteStream(context.Background(), "foo")
+	require.NoError(t, err)
+	err = client.CreateStream(context.Background(), "baz", "baz")
+	require.NoError(t, err)
 }
 
-// Ensure getting a deleted stream returns nil.
-func TestDeleteStream(t *testing.T) {
+// Ensure when a partitioned stream is created the correct number of partitions
+// are made.
+func TestCreateStreamPartitioned(t *testing.T) {
 	defer cleanupStorage(t)
 
 	// Use a central NATS server.
@@ -227,28 +433,17 @@ func TestDeleteStream(t *testing.T) {
 	require.NoError(t, err)
 	defer client.Close()
 
-	err = client.DeleteStream(context.Background(), "foo")
-	require.Error(t, err)
-
 	err = client.CreateStream(context.Background(), "foo", "foo", lift.Partitions(3))
 	require.NoError(t, err)
 
 	stream := s1.metadata.GetStream("foo")
 	require.NotNil(t, stream)
-
-	err = client.DeleteStream(context.Background(), "foo")
-	require.NoError(t, err)
-
-	stream = s1.metadata.GetStream("foo")
-	require.Nil(t, stream)
-
-	err = client.CreateStream(context.Background(), "foo", "foo", lift.Partitions(3))
-	require.NoError(t, err)
+	require.Len(t, stream.partitions, 3)
 }
 
-// Ensure deleting a stream works when we send the request to the metadata
-// follower.
-func TestDeleteStreamPropagate(t *testing.T) {
+// Ensure CreateStreams creates many streams in one call when sent to the
+// metadata follower and reports the result of each stream.
+func TestCreateStreams(t *testing.T) {
 	defer cleanupStorage(t)
 
 	// Use a central NATS server.
@@ -256,32 +451,67 @@ func TestDeleteStreamPropagate(t *testing.T) {
 	defer ns.Shutdown()
 
 	// Configure first server.
-	s1Config := getTestConfig("a", true, 0)
+	s1Config := getTestConfig("a", true, 5050)
 	s1 := runServerWithConfig(t, s1Config)
 	defer s1.Stop()
 
 	// Configure second server.
-	s2Config := getTestConfig("b", false, 5050)
+	s2Config := getTestConfig("b", false, 5051)
 	s2 := runServerWithConfig(t, s2Config)
 	defer s2.Stop()
 
-	// Connect and send the request to the follower.
+	getMetadataLeader(t, 10*time.Second, s1, s2)
+
 	client, err := lift.Connect([]string{"localhost:5050"})
 	require.NoError(t, err)
 	defer client.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	err = client.CreateStream(ctx, "foo", "foo")
-	require.NoError(t, err)
+	require.NoError(t, client.CreateStream(ctx, "existing", "existing"))
+
+	var reqs []*proto.CreateStreamRequest
+	for i := 0; i < 100; i++ {
+		reqs = append(reqs, &proto.CreateStreamRequest{
+			Subject:           fmt.Sprintf("subject-%d", i),
+			Name:              fmt.Sprintf("stream-%d", i),
+			Group:             "group",
+			ReplicationFactor: int32(i%2 + 1),
+			Partitions:        int32(i%3 + 1),
+		})
+	}
+	reqs = append(reqs,
+		&proto.CreateStreamRequest{Subject: "existing", Name: "existing"},
+		&proto.CreateStreamRequest{Subject: "foo"},
+	)
 
-	err = client.DeleteStream(context.Background(), "foo")
+	errs, err := s2.CreateStreams(ctx, reqs)
 	require.NoError(t, err)
+	require.Len(t, errs, 102)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, errs[i])
+	}
+	require.Equal(t, codes.AlreadyExists, status.Code(errs[100]))
+	require.Equal(t, codes.InvalidArgument, status.Code(errs[101]))
+
+	for _, s := range []*Server{s1, s2} {
+		for i := 0; i < 100; i++ {
+			stream := s.metadata.GetStream(fmt.Sprintf("stream-%d", i))
+			require.NotNil(t, stream)
+			require.Equal(t, fmt.Sprintf("subject-%d", i), stream.GetSubject())
+			partitions := stream.GetPartitions()
+			require.Len(t, partitions, i%3+1)
+			for _, partition := range partitions {
+				require.Equal(t, "group", partition.Group)
+				require.Len(t, partition.GetReplicas(), i%2+1)
+			}
+		}
+	}
 }
 
-// Ensure sending a subscribe request to a server that is not the stream leader
-// returns an error.
-func TestSubscribeStreamNotLeader(t *testing.T) {
+// Ensure new partitions are not led by read-only servers and that a server
+// can lead partitions again once it's writable.
+func TestSetServerReadOnly(t *testing.T) {
 	defer cleanupStorage(t)
 
 	// Use a central NATS server.
@@ -298,47 +528,170 @@ func TestSubscribeStreamNotLeader(t *testing.T) {
 	s2 := runServerWithConfig(t, s2Config)
 	defer s2.Stop()
 
-	getMetadataLeader(t, 10*time.Second, s1, s2)
+	leader := getMetadataLeader(t, 10*time.Second, s1, s2)
+	follower := s1
+	if leader == s1 {
+		follower = s2
+	}
+	readOnlyID := follower.config.Clustering.ServerID
 
-	// Create the stream.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Unknown servers cannot be set read-only.
+	err := follower.SetServerReadOnly(ctx, "unknown", true)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Set the server read-only through the follower so the request is
+	// forwarded to the metadata leader.
+	require.NoError(t, follower.SetServerReadOnly(ctx, readOnlyID, true))
+	require.Equal(t, []string{readOnlyID}, leader.metadata.GetReadOnlyServers())
+
+	var reqs []*proto.CreateStreamRequest
+	for i := 0; i < 20; i++ {
+		reqs = append(reqs, &proto.CreateStreamRequest{
+			Subject:           fmt.Sprintf("subject-%d", i),
+			Name:              fmt.Sprintf("stream-%d", i),
+			ReplicationFactor: int32(i%2 + 1),
+			Partitions:        2,
+		})
+	}
+	errs, err := follower.CreateStreams(ctx, reqs)
+	require.NoError(t, err)
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	// The read-only server still gets replicas but never leads.
+	for i := 0; i < 20; i++ {
+		stream := leader.metadata.GetStream(fmt.Sprintf("stream-%d", i))
+		require.NotNil(t, stream)
+		for _, partition := range stream.GetPartitions() {
+			require.Len(t, partition.GetReplicas(), i%2+1)
+			partitionLeader, _ := partition.GetLeader()
+			require.NotEqual(t, readOnlyID, partitionLeader)
+		}
+	}
+
+	// A stream which can only be placed on the read-only server fails.
 	client, err := lift.Connect([]string{"localhost:5050"})
 	require.NoError(t, err)
 	defer client.Close()
+	pinnedCtx := grpcMetadata.AppendToOutgoingContext(ctx, ReplicaServersMetadataKey, readOnlyID)
+	err = client.CreateStream(pinnedCtx, "pinned", "pinned")
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// Once writable, the server can lead new partitions again.
+	require.NoError(t, follower.SetServerReadOnly(ctx, readOnlyID, false))
+	require.Empty(t, leader.metadata.GetReadOnlyServers())
+	require.NoError(t, client.CreateStream(pinnedCtx, "pinned", "pinned"))
+	partitionLeader, _ := leader.metadata.GetPartition("pinned", 0).GetLeader()
+	require.Equal(t, readOnlyID, partitionLeader)
+}
 
-	name := "foo"
-	subject := "foo"
-	err = client.CreateStream(context.Background(), subject, name,
-		lift.ReplicationFactor(2))
-	require.NoError(t, err)
+// Ensure when concurrent requests to different servers race to create the
+// same stream, exactly one succeeds and the others receive ErrStreamExists.
+func TestCreateStreamConcurrent(t *testing.T) {
+	defer cleanupStorage(t)
 
-	require.NoError(t, client.Close())
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
 
-	// Wait for both nodes to create stream.
-	waitForPartition(t, 5*time.Second, name, 0, s1, s2)
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
 
-	// Connect to the server that is the stream follower.
-	leader := getPartitionLeader(t, 10*time.Second, name, 0, s1, s2)
-	var followerConfig *Config
-	if leader == s1 {
-		followerConfig = s2Config
-	} else {
-		followerConfig = s1Config
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Configure third server.
+	s3Config := getTestConfig("c", false, 5052)
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := []*Server{s1, s2, s3}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	var (
+		num     = 9
+		errs    = make(chan error, num)
+		clients = make([]lift.Client, len(servers))
+	)
+	for i, s := range servers {
+		client, err := lift.Connect([]string{fmt.Sprintf("localhost:%d", s.config.Port)})
+		require.NoError(t, err)
+		defer client.Close()
+		clients[i] = client
 	}
-	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", followerConfig.Port), grpc.WithInsecure())
+
+	for i := 0; i < num; i++ {
+		client := clients[i%len(clients)]
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			errs <- client.CreateStream(ctx, "foo", "foo", lift.Partitions(3))
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < num; i++ {
+		select {
+		case err := <-errs:
+			if err == nil {
+				succeeded++
+			} else {
+				require.Equal(t, lift.ErrStreamExists, err)
+			}
+		case <-time.After(15 * time.Second):
+			t.Fatal("Did not receive CreateStream response")
+		}
+	}
+	require.Equal(t, 1, succeeded)
+
+	// Each server should have exactly one copy of the stream's partitions.
+	for _, s := range servers {
+		waitForPartition(t, 10*time.Second, "foo", 2, s)
+		stream := s.metadata.GetStream("foo")
+		require.NotNil(t, stream)
+		require.Len(t, stream.GetPartitions(), 3)
+	}
+}
+
+// Ensure subscribing to a non-existent stream returns an error.
+func TestSubscribeStreamNoSuchStream(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
 	require.NoError(t, err)
 	defer conn.Close()
 	apiClient := proto.NewAPIClient(conn)
 
-	// Subscribe on the follower.
-	stream, err := apiClient.Subscribe(context.Background(), &proto.SubscribeRequest{Stream: name})
+	stream, err := apiClient.Subscribe(context.Background(),
+		&proto.SubscribeRequest{Stream: "foo"})
 	require.NoError(t, err)
 	_, err = stream.Recv()
 	require.Error(t, err)
-	require.Contains(t, err.Error(), "Server not partition leader")
+	require.Contains(t, err.Error(), "No such partition")
 }
 
-// Ensure publishing and receiving messages on a stream works.
-func TestStreamPublishSubscribe(t *testing.T) {
+// Ensure subscribing beyond the end of the log waits for new messages by
+// default and fails with OutOfRange when requested.
+func TestSubscribeStreamOffsetOutOfRange(t *testing.T) {
 	defer cleanupStorage(t)
 
 	// Use a central NATS server.
@@ -357,92 +710,2772 @@ func TestStreamPublishSubscribe(t *testing.T) {
 	defer client.Close()
 
 	name := "foo"
-	subject := "foo"
-	err = client.CreateStream(context.Background(), subject, name)
+	err = client.CreateStream(context.Background(), "foo", name)
 	require.NoError(t, err)
 
+	// Publish some messages.
 	num := 5
-	expected := make([]*message, num)
-	for i := 0; i < num; i++ {
-		expected[i] = &message{
-			Key:    []byte("bar"),
-			Value:  []byte(strconv.Itoa(i)),
-			Offset: int64(i),
-		}
-	}
-	i := 0
-	ch1 := make(chan struct{})
-	ch2 := make(chan struct{})
-	err = client.Subscribe(context.Background(), name, func(msg lift.Message, err error) {
-		require.NoError(t, err)
-		expect := expected[i]
-		assertMsg(t, expect, msg)
-		i++
-		if i == num {
-			close(ch1)
-		}
-		if i == num+5 {
-			close(ch2)
-		}
-	})
-	require.NoError(t, err)
-
-	// Publish messages.
 	for i := 0; i < num; i++ {
-		_, err = client.Publish(context.Background(), name, expected[i].Value,
-			lift.Key(expected[i].Key))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
 		require.NoError(t, err)
 	}
 
-	// Wait to receive initial messages.
-	select {
-	case <-ch1:
-	case <-time.After(10 * time.Second):
-		t.Fatal("Did not receive all expected messages")
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+	req := &proto.SubscribeRequest{
+		Stream:        name,
+		StartPosition: proto.StartPosition_OFFSET,
+		StartOffset:   int64(num - 1 + 100),
 	}
 
-	// Publish some more messages.
-	for i := 0; i < 5; i++ {
-		expected = append(expected, &message{
-			Key:    []byte("baz"),
-			Value:  []byte(strconv.Itoa(i + num)),
-			Offset: int64(i + num),
-		})
+	// Subscribing with the error behavior fails.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		OffsetOutOfRangeMetadataKey, "error")
+	stream, err := apiClient.Subscribe(ctx, req)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+
+	// Subscribing by default waits at the end of the log.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err = apiClient.Subscribe(ctx, req)
+	require.NoError(t, err)
+	// The first message signals the subscription was created.
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	_, err = client.Publish(context.Background(), name, []byte("new"))
+	require.NoError(t, err)
+
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, int64(num), msg.Offset)
+	require.Equal(t, []byte("new"), msg.Value)
+}
+
+// Ensure a subscription resuming from an offset removed by retention is reset
+// according to the offset reset policy with a control message signaling the
+// reset.
+func TestSubscribeOffsetReset(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.SegmentMaxBytes = 1
+	s1Config.Streams.RetentionMaxMessages = 5
+	s1Config.BatchMaxMessages = 1
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name)
+	require.NoError(t, err)
+
+	// Publish more messages than are retained so retention outpaces a
+	// consumer which committed offset 2.
+	num := 10
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
 	}
-	for i := 0; i < 5; i++ {
-		_, err = client.Publish(context.Background(), name, expected[i+num].Value,
-			lift.Key(expected[i+num].Key))
+	forceLogClean(t, "foo", name, s1)
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+	req := &proto.SubscribeRequest{
+		Stream:        name,
+		StartPosition: proto.StartPosition_OFFSET,
+		StartOffset:   2,
+	}
+
+	// An invalid policy fails.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		OffsetResetMetadataKey, "bogus")
+	stream, err := apiClient.Subscribe(ctx, req)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	subscribe := func(policy string) proto.API_SubscribeClient {
+		ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+			OffsetResetMetadataKey, policy)
+		stream, err := apiClient.Subscribe(ctx, req)
+		require.NoError(t, err)
+		// The first message signals the subscription was created.
+		_, err = stream.Recv()
 		require.NoError(t, err)
+
+		// The reset is signaled before any messages.
+		msg, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, []byte(ControlOffsetReset), msg.Headers[ControlHeader])
+		require.Equal(t, []byte("2"), msg.Headers[RequestedOffsetHeader])
+		require.Equal(t, []byte("5"), msg.Headers[OldestOffsetHeader])
+		require.Equal(t, []byte(strconv.Itoa(num-1)), msg.Headers[NewestOffsetHeader])
+		require.Empty(t, msg.Value)
+		return stream
 	}
 
-	// Wait to receive remaining messages.
-	select {
-	case <-ch2:
-	case <-time.After(10 * time.Second):
-		t.Fatal("Did not receive all expected messages")
+	// Resetting to the earliest offset starts at the oldest retained message.
+	stream = subscribe("earliest")
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, int64(5), msg.Offset)
+	require.Equal(t, []byte("5"), msg.Value)
+
+	// Resetting to the latest offset only receives new messages.
+	stream = subscribe("latest")
+	_, err = client.Publish(context.Background(), name, []byte("new"))
+	require.NoError(t, err)
+	msg, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, int64(num), msg.Offset)
+	require.Equal(t, []byte("new"), msg.Value)
+}
+
+// Ensure a server configured with a larger max receive message size accepts
+// publishes exceeding gRPC's default while a server using the default rejects
+// them.
+func TestGRPCMaxRecvMessageSize(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server which allows large messages.
+	natsOpts := natsdTest.DefaultTestOptions
+	natsOpts.MaxPayload = 8 * 1024 * 1024
+	ns := natsdTest.RunServer(&natsOpts)
+	defer ns.Shutdown()
+
+	// Configure first server with the default max message size.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server with a larger max message size.
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.GRPC.MaxRecvMessageSize = 8 * 1024 * 1024
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1, s2)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name)
+	require.NoError(t, err)
+
+	// Larger than gRPC's default max receive message size of 4MB.
+	req := &proto.PublishRequest{
+		Stream:    name,
+		Value:     make([]byte, 5*1024*1024),
+		AckPolicy: proto.AckPolicy_LEADER,
+	}
+	publish := func(port int) (*proto.PublishResponse, error) {
+		conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", port), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return proto.NewAPIClient(conn).Publish(ctx, req)
 	}
 
-	// Make sure we can play back the log.
+	_, err = publish(5050)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	resp, err := publish(5051)
+	require.NoError(t, err)
+	require.NotNil(t, resp.Ack)
+	require.Equal(t, int64(0), resp.Ack.Offset)
+}
+
+// Ensure a server does not start with inconsistent gRPC keepalive settings.
+func TestGRPCInvalidKeepalive(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	config := getTestConfig("a", true, 5050)
+	config.GRPC.KeepaliveTime = time.Second
+	config.GRPC.KeepaliveTimeout = 2 * time.Second
+	s := New(config)
+	require.Error(t, s.Start())
+}
+
+// Ensure getting a deleted stream returns nil.
+func TestDeleteStream(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = client.DeleteStream(context.Background(), "foo")
+	require.Error(t, err)
+
+	err = client.CreateStream(context.Background(), "foo", "foo", lift.Partitions(3))
+	require.NoError(t, err)
+
+	stream := s1.metadata.GetStream("foo")
+	require.NotNil(t, stream)
+
+	err = client.DeleteStream(context.Background(), "foo")
+	require.NoError(t, err)
+
+	stream = s1.metadata.GetStream("foo")
+	require.Nil(t, stream)
+
+	err = client.CreateStream(context.Background(), "foo", "foo", lift.Partitions(3))
+	require.NoError(t, err)
+}
+
+// Ensure deleting a stream works when we send the request to the metadata
+// follower.
+func TestDeleteStreamPropagate(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 0)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5050)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Connect and send the request to the follower.
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = client.CreateStream(ctx, "foo", "foo")
+	require.NoError(t, err)
+
+	err = client.DeleteStream(context.Background(), "foo")
+	require.NoError(t, err)
+}
+
+// Ensure admin operations carrying a fencing token below the fencing epoch are
+// rejected with Aborted, including when forwarded to the metadata leader.
+func TestDeleteStreamFencingToken(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 0)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5050)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	// Connect and send the requests to the follower.
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	for _, name := range []string{"foo", "bar", "baz"} {
+		err = client.CreateStream(context.Background(), name, name)
+		require.NoError(t, err)
+	}
+
+	fenced := func(token string) context.Context {
+		return grpcMetadata.AppendToOutgoingContext(context.Background(),
+			FencingTokenMetadataKey, token)
+	}
+
+	err = client.DeleteStream(fenced("5"), "foo")
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), s1.metadata.GetFencingEpoch())
+
+	// A stale token is rejected.
+	err = client.DeleteStream(fenced("3"), "bar")
+	require.Equal(t, codes.Aborted, status.Code(err))
+	require.Equal(t, ErrorCodeFenced, GetErrorCode(err))
+	require.NotNil(t, s1.metadata.GetStream("bar"))
+
+	// Invalid tokens are rejected.
+	err = client.DeleteStream(fenced("0"), "bar")
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Other admin operations are fenced too.
+	trimCtx := grpcMetadata.NewIncomingContext(context.Background(),
+		grpcMetadata.Pairs(FencingTokenMetadataKey, "4"))
+	err = s1.TrimStream(trimCtx, "bar", 0)
+	require.Equal(t, codes.Aborted, status.Code(err))
+
+	// The current token and operations without a token are accepted.
+	err = client.DeleteStream(fenced("5"), "bar")
+	require.NoError(t, err)
+	err = client.DeleteStream(context.Background(), "baz")
+	require.NoError(t, err)
+
+	// The fencing epoch is replicated.
+	deadline := time.Now().Add(5 * time.Second)
+	for s2.metadata.GetFencingEpoch() != 5 {
+		if time.Now().After(deadline) {
+			t.Fatal("Fencing epoch was not replicated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Ensure renaming a stream preserves its messages and offsets under the new
+// name and the old name no longer exists.
+func TestRenameStream(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = s1.RenameStream(context.Background(), "foo", "bar")
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	err = client.CreateStream(context.Background(), "foo", "foo")
+	require.NoError(t, err)
+	err = client.CreateStream(context.Background(), "baz", "baz")
+	require.NoError(t, err)
+
+	// Publish some messages.
+	num := 5
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, "foo", []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	err = s1.RenameStream(context.Background(), "foo", "baz")
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	err = s1.RenameStream(context.Background(), "foo", "bar")
+	require.NoError(t, err)
+
+	require.Nil(t, s1.metadata.GetStream("foo"))
+	stream := s1.metadata.GetStream("bar")
+	require.NotNil(t, stream)
+	require.Equal(t, "foo", stream.GetSubject())
+
+	err = s1.RenameStream(context.Background(), "foo", "qux")
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// Make sure we can play back the log under the new name.
 	client2, err := lift.Connect([]string{"localhost:5050"})
 	require.NoError(t, err)
 	defer client2.Close()
-	i = num
-	ch1 = make(chan struct{})
-	err = client2.Subscribe(context.Background(), name,
+	i := 0
+	ch := make(chan struct{})
+	err = client2.Subscribe(context.Background(), "bar",
 		func(msg lift.Message, err error) {
 			require.NoError(t, err)
-			expect := expected[i]
-			assertMsg(t, expect, msg)
+			require.Equal(t, int64(i), msg.Offset())
+			require.Equal(t, []byte(strconv.Itoa(i)), msg.Value())
 			i++
-			if i == num+5 {
-				close(ch1)
+			if i == num {
+				close(ch)
 			}
-		}, lift.StartAtOffset(int64(num)))
+		}, lift.StartAtEarliestReceived())
 	require.NoError(t, err)
 
 	select {
-	case <-ch1:
+	case <-ch:
 	case <-time.After(10 * time.Second):
 		t.Fatal("Did not receive all expected messages")
 	}
 }
+
+// Ensure renamed streams keep their data across restarts and can be renamed
+// again or have their old name reused after replaying the Raft log.
+func TestRenameStreamRestart(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = client.CreateStream(context.Background(), "foo", "foo")
+	require.NoError(t, err)
+	num := 3
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, "foo", []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+	err = s1.RenameStream(context.Background(), "foo", "bar")
+	require.NoError(t, err)
+
+	restart := func() {
+		s1.Stop()
+		s1 = runServerWithConfig(t, s1Config)
+		getMetadataLeader(t, 10*time.Second, s1)
+	}
+	newest := func(stream string) int64 {
+		waitForPartition(t, 10*time.Second, stream, 0, s1)
+		return s1.metadata.GetPartition(stream, 0).log.NewestOffset()
+	}
+
+	restart()
+	require.Equal(t, int64(num-1), newest("bar"))
+
+	// Replaying the Raft log recreates the old stream's directory, which
+	// must not prevent renaming back to it.
+	err = s1.RenameStream(context.Background(), "bar", "foo")
+	require.NoError(t, err)
+	require.Equal(t, int64(num-1), newest("foo"))
+
+	restart()
+	defer s1.Stop()
+	require.Equal(t, int64(num-1), newest("foo"))
+	require.Nil(t, s1.metadata.GetStream("bar"))
+
+	// The intermediate name can be reused.
+	client2, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client2.Close()
+	err = client2.CreateStream(context.Background(), "bar", "bar")
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), newest("bar"))
+}
+
+// Ensure swapping streams exchanges their names, subjects, and data on every
+// replica, including after a restart, so subscribers of the original name see
+// the swapped-in data.
+func TestSwapStreams(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	servers := []*Server{s1, s2}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = s1.SwapStreams(context.Background(), "foo", "foo-v2")
+	require.Equal(t, codes.NotFound, status.Code(err))
+	err = s1.SwapStreams(context.Background(), "foo", "foo")
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = client.CreateStream(context.Background(), "foo", "foo", lift.ReplicationFactor(2))
+	require.NoError(t, err)
+	err = client.CreateStream(context.Background(), "foo-v2", "foo-v2", lift.ReplicationFactor(2))
+	require.NoError(t, err)
+
+	publish := func(stream, value string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := client.Publish(ctx, stream, []byte(value), lift.AckPolicyAll())
+		require.NoError(t, err)
+	}
+
+	// Publish some messages.
+	num := 5
+	for i := 0; i < num; i++ {
+		publish("foo", strconv.Itoa(i))
+	}
+
+	// Build a transformed copy of the stream.
+	ctx, cancel := context.WithCancel(context.Background())
+	copied := make(chan struct{})
+	i := 0
+	err = client.Subscribe(ctx, "foo", func(msg lift.Message, err error) {
+		if i == num {
+			// Copy is done, ignore the error from the cancelled subscription.
+			return
+		}
+		require.NoError(t, err)
+		publish("foo-v2", "v2-"+string(msg.Value()))
+		i++
+		if i == num {
+			close(copied)
+		}
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	select {
+	case <-copied:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Did not copy all expected messages")
+	}
+	cancel()
+	waitForHW(t, 5*time.Second, "foo-v2", 0, int64(num-1), servers...)
+
+	// Swap the copy in from the server which isn't the metadata leader.
+	before := make(map[*Server]*stream, len(servers))
+	for _, s := range servers {
+		before[s] = s.metadata.GetStream("foo")
+	}
+	err = s2.SwapStreams(context.Background(), "foo", "foo-v2")
+	require.NoError(t, err)
+
+	// Returns the values of the messages in the given server's replica of
+	// the stream.
+	values := func(s *Server, stream string) []string {
+		waitForPartition(t, 10*time.Second, stream, 0, s)
+		partition := s.metadata.GetPartition(stream, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r, err := partition.log.NewReader(0, true)
+		require.NoError(t, err)
+		var values []string
+		for offset := int64(-1); offset < partition.log.NewestOffset(); {
+			var m commitlog.SerializedMessage
+			m, offset, _, _, err = r.ReadMessage(ctx, make([]byte, 28))
+			require.NoError(t, err)
+			values = append(values, string(m.Value()))
+		}
+		return values
+	}
+	var (
+		original    = make([]string, num)
+		transformed = make([]string, num)
+	)
+	for i := 0; i < num; i++ {
+		original[i] = strconv.Itoa(i)
+		transformed[i] = "v2-" + strconv.Itoa(i)
+	}
+	for _, s := range servers {
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			if stream := s.metadata.GetStream("foo"); stream != nil && stream != before[s] {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Streams were not swapped")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.Equal(t, "foo-v2", s.metadata.GetStream("foo-v2").GetSubject())
+		require.Equal(t, transformed, values(s, "foo"))
+		require.Equal(t, original, values(s, "foo-v2"))
+	}
+
+	// New subscribers and publishes on the original name use the new data.
+	publish("foo", "v2-"+strconv.Itoa(num))
+	transformed = append(transformed, "v2-"+strconv.Itoa(num))
+	client2, err := lift.Connect([]string{"localhost:5050", "localhost:5051"})
+	require.NoError(t, err)
+	defer client2.Close()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan struct{})
+	i = 0
+	err = client2.Subscribe(ctx, "foo", func(msg lift.Message, err error) {
+		if i == len(transformed) {
+			return
+		}
+		require.NoError(t, err)
+		require.Equal(t, int64(i), msg.Offset())
+		require.Equal(t, transformed[i], string(msg.Value()))
+		i++
+		if i == len(transformed) {
+			close(received)
+		}
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	select {
+	case <-received:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Did not receive all expected messages")
+	}
+
+	// The swap is not redone when the Raft log is replayed on restart.
+	s2.Stop()
+	s2 = runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+	waitForHW(t, 10*time.Second, "foo", 0, int64(num), s2)
+	require.Equal(t, transformed, values(s2, "foo"))
+	require.Equal(t, original, values(s2, "foo-v2"))
+}
+
+// Ensure sending a subscribe request to a server that is not the stream leader
+// returns an error.
+func TestSubscribeStreamNotLeader(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1, s2)
+
+	// Create the stream.
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name,
+		lift.ReplicationFactor(2))
+	require.NoError(t, err)
+
+	require.NoError(t, client.Close())
+
+	// Wait for both nodes to create stream.
+	waitForPartition(t, 5*time.Second, name, 0, s1, s2)
+
+	// Connect to the server that is the stream follower.
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, s1, s2)
+	var followerConfig *Config
+	if leader == s1 {
+		followerConfig = s2Config
+	} else {
+		followerConfig = s1Config
+	}
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", followerConfig.Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// Subscribe on the follower.
+	stream, err := apiClient.Subscribe(context.Background(), &proto.SubscribeRequest{Stream: name})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Server not partition leader")
+	require.Equal(t, ErrorCodeNotLeader, GetErrorCode(err))
+	require.Equal(t, leader.config.Clustering.ServerID, GetErrorLeader(err))
+}
+
+// Ensure FetchMetadata advertises the configured metadata TTL and NOT_LEADER
+// errors name the partition's new leader after a leader change.
+func TestMetadataTTLAndNotLeaderAfterFailover(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	var servers []*Server
+	for i, id := range []string{"a", "b", "c"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.MetadataClientTTL = 30 * time.Second
+		config.Clustering.ReplicaMaxLeaderTimeout = time.Second
+		config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+		config.Clustering.ReplicaFetchTimeout = 500 * time.Millisecond
+		s := runServerWithConfig(t, config)
+		defer s.Stop()
+		servers = append(servers, s)
+	}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	var md grpcMetadata.MD
+	_, err = proto.NewAPIClient(conn).FetchMetadata(context.Background(),
+		&proto.FetchMetadataRequest{}, grpc.Header(&md))
+	require.NoError(t, err)
+	require.Equal(t, []string{"30s"}, md.Get(MetadataTTLMetadataKey))
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+
+	// Stop the partition leader to force a new one.
+	oldLeader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	oldLeader.Stop()
+	var remaining []*Server
+	for _, s := range servers {
+		if s != oldLeader {
+			remaining = append(remaining, s)
+		}
+	}
+	getMetadataLeader(t, 10*time.Second, remaining...)
+	newLeader := getPartitionLeader(t, 10*time.Second, name, 0, remaining...)
+	follower := remaining[0]
+	if follower == newLeader {
+		follower = remaining[1]
+	}
+
+	// Wait for the follower to learn of the new leader.
+	followerConn, err := grpc.Dial(fmt.Sprintf("localhost:%d", follower.config.Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer followerConn.Close()
+	apiClient := proto.NewAPIClient(followerConn)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		stream, err := apiClient.Subscribe(context.Background(), &proto.SubscribeRequest{Stream: name})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, ErrorCodeNotLeader, GetErrorCode(err))
+		if GetErrorLeader(err) == newLeader.config.Clustering.ServerID {
+			break
+		}
+		if time.Now().After(deadline) {
+			stackFatalf(t, "Expected NOT_LEADER error naming leader %s, got %s",
+				newLeader.config.Clustering.ServerID, GetErrorLeader(err))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Ensure publishing and receiving messages on a stream works.
+func TestStreamPublishSubscribe(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	num := 5
+	expected := make([]*message, num)
+	for i := 0; i < num; i++ {
+		expected[i] = &message{
+			Key:    []byte("bar"),
+			Value:  []byte(strconv.Itoa(i)),
+			Offset: int64(i),
+		}
+	}
+	i := 0
+	ch1 := make(chan struct{})
+	ch2 := make(chan struct{})
+	err = client.Subscribe(context.Background(), name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		expect := expected[i]
+		assertMsg(t, expect, msg)
+		i++
+		if i == num {
+			close(ch1)
+		}
+		if i == num+5 {
+			close(ch2)
+		}
+	})
+	require.NoError(t, err)
+
+	// Publish messages.
+	for i := 0; i < num; i++ {
+		_, err = client.Publish(context.Background(), name, expected[i].Value,
+			lift.Key(expected[i].Key))
+		require.NoError(t, err)
+	}
+
+	// Wait to receive initial messages.
+	select {
+	case <-ch1:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Did not receive all expected messages")
+	}
+
+	// Publish some more messages.
+	for i := 0; i < 5; i++ {
+		expected = append(expected, &message{
+			Key:    []byte("baz"),
+			Value:  []byte(strconv.Itoa(i + num)),
+			Offset: int64(i + num),
+		})
+	}
+	for i := 0; i < 5; i++ {
+		_, err = client.Publish(context.Background(), name, expected[i+num].Value,
+			lift.Key(expected[i+num].Key))
+		require.NoError(t, err)
+	}
+
+	// Wait to receive remaining messages.
+	select {
+	case <-ch2:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Did not receive all expected messages")
+	}
+
+	// Make sure we can play back the log.
+	client2, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client2.Close()
+	i = num
+	ch1 = make(chan struct{})
+	err = client2.Subscribe(context.Background(), name,
+		func(msg lift.Message, err error) {
+			require.NoError(t, err)
+			expect := expected[i]
+			assertMsg(t, expect, msg)
+			i++
+			if i == num+5 {
+				close(ch1)
+			}
+		}, lift.StartAtOffset(int64(num)))
+	require.NoError(t, err)
+
+	select {
+	case <-ch1:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Did not receive all expected messages")
+	}
+}
+
+// Ensure a publish with TryPublish set fails fast with ResourceExhausted when
+// the partition leader's commit backlog is full while a normal publish blocks.
+func TestTryPublishLeaderBusy(t *testing.T) {
+	defer cleanupStorage(t)
+
+	defer func(backlog int64) {
+		maxCommitBacklog = backlog
+	}(maxCommitBacklog)
+	maxCommitBacklog = 5
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1, s2)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name, lift.ReplicationFactor(2))
+	require.NoError(t, err)
+
+	waitForPartition(t, 5*time.Second, name, 0, s1, s2)
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, s1, s2)
+	partition := leader.metadata.GetPartition(name, 0)
+
+	// Pause replication so messages are never committed.
+	partition.PauseReplication()
+
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", leader.config.Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// TryPublish succeeds while the leader is not busy.
+	tryCtx := grpcMetadata.AppendToOutgoingContext(context.Background(), TryPublishMetadataKey, "true")
+	_, err = apiClient.Publish(tryCtx, &proto.PublishRequest{Stream: name, Value: []byte("0")})
+	require.NoError(t, err)
+
+	// TryPublish fails on a server which isn't the partition leader since it
+	// can't tell if the leader is busy.
+	follower := s1
+	if leader == s1 {
+		follower = s2
+	}
+	followerConn, err := grpc.Dial(fmt.Sprintf("localhost:%d", follower.config.Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer followerConn.Close()
+	_, err = proto.NewAPIClient(followerConn).Publish(tryCtx, &proto.PublishRequest{Stream: name, Value: []byte("0")})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.Equal(t, ErrorCodeNotLeader, GetErrorCode(err))
+
+	// Fill the commit backlog.
+	for i := 1; i < int(maxCommitBacklog); i++ {
+		_, err = apiClient.Publish(context.Background(), &proto.PublishRequest{
+			Stream: name,
+			Value:  []byte(strconv.Itoa(i)),
+		})
+		require.NoError(t, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !partition.IsBusy() {
+		if time.Now().After(deadline) {
+			t.Fatal("Partition leader did not become busy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// TryPublish fails fast.
+	start := time.Now()
+	_, err = apiClient.Publish(tryCtx, &proto.PublishRequest{Stream: name, Value: []byte("hello")})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.True(t, time.Since(start) < time.Second)
+
+	// A normal publish waiting on the ack blocks until its deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err = apiClient.Publish(ctx, &proto.PublishRequest{
+		Stream:    name,
+		Value:     []byte("hello"),
+		AckPolicy: proto.AckPolicy_ALL,
+	})
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+// Ensure publishes to a partition whose leader is being re-elected fail
+// immediately with the fail policy, fail after the timeout with the queue
+// policy, and are sent to the new leader once it's elected with the queue
+// policy.
+func TestPublishDuringLeaderElection(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers.
+	var servers []*Server
+	for i, id := range []string{"a", "b", "c"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxLeaderTimeout = time.Second
+		config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+		config.Clustering.ReplicaFetchTimeout = 500 * time.Millisecond
+		s := runServerWithConfig(t, config)
+		defer s.Stop()
+		servers = append(servers, s)
+	}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+	_, err = client.Publish(context.Background(), name, []byte("0"), lift.AckPolicyAll())
+	require.NoError(t, err)
+	waitForHW(t, 5*time.Second, name, 0, 0, servers...)
+
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	var followers []*Server
+	for _, s := range servers {
+		if s != leader {
+			followers = append(followers, s)
+		}
+	}
+	publisher, other := followers[0], followers[1]
+	partition := publisher.metadata.GetPartition(name, 0)
+
+	// Pause replication on the other follower so that it doesn't report the
+	// leader, which keeps the election from completing, then stop the
+	// leader.
+	require.NoError(t, other.PauseReplication(name, nil))
+	leader.Stop()
+	metadataLeader := getMetadataLeader(t, 10*time.Second, followers...)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !partition.IsElectingLeader() {
+		if time.Now().After(deadline) {
+			t.Fatal("Follower did not report the leader")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", publisher.config.Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+	publish := func() (*proto.PublishResponse, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return apiClient.Publish(ctx, &proto.PublishRequest{
+			Stream:    name,
+			Value:     []byte("1"),
+			AckPolicy: proto.AckPolicy_LEADER,
+		})
+	}
+
+	// The fail policy fails the publish immediately.
+	publisher.config.Clustering.PublishElectionPolicy = PublishElectionFail
+	start := time.Now()
+	_, err = publish()
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.Equal(t, ErrorCodeLeaderElection, GetErrorCode(err))
+	require.True(t, time.Since(start) < time.Second)
+
+	// The queue policy fails the publish if no leader is elected in time.
+	publisher.config.Clustering.PublishElectionPolicy = PublishElectionQueue
+	publisher.config.Clustering.PublishElectionTimeout = 200 * time.Millisecond
+	start = time.Now()
+	_, err = publish()
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.Equal(t, ErrorCodeLeaderElection, GetErrorCode(err))
+	elapsed := time.Since(start)
+	require.True(t, elapsed >= 200*time.Millisecond && elapsed < 5*time.Second)
+
+	// Otherwise the queued publish is sent to the new leader. Make the other
+	// follower read-only so that the publisher is elected, then let it report
+	// the leader to complete the election.
+	publisher.config.Clustering.PublishElectionTimeout = 10 * time.Second
+	type result struct {
+		resp *proto.PublishResponse
+		err  error
+	}
+	published := make(chan result, 1)
+	go func() {
+		resp, err := publish()
+		published <- result{resp, err}
+	}()
+	require.NoError(t, metadataLeader.SetServerReadOnly(context.Background(),
+		other.config.Clustering.ServerID, true))
+	require.NoError(t, other.ResumeReplication(name, nil))
+
+	select {
+	case res := <-published:
+		require.NoError(t, res.err)
+		require.NotNil(t, res.resp.Ack)
+		require.Equal(t, int64(1), res.resp.Ack.Offset)
+	case <-time.After(15 * time.Second):
+		t.Fatal("Queued publish did not complete")
+	}
+	current, _ := partition.GetLeader()
+	require.Equal(t, publisher.config.Clustering.ServerID, current)
+	require.False(t, partition.IsElectingLeader())
+}
+
+// Ensure AckPolicy_ALL publishes fail with ResourceExhausted once the
+// partition leader's pending acks reach streams.max.pending.acks and succeed
+// again after the backlog is committed.
+func TestPublishMaxPendingAcks(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.MaxPendingAcks = 3
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Streams.MaxPendingAcks = 3
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1, s2)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name, lift.ReplicationFactor(2))
+	require.NoError(t, err)
+
+	waitForISR(t, 10*time.Second, name, 0, 2, s1, s2)
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, s1, s2)
+	partition := leader.metadata.GetPartition(name, 0)
+
+	// Pause replication so messages are never committed.
+	partition.PauseReplication()
+
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", leader.config.Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// Saturate the pending acks. The publishes time out since nothing is
+	// committed, but their acks stay pending.
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		_, err = apiClient.Publish(ctx, &proto.PublishRequest{
+			Stream:    name,
+			Value:     []byte(strconv.Itoa(i)),
+			AckPolicy: proto.AckPolicy_ALL,
+		})
+		cancel()
+		require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !partition.PendingAcksFull() {
+		if time.Now().After(deadline) {
+			t.Fatal("Pending acks did not reach the maximum")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Further AckPolicy_ALL publishes are rejected rather than queued.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = apiClient.Publish(ctx, &proto.PublishRequest{
+		Stream:    name,
+		Value:     []byte("hello"),
+		AckPolicy: proto.AckPolicy_ALL,
+	})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.True(t, time.Since(start) < time.Second)
+	require.Equal(t, int64(3), partition.commitQueue.AckAllLen())
+
+	// Other ack policies are unaffected.
+	_, err = apiClient.Publish(ctx, &proto.PublishRequest{
+		Stream:    name,
+		Value:     []byte("hello"),
+		AckPolicy: proto.AckPolicy_LEADER,
+	})
+	require.NoError(t, err)
+
+	// Once the backlog is committed, AckPolicy_ALL publishes succeed again.
+	partition.ResumeReplication()
+	deadline = time.Now().Add(5 * time.Second)
+	for partition.PendingAcksFull() {
+		if time.Now().After(deadline) {
+			t.Fatal("Pending acks were not drained")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, err := apiClient.Publish(ctx, &proto.PublishRequest{
+		Stream:    name,
+		Value:     []byte("hello"),
+		AckPolicy: proto.AckPolicy_ALL,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), resp.Ack.Offset)
+}
+
+// Ensure AckPolicy_ALL publishes fail with ResourceExhausted while the
+// partition leader's append latency exceeds streams.max.append.latency and
+// succeed again once it recovers.
+func TestPublishMaxAppendLatency(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.MaxAppendLatency = 200 * time.Millisecond
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Streams.MaxAppendLatency = 200 * time.Millisecond
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1, s2)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name, lift.ReplicationFactor(2))
+	require.NoError(t, err)
+
+	waitForISR(t, 10*time.Second, name, 0, 2, s1, s2)
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, s1, s2)
+	partition := leader.metadata.GetPartition(name, 0)
+
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", leader.config.Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// Pause replication so the published message isn't committed, which
+	// drives up the append latency.
+	partition.PauseReplication()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	_, err = apiClient.Publish(ctx, &proto.PublishRequest{
+		Stream:    name,
+		Value:     []byte("slow"),
+		AckPolicy: proto.AckPolicy_ALL,
+	})
+	cancel()
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	deadline := time.Now().Add(5 * time.Second)
+	for !partition.AppendLatencyExceeded() {
+		if time.Now().After(deadline) {
+			t.Fatal("Append latency did not exceed the maximum")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The latency is reported by DescribeStream.
+	desc, err := leader.DescribeStream(name)
+	require.NoError(t, err)
+	require.True(t, desc.Partitions[0].AppendLatency > 200*time.Millisecond)
+
+	// AckPolicy_ALL publishes are shed rather than queued.
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = apiClient.Publish(ctx, &proto.PublishRequest{
+		Stream:    name,
+		Value:     []byte("hello"),
+		AckPolicy: proto.AckPolicy_ALL,
+	})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.True(t, time.Since(start) < time.Second)
+
+	// Once the backlog is committed, the latency recovers and AckPolicy_ALL
+	// publishes succeed again.
+	partition.ResumeReplication()
+	deadline = time.Now().Add(5 * time.Second)
+	for partition.AppendLatencyExceeded() {
+		if time.Now().After(deadline) {
+			t.Fatal("Append latency did not recover")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, err := apiClient.Publish(ctx, &proto.PublishRequest{
+		Stream:    name,
+		Value:     []byte("hello"),
+		AckPolicy: proto.AckPolicy_ALL,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), resp.Ack.Offset)
+}
+
+// Ensure AckPolicy_ALL publishes beyond a stream's max commit waiters are
+// rejected or blocked according to streams.commit.waiters.policy.
+func TestPublishMaxCommitWaiters(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1, s2)
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// Create a stream allowing a single publish waiting on commit.
+	name := "foo"
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), MaxCommitWaitersMetadataKey, "1")
+	_, err = apiClient.CreateStream(ctx, &proto.CreateStreamRequest{
+		Subject:           "foo",
+		Name:              name,
+		ReplicationFactor: 2,
+	})
+	require.NoError(t, err)
+
+	waitForISR(t, 10*time.Second, name, 0, 2, s1, s2)
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, s1, s2)
+	partition := leader.metadata.GetPartition(name, 0)
+	require.Equal(t, 1, partition.maxCommitWaiters())
+
+	conn, err = grpc.Dial(fmt.Sprintf("localhost:%d", leader.config.Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient = proto.NewAPIClient(conn)
+	publish := func(timeout time.Duration, ackPolicy proto.AckPolicy) (*proto.PublishResponse, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return apiClient.Publish(ctx, &proto.PublishRequest{
+			Stream:    name,
+			Value:     []byte("hello"),
+			AckPolicy: ackPolicy,
+		})
+	}
+
+	// Pause replication so a publish waits on commit until it's resumed.
+	partition.PauseReplication()
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := publish(10*time.Second, proto.AckPolicy_ALL)
+		waiting <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for leader.commitWaiters.count(name) < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Publish did not wait on commit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The reject policy fails further AckPolicy_ALL publishes immediately.
+	start := time.Now()
+	_, err = publish(5*time.Second, proto.AckPolicy_ALL)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Equal(t, ErrorCodeResourceExhausted, GetErrorCode(err))
+	require.True(t, time.Since(start) < time.Second)
+
+	// Other ack policies are unaffected.
+	_, err = publish(5*time.Second, proto.AckPolicy_LEADER)
+	require.NoError(t, err)
+
+	// The block policy holds further publishes until their deadline.
+	leader.config.Streams.CommitWaitersPolicy = CommitWaitersBlock
+	start = time.Now()
+	_, err = publish(200*time.Millisecond, proto.AckPolicy_ALL)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	require.True(t, time.Since(start) >= 200*time.Millisecond)
+
+	// Or until the waiting publish is acked.
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := publish(10*time.Second, proto.AckPolicy_ALL)
+		blocked <- err
+	}()
+	select {
+	case err := <-blocked:
+		t.Fatalf("Expected publish to block, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	partition.ResumeReplication()
+	for _, ch := range []chan error{waiting, blocked} {
+		select {
+		case err := <-ch:
+			require.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("Expected publish to be acked")
+		}
+	}
+	require.Equal(t, 0, leader.commitWaiters.count(name))
+}
+
+// publishRejected publishes the message directly to NATS on the given subject
+// with an ack inbox and returns the rejection the partition leader sends to it.
+func publishRejected(t *testing.T, subject string, msg *proto.Message) *internal.PublishRejection {
+	nc, err := nats.Connect(nats.DefaultURL)
+	require.NoError(t, err)
+	defer nc.Close()
+	msg.AckInbox = nats.NewInbox()
+	msg.CorrelationId = "rejected"
+	msg.AckPolicy = proto.AckPolicy_LEADER
+	sub, err := nc.SubscribeSync(msg.AckInbox)
+	require.NoError(t, err)
+	data, err := internal.MarshalPublish(msg)
+	require.NoError(t, err)
+	require.NoError(t, nc.Publish(subject, data))
+	ackMsg, err := sub.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	rejection, err := internal.UnmarshalPublishRejection(ackMsg.Data)
+	require.NoError(t, err)
+	require.Equal(t, "rejected", rejection.CorrelationId)
+	return rejection
+}
+
+// Ensure a stream created with RequireKey rejects keyless publishes with
+// InvalidArgument, including those published directly to NATS, and accepts
+// messages with a key.
+func TestPublishRequireKey(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	name := "foo"
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), RequireKeyMetadataKey, "true")
+	_, err = apiClient.CreateStream(ctx, &proto.CreateStreamRequest{Subject: "foo", Name: name})
+	require.NoError(t, err)
+	waitForPartition(t, 5*time.Second, name, 0, s1)
+	require.True(t, s1.metadata.GetPartition(name, 0).GetRequireKey())
+
+	// A keyless publish is rejected.
+	_, err = apiClient.Publish(context.Background(), &proto.PublishRequest{
+		Stream: name,
+		Value:  []byte("hello"),
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// So is a keyless message published directly to NATS.
+	rejection := publishRejected(t, "foo", &proto.Message{Value: []byte("hello")})
+	require.Equal(t, uint32(codes.InvalidArgument), rejection.Code)
+	require.Contains(t, rejection.Reason, "requires a key")
+
+	// A publish with a key is accepted.
+	pubCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := apiClient.Publish(pubCtx, &proto.PublishRequest{
+		Stream:    name,
+		Key:       []byte("key"),
+		Value:     []byte("hello"),
+		AckPolicy: proto.AckPolicy_LEADER,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), resp.Ack.Offset)
+}
+
+// Ensure messages published concurrently in a producer session are appended
+// in sequence order and a sequence number which was already published is
+// rejected with FailedPrecondition.
+func TestPublishProducerSessionOrder(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	name := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), "foo", name))
+
+	publish := func(seq int) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		ctx = grpcMetadata.AppendToOutgoingContext(ctx,
+			ProducerSessionMetadataKey, "producer",
+			ProducerSequenceMetadataKey, strconv.Itoa(seq))
+		_, err := apiClient.Publish(ctx, &proto.PublishRequest{
+			Stream:    name,
+			Value:     []byte(strconv.Itoa(seq)),
+			AckPolicy: proto.AckPolicy_LEADER,
+		})
+		return err
+	}
+
+	// Publish all messages concurrently, in reverse order to make sure they
+	// arrive out of order.
+	num := 1000
+	errC := make(chan error, num)
+	for i := num - 1; i >= 0; i-- {
+		go func(seq int) {
+			errC <- publish(seq)
+		}(i)
+	}
+	for i := 0; i < num; i++ {
+		require.NoError(t, <-errC)
+	}
+
+	// The log offsets must match the send order.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msgs := make(chan lift.Message, num)
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		msgs <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	for i := 0; i < num; i++ {
+		select {
+		case msg := <-msgs:
+			require.Equal(t, int64(i), msg.Offset())
+			require.Equal(t, strconv.Itoa(i), string(msg.Value()))
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Did not receive message %d", i)
+		}
+	}
+
+	// Publishing an already published sequence number fails.
+	require.Equal(t, codes.FailedPrecondition, status.Code(publish(0)))
+}
+
+// Ensure a producer session's publish which is rejected before it's forwarded
+// doesn't hold up the session's next publish.
+func TestPublishProducerSessionRejected(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	name := "foo"
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		RequireKeyMetadataKey, "true")
+	require.NoError(t, client.CreateStream(ctx, "foo", name))
+
+	publish := func(seq int, key []byte) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		ctx = grpcMetadata.AppendToOutgoingContext(ctx,
+			ProducerSessionMetadataKey, "producer",
+			ProducerSequenceMetadataKey, strconv.Itoa(seq))
+		_, err := apiClient.Publish(ctx, &proto.PublishRequest{
+			Stream:    name,
+			Key:       key,
+			Value:     []byte(strconv.Itoa(seq)),
+			AckPolicy: proto.AckPolicy_LEADER,
+		})
+		return err
+	}
+
+	// The first publish is rejected for missing a key.
+	require.Equal(t, codes.InvalidArgument, status.Code(publish(0, nil)))
+
+	// The next one goes through without waiting for the gap timeout.
+	start := time.Now()
+	require.NoError(t, publish(1, []byte("key")))
+	require.True(t, time.Since(start) < producerSequenceGapTimeout/2)
+}
+
+// Ensure a server tracking the maximum number of producer sessions evicts the
+// least recently used one, which resumes where it left off when it publishes
+// again, and counts duplicates and evictions.
+func TestPublishProducerSessionEviction(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.ProducerMaxSessions = 1
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	name := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), "foo", name))
+
+	publish := func(session string, seq int) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		ctx = grpcMetadata.AppendToOutgoingContext(ctx,
+			ProducerSessionMetadataKey, session,
+			ProducerSequenceMetadataKey, strconv.Itoa(seq))
+		_, err := apiClient.Publish(ctx, &proto.PublishRequest{
+			Stream:    name,
+			Value:     []byte(session),
+			AckPolicy: proto.AckPolicy_LEADER,
+		})
+		return err
+	}
+
+	require.NoError(t, publish("p", 0))
+	require.Equal(t, ErrorCodeSequenceTooLow, GetErrorCode(publish("p", 0)))
+
+	// Tracking q evicts p, which resumes where it left off without waiting
+	// out the gap timeout, so its duplicate is still rejected.
+	require.NoError(t, publish("q", 0))
+	start := time.Now()
+	require.NoError(t, publish("p", 1))
+	require.True(t, time.Since(start) < producerSequenceGapTimeout)
+	require.Equal(t, ErrorCodeSequenceTooLow, GetErrorCode(publish("p", 0)))
+
+	require.Equal(t, int64(2), s1.DuplicatePublishes())
+	require.Equal(t, int64(2), s1.EvictedProducerSessions())
+}
+
+// Ensure messages with an empty value are stored, rejected, or stored as
+// tombstones depending on the stream's EmptyValue policy.
+func TestPublishEmptyValue(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// An invalid policy is rejected.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), EmptyValueMetadataKey, "drop")
+	_, err = apiClient.CreateStream(ctx, &proto.CreateStreamRequest{Subject: "invalid", Name: "invalid"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Creates a stream with the given policy, publishes a message with an
+	// empty value, and returns the publish error.
+	publishEmpty := func(policy string) error {
+		ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), EmptyValueMetadataKey, policy)
+		_, err := apiClient.CreateStream(ctx, &proto.CreateStreamRequest{Subject: policy, Name: policy})
+		require.NoError(t, err)
+		waitForPartition(t, 5*time.Second, policy, 0, s1)
+
+		pubCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = apiClient.Publish(pubCtx, &proto.PublishRequest{
+			Stream:    policy,
+			Key:       []byte("key"),
+			Value:     []byte{},
+			AckPolicy: proto.AckPolicy_LEADER,
+		})
+		return err
+	}
+
+	// Returns the first message of the given stream.
+	readFirst := func(stream string) lift.Message {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		recv := make(chan lift.Message, 1)
+		err := client.Subscribe(ctx, stream, func(msg lift.Message, err error) {
+			if err == nil {
+				select {
+				case recv <- msg:
+				default:
+				}
+			}
+		}, lift.StartAtEarliestReceived())
+		require.NoError(t, err)
+		select {
+		case msg := <-recv:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Did not receive message on stream %s", stream)
+		}
+		return nil
+	}
+
+	// By default, the message is stored as-is.
+	require.NoError(t, publishEmpty("store"))
+	msg := readFirst("store")
+	require.Equal(t, int64(0), msg.Offset())
+	require.Nil(t, msg.Value())
+	require.NotContains(t, msg.Headers(), TombstoneHeader)
+
+	// The message is rejected and nothing is stored.
+	require.Equal(t, codes.InvalidArgument, status.Code(publishEmpty("reject")))
+	rejection := publishRejected(t, "reject", &proto.Message{Key: []byte("key")})
+	require.Equal(t, uint32(codes.InvalidArgument), rejection.Code)
+	require.Contains(t, rejection.Reason, "empty values")
+	require.Equal(t, int64(-1), s1.metadata.GetPartition("reject", 0).log.NewestOffset())
+
+	// The message is stored as a tombstone.
+	require.NoError(t, publishEmpty("tombstone"))
+	msg = readFirst("tombstone")
+	require.Equal(t, int64(0), msg.Offset())
+	require.Equal(t, []byte("key"), msg.Key())
+	require.Nil(t, msg.Value())
+	require.Equal(t, []byte("true"), msg.Headers()[TombstoneHeader])
+}
+
+// Ensure a client connected to a single seed server discovers the rest of the
+// cluster through FetchMetadata and learns about servers which join later.
+func TestFetchMetadataDiscoverBrokers(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Configure third server.
+	s3Config := getTestConfig("c", false, 5052)
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1, s2, s3)
+
+	// Connect using only the seed server.
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	waitForBrokers := func(expected ...string) {
+		deadline := time.Now().Add(10 * time.Second)
+		var addrs []string
+		for time.Now().Before(deadline) {
+			metadata, err := client.FetchMetadata(context.Background())
+			require.NoError(t, err)
+			addrs = metadata.Addrs()
+			if len(addrs) == len(expected) {
+				require.ElementsMatch(t, expected, addrs)
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		stackFatalf(t, "Expected brokers %v, got %v", expected, addrs)
+	}
+
+	waitForBrokers("localhost:5050", "localhost:5051", "localhost:5052")
+
+	// Add a fourth server to the cluster.
+	s4Config := getTestConfig("d", false, 5053)
+	s4 := runServerWithConfig(t, s4Config)
+	defer s4.Stop()
+
+	waitForBrokers("localhost:5050", "localhost:5051", "localhost:5052", "localhost:5053")
+}
+
+// Ensure FetchMetadata reports the metadata leader, followers, and Raft term
+// in its response headers and that they follow a new election.
+func TestFetchMetadataRaftRoles(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Configure third server.
+	s3Config := getTestConfig("c", false, 5052)
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := []*Server{s1, s2, s3}
+	leader := getMetadataLeader(t, 10*time.Second, servers...)
+
+	followersOf := func(leader *Server) []string {
+		followers := []string{}
+		for _, s := range servers {
+			if s != leader {
+				followers = append(followers, s.config.Clustering.ServerID)
+			}
+		}
+		sort.Strings(followers)
+		return followers
+	}
+
+	// Wait until the given server reports the leader's roles. Servers learn
+	// of membership changes and new terms asynchronously, so retry until
+	// every header matches.
+	waitForRoles := func(s, leader *Server, term string) {
+		conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", s.config.Port), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+		apiClient := proto.NewAPIClient(conn)
+
+		var (
+			expectedLeader    = []string{leader.config.Clustering.ServerID}
+			expectedFollowers = followersOf(leader)
+			expectedTerm      = []string{term}
+			deadline          = time.Now().Add(10 * time.Second)
+			md                grpcMetadata.MD
+		)
+		for time.Now().Before(deadline) {
+			md = grpcMetadata.MD{}
+			_, err := apiClient.FetchMetadata(context.Background(),
+				&proto.FetchMetadataRequest{}, grpc.Header(&md))
+			require.NoError(t, err)
+			followers := md.Get(MetadataFollowersMetadataKey)
+			sort.Strings(followers)
+			if reflect.DeepEqual(expectedLeader, md.Get(MetadataLeaderMetadataKey)) &&
+				reflect.DeepEqual(expectedFollowers, followers) &&
+				reflect.DeepEqual(expectedTerm, md.Get(MetadataTermMetadataKey)) {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		stackFatalf(t, "Expected metadata leader %v, followers %v, and term %v, got %v",
+			expectedLeader, expectedFollowers, expectedTerm, md)
+	}
+
+	term := leader.getRaft().Stats()["term"]
+	for _, s := range servers {
+		waitForRoles(s, leader, term)
+	}
+
+	// Stop the leader to force a new election.
+	leader.Stop()
+	var remaining []*Server
+	for _, s := range servers {
+		if s != leader {
+			remaining = append(remaining, s)
+		}
+	}
+	newLeader := getMetadataLeader(t, 10*time.Second, remaining...)
+	newTerm := newLeader.getRaft().Stats()["term"]
+
+	for _, s := range remaining {
+		waitForRoles(s, newLeader, newTerm)
+	}
+
+	oldTermVal, err := strconv.ParseUint(term, 10, 64)
+	require.NoError(t, err)
+	newTermVal, err := strconv.ParseUint(newTerm, 10, 64)
+	require.NoError(t, err)
+	require.Greater(t, newTermVal, oldTermVal)
+}
+
+// Ensure when multiple streams are attached to the same subject, publishing
+// to a stream by name only appends the message to that stream while
+// publishing directly to the subject appends it to all of them.
+func TestPublishStreamSharedSubject(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create two streams on the same subject.
+	subject := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), subject, "foo"))
+	require.NoError(t, client.CreateStream(context.Background(), subject, "bar"))
+
+	// Publish to a specific stream.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.Publish(ctx, "bar", []byte("hello"), lift.AckPolicyAll())
+	require.NoError(t, err)
+
+	// Publish directly to the subject.
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.PublishToSubject(ctx, subject, []byte("world"), lift.AckPolicyAll())
+	require.NoError(t, err)
+
+	waitForHW(t, 5*time.Second, "bar", 0, 1, s1)
+	waitForHW(t, 5*time.Second, "foo", 0, 0, s1)
+
+	// The message published to "bar" should not have been appended to "foo".
+	foo := s1.metadata.GetPartition("foo", 0)
+	require.Equal(t, int64(0), foo.log.NewestOffset())
+	bar := s1.metadata.GetPartition("bar", 0)
+	require.Equal(t, int64(1), bar.log.NewestOffset())
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	gotMsg := make(chan lift.Message)
+	err = client.Subscribe(ctx, "foo", func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		gotMsg <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+
+	select {
+	case msg := <-gotMsg:
+		require.Equal(t, int64(0), msg.Offset())
+		require.Equal(t, []byte("world"), msg.Value())
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not receive expected message")
+	}
+}
+
+// Ensure subscribers can read the messages committed to a paused stream
+// without resuming it by default and are rejected when
+// PausedRejectSubscriptions is enabled.
+func TestSubscribePausedStream(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name)
+	require.NoError(t, err)
+
+	// Publish some messages.
+	num := 3
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	err = client.PauseStream(context.Background(), name)
+	require.NoError(t, err)
+	checkPartitionPaused(t, name, 0, true, s1)
+
+	// Subscribing by default reads the committed messages.
+	msgs := make(chan lift.Message, num)
+	ctx, cancel := context.WithCancel(context.Background())
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		if err != nil {
+			return
+		}
+		msgs <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	for i := 0; i < num; i++ {
+		select {
+		case msg := <-msgs:
+			require.Equal(t, int64(i), msg.Offset())
+			require.Equal(t, []byte(strconv.Itoa(i)), msg.Value())
+		case <-time.After(5 * time.Second):
+			t.Fatal("Did not receive expected message")
+		}
+	}
+	cancel()
+
+	// The partition stays paused.
+	checkPartitionPaused(t, name, 0, true, s1)
+
+	// Subscribing is rejected when enabled.
+	s1.config.Streams.PausedRejectSubscriptions = true
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+	stream, err := apiClient.Subscribe(context.Background(), &proto.SubscribeRequest{
+		Stream:        name,
+		StartPosition: proto.StartPosition_EARLIEST,
+	})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// Publishing resumes the partition.
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.Publish(ctx, name, []byte("resumed"), lift.AckPolicyAll())
+	require.NoError(t, err)
+	checkPartitionPaused(t, name, 0, false, s1)
+
+	msgs = make(chan lift.Message, 1)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		if err != nil {
+			return
+		}
+		msgs <- msg
+	}, lift.StartAtOffset(int64(num)))
+	require.NoError(t, err)
+	select {
+	case msg := <-msgs:
+		require.Equal(t, []byte("resumed"), msg.Value())
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not receive expected message")
+	}
+}
+
+// Ensure publishes to a stream with a schema are rejected when they fail the
+// schema's validator.
+func TestPublishSchemaValidation(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server with a validator requiring a JSON object with a name.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.SchemaValidators = map[string]SchemaValidator{
+		"user": func(key, value []byte) error {
+			var user map[string]interface{}
+			if err := json.Unmarshal(value, &user); err != nil {
+				return err
+			}
+			if _, ok := user["name"]; !ok {
+				return errors.New("missing name")
+			}
+			return nil
+		},
+	}
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// An unknown schema is rejected.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), SchemaMetadataKey, "order")
+	_, err = apiClient.CreateStream(ctx, &proto.CreateStreamRequest{Subject: "order", Name: "order"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	ctx = grpcMetadata.AppendToOutgoingContext(context.Background(), SchemaMetadataKey, "user")
+	_, err = apiClient.CreateStream(ctx, &proto.CreateStreamRequest{Subject: "user", Name: "user"})
+	require.NoError(t, err)
+	waitForPartition(t, 5*time.Second, "user", 0, s1)
+
+	publish := func(value string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := apiClient.Publish(ctx, &proto.PublishRequest{
+			Stream:    "user",
+			Value:     []byte(value),
+			AckPolicy: proto.AckPolicy_LEADER,
+		})
+		return err
+	}
+
+	err = publish("not json")
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = publish(`{"id": 1}`)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), "missing name")
+
+	// Messages published directly to NATS are validated by the leader.
+	rejection := publishRejected(t, "user", &proto.Message{Value: []byte(`{"id": 1}`)})
+	require.Equal(t, uint32(codes.InvalidArgument), rejection.Code)
+	require.Contains(t, rejection.Reason, "missing name")
+
+	require.NoError(t, publish(`{"name": "alice"}`))
+
+	// Only the conforming message was stored.
+	partition := s1.metadata.GetPartition("user", 0)
+	require.Equal(t, int64(0), partition.log.NewestOffset())
+}
+
+// Ensure a subscription with a max rate paces delivery.
+func TestSubscribeMaxRate(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name)
+	require.NoError(t, err)
+
+	// Publish some messages.
+	num := 11
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+	req := &proto.SubscribeRequest{
+		Stream:        name,
+		StartPosition: proto.StartPosition_EARLIEST,
+	}
+
+	// An invalid rate is rejected.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), MaxRateMetadataKey, "0")
+	stream, err := apiClient.Subscribe(ctx, req)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = grpcMetadata.AppendToOutgoingContext(ctx, MaxRateMetadataKey, "10")
+	stream, err = apiClient.Subscribe(ctx, req)
+	require.NoError(t, err)
+	// The first message received indicates the subscription was created.
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	// The first message is delivered right away, followed by one every
+	// 100ms.
+	start := time.Now()
+	for i := 0; i < num; i++ {
+		msg, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, int64(i), msg.Offset)
+	}
+	elapsed := time.Since(start)
+	require.True(t, elapsed >= 900*time.Millisecond, "delivered %d messages in %s", num, elapsed)
+	require.True(t, elapsed < 3*time.Second, "delivered %d messages in %s", num, elapsed)
+}
+
+// Ensure a subscription with an in-flight byte budget receives large messages,
+// including ones larger than the budget, and rejects an invalid budget.
+func TestSubscribeMaxInflightBytes(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name)
+	require.NoError(t, err)
+
+	// Publish some large messages, the last one larger than the budget.
+	var (
+		budget = 256 * 1024
+		sizes  = []int{100 * 1024, 100 * 1024, 100 * 1024, 100 * 1024, 300 * 1024}
+	)
+	for i, size := range sizes {
+		value := bytes.Repeat([]byte{byte(i)}, size)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, value, lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+	req := &proto.SubscribeRequest{
+		Stream:        name,
+		StartPosition: proto.StartPosition_EARLIEST,
+	}
+
+	// An invalid budget is rejected.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), MaxInflightBytesMetadataKey, "-1")
+	stream, err := apiClient.Subscribe(ctx, req)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = grpcMetadata.AppendToOutgoingContext(ctx, MaxInflightBytesMetadataKey, strconv.Itoa(budget))
+	stream, err = apiClient.Subscribe(ctx, req)
+	require.NoError(t, err)
+	// The first message received indicates the subscription was created.
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	for i, size := range sizes {
+		// Consume slowly so delivery is paused on the budget.
+		time.Sleep(10 * time.Millisecond)
+		msg, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, int64(i), msg.Offset)
+		require.Equal(t, bytes.Repeat([]byte{byte(i)}, size), msg.Value)
+	}
+}
+
+// Ensure a subscription reading uncommitted messages receives messages past
+// the HW on the leader, flagged as uncommitted, while a regular subscription
+// only receives committed messages.
+func TestSubscribeReadUncommitted(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name)
+	require.NoError(t, err)
+
+	// Publish a committed message.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.Publish(ctx, name, []byte("committed"), lift.AckPolicyAll())
+	require.NoError(t, err)
+
+	// Append a message to the leader's log without committing it.
+	partition := s1.metadata.GetPartition(name, 0)
+	_, err = partition.log.Append([]*commitlog.Message{{Value: []byte("uncommitted")}})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), partition.log.HighWatermark())
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+	req := &proto.SubscribeRequest{
+		Stream:        name,
+		StartPosition: proto.StartPosition_EARLIEST,
+	}
+
+	subscribe := func(ctx context.Context) proto.API_SubscribeClient {
+		stream, err := apiClient.Subscribe(ctx, req)
+		require.NoError(t, err)
+		// The first message received indicates the subscription was created.
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		return stream
+	}
+
+	sctx, scancel := context.WithCancel(context.Background())
+	defer scancel()
+	stream := subscribe(grpcMetadata.AppendToOutgoingContext(sctx, ReadUncommittedMetadataKey, "true"))
+
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), msg.Offset)
+	require.Equal(t, []byte("committed"), msg.Value)
+	require.NotContains(t, msg.Headers, UncommittedHeader)
+
+	msg, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), msg.Offset)
+	require.Equal(t, []byte("uncommitted"), msg.Value)
+	require.Equal(t, []byte("true"), msg.Headers[UncommittedHeader])
+
+	// A regular subscription doesn't receive the uncommitted message.
+	rctx, rcancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer rcancel()
+	stream = subscribe(rctx)
+	msg, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), msg.Offset)
+	_, err = stream.Recv()
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+// Ensure a Subscribe with ConsistentReadMetadataKey confirms the partition
+// leader with the metadata leader and sees messages committed before it.
+func TestSubscribeConsistentRead(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	metadataLeader := getMetadataLeader(t, 10*time.Second, s1, s2)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name, lift.ReplicationFactor(2))
+	require.NoError(t, err)
+	waitForPartition(t, 5*time.Second, name, 0, s1, s2)
+	waitForISR(t, 10*time.Second, name, 0, 2, s1, s2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.Publish(ctx, name, []byte("hello"), lift.AckPolicyAll())
+	require.NoError(t, err)
+
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, s1, s2)
+	conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", leader.config.Port), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+	req := &proto.SubscribeRequest{
+		Stream:        name,
+		StartPosition: proto.StartPosition_EARLIEST,
+	}
+
+	// The message committed before subscribing is delivered right away.
+	sctx, scancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer scancel()
+	stream, err := apiClient.Subscribe(
+		grpcMetadata.AppendToOutgoingContext(sctx, ConsistentReadMetadataKey, "true"), req)
+	require.NoError(t, err)
+	// The first message received indicates the subscription was created.
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), msg.Offset)
+	require.Equal(t, []byte("hello"), msg.Value)
+
+	// Consistent read can't be combined with read uncommitted.
+	stream, err = apiClient.Subscribe(grpcMetadata.AppendToOutgoingContext(sctx,
+		ConsistentReadMetadataKey, "true", ReadUncommittedMetadataKey, "true"), req)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// A stale leader epoch is rejected, including when the confirmation is
+	// forwarded to the metadata leader.
+	follower := s1
+	if metadataLeader == s1 {
+		follower = s2
+	}
+	partition := follower.metadata.GetPartition(name, 0)
+	require.NotNil(t, partition)
+	partitionLeader, epoch := partition.GetLeader()
+	st := follower.metadata.ConfirmLeader(context.Background(), &internal.ConfirmLeaderOp{
+		Stream:      name,
+		Partition:   0,
+		Leader:      partitionLeader,
+		LeaderEpoch: epoch,
+	})
+	require.Nil(t, st)
+	st = follower.metadata.ConfirmLeader(context.Background(), &internal.ConfirmLeaderOp{
+		Stream:      name,
+		Partition:   0,
+		Leader:      partitionLeader,
+		LeaderEpoch: epoch + 1,
+	})
+	require.NotNil(t, st)
+	require.Equal(t, codes.FailedPrecondition, st.Code())
+}
+
+// Ensure API errors carry the documented gRPC status code and ErrorCode.
+func TestErrorCodes(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Clustering.MinISR = 2
+	s1Config.Streams.PausedRejectSubscriptions = true
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Clustering.MinISR = 2
+	s2Config.Streams.PausedRejectSubscriptions = true
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	servers := []*Server{s1, s2}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	apiClients := make(map[*Server]proto.APIClient, len(servers))
+	for _, s := range servers {
+		conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", s.config.Port), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+		apiClients[s] = proto.NewAPIClient(conn)
+	}
+	api := apiClients[s1]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := api.CreateStream(ctx, &proto.CreateStreamRequest{
+		Subject: "foo", Name: "foo", ReplicationFactor: 2})
+	require.NoError(t, err)
+	_, err = api.CreateStream(ctx, &proto.CreateStreamRequest{
+		Subject: "bar", Name: "bar", ReplicationFactor: 2})
+	require.NoError(t, err)
+	_, err = api.CreateStream(ctx, &proto.CreateStreamRequest{
+		Subject: "single", Name: "single", ReplicationFactor: 1})
+	require.NoError(t, err)
+
+	leader := getPartitionLeader(t, 10*time.Second, "foo", 0, servers...)
+	follower := s1
+	if leader == s1 {
+		follower = s2
+	}
+	waitForISR(t, 10*time.Second, "foo", 0, 2, servers...)
+	waitForPartition(t, 10*time.Second, "bar", 0, servers...)
+	_, err = api.PauseStream(ctx, &proto.PauseStreamRequest{Name: "bar"})
+	require.NoError(t, err)
+
+	subscribe := func(s *Server, req *proto.SubscribeRequest, md ...string) error {
+		subCtx := grpcMetadata.AppendToOutgoingContext(ctx, md...)
+		stream, err := apiClients[s].Subscribe(subCtx, req)
+		if err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+
+	tests := []struct {
+		name    string
+		err     func() error
+		code    codes.Code
+		errCode ErrorCode
+	}{
+		{"CreateStream empty name", func() error {
+			_, err := api.CreateStream(ctx, &proto.CreateStreamRequest{Subject: "baz"})
+			return err
+		}, codes.InvalidArgument, ErrorCodeInvalidArgument},
+		{"CreateStream existing stream", func() error {
+			_, err := api.CreateStream(ctx, &proto.CreateStreamRequest{Subject: "foo", Name: "foo"})
+			return err
+		}, codes.AlreadyExists, ErrorCodeStreamExists},
+		{"DeleteStream missing stream", func() error {
+			_, err := api.DeleteStream(ctx, &proto.DeleteStreamRequest{Name: "missing"})
+			return err
+		}, codes.NotFound, ErrorCodeStreamNotFound},
+		{"Publish missing stream", func() error {
+			_, err := api.Publish(ctx, &proto.PublishRequest{Stream: "missing"})
+			return err
+		}, codes.NotFound, ErrorCodeStreamNotFound},
+		{"Publish missing partition", func() error {
+			_, err := api.Publish(ctx, &proto.PublishRequest{Stream: "foo", Partition: 5})
+			return err
+		}, codes.NotFound, ErrorCodePartitionNotFound},
+		{"Publish below minimum ISR", func() error {
+			_, err := api.Publish(ctx, &proto.PublishRequest{
+				Stream: "single", AckPolicy: proto.AckPolicy_ALL})
+			return err
+		}, codes.FailedPrecondition, ErrorCodeInsufficientISR},
+		{"Subscribe missing partition", func() error {
+			return subscribe(leader, &proto.SubscribeRequest{Stream: "foo", Partition: 5})
+		}, codes.NotFound, ErrorCodePartitionNotFound},
+		{"Subscribe to follower", func() error {
+			return subscribe(follower, &proto.SubscribeRequest{Stream: "foo"})
+		}, codes.FailedPrecondition, ErrorCodeNotLeader},
+		{"Subscribe past end of log", func() error {
+			return subscribe(leader, &proto.SubscribeRequest{
+				Stream:        "foo",
+				StartPosition: proto.StartPosition_OFFSET,
+				StartOffset:   10,
+			}, OffsetOutOfRangeMetadataKey, "error")
+		}, codes.OutOfRange, ErrorCodeOffsetOutOfRange},
+		{"Subscribe to paused partition", func() error {
+			barLeader := getPartitionLeader(t, 10*time.Second, "bar", 0, servers...)
+			return subscribe(barLeader, &proto.SubscribeRequest{Stream: "bar"})
+		}, codes.FailedPrecondition, ErrorCodePaused},
+	}
+	for _, test := range tests {
+		err := test.err()
+		require.Error(t, err, test.name)
+		require.Equal(t, test.code, status.Code(err), test.name)
+		require.Equal(t, test.errCode, GetErrorCode(err), test.name)
+	}
+
+	// Errors which aren't from the API have no ErrorCode.
+	require.Equal(t, ErrorCode(""), GetErrorCode(errors.New("foo")))
+}
+
+// Ensure publishes fail with ResourceExhausted rather than timing out once a
+// stream's log reaches the byte quota set when it was created and the quota
+// policy is reject.
+func TestPublishQuotaExceeded(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Invalid quotas are rejected.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		QuotaMaxBytesMetadataKey, "0")
+	err = client.CreateStream(ctx, "foo", "foo")
+	require.Error(t, err)
+
+	ctx = grpcMetadata.AppendToOutgoingContext(context.Background(),
+		QuotaMaxBytesMetadataKey, "256")
+	err = client.CreateStream(ctx, "foo", "foo")
+	require.NoError(t, err)
+
+	config, err := s1.GetStreamConfig("foo")
+	require.NoError(t, err)
+	require.Equal(t, int64(256), config.QuotaMaxBytes)
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// Publish until the log is full.
+	var published int
+	for ; published < 100; published++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = apiClient.Publish(ctx, &proto.PublishRequest{
+			Stream:    "foo",
+			Value:     []byte("hello"),
+			AckPolicy: proto.AckPolicy_LEADER,
+		})
+		cancel()
+		if err != nil {
+			break
+		}
+	}
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Greater(t, published, 0)
+
+	// The rejected message was not written.
+	require.Equal(t, int64(published-1), s1.metadata.GetPartition("foo", 0).log.NewestOffset())
+}
+
+// Ensure FetchMetadata caches the broker list for a short time when a server
+// in the cluster does not respond so that every call doesn't wait on the
+// survey.
+func TestFetchMetadataCachePartialBrokers(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Configure third server.
+	s3Config := getTestConfig("c", false, 5052)
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := []*Server{s1, s2, s3}
+	leader := getMetadataLeader(t, 10*time.Second, servers...)
+
+	// Stop a follower. It remains in the Raft configuration.
+	var (
+		stopped *Server
+		running *Server
+	)
+	for _, s := range servers {
+		if s == leader {
+			continue
+		}
+		if stopped == nil {
+			stopped = s
+		} else {
+			running = s
+		}
+	}
+
+	// Wait for the remaining follower to learn the cluster configuration.
+	waitForClusterSize(t, 10*time.Second, len(servers), running)
+	stopped.Stop()
+
+	client, err := lift.Connect([]string{fmt.Sprintf("localhost:%d", running.config.Port)})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// The first call waits on the survey.
+	metadata, err := client.FetchMetadata(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metadata.Brokers(), 2)
+
+	// The partial broker list is cached.
+	start := time.Now()
+	metadata, err = client.FetchMetadata(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metadata.Brokers(), 2)
+	require.True(t, time.Since(start) < time.Second)
+}
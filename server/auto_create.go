@@ -0,0 +1,263 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	client "github.com/liftbridge-io/liftbridge-api/go"
+)
+
+// autoCreatePlaceholder matches the wildcard token placeholders in an
+// AutoCreateRule name template, e.g. "{1}".
+var autoCreatePlaceholder = regexp.MustCompile(`\{(\d+)\}`)
+
+// subjectWildcards returns the tokens of the subject which match each
+// wildcard in the pattern and a bool indicating if the subject matches the
+// pattern at all. A full wildcard captures the remaining tokens joined by
+// dots.
+func subjectWildcards(pattern, subject string) ([]string, bool) {
+	var (
+		patternTokens = strings.Split(pattern, ".")
+		subjectTokens = strings.Split(subject, ".")
+		wildcards     []string
+	)
+	for i, token := range patternTokens {
+		if token == ">" {
+			if i >= len(subjectTokens) {
+				return nil, false
+			}
+			return append(wildcards, strings.Join(subjectTokens[i:], ".")), true
+		}
+		if i >= len(subjectTokens) {
+			return nil, false
+		}
+		switch token {
+		case "*":
+			wildcards = append(wildcards, subjectTokens[i])
+		case subjectTokens[i]:
+		default:
+			return nil, false
+		}
+	}
+	return wildcards, len(patternTokens) == len(subjectTokens)
+}
+
+// validateAutoCreateName returns an error if the rule's name template refers
+// to a wildcard which is not in its subject pattern or if the rule creates
+// partitioned streams attached to a subject ending in a full wildcard, which
+// can't have partition subjects.
+func validateAutoCreateName(rule AutoCreateRule) error {
+	var (
+		tokens     = strings.Split(rule.Subject, ".")
+		referenced = rule.referencedWildcards()
+		wildcards  = 0
+	)
+	for _, token := range tokens {
+		if token == "*" || token == ">" {
+			wildcards++
+		}
+	}
+	for n := range referenced {
+		if n < 1 || n > wildcards {
+			return fmt.Errorf("Stream auto-create rule name %q refers to wildcard %d, "+
+				"but subject %q has %d wildcards", rule.Name, n, rule.Subject, wildcards)
+		}
+	}
+	// A full wildcard is always the last one.
+	_, fullReferenced := referenced[wildcards]
+	if rule.Partitions > 1 && tokens[len(tokens)-1] == ">" && !fullReferenced &&
+		rule.Name != "" && !strings.Contains(rule.Name, "{subject}") {
+		return fmt.Errorf("Stream auto-create rule with subject %q and name %q can't "+
+			"create partitioned streams since they would be attached to a subject "+
+			"ending in a full wildcard", rule.Subject, rule.Name)
+	}
+	return nil
+}
+
+// referencedWildcards returns the numbers of the wildcards the rule's name
+// template refers to.
+func (r AutoCreateRule) referencedWildcards() map[int]struct{} {
+	referenced := make(map[int]struct{})
+	for _, match := range autoCreatePlaceholder.FindAllStringSubmatch(r.Name, -1) {
+		n, _ := strconv.Atoi(match[1])
+		referenced[n] = struct{}{}
+	}
+	return referenced
+}
+
+// streamSubject returns the subject to attach the stream created for the given
+// subject to. Wildcards which the name template doesn't refer to are kept so
+// that the stream captures every subject mapping to its name rather than only
+// the first one seen. If the name template refers to the whole subject, each
+// subject gets its own stream, so it's attached to the subject itself.
+func (r AutoCreateRule) streamSubject(subject string) string {
+	if r.Name == "" || strings.Contains(r.Name, "{subject}") {
+		return subject
+	}
+	var (
+		referenced   = r.referencedWildcards()
+		wildcards, _ = subjectWildcards(r.Subject, subject)
+		tokens       = strings.Split(r.Subject, ".")
+		n            int
+	)
+	for i, token := range tokens {
+		if token != "*" && token != ">" {
+			continue
+		}
+		n++
+		if _, ok := referenced[n]; ok && n <= len(wildcards) {
+			tokens[i] = wildcards[n-1]
+		}
+	}
+	return strings.Join(tokens, ".")
+}
+
+// streamName returns the name of the stream to create for the given subject,
+// which must match the rule's subject pattern.
+func (r AutoCreateRule) streamName(subject string) string {
+	if r.Name == "" {
+		return subject
+	}
+	wildcards, _ := subjectWildcards(r.Subject, subject)
+	name := strings.Replace(r.Name, "{subject}", subject, -1)
+	return autoCreatePlaceholder.ReplaceAllStringFunc(name, func(placeholder string) string {
+		n, _ := strconv.Atoi(placeholder[1 : len(placeholder)-1])
+		if n < 1 || n > len(wildcards) {
+			return placeholder
+		}
+		return wildcards[n-1]
+	})
+}
+
+// streamAutoCreator creates streams for NATS subjects matching the configured
+// AutoCreateRules. It only runs on the metadata leader so that a single
+// server decides which streams to create and enforces AutoCreateMax.
+type streamAutoCreator struct {
+	srv     *Server
+	mu      sync.Mutex
+	subs    []*nats.Subscription
+	pending map[string]struct{}
+	limited bool
+}
+
+func newStreamAutoCreator(s *Server) *streamAutoCreator {
+	return &streamAutoCreator{srv: s, pending: make(map[string]struct{})}
+}
+
+// Start subscribes to the subject pattern of each rule.
+func (a *streamAutoCreator) Start() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, rule := range a.srv.config.Streams.AutoCreateRules {
+		rule := rule
+		sub, err := a.srv.nc.Subscribe(rule.Subject, func(msg *nats.Msg) {
+			a.handleMessage(rule, msg.Subject)
+		})
+		if err != nil {
+			a.stop()
+			return err
+		}
+		a.subs = append(a.subs, sub)
+	}
+	return nil
+}
+
+// Stop unsubscribes from the rules' subject patterns.
+func (a *streamAutoCreator) Stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stop()
+}
+
+func (a *streamAutoCreator) stop() {
+	for _, sub := range a.subs {
+		sub.Unsubscribe() // nolint: errcheck
+	}
+	a.subs = nil
+	a.limited = false
+}
+
+// isInternalSubject indicates if the subject is used internally by Liftbridge
+// or NATS and should never get a stream automatically.
+func (a *streamAutoCreator) isInternalSubject(subject string) bool {
+	return strings.HasPrefix(subject, a.srv.config.Clustering.Namespace+".") ||
+		strings.HasPrefix(subject, "_INBOX.")
+}
+
+// handleMessage creates a stream for the subject, using the given rule, if
+// one does not already exist.
+func (a *streamAutoCreator) handleMessage(rule AutoCreateRule, subject string) {
+	if a.isInternalSubject(subject) {
+		return
+	}
+	name := rule.streamName(subject)
+	if a.srv.metadata.GetStream(name) != nil {
+		return
+	}
+
+	a.mu.Lock()
+	if _, ok := a.pending[name]; ok || a.subs == nil {
+		a.mu.Unlock()
+		return
+	}
+	if a.autoCreatedStreams()+len(a.pending) >= a.srv.config.Streams.AutoCreateMax {
+		if !a.limited {
+			a.srv.logger.Warnf("Not automatically creating stream %s for subject %s, "+
+				"limit of %d auto-created streams reached", name, subject,
+				a.srv.config.Streams.AutoCreateMax)
+			a.limited = true
+		}
+		a.mu.Unlock()
+		return
+	}
+	a.pending[name] = struct{}{}
+	a.mu.Unlock()
+
+	streamSubject := rule.streamSubject(subject)
+	a.srv.startGoroutine(func() {
+		defer func() {
+			a.mu.Lock()
+			delete(a.pending, name)
+			a.mu.Unlock()
+		}()
+		api := &apiServer{a.srv}
+		_, err := api.CreateStream(context.Background(), &client.CreateStreamRequest{
+			Subject:           streamSubject,
+			Name:              name,
+			Partitions:        rule.Partitions,
+			ReplicationFactor: rule.ReplicationFactor,
+		})
+		if err != nil && status.Code(err) != codes.AlreadyExists {
+			a.srv.logger.Errorf("Failed to automatically create stream %s for subject %s: %v",
+				name, streamSubject, err)
+			return
+		}
+		if err == nil {
+			a.srv.logger.Infof("Automatically created stream %s for subject %s", name, streamSubject)
+		}
+	})
+}
+
+// autoCreatedStreams returns the number of existing streams attached to a
+// subject matching one of the auto-create rules. This is derived from the
+// metadata so that the limit holds across metadata leader changes.
+func (a *streamAutoCreator) autoCreatedStreams() int {
+	count := 0
+	for _, stream := range a.srv.metadata.GetStreams() {
+		for _, rule := range a.srv.config.Streams.AutoCreateRules {
+			if _, ok := subjectWildcards(rule.Subject, stream.GetSubject()); ok {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
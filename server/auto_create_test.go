@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+// Ensure subjects are matched against auto-create patterns and wildcard tokens
+// are captured.
+func TestSubjectWildcards(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		subject   string
+		wildcards []string
+		match     bool
+	}{
+		{"foo", "foo", nil, true},
+		{"foo", "bar", nil, false},
+		{"foo.*", "foo.bar", []string{"bar"}, true},
+		{"foo.*", "foo.bar.baz", nil, false},
+		{"foo.*.baz", "foo.bar.baz", []string{"bar"}, true},
+		{"foo.*.baz", "foo.bar.qux", nil, false},
+		{"foo.>", "foo.bar.baz", []string{"bar.baz"}, true},
+		{"foo.>", "foo", nil, false},
+		{"*.bar.>", "foo.bar.baz.qux", []string{"foo", "baz.qux"}, true},
+	}
+	for _, test := range tests {
+		wildcards, match := subjectWildcards(test.pattern, test.subject)
+		require.Equal(t, test.match, match, "%s %s", test.pattern, test.subject)
+		if test.match {
+			require.Equal(t, test.wildcards, wildcards, "%s %s", test.pattern, test.subject)
+		}
+	}
+}
+
+// Ensure stream names are derived from the rule's name template.
+func TestAutoCreateRuleStreamName(t *testing.T) {
+	rule := AutoCreateRule{Subject: "events.*.>"}
+	require.Equal(t, "events.a.b.c", rule.streamName("events.a.b.c"))
+
+	rule.Name = "events-{1}"
+	require.Equal(t, "events-a", rule.streamName("events.a.b.c"))
+
+	rule.Name = "{2}-{1}-{subject}"
+	require.Equal(t, "b.c-a-events.a.b.c", rule.streamName("events.a.b.c"))
+
+	require.NoError(t, validateAutoCreateName(rule))
+	rule.Name = "events-{3}"
+	require.Error(t, validateAutoCreateName(rule))
+	rule.Name = "events-{0}"
+	require.Error(t, validateAutoCreateName(rule))
+}
+
+// Ensure streams are attached to the rule's subject pattern with the wildcards
+// their name refers to filled in.
+func TestAutoCreateRuleStreamSubject(t *testing.T) {
+	rule := AutoCreateRule{Subject: "events.*.>"}
+	require.Equal(t, "events.a.b.c", rule.streamSubject("events.a.b.c"))
+
+	rule.Name = "{subject}"
+	require.Equal(t, "events.a.b.c", rule.streamSubject("events.a.b.c"))
+
+	rule.Name = "events-{1}"
+	require.Equal(t, "events.a.>", rule.streamSubject("events.a.b.c"))
+
+	rule.Name = "events-{2}"
+	require.Equal(t, "events.*.b.c", rule.streamSubject("events.a.b.c"))
+
+	rule.Name = "events"
+	require.Equal(t, "events.*.>", rule.streamSubject("events.a.b.c"))
+
+	// Partitioned streams can't be attached to a subject ending in a full
+	// wildcard.
+	rule.Partitions = 2
+	require.Error(t, validateAutoCreateName(rule))
+	rule.Name = "events-{2}"
+	require.NoError(t, validateAutoCreateName(rule))
+}
+
+func waitForStream(t *testing.T, timeout time.Duration, name string, s *Server) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if s.metadata.GetStream(name) != nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stackFatalf(t, "Stream %s was not created", name)
+}
+
+// Ensure streams are automatically created only for subjects matching an
+// auto-create rule and only up to the configured limit.
+func TestAutoCreateStreams(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.AutoCreateMax = 2
+	s1Config.Streams.AutoCreateRules = []AutoCreateRule{
+		{Subject: "events.*.>", Name: "events-{1}"},
+	}
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	nc, err := nats.Connect(nats.DefaultURL)
+	require.NoError(t, err)
+	defer nc.Close()
+
+	// Publishing to a matching subject creates a stream.
+	require.NoError(t, nc.Publish("events.a.created", []byte("hello")))
+	require.NoError(t, nc.Flush())
+	waitForStream(t, 5*time.Second, "events-a", s1)
+	require.Equal(t, "events.a.>", s1.metadata.GetStream("events-a").GetSubject())
+
+	// The stream captures other subjects mapping to it.
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+	getPartitionLeader(t, 10*time.Second, "events-a", 0, s1)
+	require.NoError(t, nc.Publish("events.a.updated", []byte("hello")))
+	require.NoError(t, nc.Flush())
+	msgs := make(chan lift.Message, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = client.Subscribe(ctx, "events-a", func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		msgs <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	select {
+	case msg := <-msgs:
+		require.Equal(t, "events.a.updated", msg.Subject())
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not receive message")
+	}
+	require.Len(t, s1.metadata.GetStreams(), 1)
+
+	// Publishing to non-matching subjects does not create a stream.
+	require.NoError(t, nc.Publish("events.b", []byte("hello")))
+	require.NoError(t, nc.Publish("other.b.created", []byte("hello")))
+	require.NoError(t, nc.Flush())
+
+	// Another matching subject creates a second stream.
+	require.NoError(t, nc.Publish("events.c.created", []byte("hello")))
+	require.NoError(t, nc.Flush())
+	waitForStream(t, 5*time.Second, "events-c", s1)
+
+	// The limit has been reached, so no more streams are created.
+	require.NoError(t, nc.Publish("events.d.created", []byte("hello")))
+	require.NoError(t, nc.Flush())
+	time.Sleep(500 * time.Millisecond)
+
+	require.Nil(t, s1.metadata.GetStream("events-d"))
+	require.Nil(t, s1.metadata.GetStream("events.b"))
+	require.Nil(t, s1.metadata.GetStream("other.b.created"))
+	require.Len(t, s1.metadata.GetStreams(), 2)
+}
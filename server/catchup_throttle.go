@@ -0,0 +1,106 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// catchUpThrottle limits the aggregate bandwidth a leader spends replicating
+// data to followers which are catching up, i.e. followers outside of the ISR.
+// It's a token bucket shared by all replicators on the server. Followers
+// waiting on the bucket are served in the order they started waiting, so a
+// follower far behind the leader is not starved by followers with less to
+// catch up. A nil catchUpThrottle does not throttle.
+type catchUpThrottle struct {
+	mu      sync.Mutex
+	rate    float64 // bytes per second
+	tokens  float64
+	last    time.Time
+	waiters []*catchUpWaiter // In arrival order
+}
+
+// catchUpWaiter is a follower waiting on the catch-up throttle.
+type catchUpWaiter struct {
+	size int // Bytes to send
+}
+
+// newCatchUpThrottle returns a catchUpThrottle limiting catch-up replication
+// to the given number of bytes per second. If bytesPerSec is not positive,
+// nil is returned, which disables throttling.
+func newCatchUpThrottle(bytesPerSec int64) *catchUpThrottle {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &catchUpThrottle{
+		rate:   float64(bytesPerSec),
+		tokens: float64(bytesPerSec),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until size bytes may be sent to a follower. It returns false if
+// the stop channel was closed or maxWait passed before the bytes could be
+// sent, in which case the bytes must not be sent.
+func (c *catchUpThrottle) wait(stop <-chan struct{}, size int, maxWait time.Duration) bool {
+	if c == nil {
+		return true
+	}
+
+	w := &catchUpWaiter{size: size}
+	c.mu.Lock()
+	c.waiters = append(c.waiters, w)
+	c.mu.Unlock()
+
+	deadline := time.NewTimer(maxWait)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		c.refill(time.Now())
+		// The bucket is allowed to go negative so that batches larger than
+		// the rate can still be sent. Subsequent waiters make up the deficit.
+		if c.tokens > 0 && c.waiters[0] == w {
+			c.tokens -= float64(w.size)
+			c.remove(w)
+			c.mu.Unlock()
+			return true
+		}
+		delay := time.Millisecond
+		if c.tokens <= 0 {
+			if d := time.Duration(-c.tokens / c.rate * float64(time.Second)); d > delay {
+				delay = d
+			}
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-stop:
+		case <-deadline.C:
+		case <-time.After(delay):
+			continue
+		}
+		c.mu.Lock()
+		c.remove(w)
+		c.mu.Unlock()
+		return false
+	}
+}
+
+// refill adds tokens accrued since the last refill, up to one second's worth.
+// This must be called with the lock held.
+func (c *catchUpThrottle) refill(now time.Time) {
+	c.tokens += now.Sub(c.last).Seconds() * c.rate
+	if c.tokens > c.rate {
+		c.tokens = c.rate
+	}
+	c.last = now
+}
+
+// remove removes the given waiter. This must be called with the lock held.
+func (c *catchUpThrottle) remove(w *catchUpWaiter) {
+	for i, waiter := range c.waiters {
+		if waiter == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Ensure a nil catchUpThrottle does not throttle.
+func TestCatchUpThrottleDisabled(t *testing.T) {
+	throttle := newCatchUpThrottle(0)
+	require.Nil(t, throttle)
+	require.True(t, throttle.wait(nil, 1024*1024, time.Millisecond))
+}
+
+// Ensure several lagging followers catching up concurrently respect the
+// aggregate bandwidth cap.
+func TestCatchUpThrottleRespectsCap(t *testing.T) {
+	var (
+		rate      = int64(1024 * 1024)
+		batch     = 64 * 1024
+		batches   = 8
+		followers = 4
+		throttle  = newCatchUpThrottle(rate)
+		stop      = make(chan struct{})
+		wg        sync.WaitGroup
+	)
+	defer close(stop)
+
+	start := time.Now()
+	for i := 0; i < followers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < batches; j++ {
+				require.True(t, throttle.wait(stop, batch, time.Minute))
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// The bucket starts with one second's worth of bytes, and the last batch
+	// is allowed through once the bucket is no longer in deficit.
+	total := int64(batch * batches * followers)
+	minElapsed := time.Duration(float64(total-rate-int64(batch)) / float64(rate) * float64(time.Second))
+	require.True(t, elapsed >= minElapsed,
+		"sent %d bytes in %s, expected at least %s", total, elapsed, minElapsed)
+}
+
+// Ensure followers are served in the order they started waiting when the
+// bandwidth cap is exhausted.
+func TestCatchUpThrottleFIFO(t *testing.T) {
+	var (
+		rate     = int64(100 * 1024)
+		throttle = newCatchUpThrottle(rate)
+		stop     = make(chan struct{})
+		mu       sync.Mutex
+		order    []int
+		wg       sync.WaitGroup
+	)
+	defer close(stop)
+
+	// Exhaust the bucket so the following waiters have to queue.
+	require.True(t, throttle.wait(stop, int(rate+rate/5), time.Minute))
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			require.True(t, throttle.wait(stop, int(rate/5), time.Minute))
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		}(i)
+		// Ensure each waiter is queued before the next one.
+		time.Sleep(20 * time.Millisecond)
+	}
+	wg.Wait()
+
+	require.Equal(t, []int{0, 1, 2}, order)
+}
+
+// Ensure waiting on the throttle gives up once the max wait passes, leaving
+// the waiters behind it to be served.
+func TestCatchUpThrottleMaxWait(t *testing.T) {
+	rate := int64(1024)
+	throttle := newCatchUpThrottle(rate)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	// Put the bucket into a large deficit.
+	require.True(t, throttle.wait(stop, int(rate*100), time.Minute))
+
+	start := time.Now()
+	require.False(t, throttle.wait(stop, 1, 50*time.Millisecond))
+	elapsed := time.Since(start)
+	require.True(t, elapsed >= 50*time.Millisecond)
+	require.True(t, elapsed < time.Second, "waited %s", elapsed)
+	require.Empty(t, throttle.waiters)
+}
+
+// Ensure waiting on the throttle returns false when stopped.
+func TestCatchUpThrottleStop(t *testing.T) {
+	rate := int64(1024)
+	throttle := newCatchUpThrottle(rate)
+	stop := make(chan struct{})
+
+	// Put the bucket into a large deficit.
+	require.True(t, throttle.wait(stop, int(rate*100), time.Minute))
+
+	close(stop)
+	require.False(t, throttle.wait(stop, 1, time.Minute))
+	require.Empty(t, throttle.waiters)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	client "github.com/liftbridge-io/liftbridge-api/go"
+)
+
+// errCommitQueueDisposed is returned when operating on a commitQueue which has
+// been disposed.
+var errCommitQueueDisposed = errors.New("commit queue disposed")
+
+// commitQueue holds the acks for messages a partition leader has written but
+// not yet committed. Acks are added in offset order by the leader's message
+// processing loop, so when the HW advances, every satisfied ack is found with
+// a binary search and removed in a single step rather than checking each
+// pending entry.
+type commitQueue struct {
+	mu       sync.Mutex
+	pending  []*client.Ack
+	received []time.Time      // When each pending message was received, in offset order
+	quorums  []followerQuorum // Pending messages requiring followers, in offset order
+	ackAll   int64            // Pending messages published with AckPolicy_ALL
+	disposed bool
+}
+
+// followerQuorum is the number of followers which must have the message at
+// the given offset before it can be committed.
+type followerQuorum struct {
+	offset    int64
+	followers int
+}
+
+// newCommitQueue creates a commitQueue with room for the given number of
+// pending acks before it needs to grow.
+func newCommitQueue(hint int) *commitQueue {
+	return &commitQueue{pending: make([]*client.Ack, 0, hint)}
+}
+
+// Put adds an ack for a message pending commit which was received now. Acks
+// must be added in offset order. It returns an error if the queue has been
+// disposed.
+func (q *commitQueue) Put(ack *client.Ack) error {
+	return q.PutAt(ack, time.Now())
+}
+
+// PutAt adds an ack for a message pending commit which was received at the
+// given time. Acks must be added in offset order. It returns an error if the
+// queue has been disposed.
+func (q *commitQueue) PutAt(ack *client.Ack, received time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.disposed {
+		return errCommitQueueDisposed
+	}
+	q.pending = append(q.pending, ack)
+	q.received = append(q.received, received)
+	if ack.AckPolicy == client.AckPolicy_ALL {
+		q.ackAll++
+	}
+	return nil
+}
+
+// RequireFollowers prevents the pending message at the given offset, and every
+// message after it, from being committed until at least the given number of
+// followers, not counting the leader, have it. Requirements must be added in
+// offset order. It returns an error if the queue has been disposed.
+func (q *commitQueue) RequireFollowers(offset int64, followers int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.disposed {
+		return errCommitQueueDisposed
+	}
+	q.quorums = append(q.quorums, followerQuorum{offset: offset, followers: followers})
+	return nil
+}
+
+// CommitLimit returns the highest offset, no greater than the given offset,
+// through which pending messages can be committed when the given number of
+// followers have replicated them.
+func (q *commitQueue) CommitLimit(offset int64, followers int) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, quorum := range q.quorums {
+		if quorum.offset > offset {
+			break
+		}
+		if quorum.followers > followers {
+			return quorum.offset - 1
+		}
+	}
+	return offset
+}
+
+// TakeThrough removes and returns the acks for all pending messages with an
+// offset less than or equal to the given offset. It returns an error if the
+// queue has been disposed.
+func (q *commitQueue) TakeThrough(offset int64) ([]*client.Ack, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.disposed {
+		return nil, errCommitQueueDisposed
+	}
+	n := sort.Search(len(q.pending), func(i int) bool {
+		return q.pending[i].Offset > offset
+	})
+	if n == 0 {
+		return nil, nil
+	}
+	// Limit the capacity of the returned slice so that later appends to the
+	// queue cannot overwrite it.
+	committed := q.pending[:n:n]
+	q.pending = q.pending[n:]
+	q.received = q.received[n:]
+	for _, ack := range committed {
+		if ack.AckPolicy == client.AckPolicy_ALL {
+			q.ackAll--
+		}
+	}
+	m := sort.Search(len(q.quorums), func(i int) bool {
+		return q.quorums[i].offset > offset
+	})
+	q.quorums = q.quorums[m:]
+	return committed, nil
+}
+
+// Len returns the number of pending acks.
+func (q *commitQueue) Len() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int64(len(q.pending))
+}
+
+// AckAllLen returns the number of pending acks for messages published with
+// AckPolicy_ALL, i.e. publishers waiting on the message to be committed.
+func (q *commitQueue) AckAllLen() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.ackAll
+}
+
+// Latency returns how long the oldest pending message has waited since it was
+// received as of the given time, or zero if there are no pending messages.
+func (q *commitQueue) Latency(now time.Time) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.received) == 0 {
+		return 0
+	}
+	return now.Sub(q.received[0])
+}
+
+// Dispose releases the pending acks. Subsequent calls to Put and TakeThrough
+// return an error.
+func (q *commitQueue) Dispose() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.disposed = true
+	q.pending = nil
+	q.received = nil
+	q.quorums = nil
+	q.ackAll = 0
+}
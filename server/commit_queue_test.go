@@ -0,0 +1,163 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	client "github.com/liftbridge-io/liftbridge-api/go"
+)
+
+// Ensure TakeThrough removes and returns only the acks up to and including the
+// given offset, in order.
+func TestCommitQueueTakeThrough(t *testing.T) {
+	q := newCommitQueue(2)
+	for i := int64(0); i < 5; i++ {
+		require.NoError(t, q.Put(&client.Ack{Offset: i}))
+	}
+
+	committed, err := q.TakeThrough(-1)
+	require.NoError(t, err)
+	require.Empty(t, committed)
+	require.Equal(t, int64(5), q.Len())
+
+	committed, err = q.TakeThrough(2)
+	require.NoError(t, err)
+	require.Len(t, committed, 3)
+	for i, ack := range committed {
+		require.Equal(t, int64(i), ack.Offset)
+	}
+	require.Equal(t, int64(2), q.Len())
+
+	// Adding acks must not clobber previously returned acks.
+	require.NoError(t, q.Put(&client.Ack{Offset: 5}))
+	require.Equal(t, int64(2), committed[2].Offset)
+
+	committed, err = q.TakeThrough(10)
+	require.NoError(t, err)
+	require.Len(t, committed, 3)
+	require.Equal(t, int64(3), committed[0].Offset)
+	require.Equal(t, int64(5), committed[2].Offset)
+	require.Equal(t, int64(0), q.Len())
+}
+
+// Ensure CommitLimit holds back messages requiring more followers than have
+// replicated them, along with the messages after them, until the requirement
+// is met or the messages are taken.
+func TestCommitQueueCommitLimit(t *testing.T) {
+	q := newCommitQueue(5)
+	for i := int64(0); i < 5; i++ {
+		require.NoError(t, q.Put(&client.Ack{Offset: i}))
+	}
+	require.NoError(t, q.RequireFollowers(1, 1))
+	require.NoError(t, q.RequireFollowers(3, 2))
+
+	require.Equal(t, int64(4), q.CommitLimit(4, 2))
+	require.Equal(t, int64(2), q.CommitLimit(4, 1))
+	require.Equal(t, int64(0), q.CommitLimit(4, 0))
+	require.Equal(t, int64(0), q.CommitLimit(0, 0))
+
+	// Taking messages removes their requirements.
+	_, err := q.TakeThrough(1)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), q.CommitLimit(4, 0))
+	_, err = q.TakeThrough(3)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), q.CommitLimit(4, 0))
+}
+
+// Ensure AckAllLen only counts pending AckPolicy_ALL acks and drops them as
+// they are taken.
+func TestCommitQueueAckAllLen(t *testing.T) {
+	q := newCommitQueue(4)
+	require.NoError(t, q.Put(&client.Ack{Offset: 0, AckPolicy: client.AckPolicy_ALL}))
+	require.NoError(t, q.Put(&client.Ack{Offset: 1, AckPolicy: client.AckPolicy_LEADER}))
+	require.NoError(t, q.Put(&client.Ack{Offset: 2, AckPolicy: client.AckPolicy_ALL}))
+	require.NoError(t, q.Put(&client.Ack{Offset: 3, AckPolicy: client.AckPolicy_NONE}))
+	require.Equal(t, int64(2), q.AckAllLen())
+
+	_, err := q.TakeThrough(1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), q.AckAllLen())
+
+	q.Dispose()
+	require.Equal(t, int64(0), q.AckAllLen())
+}
+
+// Ensure Latency reports how long the oldest pending message has waited.
+func TestCommitQueueLatency(t *testing.T) {
+	q := newCommitQueue(4)
+	now := time.Now()
+	require.Equal(t, time.Duration(0), q.Latency(now))
+
+	require.NoError(t, q.PutAt(&client.Ack{Offset: 0}, now.Add(-3*time.Second)))
+	require.NoError(t, q.PutAt(&client.Ack{Offset: 1}, now.Add(-2*time.Second)))
+	require.NoError(t, q.PutAt(&client.Ack{Offset: 2}, now.Add(-time.Second)))
+	require.Equal(t, 3*time.Second, q.Latency(now))
+
+	_, err := q.TakeThrough(1)
+	require.NoError(t, err)
+	require.Equal(t, time.Second, q.Latency(now))
+
+	_, err = q.TakeThrough(2)
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), q.Latency(now))
+}
+
+// Ensure Put and TakeThrough return an error once the queue is disposed.
+func TestCommitQueueDispose(t *testing.T) {
+	q := newCommitQueue(2)
+	require.NoError(t, q.Put(&client.Ack{Offset: 0}))
+
+	q.Dispose()
+
+	require.Equal(t, errCommitQueueDisposed, q.Put(&client.Ack{Offset: 1}))
+	_, err := q.TakeThrough(1)
+	require.Equal(t, errCommitQueueDisposed, err)
+	require.Equal(t, int64(0), q.Len())
+}
+
+// Benchmark committing thousands of pending acks with a single large HW jump.
+func BenchmarkCommitQueueLargeHWJump(b *testing.B) {
+	const pending = 10000
+	acks := make([]*client.Ack, pending)
+	for i := range acks {
+		acks[i] = &client.Ack{Offset: int64(i), AckPolicy: client.AckPolicy_ALL}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		q := newCommitQueue(pending)
+		for _, ack := range acks {
+			q.Put(ack)
+		}
+		b.StartTimer()
+
+		committed, err := q.TakeThrough(pending - 1)
+		if err != nil || len(committed) != pending {
+			b.Fatalf("Expected %d committed acks, got %d: %v", pending, len(committed), err)
+		}
+	}
+}
+
+// Benchmark commit checks which advance the HW by a single message while
+// thousands of acks are pending.
+func BenchmarkCommitQueueSmallHWAdvance(b *testing.B) {
+	const pending = 10000
+	q := newCommitQueue(pending)
+	for i := 0; i < pending; i++ {
+		q.Put(&client.Ack{Offset: int64(i), AckPolicy: client.AckPolicy_ALL})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Keep the backlog constant by adding an ack for each one taken.
+		q.Put(&client.Ack{Offset: int64(pending + i), AckPolicy: client.AckPolicy_ALL})
+		committed, err := q.TakeThrough(int64(i))
+		if err != nil || len(committed) != 1 {
+			b.Fatalf("Expected 1 committed ack, got %d: %v", len(committed), err)
+		}
+	}
+}
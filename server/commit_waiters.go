@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errCommitWaitersFull is returned when a publish would exceed the max
+// commit waiters of its stream and CommitWaitersPolicy is CommitWaitersReject.
+var errCommitWaitersFull = errors.New("too many publishes waiting on commit")
+
+// CommitWaitersPolicy determines how an AckPolicy_ALL publish is handled when
+// its stream already has the max number of publishes waiting on commit.
+type CommitWaitersPolicy int
+
+const (
+	// CommitWaitersReject fails the publish immediately. This is the
+	// default.
+	CommitWaitersReject CommitWaitersPolicy = iota
+
+	// CommitWaitersBlock holds the publish until another publish to the
+	// stream stops waiting or the publish's deadline passes.
+	CommitWaitersBlock
+)
+
+// commitWaiters bounds the AckPolicy_ALL publishes a server has waiting on the
+// message to be committed for each stream. Each waiter holds an ack inbox
+// subscription until the ack arrives or the publish times out, so a partition
+// which is slow to commit can otherwise accumulate them without bound.
+type commitWaiters struct {
+	mu       sync.Mutex
+	waiters  map[string]int // Publishes waiting on commit keyed by stream
+	released chan struct{}  // Closed when a waiter is released
+}
+
+func newCommitWaiters() *commitWaiters {
+	return &commitWaiters{
+		waiters:  make(map[string]int),
+		released: make(chan struct{}),
+	}
+}
+
+// acquire adds a waiter for the given stream if it has fewer than max. If not,
+// errCommitWaitersFull is returned with CommitWaitersReject, while with
+// CommitWaitersBlock it blocks until a waiter is released, returning the
+// context error if the context is done first. A max of zero means no limit.
+// The returned function must be called once the publish stops waiting.
+func (w *commitWaiters) acquire(ctx context.Context, stream string, max int,
+	policy CommitWaitersPolicy) (func(), error) {
+
+	if max <= 0 {
+		return func() {}, nil
+	}
+	for {
+		w.mu.Lock()
+		if w.waiters[stream] < max {
+			w.waiters[stream]++
+			w.mu.Unlock()
+			return func() { w.release(stream) }, nil
+		}
+		released := w.released
+		w.mu.Unlock()
+		if policy == CommitWaitersReject {
+			return nil, errCommitWaitersFull
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release removes a waiter for the given stream, waking up blocked acquires.
+func (w *commitWaiters) release(stream string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.waiters[stream]--; w.waiters[stream] <= 0 {
+		delete(w.waiters, stream)
+	}
+	close(w.released)
+	w.released = make(chan struct{})
+}
+
+// count returns the number of publishes waiting on commit for the stream.
+func (w *commitWaiters) count(stream string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.waiters[stream]
+}
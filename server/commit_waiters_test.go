@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Ensure commitWaiters rejects waiters beyond the max with
+// CommitWaitersReject, counting each stream separately.
+func TestCommitWaitersReject(t *testing.T) {
+	waiters := newCommitWaiters()
+	done1, err := waiters.acquire(context.Background(), "foo", 2, CommitWaitersReject)
+	require.NoError(t, err)
+	_, err = waiters.acquire(context.Background(), "foo", 2, CommitWaitersReject)
+	require.NoError(t, err)
+	require.Equal(t, 2, waiters.count("foo"))
+
+	_, err = waiters.acquire(context.Background(), "foo", 2, CommitWaitersReject)
+	require.Equal(t, errCommitWaitersFull, err)
+
+	// Other streams have their own limit.
+	_, err = waiters.acquire(context.Background(), "bar", 2, CommitWaitersReject)
+	require.NoError(t, err)
+
+	// Releasing a waiter makes room for another.
+	done1()
+	require.Equal(t, 1, waiters.count("foo"))
+	_, err = waiters.acquire(context.Background(), "foo", 2, CommitWaitersReject)
+	require.NoError(t, err)
+
+	// A max of zero means no limit.
+	for i := 0; i < 10; i++ {
+		_, err = waiters.acquire(context.Background(), "foo", 0, CommitWaitersReject)
+		require.NoError(t, err)
+	}
+}
+
+// Ensure commitWaiters blocks waiters beyond the max with CommitWaitersBlock
+// until another is released or the context is done.
+func TestCommitWaitersBlock(t *testing.T) {
+	waiters := newCommitWaiters()
+	done, err := waiters.acquire(context.Background(), "foo", 1, CommitWaitersBlock)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = waiters.acquire(ctx, "foo", 1, CommitWaitersBlock)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		_, err := waiters.acquire(context.Background(), "foo", 1, CommitWaitersBlock)
+		require.NoError(t, err)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected acquire to block")
+	case <-time.After(50 * time.Millisecond):
+	}
+	done()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected acquire to unblock")
+	}
+	require.Equal(t, 1, waiters.count("foo"))
+}
@@ -22,6 +22,12 @@ import (
 // ErrSegmentNotFound is returned if the segment could not be found.
 var ErrSegmentNotFound = errors.New("segment not found")
 
+// Bounds for Options.WriteBufferSize when write buffering is enabled.
+const (
+	MinWriteBufferSize = 4096     // 4KiB
+	MaxWriteBufferSize = 67108864 // 64MiB
+)
+
 const (
 	logFileSuffix               = ".log"
 	indexFileSuffix             = ".index"
@@ -39,37 +45,83 @@ type commitLog struct {
 	compactCleaner   *compactCleaner
 	name             string
 	mu               sync.RWMutex
+	cleanMu          sync.Mutex
 	hw               int64
 	closed           chan struct{}
 	segments         []*segment
 	vActiveSegment   *segment
-	hwWaiters        map[contextReader]chan struct{}
+	hwWaiters        map[interface{}]chan struct{}
 	leaderEpochCache *leaderEpochCache
 	deleted          bool
+	readersMu        sync.Mutex
+	readers          map[*Reader]struct{}
+	syncStatsMu      sync.Mutex
+	syncStats        SyncStats
+	lastAppend       int64 // Unix nanoseconds of the last append, accessed atomically
+	lastCommit       int64 // Unix nanoseconds of the last HW advance, accessed atomically
+	rolledMu         sync.Mutex
+	rolled           []RolledSegment // Rolled segments pending the OnSegmentRoll hook
+	rolledCh         chan struct{}   // Signals the segment roll loop
+}
+
+// SyncStats contains counters for the fsyncs a commit log has performed,
+// e.g. to tell whether appends are disk-bound.
+type SyncStats struct {
+	Syncs        int64         // Number of segment fsyncs
+	SyncTime     time.Duration // Total time spent in segment fsyncs
+	MaxSyncTime  time.Duration // Longest single segment fsync
+	BytesFlushed int64         // Log bytes made durable by segment fsyncs
 }
 
 // Options contains settings for configuring a commitLog.
 type Options struct {
-	Name                 string        // commitLog name
-	Path                 string        // Path to log directory
-	MaxSegmentBytes      int64         // Max bytes a Segment can contain before creating a new one
-	MaxSegmentAge        time.Duration // Max time before a new log segment is rolled out.
-	MaxLogBytes          int64         // Retention by bytes
-	MaxLogMessages       int64         // Retention by messages
-	MaxLogAge            time.Duration // Retention by age
-	Compact              bool          // Run compaction on log clean
-	CompactMaxGoroutines int           // Max number of goroutines to use in a log compaction
-	CleanerInterval      time.Duration // Frequency to enforce retention policy
-	HWCheckpointInterval time.Duration // Frequency to checkpoint HW to disk
+	Name                 string              // commitLog name
+	Path                 string              // Path to log directory
+	MaxSegmentBytes      int64               // Max bytes a Segment can contain before creating a new one
+	MaxSegmentAge        time.Duration       // Max time before a new log segment is rolled out.
+	MaxLogBytes          int64               // Retention by bytes
+	MaxLogMessages       int64               // Retention by messages
+	MaxLogAge            time.Duration       // Retention by age
+	MaxSegments          int                 // Retention by number of segments
+	QuotaMaxBytes        int64               // Hard limit on log size in bytes enforced on append
+	QuotaPolicy          QuotaPolicy         // Behavior when an append would exceed QuotaMaxBytes
+	Compact              bool                // Run compaction on log clean
+	CompactMaxGoroutines int                 // Max number of goroutines to use in a log compaction
+	CompactMinDirtyRatio float64             // Min fraction of removable messages for a segment to be compacted
+	CompactWindowStart   time.Duration       // Start of daily UTC window compaction may run in, offset from midnight
+	CompactWindowEnd     time.Duration       // End of daily UTC window compaction may run in, offset from midnight
+	CleanerInterval      time.Duration       // Frequency to enforce retention policy
+	HWCheckpointInterval time.Duration       // Frequency to checkpoint HW to disk
+	OnSegmentRoll        func(RolledSegment) // Invoked asynchronously, in roll order, when a segment is rolled
+	SyncWrites           bool                // Fsync each appended batch before returning
+	WriteBufferSize      int                 // Bytes of appends to buffer in memory before writing to the segment file, 0 to disable
+	MaxReaderDelay       time.Duration       // Max time retention defers deleting segments open readers still need, 0 to disable
+	IndexAccess          IndexAccess         // How segment index files are accessed
+	IndexInterval        int                 // Index entries between entries sampled in memory to speed up lookups, 0 to disable
 	Logger               logger.Logger
 }
 
+// RolledSegment describes a log segment which has been sealed because a new
+// segment was rolled out. It is passed to the OnSegmentRoll hook so that the
+// segment can be archived to external storage. Note that the segment files
+// may later be removed by retention or rewritten by compaction.
+type RolledSegment struct {
+	Path        string // Path to the segment's log file
+	FirstOffset int64  // Offset of the first message in the segment
+	LastOffset  int64  // Offset of the last message in the segment
+}
+
 // New creates a new CommitLog and starts a background goroutine which
 // periodically checkpoints the high watermark to disk.
 func New(opts Options) (CommitLog, error) {
 	if opts.Path == "" {
 		return nil, errors.New("path is empty")
 	}
+	if opts.WriteBufferSize != 0 &&
+		(opts.WriteBufferSize < MinWriteBufferSize || opts.WriteBufferSize > MaxWriteBufferSize) {
+		return nil, errors.Errorf("write buffer size %d must be between %d and %d",
+			opts.WriteBufferSize, MinWriteBufferSize, MaxWriteBufferSize)
+	}
 
 	if opts.Logger == nil {
 		opts.Logger = logger.NewLogger(0)
@@ -93,12 +145,15 @@ func New(opts Options) (CommitLog, error) {
 	cleanerOpts.Retention.Bytes = opts.MaxLogBytes
 	cleanerOpts.Retention.Messages = opts.MaxLogMessages
 	cleanerOpts.Retention.Age = opts.MaxLogAge
+	cleanerOpts.Retention.Segments = opts.MaxSegments
+	cleanerOpts.Retention.ReaderMaxDelay = opts.MaxReaderDelay
 	cleaner := newDeleteCleaner(cleanerOpts)
 
 	compactCleanerOpts := compactCleanerOptions{
 		Name:          opts.Name,
 		Logger:        opts.Logger,
 		MaxGoroutines: opts.CompactMaxGoroutines,
+		MinDirtyRatio: opts.CompactMinDirtyRatio,
 	}
 	compactCleaner := newCompactCleaner(compactCleanerOpts)
 
@@ -115,9 +170,11 @@ func New(opts Options) (CommitLog, error) {
 		compactCleaner:   compactCleaner,
 		hw:               -1,
 		closed:           make(chan struct{}),
-		hwWaiters:        make(map[contextReader]chan struct{}),
+		hwWaiters:        make(map[interface{}]chan struct{}),
 		leaderEpochCache: epochCache,
+		readers:          make(map[*Reader]struct{}),
 	}
+	cleaner.readerOffset = l.minReaderOffset
 
 	if err := l.init(); err != nil {
 		return nil, err
@@ -143,6 +200,10 @@ func New(opts Options) (CommitLog, error) {
 
 	go l.checkpointHWLoop()
 	go l.cleanerLoop()
+	if l.OnSegmentRoll != nil {
+		l.rolledCh = make(chan struct{}, 1)
+		go l.segmentRollLoop()
+	}
 
 	return l, nil
 }
@@ -179,7 +240,7 @@ func (l *commitLog) open() error {
 			if err != nil {
 				return err
 			}
-			segment, err := newSegment(l.Path, int64(baseOffset), l.MaxSegmentBytes, false, "")
+			segment, err := newSegment(l.Path, int64(baseOffset), l.MaxSegmentBytes, l.WriteBufferSize, l.IndexAccess, l.IndexInterval, false, "")
 			if err != nil {
 				return err
 			}
@@ -198,7 +259,7 @@ func (l *commitLog) open() error {
 		}
 	}
 	if len(l.segments) == 0 {
-		segment, err := newSegment(l.Path, 0, l.MaxSegmentBytes, true, "")
+		segment, err := newSegment(l.Path, 0, l.MaxSegmentBytes, l.WriteBufferSize, l.IndexAccess, l.IndexInterval, true, "")
 		if err != nil {
 			return err
 		}
@@ -211,7 +272,9 @@ func (l *commitLog) open() error {
 }
 
 // Append writes the given batch of messages to the log and returns their
-// corresponding offsets in the log.
+// corresponding offsets in the log. If a byte quota is configured, this
+// either returns ErrQuotaExceeded or evicts the oldest segments when the quota
+// would be exceeded, depending on the QuotaPolicy.
 func (l *commitLog) Append(msgs []*Message) ([]int64, error) {
 	if _, err := l.checkAndPerformSplit(); err != nil {
 		return nil, err
@@ -225,15 +288,26 @@ func (l *commitLog) Append(msgs []*Message) ([]int64, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := l.enforceQuota(int64(len(ms))); err != nil {
+		return nil, err
+	}
 	return l.append(segment, ms, entries)
 }
 
 // AppendMessageSet writes the given message set data to the log and returns
-// the corresponding offsets in the log.
+// the corresponding offsets in the log. The messages were already accepted by
+// the leader, so a byte quota is never enforced by rejecting them, but if the
+// QuotaPolicy is QuotaPolicyEvict, the oldest segments are evicted as they
+// would be by Append so the log stays the same as the leader's.
 func (l *commitLog) AppendMessageSet(ms []byte) ([]int64, error) {
 	if _, err := l.checkAndPerformSplit(); err != nil {
 		return nil, err
 	}
+	if l.QuotaPolicy == QuotaPolicyEvict {
+		if err := l.enforceQuota(int64(len(ms))); err != nil {
+			return nil, err
+		}
+	}
 	var (
 		segment      = l.activeSegment()
 		basePosition = segment.Position()
@@ -246,6 +320,13 @@ func (l *commitLog) append(segment *segment, ms []byte, entries []*entry) ([]int
 	if err := segment.WriteMessageSet(ms, entries); err != nil {
 		return nil, err
 	}
+	// Messages are synced a batch at a time, so callers which batch up
+	// concurrent writes share the cost of a single fsync.
+	if l.SyncWrites {
+		if err := l.syncSegment(segment); err != nil {
+			return nil, err
+		}
+	}
 	var (
 		lastLeaderEpoch = l.leaderEpochCache.LastLeaderEpoch()
 		offsets         = make([]int64, len(entries))
@@ -261,6 +342,7 @@ func (l *commitLog) append(segment *segment, ms []byte, entries []*entry) ([]int
 		}
 		offsets[i] = entry.Offset
 	}
+	atomic.StoreInt64(&l.lastAppend, time.Now().UnixNano())
 	return offsets, nil
 }
 
@@ -278,6 +360,18 @@ func (l *commitLog) OldestOffset() int64 {
 	return l.segments[0].FirstOffset()
 }
 
+// NewestTimestamp returns the timestamp of the last message in the log or 0 if
+// empty.
+func (l *commitLog) NewestTimestamp() int64 {
+	segments := l.Segments()
+	for i := len(segments) - 1; i >= 0; i-- {
+		if !segments[i].IsEmpty() {
+			return segments[i].LastTimestamp()
+		}
+	}
+	return 0
+}
+
 // OffsetForTimestamp returns the earliest offset whose timestamp is greater
 // than or equal to the given timestamp.
 func (l *commitLog) OffsetForTimestamp(timestamp int64) (int64, error) {
@@ -327,6 +421,7 @@ func (l *commitLog) SetHighWatermark(hw int64) {
 	l.mu.Lock()
 	if hw > l.hw {
 		l.hw = hw
+		atomic.StoreInt64(&l.lastCommit, time.Now().UnixNano())
 		l.notifyHWWaiters()
 	}
 	l.mu.Unlock()
@@ -350,7 +445,7 @@ func (l *commitLog) notifyHWWaiters() {
 	}
 }
 
-func (l *commitLog) waitForHW(r contextReader, hw int64) <-chan struct{} {
+func (l *commitLog) waitForHW(r interface{}, hw int64) <-chan struct{} {
 	wait := make(chan struct{})
 	l.mu.Lock()
 	// Check if HW has changed.
@@ -363,12 +458,40 @@ func (l *commitLog) waitForHW(r contextReader, hw int64) <-chan struct{} {
 	return wait
 }
 
-func (l *commitLog) removeHWWaiter(r contextReader) {
+func (l *commitLog) removeHWWaiter(r interface{}) {
 	l.mu.Lock()
 	delete(l.hwWaiters, r)
 	l.mu.Unlock()
 }
 
+// LastAppendTime returns when messages were last appended to the log or the
+// zero time if none have been appended since it was opened.
+func (l *commitLog) LastAppendTime() time.Time {
+	return unixNanoTime(atomic.LoadInt64(&l.lastAppend))
+}
+
+// LastCommitTime returns when the high watermark last advanced or the zero
+// time if it hasn't advanced since the log was opened.
+func (l *commitLog) LastCommitTime() time.Time {
+	return unixNanoTime(atomic.LoadInt64(&l.lastCommit))
+}
+
+// unixNanoTime converts Unix nanoseconds to a time, treating 0 as unset.
+func unixNanoTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// NotifyHW registers and returns a channel which is closed when the high
+// watermark changes from the given value. If the high watermark is no longer
+// the given value, the channel is closed immediately. Waiter is an opaque
+// value that uniquely identifies the entity waiting for the change.
+func (l *commitLog) NotifyHW(waiter interface{}, hw int64) <-chan struct{} {
+	return l.waitForHW(waiter, hw)
+}
+
 // HighWatermark returns the high watermark for the log.
 func (l *commitLog) HighWatermark() int64 {
 	l.mu.RLock()
@@ -376,6 +499,75 @@ func (l *commitLog) HighWatermark() int64 {
 	return l.hw
 }
 
+// Sync commits every segment of the log and the high watermark to stable
+// storage and returns the high watermark as of the start of the sync. All
+// messages up to and including the returned offset are durable once it
+// returns.
+func (l *commitLog) Sync() (int64, error) {
+	l.mu.RLock()
+	if l.deleted {
+		l.mu.RUnlock()
+		return 0, ErrCommitLogDeleted
+	}
+	var (
+		hw       = l.hw
+		segments = l.segments
+	)
+	l.mu.RUnlock()
+
+	for _, segment := range segments {
+		// Segments closed since are being removed by retention.
+		if err := l.syncSegment(segment); err != nil && err != ErrSegmentClosed {
+			return 0, err
+		}
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.deleted {
+		return 0, ErrCommitLogDeleted
+	}
+	if err := l.checkpointHW(); err != nil {
+		return 0, errors.Wrap(err, "failed to checkpoint high watermark")
+	}
+	return hw, nil
+}
+
+// syncSegment commits the given segment to stable storage and records the
+// fsync in the log's SyncStats.
+func (l *commitLog) syncSegment(segment *segment) error {
+	start := time.Now()
+	flushed, err := segment.sync()
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+
+	l.syncStatsMu.Lock()
+	defer l.syncStatsMu.Unlock()
+	l.syncStats.Syncs++
+	l.syncStats.SyncTime += elapsed
+	if elapsed > l.syncStats.MaxSyncTime {
+		l.syncStats.MaxSyncTime = elapsed
+	}
+	l.syncStats.BytesFlushed += flushed
+	return nil
+}
+
+// SyncStats returns counters for the fsyncs the log has performed since it
+// was opened.
+func (l *commitLog) SyncStats() SyncStats {
+	l.syncStatsMu.Lock()
+	defer l.syncStatsMu.Unlock()
+	return l.syncStats
+}
+
+// CompactStats returns statistics about the compactions the log has run since
+// it was opened.
+func (l *commitLog) CompactStats() CompactStats {
+	return l.compactCleaner.Stats()
+}
+
 // NewLeaderEpoch indicates the log is entering a new leader epoch.
 func (l *commitLog) NewLeaderEpoch(epoch uint64) error {
 	return l.leaderEpochCache.Assign(epoch, l.NewestOffset())
@@ -444,6 +636,61 @@ func (l *commitLog) IsDeleted() bool {
 	return l.deleted
 }
 
+// addReader tracks the Reader so that retention can defer deleting segments
+// it has yet to read. Readers are only tracked if MaxReaderDelay is
+// set.
+func (l *commitLog) addReader(r *Reader) {
+	if l.MaxReaderDelay <= 0 {
+		return
+	}
+	l.readersMu.Lock()
+	l.readers[r] = struct{}{}
+	l.readersMu.Unlock()
+}
+
+// removeReader stops tracking the Reader.
+func (l *commitLog) removeReader(r *Reader) {
+	l.readersMu.Lock()
+	delete(l.readers, r)
+	l.readersMu.Unlock()
+}
+
+// minReaderOffset returns the lowest offset the tracked Readers will read
+// next or -1 if there are no tracked Readers.
+func (l *commitLog) minReaderOffset() int64 {
+	l.readersMu.Lock()
+	defer l.readersMu.Unlock()
+	min := int64(-1)
+	for r := range l.readers {
+		offset := atomic.LoadInt64(&r.offset)
+		if min == -1 || offset < min {
+			min = offset
+		}
+	}
+	return min
+}
+
+// isClosed indicates if the log has been closed.
+func (l *commitLog) isClosed() bool {
+	select {
+	case <-l.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// truncatedBefore indicates if retention has deleted the segment containing
+// the given offset.
+func (l *commitLog) truncatedBefore(offset int64) bool {
+	// Wait for any in-progress clean to finish replacing the segments.
+	l.cleanMu.Lock()
+	l.cleanMu.Unlock() // nolint: staticcheck
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return !l.deleted && offset < l.segments[0].BaseOffset
+}
+
 // Truncate removes all messages from the log starting at the given offset.
 func (l *commitLog) Truncate(offset int64) error {
 	l.mu.Lock()
@@ -554,14 +801,21 @@ func (l *commitLog) checkAndPerformSplit() (bool, error) {
 			return false, err
 		}
 		activeSegment.Seal()
+		l.notifySegmentRolled(activeSegment)
 		return true, nil
 	}
 }
 
 func (l *commitLog) split(oldActiveSegment *segment) error {
-	offset := l.NewestOffset() + 1
+	return l.splitAt(oldActiveSegment, l.NewestOffset()+1)
+}
+
+// splitAt replaces the given active segment with a new segment starting at the
+// given base offset. It returns ErrSegmentExists if another thread has already
+// replaced the active segment.
+func (l *commitLog) splitAt(oldActiveSegment *segment, offset int64) error {
 	l.Logger.Debugf("Appending new log segment for %s with base offset %d", l.Path, offset)
-	segment, err := newSegment(l.Path, offset, l.MaxSegmentBytes, true, "")
+	segment, err := newSegment(l.Path, offset, l.MaxSegmentBytes, l.WriteBufferSize, l.IndexAccess, l.IndexInterval, true, "")
 	if err != nil {
 		return err
 	}
@@ -581,6 +835,50 @@ func (l *commitLog) split(oldActiveSegment *segment) error {
 	return nil
 }
 
+// notifySegmentRolled queues the given sealed segment for the OnSegmentRoll
+// hook, if one is set. The hook is called by segmentRollLoop so that it does
+// not block appends.
+func (l *commitLog) notifySegmentRolled(seg *segment) {
+	if l.OnSegmentRoll == nil {
+		return
+	}
+	l.rolledMu.Lock()
+	l.rolled = append(l.rolled, RolledSegment{
+		Path:        seg.logPath(),
+		FirstOffset: seg.FirstOffset(),
+		LastOffset:  seg.LastOffset(),
+	})
+	l.rolledMu.Unlock()
+	select {
+	case l.rolledCh <- struct{}{}:
+	default:
+	}
+}
+
+// segmentRollLoop invokes the OnSegmentRoll hook for each rolled segment in
+// the order they were rolled. Segments rolled before the log is closed are
+// still passed to the hook.
+func (l *commitLog) segmentRollLoop() {
+	for {
+		var closed bool
+		select {
+		case <-l.rolledCh:
+		case <-l.closed:
+			closed = true
+		}
+		l.rolledMu.Lock()
+		rolled := l.rolled
+		l.rolled = nil
+		l.rolledMu.Unlock()
+		for _, seg := range rolled {
+			l.OnSegmentRoll(seg)
+		}
+		if closed {
+			return
+		}
+	}
+}
+
 func (l *commitLog) cleanerLoop() {
 	ticker := time.NewTicker(l.CleanerInterval)
 	defer ticker.Stop()
@@ -612,6 +910,8 @@ func (l *commitLog) cleanerLoop() {
 
 // Clean applies retention and compaction rules against the log, if applicable.
 func (l *commitLog) Clean() error {
+	l.cleanMu.Lock()
+	defer l.cleanMu.Unlock()
 	l.mu.RLock()
 	oldSegments := l.segments
 	l.mu.RUnlock()
@@ -655,12 +955,16 @@ func (l *commitLog) rebaseSegments(from, to []*segment, epochCache *leaderEpochC
 // *leaderEpochCache maintaining the start offset for each new leader epoch. If
 // compaction did not run, the leaderEpochCache will be nil.
 func (l *commitLog) clean(segments []*segment) ([]*segment, *leaderEpochCache, error) {
-	cleaned, err := l.deleteCleaner.Clean(segments)
+	cleaned, err := l.deleteCleaner.Clean(l.HighWatermark(), segments)
 	if err != nil {
 		return nil, nil, err
 	}
 	var epochCache *leaderEpochCache
 	if l.Compact {
+		if !l.inCompactWindow() {
+			l.Logger.Debugf("Deferring compaction of log %s until compaction window", l.Name)
+			return cleaned, nil, nil
+		}
 		cleaned, epochCache, err = l.compactCleaner.Compact(l.HighWatermark(), cleaned)
 		if err != nil {
 			return nil, nil, err
@@ -669,6 +973,21 @@ func (l *commitLog) clean(segments []*segment) ([]*segment, *leaderEpochCache, e
 	return cleaned, epochCache, nil
 }
 
+// inCompactWindow indicates if the current time falls within the daily
+// compaction window. The window may wrap around midnight, e.g. 22:00 to 04:00.
+// If no window is configured, compaction may run at any time.
+func (l *commitLog) inCompactWindow() bool {
+	start, end := l.CompactWindowStart, l.CompactWindowEnd
+	if start == end {
+		return true
+	}
+	now := time.Duration(timestamp() % int64(24*time.Hour))
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
 func (l *commitLog) checkpointHWLoop() {
 	ticker := time.NewTicker(l.HWCheckpointInterval)
 	defer ticker.Stop()
@@ -4,7 +4,9 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -49,6 +51,223 @@ func TestNewCommitLog(t *testing.T) {
 	}
 }
 
+// Ensure appending with SyncWrites enabled writes messages to the log.
+func TestAppendSyncWrites(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{
+		Path:       tempDir(t),
+		SyncWrites: true,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	offsets, err := l.Append(msgs)
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 1, 2, 3, 4}, offsets)
+	require.Equal(t, int64(4), l.NewestOffset())
+}
+
+// Ensure SyncStats counts an fsync for every append with SyncWrites enabled
+// and only explicit syncs otherwise, along with the bytes each one flushed.
+func TestSyncStats(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{
+		Path:       tempDir(t),
+		SyncWrites: true,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		_, err := l.Append(msgs)
+		require.NoError(t, err)
+	}
+	stats := l.SyncStats()
+	require.Equal(t, int64(3), stats.Syncs)
+	require.Equal(t, l.activeSegment().Position(), stats.BytesFlushed)
+	require.True(t, stats.SyncTime > 0)
+	require.True(t, stats.MaxSyncTime > 0 && stats.MaxSyncTime <= stats.SyncTime)
+
+	l2, cleanup2 := setupWithOptions(t, Options{Path: tempDir(t)})
+	defer l2.Close()
+	defer cleanup2()
+
+	for i := 0; i < 3; i++ {
+		_, err := l2.Append(msgs)
+		require.NoError(t, err)
+	}
+	require.Equal(t, SyncStats{}, l2.SyncStats())
+
+	_, err := l2.Sync()
+	require.NoError(t, err)
+	stats = l2.SyncStats()
+	require.Equal(t, int64(1), stats.Syncs)
+	require.Equal(t, l2.activeSegment().Position(), stats.BytesFlushed)
+}
+
+// Ensure LastAppendTime is set by appends and LastCommitTime only when the HW
+// advances.
+func TestLastAppendCommitTime(t *testing.T) {
+	l, cleanup := setup(t)
+	defer l.Close()
+	defer cleanup()
+
+	require.True(t, l.LastAppendTime().IsZero())
+	require.True(t, l.LastCommitTime().IsZero())
+
+	before := time.Now()
+	_, err := l.Append(msgs)
+	require.NoError(t, err)
+	appended := l.LastAppendTime()
+	require.False(t, appended.Before(before))
+	require.True(t, l.LastCommitTime().IsZero())
+
+	l.SetHighWatermark(4)
+	committed := l.LastCommitTime()
+	require.False(t, committed.Before(appended))
+
+	// The HW not advancing doesn't count as a commit.
+	l.SetHighWatermark(2)
+	require.Equal(t, committed, l.LastCommitTime())
+	require.Equal(t, appended, l.LastAppendTime())
+}
+
+// Ensure messages appended with a write buffer are readable before the buffer
+// is full and are written to the segment file when the log is closed.
+func TestAppendWriteBuffer(t *testing.T) {
+	opts := Options{
+		Path:            tempDir(t),
+		WriteBufferSize: MinWriteBufferSize,
+	}
+	l, cleanup := setupWithOptions(t, opts)
+	defer cleanup()
+
+	_, err := l.Append(msgs)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := l.NewReader(0, true)
+	require.NoError(t, err)
+	headers := make([]byte, 28)
+	for i, exp := range msgs {
+		msg, offset, _, _, err := r.ReadMessage(ctx, headers)
+		require.NoError(t, err)
+		require.Equal(t, int64(i), offset)
+		compareMessages(t, exp, msg)
+	}
+
+	// Buffered messages are written out on close.
+	_, err = l.Append(msgs)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	l, cleanup = setupWithOptions(t, opts)
+	defer cleanup()
+	defer l.Close()
+	require.Equal(t, int64(9), l.NewestOffset())
+}
+
+// Ensure a log using IndexAccessRead reads back the same messages and offset
+// lookups as one using memory-mapped indexes, including after reopening.
+func TestIndexAccessRead(t *testing.T) {
+	batch := make([]*Message, 25)
+	for i := range batch {
+		batch[i] = &Message{Value: []byte(strconv.Itoa(i)), Timestamp: int64(i * 10)}
+	}
+	for _, access := range []IndexAccess{IndexAccessMmap, IndexAccessRead} {
+		opts := Options{
+			Path:            tempDir(t),
+			MaxSegmentBytes: 100,
+			IndexAccess:     access,
+		}
+		l, cleanup := setupWithOptions(t, opts)
+		for _, msg := range batch {
+			_, err := l.Append([]*Message{msg})
+			require.NoError(t, err)
+		}
+		require.True(t, len(l.Segments()) > 1)
+		require.NoError(t, l.Close())
+
+		l, cleanup = setupWithOptions(t, opts)
+		require.Equal(t, int64(24), l.NewestOffset())
+		offset, err := l.OffsetForTimestamp(125)
+		require.NoError(t, err)
+		require.Equal(t, int64(13), offset)
+
+		r, err := l.NewReader(0, true)
+		require.NoError(t, err)
+		headers := make([]byte, 28)
+		for i, exp := range batch {
+			msg, offset, _, _, err := r.ReadMessage(context.Background(), headers)
+			require.NoError(t, err)
+			require.Equal(t, int64(i), offset)
+			compareMessages(t, exp, msg)
+		}
+		require.NoError(t, l.Close())
+		cleanup()
+	}
+}
+
+// Ensure a sampled index keeps every interval-th entry in memory, including
+// after reopening, and lookups by offset and timestamp with it find the same
+// entries as with an unsampled one.
+func TestIndexInterval(t *testing.T) {
+	const num = 1000
+	for _, interval := range []int{0, 1, 16} {
+		opts := Options{
+			Path:            tempDir(t),
+			MaxSegmentBytes: 1024 * 1024,
+			IndexInterval:   interval,
+		}
+		l, cleanup := setupWithOptions(t, opts)
+		// Append in batches which don't line up with the interval.
+		for i := 0; i < num; i += 7 {
+			var batch []*Message
+			for j := i; j < i+7 && j < num; j++ {
+				batch = append(batch, &Message{Value: []byte(strconv.Itoa(j)), Timestamp: int64(j * 10)})
+			}
+			_, err := l.Append(batch)
+			require.NoError(t, err)
+		}
+
+		for reopen := 0; reopen < 2; reopen++ {
+			require.Len(t, l.Segments(), 1)
+			seg := l.Segments()[0]
+			if interval == 0 {
+				require.Empty(t, seg.samples)
+			} else {
+				require.Len(t, seg.samples, (num+interval-1)/interval)
+				for k, sample := range seg.samples {
+					require.Equal(t, int64(k*interval), sample.Offset)
+					require.Equal(t, int64(k*interval*10), sample.Timestamp)
+				}
+			}
+			for i := int64(0); i < num; i++ {
+				e, err := seg.findEntry(i)
+				require.NoError(t, err)
+				require.Equal(t, i, e.Offset)
+				e, err = seg.findEntryByTimestamp(i*10 - 5)
+				require.NoError(t, err)
+				require.Equal(t, i, e.Offset)
+			}
+			_, err := seg.findEntry(num)
+			require.Equal(t, ErrEntryNotFound, err)
+			_, err = seg.findEntryByTimestamp(num * 10)
+			require.Equal(t, ErrEntryNotFound, err)
+
+			require.NoError(t, l.Close())
+			l, _ = setupWithOptions(t, opts)
+		}
+		require.NoError(t, l.Close())
+		cleanup()
+	}
+}
+
+// Ensure New returns an error when the write buffer size is out of bounds.
+func TestNewCommitLogInvalidWriteBufferSize(t *testing.T) {
+	_, err := New(Options{Path: tempDir(t), WriteBufferSize: MinWriteBufferSize - 1})
+	require.Error(t, err)
+	_, err = New(Options{Path: tempDir(t), WriteBufferSize: MaxWriteBufferSize + 1})
+	require.Error(t, err)
+}
+
 func TestNewCommitLogEmptyPath(t *testing.T) {
 	_, err := New(Options{})
 	require.Error(t, err)
@@ -183,6 +402,61 @@ func BenchmarkCommitLog(b *testing.B) {
 	}
 }
 
+// BenchmarkCommitLogSyncWrites measures the per-message cost of appending
+// with SyncWrites enabled for different batch sizes. Larger batches amortize
+// the fsync across more messages.
+func BenchmarkCommitLogSyncWrites(b *testing.B) {
+	for _, batchSize := range []int{1, 16, 128, 1024} {
+		b.Run(strconv.Itoa(batchSize), func(b *testing.B) {
+			l, cleanup := setupWithOptions(b, Options{
+				Path:       tempDir(b),
+				SyncWrites: true,
+			})
+			defer l.Close()
+			defer cleanup()
+
+			batch := make([]*Message, batchSize)
+			for i := range batch {
+				batch[i] = msgs[i%len(msgs)]
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i += batchSize {
+				n := batchSize
+				if b.N-i < n {
+					n = b.N - i
+				}
+				_, err := l.Append(batch[:n])
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+// BenchmarkCommitLogWriteBuffer measures the throughput of appending single
+// messages with different write buffer sizes. Larger buffers make fewer write
+// syscalls at the cost of memory.
+func BenchmarkCommitLogWriteBuffer(b *testing.B) {
+	for _, size := range []int{0, 4096, 65536, 1048576} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			l, cleanup := setupWithOptions(b, Options{
+				Path:            tempDir(b),
+				WriteBufferSize: size,
+			})
+			defer l.Close()
+			defer cleanup()
+
+			set, _, err := newMessageSetFromProto(0, 0, msgs[:1])
+			require.NoError(b, err)
+			b.SetBytes(int64(len(set)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := l.Append(msgs[:1])
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
 func TestOffsets(t *testing.T) {
 	l, cleanup := setupWithOptions(t, Options{
 		Path:            tempDir(t),
@@ -301,6 +575,137 @@ func TestCleanerDeleteLeaderEpochOffsets(t *testing.T) {
 	require.Equal(t, int64(14), l.LastOffsetForLeaderEpoch(3))
 }
 
+// Ensure Clean deletes the oldest segments to maintain the segments limit and
+// does not delete segments containing messages above the HW.
+func TestCleanerMaxSegments(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{
+		Path:            tempDir(t),
+		MaxSegmentBytes: 6,
+		MaxSegments:     3,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	// Add some messages, each of which rolls a new segment.
+	for i := 0; i < 10; i++ {
+		_, err := l.Append([]*Message{{
+			Value:       []byte(strconv.Itoa(i)),
+			Timestamp:   time.Now().UnixNano(),
+			LeaderEpoch: 1,
+		}})
+		require.NoError(t, err)
+	}
+	require.Equal(t, 10, len(l.Segments()))
+
+	// Nothing is committed, so no segments should be deleted.
+	require.NoError(t, l.Clean())
+	require.Equal(t, 10, len(l.Segments()))
+	require.Equal(t, int64(0), l.OldestOffset())
+
+	// Segments are only deleted up to the HW.
+	l.SetHighWatermark(4)
+	require.NoError(t, l.Clean())
+	require.Equal(t, 5, len(l.Segments()))
+	require.Equal(t, int64(5), l.OldestOffset())
+
+	l.SetHighWatermark(9)
+	require.NoError(t, l.Clean())
+	require.Equal(t, 3, len(l.Segments()))
+	require.Equal(t, int64(7), l.OldestOffset())
+	require.Equal(t, int64(9), l.NewestOffset())
+
+	// Add some more messages.
+	for i := 10; i < 15; i++ {
+		_, err := l.Append([]*Message{{
+			Value:       []byte(strconv.Itoa(i)),
+			Timestamp:   time.Now().UnixNano(),
+			LeaderEpoch: 1,
+		}})
+		require.NoError(t, err)
+	}
+	l.SetHighWatermark(14)
+	require.NoError(t, l.Clean())
+	require.Equal(t, 3, len(l.Segments()))
+	require.Equal(t, int64(12), l.OldestOffset())
+	require.Equal(t, int64(14), l.NewestOffset())
+}
+
+// Ensure Clean defers deleting segments an active reader still needs until
+// the reader moves past them or MaxReaderDelay elapses, after which the reader
+// gets ErrOffsetTruncated.
+func TestCleanerDeferForReaders(t *testing.T) {
+	timestampBefore := timestamp
+	defer func() {
+		timestamp = timestampBefore
+	}()
+	now := time.Now().UnixNano()
+	timestamp = func() int64 {
+		return now
+	}
+
+	l, cleanup := setupWithOptions(t, Options{
+		Path:            tempDir(t),
+		MaxSegmentBytes: 6,
+		MaxLogMessages:  5,
+		MaxReaderDelay:  time.Minute,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	// Add some messages, each of which rolls a new segment.
+	for i := 0; i < 10; i++ {
+		_, err := l.Append([]*Message{{
+			Value:       []byte(strconv.Itoa(i)),
+			Timestamp:   time.Now().UnixNano(),
+			LeaderEpoch: 1,
+		}})
+		require.NoError(t, err)
+	}
+	l.SetHighWatermark(9)
+
+	// Start a slow reader at the beginning of the log and read one message.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := l.NewReader(0, true)
+	require.NoError(t, err)
+	defer r.Close()
+	headers := make([]byte, 28)
+	_, offset, _, _, err := r.ReadMessage(ctx, headers)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), offset)
+
+	// Only the segment the reader is done with is deleted.
+	require.NoError(t, l.Clean())
+	require.Equal(t, 9, len(l.Segments()))
+	require.Equal(t, int64(1), l.OldestOffset())
+
+	// Deletion stays deferred until the max delay.
+	now += int64(30 * time.Second)
+	require.NoError(t, l.Clean())
+	require.Equal(t, int64(1), l.OldestOffset())
+
+	// The reader moving on releases the segments behind it.
+	_, offset, _, _, err = r.ReadMessage(ctx, headers)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), offset)
+	require.NoError(t, l.Clean())
+	require.Equal(t, int64(2), l.OldestOffset())
+
+	// Once the max delay elapses, segments are deleted regardless.
+	now += int64(30 * time.Second)
+	require.NoError(t, l.Clean())
+	require.Equal(t, 5, len(l.Segments()))
+	require.Equal(t, int64(5), l.OldestOffset())
+
+	// The reader now finds its next offset was deleted.
+	_, _, _, _, err = r.ReadMessage(ctx, headers)
+	require.Equal(t, ErrOffsetTruncated, err)
+
+	// Closed readers no longer defer deletion.
+	r.Close()
+	require.Equal(t, int64(-1), l.minReaderOffset())
+}
+
 // Ensure Clean replaces leader epoch offsets in the cache when segments are
 // compacted.
 func TestCleanerReplaceLeaderEpochOffsets(t *testing.T) {
@@ -588,6 +993,88 @@ func TestNotifyLEOIdempotent(t *testing.T) {
 	require.Equal(t, ch1, ch2)
 }
 
+// Ensure the OnSegmentRoll hook is invoked with the sealed segment's path and
+// offset range when a new segment is rolled.
+func TestOnSegmentRoll(t *testing.T) {
+	rolled := make(chan RolledSegment, 1)
+	opts := Options{
+		Path:            tempDir(t),
+		MaxSegmentBytes: 6,
+		OnSegmentRoll: func(seg RolledSegment) {
+			rolled <- seg
+		},
+	}
+	l, cleanup := setupWithOptions(t, opts)
+	defer l.Close()
+	defer cleanup()
+
+	// Append a batch which fills the active segment.
+	_, err := l.Append(msgs[:3])
+	require.NoError(t, err)
+
+	select {
+	case <-rolled:
+		t.Fatal("Unexpected segment roll")
+	default:
+	}
+
+	// The next append should roll a new segment.
+	_, err = l.Append(msgs[3:])
+	require.NoError(t, err)
+
+	select {
+	case seg := <-rolled:
+		require.Equal(t, filepath.Join(opts.Path, "00000000000000000000.log"), seg.Path)
+		require.Equal(t, int64(0), seg.FirstOffset)
+		require.Equal(t, int64(2), seg.LastOffset)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected segment roll")
+	}
+	require.Len(t, l.Segments(), 2)
+}
+
+// Ensure the OnSegmentRoll hook is invoked for rolled segments in the order
+// they were rolled, one at a time.
+func TestOnSegmentRollOrdered(t *testing.T) {
+	var (
+		rolled     = make(chan RolledSegment, len(msgs))
+		inFlight   int32
+		concurrent int32
+	)
+	opts := Options{
+		Path:            tempDir(t),
+		MaxSegmentBytes: 1,
+		OnSegmentRoll: func(seg RolledSegment) {
+			if atomic.AddInt32(&inFlight, 1) > 1 {
+				atomic.StoreInt32(&concurrent, 1)
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			rolled <- seg
+		},
+	}
+	l, cleanup := setupWithOptions(t, opts)
+	defer l.Close()
+	defer cleanup()
+
+	// Roll a segment for each message.
+	for _, msg := range msgs {
+		_, err := l.Append([]*Message{msg})
+		require.NoError(t, err)
+	}
+
+	for i := int64(0); i < int64(len(msgs)-1); i++ {
+		select {
+		case seg := <-rolled:
+			require.Equal(t, i, seg.FirstOffset)
+			require.Equal(t, i, seg.LastOffset)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected segment roll")
+		}
+	}
+	require.Equal(t, int32(0), atomic.LoadInt32(&concurrent))
+}
+
 func setup(t require.TestingT) (*commitLog, func()) {
 	opts := Options{
 		Path:            tempDir(t),
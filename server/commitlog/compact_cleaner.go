@@ -17,12 +17,38 @@ type compactCleanerOptions struct {
 	Logger        logger.Logger
 	Name          string
 	MaxGoroutines int
+
+	// MinDirtyRatio is the minimum fraction of a segment's messages which
+	// compaction would remove for the segment to be rewritten. Segments
+	// below it are left as is to limit write amplification. Zero compacts
+	// every segment.
+	MinDirtyRatio float64
+}
+
+// CompactStats contains statistics about the compactions a commit log has
+// run, which can be used to check compaction is keeping up with a stream.
+type CompactStats struct {
+	Runs           int64     // Number of compactions run
+	LastRun        time.Time // When compaction last ran, zero if it hasn't
+	LastReclaimed  int64     // Bytes removed by the last compaction
+	BytesReclaimed int64     // Bytes removed by all compactions
+	DirtyRatio     float64   // Fraction of compactable messages removable when compaction last ran
 }
 
 // compactCleaner implements the compaction policy which replaces segments with
 // compacted ones, i.e. retaining only the last message for a given key.
 type compactCleaner struct {
 	compactCleanerOptions
+	statsMu sync.Mutex
+	stats   CompactStats
+}
+
+// compactRun contains the results of a single compaction.
+type compactRun struct {
+	removed   int   // Messages removed
+	dirty     int   // Messages removable, including in segments left as is
+	scanned   int   // Messages in the compacted segments
+	reclaimed int64 // Bytes removed
 }
 
 // NewCompactCleaner returns a new cleaner which performs log compaction by
@@ -32,7 +58,7 @@ func newCompactCleaner(opts compactCleanerOptions) *compactCleaner {
 	if opts.MaxGoroutines == 0 {
 		opts.MaxGoroutines = defaultCompactMaxGoroutines
 	}
-	return &compactCleaner{opts}
+	return &compactCleaner{compactCleanerOptions: opts}
 }
 
 // Compact performs log compaction by rewriting segments such that they contain
@@ -50,13 +76,15 @@ func (c *compactCleaner) Compact(hw int64, segments []*segment) ([]*segment,
 
 	c.Logger.Debugf("Compacting log %s", c.Name)
 	before := time.Now()
-	compacted, epochCache, removed, err := c.compact(hw, segments)
+	compacted, epochCache, run, err := c.compact(hw, segments)
 	if err == nil {
 		c.Logger.Debugf("Finished compacting log %s\n"+
 			"\tMessages Removed: %d\n"+
+			"\tBytes Reclaimed: %d\n"+
 			"\tSegments: %d -> %d\n"+
 			"\tDuration: %s",
-			c.Name, removed, len(segments), len(compacted), time.Since(before))
+			c.Name, run.removed, run.reclaimed, len(segments), len(compacted), time.Since(before))
+		c.recordRun(run)
 	}
 
 	return compacted, epochCache, errors.Wrap(err, "failed to compact log")
@@ -82,8 +110,30 @@ func (k *keyOffset) get() int64 {
 	return k.offset
 }
 
+// recordRun records the results of a compaction in the cleaner's
+// CompactStats.
+func (c *compactCleaner) recordRun(run compactRun) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	c.stats.Runs++
+	c.stats.LastRun = time.Now()
+	c.stats.LastReclaimed = run.reclaimed
+	c.stats.BytesReclaimed += run.reclaimed
+	c.stats.DirtyRatio = 0
+	if run.scanned > 0 {
+		c.stats.DirtyRatio = float64(run.dirty) / float64(run.scanned)
+	}
+}
+
+// Stats returns statistics about the compactions the cleaner has run.
+func (c *compactCleaner) Stats() CompactStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.stats
+}
+
 func (c *compactCleaner) compact(hw int64, segments []*segment) ([]*segment,
-	*leaderEpochCache, int, error) {
+	*leaderEpochCache, compactRun, error) {
 
 	// Compact messages up to the last segment or HW, whichever is first, by
 	// scanning keys and retaining only the latest.
@@ -91,21 +141,43 @@ func (c *compactCleaner) compact(hw int64, segments []*segment) ([]*segment,
 	var (
 		compacted  = make([]*segment, 0, len(segments))
 		epochCache = newLeaderEpochCacheNoFile(c.Name, c.Logger)
-		removed    = 0
+		run        compactRun
 		keyOffsets = c.scanKeys(hw, segments)
 	)
 
 	// Write new segments. Skip the last segment since we will not compact it.
 	// TODO: Join segments that are below the bytes limit.
 	for _, seg := range segments[:len(segments)-1] {
-		cleaned, msgsRemoved, err := c.cleanSegment(seg, keyOffsets, hw, epochCache)
+		if c.MinDirtyRatio > 0 {
+			dirty, total, epochs := c.dirtyCounts(seg, keyOffsets, hw, epochCache.LastLeaderEpoch())
+			if total > 0 && float64(dirty)/float64(total) < c.MinDirtyRatio {
+				// Not enough to remove to be worth rewriting the
+				// segment, so retain it with the leader epochs found
+				// while counting.
+				for _, e := range epochs {
+					if err := epochCache.Assign(e.epoch, e.offset); err != nil {
+						return nil, nil, run, err
+					}
+				}
+				compacted = append(compacted, seg)
+				run.dirty += dirty
+				run.scanned += total
+				continue
+			}
+		}
+		size := seg.Position()
+		cleaned, msgsRemoved, msgsScanned, err := c.cleanSegment(seg, keyOffsets, hw, epochCache)
 		if err != nil {
-			return nil, nil, 0, err
+			return nil, nil, run, err
 		}
 		if cleaned != nil {
 			compacted = append(compacted, cleaned)
+			size -= cleaned.Position()
 		}
-		removed += msgsRemoved
+		run.removed += msgsRemoved
+		run.dirty += msgsRemoved
+		run.scanned += msgsScanned
+		run.reclaimed += size
 	}
 
 	// Add the last segment back in to the compacted list.
@@ -113,31 +185,87 @@ func (c *compactCleaner) compact(hw int64, segments []*segment) ([]*segment,
 	compacted = append(compacted, last)
 
 	// Maintain start offset for each new leader epoch for the last segment.
-	ss := newSegmentScanner(last)
+	if err := assignLeaderEpochs(last, epochCache); err != nil {
+		return nil, nil, run, err
+	}
+
+	return compacted, epochCache, run, nil
+}
+
+// assignLeaderEpochs maintains the start offset for each new leader epoch in
+// the given segment, which is retained without being compacted.
+func assignLeaderEpochs(seg *segment, epochCache *leaderEpochCache) error {
+	ss := newSegmentScanner(seg)
 	for ms, _, err := ss.Scan(); err == nil; ms, _, err = ss.Scan() {
 		leaderEpoch := ms.LeaderEpoch()
 		if leaderEpoch > epochCache.LastLeaderEpoch() {
 			if err := epochCache.Assign(leaderEpoch, ms.Offset()); err != nil {
-				return nil, nil, 0, err
+				return err
 			}
 		}
 	}
+	return nil
+}
+
+// epochStart is the offset of the first message of a leader epoch.
+type epochStart struct {
+	epoch  uint64
+	offset int64
+}
+
+// dirtyCounts returns the number of the segment's messages which compaction
+// would remove, i.e. keyed messages below the HW which have been superseded
+// by a later message with the same key, and the total number of messages in
+// the segment. It also returns the start of each leader epoch after
+// lastEpoch in the segment so that the segment can be retained as is without
+// scanning it again.
+func (c *compactCleaner) dirtyCounts(seg *segment, keyOffsets *sync.Map, hw int64,
+	lastEpoch uint64) (int, int, []epochStart) {
 
-	return compacted, epochCache, removed, nil
+	var (
+		ss     = newSegmentScanner(seg)
+		total  = 0
+		dirty  = 0
+		epochs []epochStart
+	)
+	for ms, _, err := ss.Scan(); err == nil; ms, _, err = ss.Scan() {
+		total++
+		var (
+			offset      = ms.Offset()
+			key         = ms.Message().Key()
+			leaderEpoch = ms.LeaderEpoch()
+		)
+		if leaderEpoch > lastEpoch {
+			epochs = append(epochs, epochStart{epoch: leaderEpoch, offset: offset})
+			lastEpoch = leaderEpoch
+		}
+		if key == nil || offset >= hw {
+			continue
+		}
+		if latest, ok := keyOffsets.Load(string(key)); ok && latest.(*keyOffset).get() != offset {
+			dirty++
+		}
+	}
+	return dirty, total, epochs
 }
 
+// cleanSegment rewrites the segment retaining only the latest message for each
+// key below the HW. It returns the cleaned segment, or nil if no messages were
+// retained, along with the number of messages removed and scanned.
 func (c *compactCleaner) cleanSegment(seg *segment, keyOffsets *sync.Map, hw int64,
-	epochCache *leaderEpochCache) (*segment, int, error) {
+	epochCache *leaderEpochCache) (*segment, int, int, error) {
 
 	cleaned, err := seg.Cleaned()
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, 0, err
 	}
 	var (
 		ss      = newSegmentScanner(seg)
 		removed = 0
+		scanned = 0
 	)
 	for ms, _, err := ss.Scan(); err == nil; ms, _, err = ss.Scan() {
+		scanned++
 		var (
 			offset       = ms.Offset()
 			key          = ms.Message().Key()
@@ -154,12 +282,12 @@ func (c *compactCleaner) cleanSegment(seg *segment, keyOffsets *sync.Map, hw int
 		if key == nil || offset == latestOffset || offset >= hw {
 			entries := entriesForMessageSet(cleaned.Position(), ms)
 			if err := cleaned.WriteMessageSet(ms, entries); err != nil {
-				return nil, removed, err
+				return nil, removed, scanned, err
 			}
 			// Maintain start offset for each new leader epoch.
 			if leaderEpoch > epochCache.LastLeaderEpoch() {
 				if err := epochCache.Assign(leaderEpoch, offset); err != nil {
-					return nil, removed, err
+					return nil, removed, scanned, err
 				}
 			}
 		} else {
@@ -169,13 +297,13 @@ func (c *compactCleaner) cleanSegment(seg *segment, keyOffsets *sync.Map, hw int
 
 	if cleaned.IsEmpty() {
 		// If the new segment is empty, remove it along with the old one.
-		return nil, removed, cleanupEmptySegment(cleaned, seg)
+		return nil, removed, scanned, cleanupEmptySegment(cleaned, seg)
 	}
 	// Otherwise replace the old segment with the compacted one.
 	if err = cleaned.Replace(seg); err != nil {
-		return nil, removed, err
+		return nil, removed, scanned, err
 	}
-	return cleaned, removed, nil
+	return cleaned, removed, scanned, nil
 }
 
 func (c *compactCleaner) scanKeys(hw int64, segments []*segment) *sync.Map {
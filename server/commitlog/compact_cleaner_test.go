@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -237,6 +238,180 @@ func TestCompactCleanerTruncateConcurrent(t *testing.T) {
 	require.Equal(t, int64(-1), l.OldestOffset())
 }
 
+// Ensure compaction only runs within the configured compaction window.
+func TestCompactCleanerWindow(t *testing.T) {
+	timestampBefore := timestamp
+	defer func() {
+		timestamp = timestampBefore
+	}()
+	now := 6 * time.Hour
+	timestamp = func() int64 {
+		return int64(now)
+	}
+
+	opts := Options{
+		Path:               tempDir(t),
+		MaxSegmentBytes:    100,
+		Compact:            true,
+		CompactWindowStart: time.Hour,
+		CompactWindowEnd:   5 * time.Hour,
+	}
+	l, cleanup := setupWithOptions(t, opts)
+	defer cleanup()
+
+	// Append some messages.
+	entries := []keyValue{
+		{[]byte("foo"), []byte("first")},
+		{[]byte("bar"), []byte("first")},
+		{[]byte("foo"), []byte("second")},
+		{[]byte("foo"), []byte("third")},
+		{[]byte("bar"), []byte("second")},
+		{[]byte("baz"), []byte("first")},
+		{[]byte("baz"), []byte("second")},
+		{[]byte("qux"), []byte("first")},
+		{[]byte("foo"), []byte("fourth")},
+		{[]byte("baz"), []byte("third")},
+	}
+	appendToLog(t, l, entries, true)
+
+	firstOffset := func() int64 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r, err := l.NewReader(0, true)
+		require.NoError(t, err)
+		_, offset, _, _, err := r.ReadMessage(ctx, make([]byte, 28))
+		require.NoError(t, err)
+		return offset
+	}
+
+	// Outside the window, compaction is deferred.
+	require.NoError(t, l.Clean())
+	require.Equal(t, int64(0), firstOffset())
+
+	// Inside the window, compaction runs.
+	now = 2 * time.Hour
+	require.NoError(t, l.Clean())
+	require.Equal(t, int64(4), firstOffset())
+}
+
+// Ensure Compact only rewrites segments whose fraction of removable messages
+// reaches CompactMinDirtyRatio and leaves the rest untouched.
+func TestCompactCleanerMinDirtyRatio(t *testing.T) {
+	opts := Options{
+		Path:                 tempDir(t),
+		MaxSegmentBytes:      100,
+		Compact:              true,
+		CompactMinDirtyRatio: 0.6,
+	}
+	l, cleanup := setupWithOptions(t, opts)
+	defer cleanup()
+
+	// Append some messages. Each segment holds two of them.
+	entries := []keyValue{
+		// Both superseded, so this segment is compacted away.
+		{[]byte("foo"), []byte("first")},
+		{[]byte("bar"), []byte("first")},
+		// Half superseded, so this segment is left as is.
+		{[]byte("baz"), []byte("first")},
+		{[]byte("qux"), []byte("first")},
+		// Clean segments.
+		{[]byte("foo"), []byte("second")},
+		{[]byte("bar"), []byte("second")},
+		{[]byte("baz"), []byte("second")},
+		{[]byte("quux"), []byte("first")},
+		// Active segment.
+		{[]byte("corge"), []byte("first")},
+	}
+	appendToLog(t, l, entries, true)
+
+	offsets := func() []int64 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r, err := l.NewReader(0, true)
+		require.NoError(t, err)
+		var offsets []int64
+		for offset := int64(-1); offset < int64(len(entries)-1); {
+			_, offset, _, _, err = r.ReadMessage(ctx, make([]byte, 28))
+			require.NoError(t, err)
+			offsets = append(offsets, offset)
+		}
+		return offsets
+	}
+
+	// Force a compaction.
+	require.NoError(t, l.Clean())
+	require.Equal(t, []int64{2, 3, 4, 5, 6, 7, 8}, offsets())
+
+	// Lowering the ratio compacts the half-dirty segment.
+	l.compactCleaner.MinDirtyRatio = 0.5
+	require.NoError(t, l.Clean())
+	require.Equal(t, []int64{3, 4, 5, 6, 7, 8}, offsets())
+}
+
+// Ensure CompactStats reports the bytes reclaimed, the dirty ratio, and when
+// compaction last ran.
+func TestCompactCleanerStats(t *testing.T) {
+	opts := Options{
+		Path:            tempDir(t),
+		MaxSegmentBytes: 100,
+		Compact:         true,
+	}
+	l, cleanup := setupWithOptions(t, opts)
+	defer cleanup()
+
+	// Nothing was compacted yet.
+	require.Equal(t, CompactStats{}, l.CompactStats())
+
+	// Append some messages. Each segment holds two of them, and six of the
+	// eight outside of the active segment are superseded.
+	entries := []keyValue{
+		{[]byte("foo"), []byte("first")},
+		{[]byte("bar"), []byte("first")},
+		{[]byte("foo"), []byte("second")},
+		{[]byte("foo"), []byte("third")},
+		{[]byte("bar"), []byte("second")},
+		{[]byte("baz"), []byte("first")},
+		{[]byte("baz"), []byte("second")},
+		{[]byte("qux"), []byte("first")},
+		{[]byte("foo"), []byte("fourth")},
+		{[]byte("baz"), []byte("third")},
+	}
+	appendToLog(t, l, entries, true)
+
+	size := func() int64 {
+		var size int64
+		for _, seg := range l.Segments() {
+			size += seg.Position()
+		}
+		return size
+	}
+
+	// Force a compaction.
+	before := size()
+	start := time.Now()
+	require.NoError(t, l.Clean())
+
+	stats := l.CompactStats()
+	require.Equal(t, int64(1), stats.Runs)
+	require.False(t, stats.LastRun.Before(start))
+	require.Equal(t, before-size(), stats.LastReclaimed)
+	require.True(t, stats.LastReclaimed > 0)
+	require.Equal(t, stats.LastReclaimed, stats.BytesReclaimed)
+	require.InDelta(t, 6.0/8.0, stats.DirtyRatio, 0.0001)
+
+	// Compacting again reclaims nothing, but the last run time updates.
+	lastRun, reclaimed := stats.LastRun, stats.BytesReclaimed
+	time.Sleep(time.Millisecond)
+	require.NoError(t, l.Clean())
+
+	stats = l.CompactStats()
+	require.Equal(t, int64(2), stats.Runs)
+	require.True(t, stats.LastRun.After(lastRun))
+	require.Equal(t, int64(0), stats.LastReclaimed)
+	require.Equal(t, reclaimed, stats.BytesReclaimed)
+	require.Equal(t, 0.0, stats.DirtyRatio)
+}
+
 func BenchmarkClean1GBSegments(b *testing.B) {
 	benchmarkClean(b, 1024*1024*1024)
 }
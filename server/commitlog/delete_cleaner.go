@@ -20,6 +20,12 @@ type deleteCleanerOptions struct {
 		Bytes    int64
 		Messages int64
 		Age      time.Duration
+		Segments int
+
+		// ReaderMaxDelay is the max time deletion of a segment is deferred
+		// while active readers still need it. Zero means deletion is never
+		// deferred.
+		ReaderMaxDelay time.Duration
 	}
 	Logger logger.Logger
 	Name   string
@@ -29,17 +35,27 @@ type deleteCleanerOptions struct {
 // segments based on the retention policy.
 type deleteCleaner struct {
 	deleteCleanerOptions
+
+	// readerOffset returns the lowest offset active readers will read next
+	// or -1 if there are no active readers. It's only used when
+	// Retention.ReaderMaxDelay is set.
+	readerOffset func() int64
+
+	// deferred maps the base offset of each segment whose deletion is
+	// deferred for readers to when it was first deferred.
+	deferred map[int64]int64
 }
 
 // newDeleteCleaner returns a new cleaner which enforces log retention
 // policies by deleting segments.
 func newDeleteCleaner(opts deleteCleanerOptions) *deleteCleaner {
-	return &deleteCleaner{opts}
+	return &deleteCleaner{deleteCleanerOptions: opts, deferred: make(map[int64]int64)}
 }
 
 // Clean will enforce the log retention policy by deleting old segments.
-// Deletion only occurs at the segment granularity.
-func (c *deleteCleaner) Clean(segments []*segment) ([]*segment, error) {
+// Deletion only occurs at the segment granularity. The HW is used to ensure
+// the segments limit does not delete uncommitted messages.
+func (c *deleteCleaner) Clean(hw int64, segments []*segment) ([]*segment, error) {
 	var err error
 	if len(segments) == 0 || c.noRetentionLimits() {
 		return segments, nil
@@ -64,7 +80,7 @@ func (c *deleteCleaner) Clean(segments []*segment) ([]*segment, error) {
 		}
 	}
 
-	// Lastly limit by number of bytes.
+	// Next limit by number of bytes.
 	if c.Retention.Bytes > 0 {
 		segments, err = c.applyBytesLimit(segments)
 		if err != nil {
@@ -72,11 +88,79 @@ func (c *deleteCleaner) Clean(segments []*segment) ([]*segment, error) {
 		}
 	}
 
+	// Lastly limit by number of segments.
+	if c.Retention.Segments > 0 {
+		segments, err = c.applySegmentsLimit(hw, segments)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to apply segments retention limit")
+		}
+	}
+
+	// Forget deferred segments which have since been removed.
+	for baseOffset := range c.deferred {
+		if baseOffset < segments[0].BaseOffset {
+			delete(c.deferred, baseOffset)
+		}
+	}
+
 	return segments, nil
 }
 
+// deleteSegments deletes the given segments, oldest first, and returns the
+// number deleted. Deletion stops at the first segment active readers still
+// need unless its deletion has been deferred for Retention.ReaderMaxDelay.
+func (c *deleteCleaner) deleteSegments(segments []*segment) (int, error) {
+	readerOffset := int64(-1)
+	if c.Retention.ReaderMaxDelay > 0 && c.readerOffset != nil {
+		readerOffset = c.readerOffset()
+	}
+	var (
+		now   = timestamp()
+		ready = len(segments)
+	)
+	if readerOffset != -1 {
+		// Start the deferral of every segment readers need when retention
+		// first wants to delete it so that none are held longer than the max
+		// delay.
+		for i := len(segments) - 1; i > -1; i-- {
+			seg := segments[i]
+			if seg.NextOffset() <= readerOffset {
+				break
+			}
+			since, ok := c.deferred[seg.BaseOffset]
+			if !ok {
+				c.deferred[seg.BaseOffset] = now
+				since = now
+			}
+			if now-since < int64(c.Retention.ReaderMaxDelay) {
+				ready = i
+			}
+		}
+		if ready < len(segments) {
+			c.Logger.Debugf("Deferring deletion of %d segments of log %s for active readers",
+				len(segments)-ready, c.Name)
+		}
+	}
+	for i, seg := range segments[:ready] {
+		if readerOffset != -1 && seg.NextOffset() > readerOffset {
+			c.Logger.Warnf("Deleting segment %d of log %s which active readers still need "+
+				"after deferring deletion for %s", seg.BaseOffset, c.Name, c.Retention.ReaderMaxDelay)
+		}
+		delete(c.deferred, seg.BaseOffset)
+		// TODO: There is an edge case here where we fail partway through
+		// deletion. We will delete some segments but return an error. This
+		// should probably mark segments for deletion, remove them from the
+		// read path, and then delete them asynchronously.
+		if err := seg.Delete(); err != nil {
+			return i, err
+		}
+	}
+	return ready, nil
+}
+
 func (c *deleteCleaner) noRetentionLimits() bool {
-	return c.Retention.Bytes == 0 && c.Retention.Messages == 0 && c.Retention.Age == 0
+	return c.Retention.Bytes == 0 && c.Retention.Messages == 0 && c.Retention.Age == 0 &&
+		c.Retention.Segments == 0
 }
 
 func (c *deleteCleaner) applyMessagesLimit(segments []*segment) ([]*segment, error) {
@@ -101,15 +185,11 @@ func (c *deleteCleaner) applyMessagesLimit(segments []*segment) ([]*segment, err
 		cleanedSegments = append([]*segment{s}, cleanedSegments...)
 	}
 	if i > -1 {
-		for ; i > -1; i-- {
-			// TODO: There is an edge case here where we fail partway through
-			// deletion. We will delete some segments but return an error. This
-			// should probably mark segments for deletion, remove them from the
-			// read path, and then delete them asynchronously.
-			if err := segments[i].Delete(); err != nil {
-				return nil, err
-			}
+		deleted, err := c.deleteSegments(segments[:i+1])
+		if err != nil {
+			return nil, err
 		}
+		cleanedSegments = segments[deleted:]
 	}
 
 	return cleanedSegments, nil
@@ -135,16 +215,11 @@ func (c *deleteCleaner) applyBytesLimit(segments []*segment) ([]*segment, error)
 			cleanedSegments = append([]*segment{s}, cleanedSegments...)
 		}
 		if i > -1 {
-			for ; i > -1; i-- {
-				// TODO: There is an edge case here where we fail partway
-				// through deletion. We will delete some segments but return an
-				// error. This should probably mark segments for deletion,
-				// remove them from the read path, and then delete them
-				// asynchronously.
-				if err := segments[i].Delete(); err != nil {
-					return nil, err
-				}
+			deleted, err := c.deleteSegments(segments[:i+1])
+			if err != nil {
+				return nil, err
 			}
+			cleanedSegments = segments[deleted:]
 		}
 	}
 
@@ -165,19 +240,35 @@ func (c *deleteCleaner) applyAgeLimit(segments []*segment) ([]*segment, error) {
 	// Delete all segments whose last-written timestamp is less than the TTL
 	// with the exception of the active (last) segment.
 	for i, seg := range segments {
-		if i != len(segments)-1 && seg.lastWriteTime < ttl {
-			// TODO: There is an edge case here where we fail partway through
-			// deletion. We will delete some segments but return an error. This
-			// should probably mark segments for deletion, remove them from the
-			// read path, and then delete them asynchronously.
-			if err := seg.Delete(); err != nil {
-				return nil, err
-			}
-		} else {
+		if i == len(segments)-1 || seg.lastWriteTime >= ttl {
 			idx = i
 			break
 		}
 	}
+	deleted, err := c.deleteSegments(segments[:idx])
+	if err != nil {
+		return nil, err
+	}
+
+	return segments[deleted:], nil
+}
+
+func (c *deleteCleaner) applySegmentsLimit(hw int64, segments []*segment) ([]*segment, error) {
+	// Delete the oldest segments until we are within the limit. A segment is
+	// only deleted if all of its messages have been committed, i.e. are below
+	// the HW, so that messages which have not been fully replicated are never
+	// removed.
+	var idx int
+	for len(segments)-idx > c.Retention.Segments {
+		if segments[idx].NextOffset()-1 > hw {
+			break
+		}
+		idx++
+	}
+	deleted, err := c.deleteSegments(segments[:idx])
+	if err != nil {
+		return nil, err
+	}
 
-	return segments[idx:], nil
+	return segments[deleted:], nil
 }
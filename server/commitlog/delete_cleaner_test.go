@@ -17,7 +17,7 @@ func noopLogger() logger.Logger {
 }
 
 func createSegment(t require.TestingT, dir string, baseOffset, maxBytes int64) *segment {
-	s, err := newSegment(dir, baseOffset, maxBytes, false, "")
+	s, err := newSegment(dir, baseOffset, maxBytes, 0, IndexAccessMmap, 0, false, "")
 	require.NoError(t, err)
 	return s
 }
@@ -27,7 +27,7 @@ func TestDeleteCleanerNoSegments(t *testing.T) {
 	opts := deleteCleanerOptions{Name: "foo", Logger: noopLogger()}
 	opts.Retention.Bytes = 100
 	cleaner := newDeleteCleaner(opts)
-	segments, err := cleaner.Clean(nil)
+	segments, err := cleaner.Clean(-1, nil)
 	require.NoError(t, err)
 	require.Nil(t, segments)
 }
@@ -40,7 +40,7 @@ func TestDeleteCleanerNoRetentionSet(t *testing.T) {
 	defer remove(t, dir)
 
 	expected := []*segment{createSegment(t, dir, 0, 100)}
-	actual, err := cleaner.Clean(expected)
+	actual, err := cleaner.Clean(-1, expected)
 	require.NoError(t, err)
 	require.Equal(t, expected, actual)
 }
@@ -54,7 +54,7 @@ func TestDeleteCleanerOneSegment(t *testing.T) {
 	defer remove(t, dir)
 
 	expected := []*segment{createSegment(t, dir, 0, 100)}
-	actual, err := cleaner.Clean(expected)
+	actual, err := cleaner.Clean(-1, expected)
 	require.NoError(t, err)
 	require.Equal(t, expected, actual)
 }
@@ -72,7 +72,7 @@ func TestDeleteCleanerBytes(t *testing.T) {
 		segs[i] = createSegment(t, dir, int64(i), 20)
 		writeToSegment(t, segs[i], int64(i), []byte("blah"))
 	}
-	actual, err := cleaner.Clean(segs)
+	actual, err := cleaner.Clean(-1, segs)
 	require.NoError(t, err)
 	require.Len(t, actual, 2)
 	require.Equal(t, int64(3), actual[0].BaseOffset)
@@ -92,7 +92,7 @@ func TestDeleteCleanerBytesBelowLimit(t *testing.T) {
 	for i := 0; i < 5; i++ {
 		expected[i] = createSegment(t, dir, int64(i), 20)
 	}
-	actual, err := cleaner.Clean(expected)
+	actual, err := cleaner.Clean(-1, expected)
 	require.NoError(t, err)
 	require.Equal(t, expected, actual)
 }
@@ -110,7 +110,7 @@ func TestDeleteCleanerMessages(t *testing.T) {
 		segs[i] = createSegment(t, dir, int64(i), 20)
 		writeToSegment(t, segs[i], int64(i), []byte("blah"))
 	}
-	actual, err := cleaner.Clean(segs)
+	actual, err := cleaner.Clean(-1, segs)
 	require.NoError(t, err)
 	require.Len(t, actual, 10)
 	for i := 0; i < 10; i++ {
@@ -131,7 +131,7 @@ func TestDeleteCleanerMessagesBelowLimit(t *testing.T) {
 	for i := 0; i < 5; i++ {
 		expected[i] = createSegment(t, dir, int64(i), 20)
 	}
-	actual, err := cleaner.Clean(expected)
+	actual, err := cleaner.Clean(-1, expected)
 	require.NoError(t, err)
 	require.Equal(t, expected, actual)
 }
@@ -150,7 +150,7 @@ func TestDeleteCleanerBytesMessages(t *testing.T) {
 		segs[i] = createSegment(t, dir, int64(i), 20)
 		writeToSegment(t, segs[i], int64(i), []byte("blah"))
 	}
-	actual, err := cleaner.Clean(segs)
+	actual, err := cleaner.Clean(-1, segs)
 	require.NoError(t, err)
 	require.Len(t, actual, 5)
 	for i := 0; i < 5; i++ {
@@ -182,7 +182,7 @@ func TestDeleteCleanerAge(t *testing.T) {
 		require.NoError(t, err)
 		require.NoError(t, segs[i].WriteMessageSet(ms, entries))
 	}
-	actual, err := cleaner.Clean(segs)
+	actual, err := cleaner.Clean(-1, segs)
 	require.NoError(t, err)
 	require.Len(t, actual, 10)
 	for i := 0; i < 10; i++ {
@@ -215,7 +215,7 @@ func TestDeleteCleanerMessagesBelowAgeLimit(t *testing.T) {
 		require.NoError(t, err)
 		require.NoError(t, expected[i].WriteMessageSet(ms, entries))
 	}
-	actual, err := cleaner.Clean(expected)
+	actual, err := cleaner.Clean(-1, expected)
 	require.NoError(t, err)
 	require.Equal(t, expected, actual)
 }
@@ -241,7 +241,7 @@ func TestDeleteCleanerMessagesCompacted(t *testing.T) {
 	writeToSegment(t, seg2, 15, []byte("blah"))
 
 	segs := []*segment{seg1, seg2}
-	actual, err := cleaner.Clean(segs)
+	actual, err := cleaner.Clean(-1, segs)
 
 	require.NoError(t, err)
 	require.Len(t, actual, 2)
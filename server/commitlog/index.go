@@ -16,6 +16,19 @@ import (
 
 var errIndexCorrupt = errors.New("corrupt index file")
 
+// IndexAccess is how segment index files are accessed.
+type IndexAccess int
+
+const (
+	// IndexAccessMmap memory-maps index files.
+	IndexAccessMmap IndexAccess = iota
+
+	// IndexAccessRead reads and writes index files with positioned file I/O
+	// instead of memory-mapping them, keeping them out of the process's
+	// address space at the cost of a system call per lookup.
+	IndexAccessRead
+)
+
 const (
 	offsetWidth    = 4
 	timestampWidth = 8
@@ -26,7 +39,7 @@ const (
 
 type index struct {
 	options
-	mmap     gommap.MMap
+	mmap     gommap.MMap // nil unless access is IndexAccessMmap
 	file     *os.File
 	size     int64
 	mu       sync.RWMutex
@@ -69,6 +82,7 @@ type options struct {
 	path       string
 	bytes      int64
 	baseOffset int64
+	access     IndexAccess
 }
 
 func newIndex(opts options) (idx *index, err error) {
@@ -103,9 +117,11 @@ func newIndex(opts options) (idx *index, err error) {
 	idx.position = fi.Size()
 	idx.size = fi.Size()
 
-	idx.mmap, err = gommap.Map(idx.file.Fd(), gommap.PROT_READ|gommap.PROT_WRITE, gommap.MAP_SHARED)
-	if err != nil {
-		return nil, errors.Wrap(err, "mmap file failed")
+	if opts.access == IndexAccessMmap {
+		idx.mmap, err = gommap.Map(idx.file.Fd(), gommap.PROT_READ|gommap.PROT_WRITE, gommap.MAP_SHARED)
+		if err != nil {
+			return nil, errors.Wrap(err, "mmap file failed")
+		}
 	}
 	return idx, nil
 }
@@ -133,9 +149,11 @@ func (idx *index) writeEntries(entries []*entry) (err error) {
 		}
 	}
 	idx.mu.Lock()
-	idx.writeAt(b.Bytes(), idx.position)
+	defer idx.mu.Unlock()
+	if _, err = idx.writeAt(b.Bytes(), idx.position); err != nil {
+		return err
+	}
 	idx.position += entryWidth * int64(len(entries))
-	idx.mu.Unlock()
 	return nil
 }
 
@@ -171,11 +189,18 @@ func (idx *index) ReadAt(p []byte, offset int64) (n int, err error) {
 	if idx.position < offset+entryWidth {
 		return 0, io.EOF
 	}
+	if idx.mmap == nil {
+		n, err = idx.file.ReadAt(p[:entryWidth], offset)
+		if err != nil {
+			return n, errors.Wrap(err, "read index file failed")
+		}
+		return n, nil
+	}
 	n = copy(p, idx.mmap[offset:offset+entryWidth])
 	return n, nil
 }
 
-func (idx *index) writeAt(p []byte, offset int64) (n int) {
+func (idx *index) writeAt(p []byte, offset int64) (n int, err error) {
 	// Check if we need to expand the index file.
 	if pSize := int64(len(p)); offset+pSize >= idx.size {
 		// Expand the index file.
@@ -190,13 +215,22 @@ func (idx *index) writeAt(p []byte, offset int64) (n int) {
 		idx.size = newSize
 
 		// Re-mmap the index.
-		idx.mmap, err = gommap.Map(idx.file.Fd(), gommap.PROT_READ|gommap.PROT_WRITE, gommap.MAP_SHARED)
-		if err != nil {
-			panic(errors.Wrap(err, "failed to mmap expanded index file"))
+		if idx.mmap != nil {
+			idx.mmap, err = gommap.Map(idx.file.Fd(), gommap.PROT_READ|gommap.PROT_WRITE, gommap.MAP_SHARED)
+			if err != nil {
+				panic(errors.Wrap(err, "failed to mmap expanded index file"))
+			}
 		}
 	}
 
-	return copy(idx.mmap[offset:], p)
+	if idx.mmap == nil {
+		n, err = idx.file.WriteAt(p, offset)
+		if err != nil {
+			return n, errors.Wrap(err, "write index file failed")
+		}
+		return n, nil
+	}
+	return copy(idx.mmap[offset:], p), nil
 }
 
 func (idx *index) Sync() error {
@@ -205,6 +239,9 @@ func (idx *index) Sync() error {
 	if err := idx.file.Sync(); err != nil {
 		return errors.Wrap(err, "file sync failed")
 	}
+	if idx.mmap == nil {
+		return nil
+	}
 	if err := idx.mmap.Sync(gommap.MS_SYNC); err != nil {
 		return errors.Wrap(err, "mmap sync failed")
 	}
@@ -1,7 +1,10 @@
 package commitlog
 
 import (
+	"math/rand"
 	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -78,3 +81,96 @@ func TestIndexExpansion(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, writeEntry, readEntry)
 }
+
+// Ensure indexes accessed with IndexAccessRead return the same entries as
+// memory-mapped indexes, including across expansion and reopening.
+func TestIndexAccessModes(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	const n = 100
+	entries := make([]*entry, n)
+	for i := range entries {
+		entries[i] = &entry{
+			Offset:    int64(1000 + i),
+			Timestamp: int64(i * 10),
+			Position:  int64(i * 64),
+			Size:      64,
+		}
+	}
+
+	results := make(map[IndexAccess][]entry)
+	for _, access := range []IndexAccess{IndexAccessMmap, IndexAccessRead} {
+		path := filepath.Join(dir, strconv.Itoa(int(access))+".idx")
+		// Leave room for only a few entries so the index is expanded.
+		opts := options{path: path, bytes: 4 * entryWidth, baseOffset: 1000, access: access}
+		idx, err := newIndex(opts)
+		require.NoError(t, err)
+		if access == IndexAccessRead {
+			require.Nil(t, idx.mmap)
+		}
+		e, err := idx.InitializePosition()
+		require.NoError(t, err)
+		require.Nil(t, e)
+		for i := 0; i < n; i += 10 {
+			require.NoError(t, idx.writeEntries(entries[i:i+10]))
+		}
+		require.NoError(t, idx.Close())
+
+		idx, err = newIndex(opts)
+		require.NoError(t, err)
+		last, err := idx.InitializePosition()
+		require.NoError(t, err)
+		require.Equal(t, *entries[n-1], *last)
+
+		read := make([]entry, n)
+		for i := range read {
+			require.NoError(t, idx.ReadEntryAtLogOffset(&read[i], int64(i)))
+			require.Equal(t, *entries[i], read[i])
+		}
+		var e2 entry
+		require.Error(t, idx.ReadEntryAtLogOffset(&e2, n))
+		results[access] = read
+		require.NoError(t, idx.Close())
+	}
+	require.Equal(t, results[IndexAccessMmap], results[IndexAccessRead])
+}
+
+// BenchmarkIndexLookup measures the latency of random offset lookups in an
+// index for each access mode.
+func BenchmarkIndexLookup(b *testing.B) {
+	const n = 100000
+	entries := make([]*entry, n)
+	for i := range entries {
+		entries[i] = &entry{Offset: int64(i), Timestamp: int64(i), Position: int64(i * 64), Size: 64}
+	}
+	modes := []struct {
+		name   string
+		access IndexAccess
+	}{
+		{"mmap", IndexAccessMmap},
+		{"read", IndexAccessRead},
+	}
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			dir := tempDir(b)
+			defer os.RemoveAll(dir)
+
+			idx, err := newIndex(options{path: filepath.Join(dir, "test.idx"), access: mode.access})
+			require.NoError(b, err)
+			defer idx.Close()
+			_, err = idx.InitializePosition()
+			require.NoError(b, err)
+			require.NoError(b, idx.writeEntries(entries))
+
+			rng := rand.New(rand.NewSource(1))
+			var e entry
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := idx.ReadEntryAtLogOffset(&e, rng.Int63n(n)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package commitlog
 
+import "time"
+
 // CommitLog is the durable write-ahead log interface used to back each stream.
 type CommitLog interface {
 	// Delete closes the log and removes all data associated with it from the
@@ -14,6 +16,12 @@ type CommitLog interface {
 	// Truncate removes all messages from the log starting at the given offset.
 	Truncate(offset int64) error
 
+	// TrimBefore removes all messages before the given offset from the log,
+	// making it the log's oldest offset. If the log ends before the offset,
+	// all of its messages are removed and subsequent appends start at the
+	// offset.
+	TrimBefore(offset int64) error
+
 	// NewestOffset returns the offset of the last message in the log or -1 if
 	// empty.
 	NewestOffset() int64
@@ -22,6 +30,10 @@ type CommitLog interface {
 	// empty.
 	OldestOffset() int64
 
+	// NewestTimestamp returns the timestamp of the last message in the log or
+	// 0 if empty.
+	NewestTimestamp() int64
+
 	// OffsetForTimestamp returns the earliest offset whose timestamp is
 	// greater than or equal to the given timestamp.
 	OffsetForTimestamp(timestamp int64) (int64, error)
@@ -38,6 +50,27 @@ type CommitLog interface {
 	// HighWatermark returns the high watermark for the log.
 	HighWatermark() int64
 
+	// LastAppendTime returns when messages were last appended to the log or
+	// the zero time if none have been appended since it was opened.
+	LastAppendTime() time.Time
+
+	// LastCommitTime returns when the high watermark last advanced or the
+	// zero time if it hasn't advanced since the log was opened.
+	LastCommitTime() time.Time
+
+	// Sync commits the log's messages and high watermark to stable storage
+	// and returns the high watermark, through which every message is
+	// durable.
+	Sync() (int64, error)
+
+	// SyncStats returns counters for the fsyncs the log has performed since
+	// it was opened.
+	SyncStats() SyncStats
+
+	// CompactStats returns statistics about the compactions the log has run
+	// since it was opened.
+	CompactStats() CompactStats
+
 	// NewLeaderEpoch indicates the log is entering a new leader epoch.
 	NewLeaderEpoch(epoch uint64) error
 
@@ -50,13 +83,21 @@ type CommitLog interface {
 	LastLeaderEpoch() uint64
 
 	// Append writes the given batch of messages to the log and returns their
-	// corresponding offsets in the log.
+	// corresponding offsets in the log. If a byte quota is configured, this
+	// either returns ErrQuotaExceeded or evicts the oldest segments when the
+	// quota would be exceeded, depending on the QuotaPolicy.
 	Append(msg []*Message) ([]int64, error)
 
 	// AppendMessageSet writes the given message set data to the log and
-	// returns the corresponding offsets in the log.
+	// returns the corresponding offsets in the log. If the QuotaPolicy is
+	// QuotaPolicyEvict, the oldest segments are evicted as by Append.
 	AppendMessageSet(ms []byte) ([]int64, error)
 
+	// QuotaExceeded indicates if the log has a byte quota with the
+	// QuotaPolicyReject policy and no room is left under it, i.e. Append
+	// would return ErrQuotaExceeded for any messages.
+	QuotaExceeded() bool
+
 	// Clean applies retention and compaction rules against the log, if
 	// applicable.
 	Clean() error
@@ -68,6 +109,21 @@ type CommitLog interface {
 	// for data.
 	NotifyLEO(waiter interface{}, leo int64) <-chan struct{}
 
+	// NotifyHW registers and returns a channel which is closed when the high
+	// watermark changes from the given value. If the high watermark is no
+	// longer the given value, the channel is closed immediately. Waiter is an
+	// opaque value that uniquely identifies the entity waiting for the change.
+	NotifyHW(waiter interface{}, hw int64) <-chan struct{}
+
+	// Verify scans the log's segments, checking message checksums and offset
+	// continuity, and returns a report of any corrupt or missing offsets.
+	Verify() (*VerifyReport, error)
+
+	// Positions returns the segment file and byte position of each message
+	// in the log from start through end, inclusive, e.g. for building
+	// external indexes.
+	Positions(start, end int64) ([]*OffsetPosition, error)
+
 	// Close closes each log segment file and stops the background goroutine
 	// checkpointing the high watermark to disk.
 	Close() error
@@ -149,3 +149,113 @@ func (ms messageSet) Message() SerializedMessage {
 	size := ms.Size()
 	return SerializedMessage(ms[msgSetHeaderLen : msgSetHeaderLen+size])
 }
+
+// minMessageSize is the size of a serialized message with no key, value, or
+// headers: CRC, magic byte, attributes, key and value lengths, and header
+// count.
+const minMessageSize = 4 + 1 + 1 + 4 + 4 + 2
+
+// EncodeMessageSet frames the given messages in the log's message set format,
+// i.e. each serialized message is preceded by its offset, timestamp, leader
+// epoch, and size. Offsets are assigned sequentially starting at 0. This is
+// the format DecodeMessageSet expects, e.g. for importing messages from
+// another system.
+func EncodeMessageSet(msgs []*Message) ([]byte, error) {
+	ms, _, err := newMessageSetFromProto(0, 0, msgs)
+	return ms, err
+}
+
+// DecodeMessageSet validates the framing and CRCs of the given message set
+// data and returns the messages it contains with their keys, values, headers,
+// and timestamps. The offsets and leader epochs in the data are ignored since
+// they are assigned when the messages are appended to a log. An error is
+// returned if any message is malformed.
+func DecodeMessageSet(data []byte) ([]*Message, error) {
+	var msgs []*Message
+	for i := 0; len(data) > 0; i++ {
+		if len(data) < msgSetHeaderLen {
+			return nil, errors.Errorf("message %d: truncated header", i)
+		}
+		ms := messageSet(data)
+		size := int(ms.Size())
+		if size < minMessageSize || size > len(data)-msgSetHeaderLen {
+			return nil, errors.Errorf("message %d: invalid size %d", i, size)
+		}
+		msg, err := decodeMessage(ms.Message())
+		if err != nil {
+			return nil, errors.Wrapf(err, "message %d", i)
+		}
+		msg.Timestamp = ms.Timestamp()
+		msgs = append(msgs, msg)
+		data = data[msgSetHeaderLen+size:]
+	}
+	return msgs, nil
+}
+
+// decodeMessage decodes the given serialized message, checking its CRC and
+// that its fields exactly fill it. The key, value, and headers are copied so
+// the message does not reference the serialized data.
+func decodeMessage(m SerializedMessage) (*Message, error) {
+	if m.Crc() != crc32.Checksum(m[4:], crc32cTable) {
+		return nil, errors.New("crc didn't match")
+	}
+	n := 6
+	readBytes := func() ([]byte, error) {
+		if len(m)-n < 4 {
+			return nil, errors.New("truncated message")
+		}
+		size := int32(encoding.Uint32(m[n:]))
+		n += 4
+		if size == -1 {
+			return nil, nil
+		}
+		if size < 0 || int(size) > len(m)-n {
+			return nil, errInvalidByteSliceLength
+		}
+		b := make([]byte, size)
+		copy(b, m[n:])
+		n += int(size)
+		return b, nil
+	}
+	key, err := readBytes()
+	if err != nil {
+		return nil, err
+	}
+	value, err := readBytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(m)-n < 2 {
+		return nil, errors.New("truncated message")
+	}
+	numHeaders := int(encoding.Uint16(m[n:]))
+	n += 2
+	headers := make(map[string][]byte, numHeaders)
+	for i := 0; i < numHeaders; i++ {
+		if len(m)-n < 2 {
+			return nil, errors.New("truncated message")
+		}
+		keySize := int(encoding.Uint16(m[n:]))
+		n += 2
+		if keySize > len(m)-n {
+			return nil, errInvalidStringLength
+		}
+		headerKey := string(m[n : n+keySize])
+		n += keySize
+		header, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		headers[headerKey] = header
+	}
+	if n != len(m) {
+		return nil, errors.New("trailing data after message")
+	}
+	return &Message{
+		MagicByte:  m.MagicByte(),
+		Attributes: m.Attributes(),
+		Key:        key,
+		Value:      value,
+		Headers:    headers,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package commitlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Ensure DecodeMessageSet returns the messages framed by EncodeMessageSet with
+// their fields intact and rejects malformed framing.
+func TestDecodeMessageSet(t *testing.T) {
+	msgs := []*Message{
+		{
+			MagicByte: 1,
+			Key:       []byte("key"),
+			Value:     []byte("hello"),
+			Headers:   map[string][]byte{"foo": []byte("bar"), "empty": nil},
+			Timestamp: 1000,
+		},
+		{
+			MagicByte: 1,
+			Value:     []byte("world"),
+			Headers:   map[string][]byte{},
+			Timestamp: 2000,
+		},
+	}
+	data, err := EncodeMessageSet(msgs)
+	require.NoError(t, err)
+
+	decoded, err := DecodeMessageSet(data)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+	for i, msg := range decoded {
+		require.Equal(t, msgs[i].MagicByte, msg.MagicByte)
+		require.Equal(t, msgs[i].Key, msg.Key)
+		require.Equal(t, msgs[i].Value, msg.Value)
+		require.Equal(t, msgs[i].Headers, msg.Headers)
+		require.Equal(t, msgs[i].Timestamp, msg.Timestamp)
+	}
+
+	// Empty data contains no messages.
+	decoded, err = DecodeMessageSet(nil)
+	require.NoError(t, err)
+	require.Empty(t, decoded)
+
+	// Truncated data is rejected.
+	_, err = DecodeMessageSet(data[:len(data)-1])
+	require.Error(t, err)
+	_, err = DecodeMessageSet(data[:msgSetHeaderLen-1])
+	require.Error(t, err)
+
+	// A corrupted message is rejected.
+	corrupt := append([]byte(nil), data...)
+	corrupt[msgSetHeaderLen+10] ^= 0xff
+	_, err = DecodeMessageSet(corrupt)
+	require.Error(t, err)
+}
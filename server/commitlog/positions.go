@@ -0,0 +1,88 @@
+package commitlog
+
+import "sort"
+
+// OffsetPosition locates a message in a log's segment files.
+type OffsetPosition struct {
+	Offset   int64
+	Segment  string // Path of the segment log file containing the message
+	Position int64  // Byte position of the message in the segment file
+	Size     int32  // Size of the message in bytes, including its header
+}
+
+// Positions returns the location of each message in the log from start
+// through end, inclusive, as recorded in the segment indexes. Offsets absent
+// from the log, e.g. because they were compacted, are skipped. Buffered writes
+// are written out to the segment files first so the messages can be read
+// directly at the returned positions. Positions are only valid until the
+// segment is compacted or deleted by retention.
+func (l *commitLog) Positions(start, end int64) ([]*OffsetPosition, error) {
+	// Hold the clean lock so retention and compaction do not swap segments
+	// out while the indexes are read.
+	l.cleanMu.Lock()
+	defer l.cleanMu.Unlock()
+
+	if oldest := l.OldestOffset(); start < oldest {
+		start = oldest
+	}
+	var positions []*OffsetPosition
+	for _, seg := range l.Segments() {
+		if seg.BaseOffset > end {
+			break
+		}
+		if last := seg.LastOffset(); last < start {
+			continue
+		}
+		segPositions, err := seg.positions(start, end)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, segPositions...)
+	}
+	return positions, nil
+}
+
+// positions returns the location of each message in the segment from start
+// through end, inclusive.
+func (s *segment) positions(start, end int64) ([]*OffsetPosition, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return nil, ErrSegmentClosed
+	}
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+	var (
+		positions []*OffsetPosition
+		e         = new(entry)
+		readErr   error
+		n         = int(s.Index.Position() / entryWidth)
+	)
+	// Binary search the index for the first entry in the range.
+	i := sort.Search(n, func(i int) bool {
+		if err := s.Index.ReadEntryAtFileOffset(e, int64(i*entryWidth)); err != nil {
+			readErr = err
+			return true
+		}
+		return e.Offset >= start
+	})
+	if readErr != nil {
+		return nil, readErr
+	}
+	for ; i < n; i++ {
+		if err := s.Index.ReadEntryAtFileOffset(e, int64(i*entryWidth)); err != nil {
+			return nil, err
+		}
+		if e.Offset > end {
+			break
+		}
+		positions = append(positions, &OffsetPosition{
+			Offset:   e.Offset,
+			Segment:  s.logPath(),
+			Position: e.Position,
+			Size:     e.Size,
+		})
+	}
+	return positions, nil
+}
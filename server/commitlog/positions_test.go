@@ -0,0 +1,59 @@
+package commitlog
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Ensure Positions locates each message in the range within the segment
+// files, including messages which are still buffered.
+func TestPositions(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{
+		Path:            tempDir(t),
+		MaxSegmentBytes: 100,
+		WriteBufferSize: 4096,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		_, err := l.Append([]*Message{{
+			Value:       []byte(strconv.Itoa(i)),
+			Timestamp:   time.Now().UnixNano(),
+			LeaderEpoch: 1,
+		}})
+		require.NoError(t, err)
+	}
+	require.True(t, len(l.Segments()) > 1)
+
+	positions, err := l.Positions(2, 8)
+	require.NoError(t, err)
+	require.Len(t, positions, 7)
+	segments := make(map[string]struct{})
+	for i, pos := range positions {
+		offset := int64(i + 2)
+		require.Equal(t, offset, pos.Offset)
+		segments[pos.Segment] = struct{}{}
+
+		// Read the message directly from the segment file.
+		f, err := os.Open(pos.Segment)
+		require.NoError(t, err)
+		buf := make([]byte, pos.Size)
+		_, err = f.ReadAt(buf, pos.Position)
+		f.Close()
+		require.NoError(t, err)
+		ms := messageSet(buf)
+		require.Equal(t, offset, ms.Offset())
+		require.Equal(t, []byte(strconv.FormatInt(offset, 10)), ms.Message().Value())
+	}
+	require.True(t, len(segments) > 1)
+
+	// Offsets outside the log are skipped.
+	positions, err = l.Positions(8, 20)
+	require.NoError(t, err)
+	require.Len(t, positions, 2)
+}
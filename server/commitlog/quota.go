@@ -0,0 +1,91 @@
+package commitlog
+
+import "github.com/pkg/errors"
+
+// ErrQuotaExceeded is returned by Append when writing the messages would
+// exceed the log's byte quota and the quota policy is QuotaPolicyReject.
+var ErrQuotaExceeded = errors.New("log quota exceeded")
+
+// QuotaPolicy determines what happens when an append would exceed the log's
+// byte quota.
+type QuotaPolicy int
+
+const (
+	// QuotaPolicyReject rejects appends which would exceed the quota.
+	QuotaPolicyReject QuotaPolicy = iota
+
+	// QuotaPolicyEvict deletes the oldest log segments to make room for
+	// appends which would exceed the quota.
+	QuotaPolicyEvict
+)
+
+// enforceQuota ensures there is room in the log for size more bytes. If the
+// quota policy is QuotaPolicyReject, this returns ErrQuotaExceeded if there is
+// not enough room. If the policy is QuotaPolicyEvict, the oldest segments are
+// deleted until there is room. Only segments whose messages have all been
+// committed are evicted, so the quota may be exceeded while the oldest
+// segments contain uncommitted messages. The active segment is never evicted.
+func (l *commitLog) enforceQuota(size int64) error {
+	if l.QuotaMaxBytes <= 0 {
+		return nil
+	}
+	if l.QuotaPolicy == QuotaPolicyReject {
+		if l.totalBytes()+size > l.QuotaMaxBytes {
+			return ErrQuotaExceeded
+		}
+		return nil
+	}
+
+	// Serialize with the cleaner so evicted segments are not added back when
+	// it replaces the segments.
+	l.cleanMu.Lock()
+	defer l.cleanMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var (
+		total = size
+		idx   int
+	)
+	for _, seg := range l.segments {
+		total += seg.Position()
+	}
+	for total > l.QuotaMaxBytes && idx < len(l.segments)-1 {
+		seg := l.segments[idx]
+		if seg.NextOffset()-1 > l.hw {
+			break
+		}
+		total -= seg.Position()
+		if err := seg.Delete(); err != nil {
+			return err
+		}
+		idx++
+	}
+	if idx == 0 {
+		return nil
+	}
+	l.Logger.Debugf("Evicted %d segments from log %s to stay within quota", idx, l.Path)
+	l.segments = l.segments[idx:]
+	return l.leaderEpochCache.ClearEarliest(l.segments[0].BaseOffset)
+}
+
+// QuotaExceeded indicates if the log has a byte quota with the
+// QuotaPolicyReject policy and no room is left under it, i.e. Append would
+// return ErrQuotaExceeded for any messages.
+func (l *commitLog) QuotaExceeded() bool {
+	if l.QuotaMaxBytes <= 0 || l.QuotaPolicy != QuotaPolicyReject {
+		return false
+	}
+	return l.totalBytes() >= l.QuotaMaxBytes
+}
+
+// totalBytes returns the number of bytes in the log's segments.
+func (l *commitLog) totalBytes() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var total int64
+	for _, seg := range l.segments {
+		total += seg.Position()
+	}
+	return total
+}
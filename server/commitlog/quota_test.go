@@ -0,0 +1,152 @@
+package commitlog
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func appendQuotaMessageSet(t *testing.T, l *commitLog, i int) error {
+	ms, _, err := newMessageSetFromProto(int64(i), 0, []*Message{{
+		Value:       []byte(strconv.Itoa(i)),
+		Timestamp:   time.Now().UnixNano(),
+		LeaderEpoch: 1,
+	}})
+	require.NoError(t, err)
+	_, err = l.AppendMessageSet(ms)
+	return err
+}
+
+func appendQuotaMessage(t *testing.T, l *commitLog, i int) error {
+	_, err := l.Append([]*Message{{
+		Value:       []byte(strconv.Itoa(i)),
+		Timestamp:   time.Now().UnixNano(),
+		LeaderEpoch: 1,
+	}})
+	return err
+}
+
+// Ensure Append returns ErrQuotaExceeded once the quota is reached when the
+// quota policy is reject.
+func TestQuotaReject(t *testing.T) {
+	dir := tempDir(t)
+	defer remove(t, dir)
+
+	// Determine the size of a single message.
+	l, cleanup := setupWithOptions(t, Options{Path: dir})
+	require.NoError(t, appendQuotaMessage(t, l, 0))
+	size := l.totalBytes()
+	require.NoError(t, l.Close())
+	cleanup()
+
+	l, cleanup = setupWithOptions(t, Options{
+		Path:          tempDir(t),
+		QuotaMaxBytes: 3 * size,
+		QuotaPolicy:   QuotaPolicyReject,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		require.False(t, l.QuotaExceeded())
+		require.NoError(t, appendQuotaMessage(t, l, i))
+	}
+	require.True(t, l.QuotaExceeded())
+	require.Equal(t, ErrQuotaExceeded, appendQuotaMessage(t, l, 3))
+	require.Equal(t, int64(0), l.OldestOffset())
+	require.Equal(t, int64(2), l.NewestOffset())
+	require.Equal(t, 3*size, l.totalBytes())
+}
+
+// Ensure Append evicts the oldest committed segments once the quota is reached
+// when the quota policy is evict.
+func TestQuotaEvict(t *testing.T) {
+	dir := tempDir(t)
+	defer remove(t, dir)
+
+	// Determine the size of a single message.
+	l, cleanup := setupWithOptions(t, Options{Path: dir})
+	require.NoError(t, appendQuotaMessage(t, l, 0))
+	size := l.totalBytes()
+	require.NoError(t, l.Close())
+	cleanup()
+
+	l, cleanup = setupWithOptions(t, Options{
+		Path:            tempDir(t),
+		MaxSegmentBytes: 6,
+		QuotaMaxBytes:   3 * size,
+		QuotaPolicy:     QuotaPolicyEvict,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	// Uncommitted messages are not evicted.
+	for i := 0; i < 5; i++ {
+		require.NoError(t, appendQuotaMessage(t, l, i))
+	}
+	require.Equal(t, int64(0), l.OldestOffset())
+	require.Equal(t, 5*size, l.totalBytes())
+
+	l.SetHighWatermark(4)
+	for i := 5; i < 10; i++ {
+		require.NoError(t, appendQuotaMessage(t, l, i))
+		l.SetHighWatermark(int64(i))
+	}
+	require.Equal(t, int64(7), l.OldestOffset())
+	require.Equal(t, int64(9), l.NewestOffset())
+	require.Equal(t, 3*size, l.totalBytes())
+}
+
+// Ensure AppendMessageSet evicts the oldest committed segments like Append
+// when the quota policy is evict so follower logs stay the same as the
+// leader's.
+func TestQuotaEvictAppendMessageSet(t *testing.T) {
+	dir := tempDir(t)
+	defer remove(t, dir)
+
+	// Determine the size of a single message.
+	l, cleanup := setupWithOptions(t, Options{Path: dir})
+	require.NoError(t, appendQuotaMessage(t, l, 0))
+	size := l.totalBytes()
+	require.NoError(t, l.Close())
+	cleanup()
+
+	l, cleanup = setupWithOptions(t, Options{
+		Path:            tempDir(t),
+		MaxSegmentBytes: 6,
+		QuotaMaxBytes:   3 * size,
+		QuotaPolicy:     QuotaPolicyEvict,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, appendQuotaMessageSet(t, l, i))
+		l.SetHighWatermark(int64(i))
+	}
+	require.Equal(t, int64(7), l.OldestOffset())
+	require.Equal(t, int64(9), l.NewestOffset())
+	require.Equal(t, 3*size, l.totalBytes())
+}
+
+// Ensure AppendMessageSet never rejects messages when the quota policy is
+// reject since they were already accepted by the leader.
+func TestQuotaRejectAppendMessageSet(t *testing.T) {
+	dir := tempDir(t)
+	defer remove(t, dir)
+
+	l, cleanup := setupWithOptions(t, Options{
+		Path:          dir,
+		QuotaMaxBytes: 1,
+		QuotaPolicy:   QuotaPolicyReject,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, appendQuotaMessageSet(t, l, i))
+	}
+	require.Equal(t, int64(2), l.NewestOffset())
+}
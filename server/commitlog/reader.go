@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"sync"
+	"sync/atomic"
 
 	pkgErrors "github.com/pkg/errors"
 )
@@ -14,10 +15,11 @@ type contextReader interface {
 }
 
 // Reader reads messages atomically from a CommitLog. Readers should not be
-// used concurrently.
+// used concurrently, and they should be closed when no longer needed so that
+// retention does not wait on them.
 type Reader struct {
 	ctxReader   contextReader
-	offset      int64
+	offset      int64 // Accessed atomically
 	log         *commitLog
 	uncommitted bool
 }
@@ -35,12 +37,22 @@ func (l *commitLog) NewReader(offset int64, uncommitted bool) (*Reader, error) {
 	} else {
 		ctxReader, err = l.newReaderCommitted(offset)
 	}
-	return &Reader{
+	r := &Reader{
 		ctxReader:   ctxReader,
 		offset:      offset,
 		log:         l,
 		uncommitted: uncommitted,
-	}, err
+	}
+	if err == nil {
+		l.addReader(r)
+	}
+	return r, err
+}
+
+// Close releases the Reader so that retention no longer defers deleting the
+// segments it has yet to read.
+func (r *Reader) Close() {
+	r.log.removeReader(r)
 }
 
 // ReadMessage reads a single message from the underlying CommitLog or blocks
@@ -63,23 +75,105 @@ RETRY:
 			// ErrSegmentReplaced indicates we attempted to read from a log
 			// segment that was replaced due to compaction, so reinitialize the
 			// contextReader and try again to read from the new segment.
-			if r.uncommitted {
-				r.ctxReader, err = r.log.newReaderUncommitted(r.offset)
-			} else {
-				r.ctxReader, err = r.log.newReaderCommitted(r.offset)
+			if err := r.reinitialize(); err != nil {
+				return nil, 0, 0, 0, err
 			}
-			if err != nil {
-				return nil, 0, 0, 0, pkgErrors.Wrap(err, "failed to reinitialize reader")
+			goto RETRY
+		} else if pkgErrors.Cause(err) == ErrSegmentClosed && !r.log.isClosed() {
+			// ErrSegmentClosed on an open log indicates the segment was
+			// deleted by retention. If the reader still needed it, the
+			// messages it has yet to read are gone. Otherwise, it was done
+			// with the segment, so continue from the next one.
+			if r.log.truncatedBefore(atomic.LoadInt64(&r.offset)) {
+				return nil, 0, 0, 0, ErrOffsetTruncated
+			}
+			if err := r.reinitialize(); err != nil {
+				return nil, 0, 0, 0, err
 			}
 			goto RETRY
 		} else {
 			return nil, 0, 0, 0, err
 		}
 	}
-	r.offset = offset + 1
+	atomic.StoreInt64(&r.offset, offset+1)
 	return msg, offset, timestamp, leaderEpoch, err
 }
 
+// ReadResult is a message read from a CommitLog by a Reader along with its
+// offset, timestamp, and leader epoch.
+type ReadResult struct {
+	Message     SerializedMessage
+	Offset      int64
+	Timestamp   int64
+	LeaderEpoch uint64
+}
+
+// Messages reads messages from the underlying CommitLog in a goroutine and
+// delivers them in order on the returned channel, which simplifies building
+// processors without a ReadMessage loop. If stopAtHW is true, the channel is
+// closed after the message at the log's high watermark at the time of the call
+// has been delivered, or immediately if the Reader starts past it. Otherwise,
+// it keeps delivering messages as they are added to the log. The channel is
+// also closed when the Context is done or reading fails, in which case the
+// error is delivered on the returned error channel. The error channel is
+// closed after the message channel and does not report the Context being
+// done. The Reader must not be used otherwise until the message channel is
+// closed.
+func (r *Reader) Messages(ctx context.Context, stopAtHW bool) (<-chan *ReadResult, <-chan error) {
+	var (
+		msgs  = make(chan *ReadResult)
+		errCh = make(chan error, 1)
+		hw    = r.log.HighWatermark()
+	)
+	go func() {
+		defer close(errCh)
+		defer close(msgs)
+		headersBuf := make([]byte, msgSetHeaderLen)
+		for {
+			if stopAtHW && atomic.LoadInt64(&r.offset) > hw {
+				return
+			}
+			msg, offset, timestamp, leaderEpoch, err := r.ReadMessage(ctx, headersBuf)
+			if err != nil {
+				if ctx.Err() == nil {
+					errCh <- err
+				}
+				return
+			}
+			if stopAtHW && offset > hw {
+				// The message at the HW was removed, e.g. by compaction.
+				return
+			}
+			select {
+			case msgs <- &ReadResult{
+				Message:     msg,
+				Offset:      offset,
+				Timestamp:   timestamp,
+				LeaderEpoch: leaderEpoch,
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return msgs, errCh
+}
+
+// reinitialize recreates the contextReader at the Reader's next offset after
+// the segment it was reading was replaced or deleted.
+func (r *Reader) reinitialize() error {
+	var (
+		offset = atomic.LoadInt64(&r.offset)
+		err    error
+	)
+	if r.uncommitted {
+		r.ctxReader, err = r.log.newReaderUncommitted(offset)
+	} else {
+		r.ctxReader, err = r.log.newReaderCommitted(offset)
+	}
+	return pkgErrors.Wrap(err, "failed to reinitialize reader")
+}
+
 type uncommittedReader struct {
 	cl  *commitLog
 	seg *segment
@@ -350,6 +350,88 @@ func TestReaderCommittedReadToHW(t *testing.T) {
 	}
 }
 
+// Ensure Messages delivers every message up to the HW in offset order and
+// then closes the channel without an error.
+func TestReaderMessagesStopAtHW(t *testing.T) {
+	for _, test := range segmentSizeTests {
+		t.Run(test.name, func(t *testing.T) {
+			l, cleanup := setupWithOptions(t, Options{
+				Path:            tempDir(t),
+				MaxSegmentBytes: test.segmentSize,
+			})
+			defer l.Close()
+			defer cleanup()
+
+			numMsgs := 10
+			msgs := make([]*Message, numMsgs)
+			for i := 0; i < numMsgs; i++ {
+				msgs[i] = &Message{
+					Value:       []byte(strconv.Itoa(i)),
+					Timestamp:   int64(i),
+					LeaderEpoch: 42,
+				}
+			}
+			_, err := l.Append(msgs)
+			require.NoError(t, err)
+			l.SetHighWatermark(6)
+			r, err := l.NewReader(0, false)
+			require.NoError(t, err)
+			defer r.Close()
+
+			ch, errCh := r.Messages(context.Background(), true)
+			i := 0
+			for result := range ch {
+				require.Equal(t, int64(i), result.Offset)
+				require.Equal(t, int64(i), result.Timestamp)
+				require.Equal(t, uint64(42), result.LeaderEpoch)
+				compareMessages(t, msgs[i], result.Message)
+				i++
+			}
+			require.Equal(t, 7, i)
+			require.NoError(t, <-errCh)
+		})
+	}
+}
+
+// Ensure Messages keeps delivering new messages until the Context is canceled
+// and then closes the channel without an error.
+func TestReaderMessagesCancel(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{Path: tempDir(t)})
+	defer l.Close()
+	defer cleanup()
+
+	_, err := l.Append([]*Message{{Value: []byte("0")}})
+	require.NoError(t, err)
+	l.SetHighWatermark(0)
+	r, err := l.NewReader(0, false)
+	require.NoError(t, err)
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, errCh := r.Messages(ctx, false)
+	require.Equal(t, int64(0), (<-ch).Offset)
+
+	// New messages are delivered as they are committed.
+	_, err = l.Append([]*Message{{Value: []byte("1")}})
+	require.NoError(t, err)
+	l.SetHighWatermark(1)
+	select {
+	case result := <-ch:
+		require.Equal(t, int64(1), result.Offset)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not receive new message")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		require.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Channel was not closed")
+	}
+	require.NoError(t, <-errCh)
+}
+
 func TestReaderCommittedWaitForHW(t *testing.T) {
 	var err error
 	l, cleanup := setupWithOptions(t, Options{
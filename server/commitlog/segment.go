@@ -1,6 +1,7 @@
 package commitlog
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
@@ -17,6 +18,7 @@ const (
 	logSuffix       = ".log"
 	cleanedSuffix   = ".cleaned"
 	truncatedSuffix = ".truncated"
+	trimmedSuffix   = ".trimmed"
 	indexSuffix     = ".index"
 )
 
@@ -42,6 +44,10 @@ var (
 	// that has been deleted.
 	ErrCommitLogDeleted = errors.New("commit log was deleted")
 
+	// ErrOffsetTruncated is returned when a reader's next offset was deleted
+	// by retention before it could be read.
+	ErrOffsetTruncated = errors.New("offset was truncated by retention")
+
 	// timestamp returns the current time in Unix nanoseconds. This function
 	// exists for mocking purposes.
 	timestamp = func() int64 { return time.Now().UnixNano() }
@@ -50,6 +56,7 @@ var (
 type segment struct {
 	writer         io.Writer
 	reader         io.Reader
+	buffer         *bufio.Writer // Buffers writes to log, nil if unbuffered
 	log            *os.File
 	Index          *index
 	BaseOffset     int64
@@ -58,7 +65,12 @@ type segment struct {
 	firstWriteTime int64
 	lastWriteTime  int64
 	position       int64
+	synced         int64 // Position through which the log was last synced
 	maxBytes       int64
+	bufferSize     int
+	indexAccess    IndexAccess
+	indexInterval  int     // Index entries between samples, 0 if not sampled
+	samples        []entry // Every indexInterval-th index entry
 	path           string
 	suffix         string
 	waiters        map[interface{}]chan struct{}
@@ -69,15 +81,20 @@ type segment struct {
 	sync.RWMutex
 }
 
-func newSegment(path string, baseOffset, maxBytes int64, isNew bool, suffix string) (*segment, error) {
+func newSegment(path string, baseOffset, maxBytes int64, bufferSize int, indexAccess IndexAccess,
+	indexInterval int, isNew bool, suffix string) (*segment, error) {
+
 	s := &segment{
-		maxBytes:    maxBytes,
-		BaseOffset:  baseOffset,
-		firstOffset: -1,
-		lastOffset:  -1,
-		path:        path,
-		suffix:      suffix,
-		waiters:     make(map[interface{}]chan struct{}),
+		maxBytes:      maxBytes,
+		bufferSize:    bufferSize,
+		indexAccess:   indexAccess,
+		indexInterval: indexInterval,
+		BaseOffset:    baseOffset,
+		firstOffset:   -1,
+		lastOffset:    -1,
+		path:          path,
+		suffix:        suffix,
+		waiters:       make(map[interface{}]chan struct{}),
 	}
 	// If this is a new segment, ensure the file doesn't already exist.
 	if isNew && exists(s.logPath()) {
@@ -91,23 +108,59 @@ func newSegment(path string, baseOffset, maxBytes int64, isNew bool, suffix stri
 	if err != nil {
 		return nil, errors.Wrap(err, "stat file failed")
 	}
-	s.log = log
 	s.position = info.Size()
-	s.writer = log
-	s.reader = log
+	s.synced = s.position
+	s.setLog(log)
 	err = s.setupIndex()
 	return s, err
 }
 
+// setLog sets the file the segment reads from and writes to. If the segment
+// has a buffer size, writes are buffered in memory up to that size before
+// being written to the file.
+func (s *segment) setLog(log *os.File) {
+	s.log = log
+	s.reader = log
+	s.writer = log
+	s.buffer = nil
+	if s.bufferSize > 0 {
+		s.buffer = bufio.NewWriterSize(log, s.bufferSize)
+		s.writer = s.buffer
+	}
+}
+
+// flush writes any buffered writes to the log file.
+func (s *segment) flush() error {
+	if s.buffer == nil || s.closed {
+		return nil
+	}
+	if err := s.buffer.Flush(); err != nil {
+		return errors.Wrap(err, "log flush failed")
+	}
+	return nil
+}
+
+// isBuffered indicates if any of the log up to the given position is still
+// buffered in memory rather than written to the log file.
+func (s *segment) isBuffered(pos int64) bool {
+	if s.buffer == nil {
+		return false
+	}
+	buffered := int64(s.buffer.Buffered())
+	return buffered > 0 && pos > s.position-buffered
+}
+
 // setupIndex creates and initializes an index.
 // Initialization is:
 // - Initialize index position
 // - Initialize firstOffset/lastOffset
 // - Initialize firstWriteTime/lastWriteTime
+// - Sample the index entries
 func (s *segment) setupIndex() (err error) {
 	s.Index, err = newIndex(options{
 		path:       s.indexPath(),
 		baseOffset: s.BaseOffset,
+		access:     s.indexAccess,
 	})
 	if err != nil {
 		return err
@@ -128,6 +181,18 @@ func (s *segment) setupIndex() (err error) {
 		s.firstOffset = firstEntry.Offset
 		s.firstWriteTime = firstEntry.Timestamp
 	}
+	s.samples = nil
+	if s.indexInterval == 0 {
+		return nil
+	}
+	n := s.Index.CountEntries()
+	for i := int64(0); i < n; i += int64(s.indexInterval) {
+		var sample entry
+		if err := s.Index.ReadEntryAtLogOffset(&sample, i); err != nil {
+			return err
+		}
+		s.samples = append(s.samples, sample)
+	}
 	return nil
 }
 
@@ -159,6 +224,8 @@ func (s *segment) Seal() {
 		return
 	}
 	s.sealed = true
+	// No more writes will fill the buffer, so write out what's left of it.
+	s.flush() // nolint: errcheck
 	// Notify any readers waiting for data.
 	s.notifyWaiters()
 	s.Index.Shrink() // nolint: errcheck
@@ -187,6 +254,14 @@ func (s *segment) LastOffset() int64 {
 	return s.lastOffset
 }
 
+// LastTimestamp returns the timestamp of the last message in the segment or 0
+// if it's empty.
+func (s *segment) LastTimestamp() int64 {
+	s.RLock()
+	defer s.RUnlock()
+	return s.lastWriteTime
+}
+
 func (s *segment) Position() int64 {
 	s.RLock()
 	defer s.RUnlock()
@@ -211,7 +286,46 @@ func (s *segment) WriteMessageSet(ms []byte, entries []*entry) error {
 	if _, err := s.write(ms, entries); err != nil {
 		return err
 	}
-	return s.Index.writeEntries(entries)
+	n := s.Index.CountEntries()
+	if err := s.Index.writeEntries(entries); err != nil {
+		return err
+	}
+	if s.indexInterval > 0 {
+		for i, e := range entries {
+			if (n+int64(i))%int64(s.indexInterval) == 0 {
+				s.samples = append(s.samples, *e)
+			}
+		}
+	}
+	return nil
+}
+
+// Sync commits the segment's log and index to stable storage.
+func (s *segment) Sync() error {
+	_, err := s.sync()
+	return err
+}
+
+// sync commits the segment's log and index to stable storage and returns the
+// number of log bytes written since the previous sync.
+func (s *segment) sync() (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return 0, ErrSegmentClosed
+	}
+	if err := s.flush(); err != nil {
+		return 0, err
+	}
+	if err := s.log.Sync(); err != nil {
+		return 0, errors.Wrap(err, "log sync failed")
+	}
+	if err := s.Index.Sync(); err != nil {
+		return 0, err
+	}
+	flushed := s.position - s.synced
+	s.synced = s.position
+	return flushed, nil
 }
 
 // write a byte slice to the log at the current position. This increments the
@@ -239,6 +353,17 @@ func (s *segment) write(p []byte, entries []*entry) (n int, err error) {
 
 func (s *segment) ReadAt(p []byte, off int64) (n int, err error) {
 	s.RLock()
+	if s.isBuffered(off + int64(len(p))) {
+		// Write out the buffer so the read sees the latest writes.
+		s.RUnlock()
+		s.Lock()
+		err := s.flush()
+		s.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		s.RLock()
+	}
 	defer s.RUnlock()
 	if s.closed {
 		if s.replaced {
@@ -307,6 +432,9 @@ func (s *segment) close() error {
 	if s.closed {
 		return nil
 	}
+	if err := s.flush(); err != nil {
+		return err
+	}
 	if err := s.log.Close(); err != nil {
 		return err
 	}
@@ -319,12 +447,17 @@ func (s *segment) close() error {
 
 // Cleaned creates a cleaned segment for this segment.
 func (s *segment) Cleaned() (*segment, error) {
-	return newSegment(s.path, s.BaseOffset, s.maxBytes, false, cleanedSuffix)
+	return newSegment(s.path, s.BaseOffset, s.maxBytes, s.bufferSize, s.indexAccess, s.indexInterval, false, cleanedSuffix)
 }
 
 // Truncated creates a truncated segment for this segment.
 func (s *segment) Truncated() (*segment, error) {
-	return newSegment(s.path, s.BaseOffset, s.maxBytes, false, truncatedSuffix)
+	return newSegment(s.path, s.BaseOffset, s.maxBytes, s.bufferSize, s.indexAccess, s.indexInterval, false, truncatedSuffix)
+}
+
+// Trimmed creates a trimmed segment for this segment.
+func (s *segment) Trimmed() (*segment, error) {
+	return newSegment(s.path, s.BaseOffset, s.maxBytes, s.bufferSize, s.indexAccess, s.indexInterval, false, trimmedSuffix)
 }
 
 // Replace replaces the given segment with the callee.
@@ -350,9 +483,7 @@ func (s *segment) Replace(old *segment) error {
 	if err != nil {
 		return errors.Wrap(err, "open file failed")
 	}
-	s.log = log
-	s.writer = log
-	s.reader = log
+	s.setLog(log)
 	s.closed = false
 	old.replaced = true
 	return s.setupIndex()
@@ -363,19 +494,9 @@ func (s *segment) Replace(old *segment) error {
 func (s *segment) findEntry(offset int64) (e *entry, err error) {
 	s.RLock()
 	defer s.RUnlock()
-	e = &entry{}
-	n := int(s.Index.Position() / entryWidth)
-	idx := sort.Search(n, func(i int) bool {
-		if err := s.Index.ReadEntryAtFileOffset(e, int64(i*entryWidth)); err != nil {
-			panic(err)
-		}
+	return s.searchIndex(func(e *entry) bool {
 		return e.Offset >= offset
 	})
-	if idx == n {
-		return nil, ErrEntryNotFound
-	}
-	err = s.Index.ReadEntryAtFileOffset(e, int64(idx*entryWidth))
-	return e, err
 }
 
 // findEntryByTimestamp returns the first entry whose timestamp is greater than
@@ -383,18 +504,41 @@ func (s *segment) findEntry(offset int64) (e *entry, err error) {
 func (s *segment) findEntryByTimestamp(timestamp int64) (e *entry, err error) {
 	s.RLock()
 	defer s.RUnlock()
-	e = &entry{}
-	n := int(s.Index.Position() / entryWidth)
-	idx := sort.Search(n, func(i int) bool {
-		if err := s.Index.ReadEntryAtFileOffset(e, int64(i*entryWidth)); err != nil {
+	return s.searchIndex(func(e *entry) bool {
+		return e.Timestamp >= timestamp
+	})
+}
+
+// searchIndex returns the first index entry satisfying the predicate, which
+// must be false for some prefix of the index and true for the rest. If the
+// index is sampled, the samples narrow the search down to the entries between
+// two samples so that fewer entries are read from the index. This must be
+// called with the segment read lock held.
+func (s *segment) searchIndex(pred func(e *entry) bool) (*entry, error) {
+	var (
+		e      = &entry{}
+		lo, hi = 0, int(s.Index.Position() / entryWidth)
+	)
+	if len(s.samples) > 0 {
+		k := sort.Search(len(s.samples), func(k int) bool { return pred(&s.samples[k]) })
+		if k > 0 {
+			lo = (k-1)*s.indexInterval + 1
+		}
+		// The sample itself satisfies the predicate.
+		if k < len(s.samples) {
+			hi = k * s.indexInterval
+		}
+	}
+	idx := lo + sort.Search(hi-lo, func(i int) bool {
+		if err := s.Index.ReadEntryAtFileOffset(e, int64((lo+i)*entryWidth)); err != nil {
 			panic(err)
 		}
-		return e.Timestamp >= timestamp
+		return pred(e)
 	})
-	if idx == n {
+	if idx == int(s.Index.Position()/entryWidth) {
 		return nil, ErrEntryNotFound
 	}
-	err = s.Index.ReadEntryAtFileOffset(e, int64(idx*entryWidth))
+	err := s.Index.ReadEntryAtFileOffset(e, int64(idx*entryWidth))
 	return e, err
 }
 
@@ -0,0 +1,104 @@
+package commitlog
+
+// TrimBefore removes all messages before the given offset from the log,
+// making it the log's oldest offset. Segments which end before the offset are
+// deleted and the segment containing it is rewritten without the preceding
+// messages. If the log ends before the offset, all of its messages are removed
+// and subsequent appends start at the offset. Newer messages are not touched.
+func (l *commitLog) TrimBefore(offset int64) error {
+	// Serialize with the cleaner so trimmed segments are not added back when
+	// it replaces the segments.
+	l.cleanMu.Lock()
+	defer l.cleanMu.Unlock()
+
+	// Nothing to do if the log was already trimmed, e.g. when the trim is
+	// replayed on recovery.
+	oldest := l.OldestOffset()
+	if oldest >= offset || (oldest == -1 && l.activeSegment().BaseOffset >= offset) {
+		return nil
+	}
+
+	// Roll a new active segment if the active segment starts before the
+	// offset so that the segments deleted or rewritten below are sealed and
+	// not being appended to.
+	for {
+		active := l.activeSegment()
+		if active.BaseOffset >= offset {
+			break
+		}
+		next := active.NextOffset()
+		if next < offset {
+			next = offset
+		}
+		if err := l.splitAt(active, next); err != nil {
+			// ErrSegmentExists indicates another thread has already rolled
+			// the active segment, so check the new one.
+			if err == ErrSegmentExists {
+				continue
+			}
+			return err
+		}
+		active.Seal()
+		break
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Delete all segments which end before the offset. The active segment
+	// starts at or after the offset, so it's never deleted.
+	deleted := 0
+	for _, seg := range l.segments[:len(l.segments)-1] {
+		if seg.NextOffset() > offset {
+			break
+		}
+		if err := seg.Delete(); err != nil {
+			return err
+		}
+		deleted++
+	}
+	segments := make([]*segment, len(l.segments)-deleted)
+	copy(segments, l.segments[deleted:])
+
+	// Replace the segment containing the offset with a trimmed segment.
+	if seg := segments[0]; seg.BaseOffset < offset {
+		trimmed, err := seg.Trimmed()
+		if err != nil {
+			return err
+		}
+		ss := newSegmentScanner(seg)
+		for ms, _, err := ss.Scan(); err == nil; ms, _, err = ss.Scan() {
+			if ms.Offset() < offset {
+				continue
+			}
+			entries := entriesForMessageSet(trimmed.Position(), ms)
+			if err := trimmed.WriteMessageSet(ms, entries); err != nil {
+				return err
+			}
+		}
+		if trimmed.IsEmpty() {
+			// This can only happen if the log is compacted and there are no
+			// messages left in the segment after the offset.
+			if err := cleanupEmptySegment(trimmed, seg); err != nil {
+				return err
+			}
+			segments = segments[1:]
+		} else {
+			if err := trimmed.Replace(seg); err != nil {
+				return err
+			}
+			segments[0] = trimmed
+		}
+	}
+
+	l.Logger.Debugf("Trimmed log %s before offset %d", l.Path, offset)
+	l.segments = segments
+
+	// Messages before the offset are gone, so they can no longer be
+	// considered uncommitted.
+	if l.hw < offset-1 {
+		l.hw = offset - 1
+		l.notifyHWWaiters()
+	}
+	return l.leaderEpochCache.ClearEarliest(offset)
+}
@@ -0,0 +1,110 @@
+package commitlog
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Ensure TrimBefore removes messages before the offset from a log with a
+// single segment and leaves newer messages readable.
+func TestTrimBefore(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{Path: tempDir(t)})
+	defer l.Close()
+	defer cleanup()
+
+	appendVerifyMessages(t, l, 100)
+	l.SetHighWatermark(99)
+
+	require.NoError(t, l.TrimBefore(50))
+	require.Equal(t, int64(50), l.OldestOffset())
+	require.Equal(t, int64(99), l.NewestOffset())
+	require.Equal(t, int64(99), l.HighWatermark())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := l.NewReader(50, true)
+	require.NoError(t, err)
+	headers := make([]byte, 28)
+	for i := 50; i < 100; i++ {
+		msg, offset, _, _, err := r.ReadMessage(ctx, headers)
+		require.NoError(t, err)
+		require.Equal(t, int64(i), offset)
+		require.Equal(t, []byte(strconv.Itoa(i)), msg.Value())
+	}
+
+	// Appends continue after the newest offset.
+	offsets, err := l.Append([]*Message{{Value: []byte("100")}})
+	require.NoError(t, err)
+	require.Equal(t, []int64{100}, offsets)
+
+	// Trimming before the oldest offset is a no-op.
+	require.NoError(t, l.TrimBefore(10))
+	require.Equal(t, int64(50), l.OldestOffset())
+}
+
+// Ensure TrimBefore deletes segments which end before the offset when the log
+// spans multiple segments.
+func TestTrimBeforeMultipleSegments(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{
+		Path:            tempDir(t),
+		MaxSegmentBytes: 500,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	appendVerifyMessages(t, l, 100)
+	before := len(l.Segments())
+	require.True(t, before > 2)
+
+	require.NoError(t, l.TrimBefore(50))
+	require.Equal(t, int64(50), l.OldestOffset())
+	require.Equal(t, int64(99), l.NewestOffset())
+	require.True(t, len(l.Segments()) < before)
+
+	report, err := l.Verify()
+	require.NoError(t, err)
+	require.True(t, report.Healthy())
+	require.Equal(t, int64(50), report.Messages)
+}
+
+// Ensure TrimBefore removes all messages when the log ends before the offset
+// and appends then start at the offset.
+func TestTrimBeforePastEnd(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{Path: tempDir(t)})
+	defer l.Close()
+	defer cleanup()
+
+	appendVerifyMessages(t, l, 10)
+
+	require.NoError(t, l.TrimBefore(50))
+	require.Equal(t, int64(-1), l.OldestOffset())
+	require.Equal(t, int64(49), l.NewestOffset())
+	require.Equal(t, int64(49), l.HighWatermark())
+
+	offsets, err := l.Append([]*Message{{Value: []byte("50")}})
+	require.NoError(t, err)
+	require.Equal(t, []int64{50}, offsets)
+	require.Equal(t, int64(50), l.OldestOffset())
+}
+
+// Ensure a trimmed log is recovered with the same offsets after being
+// reopened.
+func TestTrimBeforeRecover(t *testing.T) {
+	opts := Options{Path: tempDir(t)}
+	l, cleanup := setupWithOptions(t, opts)
+	defer cleanup()
+
+	appendVerifyMessages(t, l, 100)
+	require.NoError(t, l.TrimBefore(50))
+	require.NoError(t, l.Close())
+
+	l, cleanup = setupWithOptions(t, opts)
+	defer cleanup()
+	defer l.Close()
+
+	require.Equal(t, int64(50), l.OldestOffset())
+	require.Equal(t, int64(99), l.NewestOffset())
+}
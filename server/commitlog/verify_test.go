@@ -0,0 +1,96 @@
+package commitlog
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func appendVerifyMessages(t *testing.T, l *commitLog, n int) {
+	for i := 0; i < n; i++ {
+		_, err := l.Append([]*Message{{
+			Value:       []byte(strconv.Itoa(i)),
+			Timestamp:   time.Now().UnixNano(),
+			LeaderEpoch: 1,
+		}})
+		require.NoError(t, err)
+	}
+}
+
+// corruptSegment overwrites the segment's log file at the given position.
+func corruptSegment(t *testing.T, seg *segment, pos int64, data []byte) {
+	f, err := os.OpenFile(seg.logPath(), os.O_WRONLY, 0666)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteAt(data, pos)
+	require.NoError(t, err)
+}
+
+// Ensure Verify reports no problems for a healthy log spanning multiple
+// segments.
+func TestVerifyHealthy(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{
+		Path:            tempDir(t),
+		MaxSegmentBytes: 100,
+	})
+	defer l.Close()
+	defer cleanup()
+
+	appendVerifyMessages(t, l, 10)
+
+	report, err := l.Verify()
+	require.NoError(t, err)
+	require.True(t, report.Healthy())
+	require.True(t, report.Segments > 1)
+	require.Equal(t, int64(10), report.Messages)
+}
+
+// Ensure Verify reports a message whose checksum does not match its data.
+func TestVerifyCorruptMessage(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{Path: tempDir(t)})
+	defer l.Close()
+	defer cleanup()
+
+	appendVerifyMessages(t, l, 10)
+
+	// Flip the last byte of the message at offset 3.
+	seg := l.activeSegment()
+	entry, err := seg.findEntry(3)
+	require.NoError(t, err)
+	b := make([]byte, 1)
+	_, err = seg.ReadAt(b, entry.Position+int64(entry.Size)-1)
+	require.NoError(t, err)
+	corruptSegment(t, seg, entry.Position+int64(entry.Size)-1, []byte{^b[0]})
+
+	report, err := l.Verify()
+	require.NoError(t, err)
+	require.False(t, report.Healthy())
+	require.Equal(t, []OffsetRange{{Start: 3, End: 3}}, report.Corrupt)
+	require.Empty(t, report.Missing)
+	require.Equal(t, int64(9), report.Messages)
+}
+
+// Ensure Verify reports the remainder of a segment as corrupt when a message
+// header has an invalid size.
+func TestVerifyCorruptHeader(t *testing.T) {
+	l, cleanup := setupWithOptions(t, Options{Path: tempDir(t)})
+	defer l.Close()
+	defer cleanup()
+
+	appendVerifyMessages(t, l, 10)
+
+	// Overwrite the size of the message at offset 5.
+	seg := l.activeSegment()
+	entry, err := seg.findEntry(5)
+	require.NoError(t, err)
+	corruptSegment(t, seg, entry.Position+sizePos, []byte{0xff, 0xff, 0xff, 0xff})
+
+	report, err := l.Verify()
+	require.NoError(t, err)
+	require.Equal(t, []OffsetRange{{Start: 5, End: 9}}, report.Corrupt)
+	require.Empty(t, report.Missing)
+	require.Equal(t, int64(5), report.Messages)
+}
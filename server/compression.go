@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
+)
+
+const (
+	// CodecHeader is the message header containing the codec a message's
+	// value is compressed with in the log, on streams created with
+	// CompressionThresholdMetadataKey. Subscriptions decompress the value and
+	// remove the header before delivering the message.
+	CodecHeader = "codec"
+
+	// CodecGzip is the CodecHeader value of values compressed with gzip.
+	CodecGzip = "gzip"
+)
+
+// compressValues compresses the values of the messages in the batch larger
+// than the stream's compression threshold, if it has one, and marks them with
+// the CodecHeader header. A value is stored uncompressed if compressing it
+// doesn't make it smaller.
+func (p *partition) compressValues(msgBatch []*commitlog.Message) {
+	threshold := p.GetCompressionThreshold()
+	if threshold <= 0 {
+		return
+	}
+	for _, m := range msgBatch {
+		if int64(len(m.Value)) <= threshold {
+			continue
+		}
+		compressed, err := compressValue(m.Value)
+		if err != nil {
+			p.srv.logger.Warnf("Failed to compress message for partition %s: %v", p, err)
+			continue
+		}
+		if len(compressed) >= len(m.Value) {
+			continue
+		}
+		if m.Headers == nil {
+			m.Headers = make(map[string][]byte)
+		}
+		m.Value = compressed
+		m.Headers[CodecHeader] = []byte(CodecGzip)
+	}
+}
+
+// compressValue returns the value compressed with gzip.
+func compressValue(value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(value); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressValue returns the value of a message with the given headers
+// decompressed with the codec in its CodecHeader header, which is removed
+// from the headers. The value is returned as-is if the message isn't
+// compressed.
+func decompressValue(headers map[string][]byte, value []byte) ([]byte, error) {
+	codec, ok := headers[CodecHeader]
+	if !ok {
+		return value, nil
+	}
+	if string(codec) != CodecGzip {
+		return nil, fmt.Errorf("unknown codec %q", codec)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	decompressed, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	delete(headers, CodecHeader)
+	return decompressed, nil
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	grpcMetadata "google.golang.org/grpc/metadata"
+
+	proto "github.com/liftbridge-io/liftbridge-api/go"
+)
+
+// Ensure the partition leader only stores values larger than the stream's
+// compression threshold compressed and subscriptions deliver them
+// decompressed.
+func TestCompressionThreshold(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		CompressionThresholdMetadataKey, "100")
+	require.NoError(t, client.CreateStream(ctx, "foo", name))
+
+	values := [][]byte{
+		[]byte("small"),
+		bytes.Repeat([]byte("large"), 200),
+		bytes.Repeat([]byte("x"), 100),
+	}
+	for _, value := range values {
+		_, err := client.Publish(context.Background(), name, value, lift.AckPolicyLeader())
+		require.NoError(t, err)
+	}
+
+	// Only the value larger than the threshold is stored compressed.
+	partition := s1.metadata.GetPartition(name, 0)
+	require.Equal(t, int64(100), partition.GetCompressionThreshold())
+	reader, err := partition.log.NewReader(0, true)
+	require.NoError(t, err)
+	defer reader.Close()
+	headersBuf := make([]byte, 28)
+	for i, value := range values {
+		m, offset, _, _, err := reader.ReadMessage(context.Background(), headersBuf)
+		require.NoError(t, err)
+		require.Equal(t, int64(i), offset)
+		codec, compressed := m.Headers()[CodecHeader]
+		if i == 1 {
+			require.True(t, compressed)
+			require.Equal(t, CodecGzip, string(codec))
+			require.True(t, len(m.Value()) < len(value))
+		} else {
+			require.False(t, compressed)
+			require.Equal(t, value, m.Value())
+		}
+	}
+
+	// Subscriptions deliver the values as published.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan lift.Message, len(values))
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		received <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	for _, value := range values {
+		select {
+		case msg := <-received:
+			require.Equal(t, value, msg.Value())
+			_, ok := msg.Headers()[CodecHeader]
+			require.False(t, ok)
+		case <-ctx.Done():
+			t.Fatal("Did not receive all expected messages")
+		}
+	}
+}
+
+// Ensure a pass-through subscription receives compressed values as stored
+// along with their codec.
+func TestSubscribePassThrough(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		CompressionThresholdMetadataKey, "100")
+	require.NoError(t, client.CreateStream(ctx, "foo", name))
+
+	values := [][]byte{[]byte("small"), bytes.Repeat([]byte("large"), 200)}
+	for _, value := range values {
+		_, err := client.Publish(context.Background(), name, value, lift.AckPolicyLeader())
+		require.NoError(t, err)
+	}
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = grpcMetadata.AppendToOutgoingContext(ctx, PassThroughMetadataKey, "true")
+	sub, err := proto.NewAPIClient(conn).Subscribe(ctx, &proto.SubscribeRequest{
+		Stream:        name,
+		StartPosition: proto.StartPosition_EARLIEST,
+	})
+	require.NoError(t, err)
+	// The first message signals the subscription was created.
+	_, err = sub.Recv()
+	require.NoError(t, err)
+
+	// Uncompressed values are delivered as-is.
+	msg, err := sub.Recv()
+	require.NoError(t, err)
+	require.Equal(t, values[0], msg.Value)
+	_, ok := msg.Headers[CodecHeader]
+	require.False(t, ok)
+
+	// Compressed values are delivered compressed with their codec.
+	msg, err = sub.Recv()
+	require.NoError(t, err)
+	require.Equal(t, CodecGzip, string(msg.Headers[CodecHeader]))
+	require.True(t, len(msg.Value) < len(values[1]))
+	decompressed, err := decompressValue(msg.Headers, msg.Value)
+	require.NoError(t, err)
+	require.Equal(t, values[1], decompressed)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/spf13/viper"
 
 	client "github.com/liftbridge-io/liftbridge-api/go"
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
 )
 
 const (
@@ -40,12 +41,19 @@ const (
 	defaultBatchMaxMessages               = 1024
 	defaultReplicaFetchTimeout            = 3 * time.Second
 	defaultMinInsyncReplicas              = 1
+	defaultReplicaRebalanceThreshold      = 1
+	defaultPublishElectionTimeout         = 5 * time.Second
+	defaultMetadataExportInterval         = time.Minute
 	defaultRetentionMaxAge                = 7 * 24 * time.Hour
 	defaultCleanerInterval                = 5 * time.Minute
 	defaultMaxSegmentBytes                = 1024 * 1024 * 256 // 256MB
 	defaultMaxSegmentAge                  = defaultRetentionMaxAge
 	defaultActivityStreamPublishTimeout   = 5 * time.Second
 	defaultActivityStreamPublishAckPolicy = client.AckPolicy_ALL
+	defaultStreamsAutoCreateMax           = 100
+	defaultGRPCKeepaliveMinTime           = 5 * time.Minute
+	defaultProducerMaxSessions            = 10000
+	defaultMaxDeliveryDelay               = 24 * time.Hour
 )
 
 // Config setting key names.
@@ -55,6 +63,8 @@ const (
 	configPort                = "port"
 	configDataDir             = "data.dir"
 	configMetadataCacheMaxAge = "metadata.cache.max.age"
+	configMetadataClientTTL   = "metadata.client.ttl"
+	configProducerMaxSessions = "producer.max.sessions"
 
 	configLoggingLevel    = "logging.level"
 	configLoggingRecovery = "logging.recovery"
@@ -68,6 +78,13 @@ const (
 	configTLSClientAuthEnabled = "tls.client.auth.enabled"
 	configTLSClientAuthCA      = "tls.client.auth.ca"
 
+	configGRPCMaxRecvMessageSize           = "grpc.max.recv.message.size"
+	configGRPCMaxSendMessageSize           = "grpc.max.send.message.size"
+	configGRPCKeepaliveTime                = "grpc.keepalive.time"
+	configGRPCKeepaliveTimeout             = "grpc.keepalive.timeout"
+	configGRPCKeepaliveMinTime             = "grpc.keepalive.min.time"
+	configGRPCKeepalivePermitWithoutStream = "grpc.keepalive.permit.without.stream"
+
 	configNATSServers  = "nats.servers"
 	configNATSUser     = "nats.user"
 	configNATSPassword = "nats.password"
@@ -75,11 +92,34 @@ const (
 	configStreamsRetentionMaxBytes    = "streams.retention.max.bytes"
 	configStreamsRetentionMaxMessages = "streams.retention.max.messages"
 	configStreamsRetentionMaxAge      = "streams.retention.max.age"
+	configStreamsRetentionMaxSegments = "streams.retention.max.segments"
+	configStreamsRetentionReaderDelay = "streams.retention.reader.max.delay"
 	configStreamsCleanerInterval      = "streams.cleaner.interval"
 	configStreamsSegmentMaxBytes      = "streams.segment.max.bytes"
 	configStreamsSegmentMaxAge        = "streams.segment.max.age"
 	configStreamsCompactEnabled       = "streams.compact.enabled"
 	configStreamsCompactMaxGoroutines = "streams.compact.max.goroutines"
+	configStreamsCompactWindow        = "streams.compact.window"
+	configStreamsCompactMinDirtyRatio = "streams.compact.min.dirty.ratio"
+	configStreamsSyncWrites           = "streams.sync.writes"
+	configStreamsWriteBufferSize      = "streams.write.buffer.size"
+	configStreamsQuotaMaxBytes        = "streams.quota.max.bytes"
+	configStreamsQuotaPolicy          = "streams.quota.policy"
+	configStreamsAutoCreateRules      = "streams.auto.create.rules"
+	configStreamsAutoCreateMax        = "streams.auto.create.max"
+	configStreamsIngestMaxPendingMsgs = "streams.ingest.max.pending.messages"
+	configStreamsIngestMaxPendingSize = "streams.ingest.max.pending.bytes"
+	configStreamsIngestDropPause      = "streams.ingest.drop.pause"
+	configStreamsPausedRejectSubs     = "streams.paused.reject.subscriptions"
+	configStreamsDirShards            = "streams.dir.shards"
+	configStreamsIdleDeleteTime       = "streams.idle.delete.time"
+	configStreamsMaxPendingAcks       = "streams.max.pending.acks"
+	configStreamsMaxAppendLatency     = "streams.max.append.latency"
+	configStreamsMaxDeliveryDelay     = "streams.max.delivery.delay"
+	configStreamsMaxCommitWaiters     = "streams.max.commit.waiters"
+	configStreamsCommitWaitersPolicy  = "streams.commit.waiters.policy"
+	configStreamsIndexAccess          = "streams.index.access"
+	configStreamsIndexInterval        = "streams.index.interval"
 
 	configClusteringServerID                = "clustering.server.id"
 	configClusteringNamespace               = "clustering.namespace"
@@ -89,10 +129,25 @@ const (
 	configClusteringRaftBootstrapSeed       = "clustering.raft.bootstrap.seed"
 	configClusteringRaftBootstrapPeers      = "clustering.raft.bootstrap.peers"
 	configClusteringReplicaMaxLagTime       = "clustering.replica.max.lag.time"
+	configClusteringReplicaMaxLagOffsets    = "clustering.replica.max.lag.offsets"
 	configClusteringReplicaMaxLeaderTimeout = "clustering.replica.max.leader.timeout"
 	configClusteringReplicaMaxIdleWait      = "clustering.replica.max.idle.wait"
 	configClusteringReplicaFetchTimeout     = "clustering.replica.fetch.timeout"
 	configClusteringMinInsyncReplicas       = "clustering.min.insync.replicas"
+	configClusteringReplicaCatchUpMaxBytes  = "clustering.replica.catchup.max.bytes.per.sec"
+	configClusteringReplicaHWUpdateInterval = "clustering.replica.hw.update.interval"
+	configClusteringCommitCoalesceInterval  = "clustering.commit.coalesce.interval"
+	configClusteringServerMaxReplicas       = "clustering.server.max.replicas"
+	configClusteringReplicaRebalanceThresh  = "clustering.replica.rebalance.threshold"
+	configClusteringReplicaMaxCatchUpFails  = "clustering.replica.max.catchup.failures"
+	configClusteringLeaderElectionPref      = "clustering.leader.election.preference"
+	configClusteringReplicaTruncateFallback = "clustering.replica.truncate.fallback"
+	configClusteringReplicaSyncSource       = "clustering.replica.sync.source"
+	configClusteringPublishElectionPolicy   = "clustering.publish.election.policy"
+	configClusteringPublishElectionTimeout  = "clustering.publish.election.timeout"
+	configClusteringMetadataExportPath      = "clustering.metadata.export.path"
+	configClusteringMetadataExportInterval  = "clustering.metadata.export.interval"
+	configClusteringMetadataBootstrapPath   = "clustering.metadata.bootstrap.path"
 
 	configActivityStreamEnabled          = "activity.stream.enabled"
 	configActivityStreamPublishTimeout   = "activity.stream.publish.timeout"
@@ -105,6 +160,8 @@ var configKeys = map[string]struct{}{
 	configPort:                              {},
 	configDataDir:                           {},
 	configMetadataCacheMaxAge:               {},
+	configMetadataClientTTL:                 {},
+	configProducerMaxSessions:               {},
 	configLoggingLevel:                      {},
 	configLoggingRecovery:                   {},
 	configLoggingRaft:                       {},
@@ -114,17 +171,46 @@ var configKeys = map[string]struct{}{
 	configTLSCert:                           {},
 	configTLSClientAuthEnabled:              {},
 	configTLSClientAuthCA:                   {},
+	configGRPCMaxRecvMessageSize:            {},
+	configGRPCMaxSendMessageSize:            {},
+	configGRPCKeepaliveTime:                 {},
+	configGRPCKeepaliveTimeout:              {},
+	configGRPCKeepaliveMinTime:              {},
+	configGRPCKeepalivePermitWithoutStream:  {},
 	configNATSServers:                       {},
 	configNATSUser:                          {},
 	configNATSPassword:                      {},
 	configStreamsRetentionMaxBytes:          {},
 	configStreamsRetentionMaxMessages:       {},
 	configStreamsRetentionMaxAge:            {},
+	configStreamsRetentionMaxSegments:       {},
+	configStreamsRetentionReaderDelay:       {},
 	configStreamsCleanerInterval:            {},
 	configStreamsSegmentMaxBytes:            {},
 	configStreamsSegmentMaxAge:              {},
 	configStreamsCompactEnabled:             {},
 	configStreamsCompactMaxGoroutines:       {},
+	configStreamsCompactWindow:              {},
+	configStreamsCompactMinDirtyRatio:       {},
+	configStreamsSyncWrites:                 {},
+	configStreamsWriteBufferSize:            {},
+	configStreamsQuotaMaxBytes:              {},
+	configStreamsQuotaPolicy:                {},
+	configStreamsAutoCreateRules:            {},
+	configStreamsAutoCreateMax:              {},
+	configStreamsIngestMaxPendingMsgs:       {},
+	configStreamsIngestMaxPendingSize:       {},
+	configStreamsIngestDropPause:            {},
+	configStreamsPausedRejectSubs:           {},
+	configStreamsDirShards:                  {},
+	configStreamsIdleDeleteTime:             {},
+	configStreamsMaxPendingAcks:             {},
+	configStreamsMaxAppendLatency:           {},
+	configStreamsMaxDeliveryDelay:           {},
+	configStreamsMaxCommitWaiters:           {},
+	configStreamsCommitWaitersPolicy:        {},
+	configStreamsIndexAccess:                {},
+	configStreamsIndexInterval:              {},
 	configClusteringServerID:                {},
 	configClusteringNamespace:               {},
 	configClusteringRaftSnapshotRetain:      {},
@@ -133,10 +219,25 @@ var configKeys = map[string]struct{}{
 	configClusteringRaftBootstrapSeed:       {},
 	configClusteringRaftBootstrapPeers:      {},
 	configClusteringReplicaMaxLagTime:       {},
+	configClusteringReplicaMaxLagOffsets:    {},
 	configClusteringReplicaMaxLeaderTimeout: {},
 	configClusteringReplicaMaxIdleWait:      {},
 	configClusteringReplicaFetchTimeout:     {},
 	configClusteringMinInsyncReplicas:       {},
+	configClusteringReplicaCatchUpMaxBytes:  {},
+	configClusteringReplicaHWUpdateInterval: {},
+	configClusteringCommitCoalesceInterval:  {},
+	configClusteringServerMaxReplicas:       {},
+	configClusteringReplicaRebalanceThresh:  {},
+	configClusteringReplicaMaxCatchUpFails:  {},
+	configClusteringLeaderElectionPref:      {},
+	configClusteringReplicaTruncateFallback: {},
+	configClusteringReplicaSyncSource:       {},
+	configClusteringPublishElectionPolicy:   {},
+	configClusteringPublishElectionTimeout:  {},
+	configClusteringMetadataExportPath:      {},
+	configClusteringMetadataExportInterval:  {},
+	configClusteringMetadataBootstrapPath:   {},
 	configActivityStreamEnabled:             {},
 	configActivityStreamPublishTimeout:      {},
 	configActivityStreamPublishAckPolicy:    {},
@@ -147,11 +248,185 @@ type StreamsConfig struct {
 	RetentionMaxBytes    int64
 	RetentionMaxMessages int64
 	RetentionMaxAge      time.Duration
+	RetentionMaxSegments int
 	CleanerInterval      time.Duration
 	SegmentMaxBytes      int64
 	SegmentMaxAge        time.Duration
 	Compact              bool
 	CompactMaxGoroutines int
+	CompactWindowStart   time.Duration
+	CompactWindowEnd     time.Duration
+	SyncWrites           bool
+	WriteBufferSize      int
+	QuotaMaxBytes        int64
+	QuotaPolicy          commitlog.QuotaPolicy
+
+	// CompactMinDirtyRatio is the minimum fraction of a segment's messages
+	// which compaction would remove for the segment to be rewritten. Segments
+	// below it are left as is to limit write amplification. Zero compacts
+	// every segment.
+	CompactMinDirtyRatio float64
+
+	// IndexAccess is how segment index files are accessed: memory-mapped,
+	// the default, or with positioned file reads and writes, which keeps
+	// large indexes out of the process's address space.
+	IndexAccess commitlog.IndexAccess
+
+	// IndexInterval is the number of segment index entries between the
+	// entries each segment keeps in memory to narrow down offset and
+	// timestamp lookups, so that fewer entries are read from the index file.
+	// A smaller interval speeds up lookups at the cost of memory. Zero
+	// disables sampling, so lookups binary search the whole index file.
+	IndexInterval int
+
+	// RetentionReaderMaxDelay is the max time retention defers deleting log
+	// segments which active subscribers are still reading. Zero disables
+	// deferring.
+	RetentionReaderMaxDelay time.Duration
+
+	// AutoCreateRules are rules for automatically creating a stream for NATS
+	// subjects matching a pattern when the first message is published to
+	// them. AutoCreateMax bounds the number of streams created this way.
+	AutoCreateRules []AutoCreateRule
+	AutoCreateMax   int
+
+	// MessageIDFunc, if set, is invoked by partition leaders when appending
+	// each message to generate an ID which is stored in the message's
+	// MessageIDHeader. This can only be set programmatically.
+	MessageIDFunc MessageIDFunc
+
+	// IngestMaxPendingMessages and IngestMaxPendingBytes limit the messages
+	// buffered on a partition leader's NATS subscription before NATS drops
+	// them. Zero means no limit. When messages are dropped, ingest is paused
+	// for IngestDropPause, if set, to let the leader catch up.
+	IngestMaxPendingMessages int
+	IngestMaxPendingBytes    int
+	IngestDropPause          time.Duration
+
+	// PausedRejectSubscriptions rejects subscriptions to paused partitions
+	// instead of serving the messages committed before they were paused.
+	PausedRejectSubscriptions bool
+
+	// DirShards spreads stream data directories across this many shard
+	// directories, kept apart from the streams directory, by the hash of the
+	// stream name, keeping directories small with many streams. Zero keeps
+	// every stream directly under the streams directory.
+	DirShards int
+
+	// IdleDeleteTime is how long a stream can go without publishes or
+	// subscribers before the metadata leader deletes it. Streams can override
+	// it when created. Zero never deletes idle streams.
+	IdleDeleteTime time.Duration
+
+	// MaxPendingAcks limits the AckPolicy_ALL messages each partition leader
+	// holds waiting to be committed. Once reached, AckPolicy_ALL publishes
+	// sent to the partition leader fail with ResourceExhausted until commits
+	// drain the backlog. Zero means no limit.
+	MaxPendingAcks int64
+
+	// MaxAppendLatency is the append latency SLO of partition leaders. While
+	// the oldest message a leader holds waiting to be committed has waited
+	// longer than this since it was received, AckPolicy_ALL publishes sent to
+	// the partition leader fail with ResourceExhausted, shedding load until
+	// the backlog is committed. Zero disables shedding.
+	MaxAppendLatency time.Duration
+
+	// MaxDeliveryDelay is the furthest in the future a message can be
+	// scheduled for delivery with its DeliverAtHeader. Publishes scheduled
+	// later fail with InvalidArgument, and partition leaders remove the
+	// header from such messages published directly to NATS or imported.
+	// Subscriptions never hold a message longer than this.
+	MaxDeliveryDelay time.Duration
+
+	// MaxCommitWaiters limits the AckPolicy_ALL publishes each server has
+	// waiting on the message to be committed for each stream. Streams can
+	// override it when created.
+	// CommitWaitersPolicy determines how publishes beyond the limit are
+	// handled. Zero means no limit.
+	MaxCommitWaiters    int
+	CommitWaitersPolicy CommitWaitersPolicy
+
+	// SchemaValidators are the validators streams can be created with, keyed
+	// by the schema name streams reference. Partition leaders reject
+	// messages the stream's validator returns an error for. This can only be
+	// set programmatically.
+	SchemaValidators map[string]SchemaValidator
+
+	// Transforms are the transforms streams can be reprocessed with by
+	// ReprocessStream, keyed by name. This can only be set programmatically.
+	Transforms map[string]MessageTransform
+
+	// OnIngestDrop, if set, is invoked asynchronously when NATS drops
+	// messages on a partition leader's subscription because the leader is a
+	// slow consumer. This can only be set programmatically.
+	OnIngestDrop func(IngestDrop)
+
+	// OnSegmentRoll, if set, is invoked when a partition's active log
+	// segment is sealed because a new segment was rolled, e.g. to archive
+	// the segment to external storage. Calls for a partition are made in
+	// roll order from a single goroutine, so a slow hook delays later calls
+	// but not appends. This can only be set programmatically.
+	OnSegmentRoll func(SegmentRoll)
+}
+
+// SegmentRoll describes a sealed log segment of a stream partition passed to
+// the OnSegmentRoll hook. The segment files may later be removed by retention
+// or rewritten by compaction.
+type SegmentRoll struct {
+	Stream    string
+	Partition int32
+	commitlog.RolledSegment
+}
+
+// IngestDrop describes messages NATS dropped on a partition leader's
+// subscription because the leader was a slow consumer. Dropped messages are
+// never written to the stream, leaving a gap in the data.
+type IngestDrop struct {
+	Stream    string
+	Partition int32
+	Dropped   int64 // Messages dropped since the last report
+	Total     int64 // Messages dropped since the partition was loaded
+}
+
+// SchemaValidator validates the key and value of a message appended to a
+// stream. A non-nil error rejects the message and describes why it does not
+// conform to the schema.
+type SchemaValidator func(key, value []byte) error
+
+// MessageTransform rewrites a message reprocessed by ReprocessStream. It's
+// passed a copy of the message's key, value, headers, and timestamp, which it
+// modifies in place to produce the message appended to the stream. A non-nil
+// error aborts the reprocessing.
+type MessageTransform func(msg *commitlog.Message) error
+
+// MessageIDFunc generates an ID for a message appended to the given stream
+// partition at the given offset with the given timestamp (in Unix
+// nanoseconds). The ID is generated once by the partition leader and
+// replicated to followers with the message.
+type MessageIDFunc func(stream string, partition int32, offset, timestamp int64) string
+
+// AutoCreateRule is a rule for automatically creating streams. The first time
+// a message is published to a NATS subject matching Subject, which may
+// contain wildcards, a stream attached to that subject is created using the
+// rule's settings.
+type AutoCreateRule struct {
+	// Subject is the NATS subject pattern to watch.
+	Subject string `mapstructure:"subject"`
+
+	// Name is the template for the stream name. "{subject}" is replaced
+	// with the matching subject and "{1}", "{2}", etc. are replaced with the
+	// tokens matching each wildcard in Subject. If empty, the stream is named
+	// after the subject. The stream is attached to Subject with the wildcards
+	// Name refers to filled in, or to the matching subject if Name is empty or
+	// refers to "{subject}".
+	Name string `mapstructure:"name"`
+
+	// Partitions is the number of partitions for created streams.
+	Partitions int32 `mapstructure:"partitions"`
+
+	// ReplicationFactor is the replication factor for created streams. -1
+	// replicates to all servers in the cluster.
+	ReplicationFactor int32 `mapstructure:"replication.factor"`
 }
 
 // RetentionString returns a human-readable string representation of the
@@ -171,6 +446,10 @@ func (l StreamsConfig) RetentionString() string {
 		str += fmt.Sprintf("%sAge: %s", prefix, durafmt.Parse(l.RetentionMaxAge))
 		prefix = ", "
 	}
+	if l.RetentionMaxSegments > 0 {
+		str += fmt.Sprintf("%sSegments: %d", prefix, l.RetentionMaxSegments)
+		prefix = ", "
+	}
 	if prefix == "" {
 		str += "no limits"
 	}
@@ -193,6 +472,104 @@ type ClusteringConfig struct {
 	ReplicaFetchTimeout     time.Duration
 	ReplicaMaxIdleWait      time.Duration
 	MinISR                  int
+
+	// ReplicaMaxLagOffsets is how many offsets a follower can fall behind
+	// the leader's log end offset before it's removed from the ISR, even if
+	// it has caught up within ReplicaMaxLagTime. Zero disables this.
+	ReplicaMaxLagOffsets int64
+
+	// ReplicaCatchUpMaxBytesPerSec caps the aggregate bandwidth a leader
+	// spends replicating to followers outside of the ISR. Zero disables the
+	// cap.
+	ReplicaCatchUpMaxBytesPerSec int64
+
+	// ReplicaHWUpdateInterval is how long a leader batches HW advances before
+	// waking caught-up followers to fetch the new HW. Zero disables this, so
+	// idle followers learn the HW on their next replication request.
+	ReplicaHWUpdateInterval time.Duration
+
+	// CommitCoalesceInterval is how long a partition leader waits after
+	// followers replicate messages before committing them, so that the
+	// progress of several replication rounds is committed, and its
+	// publishers acked, in a single pass. Zero commits as soon as followers
+	// replicate messages.
+	CommitCoalesceInterval time.Duration
+
+	// ServerMaxReplicas caps the number of stream partition replicas the
+	// metadata leader places on each server. Zero disables the cap.
+	ServerMaxReplicas int
+
+	// ReplicaRebalanceThreshold is the largest difference in the number of
+	// replicas placed on any two servers which RebalanceReplicas leaves as
+	// is. Values below one are treated as one.
+	ReplicaRebalanceThreshold int
+
+	// ReplicaMaxCatchUpFailures is how many consecutive ReplicaMaxLagTime
+	// periods a follower can spend out of sync with the partition leader
+	// before the leader replaces it with a replica on another server. Zero
+	// disables this.
+	ReplicaMaxCatchUpFailures int
+
+	// LeaderElectionPreference determines which ISR replica the metadata
+	// leader elects when a partition leader fails.
+	LeaderElectionPreference LeaderElectionPreference
+
+	// ReplicaTruncateFallback determines how a follower truncates
+	// uncommitted messages when it can't fetch the leader's offset for its
+	// leader epoch on becoming a follower.
+	ReplicaTruncateFallback TruncateFallback
+
+	// ReplicaSyncSource is the ID of a server which partitions followed by
+	// this server fetch from instead of the leader while it's an in-sync
+	// follower of the same partition. Followers fetching from a sync source
+	// only receive committed messages and leave the ISR. If empty, followers
+	// always fetch from the leader.
+	ReplicaSyncSource string
+
+	// PublishElectionPolicy determines how publishes to a partition whose
+	// leader is being re-elected are handled.
+	PublishElectionPolicy PublishElectionPolicy
+
+	// PublishElectionTimeout is how long a publish waits for a partition
+	// leader to be elected when PublishElectionPolicy is
+	// PublishElectionQueue.
+	PublishElectionTimeout time.Duration
+
+	// MetadataExportPath is the file the metadata leader periodically exports
+	// the stream definitions to, e.g. on a volume backed by external storage
+	// for disaster recovery. If empty, metadata is not exported.
+	MetadataExportPath string
+
+	// MetadataExportInterval is how often the metadata leader exports the
+	// stream definitions to MetadataExportPath.
+	MetadataExportInterval time.Duration
+
+	// MetadataBootstrapPath is a file previously exported to
+	// MetadataExportPath which the metadata leader recreates the stream
+	// definitions from when the cluster has no streams, e.g. on a fresh
+	// deployment. Only the definitions are recreated, not the data.
+	MetadataBootstrapPath string
+
+	// ReplicationCodec frames replication responses between partition
+	// leaders and followers. If nil, the default envelope framing is used.
+	// This can only be set programmatically.
+	ReplicationCodec ReplicationCodec
+
+	// OnReplicaReplaced, if set, is invoked asynchronously on a partition
+	// leader when it replaces a follower which failed to catch up
+	// ReplicaMaxCatchUpFailures times. This can only be set
+	// programmatically.
+	OnReplicaReplaced func(ReplicaReplacement)
+}
+
+// ReplicaReplacement describes a follower a partition leader replaced with a
+// replica on another server because it repeatedly failed to catch up.
+type ReplicaReplacement struct {
+	Stream      string
+	Partition   int32
+	Replaced    string // ID of the server the replica was removed from
+	Replacement string // ID of the server the new replica was placed on
+	Failures    int    // Consecutive catch-up failures of the replaced replica
 }
 
 // ActivityStreamConfig contains settings for controlling activity stream
@@ -203,6 +580,68 @@ type ActivityStreamConfig struct {
 	PublishAckPolicy client.AckPolicy
 }
 
+// GRPCConfig contains settings for the gRPC server clients connect to. Zero
+// values use gRPC's defaults.
+type GRPCConfig struct {
+	// MaxRecvMessageSize is the largest message in bytes the server can
+	// receive, e.g. a large publish. gRPC's default is 4MB.
+	MaxRecvMessageSize int
+
+	// MaxSendMessageSize is the largest message in bytes the server can
+	// send, e.g. a large message on a subscription. gRPC's default is
+	// unlimited.
+	MaxSendMessageSize int
+
+	// KeepaliveTime is how long a connection can be idle before the server
+	// pings the client to keep it alive, e.g. on networks which drop idle
+	// connections. gRPC's default is two hours.
+	KeepaliveTime time.Duration
+
+	// KeepaliveTimeout is how long the server waits for a ping to be
+	// acknowledged before closing the connection. It must be less than
+	// KeepaliveTime. gRPC's default is 20 seconds.
+	KeepaliveTimeout time.Duration
+
+	// KeepaliveMinTime is the minimum time clients should wait between
+	// pings. Clients pinging more often are disconnected. gRPC's default is
+	// five minutes.
+	KeepaliveMinTime time.Duration
+
+	// KeepalivePermitWithoutStream allows clients to ping when there are no
+	// active RPCs.
+	KeepalivePermitWithoutStream bool
+}
+
+// validate returns an error if the settings are invalid or inconsistent with
+// one another.
+func (g GRPCConfig) validate() error {
+	if g.MaxRecvMessageSize < 0 {
+		return fmt.Errorf("%s must not be negative: %d", configGRPCMaxRecvMessageSize, g.MaxRecvMessageSize)
+	}
+	if g.MaxSendMessageSize < 0 {
+		return fmt.Errorf("%s must not be negative: %d", configGRPCMaxSendMessageSize, g.MaxSendMessageSize)
+	}
+	if g.KeepaliveTime < 0 {
+		return fmt.Errorf("%s must not be negative: %s", configGRPCKeepaliveTime, g.KeepaliveTime)
+	}
+	if g.KeepaliveTimeout < 0 {
+		return fmt.Errorf("%s must not be negative: %s", configGRPCKeepaliveTimeout, g.KeepaliveTimeout)
+	}
+	if g.KeepaliveMinTime < 0 {
+		return fmt.Errorf("%s must not be negative: %s", configGRPCKeepaliveMinTime, g.KeepaliveMinTime)
+	}
+	// gRPC raises shorter intervals to a second, so reject them rather than
+	// silently pinging less often than configured.
+	if g.KeepaliveTime > 0 && g.KeepaliveTime < time.Second {
+		return fmt.Errorf("%s must be at least 1s: %s", configGRPCKeepaliveTime, g.KeepaliveTime)
+	}
+	if g.KeepaliveTime > 0 && g.KeepaliveTimeout >= g.KeepaliveTime {
+		return fmt.Errorf("%s (%s) must be less than %s (%s)", configGRPCKeepaliveTimeout,
+			g.KeepaliveTimeout, configGRPCKeepaliveTime, g.KeepaliveTime)
+	}
+	return nil
+}
+
 // Config contains all settings for a Liftbridge Server.
 type Config struct {
 	Listen              HostPort
@@ -216,10 +655,13 @@ type Config struct {
 	BatchMaxMessages    int
 	BatchMaxTime        time.Duration
 	MetadataCacheMaxAge time.Duration
+	MetadataClientTTL   time.Duration
+	ProducerMaxSessions int
 	TLSKey              string
 	TLSCert             string
 	TLSClientAuth       bool
 	TLSClientAuthCA     string
+	GRPC                GRPCConfig
 	NATS                nats.Options
 	Streams             StreamsConfig
 	Clustering          ClusteringConfig
@@ -235,6 +677,7 @@ func NewDefaultConfig() *Config {
 	config.LogLevel = uint32(log.InfoLevel)
 	config.BatchMaxMessages = defaultBatchMaxMessages
 	config.MetadataCacheMaxAge = defaultMetadataCacheMaxAge
+	config.ProducerMaxSessions = defaultProducerMaxSessions
 	config.Clustering.ServerID = nuid.Next()
 	config.Clustering.Namespace = DefaultNamespace
 	config.Clustering.ReplicaMaxLagTime = defaultReplicaMaxLagTime
@@ -244,10 +687,15 @@ func NewDefaultConfig() *Config {
 	config.Clustering.RaftSnapshots = defaultRaftSnapshots
 	config.Clustering.RaftCacheSize = defaultRaftCacheSize
 	config.Clustering.MinISR = defaultMinInsyncReplicas
+	config.Clustering.ReplicaRebalanceThreshold = defaultReplicaRebalanceThreshold
+	config.Clustering.PublishElectionTimeout = defaultPublishElectionTimeout
+	config.Clustering.MetadataExportInterval = defaultMetadataExportInterval
 	config.Streams.SegmentMaxBytes = defaultMaxSegmentBytes
 	config.Streams.SegmentMaxAge = defaultMaxSegmentAge
 	config.Streams.RetentionMaxAge = defaultRetentionMaxAge
 	config.Streams.CleanerInterval = defaultCleanerInterval
+	config.Streams.AutoCreateMax = defaultStreamsAutoCreateMax
+	config.Streams.MaxDeliveryDelay = defaultMaxDeliveryDelay
 	config.ActivityStream.PublishTimeout = defaultActivityStreamPublishTimeout
 	config.ActivityStream.PublishAckPolicy = defaultActivityStreamPublishAckPolicy
 	return config
@@ -394,6 +842,18 @@ func NewConfig(configFile string) (*Config, error) { // nolint: gocyclo
 		config.MetadataCacheMaxAge = v.GetDuration(configMetadataCacheMaxAge)
 	}
 
+	if v.IsSet(configMetadataClientTTL) {
+		config.MetadataClientTTL = v.GetDuration(configMetadataClientTTL)
+	}
+
+	if v.IsSet(configProducerMaxSessions) {
+		maxSessions := v.GetInt(configProducerMaxSessions)
+		if maxSessions < 1 {
+			return nil, fmt.Errorf("Invalid %s %d, must be at least 1", configProducerMaxSessions, maxSessions)
+		}
+		config.ProducerMaxSessions = maxSessions
+	}
+
 	if v.IsSet(configTLSKey) {
 		config.TLSKey = v.GetString(configTLSKey)
 	}
@@ -410,8 +870,14 @@ func NewConfig(configFile string) (*Config, error) { // nolint: gocyclo
 		config.TLSClientAuthCA = v.GetString(configTLSClientAuthCA)
 	}
 
+	if err := parseGRPCConfig(config, v); err != nil {
+		return nil, err
+	}
+
 	parseNATSConfig(&config.NATS, v)
-	parseStreamsConfig(config, v)
+	if err := parseStreamsConfig(config, v); err != nil {
+		return nil, err
+	}
 	parseClusteringConfig(config, v)
 	parseActivityStreamConfig(config, v)
 
@@ -423,6 +889,36 @@ func NewConfig(configFile string) (*Config, error) { // nolint: gocyclo
 	return config, nil
 }
 
+// parseGRPCConfig parses the `grpc` section of a config file and populates the
+// given Config.
+func parseGRPCConfig(config *Config, v *viper.Viper) error {
+	if v.IsSet(configGRPCMaxRecvMessageSize) {
+		config.GRPC.MaxRecvMessageSize = v.GetInt(configGRPCMaxRecvMessageSize)
+	}
+
+	if v.IsSet(configGRPCMaxSendMessageSize) {
+		config.GRPC.MaxSendMessageSize = v.GetInt(configGRPCMaxSendMessageSize)
+	}
+
+	if v.IsSet(configGRPCKeepaliveTime) {
+		config.GRPC.KeepaliveTime = v.GetDuration(configGRPCKeepaliveTime)
+	}
+
+	if v.IsSet(configGRPCKeepaliveTimeout) {
+		config.GRPC.KeepaliveTimeout = v.GetDuration(configGRPCKeepaliveTimeout)
+	}
+
+	if v.IsSet(configGRPCKeepaliveMinTime) {
+		config.GRPC.KeepaliveMinTime = v.GetDuration(configGRPCKeepaliveMinTime)
+	}
+
+	if v.IsSet(configGRPCKeepalivePermitWithoutStream) {
+		config.GRPC.KeepalivePermitWithoutStream = v.GetBool(configGRPCKeepalivePermitWithoutStream)
+	}
+
+	return config.GRPC.validate()
+}
+
 // parseNATSConfig parses the `nats` section of a config file and populates the
 // given nats.Options.
 func parseNATSConfig(opts *nats.Options, v *viper.Viper) error {
@@ -457,6 +953,18 @@ func parseStreamsConfig(config *Config, v *viper.Viper) error {
 		config.Streams.RetentionMaxAge = v.GetDuration(configStreamsRetentionMaxAge)
 	}
 
+	if v.IsSet(configStreamsRetentionMaxSegments) {
+		config.Streams.RetentionMaxSegments = v.GetInt(configStreamsRetentionMaxSegments)
+	}
+
+	if v.IsSet(configStreamsRetentionReaderDelay) {
+		delay := v.GetDuration(configStreamsRetentionReaderDelay)
+		if delay < 0 {
+			return fmt.Errorf("%s must not be negative: %s", configStreamsRetentionReaderDelay, delay)
+		}
+		config.Streams.RetentionReaderMaxDelay = delay
+	}
+
 	if v.IsSet(configStreamsCleanerInterval) {
 		config.Streams.CleanerInterval = v.GetDuration(configStreamsCleanerInterval)
 	}
@@ -477,6 +985,134 @@ func parseStreamsConfig(config *Config, v *viper.Viper) error {
 		config.Streams.CompactMaxGoroutines = v.GetInt(configStreamsCompactMaxGoroutines)
 	}
 
+	if v.IsSet(configStreamsCompactWindow) {
+		start, end, err := parseCompactWindow(v.GetString(configStreamsCompactWindow))
+		if err != nil {
+			return err
+		}
+		config.Streams.CompactWindowStart = start
+		config.Streams.CompactWindowEnd = end
+	}
+
+	if v.IsSet(configStreamsCompactMinDirtyRatio) {
+		ratio := v.GetFloat64(configStreamsCompactMinDirtyRatio)
+		if ratio < 0 || ratio > 1 {
+			return fmt.Errorf("Invalid %s %v, must be between 0 and 1",
+				configStreamsCompactMinDirtyRatio, ratio)
+		}
+		config.Streams.CompactMinDirtyRatio = ratio
+	}
+
+	if v.IsSet(configStreamsSyncWrites) {
+		config.Streams.SyncWrites = v.GetBool(configStreamsSyncWrites)
+	}
+
+	if v.IsSet(configStreamsWriteBufferSize) {
+		size := v.GetInt(configStreamsWriteBufferSize)
+		if size != 0 && (size < commitlog.MinWriteBufferSize || size > commitlog.MaxWriteBufferSize) {
+			return fmt.Errorf("Invalid %s %d, must be 0 or between %d and %d",
+				configStreamsWriteBufferSize, size, commitlog.MinWriteBufferSize,
+				commitlog.MaxWriteBufferSize)
+		}
+		config.Streams.WriteBufferSize = size
+	}
+
+	if v.IsSet(configStreamsQuotaMaxBytes) {
+		config.Streams.QuotaMaxBytes = v.GetInt64(configStreamsQuotaMaxBytes)
+	}
+
+	if v.IsSet(configStreamsQuotaPolicy) {
+		policy, err := parseQuotaPolicy(v.GetString(configStreamsQuotaPolicy))
+		if err != nil {
+			return err
+		}
+		config.Streams.QuotaPolicy = policy
+	}
+
+	if v.IsSet(configStreamsIndexAccess) {
+		access, err := parseIndexAccess(v.GetString(configStreamsIndexAccess))
+		if err != nil {
+			return err
+		}
+		config.Streams.IndexAccess = access
+	}
+
+	if v.IsSet(configStreamsIndexInterval) {
+		interval := v.GetInt(configStreamsIndexInterval)
+		if interval < 0 {
+			return fmt.Errorf("Invalid %s %d, must not be negative", configStreamsIndexInterval, interval)
+		}
+		config.Streams.IndexInterval = interval
+	}
+
+	if v.IsSet(configStreamsAutoCreateRules) {
+		rules, err := parseAutoCreateRules(v)
+		if err != nil {
+			return err
+		}
+		config.Streams.AutoCreateRules = rules
+	}
+
+	if v.IsSet(configStreamsAutoCreateMax) {
+		config.Streams.AutoCreateMax = v.GetInt(configStreamsAutoCreateMax)
+	}
+
+	if v.IsSet(configStreamsIngestMaxPendingMsgs) {
+		config.Streams.IngestMaxPendingMessages = v.GetInt(configStreamsIngestMaxPendingMsgs)
+	}
+
+	if v.IsSet(configStreamsIngestMaxPendingSize) {
+		config.Streams.IngestMaxPendingBytes = v.GetInt(configStreamsIngestMaxPendingSize)
+	}
+
+	if v.IsSet(configStreamsIngestDropPause) {
+		config.Streams.IngestDropPause = v.GetDuration(configStreamsIngestDropPause)
+	}
+
+	if v.IsSet(configStreamsPausedRejectSubs) {
+		config.Streams.PausedRejectSubscriptions = v.GetBool(configStreamsPausedRejectSubs)
+	}
+
+	if v.IsSet(configStreamsDirShards) {
+		config.Streams.DirShards = v.GetInt(configStreamsDirShards)
+	}
+
+	if v.IsSet(configStreamsIdleDeleteTime) {
+		config.Streams.IdleDeleteTime = v.GetDuration(configStreamsIdleDeleteTime)
+	}
+
+	if v.IsSet(configStreamsMaxPendingAcks) {
+		config.Streams.MaxPendingAcks = v.GetInt64(configStreamsMaxPendingAcks)
+	}
+
+	if v.IsSet(configStreamsMaxAppendLatency) {
+		config.Streams.MaxAppendLatency = v.GetDuration(configStreamsMaxAppendLatency)
+	}
+
+	if v.IsSet(configStreamsMaxDeliveryDelay) {
+		config.Streams.MaxDeliveryDelay = v.GetDuration(configStreamsMaxDeliveryDelay)
+		if config.Streams.MaxDeliveryDelay <= 0 {
+			return fmt.Errorf("Invalid %s %s: must be positive",
+				configStreamsMaxDeliveryDelay, config.Streams.MaxDeliveryDelay)
+		}
+	}
+
+	if v.IsSet(configStreamsMaxCommitWaiters) {
+		config.Streams.MaxCommitWaiters = v.GetInt(configStreamsMaxCommitWaiters)
+		if config.Streams.MaxCommitWaiters < 0 {
+			return fmt.Errorf("Invalid %s %d: must be non-negative",
+				configStreamsMaxCommitWaiters, config.Streams.MaxCommitWaiters)
+		}
+	}
+
+	if v.IsSet(configStreamsCommitWaitersPolicy) {
+		policy, err := parseCommitWaitersPolicy(v.GetString(configStreamsCommitWaitersPolicy))
+		if err != nil {
+			return err
+		}
+		config.Streams.CommitWaitersPolicy = policy
+	}
+
 	return nil
 }
 
@@ -515,6 +1151,10 @@ func parseClusteringConfig(config *Config, v *viper.Viper) error { // nolint: go
 		config.Clustering.ReplicaMaxLagTime = v.GetDuration(configClusteringReplicaMaxLagTime)
 	}
 
+	if v.IsSet(configClusteringReplicaMaxLagOffsets) {
+		config.Clustering.ReplicaMaxLagOffsets = v.GetInt64(configClusteringReplicaMaxLagOffsets)
+	}
+
 	if v.IsSet(configClusteringReplicaMaxLeaderTimeout) {
 		config.Clustering.ReplicaMaxLeaderTimeout = v.GetDuration(configClusteringReplicaMaxLeaderTimeout)
 	}
@@ -531,6 +1171,86 @@ func parseClusteringConfig(config *Config, v *viper.Viper) error { // nolint: go
 		config.Clustering.MinISR = v.GetInt(configClusteringMinInsyncReplicas)
 	}
 
+	if v.IsSet(configClusteringReplicaCatchUpMaxBytes) {
+		config.Clustering.ReplicaCatchUpMaxBytesPerSec = v.GetInt64(configClusteringReplicaCatchUpMaxBytes)
+	}
+
+	if v.IsSet(configClusteringReplicaHWUpdateInterval) {
+		config.Clustering.ReplicaHWUpdateInterval = v.GetDuration(configClusteringReplicaHWUpdateInterval)
+	}
+
+	if v.IsSet(configClusteringCommitCoalesceInterval) {
+		config.Clustering.CommitCoalesceInterval = v.GetDuration(configClusteringCommitCoalesceInterval)
+	}
+
+	if v.IsSet(configClusteringServerMaxReplicas) {
+		config.Clustering.ServerMaxReplicas = v.GetInt(configClusteringServerMaxReplicas)
+	}
+
+	if v.IsSet(configClusteringReplicaRebalanceThresh) {
+		config.Clustering.ReplicaRebalanceThreshold = v.GetInt(configClusteringReplicaRebalanceThresh)
+	}
+
+	if v.IsSet(configClusteringReplicaMaxCatchUpFails) {
+		config.Clustering.ReplicaMaxCatchUpFailures = v.GetInt(configClusteringReplicaMaxCatchUpFails)
+		if config.Clustering.ReplicaMaxCatchUpFailures < 0 {
+			return fmt.Errorf("Invalid %s %d, must not be negative",
+				configClusteringReplicaMaxCatchUpFails, config.Clustering.ReplicaMaxCatchUpFailures)
+		}
+	}
+
+	if v.IsSet(configClusteringLeaderElectionPref) {
+		pref, err := parseLeaderElectionPreference(v.GetString(configClusteringLeaderElectionPref))
+		if err != nil {
+			return err
+		}
+		config.Clustering.LeaderElectionPreference = pref
+	}
+
+	if v.IsSet(configClusteringReplicaTruncateFallback) {
+		fallback, err := parseTruncateFallback(v.GetString(configClusteringReplicaTruncateFallback))
+		if err != nil {
+			return err
+		}
+		config.Clustering.ReplicaTruncateFallback = fallback
+	}
+
+	if v.IsSet(configClusteringReplicaSyncSource) {
+		config.Clustering.ReplicaSyncSource = v.GetString(configClusteringReplicaSyncSource)
+	}
+
+	if v.IsSet(configClusteringPublishElectionPolicy) {
+		policy, err := parsePublishElectionPolicy(v.GetString(configClusteringPublishElectionPolicy))
+		if err != nil {
+			return err
+		}
+		config.Clustering.PublishElectionPolicy = policy
+	}
+
+	if v.IsSet(configClusteringPublishElectionTimeout) {
+		config.Clustering.PublishElectionTimeout = v.GetDuration(configClusteringPublishElectionTimeout)
+		if config.Clustering.PublishElectionTimeout <= 0 {
+			return fmt.Errorf("Invalid %s %s, must be greater than 0",
+				configClusteringPublishElectionTimeout, config.Clustering.PublishElectionTimeout)
+		}
+	}
+
+	if v.IsSet(configClusteringMetadataExportPath) {
+		config.Clustering.MetadataExportPath = v.GetString(configClusteringMetadataExportPath)
+	}
+
+	if v.IsSet(configClusteringMetadataExportInterval) {
+		config.Clustering.MetadataExportInterval = v.GetDuration(configClusteringMetadataExportInterval)
+		if config.Clustering.MetadataExportInterval <= 0 {
+			return fmt.Errorf("Invalid %s %s, must be greater than 0",
+				configClusteringMetadataExportInterval, config.Clustering.MetadataExportInterval)
+		}
+	}
+
+	if v.IsSet(configClusteringMetadataBootstrapPath) {
+		config.Clustering.MetadataBootstrapPath = v.GetString(configClusteringMetadataBootstrapPath)
+	}
+
 	return nil
 }
 
@@ -585,6 +1305,124 @@ func parseListen(v *viper.Viper) (*HostPort, error) {
 	return hp, nil
 }
 
+// parseCompactWindow will parse the streams' `compact.window` option, a daily
+// UTC time range in the form HH:MM-HH:MM, into offsets from midnight.
+func parseCompactWindow(window string) (time.Duration, time.Duration, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid %s setting %q", configStreamsCompactWindow, window)
+	}
+	var bounds [2]time.Duration
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid %s setting %q", configStreamsCompactWindow, window)
+		}
+		bounds[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// parseAutoCreateRules will parse the streams' `auto.create.rules` option
+// containing the list of rules for automatically creating streams.
+func parseAutoCreateRules(v *viper.Viper) ([]AutoCreateRule, error) {
+	var rules []AutoCreateRule
+	if err := v.UnmarshalKey(configStreamsAutoCreateRules, &rules); err != nil {
+		return nil, errors.Wrap(err, "invalid stream auto-create rules")
+	}
+	for i, rule := range rules {
+		if rule.Subject == "" {
+			return nil, fmt.Errorf("Stream auto-create rule %d has no subject", i)
+		}
+		if err := validateAutoCreateName(rule); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// parseQuotaPolicy will parse the streams' `quota.policy` option containing
+// the behavior when a stream log reaches its byte quota.
+func parseQuotaPolicy(policy string) (commitlog.QuotaPolicy, error) {
+	switch policy {
+	case "reject":
+		return commitlog.QuotaPolicyReject, nil
+	case "evict":
+		return commitlog.QuotaPolicyEvict, nil
+	default:
+		return commitlog.QuotaPolicyReject, fmt.Errorf("Unknown stream quota policy %q", policy)
+	}
+}
+
+// parseIndexAccess will parse the streams' `index.access` option containing
+// how segment index files are accessed.
+func parseIndexAccess(access string) (commitlog.IndexAccess, error) {
+	switch access {
+	case "mmap":
+		return commitlog.IndexAccessMmap, nil
+	case "read":
+		return commitlog.IndexAccessRead, nil
+	default:
+		return commitlog.IndexAccessMmap, fmt.Errorf("Unknown stream index access %q", access)
+	}
+}
+
+// parseTruncateFallback will parse the clustering `replica.truncate.fallback`
+// option containing how a follower truncates its log when it can't reach the
+// leader.
+func parseTruncateFallback(fallback string) (TruncateFallback, error) {
+	switch fallback {
+	case "hw":
+		return TruncateFallbackHW, nil
+	case "leader":
+		return TruncateFallbackLeader, nil
+	default:
+		return TruncateFallbackHW, fmt.Errorf("Unknown replica truncate fallback %q", fallback)
+	}
+}
+
+// parseLeaderElectionPreference will parse the clustering
+// `leader.election.preference` option containing how a new partition leader is
+// chosen from the ISR.
+func parseLeaderElectionPreference(pref string) (LeaderElectionPreference, error) {
+	switch pref {
+	case "offset":
+		return LeaderElectionOffset, nil
+	case "random":
+		return LeaderElectionRandom, nil
+	default:
+		return LeaderElectionOffset, fmt.Errorf("Unknown leader election preference %q", pref)
+	}
+}
+
+// parsePublishElectionPolicy will parse the clustering
+// `publish.election.policy` option containing how publishes to a partition
+// whose leader is being re-elected are handled.
+func parsePublishElectionPolicy(policy string) (PublishElectionPolicy, error) {
+	switch policy {
+	case "queue":
+		return PublishElectionQueue, nil
+	case "fail":
+		return PublishElectionFail, nil
+	default:
+		return PublishElectionQueue, fmt.Errorf("Unknown publish election policy %q", policy)
+	}
+}
+
+// parseCommitWaitersPolicy will parse the streams `commit.waiters.policy`
+// option containing how publishes beyond a stream's max commit waiters are
+// handled.
+func parseCommitWaitersPolicy(policy string) (CommitWaitersPolicy, error) {
+	switch policy {
+	case "reject":
+		return CommitWaitersReject, nil
+	case "block":
+		return CommitWaitersBlock, nil
+	default:
+		return CommitWaitersReject, fmt.Errorf("Unknown commit waiters policy %q", policy)
+	}
+}
+
 // parseAckPolicy will parse the activity stream's `ack.policy` option
 // containing the ack policy to use when publishing activity events.
 func parseAckPolicy(v *viper.Viper) (client.AckPolicy, error) {
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	client "github.com/liftbridge-io/liftbridge-api/go"
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
 )
 
 // Ensure NewConfig properly parses config files.
@@ -25,15 +26,51 @@ func TestNewConfigFromFile(t *testing.T) {
 	require.Equal(t, 10, config.BatchMaxMessages)
 	require.Equal(t, time.Second, config.BatchMaxTime)
 	require.Equal(t, time.Minute, config.MetadataCacheMaxAge)
+	require.Equal(t, 30*time.Second, config.MetadataClientTTL)
+	require.Equal(t, 500, config.ProducerMaxSessions)
+
+	require.Equal(t, 8388608, config.GRPC.MaxRecvMessageSize)
+	require.Equal(t, 16777216, config.GRPC.MaxSendMessageSize)
+	require.Equal(t, 30*time.Second, config.GRPC.KeepaliveTime)
+	require.Equal(t, 10*time.Second, config.GRPC.KeepaliveTimeout)
+	require.Equal(t, 10*time.Second, config.GRPC.KeepaliveMinTime)
+	require.True(t, config.GRPC.KeepalivePermitWithoutStream)
 
 	require.Equal(t, int64(1024), config.Streams.RetentionMaxBytes)
 	require.Equal(t, int64(100), config.Streams.RetentionMaxMessages)
+	require.Equal(t, 10, config.Streams.RetentionMaxSegments)
+	require.Equal(t, 10*time.Minute, config.Streams.RetentionReaderMaxDelay)
+	require.Equal(t, int64(2048), config.Streams.QuotaMaxBytes)
+	require.Equal(t, commitlog.QuotaPolicyEvict, config.Streams.QuotaPolicy)
 	require.Equal(t, time.Hour, config.Streams.RetentionMaxAge)
 	require.Equal(t, time.Minute, config.Streams.CleanerInterval)
 	require.Equal(t, int64(64), config.Streams.SegmentMaxBytes)
 	require.Equal(t, time.Minute, config.Streams.SegmentMaxAge)
 	require.True(t, config.Streams.Compact)
 	require.Equal(t, 2, config.Streams.CompactMaxGoroutines)
+	require.Equal(t, time.Hour, config.Streams.CompactWindowStart)
+	require.Equal(t, 5*time.Hour, config.Streams.CompactWindowEnd)
+	require.Equal(t, 0.5, config.Streams.CompactMinDirtyRatio)
+	require.True(t, config.Streams.SyncWrites)
+	require.Equal(t, 65536, config.Streams.WriteBufferSize)
+	require.Equal(t, commitlog.IndexAccessRead, config.Streams.IndexAccess)
+	require.Equal(t, 64, config.Streams.IndexInterval)
+	require.Equal(t, 10, config.Streams.AutoCreateMax)
+	require.Equal(t, 10000, config.Streams.IngestMaxPendingMessages)
+	require.Equal(t, 1048576, config.Streams.IngestMaxPendingBytes)
+	require.Equal(t, 100*time.Millisecond, config.Streams.IngestDropPause)
+	require.True(t, config.Streams.PausedRejectSubscriptions)
+	require.Equal(t, 16, config.Streams.DirShards)
+	require.Equal(t, time.Hour, config.Streams.IdleDeleteTime)
+	require.Equal(t, int64(5000), config.Streams.MaxPendingAcks)
+	require.Equal(t, 500*time.Millisecond, config.Streams.MaxAppendLatency)
+	require.Equal(t, time.Hour, config.Streams.MaxDeliveryDelay)
+	require.Equal(t, 1000, config.Streams.MaxCommitWaiters)
+	require.Equal(t, CommitWaitersBlock, config.Streams.CommitWaitersPolicy)
+	require.Equal(t, []AutoCreateRule{
+		{Subject: "events.*.*", Name: "events-{1}", Partitions: 2, ReplicationFactor: 3},
+		{Subject: "logs.>"},
+	}, config.Streams.AutoCreateRules)
 
 	require.Equal(t, "foo", config.Clustering.ServerID)
 	require.Equal(t, "bar", config.Clustering.Namespace)
@@ -42,10 +79,25 @@ func TestNewConfigFromFile(t *testing.T) {
 	require.Equal(t, 5, config.Clustering.RaftCacheSize)
 	require.Equal(t, []string{"a", "b"}, config.Clustering.RaftBootstrapPeers)
 	require.Equal(t, time.Minute, config.Clustering.ReplicaMaxLagTime)
+	require.Equal(t, int64(1000), config.Clustering.ReplicaMaxLagOffsets)
 	require.Equal(t, 30*time.Second, config.Clustering.ReplicaMaxLeaderTimeout)
 	require.Equal(t, 2*time.Second, config.Clustering.ReplicaMaxIdleWait)
 	require.Equal(t, 3*time.Second, config.Clustering.ReplicaFetchTimeout)
 	require.Equal(t, 1, config.Clustering.MinISR)
+	require.Equal(t, int64(1048576), config.Clustering.ReplicaCatchUpMaxBytesPerSec)
+	require.Equal(t, 50*time.Millisecond, config.Clustering.ReplicaHWUpdateInterval)
+	require.Equal(t, 5*time.Millisecond, config.Clustering.CommitCoalesceInterval)
+	require.Equal(t, 100, config.Clustering.ServerMaxReplicas)
+	require.Equal(t, 2, config.Clustering.ReplicaRebalanceThreshold)
+	require.Equal(t, LeaderElectionRandom, config.Clustering.LeaderElectionPreference)
+	require.Equal(t, TruncateFallbackLeader, config.Clustering.ReplicaTruncateFallback)
+	require.Equal(t, "b", config.Clustering.ReplicaSyncSource)
+	require.Equal(t, 5, config.Clustering.ReplicaMaxCatchUpFailures)
+	require.Equal(t, PublishElectionFail, config.Clustering.PublishElectionPolicy)
+	require.Equal(t, 10*time.Second, config.Clustering.PublishElectionTimeout)
+	require.Equal(t, "/mnt/backup/metadata", config.Clustering.MetadataExportPath)
+	require.Equal(t, 30*time.Second, config.Clustering.MetadataExportInterval)
+	require.Equal(t, "/mnt/restore/metadata", config.Clustering.MetadataBootstrapPath)
 
 	require.Equal(t, true, config.ActivityStream.Enabled)
 	require.Equal(t, time.Minute, config.ActivityStream.PublishTimeout)
@@ -117,6 +169,19 @@ func TestNewConfigInvalidClusteringSetting(t *testing.T) {
 	require.Error(t, err)
 }
 
+// Ensure an error is returned when the write buffer size is out of bounds.
+func TestNewConfigInvalidWriteBufferSize(t *testing.T) {
+	_, err := NewConfig("configs/invalid-write-buffer.yaml")
+	require.Error(t, err)
+}
+
+// Ensure an error is returned when the gRPC keepalive timeout is not less than
+// the keepalive time.
+func TestNewConfigInvalidGRPCKeepalive(t *testing.T) {
+	_, err := NewConfig("configs/invalid-grpc-keepalive.yaml")
+	require.Error(t, err)
+}
+
 // Ensure an error is returned when there is an unknown setting in the file.
 func TestNewConfigUnknownSetting(t *testing.T) {
 	_, err := NewConfig("configs/unknown-setting.yaml")
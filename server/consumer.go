@@ -0,0 +1,469 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	atomic_file "github.com/natefinch/atomic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	client "github.com/liftbridge-io/liftbridge-api/go"
+)
+
+// consumersDir is the directory in a partition's data directory containing the
+// cursors of its durable consumers, one file per consumer.
+const consumersDir = "consumers"
+
+var (
+	// ErrConsumerActive is returned by SetConsumerOffset when the durable
+	// consumer has an open subscription.
+	ErrConsumerActive = errors.New("consumer has an active subscription")
+
+	// ErrConsumerInactive is returned by Ack when the durable consumer has
+	// no open subscription.
+	ErrConsumerInactive = errors.New("consumer has no active subscription")
+)
+
+// consumer is a durable consumer of a partition. Its cursor is the offset of
+// the next message to deliver to it, which a Subscribe with its name resumes
+// from. The cursor is persisted in the partition's data directory so that it
+// survives restarts, but it's local to the server the consumer subscribes to,
+// i.e. the partition leader. A manual-ack consumer's cursor is instead the
+// offset of its oldest message not yet acknowledged.
+type consumer struct {
+	name          string
+	partition     *partition
+	mu            sync.Mutex
+	offset        int64           // Offset of the next message to deliver, -1 if none
+	output        *consumerOutput // Partition output is committed to, nil if none
+	persisted     string          // State last written to disk
+	persistedAt   time.Time
+	active        bool    // Set while a subscription is open
+	manualAck     bool    // Set if the subscription acknowledges messages
+	maxUnacked    int     // Max messages pending acknowledgement, 0 if unbounded
+	pending       []int64 // Offsets delivered but not acknowledged, in order
+	lastDelivered int64
+	attempts      map[int64]int // Deliveries of messages not acknowledged, by offset
+	acked         chan struct{} // Signaled when a pending message is acknowledged
+}
+
+// SetConsumerOffset sets the cursor of the durable consumer with the given
+// name on the given stream partition, e.g. to rewind or skip messages, so the
+// consumer resumes from the offset when it next subscribes. The consumer is
+// created if it doesn't exist. The offset must be between the partition's
+// oldest offset and one past its newest offset. This must be called on the
+// partition leader, otherwise ErrNotPartitionLeader is returned. Like every
+// cursor, it's only stored on the leader and doesn't carry over to a new one.
+// ErrConsumerActive is returned if the consumer has an open subscription.
+// ErrStreamNotFound or ErrPartitionNotFound is returned if the stream or
+// partition does not exist.
+func (s *Server) SetConsumerOffset(stream string, partitionID int32, name string, offset int64) error {
+	if !validConnectorName(name) {
+		return fmt.Errorf("invalid consumer name %q", name)
+	}
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return err
+	}
+	partition := partitions[0]
+	if leader, _ := partition.GetLeader(); leader != s.config.Clustering.ServerID {
+		return ErrNotPartitionLeader
+	}
+	oldest, newest := partition.log.OldestOffset(), partition.log.NewestOffset()
+	if offset < oldest || offset > newest+1 {
+		return fmt.Errorf("offset %d out of range, oldest offset %d, newest offset %d",
+			offset, oldest, newest)
+	}
+	c, err := partition.getConsumer(name)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active {
+		return ErrConsumerActive
+	}
+	c.offset = offset
+	c.attempts = nil
+	return c.persist()
+}
+
+// Ack acknowledges the message at the given offset delivered to the
+// manual-ack durable consumer with the given name on the given stream
+// partition. The consumer's cursor advances past every message acknowledged
+// so far without a gap, and a slot opens in its window of unacknowledged
+// messages. Offsets which are not pending acknowledgement, e.g. those already
+// acknowledged, are ignored. This must be called on the server the consumer
+// is subscribed to, otherwise ErrConsumerInactive is returned. Messages whose
+// acknowledgement is lost are redelivered when the consumer resubscribes.
+// ErrStreamNotFound or ErrPartitionNotFound is returned if the stream or
+// partition does not exist.
+func (s *Server) Ack(stream string, partitionID int32, name string, offset int64) error {
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return err
+	}
+	c := partitions[0].lookupConsumer(name)
+	if c == nil {
+		return ErrConsumerInactive
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.active || !c.manualAck {
+		return ErrConsumerInactive
+	}
+	i := sort.Search(len(c.pending), func(i int) bool { return c.pending[i] >= offset })
+	if i == len(c.pending) || c.pending[i] != offset {
+		return nil
+	}
+	c.pending = append(c.pending[:i], c.pending[i+1:]...)
+	delete(c.attempts, offset)
+	c.advance()
+	select {
+	case c.acked <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// AckThrough acknowledges every message up to and including the given offset
+// delivered to the manual-ack durable consumer with the given name on the
+// given stream partition in one call, e.g. once the consumer processed a
+// batch, rather than acknowledging each message with Ack. The consumer's
+// cursor advances past them and their slots open in its window of
+// unacknowledged messages. This must be called on the server the consumer is
+// subscribed to, otherwise ErrConsumerInactive is returned. ErrStreamNotFound
+// or ErrPartitionNotFound is returned if the stream or partition does not
+// exist.
+func (s *Server) AckThrough(stream string, partitionID int32, name string, offset int64) error {
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return err
+	}
+	c := partitions[0].lookupConsumer(name)
+	if c == nil {
+		return ErrConsumerInactive
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.active || !c.manualAck {
+		return ErrConsumerInactive
+	}
+	c.ackThrough(offset)
+	return nil
+}
+
+// partitionDataDir returns the directory containing the data for the given
+// stream partition.
+func (s *Server) partitionDataDir(stream string, partitionID int32) string {
+	return filepath.Join(s.streamDataDir(stream), strconv.FormatInt(int64(partitionID), 10))
+}
+
+// getConsumer returns the durable consumer of the partition with the given
+// name, loading its cursor from disk if it's not loaded yet.
+func (p *partition) getConsumer(name string) (*consumer, error) {
+	p.consumersMu.Lock()
+	defer p.consumersMu.Unlock()
+	if c, ok := p.consumers[name]; ok {
+		return c, nil
+	}
+	c := &consumer{name: name, partition: p, offset: -1, acked: make(chan struct{}, 1)}
+	data, err := ioutil.ReadFile(c.file())
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, errors.Wrap(err, "failed to read consumer cursor")
+	default:
+		if err := c.decode(string(data)); err != nil {
+			return nil, errors.Wrapf(err, "invalid cursor for consumer %s", name)
+		}
+		c.persisted = string(data)
+	}
+	if c.output != nil {
+		p.srv.recoverConsumerOutput(c)
+	}
+	if p.consumers == nil {
+		p.consumers = make(map[string]*consumer)
+	}
+	p.consumers[name] = c
+	return c, nil
+}
+
+// lookupConsumer returns the durable consumer of the partition with the given
+// name if it's loaded, otherwise nil. Consumers with an open subscription are
+// always loaded.
+func (p *partition) lookupConsumer(name string) *consumer {
+	p.consumersMu.Lock()
+	defer p.consumersMu.Unlock()
+	return p.consumers[name]
+}
+
+// acquireConsumer returns the durable consumer a Subscribe resumes based on
+// the request metadata, marked active so that it can't be subscribed to
+// concurrently, or nil if the Subscribe isn't for a durable consumer. The
+// caller must release the consumer when the subscription ends.
+func (a *apiServer) acquireConsumer(ctx context.Context, partitions []*partition) (*consumer, *status.Status) {
+	var (
+		name, ok   = getMetadataValue(ctx, ConsumerMetadataKey)
+		manualAck  = getMetadataFlag(ctx, ManualAckMetadataKey)
+		maxUnacked int64
+		err        error
+	)
+	if manualAck && !ok {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+			"Manual ack requires a durable consumer")
+	}
+	if !ok {
+		return nil, nil
+	}
+	if manualAck {
+		if maxUnacked, err = getMetadataInt(ctx, MaxUnackedMetadataKey, 32); err != nil {
+			return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument, err.Error())
+		}
+	} else if _, ok := getMetadataValue(ctx, MaxUnackedMetadataKey); ok {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+			"Max unacked requires manual ack")
+	}
+	if !validConnectorName(name) {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+			fmt.Sprintf("Invalid %s %q", ConsumerMetadataKey, name))
+	}
+	if len(partitions) > 1 {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+			"Durable consumer can't follow multiple streams")
+	}
+	if _, ok := getMetadataValue(ctx, StartTimeDeltaMetadataKey); ok {
+		return nil, newStatus(codes.InvalidArgument, ErrorCodeInvalidArgument,
+			"Durable consumer can't be combined with start time delta")
+	}
+	c, err := partitions[0].getConsumer(name)
+	if err != nil {
+		return nil, newStatus(codes.Internal, ErrorCodeInternal, err.Error())
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active {
+		return nil, newStatus(codes.FailedPrecondition, ErrorCodeFailedPrecondition,
+			fmt.Sprintf("Consumer %s already has an active subscription", name))
+	}
+	c.active = true
+	c.manualAck = manualAck
+	c.maxUnacked = int(maxUnacked)
+	c.lastDelivered = c.offset - 1
+	return c, nil
+}
+
+// resume returns the Subscribe request starting at the consumer's cursor, or
+// the given request if the consumer has no cursor yet.
+func (c *consumer) resume(req *client.SubscribeRequest) *client.SubscribeRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.offset < 0 {
+		return req
+	}
+	resumed := *req
+	resumed.StartPosition = client.StartPosition_OFFSET
+	resumed.StartOffset = c.offset
+	return &resumed
+}
+
+// deliver records the given message as pending acknowledgement before it's
+// sent to a manual-ack consumer, first waiting for room in the consumer's
+// window, and sets its DeliveryAttemptHeader header. It returns false if the
+// Context is canceled while waiting. Markers and control messages, which are
+// not messages in the log, are not recorded.
+func (c *consumer) deliver(ctx context.Context, msg *client.Message) bool {
+	if !isLogMessage(msg) {
+		return true
+	}
+	for {
+		c.mu.Lock()
+		if !c.manualAck {
+			c.mu.Unlock()
+			return true
+		}
+		if c.maxUnacked == 0 || len(c.pending) < c.maxUnacked {
+			c.pending = append(c.pending, msg.Offset)
+			c.lastDelivered = msg.Offset
+			if c.attempts == nil {
+				c.attempts = make(map[int64]int)
+			}
+			c.attempts[msg.Offset]++
+			if msg.Headers == nil {
+				msg.Headers = make(map[string][]byte)
+			}
+			msg.Headers[DeliveryAttemptHeader] = []byte(strconv.Itoa(c.attempts[msg.Offset]))
+			c.mu.Unlock()
+			return true
+		}
+		c.mu.Unlock()
+		select {
+		case <-c.acked:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// delivered advances the cursor of a consumer which doesn't acknowledge
+// messages past the given message once it has been sent to the consumer.
+// Markers and control messages are ignored.
+func (c *consumer) delivered(msg *client.Message) {
+	if !isLogMessage(msg) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.manualAck {
+		return
+	}
+	c.offset = msg.Offset + 1
+	c.checkpoint()
+}
+
+// ackThrough acknowledges the messages pending acknowledgement up to and
+// including the given offset. This must be called with the consumer lock
+// held.
+func (c *consumer) ackThrough(offset int64) {
+	i := sort.Search(len(c.pending), func(i int) bool { return c.pending[i] > offset })
+	if i == 0 {
+		return
+	}
+	for _, acked := range c.pending[:i] {
+		delete(c.attempts, acked)
+	}
+	c.pending = c.pending[i:]
+	c.advance()
+	select {
+	case c.acked <- struct{}{}:
+	default:
+	}
+}
+
+// advance moves a manual-ack consumer's cursor to its oldest message pending
+// acknowledgement, or past the last message delivered if there is none. This
+// must be called with the consumer lock held.
+func (c *consumer) advance() {
+	if len(c.pending) > 0 {
+		c.offset = c.pending[0]
+	} else {
+		c.offset = c.lastDelivered + 1
+	}
+	c.checkpoint()
+}
+
+// release persists the consumer's cursor and marks it inactive once its
+// subscription ends. Messages pending acknowledgement are redelivered when
+// the consumer resubscribes. Their delivery attempts are kept in memory, so
+// they are counted from the first delivery after a restart.
+func (c *consumer) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active = false
+	c.pending = nil
+	for offset := range c.attempts {
+		if offset < c.offset {
+			delete(c.attempts, offset)
+		}
+	}
+	if err := c.persist(); err != nil {
+		c.partition.srv.logger.Errorf("Failed to persist cursor of consumer %s on partition %s: %v",
+			c.name, c.partition, err)
+	}
+}
+
+// checkpoint persists the consumer's cursor at most once per
+// connectorCheckpointInterval. This must be called with the consumer lock
+// held.
+func (c *consumer) checkpoint() {
+	if time.Since(c.persistedAt) < connectorCheckpointInterval {
+		return
+	}
+	if err := c.persist(); err != nil {
+		c.partition.srv.logger.Errorf("Failed to persist cursor of consumer %s on partition %s: %v",
+			c.name, c.partition, err)
+	}
+}
+
+// persist writes the consumer's cursor, and the partition its output is
+// committed to, if any, to disk if they changed. This must be called with the
+// consumer lock held.
+func (c *consumer) persist() error {
+	data := c.encode()
+	if data == c.persisted {
+		return nil
+	}
+	file := c.file()
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return errors.Wrap(err, "failed to create consumers directory")
+	}
+	if err := atomic_file.WriteFile(file, strings.NewReader(data)); err != nil {
+		return err
+	}
+	c.persisted = data
+	c.persistedAt = time.Now()
+	return nil
+}
+
+// encode returns the consumer's persisted state: its cursor on the first
+// line followed, if it commits output, by the output stream, partition, and
+// the offset from which the output partition may contain commits newer than
+// the cursor, one per line.
+func (c *consumer) encode() string {
+	data := strconv.FormatInt(c.offset, 10)
+	if c.output != nil {
+		data += fmt.Sprintf("\n%s\n%d\n%d", c.output.stream, c.output.partition, c.output.from)
+	}
+	return data
+}
+
+// decode sets the consumer's state from its persisted state.
+func (c *consumer) decode(data string) (err error) {
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	if len(lines) != 1 && len(lines) != 4 {
+		return errors.New("unexpected number of lines")
+	}
+	if c.offset, err = strconv.ParseInt(lines[0], 10, 64); err != nil {
+		return err
+	}
+	if len(lines) == 1 {
+		return nil
+	}
+	output := &consumerOutput{stream: lines[1]}
+	partition, err := strconv.ParseInt(lines[2], 10, 32)
+	if err != nil {
+		return err
+	}
+	output.partition = int32(partition)
+	if output.from, err = strconv.ParseInt(lines[3], 10, 64); err != nil {
+		return err
+	}
+	c.output = output
+	return nil
+}
+
+// file returns the path of the file containing the consumer's cursor.
+func (c *consumer) file() string {
+	return filepath.Join(c.partition.srv.partitionDataDir(c.partition.Stream, c.partition.Id),
+		consumersDir, c.name)
+}
+
+// isLogMessage indicates if the delivered message is a message in the log
+// rather than a caught-up marker or control message.
+func isLogMessage(msg *client.Message) bool {
+	if _, ok := msg.Headers[ControlHeader]; ok {
+		return false
+	}
+	_, ok := msg.Headers[CaughtUpHeader]
+	return !ok
+}
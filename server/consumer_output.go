@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
+)
+
+const (
+	// ConsumerHeader is the message header containing the name of the
+	// durable consumer whose cursor was committed with the output it's on by
+	// CommitConsumerOutput. It's set on the last message of the output.
+	ConsumerHeader = "consumer"
+
+	// ConsumerStreamHeader is the message header containing the stream of
+	// the durable consumer in the ConsumerHeader.
+	ConsumerStreamHeader = "consumerStream"
+
+	// ConsumerPartitionHeader is the message header containing the partition
+	// of the durable consumer in the ConsumerHeader as a decimal string.
+	ConsumerPartitionHeader = "consumerPartition"
+
+	// ConsumerOffsetHeader is the message header containing the cursor
+	// committed for the durable consumer in the ConsumerHeader as a decimal
+	// string.
+	ConsumerOffsetHeader = "consumerOffset"
+)
+
+// ErrConsumerAutoAck is returned by CommitConsumerOutput when the durable
+// consumer's open subscription advances its cursor as messages are delivered
+// rather than as they are acknowledged.
+var ErrConsumerAutoAck = errors.New("consumer subscription does not use manual ack")
+
+// consumerOutputCommitted is called by CommitConsumerOutput once the output
+// was appended and before the consumer's cursor is advanced. An error aborts
+// the commit. This is a var for testing purposes.
+var consumerOutputCommitted = func() error { return nil }
+
+// consumerOutput is the partition a durable consumer commits output to.
+type consumerOutput struct {
+	stream    string
+	partition int32
+	from      int64 // Offset from which the output may contain newer commits
+}
+
+// CommitConsumerOutput appends the output messages a durable consumer
+// produced, e.g. a stream processor, to the output stream partition and
+// advances the consumer's cursor on the given stream partition to the given
+// offset as one operation, so a failure can't leave output without advancing
+// the cursor or the other way around. The cursor is recorded in the
+// ConsumerHeader, ConsumerStreamHeader, ConsumerPartitionHeader, and
+// ConsumerOffsetHeader headers of the last output message, which are used to
+// recover it if the server fails after appending the output. Output messages
+// must be in the log's message set format, as with ImportMessages, and are
+// appended as a single batch. It returns the offsets of the output messages.
+// This server must lead both partitions, otherwise ErrNotPartitionLeader is
+// returned. A consumer with an open subscription must use manual ack,
+// otherwise ErrConsumerAutoAck is returned, and the messages before the
+// offset are acknowledged. ErrStreamNotFound or ErrPartitionNotFound is
+// returned if a stream or partition does not exist.
+func (s *Server) CommitConsumerOutput(ctx context.Context, stream string, partitionID int32, name string,
+	offset int64, outputStream string, outputPartitionID int32, data []byte) ([]int64, error) {
+
+	if !validConnectorName(name) {
+		return nil, fmt.Errorf("invalid consumer name %q", name)
+	}
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return nil, err
+	}
+	partition := partitions[0]
+	if leader, _ := partition.GetLeader(); leader != s.config.Clustering.ServerID {
+		return nil, ErrNotPartitionLeader
+	}
+	partitions, err = s.getStreamPartitions(outputStream, []int32{outputPartitionID})
+	if err != nil {
+		return nil, err
+	}
+	output := partitions[0]
+	msgs, err := commitlog.DecodeMessageSet(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid message set")
+	}
+	if len(msgs) == 0 {
+		return nil, errors.New("no output messages")
+	}
+	if err := validateImported(output, msgs); err != nil {
+		return nil, err
+	}
+
+	c, err := partition.getConsumer(name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	oldest, newest := partition.log.OldestOffset(), partition.log.NewestOffset()
+	if c.active {
+		if !c.manualAck {
+			return nil, ErrConsumerAutoAck
+		}
+		newest = c.lastDelivered
+	}
+	if offset < oldest || offset > newest+1 {
+		return nil, fmt.Errorf("offset %d out of range, oldest offset %d, newest offset %d",
+			offset, oldest, newest)
+	}
+
+	// Record where to look for the commit before appending the output so it
+	// can be recovered if the server fails before the cursor is persisted.
+	c.output = &consumerOutput{
+		stream:    outputStream,
+		partition: outputPartitionID,
+		from:      output.log.NewestOffset() + 1,
+	}
+	if err := c.persist(); err != nil {
+		return nil, err
+	}
+	last := msgs[len(msgs)-1]
+	headers := make(map[string][]byte, len(last.Headers)+4)
+	for key, value := range last.Headers {
+		headers[key] = value
+	}
+	headers[ConsumerHeader] = []byte(name)
+	headers[ConsumerStreamHeader] = []byte(stream)
+	headers[ConsumerPartitionHeader] = []byte(strconv.FormatInt(int64(partitionID), 10))
+	headers[ConsumerOffsetHeader] = []byte(strconv.FormatInt(offset, 10))
+	last.Headers = headers
+	offsets, err := output.Import(ctx, msgs)
+	if err != nil {
+		return nil, err
+	}
+	if err := consumerOutputCommitted(); err != nil {
+		return nil, err
+	}
+
+	// Messages acknowledged individually may put an open subscription's
+	// cursor past the offset already.
+	if c.active {
+		c.ackThrough(offset - 1)
+	}
+	if !c.active || c.offset < offset {
+		c.offset = offset
+	}
+	c.output.from = offsets[len(offsets)-1] + 1
+	if err := c.persist(); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// recoverConsumerOutput advances the consumer's cursor to the latest cursor
+// committed with its output which was not persisted, i.e. when the server
+// failed after appending the output. This must be called before the consumer
+// is used.
+func (s *Server) recoverConsumerOutput(c *consumer) {
+	output := s.metadata.GetPartition(c.output.stream, c.output.partition)
+	if output == nil {
+		s.logger.Warnf("Failed to recover output of consumer %s on partition %s: "+
+			"output partition [stream=%s, partition=%d] not found",
+			c.name, c.partition, c.output.stream, c.output.partition)
+		return
+	}
+	newest := output.log.NewestOffset()
+	if c.output.from > newest {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Read uncommitted messages since the output is appended before it's
+	// committed.
+	reader, err := output.log.NewReader(c.output.from, true)
+	if err != nil {
+		s.logger.Warnf("Failed to recover output of consumer %s on partition %s: %v",
+			c.name, c.partition, err)
+		return
+	}
+	defer reader.Close()
+	var (
+		headersBuf  = make([]byte, 28)
+		partitionID = []byte(strconv.FormatInt(int64(c.partition.Id), 10))
+		last        = c.output.from - 1
+	)
+	for last < newest {
+		m, offset, _, _, err := reader.ReadMessage(ctx, headersBuf)
+		if err != nil {
+			s.logger.Warnf("Failed to recover output of consumer %s on partition %s: %v",
+				c.name, c.partition, err)
+			return
+		}
+		last = offset
+		headers := m.Headers()
+		if string(headers[ConsumerHeader]) != c.name ||
+			string(headers[ConsumerStreamHeader]) != c.partition.Stream ||
+			string(headers[ConsumerPartitionHeader]) != string(partitionID) {
+			continue
+		}
+		committed, err := strconv.ParseInt(string(headers[ConsumerOffsetHeader]), 10, 64)
+		if err != nil {
+			continue
+		}
+		if committed > c.offset {
+			s.logger.Infof("Recovered cursor %d of consumer %s on partition %s from output at offset %d",
+				committed, c.name, c.partition, offset)
+			c.offset = committed
+		}
+		c.output.from = offset + 1
+	}
+}
@@ -0,0 +1,615 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcMetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+	proto "github.com/liftbridge-io/liftbridge-api/go"
+
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
+)
+
+// subscribeConsumer subscribes to the stream as the given durable consumer,
+// starting at the earliest offset if the consumer is new, and returns the
+// subscription with the function canceling it. It retries while the
+// consumer's previous subscription is still being closed.
+func subscribeConsumer(t *testing.T, apiClient proto.APIClient, stream, name string,
+	md ...string) (proto.API_SubscribeClient, context.CancelFunc) {
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		ctx = grpcMetadata.AppendToOutgoingContext(ctx, append([]string{ConsumerMetadataKey, name}, md...)...)
+		sub, err := apiClient.Subscribe(ctx, &proto.SubscribeRequest{
+			Stream:        stream,
+			StartPosition: proto.StartPosition_EARLIEST,
+		})
+		require.NoError(t, err)
+		// The first message signals the subscription was created.
+		_, err = sub.Recv()
+		if err == nil {
+			return sub, cancel
+		}
+		cancel()
+		if status.Code(err) != codes.FailedPrecondition || time.Now().After(deadline) {
+			require.NoError(t, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// setConsumerOffset sets the durable consumer's cursor, retrying while its
+// previous subscription is still being closed.
+func setConsumerOffset(t *testing.T, s *Server, stream, name string, offset int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := s.SetConsumerOffset(stream, 0, name, offset)
+		if err != ErrConsumerActive || time.Now().After(deadline) {
+			require.NoError(t, err)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Ensure a durable consumer resumes after the last message delivered to it and
+// from the offset set with SetConsumerOffset.
+func TestSetConsumerOffset(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), "foo", name))
+	publish := func(start, end int) {
+		for i := start; i < end; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+			cancel()
+			require.NoError(t, err)
+		}
+	}
+	publish(0, 10)
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// expect receives the messages from start to end on the subscription.
+	expect := func(sub proto.API_SubscribeClient, start, end int64) {
+		for i := start; i < end; i++ {
+			msg, err := sub.Recv()
+			require.NoError(t, err)
+			require.Equal(t, i, msg.Offset)
+			require.Equal(t, []byte(strconv.FormatInt(i, 10)), msg.Value)
+		}
+	}
+
+	// A new consumer starts at the request's start position.
+	sub, cancel := subscribeConsumer(t, apiClient, name, "durable")
+	expect(sub, 0, 10)
+
+	// A consumer can only have one subscription at a time.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), ConsumerMetadataKey, "durable")
+	other, err := apiClient.Subscribe(ctx, &proto.SubscribeRequest{Stream: name})
+	require.NoError(t, err)
+	_, err = other.Recv()
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.Equal(t, ErrConsumerActive, s1.SetConsumerOffset(name, 0, "durable", 0))
+	cancel()
+
+	// The consumer resumes after the last message delivered to it.
+	sub, cancel = subscribeConsumer(t, apiClient, name, "durable")
+	publish(10, 11)
+	expect(sub, 10, 11)
+	cancel()
+
+	// Rewinding the cursor redelivers from the set offset.
+	setConsumerOffset(t, s1, name, "durable", 2)
+	sub, cancel = subscribeConsumer(t, apiClient, name, "durable")
+	expect(sub, 2, 4)
+	cancel()
+
+	// Skipping ahead does too.
+	setConsumerOffset(t, s1, name, "durable", 9)
+	sub, cancel = subscribeConsumer(t, apiClient, name, "durable")
+	expect(sub, 9, 11)
+	cancel()
+
+	// Offsets outside the log are rejected.
+	require.Error(t, s1.SetConsumerOffset(name, 0, "durable", 12))
+	require.Error(t, s1.SetConsumerOffset(name, 0, "durable", -1))
+	require.Equal(t, ErrPartitionNotFound, s1.SetConsumerOffset(name, 1, "durable", 0))
+
+	// The cursor survives a restart.
+	setConsumerOffset(t, s1, name, "durable", 6)
+	s1.Stop()
+	s1 = runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+	getMetadataLeader(t, 10*time.Second, s1)
+	waitForPartition(t, 10*time.Second, name, 0, s1)
+	waitForHW(t, 10*time.Second, name, 0, 10, s1)
+	sub, cancel = subscribeConsumer(t, apiClient, name, "durable")
+	defer cancel()
+	expect(sub, 6, 11)
+}
+
+// Ensure a durable consumer's cursor stays on the partition leader which
+// stored it: after a leader failover, the consumer starts at the
+// subscription's start position on the new leader, which keeps its own cursor
+// from then on.
+func TestConsumerCursorFailover(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers.
+	servers := make([]*Server, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxLeaderTimeout = time.Second
+		config.Clustering.ReplicaMaxLagTime = 2 * time.Second
+		config.Clustering.ReplicaMaxIdleWait = 500 * time.Millisecond
+		config.Clustering.ReplicaFetchTimeout = 500 * time.Millisecond
+		servers[i] = runServerWithConfig(t, config)
+		defer servers[i].Stop()
+	}
+
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), "foo", name, lift.ReplicationFactor(3)))
+	publish := func(start, end int) {
+		for i := start; i < end; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+			cancel()
+			require.NoError(t, err)
+		}
+	}
+	publish(0, 5)
+	waitForHW(t, 10*time.Second, name, 0, 4, servers...)
+
+	// subscribe subscribes as the durable consumer on the given server.
+	subscribe := func(s *Server) (proto.API_SubscribeClient, context.CancelFunc) {
+		conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", s.config.Port), grpc.WithInsecure())
+		require.NoError(t, err)
+		sub, cancel := subscribeConsumer(t, proto.NewAPIClient(conn), name, "durable")
+		return sub, func() {
+			cancel()
+			conn.Close()
+		}
+	}
+
+	// expect receives the messages from start to end on the subscription.
+	expect := func(sub proto.API_SubscribeClient, start, end int64) {
+		for i := start; i < end; i++ {
+			msg, err := sub.Recv()
+			require.NoError(t, err)
+			require.Equal(t, i, msg.Offset)
+		}
+	}
+
+	// Consume every message on the leader, which stores the cursor.
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	sub, cancel := subscribe(leader)
+	expect(sub, 0, 5)
+	cancel()
+	c := leader.metadata.GetPartition(name, 0).lookupConsumer("durable")
+	require.NotNil(t, c)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		offset, active := c.offset, c.active
+		c.mu.Unlock()
+		if offset == 5 && !active {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Consumer cursor not released at offset 5, got %d", offset)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Fail the leader.
+	var remaining []*Server
+	for _, s := range servers {
+		if s != leader {
+			remaining = append(remaining, s)
+		}
+	}
+	leader.Stop()
+	newLeader := getPartitionLeader(t, 10*time.Second, name, 0, remaining...)
+
+	// The cursor was not carried over, so the consumer starts at the
+	// subscription's start position.
+	sub, cancel = subscribe(newLeader)
+	expect(sub, 0, 5)
+	cancel()
+
+	// The new leader keeps the cursor from then on.
+	publish(5, 6)
+	sub, cancel = subscribe(newLeader)
+	defer cancel()
+	expect(sub, 5, 6)
+}
+
+// recvMessages receives the messages on the subscription in the background
+// until it fails.
+func recvMessages(sub proto.API_SubscribeClient) <-chan *proto.Message {
+	ch := make(chan *proto.Message, 128)
+	go func() {
+		defer close(ch)
+		for {
+			msg, err := sub.Recv()
+			if err != nil {
+				return
+			}
+			ch <- msg
+		}
+	}()
+	return ch
+}
+
+// Ensure a manual-ack consumer is not delivered more messages than its window
+// of unacknowledged messages allows until messages are acknowledged, and that
+// its cursor only advances past acknowledged messages.
+func TestConsumerMaxUnacked(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), "foo", name))
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	// A window requires manual ack, which requires a durable consumer.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), ManualAckMetadataKey, "true")
+	sub, err := apiClient.Subscribe(ctx, &proto.SubscribeRequest{Stream: name})
+	require.NoError(t, err)
+	_, err = sub.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	ctx = grpcMetadata.AppendToOutgoingContext(context.Background(),
+		ConsumerMetadataKey, "durable", MaxUnackedMetadataKey, "10")
+	sub, err = apiClient.Subscribe(ctx, &proto.SubscribeRequest{Stream: name})
+	require.NoError(t, err)
+	_, err = sub.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, ErrConsumerInactive, s1.Ack(name, 0, "durable", 0))
+
+	sub, cancel := subscribeConsumer(t, apiClient, name, "durable",
+		ManualAckMetadataKey, "true", MaxUnackedMetadataKey, "10")
+	msgs := recvMessages(sub)
+	expect := func(offset int64) {
+		select {
+		case msg := <-msgs:
+			require.Equal(t, offset, msg.Offset)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected message %d", offset)
+		}
+	}
+	for i := int64(0); i < 10; i++ {
+		expect(i)
+	}
+
+	// No 11th message is delivered until a message is acknowledged.
+	select {
+	case msg := <-msgs:
+		t.Fatalf("Unexpected message %d", msg.Offset)
+	case <-time.After(200 * time.Millisecond):
+	}
+	require.NoError(t, s1.Ack(name, 0, "durable", 1))
+	expect(10)
+	select {
+	case msg := <-msgs:
+		t.Fatalf("Unexpected message %d", msg.Offset)
+	case <-time.After(200 * time.Millisecond):
+	}
+	require.NoError(t, s1.Ack(name, 0, "durable", 0))
+	expect(11)
+
+	// Offset 2 was not acknowledged, so the consumer resumes from it.
+	cancel()
+	sub, cancel = subscribeConsumer(t, apiClient, name, "durable", ManualAckMetadataKey, "true")
+	defer cancel()
+	msg, err := sub.Recv()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), msg.Offset)
+}
+
+// Ensure AckThrough acknowledges a batch of messages delivered to a manual-ack
+// consumer in one call, advancing its cursor so they aren't redelivered.
+func TestConsumerAckThrough(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), "foo", name))
+	publish := func(i int) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		require.NoError(t, err)
+	}
+	for i := 0; i < 100; i++ {
+		publish(i)
+	}
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	require.Equal(t, ErrConsumerInactive, s1.AckThrough(name, 0, "durable", 99))
+
+	sub, cancel := subscribeConsumer(t, apiClient, name, "durable",
+		ManualAckMetadataKey, "true", MaxUnackedMetadataKey, "100")
+	for i := int64(0); i < 100; i++ {
+		msg, err := sub.Recv()
+		require.NoError(t, err)
+		require.Equal(t, i, msg.Offset)
+	}
+	require.NoError(t, s1.AckThrough(name, 0, "durable", 99))
+
+	c := s1.metadata.GetPartition(name, 0).lookupConsumer("durable")
+	c.mu.Lock()
+	require.Equal(t, int64(100), c.offset)
+	require.Empty(t, c.pending)
+	c.mu.Unlock()
+
+	// Nothing is redelivered when the consumer resubscribes.
+	cancel()
+	sub, cancel = subscribeConsumer(t, apiClient, name, "durable", ManualAckMetadataKey, "true")
+	defer cancel()
+	publish(100)
+	msg, err := sub.Recv()
+	require.NoError(t, err)
+	require.Equal(t, int64(100), msg.Offset)
+}
+
+// Ensure messages redelivered to a manual-ack consumer because they weren't
+// acknowledged carry an incrementing delivery attempt.
+func TestConsumerDeliveryAttempts(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), "foo", name))
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	expect := func(sub proto.API_SubscribeClient, offset int64, attempt string) {
+		msg, err := sub.Recv()
+		require.NoError(t, err)
+		require.Equal(t, offset, msg.Offset)
+		require.Equal(t, attempt, string(msg.Headers[DeliveryAttemptHeader]))
+	}
+
+	// The message is redelivered each time the consumer resubscribes
+	// without acknowledging it. The window keeps the next message from being
+	// delivered.
+	for attempt := 1; attempt <= 3; attempt++ {
+		sub, cancel := subscribeConsumer(t, apiClient, name, "durable",
+			ManualAckMetadataKey, "true", MaxUnackedMetadataKey, "1")
+		expect(sub, 0, strconv.Itoa(attempt))
+		cancel()
+	}
+
+	// Once it's acknowledged, the next message is delivered for the first
+	// time.
+	sub, cancel := subscribeConsumer(t, apiClient, name, "durable",
+		ManualAckMetadataKey, "true", MaxUnackedMetadataKey, "1")
+	defer cancel()
+	expect(sub, 0, "4")
+	require.NoError(t, s1.Ack(name, 0, "durable", 0))
+	expect(sub, 1, "1")
+}
+
+// Ensure CommitConsumerOutput appends a consumer's output and advances its
+// cursor together, and that the cursor is recovered from the output if the
+// server fails after appending it.
+func TestCommitConsumerOutput(t *testing.T) {
+	defer cleanupStorage(t)
+
+	defer func(committed func() error) {
+		consumerOutputCommitted = committed
+	}(consumerOutputCommitted)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.CreateStream(context.Background(), "in", "in"))
+	require.NoError(t, client.CreateStream(context.Background(), "out", "out"))
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.Publish(ctx, "in", []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	apiClient := proto.NewAPIClient(conn)
+
+	output := func(values ...string) []byte {
+		msgs := make([]*commitlog.Message, len(values))
+		for i, value := range values {
+			msgs[i] = &commitlog.Message{Value: []byte(value), Timestamp: time.Now().UnixNano()}
+		}
+		data, err := commitlog.EncodeMessageSet(msgs)
+		require.NoError(t, err)
+		return data
+	}
+	expect := func(sub proto.API_SubscribeClient, start, end int64) {
+		for i := start; i < end; i++ {
+			msg, err := sub.Recv()
+			require.NoError(t, err)
+			require.Equal(t, i, msg.Offset)
+		}
+	}
+
+	// A consumer whose cursor advances on delivery can't commit output.
+	sub, cancel := subscribeConsumer(t, apiClient, "in", "auto")
+	expect(sub, 0, 1)
+	_, err = s1.CommitConsumerOutput(context.Background(), "in", 0, "auto", 1, "out", 0, output("x"))
+	require.Equal(t, ErrConsumerAutoAck, err)
+	cancel()
+
+	// Committing output acknowledges the messages before the offset.
+	sub, cancel = subscribeConsumer(t, apiClient, "in", "proc", ManualAckMetadataKey, "true")
+	expect(sub, 0, 8)
+	offsets, err := s1.CommitConsumerOutput(context.Background(), "in", 0, "proc", 5, "out", 0, output("a", "b"))
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 1}, offsets)
+	_, err = s1.CommitConsumerOutput(context.Background(), "in", 0, "proc", 11, "out", 0, output("c"))
+	require.Error(t, err)
+
+	// The server fails after appending the output but before advancing the
+	// cursor.
+	consumerOutputCommitted = func() error { return errors.New("crashed") }
+	_, err = s1.CommitConsumerOutput(context.Background(), "in", 0, "proc", 8, "out", 0, output("c"))
+	require.Error(t, err)
+	cancel()
+	s1.Stop()
+	consumerOutputCommitted = func() error { return nil }
+
+	s1 = runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+	getMetadataLeader(t, 10*time.Second, s1)
+	waitForPartition(t, 10*time.Second, "in", 0, s1)
+	waitForPartition(t, 10*time.Second, "out", 0, s1)
+	waitForHW(t, 10*time.Second, "out", 0, 2, s1)
+
+	// The output was appended once, with the cursor in its last message.
+	ctx, cancelOut := context.WithCancel(context.Background())
+	defer cancelOut()
+	outSub, err := apiClient.Subscribe(ctx, &proto.SubscribeRequest{
+		Stream:        "out",
+		StartPosition: proto.StartPosition_EARLIEST,
+	})
+	require.NoError(t, err)
+	_, err = outSub.Recv()
+	require.NoError(t, err)
+	var msg *proto.Message
+	for i, value := range []string{"a", "b", "c"} {
+		msg, err = outSub.Recv()
+		require.NoError(t, err)
+		require.Equal(t, int64(i), msg.Offset)
+		require.Equal(t, []byte(value), msg.Value)
+	}
+	require.Equal(t, []byte("proc"), msg.Headers[ConsumerHeader])
+	require.Equal(t, []byte("in"), msg.Headers[ConsumerStreamHeader])
+	require.Equal(t, []byte("0"), msg.Headers[ConsumerPartitionHeader])
+	require.Equal(t, []byte("8"), msg.Headers[ConsumerOffsetHeader])
+
+	// The cursor is recovered from the output, so the consumer resumes after
+	// the messages the output was produced from.
+	sub, cancel = subscribeConsumer(t, apiClient, "in", "proc", ManualAckMetadataKey, "true")
+	defer cancel()
+	expect(sub, 8, 10)
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"strconv"
+
+	client "github.com/liftbridge-io/liftbridge-api/go"
+)
+
+// Values of the ControlHeader header identifying the kind of control message
+// delivered on a subscription.
+const (
+	// ControlLeaderChanged indicates the partition's leader changed. The
+	// message carries the new leader and leader epoch in the LeaderHeader and
+	// LeaderEpochHeader headers.
+	ControlLeaderChanged = "leaderChanged"
+
+	// ControlTruncated indicates messages were removed from the partition's
+	// log by truncation. The message carries the log's resulting oldest and
+	// newest offsets in the OldestOffsetHeader and NewestOffsetHeader
+	// headers.
+	ControlTruncated = "truncated"
+
+	// ControlOffsetReset indicates the subscription's start offset was no
+	// longer in the partition's log, e.g. because it was removed by
+	// retention, so it was reset according to the OffsetResetMetadataKey
+	// policy. The message carries the requested start offset in the
+	// RequestedOffsetHeader header and the log's oldest and newest offsets in
+	// the OldestOffsetHeader and NewestOffsetHeader headers. It's delivered
+	// first, before any messages.
+	ControlOffsetReset = "offsetReset"
+)
+
+// notifyChanged wakes up subscriptions waiting on changes to the partition,
+// recording a truncation of the log if truncated is true.
+func (p *partition) notifyChanged(truncated bool) {
+	p.changeMu.Lock()
+	defer p.changeMu.Unlock()
+	if truncated {
+		p.truncations++
+	}
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// changes returns a channel which is closed on the next change to the
+// partition's leader or truncation of its log, along with the number of
+// truncations so far.
+func (p *partition) changes() (<-chan struct{}, int64) {
+	p.changeMu.Lock()
+	defer p.changeMu.Unlock()
+	return p.changed, p.truncations
+}
+
+// watchChanges calls send with a control message each time the partition's
+// leader changes or its log is truncated until send returns false or the
+// cancel channel is closed. The control messages are not messages in the log.
+func (p *partition) watchChanges(cancel <-chan struct{}, send func(*client.Message) bool) {
+	var (
+		changed, truncations = p.changes()
+		_, leaderEpoch       = p.GetLeader()
+	)
+	for {
+		select {
+		case <-changed:
+		case <-cancel:
+			return
+		}
+		var newTruncations int64
+		changed, newTruncations = p.changes()
+		leader, epoch := p.GetLeader()
+		if epoch != leaderEpoch {
+			leaderEpoch = epoch
+			if !send(p.controlMessage(ControlLeaderChanged, map[string][]byte{
+				LeaderHeader:      []byte(leader),
+				LeaderEpochHeader: []byte(strconv.FormatUint(epoch, 10)),
+			})) {
+				return
+			}
+		}
+		if newTruncations != truncations {
+			truncations = newTruncations
+			if !send(p.controlMessage(ControlTruncated, map[string][]byte{
+				OldestOffsetHeader: []byte(strconv.FormatInt(p.log.OldestOffset(), 10)),
+				NewestOffsetHeader: []byte(strconv.FormatInt(p.log.NewestOffset(), 10)),
+			})) {
+				return
+			}
+		}
+	}
+}
+
+// controlMessage returns a control message of the given kind with the given
+// headers.
+func (p *partition) controlMessage(kind string, headers map[string][]byte) *client.Message {
+	headers[ControlHeader] = []byte(kind)
+	return &client.Message{
+		Stream:    p.Stream,
+		Partition: p.Id,
+		Headers:   headers,
+	}
+}
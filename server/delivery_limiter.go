@@ -0,0 +1,42 @@
+package server
+
+import "time"
+
+// deliveryLimiter paces the messages delivered on a subscription. It's a
+// token bucket holding a single token, so messages are spread evenly rather
+// than delivered in bursts. A nil deliveryLimiter does not limit delivery.
+type deliveryLimiter struct {
+	interval time.Duration // time to accrue a token
+	next     time.Time     // when the next token is available
+}
+
+// newDeliveryLimiter returns a deliveryLimiter delivering at most the given
+// number of messages per second. If msgsPerSec is not positive, nil is
+// returned, which disables limiting.
+func newDeliveryLimiter(msgsPerSec int64) *deliveryLimiter {
+	if msgsPerSec <= 0 {
+		return nil
+	}
+	return &deliveryLimiter{interval: time.Second / time.Duration(msgsPerSec)}
+}
+
+// wait blocks until the next message may be delivered. It returns false if
+// the stop channel was closed first.
+func (d *deliveryLimiter) wait(stop <-chan struct{}) bool {
+	if d == nil {
+		return true
+	}
+	now := time.Now()
+	if delay := d.next.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return false
+		}
+		now = d.next
+	}
+	d.next = now.Add(d.interval)
+	return true
+}
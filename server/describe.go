@@ -0,0 +1,258 @@
+package server
+
+import (
+	"sort"
+	"time"
+
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// StreamDescription is a diagnostic snapshot of a stream's partitions and
+// their replication state as seen by a server. It is returned by
+// DescribeStream.
+type StreamDescription struct {
+	Name       string
+	Subject    string
+	LastAppend time.Time               // Latest LastAppend of the partitions
+	LastCommit time.Time               // Latest LastCommit of the partitions
+	Partitions []*PartitionDescription // Ordered by partition ID
+}
+
+// PartitionDescription is a diagnostic snapshot of a stream partition's
+// replication state. LastAppend and LastCommit are the zero time if there
+// was no append or commit since the server opened the partition's log.
+// AppendLatency is only known by the partition leader, so it is zero when the
+// describing server is not the leader.
+type PartitionDescription struct {
+	ID            int32
+	Leader        string
+	LeaderEpoch   uint64
+	Epoch         uint64 // Partition metadata epoch
+	HighWatermark int64
+	NewestOffset  int64
+	IngestDropped int64                  // Messages NATS dropped while this server was leader
+	Sync          commitlog.SyncStats    // Fsyncs of this server's copy of the log
+	Compaction    commitlog.CompactStats // Compactions of this server's copy of the log
+	LastAppend    time.Time              // Last append to this server's copy of the log
+	LastCommit    time.Time              // Last HW advance seen by this server
+	AppendLatency time.Duration          // Time the oldest message pending commit has waited
+	Replicas      []*ReplicaDescription  // In configured replica order
+}
+
+// ReplicaDescription describes a single replica of a stream partition. Offset
+// and Lag are only known by the partition leader, so they are -1 when the
+// describing server is not the leader or the replica has not yet sent a
+// replication request.
+type ReplicaDescription struct {
+	ID     string
+	Leader bool
+	InISR  bool
+	Offset int64 // Latest log offset reported by the replica
+	Lag    int64 // Number of messages the replica is behind the leader
+}
+
+// describe returns a diagnostic snapshot of the partition's replication state.
+func (p *partition) describe() *PartitionDescription {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	newest := p.log.NewestOffset()
+	desc := &PartitionDescription{
+		ID:            p.Id,
+		Leader:        p.Leader,
+		LeaderEpoch:   p.LeaderEpoch,
+		Epoch:         p.Epoch,
+		HighWatermark: p.log.HighWatermark(),
+		NewestOffset:  newest,
+		IngestDropped: p.ingestDroppedLocked(),
+		Sync:          p.log.SyncStats(),
+		Compaction:    p.log.CompactStats(),
+		LastAppend:    p.log.LastAppendTime(),
+		LastCommit:    p.log.LastCommitTime(),
+		Replicas:      make([]*ReplicaDescription, 0, len(p.Replicas)),
+	}
+	for _, id := range p.Replicas {
+		_, inISR := p.isr[id]
+		replica := &ReplicaDescription{
+			ID:     id,
+			Leader: id == p.Leader,
+			InISR:  inISR,
+			Offset: -1,
+			Lag:    -1,
+		}
+		if p.isLeading {
+			if id == p.srv.config.Clustering.ServerID {
+				replica.Offset = newest
+			} else if r, ok := p.replicators[id]; ok {
+				replica.Offset = r.getOffset()
+			}
+			if replica.Offset != -1 || newest == -1 {
+				replica.Lag = newest - replica.Offset
+			}
+		}
+		desc.Replicas = append(desc.Replicas, replica)
+	}
+	if p.isLeading {
+		desc.AppendLatency = p.commitQueue.Latency(time.Now())
+	}
+	return desc
+}
+
+// DescribeStream returns the replica set, ISR, leader, epochs, HW, and each
+// replica's offset and lag for every partition of the given stream. Replica
+// offsets and lag are only reported for partitions this server leads, so this
+// should be called on the partition leader for a complete picture. The last
+// append and commit times can be used to detect stuck streams, e.g. a stream
+// which is appended to but hasn't committed in a while.
+// ErrStreamNotFound is returned if the stream does not exist.
+func (s *Server) DescribeStream(stream string) (*StreamDescription, error) {
+	st := s.metadata.GetStream(stream)
+	if st == nil {
+		return nil, ErrStreamNotFound
+	}
+	partitions := st.GetPartitions()
+	desc := &StreamDescription{
+		Name:       st.GetName(),
+		Subject:    st.GetSubject(),
+		Partitions: make([]*PartitionDescription, 0, len(partitions)),
+	}
+	for _, partition := range partitions {
+		pdesc := partition.describe()
+		if pdesc.LastAppend.After(desc.LastAppend) {
+			desc.LastAppend = pdesc.LastAppend
+		}
+		if pdesc.LastCommit.After(desc.LastCommit) {
+			desc.LastCommit = pdesc.LastCommit
+		}
+		desc.Partitions = append(desc.Partitions, pdesc)
+	}
+	sort.Slice(desc.Partitions, func(i, j int) bool {
+		return desc.Partitions[i].ID < desc.Partitions[j].ID
+	})
+	return desc, nil
+}
+
+// StreamConfig is the configuration a server applies to a stream, with the
+// stream's own settings resolved against the server's defaults. It is
+// returned by GetStreamConfig.
+type StreamConfig struct {
+	Name              string
+	Subject           string
+	Group             string
+	Partitions        int32
+	ReplicationFactor int32
+	Placement         []*PartitionPlacement // Ordered by partition ID
+
+	// Message validation.
+	RequireKey bool
+	EmptyValue proto.EmptyValue
+	Schema     string
+
+	// Replication and acks.
+	MinISR               int
+	MaxPendingAcks       int64
+	MaxAppendLatency     time.Duration
+	MaxCommitWaiters     int
+	CommitWaitersPolicy  CommitWaitersPolicy
+	ReplicaMaxLagTime    time.Duration
+	ReplicaMaxLagOffsets int64
+	ReplicaFetchTimeout  time.Duration
+
+	// Retention, compaction, and quota.
+	RetentionMaxBytes    int64
+	RetentionMaxMessages int64
+	RetentionMaxAge      time.Duration
+	RetentionMaxSegments int
+	SegmentMaxBytes      int64
+	SegmentMaxAge        time.Duration
+	Compact              bool
+	CompactWindowStart   time.Duration
+	CompactWindowEnd     time.Duration
+	CompactMinDirtyRatio float64
+	QuotaMaxBytes        int64
+	QuotaPolicy          commitlog.QuotaPolicy
+	IdleDeleteTime       time.Duration
+
+	// Compression.
+	CompressionThreshold int64
+}
+
+// PartitionPlacement is the servers a stream partition is assigned to.
+type PartitionPlacement struct {
+	ID        int32
+	Replicas  []string // In configured replica order
+	Observers []string
+}
+
+// placement returns the servers the partition is assigned to.
+func (p *partition) placement() *PartitionPlacement {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return &PartitionPlacement{
+		ID:        p.Id,
+		Replicas:  append([]string(nil), p.Replicas...),
+		Observers: append([]string(nil), p.Observers...),
+	}
+}
+
+// GetStreamConfig returns the effective configuration of the given stream,
+// i.e. its replication factor, partitioning, placement, and the settings it
+// was created with, with any it did not set resolved to this server's
+// defaults. Server-wide settings such as retention are reported as this
+// server applies them, so servers configured differently may report
+// different values. ErrStreamNotFound is returned if the stream does not
+// exist.
+func (s *Server) GetStreamConfig(stream string) (*StreamConfig, error) {
+	partitions, err := s.getStreamPartitions(stream, nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].Id < partitions[j].Id
+	})
+	var (
+		streams = s.config.Streams
+		first   = partitions[0]
+		config  = &StreamConfig{
+			Name:                 first.Stream,
+			Subject:              first.Subject,
+			Group:                first.GetGroup(),
+			Partitions:           int32(len(partitions)),
+			Placement:            make([]*PartitionPlacement, 0, len(partitions)),
+			RequireKey:           first.GetRequireKey(),
+			EmptyValue:           first.GetEmptyValue(),
+			Schema:               first.GetSchema(),
+			MinISR:               s.config.Clustering.MinISR,
+			MaxPendingAcks:       streams.MaxPendingAcks,
+			MaxAppendLatency:     streams.MaxAppendLatency,
+			MaxCommitWaiters:     first.maxCommitWaiters(),
+			CommitWaitersPolicy:  streams.CommitWaitersPolicy,
+			ReplicaMaxLagTime:    first.replicaMaxLagTime(),
+			ReplicaMaxLagOffsets: first.replicaMaxLagOffsets(),
+			ReplicaFetchTimeout:  first.replicaFetchTimeout(),
+			RetentionMaxBytes:    streams.RetentionMaxBytes,
+			RetentionMaxMessages: streams.RetentionMaxMessages,
+			RetentionMaxAge:      first.retentionMaxAge(),
+			RetentionMaxSegments: first.retentionMaxSegments(),
+			SegmentMaxBytes:      streams.SegmentMaxBytes,
+			SegmentMaxAge:        streams.SegmentMaxAge,
+			Compact:              streams.Compact,
+			CompactWindowStart:   streams.CompactWindowStart,
+			CompactWindowEnd:     streams.CompactWindowEnd,
+			CompactMinDirtyRatio: streams.CompactMinDirtyRatio,
+			QuotaMaxBytes:        first.quotaMaxBytes(),
+			QuotaPolicy:          streams.QuotaPolicy,
+			IdleDeleteTime:       first.idleDeleteTime(),
+			CompressionThreshold: first.GetCompressionThreshold(),
+		}
+	)
+	for _, partition := range partitions {
+		placement := partition.placement()
+		if int32(len(placement.Replicas)) > config.ReplicationFactor {
+			config.ReplicationFactor = int32(len(placement.Replicas))
+		}
+		config.Placement = append(config.Placement, placement)
+	}
+	return config, nil
+}
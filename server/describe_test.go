@@ -0,0 +1,332 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+	grpcMetadata "google.golang.org/grpc/metadata"
+
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// Ensure DescribeStream reports a replica removed from the ISR as out-of-ISR
+// along with its lag.
+func TestDescribeStream(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	servers := make([]*Server, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxLagTime = time.Second
+		config.Clustering.ReplicaMaxIdleWait = 100 * time.Millisecond
+		config.Clustering.ReplicaFetchTimeout = 100 * time.Millisecond
+		servers[i] = runServerWithConfig(t, config)
+		defer servers[i].Stop()
+	}
+
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	// Describing a nonexistent stream fails.
+	_, err := servers[0].DescribeStream("foo")
+	require.Equal(t, ErrStreamNotFound, err)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = client.CreateStream(ctx, subject, name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+
+	publish := func(i int) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		require.NoError(t, err)
+	}
+
+	// Publish two messages which are replicated to all replicas.
+	publish(0)
+	publish(1)
+	waitForHW(t, 5*time.Second, name, 0, 1, servers...)
+
+	// Kill a stream follower.
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	var stopped, follower *Server
+	for _, s := range servers {
+		if s == leader {
+			continue
+		}
+		if stopped == nil {
+			stopped = s
+		} else {
+			follower = s
+		}
+	}
+	stopped.Stop()
+
+	// Publish more messages. These commit once the ISR shrinks.
+	for i := 2; i < 5; i++ {
+		publish(i)
+	}
+	waitForISR(t, 10*time.Second, name, 0, 2, leader, follower)
+	waitForHW(t, 5*time.Second, name, 0, 4, leader, follower)
+
+	desc, err := leader.DescribeStream(name)
+	require.NoError(t, err)
+	require.Equal(t, name, desc.Name)
+	require.Equal(t, subject, desc.Subject)
+	require.Len(t, desc.Partitions, 1)
+
+	partition := desc.Partitions[0]
+	require.Equal(t, int32(0), partition.ID)
+	require.Equal(t, leader.config.Clustering.ServerID, partition.Leader)
+	require.Equal(t, int64(4), partition.HighWatermark)
+	require.Equal(t, int64(4), partition.NewestOffset)
+	// Appends aren't fsynced unless streams.sync.writes is enabled.
+	require.Equal(t, int64(0), partition.Sync.Syncs)
+	require.Len(t, partition.Replicas, 3)
+
+	for _, replica := range partition.Replicas {
+		switch replica.ID {
+		case leader.config.Clustering.ServerID:
+			require.True(t, replica.Leader)
+			require.True(t, replica.InISR)
+			require.Equal(t, int64(4), replica.Offset)
+			require.Equal(t, int64(0), replica.Lag)
+		case stopped.config.Clustering.ServerID:
+			require.False(t, replica.Leader)
+			require.False(t, replica.InISR)
+			require.Equal(t, int64(1), replica.Offset)
+			require.Equal(t, int64(3), replica.Lag)
+		case follower.config.Clustering.ServerID:
+			require.False(t, replica.Leader)
+			require.True(t, replica.InISR)
+			require.Equal(t, int64(4), replica.Offset)
+			require.Equal(t, int64(0), replica.Lag)
+		default:
+			t.Fatalf("Unexpected replica %s", replica.ID)
+		}
+	}
+
+	// A follower knows the replica set and ISR but not replica offsets.
+	desc, err = follower.DescribeStream(name)
+	require.NoError(t, err)
+	for _, replica := range desc.Partitions[0].Replicas {
+		require.Equal(t, int64(-1), replica.Offset)
+		require.Equal(t, int64(-1), replica.Lag)
+	}
+}
+
+// Ensure DescribeStream reports when a stream was last appended to and
+// committed and that the times don't change while the stream is idle.
+func TestDescribeStreamLastAppendCommit(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name, lift.Partitions(2))
+	require.NoError(t, err)
+
+	// Nothing was appended or committed yet.
+	desc, err := s1.DescribeStream(name)
+	require.NoError(t, err)
+	require.True(t, desc.LastAppend.IsZero())
+	require.True(t, desc.LastCommit.IsZero())
+
+	before := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.Publish(ctx, name, []byte("hello"), lift.ToPartition(1),
+		lift.AckPolicyAll())
+	require.NoError(t, err)
+	waitForHW(t, 5*time.Second, name, 1, 0, s1)
+
+	desc, err = s1.DescribeStream(name)
+	require.NoError(t, err)
+	idle, published := desc.Partitions[0], desc.Partitions[1]
+	require.True(t, idle.LastAppend.IsZero())
+	require.True(t, idle.LastCommit.IsZero())
+	require.False(t, published.LastAppend.Before(before))
+	require.False(t, published.LastCommit.Before(published.LastAppend))
+	require.Equal(t, published.LastAppend, desc.LastAppend)
+	require.Equal(t, published.LastCommit, desc.LastCommit)
+
+	// The times stay put while the stream is idle.
+	time.Sleep(100 * time.Millisecond)
+	idleDesc, err := s1.DescribeStream(name)
+	require.NoError(t, err)
+	require.Equal(t, desc.LastAppend, idleDesc.LastAppend)
+	require.Equal(t, desc.LastCommit, idleDesc.LastCommit)
+	require.True(t, time.Since(idleDesc.LastCommit) >= 100*time.Millisecond)
+}
+
+// Ensure DescribeStream reports the compactions of a compacted stream.
+func TestDescribeStreamCompaction(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.Compact = true
+	s1Config.Streams.SegmentMaxBytes = 1
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name)
+	require.NoError(t, err)
+
+	// Nothing was compacted yet.
+	desc, err := s1.DescribeStream(name)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), desc.Partitions[0].Compaction.Runs)
+	require.True(t, desc.Partitions[0].Compaction.LastRun.IsZero())
+
+	// Publish the same key several times so compaction has something to
+	// remove.
+	num := 5
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte("value"), lift.Key([]byte("key")),
+			lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+	waitForHW(t, 5*time.Second, name, 0, int64(num-1), s1)
+
+	// Force a compaction.
+	partition := s1.metadata.GetPartition(name, 0)
+	require.NotNil(t, partition)
+	before := time.Now()
+	require.NoError(t, partition.log.Clean())
+
+	desc, err = s1.DescribeStream(name)
+	require.NoError(t, err)
+	compaction := desc.Partitions[0].Compaction
+	require.Equal(t, int64(1), compaction.Runs)
+	require.False(t, compaction.LastRun.Before(before))
+	require.True(t, compaction.LastReclaimed > 0)
+	require.Equal(t, compaction.LastReclaimed, compaction.BytesReclaimed)
+	require.True(t, compaction.DirtyRatio > 0)
+}
+
+// Ensure GetStreamConfig reports the settings a stream was created with and
+// resolves the ones it did not set to the server's defaults.
+func TestGetStreamConfig(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	servers := make([]*Server, 2)
+	for i, id := range []string{"a", "b"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxLagTime = 20 * time.Second
+		config.Clustering.ReplicaFetchTimeout = 2 * time.Second
+		config.Streams.RetentionMaxMessages = 100
+		config.Streams.Compact = true
+		config.Streams.QuotaPolicy = commitlog.QuotaPolicyEvict
+		config.Streams.IdleDeleteTime = time.Hour
+		config.Streams.MaxPendingAcks = 50
+		config.Streams.MaxCommitWaiters = 500
+		config.Streams.CommitWaitersPolicy = CommitWaitersBlock
+		servers[i] = runServerWithConfig(t, config)
+		defer servers[i].Stop()
+	}
+
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	// Getting the config of a nonexistent stream fails.
+	_, err := servers[0].GetStreamConfig("foo")
+	require.Equal(t, ErrStreamNotFound, err)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create a stream overriding only some settings.
+	name := "foo"
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		ReplicaMaxLagTimeMetadataKey, "5s",
+		RequireKeyMetadataKey, "true",
+		MaxCommitWaitersMetadataKey, "10",
+		RetentionMaxAgeMetadataKey, "24h",
+	)
+	err = client.CreateStream(ctx, "foo", name, lift.Partitions(2), lift.ReplicationFactor(2))
+	require.NoError(t, err)
+	waitForPartition(t, 5*time.Second, name, 1, servers...)
+
+	for _, s := range servers {
+		config, err := s.GetStreamConfig(name)
+		require.NoError(t, err)
+
+		require.Equal(t, name, config.Name)
+		require.Equal(t, "foo", config.Subject)
+		require.Equal(t, int32(2), config.Partitions)
+		require.Equal(t, int32(2), config.ReplicationFactor)
+		require.Len(t, config.Placement, 2)
+		for i, placement := range config.Placement {
+			require.Equal(t, int32(i), placement.ID)
+			require.ElementsMatch(t, []string{"a", "b"}, placement.Replicas)
+			require.Empty(t, placement.Observers)
+		}
+
+		// Settings the stream was created with.
+		require.Equal(t, 5*time.Second, config.ReplicaMaxLagTime)
+		require.True(t, config.RequireKey)
+		require.Equal(t, 10, config.MaxCommitWaiters)
+		require.Equal(t, 24*time.Hour, config.RetentionMaxAge)
+
+		// Settings resolved to the server's defaults.
+		require.Equal(t, 2*time.Second, config.ReplicaFetchTimeout)
+		require.Equal(t, int64(0), config.ReplicaMaxLagOffsets)
+		require.Equal(t, proto.EmptyValue_STORE, config.EmptyValue)
+		require.Empty(t, config.Schema)
+		require.Equal(t, time.Hour, config.IdleDeleteTime)
+		require.Equal(t, 1, config.MinISR)
+		require.Equal(t, int64(50), config.MaxPendingAcks)
+		require.Equal(t, CommitWaitersBlock, config.CommitWaitersPolicy)
+		require.Equal(t, int64(100), config.RetentionMaxMessages)
+		require.True(t, config.Compact)
+		require.Equal(t, commitlog.QuotaPolicyEvict, config.QuotaPolicy)
+	}
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorCode identifies the cause of an error returned by the API so that
+// clients can handle it programmatically rather than matching on error
+// messages. It is attached to the gRPC status of the error as the reason of an
+// errdetails.ErrorInfo in the ErrorCodeDomain domain, alongside the usual gRPC
+// status code. Use GetErrorCode to read it from an error.
+type ErrorCode string
+
+// ErrorCodeDomain is the domain of the errdetails.ErrorInfo carrying the
+// ErrorCode of an API error.
+const ErrorCodeDomain = "liftbridge.io"
+
+const (
+	// ErrorCodeInvalidArgument indicates the request or its metadata is
+	// invalid. The status code is InvalidArgument.
+	ErrorCodeInvalidArgument ErrorCode = "INVALID_ARGUMENT"
+
+	// ErrorCodeStreamNotFound indicates the stream does not exist. The status
+	// code is NotFound.
+	ErrorCodeStreamNotFound ErrorCode = "STREAM_NOT_FOUND"
+
+	// ErrorCodePartitionNotFound indicates the stream partition does not
+	// exist. The status code is NotFound.
+	ErrorCodePartitionNotFound ErrorCode = "PARTITION_NOT_FOUND"
+
+	// ErrorCodeStreamExists indicates a stream with the same name already
+	// exists. The status code is AlreadyExists.
+	ErrorCodeStreamExists ErrorCode = "STREAM_EXISTS"
+
+	// ErrorCodeNotLeader indicates the server is not the leader of the
+	// partition, e.g. because leadership has changed, so the client should
+	// refresh its metadata and retry. The status code is FailedPrecondition.
+	ErrorCodeNotLeader ErrorCode = "NOT_LEADER"
+
+	// ErrorCodeInsufficientISR indicates the partition's ISR is smaller than
+	// the configured minimum, so messages published with AckPolicy ALL cannot
+	// be committed. The status code is FailedPrecondition.
+	ErrorCodeInsufficientISR ErrorCode = "INSUFFICIENT_ISR"
+
+	// ErrorCodeOffsetOutOfRange indicates the requested offset is not in the
+	// log, e.g. it's past the end of the log or was removed by retention. The
+	// status code is OutOfRange.
+	ErrorCodeOffsetOutOfRange ErrorCode = "OFFSET_OUT_OF_RANGE"
+
+	// ErrorCodePaused indicates the partition is paused. The status code is
+	// FailedPrecondition.
+	ErrorCodePaused ErrorCode = "PAUSED"
+
+	// ErrorCodeBusy indicates the partition cannot accept a TryPublish
+	// without waiting. The status code is ResourceExhausted.
+	ErrorCodeBusy ErrorCode = "BUSY"
+
+	// ErrorCodeSequenceTooLow indicates a producer session's sequence number
+	// was already published or skipped. The status code is
+	// FailedPrecondition.
+	ErrorCodeSequenceTooLow ErrorCode = "SEQUENCE_TOO_LOW"
+
+	// ErrorCodeLeaderElection indicates the partition's leader has failed
+	// and a new leader has not been elected yet, so the publish was not
+	// accepted. The status code is FailedPrecondition.
+	ErrorCodeLeaderElection ErrorCode = "LEADER_ELECTION"
+
+	// ErrorCodeFailedPrecondition indicates the cluster is not in a state
+	// which allows the operation, for a reason without a more specific code.
+	// The status code is FailedPrecondition.
+	ErrorCodeFailedPrecondition ErrorCode = "FAILED_PRECONDITION"
+
+	// ErrorCodeResourceExhausted indicates a cluster limit was reached, e.g.
+	// the maximum number of replicas per server. The status code is
+	// ResourceExhausted.
+	ErrorCodeResourceExhausted ErrorCode = "RESOURCE_EXHAUSTED"
+
+	// ErrorCodeFenced indicates an admin operation carried a fencing token
+	// below the cluster's fencing epoch, i.e. it was issued by an actor which
+	// has since been superseded. The status code is Aborted.
+	ErrorCodeFenced ErrorCode = "FENCED"
+
+	// ErrorCodeTimeout indicates the operation, e.g. waiting for a publish
+	// ack, did not complete before the deadline. The status code is
+	// DeadlineExceeded.
+	ErrorCodeTimeout ErrorCode = "TIMEOUT"
+
+	// ErrorCodeUnavailable indicates a transient failure, e.g. the metadata
+	// leader changed, so the request can be retried. The status code is
+	// Unavailable.
+	ErrorCodeUnavailable ErrorCode = "UNAVAILABLE"
+
+	// ErrorCodeInternal indicates an unexpected server error or any other
+	// error without a more specific code. The status code is usually
+	// Internal.
+	ErrorCodeInternal ErrorCode = "INTERNAL"
+)
+
+// statusErrorCodes maps gRPC status codes to the ErrorCode used for statuses
+// which don't have a more specific one, e.g. those from metadata operations
+// forwarded to the metadata leader, where only the status code and message
+// are propagated.
+var statusErrorCodes = map[codes.Code]ErrorCode{
+	codes.InvalidArgument:    ErrorCodeInvalidArgument,
+	codes.NotFound:           ErrorCodeStreamNotFound,
+	codes.AlreadyExists:      ErrorCodeStreamExists,
+	codes.FailedPrecondition: ErrorCodeFailedPrecondition,
+	codes.OutOfRange:         ErrorCodeOffsetOutOfRange,
+	codes.ResourceExhausted:  ErrorCodeResourceExhausted,
+	codes.Aborted:            ErrorCodeFenced,
+	codes.DeadlineExceeded:   ErrorCodeTimeout,
+	codes.Unavailable:        ErrorCodeUnavailable,
+}
+
+// GetErrorCode returns the ErrorCode of an error returned by the API or an
+// empty ErrorCode if it doesn't have one.
+func GetErrorCode(err error) ErrorCode {
+	st, ok := status.FromError(err)
+	if !ok || st == nil {
+		return ""
+	}
+	return getStatusErrorCode(st)
+}
+
+// getStatusErrorCode returns the ErrorCode attached to the Status or an empty
+// ErrorCode if it doesn't have one.
+func getStatusErrorCode(st *status.Status) ErrorCode {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == ErrorCodeDomain {
+			return ErrorCode(info.Reason)
+		}
+	}
+	return ""
+}
+
+// ErrorInfoLeaderKey is the key of the errdetails.ErrorInfo metadata of a
+// NOT_LEADER error containing the ID of the partition's leader known to the
+// server, if any, so clients can invalidate their cached metadata for the
+// partition and retry on the leader right away.
+const ErrorInfoLeaderKey = "leader"
+
+// GetErrorLeader returns the ID of the partition leader carried by a
+// NOT_LEADER error returned by the API or an empty string if it doesn't have
+// one.
+func GetErrorLeader(err error) string {
+	st, ok := status.FromError(err)
+	if !ok || st == nil {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == ErrorCodeDomain {
+			return info.Metadata[ErrorInfoLeaderKey]
+		}
+	}
+	return ""
+}
+
+// newStatus returns a Status with the given code and message which carries
+// the ErrorCode.
+func newStatus(code codes.Code, errCode ErrorCode, msg string) *status.Status {
+	return withErrorCode(status.New(code, msg), errCode)
+}
+
+// newNotLeaderStatus returns a FailedPrecondition Status with the given message
+// which carries the NOT_LEADER ErrorCode and the ID of the partition's leader,
+// if it's known.
+func newNotLeaderStatus(msg, leader string) *status.Status {
+	info := &errdetails.ErrorInfo{
+		Reason: string(ErrorCodeNotLeader),
+		Domain: ErrorCodeDomain,
+	}
+	if leader != "" {
+		info.Metadata = map[string]string{ErrorInfoLeaderKey: leader}
+	}
+	st, err := status.New(codes.FailedPrecondition, msg).WithDetails(info)
+	if err != nil {
+		panic(err)
+	}
+	return st
+}
+
+// withErrorCode returns a copy of the Status carrying the ErrorCode.
+func withErrorCode(st *status.Status, errCode ErrorCode) *status.Status {
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: string(errCode),
+		Domain: ErrorCodeDomain,
+	})
+	if err != nil {
+		// This only fails for an OK status, which isn't an error.
+		return st
+	}
+	return detailed
+}
+
+// withDefaultErrorCode returns a copy of the Status carrying the ErrorCode for
+// its status code if it doesn't have an ErrorCode already. A nil Status is
+// returned as is.
+func withDefaultErrorCode(st *status.Status) *status.Status {
+	if st == nil || getStatusErrorCode(st) != "" {
+		return st
+	}
+	if st.Code() == codes.NotFound && st.Message() == ErrPartitionNotFound.Error() {
+		return withErrorCode(st, ErrorCodePartitionNotFound)
+	}
+	errCode, ok := statusErrorCodes[st.Code()]
+	if !ok {
+		errCode = ErrorCodeInternal
+	}
+	return withErrorCode(st, errCode)
+}
+
+// apiError returns the error as a gRPC status error carrying an ErrorCode.
+// Errors which aren't statuses, e.g. from NATS, are Internal errors. A nil
+// error is returned as is.
+func apiError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.New(codes.Internal, err.Error())
+	}
+	return withDefaultErrorCode(st).Err()
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	atomic_file "github.com/natefinch/atomic"
+	"github.com/pkg/errors"
+
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// exportsDir is the directory in the data directory containing the state of
+// the server's stream exports, one file per export.
+const exportsDir = "exports"
+
+var (
+	// ErrExportExists is returned by StartExport when an export with the
+	// same name already exists on the server.
+	ErrExportExists = errors.New("export already exists")
+
+	// ErrExportNotFound is returned by StopExport when there is no export
+	// with the given name on the server.
+	ErrExportNotFound = errors.New("export does not exist")
+)
+
+// connectorCheckpointInterval is how often exports and imports persist their
+// progress. Exports resume after the persisted offset, so messages exported
+// since the last checkpoint are exported again if the server crashes. This is
+// a var for testing purposes.
+var connectorCheckpointInterval = time.Second
+
+// connectorRetryInterval is how long an export or import waits before
+// retrying after failing to read or publish to its partition, e.g. because
+// the partition has not been recovered yet. This is a var for testing
+// purposes.
+var connectorRetryInterval = time.Second
+
+// exporter continuously publishes the committed messages of a stream
+// partition on this server to a NATS subject, in order, persisting its offset
+// so that it resumes where it left off when the server restarts.
+type exporter struct {
+	srv      *Server
+	mu       sync.Mutex
+	state    *proto.ExportState
+	exported int64 // Offset of the last message published
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// StartExport starts exporting the committed messages of the given stream
+// partition on this server to the NATS subject. Each message's value is
+// published to the subject, in offset order, starting with the oldest
+// message in the partition. The export is persisted and resumes when the
+// server restarts. Messages exported shortly before a crash may be exported
+// again. The name identifies the export on this server. ErrExportExists is
+// returned if there is already an export with the name.
+func (s *Server) StartExport(name, stream string, partitionID int32, subject string) error {
+	if !validConnectorName(name) {
+		return fmt.Errorf("invalid export name %q", name)
+	}
+	if subject == "" {
+		return errors.New("export subject cannot be empty")
+	}
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return err
+	}
+	if _, ok := subjectWildcards(partitions[0].getSubject(), subject); ok {
+		return fmt.Errorf("export subject %s is ingested by partition %s", subject, partitions[0])
+	}
+
+	s.exportsMu.Lock()
+	defer s.exportsMu.Unlock()
+	if _, ok := s.exports[name]; ok {
+		return ErrExportExists
+	}
+	state := &proto.ExportState{
+		Name:      name,
+		Stream:    stream,
+		Partition: partitionID,
+		Subject:   subject,
+		Offset:    -1,
+	}
+	if err := s.persistExport(state); err != nil {
+		return err
+	}
+	s.startExport(state)
+	return nil
+}
+
+// StopExport stops the export with the given name on this server and removes
+// its persisted state. ErrExportNotFound is returned if there is no such
+// export.
+func (s *Server) StopExport(name string) error {
+	s.exportsMu.Lock()
+	defer s.exportsMu.Unlock()
+	e, ok := s.exports[name]
+	if !ok {
+		return ErrExportNotFound
+	}
+	e.stop()
+	delete(s.exports, name)
+	err := os.Remove(s.exportFile(name))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove export state")
+	}
+	return nil
+}
+
+// resumeExports starts the exports persisted in the data directory.
+func (s *Server) resumeExports() error {
+	files, err := ioutil.ReadDir(filepath.Join(s.config.DataDir, exportsDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read exports directory")
+	}
+	s.exportsMu.Lock()
+	defer s.exportsMu.Unlock()
+	for _, file := range files {
+		data, err := ioutil.ReadFile(s.exportFile(file.Name()))
+		if err != nil {
+			return errors.Wrap(err, "failed to read export state")
+		}
+		state := &proto.ExportState{}
+		if err := state.Unmarshal(data); err != nil {
+			s.logger.Errorf("Skipping invalid export state %s: %v", file.Name(), err)
+			continue
+		}
+		s.logger.Infof("Resuming export %s of partition [stream=%s, partition=%d] after offset %d",
+			state.Name, state.Stream, state.Partition, state.Offset)
+		s.startExport(state)
+	}
+	return nil
+}
+
+// startExport starts exporting with the given state. This must be called
+// with the exports lock held.
+func (s *Server) startExport(state *proto.ExportState) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &exporter{
+		srv:      s,
+		state:    state,
+		exported: state.Offset,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	s.exports[state.Name] = e
+	s.startGoroutine(func() { e.run(ctx) })
+	s.startGoroutine(func() { e.checkpointLoop(ctx) })
+}
+
+// exportFile returns the path of the file containing the state of the export
+// with the given name.
+func (s *Server) exportFile(name string) string {
+	return filepath.Join(s.config.DataDir, exportsDir, name)
+}
+
+// persistExport writes the export state to disk.
+func (s *Server) persistExport(state *proto.ExportState) error {
+	data, err := state.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	return s.writeStateFile(exportsDir, state.Name, data)
+}
+
+// writeStateFile atomically writes the data to the file with the given name
+// in the given directory of the data directory, creating the directory if
+// needed.
+func (s *Server) writeStateFile(dir, name string, data []byte) error {
+	dir = filepath.Join(s.config.DataDir, dir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return errors.Wrap(err, "failed to create state directory")
+	}
+	return atomic_file.WriteFile(filepath.Join(dir, name), bytes.NewReader(data))
+}
+
+// validConnectorName indicates if the name can identify an export or import,
+// which is persisted in a file with the name.
+func validConnectorName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`)
+}
+
+// stop stops the export and waits for it to persist its offset.
+func (e *exporter) stop() {
+	e.cancel()
+	select {
+	case <-e.done:
+	case <-e.srv.shutdownCh:
+	}
+}
+
+// run exports messages until the Context is canceled, retrying when the
+// partition cannot be read. The offset is persisted when it returns.
+func (e *exporter) run(ctx context.Context) {
+	defer close(e.done)
+	defer e.checkpoint()
+	for {
+		err := e.export(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		e.srv.logger.Warnf("Export %s failed, retrying: %v", e.state.Name, err)
+		select {
+		case <-time.After(connectorRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// export publishes the partition's committed messages after the last
+// exported offset until reading the partition fails or the Context is
+// canceled.
+func (e *exporter) export(ctx context.Context) error {
+	partition := e.srv.metadata.GetPartition(e.state.Stream, e.state.Partition)
+	if partition == nil {
+		return ErrPartitionNotFound
+	}
+	e.mu.Lock()
+	start := e.exported + 1
+	e.mu.Unlock()
+	if oldest := partition.log.OldestOffset(); start < oldest {
+		e.srv.logger.Warnf("Export %s skipping offsets %d to %d removed by retention",
+			e.state.Name, start, oldest-1)
+		start = oldest
+	}
+	reader, err := partition.log.NewReader(start, false)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	headersBuf := make([]byte, 28)
+	for {
+		m, offset, _, _, err := reader.ReadMessage(ctx, headersBuf)
+		if err != nil {
+			return err
+		}
+		if err := e.srv.nc.Publish(e.state.Subject, m.Value()); err != nil {
+			return err
+		}
+		e.mu.Lock()
+		e.exported = offset
+		e.mu.Unlock()
+	}
+}
+
+// checkpointLoop periodically persists the export's offset until the Context
+// is canceled. It also stops the export when the server shuts down.
+func (e *exporter) checkpointLoop(ctx context.Context) {
+	ticker := time.NewTicker(connectorCheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.checkpoint()
+		case <-ctx.Done():
+			return
+		case <-e.srv.shutdownCh:
+			e.cancel()
+			return
+		}
+	}
+}
+
+// checkpoint persists the offset of the last message exported once it has
+// been flushed to NATS.
+func (e *exporter) checkpoint() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.exported == e.state.Offset {
+		return
+	}
+	if err := e.srv.nc.FlushTimeout(5 * time.Second); err != nil {
+		e.srv.logger.Warnf("Failed to flush export %s: %v", e.state.Name, err)
+		return
+	}
+	state := *e.state
+	state.Offset = e.exported
+	if err := e.srv.persistExport(&state); err != nil {
+		e.srv.logger.Errorf("Failed to persist export %s: %v", e.state.Name, err)
+		return
+	}
+	e.state.Offset = e.exported
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+)
+
+// Ensure an export publishes every committed message of a stream partition to
+// its NATS subject in order and resumes where it left off after a restart.
+func TestExportStream(t *testing.T) {
+	defer cleanupStorage(t)
+
+	defer func(interval time.Duration) {
+		connectorCheckpointInterval = interval
+	}(connectorCheckpointInterval)
+	connectorCheckpointInterval = 10 * time.Millisecond
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), "foo", name))
+
+	publish := func(start, end int) {
+		for i := start; i < end; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+			cancel()
+			require.NoError(t, err)
+		}
+	}
+	publish(0, 5)
+
+	nc, err := nats.Connect(nats.DefaultURL)
+	require.NoError(t, err)
+	defer nc.Close()
+	sub, err := nc.SubscribeSync("exported")
+	require.NoError(t, err)
+	require.NoError(t, nc.Flush())
+
+	expect := func(start, end int) {
+		for i := start; i < end; i++ {
+			msg, err := sub.NextMsg(5 * time.Second)
+			require.NoError(t, err)
+			require.Equal(t, strconv.Itoa(i), string(msg.Data))
+		}
+	}
+
+	// Exporting to a subject the stream ingests from fails.
+	require.Error(t, s1.StartExport("loop", name, 0, "foo"))
+
+	// Existing and new messages are exported in order.
+	require.NoError(t, s1.StartExport("export", name, 0, "exported"))
+	require.Equal(t, ErrExportExists, s1.StartExport("export", name, 0, "exported"))
+	expect(0, 5)
+	publish(5, 8)
+	expect(5, 8)
+
+	// Let the export checkpoint its offset, then restart the server.
+	time.Sleep(100 * time.Millisecond)
+	s1.Stop()
+	s1 = runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+	getMetadataLeader(t, 10*time.Second, s1)
+	waitForPartition(t, 10*time.Second, name, 0, s1)
+
+	// The export resumes after the last exported message.
+	publish(8, 10)
+	expect(8, 10)
+	_, err = sub.NextMsg(200 * time.Millisecond)
+	require.Equal(t, nats.ErrTimeout, err)
+
+	// Once stopped, messages are no longer exported.
+	require.NoError(t, s1.StopExport("export"))
+	require.Equal(t, ErrExportNotFound, s1.StopExport("export"))
+	publish(10, 11)
+	_, err = sub.NextMsg(200 * time.Millisecond)
+	require.Equal(t, nats.ErrTimeout, err)
+}
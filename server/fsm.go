@@ -112,6 +112,9 @@ func (s *Server) Apply(l *raft.Log) interface{} {
 		}
 		panic(err)
 	}
+	change := &MetadataChange{Index: l.Index, Op: log.Op, Log: log, Recovered: recovered}
+	change.Err, _ = value.(error)
+	s.metadataChanges.publish(change)
 	return value
 }
 
@@ -122,6 +125,13 @@ func (s *Server) Apply(l *raft.Log) interface{} {
 // entry in the Raft log. The recovered parameter indicates if this entry is
 // being applied during the recovery process.
 func (s *Server) apply(log *proto.RaftLog, index uint64, recovered bool) (interface{}, error) {
+	// Operations from actors which have been fenced off are rejected when
+	// applied, rather than when proposed, so that every server agrees.
+	if log.FencingToken > 0 {
+		if err := s.metadata.fence(log.FencingToken); err != nil {
+			return err, nil
+		}
+	}
 	switch log.Op {
 	case proto.Op_CREATE_PARTITION:
 		partition := log.CreatePartitionOp.Partition
@@ -137,13 +147,56 @@ func (s *Server) apply(log *proto.RaftLog, index uint64, recovered bool) (interf
 		if err != nil {
 			return nil, err
 		}
+	case proto.Op_CREATE_STREAM:
+		partitions := log.CreateStreamOp.Partitions
+		for _, partition := range partitions {
+			// Make sure to set the leader epoch on the partitions.
+			partition.LeaderEpoch = index
+			partition.Epoch = index
+		}
+		err := s.applyCreateStream(partitions, recovered)
+		// If err is ErrStreamExists, we want to return this value back to the
+		// caller.
+		if err == ErrStreamExists {
+			return err, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	case proto.Op_CREATE_STREAMS:
+		// Each stream is created independently, so return the result of each
+		// back to the caller. ErrStreamExists fails only that stream.
+		errs := make([]error, len(log.CreateStreamsOp.Streams))
+		for i, stream := range log.CreateStreamsOp.Streams {
+			for _, partition := range stream.Partitions {
+				// Make sure to set the leader epoch on the partitions.
+				partition.LeaderEpoch = index
+				partition.Epoch = index
+			}
+			err := s.applyCreateStream(stream.Partitions, recovered)
+			if err == ErrStreamExists {
+				errs[i] = err
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return errs, nil
 	case proto.Op_SHRINK_ISR:
 		var (
 			stream    = log.ShrinkISROp.Stream
 			replica   = log.ShrinkISROp.ReplicaToRemove
 			partition = log.ShrinkISROp.Partition
 		)
-		if err := s.applyShrinkISR(stream, replica, partition, index); err != nil {
+		err := s.applyShrinkISR(stream, replica, partition, index)
+		// If the replica is no longer a replica, e.g. because it was removed
+		// since the request was made, we want to return this value back to
+		// the caller.
+		if isReplicaStateError(err) {
+			return err, nil
+		}
+		if err != nil {
 			return nil, err
 		}
 	case proto.Op_CHANGE_LEADER:
@@ -161,7 +214,14 @@ func (s *Server) apply(log *proto.RaftLog, index uint64, recovered bool) (interf
 			replica   = log.ExpandISROp.ReplicaToAdd
 			partition = log.ExpandISROp.Partition
 		)
-		if err := s.applyExpandISR(stream, replica, partition, index); err != nil {
+		err := s.applyExpandISR(stream, replica, partition, index)
+		// If the replica is no longer a replica, e.g. because it was removed
+		// since the request was made, we want to return this value back to
+		// the caller.
+		if isReplicaStateError(err) {
+			return err, nil
+		}
+		if err != nil {
 			return nil, err
 		}
 	case proto.Op_DELETE_STREAM:
@@ -192,6 +252,87 @@ func (s *Server) apply(log *proto.RaftLog, index uint64, recovered bool) (interf
 		if err != nil {
 			return nil, err
 		}
+	case proto.Op_RENAME_STREAM:
+		var (
+			stream  = log.RenameStreamOp.Stream
+			newName = log.RenameStreamOp.NewName
+		)
+		err := s.applyRenameStream(stream, newName, index, recovered)
+		// If err is ErrStreamNotFound or ErrStreamExists, we want to return
+		// this value back to the caller.
+		if err == ErrStreamNotFound || err == ErrStreamExists {
+			return err, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	case proto.Op_SWAP_STREAMS:
+		var (
+			stream = log.SwapStreamsOp.Stream
+			other  = log.SwapStreamsOp.Other
+		)
+		err := s.applySwapStreams(stream, other, index, recovered)
+		// If err is ErrStreamNotFound, we want to return this value back to
+		// the caller.
+		if err == ErrStreamNotFound {
+			return err, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	case proto.Op_TRIM_STREAM:
+		var (
+			stream = log.TrimStreamOp.Stream
+			offset = log.TrimStreamOp.Offset
+		)
+		err := s.applyTrimStream(stream, offset)
+		// If err is ErrStreamNotFound, we want to return this value back to
+		// the caller.
+		if err == ErrStreamNotFound {
+			return err, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	case proto.Op_CHANGE_REPLICAS:
+		var (
+			stream    = log.ChangeReplicasOp.Stream
+			partition = log.ChangeReplicasOp.Partition
+			replicas  = log.ChangeReplicasOp.Replicas
+		)
+		err := s.applyChangeReplicas(stream, partition, replicas, index)
+		// If err is ErrPartitionNotFound or the change no longer applies,
+		// e.g. because the partition leader changed since the request was
+		// validated, we want to return this value back to the caller.
+		if err == ErrPartitionNotFound || isReplicaStateError(err) {
+			return err, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	case proto.Op_CHANGE_REPLICA_ROLE:
+		var (
+			stream    = log.ChangeReplicaRoleOp.Stream
+			replica   = log.ChangeReplicaRoleOp.Replica
+			partition = log.ChangeReplicaRoleOp.Partition
+			observer  = log.ChangeReplicaRoleOp.Observer
+		)
+		err := s.applyChangeReplicaRole(stream, replica, partition, observer, index)
+		// If err is ErrPartitionNotFound or the change no longer applies,
+		// e.g. because the replica has since become the partition leader, we
+		// want to return this value back to the caller.
+		if err == ErrPartitionNotFound || isReplicaStateError(err) {
+			return err, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	case proto.Op_SET_SERVER_READ_ONLY:
+		var (
+			server   = log.SetServerReadOnlyOp.Server
+			readOnly = log.SetServerReadOnlyOp.ReadOnly
+		)
+		s.applySetServerReadOnly(server, readOnly)
 	default:
 		return nil, fmt.Errorf("Unknown Raft operation: %s", log.Op)
 	}
@@ -290,7 +431,11 @@ func (s *Server) Snapshot() (raft.FSMSnapshot, error) {
 			partitions = append(partitions, partition.Partition)
 		}
 	}
-	return &fsmSnapshot{&proto.MetadataSnapshot{Partitions: partitions}}, nil
+	return &fsmSnapshot{&proto.MetadataSnapshot{
+		Partitions:      partitions,
+		ReadOnlyServers: s.metadata.GetReadOnlyServers(),
+		FencingEpoch:    s.metadata.GetFencingEpoch(),
+	}}, nil
 }
 
 // Restore is used to restore an FSM from a snapshot. It is not called
@@ -327,6 +472,10 @@ func (s *Server) Restore(snapshot io.ReadCloser) error {
 		}
 		recoveredStreams[partition.Stream] = struct{}{}
 	}
+	for _, server := range snap.ReadOnlyServers {
+		s.applySetServerReadOnly(server, true)
+	}
+	s.metadata.setFencingEpoch(snap.FencingEpoch)
 	s.logger.Debugf("fsm: Finished restoring Raft state from snapshot, recovered %s",
 		english.Plural(len(recoveredStreams), "stream", ""))
 	return nil
@@ -353,6 +502,36 @@ func (s *Server) applyCreatePartition(protoPartition *proto.Partition, recovered
 	return nil
 }
 
+// applySetServerReadOnly sets whether the given server is read-only, which
+// keeps it from being selected to lead new partitions.
+func (s *Server) applySetServerReadOnly(server string, readOnly bool) {
+	s.metadata.SetReadOnly(server, readOnly)
+	if readOnly {
+		s.logger.Debugf("fsm: Set server %s read-only", server)
+	} else {
+		s.logger.Debugf("fsm: Set server %s writable", server)
+	}
+}
+
+// applyCreateStream adds the given stream partitions to the metadata store.
+// If the partitions are being recovered, they will not be started until after
+// the recovery process completes. If they are not being recovered, the
+// partitions will be started as a leader or follower if applicable.
+// ErrStreamExists is returned if the stream already exists.
+func (s *Server) applyCreateStream(protoPartitions []*proto.Partition, recovered bool) error {
+	partitions, err := s.metadata.AddStream(protoPartitions, recovered)
+	if err == ErrStreamExists {
+		return err
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to add stream to metadata store")
+	}
+	for _, partition := range partitions {
+		s.logger.Debugf("fsm: Created partition %s", partition)
+	}
+	return nil
+}
+
 // applyShrinkISR removes the given replica from the partition and updates the
 // partition epoch. If the partition epoch is greater than or equal to the
 // specified epoch, this does nothing.
@@ -368,8 +547,7 @@ func (s *Server) applyShrinkISR(stream, replica string, partitionID int32, epoch
 	}
 
 	if err := partition.RemoveFromISR(replica); err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to remove %s from ISR for partition %s",
-			replica, partition))
+		return err
 	}
 
 	partition.SetEpoch(epoch)
@@ -393,8 +571,7 @@ func (s *Server) applyExpandISR(stream, replica string, partitionID int32, epoch
 	}
 
 	if err := partition.AddToISR(replica); err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to add %s to ISR for partition %s",
-			replica, partition))
+		return err
 	}
 
 	partition.SetEpoch(epoch)
@@ -403,6 +580,70 @@ func (s *Server) applyExpandISR(stream, replica string, partitionID int32, epoch
 	return nil
 }
 
+// applyChangeReplicaRole demotes the given replica to an observer or promotes
+// the given observer to a replica and updates the partition epoch. If the
+// partition epoch is greater than or equal to the specified epoch, this does
+// nothing. A replicaStateError is returned if the change no longer applies to
+// the partition, e.g. because the replica has become its leader.
+func (s *Server) applyChangeReplicaRole(stream, replica string, partitionID int32, observer bool,
+	epoch uint64) error {
+
+	partition := s.metadata.GetPartition(stream, partitionID)
+	if partition == nil {
+		return ErrPartitionNotFound
+	}
+
+	// Idempotency check.
+	if partition.GetEpoch() >= epoch {
+		return nil
+	}
+
+	if err := partition.ChangeReplicaRole(replica, observer); err != nil {
+		return err
+	}
+
+	partition.SetEpoch(epoch)
+
+	if observer {
+		s.logger.Infof("fsm: Demoted replica %s to observer for partition %s", replica, partition)
+	} else {
+		s.logger.Infof("fsm: Promoted observer %s to replica for partition %s", replica, partition)
+	}
+	return nil
+}
+
+// applyChangeReplicas sets the replica set of the given partition and updates
+// the partition epoch. If the partition epoch is greater than or equal to the
+// specified epoch, this does nothing. A replicaStateError is returned if the
+// change no longer applies to the partition, e.g. because it would remove the
+// partition's current leader.
+func (s *Server) applyChangeReplicas(stream string, partitionID int32, replicas []string,
+	epoch uint64) error {
+
+	partition := s.metadata.GetPartition(stream, partitionID)
+	if partition == nil {
+		return ErrPartitionNotFound
+	}
+
+	// Idempotency check.
+	if partition.GetEpoch() >= epoch {
+		return nil
+	}
+
+	if err := partition.ChangeReplicas(replicas); err != nil {
+		if isReplicaStateError(err) {
+			return err
+		}
+		return errors.Wrap(err, fmt.Sprintf("failed to change replicas for partition %s",
+			partition))
+	}
+
+	partition.SetEpoch(epoch)
+
+	s.logger.Infof("fsm: Changed replicas for partition %s to %v", partition, replicas)
+	return nil
+}
+
 // applyChangePartitionLeader sets the partition's leader to the given replica and
 // updates the partition epoch. If the partition epoch is greater than or equal
 // to the specified epoch, this does nothing.
@@ -443,6 +684,64 @@ func (s *Server) applyDeleteStream(streamName string) error {
 	return nil
 }
 
+// applyRenameStream renames the given stream, preserving its partitions'
+// commit logs.
+func (s *Server) applyRenameStream(streamName, newName string, index uint64, recovered bool) error {
+	stream := s.metadata.GetStream(streamName)
+	if stream == nil {
+		return ErrStreamNotFound
+	}
+
+	err := s.metadata.CloseAndRenameStream(stream, newName, index, recovered)
+	if err == ErrStreamExists {
+		return err
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to rename stream")
+	}
+
+	s.logger.Debugf("fsm: Renamed stream %s to %s", streamName, newName)
+	return nil
+}
+
+// applySwapStreams exchanges the names and subjects of the given streams,
+// preserving their partitions' commit logs.
+func (s *Server) applySwapStreams(streamName, otherName string, index uint64, recovered bool) error {
+	stream := s.metadata.GetStream(streamName)
+	if stream == nil {
+		return ErrStreamNotFound
+	}
+	other := s.metadata.GetStream(otherName)
+	if other == nil {
+		return ErrStreamNotFound
+	}
+
+	if err := s.metadata.CloseAndSwapStreams(stream, other, index, recovered); err != nil {
+		return errors.Wrap(err, "failed to swap streams")
+	}
+
+	s.logger.Debugf("fsm: Swapped streams %s and %s", streamName, otherName)
+	return nil
+}
+
+// applyTrimStream deletes all messages before the given offset from the
+// stream's partitions.
+func (s *Server) applyTrimStream(streamName string, offset int64) error {
+	stream := s.metadata.GetStream(streamName)
+	if stream == nil {
+		return ErrStreamNotFound
+	}
+
+	for _, partition := range stream.GetPartitions() {
+		if err := partition.TrimBefore(offset); err != nil {
+			return errors.Wrapf(err, "failed to trim partition %d", partition.Id)
+		}
+	}
+
+	s.logger.Debugf("fsm: Trimmed stream %s before offset %d", streamName, offset)
+	return nil
+}
+
 // applyPauseStream pauses the given stream partitions.
 func (s *Server) applyPauseStream(streamName string, partitions []int32, resumeAll bool) error {
 	stream := s.metadata.GetStream(streamName)
@@ -33,6 +33,7 @@ func TestFSMSnapshotRestore(t *testing.T) {
 	// Create some streams.
 	require.NoError(t, client.CreateStream(context.Background(), "foo", "foo"))
 	require.NoError(t, client.CreateStream(context.Background(), "bar", "bar", lift.Partitions(3)))
+	require.NoError(t, s1.SetServerReadOnly(context.Background(), "a", true))
 
 	// Force a snapshot.
 	future := s1.getRaft().Snapshot()
@@ -49,4 +50,5 @@ func TestFSMSnapshotRestore(t *testing.T) {
 	waitForPartition(t, 10*time.Second, "bar", 1, s1)
 	waitForPartition(t, 10*time.Second, "bar", 2, s1)
 	require.Len(t, s1.metadata.GetStreams(), 2)
+	require.Equal(t, []string{"a"}, s1.metadata.GetReadOnlyServers())
 }
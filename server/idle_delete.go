@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// idleDeleteCheckInterval is how often servers report partition activity to
+// the metadata leader and how often the metadata leader checks for idle
+// streams. This is a var for testing purposes.
+var idleDeleteCheckInterval = time.Second
+
+// streamIdleDeleter deletes streams which go their idle delete time without
+// publishes or subscribers. Servers report activity on their partitions to
+// the metadata leader, which tracks when each stream was last active. It only
+// runs on the metadata leader so that a single server decides which streams
+// are idle.
+type streamIdleDeleter struct {
+	srv          *Server
+	mu           sync.Mutex
+	lastActivity map[string]time.Time
+	stop         chan struct{}
+}
+
+func newStreamIdleDeleter(s *Server) *streamIdleDeleter {
+	return &streamIdleDeleter{srv: s}
+}
+
+// Start begins checking for idle streams. Every stream is considered active
+// as of when this is called since activity reported to a previous leader is
+// not known.
+func (d *streamIdleDeleter) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		return
+	}
+	d.lastActivity = make(map[string]time.Time)
+	stop := make(chan struct{})
+	d.stop = stop
+	d.srv.startGoroutine(func() { d.loop(stop) })
+}
+
+// Stop stops checking for idle streams.
+func (d *streamIdleDeleter) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop == nil {
+		return
+	}
+	close(d.stop)
+	d.stop = nil
+	d.lastActivity = nil
+}
+
+// Touch records activity on the given stream.
+func (d *streamIdleDeleter) Touch(stream string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastActivity != nil {
+		d.lastActivity[stream] = time.Now()
+	}
+}
+
+func (d *streamIdleDeleter) loop(stop <-chan struct{}) {
+	ticker := time.NewTicker(idleDeleteCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, name := range d.idleStreams() {
+				d.deleteStream(name)
+			}
+		case <-stop:
+			return
+		case <-d.srv.shutdownCh:
+			return
+		}
+	}
+}
+
+// idleStreams returns the names of the streams which have gone their idle
+// delete time without activity. Streams are not considered idle until twice
+// the check interval has passed so that activity reports have time to arrive.
+func (d *streamIdleDeleter) idleStreams() []string {
+	var (
+		now     = time.Now()
+		streams = d.srv.metadata.GetStreams()
+		idle    []string
+	)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.lastActivity == nil {
+		return nil
+	}
+	exists := make(map[string]struct{}, len(streams))
+	for _, stream := range streams {
+		name := stream.GetName()
+		exists[name] = struct{}{}
+		partition := stream.GetPartition(0)
+		if partition == nil {
+			continue
+		}
+		idleTime := partition.idleDeleteTime()
+		if idleTime <= 0 {
+			continue
+		}
+		if idleTime < 2*idleDeleteCheckInterval {
+			idleTime = 2 * idleDeleteCheckInterval
+		}
+		last, ok := d.lastActivity[name]
+		if !ok {
+			d.lastActivity[name] = now
+			continue
+		}
+		if now.Sub(last) >= idleTime {
+			idle = append(idle, name)
+			delete(d.lastActivity, name)
+		}
+	}
+	// Forget streams which have since been deleted.
+	for name := range d.lastActivity {
+		if _, ok := exists[name]; !ok {
+			delete(d.lastActivity, name)
+		}
+	}
+	return idle
+}
+
+// deleteStream deletes the idle stream through the Raft log.
+func (d *streamIdleDeleter) deleteStream(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), raftApplyTimeout)
+	defer cancel()
+	st := d.srv.metadata.DeleteStream(ctx, &proto.DeleteStreamOp{Stream: name})
+	if st != nil {
+		if st.Code() != codes.NotFound {
+			d.srv.logger.Errorf("Failed to delete idle stream %s: %v", name, st.Message())
+		}
+		return
+	}
+	d.srv.logger.Infof("Deleted idle stream %s", name)
+}
+
+// reportStreamActivity periodically reports activity on the partitions this
+// server leads or serves subscriptions for to the metadata leader, which
+// deletes streams that go their idle delete time without activity. Only
+// partitions whose stream can be deleted when idle are reported.
+func (s *Server) reportStreamActivity() {
+	ticker := time.NewTicker(idleDeleteCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.shutdownCh:
+			return
+		}
+		for _, stream := range s.metadata.GetStreams() {
+			for _, partition := range stream.GetPartitions() {
+				if partition.idleDeleteTime() <= 0 || !partition.takeActivity() {
+					continue
+				}
+				op := &proto.ReportActivityOp{
+					Stream:    partition.Stream,
+					Partition: partition.Id,
+				}
+				if st := s.metadata.ReportActivity(context.Background(), op); st != nil {
+					s.logger.Warnf("Failed to report activity on partition %s: %v",
+						partition, st.Message())
+				}
+				// One active partition is enough to keep the stream.
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+	grpcMetadata "google.golang.org/grpc/metadata"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+)
+
+// waitForStreamDeleted waits until the stream is removed from the server's
+// metadata or fails the test if it isn't within the timeout.
+func waitForStreamDeleted(t *testing.T, timeout time.Duration, name string, s *Server) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if s.metadata.GetStream(name) == nil {
+			return
+		}
+		time.Sleep(15 * time.Millisecond)
+	}
+	stackFatalf(t, "Stream %s was not deleted", name)
+}
+
+// Ensure streams created with an idle delete time are deleted once they go
+// that long without publishes or subscribers and are kept while active.
+func TestIdleDeleteStreams(t *testing.T) {
+	defer cleanupStorage(t)
+
+	defer func(interval time.Duration) {
+		idleDeleteCheckInterval = interval
+	}(idleDeleteCheckInterval)
+	idleDeleteCheckInterval = 100 * time.Millisecond
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	leader := getMetadataLeader(t, 10*time.Second, s1, s2)
+	follower := s1
+	if leader == s1 {
+		follower = s2
+	}
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Place the idle streams on the server which isn't the metadata leader so
+	// activity is reported to the leader over NATS.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		IdleDeleteTimeMetadataKey, "1s",
+		ReplicaServersMetadataKey, follower.config.Clustering.ServerID)
+	require.NoError(t, client.CreateStream(ctx, "foo", "foo"))
+	require.NoError(t, client.CreateStream(ctx, "bar", "bar"))
+	require.NoError(t, client.CreateStream(context.Background(), "baz", "baz"))
+
+	// Keep bar active with a subscription.
+	subCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, client.Subscribe(subCtx, "bar", func(lift.Message, error) {}))
+
+	// Publishing keeps foo from being deleted.
+	for i := 0; i < 10; i++ {
+		pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.Publish(pubCtx, "foo", []byte("hello"))
+		pubCancel()
+		require.NoError(t, err)
+		time.Sleep(200 * time.Millisecond)
+	}
+	require.NotNil(t, leader.metadata.GetStream("foo"))
+
+	// Once publishes stop, foo is deleted on every server.
+	waitForStreamDeleted(t, 5*time.Second, "foo", leader)
+	waitForStreamDeleted(t, 5*time.Second, "foo", follower)
+
+	// The subscribed stream and the stream without an idle delete time are
+	// kept.
+	require.NotNil(t, leader.metadata.GetStream("bar"))
+	require.NotNil(t, leader.metadata.GetStream("baz"))
+
+	// Once the subscription ends, bar is deleted too.
+	cancel()
+	waitForStreamDeleted(t, 5*time.Second, "bar", leader)
+	require.NotNil(t, leader.metadata.GetStream("baz"))
+}
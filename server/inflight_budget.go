@@ -0,0 +1,76 @@
+package server
+
+import (
+	"sync"
+
+	client "github.com/liftbridge-io/liftbridge-api/go"
+)
+
+// inflightBudget bounds the bytes of messages a subscription has read from the
+// log but not yet handed off to the gRPC stream. Since the gRPC stream blocks
+// sends while the client isn't consuming, delivery pauses once the budget is
+// used up and resumes as the client catches up. A message is always allowed
+// when nothing is in flight so that messages larger than the budget are still
+// delivered. A nil inflightBudget does not limit delivery.
+type inflightBudget struct {
+	max      int64
+	mu       sync.Mutex
+	bytes    int64         // bytes currently in flight
+	peak     int64         // most bytes ever in flight
+	released chan struct{} // closed when bytes are released
+}
+
+// newInflightBudget returns an inflightBudget allowing at most the given
+// number of bytes in flight. If maxBytes is not positive, nil is returned,
+// which disables limiting.
+func newInflightBudget(maxBytes int64) *inflightBudget {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &inflightBudget{max: maxBytes, released: make(chan struct{})}
+}
+
+// acquire blocks until n more bytes fit in the budget and adds them to the
+// bytes in flight. It returns false if the stop channel was closed first.
+func (b *inflightBudget) acquire(n int64, stop <-chan struct{}) bool {
+	if b == nil {
+		return true
+	}
+	for {
+		b.mu.Lock()
+		if b.bytes == 0 || b.bytes+n <= b.max {
+			b.bytes += n
+			if b.bytes > b.peak {
+				b.peak = b.bytes
+			}
+			b.mu.Unlock()
+			return true
+		}
+		released := b.released
+		b.mu.Unlock()
+		select {
+		case <-released:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// release removes n bytes from the bytes in flight, waking up any blocked
+// acquires.
+func (b *inflightBudget) release(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.bytes -= n
+	close(b.released)
+	b.released = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// inflightSize returns the number of bytes the message counts against an
+// inflightBudget, which is the size of its key and value.
+func inflightSize(msg *client.Message) int64 {
+	return int64(len(msg.Key) + len(msg.Value))
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/liftbridge-io/liftbridge-api/go"
+	"github.com/stretchr/testify/require"
+)
+
+// Ensure a nil inflightBudget does not limit delivery.
+func TestInflightBudgetDisabled(t *testing.T) {
+	budget := newInflightBudget(0)
+	require.Nil(t, budget)
+	require.True(t, budget.acquire(1024*1024, nil))
+	budget.release(1024 * 1024)
+}
+
+// Ensure the bytes in flight stay under the budget when delivering large
+// messages from several partitions to a slow consumer.
+func TestInflightBudgetBoundsLargeMessages(t *testing.T) {
+	var (
+		maxBytes   = int64(1024 * 1024)
+		size       = 300 * 1024
+		perReader  = 10
+		readers    = 4
+		budget     = newInflightBudget(maxBytes)
+		ch         = make(chan *client.Message)
+		stop       = make(chan struct{})
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		inflight   int64
+		overBudget bool
+	)
+	defer close(stop)
+
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perReader; j++ {
+				msg := &client.Message{Key: []byte("key"), Value: make([]byte, size)}
+				require.True(t, budget.acquire(inflightSize(msg), stop))
+				mu.Lock()
+				inflight += inflightSize(msg)
+				if inflight > maxBytes {
+					overBudget = true
+				}
+				mu.Unlock()
+				ch <- msg
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	received := 0
+	for msg := range ch {
+		// Consume slowly so the readers fill up the budget.
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		inflight -= inflightSize(msg)
+		mu.Unlock()
+		budget.release(inflightSize(msg))
+		received++
+	}
+	require.Equal(t, readers*perReader, received)
+	require.False(t, overBudget)
+	require.True(t, budget.peak <= maxBytes, "peak %d exceeds budget %d", budget.peak, maxBytes)
+	// The readers are blocked, not just serialized.
+	require.True(t, budget.peak > int64(size), "peak %d", budget.peak)
+}
+
+// Ensure a message larger than the budget is allowed once nothing else is in
+// flight.
+func TestInflightBudgetOversizedMessage(t *testing.T) {
+	budget := newInflightBudget(100)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	require.True(t, budget.acquire(10, stop))
+	acquired := make(chan bool)
+	go func() { acquired <- budget.acquire(1000, stop) }()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected oversized message to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	budget.release(10)
+	select {
+	case ok := <-acquired:
+		require.True(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected oversized message to be allowed")
+	}
+}
+
+// Ensure acquire returns false when stopped while waiting.
+func TestInflightBudgetStop(t *testing.T) {
+	budget := newInflightBudget(100)
+	stop := make(chan struct{})
+	require.True(t, budget.acquire(100, stop))
+	close(stop)
+	require.False(t, budget.acquire(1, stop))
+}
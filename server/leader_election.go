@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// LeaderElectionPreference determines which ISR replica the metadata leader
+// elects when a partition leader fails.
+type LeaderElectionPreference int
+
+const (
+	// LeaderElectionOffset elects the most caught-up replica, i.e. the one
+	// with the latest leader epoch and the highest committed and log end
+	// offsets, to minimize the messages truncated by the election. This is
+	// the default.
+	LeaderElectionOffset LeaderElectionPreference = iota
+
+	// LeaderElectionRandom elects a random replica.
+	LeaderElectionRandom
+)
+
+// PublishElectionPolicy determines how publishes to a partition whose leader
+// is being re-elected are handled, i.e. once the leader has been reported as
+// failed and until a new leader is elected.
+type PublishElectionPolicy int
+
+const (
+	// PublishElectionQueue holds publishes until the new leader is elected
+	// or PublishElectionTimeout elapses, in which case they fail. This is the
+	// default.
+	PublishElectionQueue PublishElectionPolicy = iota
+
+	// PublishElectionFail fails publishes immediately so that clients can
+	// retry or fail over themselves.
+	PublishElectionFail
+)
+
+// selectMostCaughtUpReplica requests the status of the partition from each
+// candidate and returns the most caught-up candidate. Ties are broken at
+// random. Candidates which fail to respond are not considered, and if none
+// respond, a random candidate is returned.
+func (m *metadataAPI) selectMostCaughtUpReplica(partition *partition, candidates []string) string {
+	req, err := proto.MarshalPartitionStatusRequest(&proto.PartitionStatusRequest{
+		Stream:    partition.Stream,
+		Partition: partition.Id,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	var (
+		statuses = make([]*proto.PartitionStatusResponse, len(candidates))
+		wg       sync.WaitGroup
+	)
+	for i, candidate := range candidates {
+		wg.Add(1)
+		go func(i int, candidate string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), partitionStatusTimeout)
+			defer cancel()
+			resp, err := m.ncRaft.RequestWithContext(ctx, m.getPartitionStatusInbox(candidate), req)
+			if err != nil {
+				m.logger.Warnf("Failed to get status for partition %s from replica %s: %v",
+					partition, candidate, err)
+				return
+			}
+			status, err := proto.UnmarshalPartitionStatusResponse(resp.Data)
+			if err != nil {
+				m.logger.Warnf("Invalid status response for partition %s from replica %s: %v",
+					partition, candidate, err)
+				return
+			}
+			if status.Exists {
+				statuses[i] = status
+			}
+		}(i, candidate)
+	}
+	wg.Wait()
+
+	var (
+		best     *proto.PartitionStatusResponse
+		selected []string
+	)
+	for i, status := range statuses {
+		if status == nil {
+			continue
+		}
+		switch cmp := compareReplicaStatus(status, best); {
+		case cmp > 0:
+			best = status
+			selected = []string{candidates[i]}
+		case cmp == 0:
+			selected = append(selected, candidates[i])
+		}
+	}
+	if len(selected) == 0 {
+		m.logger.Warnf("No replicas responded with their status for partition %s, "+
+			"electing a random replica", partition)
+		return selectRandomReplica(candidates)
+	}
+	return selectRandomReplica(selected)
+}
+
+// compareReplicaStatus returns a positive number if replica a is more caught
+// up than replica b, a negative number if it's less caught up, and zero if
+// they are equally caught up. A replica with a later leader epoch is more
+// caught up since it has seen a newer lineage of the log, followed by a higher
+// HW and then a higher log end offset. A nil b is less caught up than any
+// replica.
+func compareReplicaStatus(a, b *proto.PartitionStatusResponse) int {
+	if b == nil {
+		return 1
+	}
+	switch {
+	case a.LastLeaderEpoch != b.LastLeaderEpoch:
+		return compareUint64(a.LastLeaderEpoch, b.LastLeaderEpoch)
+	case a.HighWatermark != b.HighWatermark:
+		return compareInt64(a.HighWatermark, b.HighWatermark)
+	default:
+		return compareInt64(a.NewestOffset, b.NewestOffset)
+	}
+}
+
+func compareUint64(a, b uint64) int {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// Ensure replicas are ranked by leader epoch, then HW, then log end offset.
+func TestCompareReplicaStatus(t *testing.T) {
+	status := func(epoch uint64, hw, newest int64) *proto.PartitionStatusResponse {
+		return &proto.PartitionStatusResponse{
+			Exists:          true,
+			LastLeaderEpoch: epoch,
+			HighWatermark:   hw,
+			NewestOffset:    newest,
+		}
+	}
+	require.Equal(t, 1, compareReplicaStatus(status(1, 0, 0), nil))
+	require.Equal(t, 1, compareReplicaStatus(status(2, 0, 0), status(1, 5, 5)))
+	require.Equal(t, -1, compareReplicaStatus(status(1, 5, 5), status(2, 0, 0)))
+	require.Equal(t, 1, compareReplicaStatus(status(1, 3, 3), status(1, 2, 5)))
+	require.Equal(t, 1, compareReplicaStatus(status(1, 2, 5), status(1, 2, 3)))
+	require.Equal(t, 0, compareReplicaStatus(status(1, 2, 3), status(1, 2, 3)))
+}
+
+// Ensure the follower with the most messages is elected partition leader when
+// the leader fails.
+func TestLeaderElectionPrefersMostCaughtUpReplica(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Use a long max lag time so that the lagging follower stays in the ISR.
+	servers := make([]*Server, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxLagTime = time.Minute
+		config.Clustering.ReplicaMaxIdleWait = 10 * time.Millisecond
+		servers[i] = runServerWithConfig(t, config)
+		defer servers[i].Stop()
+	}
+
+	metadataLeader := getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	var followers []*Server
+	for _, s := range servers {
+		if s != leader {
+			followers = append(followers, s)
+		}
+	}
+	ahead, behind := followers[0], followers[1]
+
+	// Publish a message replicated to every server.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.Publish(ctx, name, []byte("hello"), lift.AckPolicyAll())
+	require.NoError(t, err)
+	waitForHW(t, 5*time.Second, name, 0, 0, servers...)
+
+	// Stop one follower from replicating, then publish more messages which
+	// only the other follower replicates.
+	require.NoError(t, behind.PauseReplication(name, nil))
+	for i := 0; i < 3; i++ {
+		_, err = client.Publish(ctx, name, []byte("world"), lift.AckPolicyLeader())
+		require.NoError(t, err)
+	}
+	aheadPartition := ahead.metadata.GetPartition(name, 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for aheadPartition.log.NewestOffset() != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("Follower did not replicate messages")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, int64(0), behind.metadata.GetPartition(name, 0).log.NewestOffset())
+	waitForISR(t, 5*time.Second, name, 0, 3, servers...)
+
+	// Elect a new leader, which must be the follower with the most messages.
+	partition := metadataLeader.metadata.GetPartition(name, 0)
+	require.Nil(t, metadataLeader.metadata.electNewPartitionLeader(partition))
+	newLeader := getPartitionLeader(t, 10*time.Second, name, 0, ahead, behind)
+	require.Equal(t, ahead, newLeader)
+}
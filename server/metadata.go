@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
+	grpcMetadata "google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	client "github.com/liftbridge-io/liftbridge-api/go"
@@ -21,22 +23,41 @@ import (
 )
 
 const (
-	defaultPropagateTimeout       = 5 * time.Second
-	maxReplicationFactor    int32 = -1
+	defaultPropagateTimeout           = 5 * time.Second
+	defaultMetadataRetryTimeout       = 10 * time.Second
+	minMetadataRetryBackoff           = 10 * time.Millisecond
+	maxMetadataRetryBackoff           = time.Second
+	partitionStatusTimeout            = time.Second
+	partialBrokerCacheMaxAge          = 5 * time.Second
+	maxReplicationFactor        int32 = -1
 )
 
+// errMetadataLeaderChanged is the message of the Unavailable status returned
+// when a request forwarded to the metadata leader is abandoned because the
+// leader changed. The leader may have applied the request anyway.
+const errMetadataLeaderChanged = "metadata leader changed"
+
 var (
 	// ErrPartitionExists is returned by CreatePartition when attempting to
 	// create a stream partition that already exists.
 	ErrPartitionExists = errors.New("partition already exists")
 
-	// ErrStreamNotFound is returned by DeleteStream/PauseStream when
-	// attempting to delete/pause a stream that does not exist.
+	// ErrStreamExists is returned by CreateStream/RenameStream when attempting
+	// to create or rename to a stream that already exists.
+	ErrStreamExists = errors.New("stream already exists")
+
+	// ErrStreamNotFound is returned by DeleteStream/PauseStream/RenameStream/
+	// TrimStream when attempting to delete/pause/rename/trim a stream that
+	// does not exist.
 	ErrStreamNotFound = errors.New("stream does not exist")
 
 	// ErrPartitionNotFound is returned by PauseStream when attempting to pause
 	// a stream partition that does not exist.
 	ErrPartitionNotFound = errors.New("partition does not exist")
+
+	// ErrFenced is returned by DeleteStream/TrimStream/ChangeReplicas when
+	// the operation's fencing token is below the fencing epoch.
+	ErrFenced = errors.New("fencing token is below the fencing epoch")
 )
 
 // leaderReport tracks witnesses for a partition leader. Witnesses are replicas
@@ -103,16 +124,20 @@ type metadataAPI struct {
 	streams         map[string]*stream
 	mu              sync.RWMutex
 	leaderReports   map[*partition]*leaderReport
+	readOnlyServers map[string]struct{}
+	fencingEpoch    uint64 // Highest fencing token accepted
 	cachedBrokers   []*client.Broker
 	cachedServerIDs map[string]struct{}
+	cachedPartial   bool // Not every server responded to the survey
 	lastCached      time.Time
 }
 
 func newMetadataAPI(s *Server) *metadataAPI {
 	return &metadataAPI{
-		Server:        s,
-		streams:       make(map[string]*stream),
-		leaderReports: make(map[*partition]*leaderReport),
+		Server:          s,
+		streams:         make(map[string]*stream),
+		leaderReports:   make(map[*partition]*leaderReport),
+		readOnlyServers: make(map[string]struct{}),
 	}
 }
 
@@ -145,10 +170,14 @@ func (m *metadataAPI) FetchMetadata(ctx context.Context, req *client.FetchMetada
 		}
 		resp.Brokers = brokers
 
-		// Update the cache.
+		// Update the cache. If not every server responded, e.g. because one
+		// is down or has just joined the cluster, the partial list is only
+		// cached for a short time so the missing servers are picked up soon
+		// without every call waiting on the survey timeout.
 		m.mu.Lock()
 		m.cachedBrokers = brokers
 		m.cachedServerIDs = serverIDs
+		m.cachedPartial = len(brokers) < len(servers)
 		m.lastCached = time.Now()
 		m.mu.Unlock()
 	}
@@ -158,7 +187,8 @@ func (m *metadataAPI) FetchMetadata(ctx context.Context, req *client.FetchMetada
 
 // brokerCache checks if the cache of broker metadata is clean and, if it is
 // and it's not past the metadata cache max age, returns the cached broker
-// list. The bool returned indicates if the cached data is returned or not.
+// list. A partial broker list expires after partialBrokerCacheMaxAge. The bool
+// returned indicates if the cached data is returned or not.
 func (m *metadataAPI) brokerCache(serverIDs map[string]struct{}) ([]*client.Broker, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -173,15 +203,29 @@ func (m *metadataAPI) brokerCache(serverIDs map[string]struct{}) ([]*client.Brok
 			}
 		}
 	}
+	maxAge := m.config.MetadataCacheMaxAge
+	if m.cachedPartial && maxAge > partialBrokerCacheMaxAge {
+		maxAge = partialBrokerCacheMaxAge
+	}
 	useCache := len(m.cachedBrokers) > 0 &&
 		!serversChanged &&
-		time.Since(m.lastCached) <= m.config.MetadataCacheMaxAge
+		time.Since(m.lastCached) <= maxAge
 	if useCache {
 		return m.cachedBrokers, true
 	}
 	return nil, false
 }
 
+// invalidateBrokerCache clears the cache of broker metadata so that the next
+// FetchMetadata surveys the cluster.
+func (m *metadataAPI) invalidateBrokerCache() {
+	m.mu.Lock()
+	m.cachedBrokers = nil
+	m.cachedServerIDs = nil
+	m.cachedPartial = false
+	m.mu.Unlock()
+}
+
 // fetchBrokerInfo retrieves the broker metadata for the cluster. The numPeers
 // argument is the expected number of peers to get a response from.
 func (m *metadataAPI) fetchBrokerInfo(ctx context.Context, numPeers int) ([]*client.Broker, *status.Status) {
@@ -282,6 +326,250 @@ func (m *metadataAPI) createMetadataResponse(streams []string) *client.FetchMeta
 	return &client.FetchMetadataResponse{Metadata: metadata}
 }
 
+// CreateStream creates a new stream and all of its partitions if this server
+// is the metadata leader. If it is not, it will forward the request to the
+// leader and return the response. This operation is replicated by Raft as a
+// single entry, so the stream's partitions are created atomically. If
+// concurrent requests attempt to create the same stream, exactly one will
+// succeed and the others will receive an AlreadyExists status. The metadata
+// leader will select replicationFactor nodes to participate in each partition
+// and a leader. If successful, this will return once the partitions have been
+// replicated to the cluster and the partition leaders have started.
+func (m *metadataAPI) CreateStream(ctx context.Context, req *proto.CreateStreamOp) *status.Status {
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		isLeader, st := m.propagateCreateStream(ctx, req)
+		if st != nil {
+			return st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return nil
+		}
+	}
+
+	if st := m.placeStreamReplicas(req, m.getReplicaCounts()); st != nil {
+		return st
+	}
+
+	// Replicate stream create through Raft.
+	op := &proto.RaftLog{
+		Op:             proto.Op_CREATE_STREAM,
+		CreateStreamOp: req,
+	}
+
+	// Wait on result of replication.
+	future := m.applyRaftOperation(op)
+	if err := future.Error(); err != nil {
+		return raftApplyStatus("Failed to replicate stream", err)
+	}
+
+	// If there is a response, it's an error (most likely ErrStreamExists).
+	if resp := future.Response(); resp != nil {
+		err := resp.(error)
+		code := codes.Internal
+		if err == ErrStreamExists {
+			code = codes.AlreadyExists
+		}
+		return status.New(code, err.Error())
+	}
+
+	return m.startedStream(ctx, req)
+}
+
+// CreateStreams creates many streams and all of their partitions if this
+// server is the metadata leader. If it is not, it will forward the request to
+// the leader and return the response. Rather than a Raft entry per stream,
+// the streams are replicated by Raft as a single entry, which makes creating
+// many streams at once much faster. Each stream still succeeds or fails on
+// its own, so a Status is returned for each stream in the request, in order,
+// which is nil if the stream was created. A separate Status is returned if
+// the request failed as a whole.
+func (m *metadataAPI) CreateStreams(ctx context.Context, req *proto.CreateStreamsOp) (
+	[]*status.Status, *status.Status) {
+
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		results, isLeader, st := m.propagateCreateStreams(ctx, req)
+		if st != nil {
+			return nil, st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return results, nil
+		}
+	}
+
+	// Streams whose replicas cannot be placed fail without being included in
+	// the Raft entry.
+	var (
+		results       = make([]*status.Status, len(req.Streams))
+		replicaCounts = m.getReplicaCounts()
+		placed        = &proto.CreateStreamsOp{}
+		indexes       []int
+	)
+	for i, stream := range req.Streams {
+		if st := m.placeStreamReplicas(stream, replicaCounts); st != nil {
+			results[i] = st
+			continue
+		}
+		placed.Streams = append(placed.Streams, stream)
+		indexes = append(indexes, i)
+	}
+	if len(placed.Streams) == 0 {
+		return results, nil
+	}
+
+	// Replicate stream creates through Raft.
+	op := &proto.RaftLog{
+		Op:              proto.Op_CREATE_STREAMS,
+		CreateStreamsOp: placed,
+	}
+
+	// Wait on result of replication.
+	future := m.applyRaftOperation(op)
+	if err := future.Error(); err != nil {
+		return nil, raftApplyStatus("Failed to replicate streams", err)
+	}
+
+	// The response contains the error for each stream, nil if it was
+	// created, unless the operation failed as a whole.
+	errs, ok := future.Response().([]error)
+	if !ok {
+		return nil, status.Newf(codes.Internal, "Failed to create streams: %v", future.Response())
+	}
+	for j, err := range errs {
+		i := indexes[j]
+		if err != nil {
+			code := codes.Internal
+			if err == ErrStreamExists {
+				code = codes.AlreadyExists
+			}
+			results[i] = status.New(code, err.Error())
+			continue
+		}
+		results[i] = m.startedStream(ctx, placed.Streams[j])
+	}
+
+	return results, nil
+}
+
+// placeStreamReplicas selects the replicas and a leader for each partition of
+// the stream being created and adds them to replicaCounts. If the replicas
+// cannot be placed, replicaCounts is left unchanged and a Status is returned.
+func (m *metadataAPI) placeStreamReplicas(req *proto.CreateStreamOp, replicaCounts map[string]int) *status.Status {
+	observers, servers, st := m.getStreamObservers(req)
+	if st != nil {
+		return st
+	}
+	for i, partition := range req.Partitions {
+		// Select replicationFactor nodes to participate in the partition.
+		replicas, st := m.getPartitionReplicas(partition.ReplicationFactor, servers, replicaCounts)
+		if st != nil {
+			m.unplaceStreamReplicas(req.Partitions[:i], replicaCounts)
+			return st
+		}
+
+		// Select a leader at random.
+		leader, st := m.selectPartitionLeader(replicas)
+		if st != nil {
+			for _, replica := range replicas {
+				replicaCounts[replica]--
+			}
+			m.unplaceStreamReplicas(req.Partitions[:i], replicaCounts)
+			return st
+		}
+		partition.Replicas = replicas
+		partition.Isr = replicas
+		partition.Leader = leader
+		partition.Observers = observers
+		partition.PinnedServers = req.Servers
+	}
+	return nil
+}
+
+// unplaceStreamReplicas removes the replicas of the given placed partitions
+// from replicaCounts.
+func (m *metadataAPI) unplaceStreamReplicas(placed []*proto.Partition, replicaCounts map[string]int) {
+	for _, partition := range placed {
+		for _, replica := range partition.Replicas {
+			replicaCounts[replica]--
+		}
+	}
+}
+
+// getStreamObservers validates the observers requested for the stream being
+// created and returns them along with the servers its replicas can be placed
+// on, which excludes the observers. Observers must be members of the cluster
+// and cannot be pinned replicas.
+func (m *metadataAPI) getStreamObservers(req *proto.CreateStreamOp) ([]string, []string, *status.Status) {
+	if len(req.Observers) == 0 {
+		return nil, req.Servers, nil
+	}
+	ids, err := m.getClusterServerIDs()
+	if err != nil {
+		return nil, nil, status.New(codes.Internal, err.Error())
+	}
+	members := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		members[id] = struct{}{}
+	}
+	var (
+		observers   = make([]string, 0, len(req.Observers))
+		isObserver  = make(map[string]struct{}, len(req.Observers))
+		replicaPool []string
+	)
+	for _, observer := range req.Observers {
+		if _, ok := members[observer]; !ok {
+			return nil, nil, status.Newf(codes.InvalidArgument, "No such server %s", observer)
+		}
+		if _, ok := isObserver[observer]; ok {
+			continue
+		}
+		isObserver[observer] = struct{}{}
+		observers = append(observers, observer)
+	}
+	if len(req.Servers) > 0 {
+		for _, server := range req.Servers {
+			if _, ok := isObserver[server]; ok {
+				return nil, nil, status.Newf(codes.InvalidArgument,
+					"Server %s cannot be both a replica and an observer", server)
+			}
+		}
+		return observers, req.Servers, nil
+	}
+	for _, id := range ids {
+		if _, ok := isObserver[id]; !ok {
+			replicaPool = append(replicaPool, id)
+		}
+	}
+	if len(replicaPool) == 0 {
+		return nil, nil, status.New(codes.InvalidArgument, "No servers left to place replicas on")
+	}
+	return observers, replicaPool, nil
+}
+
+// startedStream waits for the leaders of a newly created stream's partitions
+// to start them and publishes their creation on the activity stream.
+func (m *metadataAPI) startedStream(ctx context.Context, req *proto.CreateStreamOp) *status.Status {
+	for _, partition := range req.Partitions {
+		// Wait for leader to create partition (best effort).
+		m.waitForPartitionLeader(ctx, partition.Stream, partition.Leader, partition.Id)
+
+		err := m.publishActivityEvent(client.ActivityStreamEvent{
+			Op: client.ActivityStreamOp_CREATE_PARTITION,
+			CreatePartitionOp: &client.CreatePartitionOp{
+				Stream:    partition.Stream,
+				Partition: partition.Id,
+			},
+		})
+		if err != nil {
+			return status.Newf(codes.Internal, "Failed to publish on the activity stream: %v", err.Error())
+		}
+	}
+	return nil
+}
+
 // CreatePartition creates a new stream partition if this server is the
 // metadata leader. If it is not, it will forward the request to the leader and
 // return the response. This operation is replicated by Raft. The metadata
@@ -302,13 +590,17 @@ func (m *metadataAPI) CreatePartition(ctx context.Context, req *proto.CreatePart
 	}
 
 	// Select replicationFactor nodes to participate in the partition.
-	replicas, st := m.getPartitionReplicas(req.Partition.ReplicationFactor)
+	replicas, st := m.getPartitionReplicas(req.Partition.ReplicationFactor,
+		req.Partition.PinnedServers, m.getReplicaCounts())
 	if st != nil {
 		return st
 	}
 
 	// Select a leader at random.
-	leader := selectRandomReplica(replicas)
+	leader, st := m.selectPartitionLeader(replicas)
+	if st != nil {
+		return st
+	}
 
 	req.Partition.Replicas = replicas
 	req.Partition.Isr = replicas
@@ -323,7 +615,7 @@ func (m *metadataAPI) CreatePartition(ctx context.Context, req *proto.CreatePart
 	// Wait on result of replication.
 	future := m.applyRaftOperation(op)
 	if err := future.Error(); err != nil {
-		return status.Newf(codes.Internal, "Failed to replicate partition: %v", err.Error())
+		return raftApplyStatus("Failed to replicate partition", err)
 	}
 
 	// If there is a response, it's an error (most likely ErrPartitionExists).
@@ -356,8 +648,14 @@ func (m *metadataAPI) CreatePartition(ctx context.Context, req *proto.CreatePart
 // DeleteStream deletes a stream if this server is the metadata leader. If it is
 // not, it will forward the request to the leader and return the response. This
 // operation is replicated by Raft. If successful, this will return once the
-// stream has been deleted from the cluster.
+// stream has been deleted from the cluster. If the Context carries a fencing
+// token below the fencing epoch, this returns Aborted.
 func (m *metadataAPI) DeleteStream(ctx context.Context, req *proto.DeleteStreamOp) *status.Status {
+	token, st := m.checkFencingToken(ctx)
+	if st != nil {
+		return st
+	}
+
 	// Forward the request if we're not the leader.
 	if !m.IsLeader() {
 		isLeader, st := m.propagateDeleteStream(ctx, req)
@@ -374,20 +672,24 @@ func (m *metadataAPI) DeleteStream(ctx context.Context, req *proto.DeleteStreamO
 	op := &proto.RaftLog{
 		Op:             proto.Op_DELETE_STREAM,
 		DeleteStreamOp: req,
+		FencingToken:   token,
 	}
 
 	// Wait on result of deletion.
 	future := m.applyRaftOperation(op)
 	if err := future.Error(); err != nil {
-		return status.Newf(codes.Internal, "Failed to delete stream: %v", err.Error())
+		return raftApplyStatus("Failed to delete stream", err)
 	}
 
 	// If there is a response, it's an error (most likely ErrStreamNotFound).
 	if resp := future.Response(); resp != nil {
 		err := resp.(error)
 		code := codes.Internal
-		if err == ErrStreamNotFound {
+		switch err {
+		case ErrStreamNotFound:
 			code = codes.NotFound
+		case ErrFenced:
+			code = codes.Aborted
 		}
 		return status.New(code, err.Error())
 	}
@@ -431,7 +733,7 @@ func (m *metadataAPI) PauseStream(ctx context.Context, req *proto.PauseStreamOp)
 	// Wait on result of pausing.
 	future := m.applyRaftOperation(op)
 	if err := future.Error(); err != nil {
-		return status.Newf(codes.Internal, "Failed to pause stream: %v", err.Error())
+		return raftApplyStatus("Failed to pause stream", err)
 	}
 
 	// If there is a response, it's an error (most likely ErrStreamNotFound or
@@ -448,14 +750,19 @@ func (m *metadataAPI) PauseStream(ctx context.Context, req *proto.PauseStreamOp)
 	return nil
 }
 
-// ShrinkISR removes the specified replica from the partition's in-sync
-// replicas set if this server is the metadata leader. If it is not, it will
-// forward the request to the leader and return the response. This operation is
-// replicated by Raft.
-func (m *metadataAPI) ShrinkISR(ctx context.Context, req *proto.ShrinkISROp) *status.Status {
+// RenameStream renames a stream if this server is the metadata leader. If it is
+// not, it will forward the request to the leader and return the response. This
+// operation is replicated by Raft. The stream's partitions keep their commit
+// logs, offsets, and NATS subjects. If successful, this will return once the
+// stream has been renamed.
+func (m *metadataAPI) RenameStream(ctx context.Context, req *proto.RenameStreamOp) *status.Status {
+	if req.NewName == "" {
+		return status.New(codes.InvalidArgument, "No new stream name provided")
+	}
+
 	// Forward the request if we're not the leader.
 	if !m.IsLeader() {
-		isLeader, st := m.propagateShrinkISR(ctx, req)
+		isLeader, st := m.propagateRenameStream(ctx, req)
 		if st != nil {
 			return st
 		}
@@ -465,44 +772,99 @@ func (m *metadataAPI) ShrinkISR(ctx context.Context, req *proto.ShrinkISROp) *st
 		}
 	}
 
-	// Verify the partition exists.
-	partition := m.GetPartition(req.Stream, req.Partition)
-	if partition == nil {
-		return status.New(codes.FailedPrecondition, fmt.Sprintf("No such partition [stream=%s, partition=%d]",
-			req.Stream, req.Partition))
+	// Replicate stream renaming through Raft.
+	op := &proto.RaftLog{
+		Op:             proto.Op_RENAME_STREAM,
+		RenameStreamOp: req,
 	}
 
-	// Check the leader epoch.
-	leader, epoch := partition.GetLeader()
-	if req.Leader != leader || req.LeaderEpoch != epoch {
-		return status.New(
-			codes.FailedPrecondition,
-			fmt.Sprintf("Leader generation mismatch, current leader: %s epoch: %d, got leader: %s epoch: %d",
-				leader, epoch, req.Leader, req.LeaderEpoch))
+	// Wait on result of renaming.
+	future := m.applyRaftOperation(op)
+	if err := future.Error(); err != nil {
+		return raftApplyStatus("Failed to rename stream", err)
 	}
 
-	// Replicate ISR shrink through Raft.
+	// If there is a response, it's an error (most likely ErrStreamNotFound or
+	// ErrStreamExists).
+	if resp := future.Response(); resp != nil {
+		err := resp.(error)
+		code := codes.Internal
+		switch err {
+		case ErrStreamNotFound:
+			code = codes.NotFound
+		case ErrStreamExists:
+			code = codes.AlreadyExists
+		}
+		return status.New(code, err.Error())
+	}
+
+	return nil
+}
+
+// SwapStreams exchanges the names and subjects of two streams, along with
+// their data, if this server is the metadata leader. If it is not, it will
+// forward the request to the leader and return the response. This operation
+// is replicated by Raft so that every replica swaps the streams at the same
+// point. If successful, this will return once the streams have been swapped.
+func (m *metadataAPI) SwapStreams(ctx context.Context, req *proto.SwapStreamsOp) *status.Status {
+	if req.Stream == req.Other {
+		return status.New(codes.InvalidArgument, "Cannot swap a stream with itself")
+	}
+
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		isLeader, st := m.propagateSwapStreams(ctx, req)
+		if st != nil {
+			return st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return nil
+		}
+	}
+
+	// Replicate stream swap through Raft.
 	op := &proto.RaftLog{
-		Op:          proto.Op_SHRINK_ISR,
-		ShrinkISROp: req,
+		Op:            proto.Op_SWAP_STREAMS,
+		SwapStreamsOp: req,
 	}
 
-	// Wait on result of replication.
-	if err := m.applyRaftOperation(op).Error(); err != nil {
-		return status.Newf(codes.Internal, "Failed to shrink ISR: %v", err.Error())
+	// Wait on result of swap.
+	future := m.applyRaftOperation(op)
+	if err := future.Error(); err != nil {
+		return raftApplyStatus("Failed to swap streams", err)
+	}
+
+	// If there is a response, it's an ErrStreamNotFound.
+	if resp := future.Response(); resp != nil {
+		return status.New(codes.NotFound, resp.(error).Error())
 	}
 
 	return nil
 }
 
-// ExpandISR adds the specified replica to the partition's in-sync replicas set
-// if this server is the metadata leader. If it is not, it will forward the
-// request to the leader and return the response. This operation is replicated
-// by Raft.
-func (m *metadataAPI) ExpandISR(ctx context.Context, req *proto.ExpandISROp) *status.Status {
+// TrimStream deletes all messages before the given offset from each partition
+// of a stream if this server is the metadata leader. If it is not, it will
+// forward the request to the leader and return the response. This operation
+// is replicated by Raft so that every replica trims its log at the same
+// offset. Messages at or after the offset are not touched. Only committed
+// messages may be trimmed, which is checked against the HW of the metadata
+// leader's replicas of the stream's partitions. Paused partitions cannot be
+// trimmed. If successful, this will return once the stream has been trimmed.
+// If the Context carries a fencing token below the fencing epoch, this returns
+// Aborted.
+func (m *metadataAPI) TrimStream(ctx context.Context, req *proto.TrimStreamOp) *status.Status {
+	if req.Offset < 0 {
+		return status.Newf(codes.InvalidArgument, "Invalid trim offset %d", req.Offset)
+	}
+	token, st := m.checkFencingToken(ctx)
+	if st != nil {
+		return st
+	}
+
 	// Forward the request if we're not the leader.
 	if !m.IsLeader() {
-		isLeader, st := m.propagateExpandISR(ctx, req)
+		isLeader, st := m.propagateTrimStream(ctx, req)
 		if st != nil {
 			return st
 		}
@@ -512,45 +874,65 @@ func (m *metadataAPI) ExpandISR(ctx context.Context, req *proto.ExpandISROp) *st
 		}
 	}
 
-	// Verify the partition exists.
-	partition := m.GetPartition(req.Stream, req.Partition)
-	if partition == nil {
-		return status.New(codes.FailedPrecondition, fmt.Sprintf("No such partition [stream=%s, partition=%d]",
-			req.Stream, req.Partition))
+	stream := m.GetStream(req.Stream)
+	if stream == nil {
+		return status.New(codes.NotFound, ErrStreamNotFound.Error())
 	}
-
-	// Check the leader epoch.
-	leader, epoch := partition.GetLeader()
-	if req.Leader != leader || req.LeaderEpoch != epoch {
-		return status.New(
-			codes.FailedPrecondition,
-			fmt.Sprintf("Leader generation mismatch, current leader: %s epoch: %d, got leader: %s epoch: %d",
-				leader, epoch, req.Leader, req.LeaderEpoch))
+	for _, partition := range stream.GetPartitions() {
+		if partition.IsPaused() {
+			return status.Newf(codes.FailedPrecondition, "Partition %d is paused", partition.Id)
+		}
+		// Trimming past the HW would discard uncommitted messages, so the
+		// offset can't be checked against an unknown HW.
+		hw, ok := m.partitionHighWatermark(ctx, partition)
+		if !ok {
+			return status.Newf(codes.FailedPrecondition,
+				"High watermark of partition %d is unknown", partition.Id)
+		}
+		if req.Offset > hw+1 {
+			return status.Newf(codes.OutOfRange,
+				"Offset %d is past the high watermark %d of partition %d", req.Offset, hw, partition.Id)
+		}
 	}
 
-	// Replicate ISR expand through Raft.
+	// Replicate stream trimming through Raft.
 	op := &proto.RaftLog{
-		Op:          proto.Op_EXPAND_ISR,
-		ExpandISROp: req,
+		Op:           proto.Op_TRIM_STREAM,
+		TrimStreamOp: req,
+		FencingToken: token,
 	}
 
-	// Wait on result of replication.
-	if err := m.applyRaftOperation(op).Error(); err != nil {
-		return status.Newf(codes.Internal, "Failed to expand ISR: %v", err.Error())
+	// Wait on result of trimming.
+	future := m.applyRaftOperation(op)
+	if err := future.Error(); err != nil {
+		return raftApplyStatus("Failed to trim stream", err)
+	}
+
+	// If there is a response, it's an ErrStreamNotFound or ErrFenced.
+	if resp := future.Response(); resp != nil {
+		err := resp.(error)
+		code := codes.Internal
+		switch err {
+		case ErrStreamNotFound:
+			code = codes.NotFound
+		case ErrFenced:
+			code = codes.Aborted
+		}
+		return status.New(code, err.Error())
 	}
 
 	return nil
 }
 
-// ReportLeader marks the partition leader as unresponsive with respect to the
-// specified replica if this server is the metadata leader. If it is not, it
-// will forward the request to the leader and return the response. If a quorum
-// of replicas report the partition leader within a bounded period, the
-// metadata leader will select a new partition leader.
-func (m *metadataAPI) ReportLeader(ctx context.Context, req *proto.ReportLeaderOp) *status.Status {
+// ChangeReplicaRole demotes a partition replica to an observer or promotes an
+// observer to a replica if this server is the metadata leader. If it is not,
+// it will forward the request to the leader and return the response. This
+// operation is replicated by Raft. The partition leader cannot be demoted, and
+// the partition must keep at least one replica.
+func (m *metadataAPI) ChangeReplicaRole(ctx context.Context, req *proto.ChangeReplicaRoleOp) *status.Status {
 	// Forward the request if we're not the leader.
 	if !m.IsLeader() {
-		isLeader, st := m.propagateReportLeader(ctx, req)
+		isLeader, st := m.propagateChangeReplicaRole(ctx, req)
 		if st != nil {
 			return st
 		}
@@ -560,35 +942,561 @@ func (m *metadataAPI) ReportLeader(ctx context.Context, req *proto.ReportLeaderO
 		}
 	}
 
-	// Verify the partition exists.
 	partition := m.GetPartition(req.Stream, req.Partition)
 	if partition == nil {
-		return status.New(codes.FailedPrecondition, fmt.Sprintf("No such partition [stream=%s, partition=%d]",
-			req.Stream, req.Partition))
+		return status.New(codes.NotFound, ErrPartitionNotFound.Error())
+	}
+	if req.Observer {
+		if !partition.isReplica(req.Replica) {
+			return status.Newf(codes.FailedPrecondition, "%s is not a replica of partition %d",
+				req.Replica, req.Partition)
+		}
+		if leader, _ := partition.GetLeader(); leader == req.Replica {
+			return status.Newf(codes.FailedPrecondition, "%s is the leader of partition %d",
+				req.Replica, req.Partition)
+		}
+	} else if !partition.isObserver(req.Replica) {
+		return status.Newf(codes.FailedPrecondition, "%s is not an observer of partition %d",
+			req.Replica, req.Partition)
 	}
 
-	// Check the leader epoch.
-	leader, epoch := partition.GetLeader()
-	if req.Leader != leader || req.LeaderEpoch != epoch {
-		return status.New(
-			codes.FailedPrecondition,
-			fmt.Sprintf("Leader generation mismatch, current leader: %s epoch: %d, got leader: %s epoch: %d",
-				leader, epoch, req.Leader, req.LeaderEpoch))
+	// Replicate the role change through Raft.
+	op := &proto.RaftLog{
+		Op:                  proto.Op_CHANGE_REPLICA_ROLE,
+		ChangeReplicaRoleOp: req,
 	}
 
-	m.mu.Lock()
-	reported := m.leaderReports[partition]
-	if reported == nil {
-		reported = &leaderReport{
-			partition:       partition,
+	// Wait on result of the role change.
+	future := m.applyRaftOperation(op)
+	if err := future.Error(); err != nil {
+		return raftApplyStatus("Failed to change replica role", err)
+	}
+
+	// If there is a response, it's an ErrPartitionNotFound or the change no
+	// longer applies to the partition.
+	if resp := future.Response(); resp != nil {
+		err := resp.(error)
+		if isReplicaStateError(err) {
+			return status.New(codes.FailedPrecondition, err.Error())
+		}
+		return status.New(codes.NotFound, err.Error())
+	}
+
+	return nil
+}
+
+// ChangeReplicas sets the replica set of a partition if this server is the
+// metadata leader. If it is not, it will forward the request to the leader and
+// return the response. This operation is replicated by Raft. Added replicas
+// replicate the partition from the leader and join the ISR once they have
+// caught up. Removed replicas leave the ISR and stop replicating. The new
+// replica set must include the partition leader and cannot include observers.
+// If the Context carries a fencing token below the fencing epoch, this returns
+// Aborted.
+func (m *metadataAPI) ChangeReplicas(ctx context.Context, req *proto.ChangeReplicasOp) *status.Status {
+	token, st := m.checkFencingToken(ctx)
+	if st != nil {
+		return st
+	}
+
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		isLeader, st := m.propagateChangeReplicas(ctx, req)
+		if st != nil {
+			return st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return nil
+		}
+	}
+
+	partition := m.GetPartition(req.Stream, req.Partition)
+	if partition == nil {
+		return status.New(codes.NotFound, ErrPartitionNotFound.Error())
+	}
+	ids, err := m.getClusterServerIDs()
+	if err != nil {
+		return status.New(codes.Internal, err.Error())
+	}
+	members := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		members[id] = struct{}{}
+	}
+	seen := make(map[string]struct{}, len(req.Replicas))
+	for _, replica := range req.Replicas {
+		if _, ok := members[replica]; !ok {
+			return status.Newf(codes.InvalidArgument, "No such server %s", replica)
+		}
+		if _, ok := seen[replica]; ok {
+			return status.Newf(codes.InvalidArgument, "Duplicate replica %s", replica)
+		}
+		seen[replica] = struct{}{}
+		if partition.isObserver(replica) {
+			return status.Newf(codes.FailedPrecondition, "%s is an observer of partition %d",
+				replica, req.Partition)
+		}
+	}
+	if leader, _ := partition.GetLeader(); leader != "" {
+		if _, ok := seen[leader]; !ok {
+			return status.Newf(codes.FailedPrecondition, "%s is the leader of partition %d",
+				leader, req.Partition)
+		}
+	}
+
+	// Replicate the replica set change through Raft.
+	op := &proto.RaftLog{
+		Op:               proto.Op_CHANGE_REPLICAS,
+		ChangeReplicasOp: req,
+		FencingToken:     token,
+	}
+
+	// Wait on result of the replica set change.
+	future := m.applyRaftOperation(op)
+	if err := future.Error(); err != nil {
+		return raftApplyStatus("Failed to change replicas", err)
+	}
+
+	// If there is a response, it's an ErrPartitionNotFound, ErrFenced, or the
+	// change no longer applies to the partition.
+	if resp := future.Response(); resp != nil {
+		err := resp.(error)
+		switch {
+		case err == ErrFenced:
+			return status.New(codes.Aborted, err.Error())
+		case isReplicaStateError(err):
+			return status.New(codes.FailedPrecondition, err.Error())
+		}
+		return status.New(codes.NotFound, err.Error())
+	}
+
+	return nil
+}
+
+// ChangeLeader hands off leadership of a partition to the given replica if
+// this server is the metadata leader. If it is not, it will forward the
+// request to the leader and return the response. The new leader must be in the
+// partition's ISR so that it has all committed messages. This operation is
+// replicated by Raft.
+func (m *metadataAPI) ChangeLeader(ctx context.Context, req *proto.ChangeLeaderOp) *status.Status {
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		isLeader, st := m.propagateChangeLeader(ctx, req)
+		if st != nil {
+			return st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return nil
+		}
+	}
+
+	partition := m.GetPartition(req.Stream, req.Partition)
+	if partition == nil {
+		return status.New(codes.NotFound, ErrPartitionNotFound.Error())
+	}
+	if leader, _ := partition.GetLeader(); leader == req.Leader {
+		return nil
+	}
+	if !partition.inISR(req.Leader) {
+		return status.Newf(codes.FailedPrecondition, "%s is not in the ISR of partition %d",
+			req.Leader, req.Partition)
+	}
+
+	return m.changePartitionLeader(partition, req.Leader)
+}
+
+// ShrinkISR removes the specified replica from the partition's in-sync
+// replicas set if this server is the metadata leader. If it is not, it will
+// forward the request to the leader and return the response. This operation is
+// replicated by Raft.
+func (m *metadataAPI) ShrinkISR(ctx context.Context, req *proto.ShrinkISROp) *status.Status {
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		isLeader, st := m.propagateShrinkISR(ctx, req)
+		if st != nil {
+			return st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return nil
+		}
+	}
+
+	// Verify the partition exists.
+	partition := m.GetPartition(req.Stream, req.Partition)
+	if partition == nil {
+		return status.New(codes.FailedPrecondition, fmt.Sprintf("No such partition [stream=%s, partition=%d]",
+			req.Stream, req.Partition))
+	}
+
+	// Check the leader epoch.
+	leader, epoch := partition.GetLeader()
+	if req.Leader != leader || req.LeaderEpoch != epoch {
+		return status.New(
+			codes.FailedPrecondition,
+			fmt.Sprintf("Leader generation mismatch, current leader: %s epoch: %d, got leader: %s epoch: %d",
+				leader, epoch, req.Leader, req.LeaderEpoch))
+	}
+
+	// Replicate ISR shrink through Raft.
+	op := &proto.RaftLog{
+		Op:          proto.Op_SHRINK_ISR,
+		ShrinkISROp: req,
+	}
+
+	// Wait on result of replication.
+	future := m.applyRaftOperation(op)
+	if err := future.Error(); err != nil {
+		return status.Newf(codes.Internal, "Failed to shrink ISR: %v", err.Error())
+	}
+
+	// If there is a response, the replica is no longer a replica.
+	if resp := future.Response(); resp != nil {
+		return status.New(codes.FailedPrecondition, resp.(error).Error())
+	}
+
+	return nil
+}
+
+// ExpandISR adds the specified replica to the partition's in-sync replicas set
+// if this server is the metadata leader. If it is not, it will forward the
+// request to the leader and return the response. This operation is replicated
+// by Raft.
+func (m *metadataAPI) ExpandISR(ctx context.Context, req *proto.ExpandISROp) *status.Status {
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		isLeader, st := m.propagateExpandISR(ctx, req)
+		if st != nil {
+			return st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return nil
+		}
+	}
+
+	// Verify the partition exists.
+	partition := m.GetPartition(req.Stream, req.Partition)
+	if partition == nil {
+		return status.New(codes.FailedPrecondition, fmt.Sprintf("No such partition [stream=%s, partition=%d]",
+			req.Stream, req.Partition))
+	}
+
+	// Check the leader epoch.
+	leader, epoch := partition.GetLeader()
+	if req.Leader != leader || req.LeaderEpoch != epoch {
+		return status.New(
+			codes.FailedPrecondition,
+			fmt.Sprintf("Leader generation mismatch, current leader: %s epoch: %d, got leader: %s epoch: %d",
+				leader, epoch, req.Leader, req.LeaderEpoch))
+	}
+
+	// Replicate ISR expand through Raft.
+	op := &proto.RaftLog{
+		Op:          proto.Op_EXPAND_ISR,
+		ExpandISROp: req,
+	}
+
+	// Wait on result of replication.
+	future := m.applyRaftOperation(op)
+	if err := future.Error(); err != nil {
+		return status.Newf(codes.Internal, "Failed to expand ISR: %v", err.Error())
+	}
+
+	// If there is a response, the replica is no longer a replica.
+	if resp := future.Response(); resp != nil {
+		return status.New(codes.FailedPrecondition, resp.(error).Error())
+	}
+
+	return nil
+}
+
+// ReportLeader marks the partition leader as unresponsive with respect to the
+// specified replica if this server is the metadata leader. If it is not, it
+// will forward the request to the leader and return the response. If a quorum
+// of replicas report the partition leader within a bounded period, the
+// metadata leader will select a new partition leader.
+func (m *metadataAPI) ReportLeader(ctx context.Context, req *proto.ReportLeaderOp) *status.Status {
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		isLeader, st := m.propagateReportLeader(ctx, req)
+		if st != nil {
+			return st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return nil
+		}
+	}
+
+	// Verify the partition exists.
+	partition := m.GetPartition(req.Stream, req.Partition)
+	if partition == nil {
+		return status.New(codes.FailedPrecondition, fmt.Sprintf("No such partition [stream=%s, partition=%d]",
+			req.Stream, req.Partition))
+	}
+
+	// Check the leader epoch.
+	leader, epoch := partition.GetLeader()
+	if req.Leader != leader || req.LeaderEpoch != epoch {
+		return status.New(
+			codes.FailedPrecondition,
+			fmt.Sprintf("Leader generation mismatch, current leader: %s epoch: %d, got leader: %s epoch: %d",
+				leader, epoch, req.Leader, req.LeaderEpoch))
+	}
+
+	m.mu.Lock()
+	reported := m.leaderReports[partition]
+	if reported == nil {
+		reported = &leaderReport{
+			partition:       partition,
 			witnessReplicas: make(map[string]struct{}),
 			api:             m,
 		}
-		m.leaderReports[partition] = reported
+		m.leaderReports[partition] = reported
+	}
+	m.mu.Unlock()
+
+	return reported.addWitness(req.Replica)
+}
+
+// ReportActivity records activity on the stream of the specified partition
+// if this server is the metadata leader, keeping the stream from being
+// deleted when idle. If it is not, it will forward the request to the leader.
+func (m *metadataAPI) ReportActivity(ctx context.Context, req *proto.ReportActivityOp) *status.Status {
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		isLeader, st := m.propagateReportActivity(ctx, req)
+		if st != nil {
+			return st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return nil
+		}
+	}
+
+	// Verify the partition exists.
+	if m.GetPartition(req.Stream, req.Partition) == nil {
+		return status.New(codes.FailedPrecondition, fmt.Sprintf("No such partition [stream=%s, partition=%d]",
+			req.Stream, req.Partition))
+	}
+
+	m.idleDeleter.Touch(req.Stream)
+	return nil
+}
+
+// ConfirmLeader verifies that the leader and leader epoch in the request are
+// still current for the specified partition if this server is the metadata
+// leader. A Raft barrier is applied first so that the check reflects every
+// committed leader change. If this server is not the metadata leader, it will
+// forward the request to the leader and return the response.
+func (m *metadataAPI) ConfirmLeader(ctx context.Context, req *proto.ConfirmLeaderOp) *status.Status {
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		isLeader, st := m.propagateConfirmLeader(ctx, req)
+		if st != nil {
+			return st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return nil
+		}
+	}
+
+	// The barrier fails if this server is no longer the metadata leader.
+	if err := m.getRaft().Barrier(5 * time.Second).Error(); err != nil {
+		return status.New(codes.Unavailable, err.Error())
+	}
+
+	partition := m.GetPartition(req.Stream, req.Partition)
+	if partition == nil {
+		return status.New(codes.FailedPrecondition, fmt.Sprintf("No such partition [stream=%s, partition=%d]",
+			req.Stream, req.Partition))
+	}
+
+	leader, epoch := partition.GetLeader()
+	if leader != req.Leader || epoch != req.LeaderEpoch {
+		return status.New(codes.FailedPrecondition, fmt.Sprintf(
+			"Server %s is not the leader of partition [stream=%s, partition=%d], leader is %s for epoch %d",
+			req.Leader, req.Stream, req.Partition, leader, epoch))
+	}
+	return nil
+}
+
+// SetServerReadOnly marks the specified server read-only, or writable again,
+// if this server is the metadata leader. If it is not, it will forward the
+// request to the leader and return the response. This operation is replicated
+// by Raft. A read-only server keeps its replicas but is not selected to lead
+// new partitions and is only elected leader of a partition when no other ISR
+// replica is available.
+func (m *metadataAPI) SetServerReadOnly(ctx context.Context, req *proto.SetServerReadOnlyOp) *status.Status {
+	// Forward the request if we're not the leader.
+	if !m.IsLeader() {
+		isLeader, st := m.propagateSetServerReadOnly(ctx, req)
+		if st != nil {
+			return st
+		}
+		// If we have since become leader, continue on with the request.
+		if !isLeader {
+			return nil
+		}
+	}
+
+	ids, err := m.getClusterServerIDs()
+	if err != nil {
+		return status.New(codes.Internal, err.Error())
+	}
+	member := false
+	for _, id := range ids {
+		if id == req.Server {
+			member = true
+			break
+		}
+	}
+	if !member {
+		return status.Newf(codes.InvalidArgument, "No such server %s", req.Server)
+	}
+
+	// Replicate the server's mode through Raft.
+	op := &proto.RaftLog{
+		Op:                  proto.Op_SET_SERVER_READ_ONLY,
+		SetServerReadOnlyOp: req,
+	}
+
+	// Wait on result of replication.
+	future := m.applyRaftOperation(op)
+	if err := future.Error(); err != nil {
+		return raftApplyStatus("Failed to set server read-only", err)
+	}
+	return nil
+}
+
+// SetReadOnly sets whether the given server is read-only in the metadata
+// store.
+func (m *metadataAPI) SetReadOnly(server string, readOnly bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if readOnly {
+		m.readOnlyServers[server] = struct{}{}
+	} else {
+		delete(m.readOnlyServers, server)
+	}
+}
+
+// GetFencingEpoch returns the highest fencing token accepted for an admin
+// operation.
+func (m *metadataAPI) GetFencingEpoch() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.fencingEpoch
+}
+
+// setFencingEpoch sets the highest fencing token accepted, e.g. when
+// restoring a snapshot.
+func (m *metadataAPI) setFencingEpoch(epoch uint64) {
+	m.mu.Lock()
+	m.fencingEpoch = epoch
+	m.mu.Unlock()
+}
+
+// fence returns ErrFenced if the fencing token is below the fencing epoch.
+// Otherwise it raises the fencing epoch to the token. This is called when a
+// Raft entry with a fencing token is applied so that every server agrees on
+// which operations are fenced.
+func (m *metadataAPI) fence(token uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if token < m.fencingEpoch {
+		return ErrFenced
+	}
+	m.fencingEpoch = token
+	return nil
+}
+
+// checkFencingToken returns the fencing token from the request metadata of
+// the Context. A Status is returned if the token is invalid or already below
+// the fencing epoch, which can be checked early since the epoch never
+// decreases.
+func (m *metadataAPI) checkFencingToken(ctx context.Context) (uint64, *status.Status) {
+	token, err := getFencingToken(ctx)
+	if err != nil {
+		return 0, status.New(codes.InvalidArgument, err.Error())
+	}
+	if epoch := m.GetFencingEpoch(); token > 0 && token < epoch {
+		return 0, status.Newf(codes.Aborted, "Fencing token %d is below the fencing epoch %d", token, epoch)
+	}
+	return token, nil
+}
+
+// GetReadOnlyServers returns the IDs of the servers which are read-only.
+func (m *metadataAPI) GetReadOnlyServers() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	servers := make([]string, 0, len(m.readOnlyServers))
+	for server := range m.readOnlyServers {
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+// getLeaderCandidates returns the given replicas which are not read-only and
+// so can be selected to lead a partition.
+func (m *metadataAPI) getLeaderCandidates(replicas []string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	candidates := make([]string, 0, len(replicas))
+	for _, replica := range replicas {
+		if _, ok := m.readOnlyServers[replica]; !ok {
+			candidates = append(candidates, replica)
+		}
 	}
-	m.mu.Unlock()
+	return candidates
+}
 
-	return reported.addWitness(req.Replica)
+// selectPartitionLeader selects a random replica which is not read-only to
+// lead a new partition. A Status is returned if every replica is read-only.
+func (m *metadataAPI) selectPartitionLeader(replicas []string) (string, *status.Status) {
+	candidates := m.getLeaderCandidates(replicas)
+	if len(candidates) == 0 {
+		return "", status.Newf(codes.FailedPrecondition,
+			"Cannot select partition leader, replicas %v are read-only", replicas)
+	}
+	return selectRandomReplica(candidates), nil
+}
+
+// AddStream adds the given stream partitions to the metadata store. It returns
+// ErrStreamExists if there already exists a stream with the same name. If the
+// partitions are recovered, this will not start them until recovery
+// completes.
+func (m *metadataAPI) AddStream(protoPartitions []*proto.Partition, recovered bool) ([]*partition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(protoPartitions) == 0 {
+		return nil, errors.New("no partitions provided")
+	}
+	if _, ok := m.streams[protoPartitions[0].Stream]; ok {
+		return nil, ErrStreamExists
+	}
+
+	partitions := make([]*partition, len(protoPartitions))
+	for i, protoPartition := range protoPartitions {
+		partition, err := m.addPartition(protoPartition, recovered)
+		if err != nil {
+			return nil, err
+		}
+		partitions[i] = partition
+	}
+
+	// Start leader/follower loops if necessary.
+	for _, partition := range partitions {
+		leader, epoch := partition.GetLeader()
+		if err := partition.SetLeader(leader, epoch); err != nil {
+			return nil, err
+		}
+	}
+	return partitions, nil
 }
 
 // AddPartition adds the given stream partition to the metadata store. It
@@ -615,9 +1523,16 @@ func (m *metadataAPI) addPartition(protoPartition *proto.Partition, recovered bo
 		st = newStream(protoPartition.Stream, protoPartition.Subject)
 		m.streams[protoPartition.Stream] = st
 	}
-	if p := st.GetPartition(protoPartition.Id); p != nil && !p.paused {
-		// Partition already exists for stream.
-		return nil, ErrPartitionExists
+	if p := st.GetPartition(protoPartition.Id); p != nil {
+		if !p.IsPaused() {
+			// Partition already exists for stream.
+			return nil, ErrPartitionExists
+		}
+		// Release the paused partition's log in case it was reopened for
+		// subscribers.
+		if err := p.Close(); err != nil {
+			return nil, err
+		}
 	}
 
 	// This will initialize/recover the durable commit log.
@@ -656,14 +1571,33 @@ func (m *metadataAPI) GetPartition(streamName string, id int32) *partition {
 	return stream.GetPartition(id)
 }
 
+// UnderReplicatedStreams returns the number of streams which have at least
+// one partition whose in-sync replicas set is smaller than its replication
+// factor. Since ISR changes are replicated through Raft, this reflects the
+// cluster-wide view on any server.
+func (m *metadataAPI) UnderReplicatedStreams() int {
+	count := 0
+	for _, stream := range m.GetStreams() {
+		for _, partition := range stream.GetPartitions() {
+			if partition.IsUnderReplicated() {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
 // Reset closes all streams and clears all existing state in the metadata
 // store.
 func (m *metadataAPI) Reset() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	// Close every stream even if one fails so none are left running.
+	var closeErr error
 	for _, stream := range m.getStreams() {
-		if err := stream.Close(); err != nil {
-			return err
+		if err := stream.Close(); err != nil && closeErr == nil {
+			closeErr = err
 		}
 	}
 	m.streams = make(map[string]*stream)
@@ -671,7 +1605,9 @@ func (m *metadataAPI) Reset() error {
 		report.cancel()
 	}
 	m.leaderReports = make(map[*partition]*leaderReport)
-	return nil
+	m.readOnlyServers = make(map[string]struct{})
+	m.fencingEpoch = 0
+	return closeErr
 }
 
 // CloseStream close a streams and clears corresponding state in the metadata
@@ -686,7 +1622,13 @@ func (m *metadataAPI) CloseAndDeleteStream(stream *stream) error {
 	}
 
 	// Remove the (now empty) stream data directory
-	streamDataDir := filepath.Join(m.Server.config.DataDir, "streams", stream.GetName())
+	streamDataDir := m.Server.streamDataDir(stream.GetName())
+	if err := os.Remove(filepath.Join(streamDataDir, moveMarkerFile)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to delete stream move marker")
+	}
+	if err := removeSwapTags(streamDataDir); err != nil {
+		return err
+	}
 	err = os.Remove(streamDataDir)
 	if err != nil {
 		return errors.Wrap(err, "failed to delete stream data directory")
@@ -705,6 +1647,142 @@ func (m *metadataAPI) CloseAndDeleteStream(stream *stream) error {
 	return nil
 }
 
+// CloseAndRenameStream closes the given stream and re-adds its partitions
+// under the new name, moving the stream data directory so that the partition
+// commit logs are preserved. It returns ErrStreamExists if there already
+// exists a stream with the new name. The index is that of the Raft entry
+// performing the rename, which is recorded in the data directory so that
+// replaying the entry doesn't move it again. If recovered is true, the
+// partitions will not be started until recovery completes.
+func (m *metadataAPI) CloseAndRenameStream(stream *stream, newName string, index uint64, recovered bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.streams[newName]; ok {
+		return ErrStreamExists
+	}
+
+	protoPartitions := m.renamePartitions(stream, newName, stream.GetSubject())
+
+	if err := stream.Close(); err != nil {
+		return errors.Wrap(err, "failed to close stream")
+	}
+
+	err := renameStreamDataDir(m.Server.streamDataDir(stream.GetName()),
+		m.Server.streamDataDir(newName), index, recovered)
+	if err != nil {
+		return err
+	}
+
+	delete(m.streams, stream.GetName())
+
+	return m.addRenamedPartitions(protoPartitions, recovered)
+}
+
+// CloseAndSwapStreams closes the given streams and re-adds the partitions of
+// each under the other's name and subject, exchanging their stream data
+// directories so that the partition commit logs follow them. The index is
+// that of the Raft entry performing the swap, which is recorded in the data
+// directories so that replaying the entry doesn't exchange them again. If
+// recovered is true, the partitions will be created but not started.
+func (m *metadataAPI) CloseAndSwapStreams(stream, other *stream, index uint64, recovered bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var (
+		name            = stream.GetName()
+		otherName       = other.GetName()
+		protoPartitions = append(
+			m.renamePartitions(stream, otherName, other.GetSubject()),
+			m.renamePartitions(other, name, stream.GetSubject())...)
+	)
+
+	if err := stream.Close(); err != nil {
+		return errors.Wrap(err, "failed to close stream")
+	}
+	if err := other.Close(); err != nil {
+		return errors.Wrap(err, "failed to close stream")
+	}
+
+	err := swapStreamDataDirs(m.Server.streamDataDir(name), m.Server.streamDataDir(otherName),
+		m.Server.streamSwapDir(), index, recovered)
+	if err != nil {
+		return err
+	}
+
+	delete(m.streams, name)
+	delete(m.streams, otherName)
+
+	return m.addRenamedPartitions(protoPartitions, recovered)
+}
+
+// renamePartitions returns the partitions of the given stream under the new
+// stream name and subject and cancels any pending leader reports for them.
+// This must be called with the metadata lock held.
+func (m *metadataAPI) renamePartitions(stream *stream, newName, subject string) []*proto.Partition {
+	partitions := stream.GetPartitions()
+	protoPartitions := make([]*proto.Partition, 0, len(partitions))
+	for _, partition := range partitions {
+		leader, leaderEpoch := partition.GetLeader()
+		protoPartitions = append(protoPartitions, &proto.Partition{
+			Subject:              subject,
+			Stream:               newName,
+			Id:                   partition.Id,
+			Group:                partition.Group,
+			ReplicationFactor:    partition.ReplicationFactor,
+			Replicas:             partition.GetReplicas(),
+			Leader:               leader,
+			Isr:                  partition.GetISR(),
+			LeaderEpoch:          leaderEpoch,
+			Epoch:                partition.GetEpoch(),
+			ReplicaMaxLagTime:    partition.GetReplicaMaxLagTime(),
+			ReplicaMaxLagOffsets: partition.GetReplicaMaxLagOffsets(),
+			ReplicaFetchTimeout:  partition.GetReplicaFetchTimeout(),
+			RequireKey:           partition.GetRequireKey(),
+			EmptyValue:           partition.GetEmptyValue(),
+			Observers:            partition.GetObservers(),
+			Schema:               partition.GetSchema(),
+			IdleDeleteTime:       partition.GetIdleDeleteTime(),
+			MaxCommitWaiters:     partition.GetMaxCommitWaiters(),
+			RetentionMaxAge:      partition.GetRetentionMaxAge(),
+			QuotaMaxBytes:        partition.GetQuotaMaxBytes(),
+			TrimOffset:           partition.GetTrimOffset(),
+			PinnedServers:        partition.GetPinnedServers(),
+			RetentionMaxSegments: partition.GetRetentionMaxSegments(),
+			CompressionThreshold: partition.GetCompressionThreshold(),
+		})
+		report, ok := m.leaderReports[partition]
+		if ok {
+			report.cancel()
+			delete(m.leaderReports, partition)
+		}
+	}
+	return protoPartitions
+}
+
+// addRenamedPartitions adds the given partitions of a renamed stream and
+// starts them. This must be called with the metadata lock held.
+func (m *metadataAPI) addRenamedPartitions(protoPartitions []*proto.Partition, recovered bool) error {
+	renamed := make([]*partition, len(protoPartitions))
+	for i, protoPartition := range protoPartitions {
+		partition, err := m.addPartition(protoPartition, recovered)
+		if err != nil {
+			return err
+		}
+		renamed[i] = partition
+	}
+
+	// Start leader/follower loops if necessary.
+	for _, partition := range renamed {
+		leader, epoch := partition.GetLeader()
+		if err := partition.SetLeader(leader, epoch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // LostLeadership should be called when the server loses metadata leadership.
 func (m *metadataAPI) LostLeadership() {
 	m.mu.Lock()
@@ -724,14 +1802,46 @@ func (m *metadataAPI) getStreams() []*stream {
 }
 
 // getPartitionReplicas selects replicationFactor replicas to participate in
-// the stream partition.
-func (m *metadataAPI) getPartitionReplicas(replicationFactor int32) ([]string, *status.Status) {
+// the stream partition. If servers is not empty, replicas are only selected
+// from those servers, each of which must be a member of the cluster. If a
+// maximum number of replicas per server is configured, servers which have
+// reached it according to replicaCounts are not selected. At least one
+// selected replica is not read-only, if possible, so that it can lead the
+// partition. The selected replicas are added to replicaCounts.
+func (m *metadataAPI) getPartitionReplicas(replicationFactor int32, servers []string,
+	replicaCounts map[string]int) ([]string, *status.Status) {
+
 	// TODO: Currently this selection is random but could be made more
 	// intelligent, e.g. selecting based on current load.
 	ids, err := m.getClusterServerIDs()
 	if err != nil {
 		return nil, status.New(codes.Internal, err.Error())
 	}
+	if len(servers) > 0 {
+		members := make(map[string]struct{}, len(ids))
+		for _, id := range ids {
+			members[id] = struct{}{}
+		}
+		var (
+			pinned = make([]string, 0, len(servers))
+			seen   = make(map[string]struct{}, len(servers))
+		)
+		for _, server := range servers {
+			if _, ok := members[server]; !ok {
+				return nil, status.Newf(codes.InvalidArgument, "No such server %s", server)
+			}
+			if _, ok := seen[server]; ok {
+				continue
+			}
+			seen[server] = struct{}{}
+			pinned = append(pinned, server)
+		}
+		if replicationFactor > int32(len(pinned)) {
+			return nil, status.Newf(codes.InvalidArgument, "Invalid replicationFactor %d, servers specified %d",
+				replicationFactor, len(pinned))
+		}
+		ids = pinned
+	}
 	if replicationFactor == maxReplicationFactor {
 		replicationFactor = int32(len(ids))
 	}
@@ -742,16 +1852,57 @@ func (m *metadataAPI) getPartitionReplicas(replicationFactor int32) ([]string, *
 		return nil, status.Newf(codes.InvalidArgument, "Invalid replicationFactor %d, cluster size %d",
 			replicationFactor, len(ids))
 	}
+	if max := m.config.Clustering.ServerMaxReplicas; max > 0 {
+		available := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if replicaCounts[id] < max {
+				available = append(available, id)
+			}
+		}
+		if replicationFactor > int32(len(available)) {
+			return nil, status.Newf(codes.ResourceExhausted,
+				"Cannot place %d replicas, only %d servers are below the limit of %d replicas per server",
+				replicationFactor, len(available), max)
+		}
+		ids = available
+	}
 	var (
 		indexes  = rand.Perm(len(ids))
 		replicas = make([]string, replicationFactor)
 	)
+	// Make sure a server which isn't read-only is selected, if there is one,
+	// so that the partition can be given a leader.
+	writable := make(map[string]struct{})
+	for _, id := range m.getLeaderCandidates(ids) {
+		writable[id] = struct{}{}
+	}
+	for j, index := range indexes {
+		if _, ok := writable[ids[index]]; ok {
+			indexes[0], indexes[j] = indexes[j], indexes[0]
+			break
+		}
+	}
 	for i := int32(0); i < replicationFactor; i++ {
 		replicas[i] = ids[indexes[i]]
+		replicaCounts[replicas[i]]++
 	}
 	return replicas, nil
 }
 
+// getReplicaCounts returns the number of stream partition replicas placed on
+// each server.
+func (m *metadataAPI) getReplicaCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, stream := range m.GetStreams() {
+		for _, partition := range stream.GetPartitions() {
+			for _, replica := range partition.GetReplicas() {
+				counts[replica]++
+			}
+		}
+	}
+	return counts
+}
+
 // getClusterServerIDs returns a list of all the broker IDs in the cluster.
 func (m *metadataAPI) getClusterServerIDs() ([]string, error) {
 	future := m.getRaft().GetConfiguration()
@@ -768,6 +1919,31 @@ func (m *metadataAPI) getClusterServerIDs() ([]string, error) {
 	return ids, nil
 }
 
+// getMetadataRoles returns the ID of the metadata leader, the IDs of the
+// remaining members of the metadata Raft group, and this server's current
+// Raft term. The leader is empty if there is no known leader.
+func (m *metadataAPI) getMetadataRoles() (string, []string, uint64, error) {
+	var (
+		node   = m.getRaft()
+		leader = string(node.Leader())
+	)
+	term, err := strconv.ParseUint(node.Stats()["term"], 10, 64)
+	if err != nil {
+		return "", nil, 0, errors.Wrap(err, "failed to parse Raft term")
+	}
+	servers, err := m.getClusterServerIDs()
+	if err != nil {
+		return "", nil, 0, err
+	}
+	followers := make([]string, 0, len(servers))
+	for _, id := range servers {
+		if id != leader {
+			followers = append(followers, id)
+		}
+	}
+	return leader, followers, term, nil
+}
+
 // electNewPartitionLeader selects a new leader for the given partition,
 // applies this update to the Raft group, and notifies the replica set. This
 // will fail if the current broker is not the metadata leader.
@@ -792,15 +1968,31 @@ func (m *metadataAPI) electNewPartitionLeader(partition *partition) *status.Stat
 		return status.New(codes.FailedPrecondition, "No ISR candidates")
 	}
 
-	// Select a new leader at random.
-	leader = selectRandomReplica(candidates)
+	// Avoid electing read-only servers unless they are the only candidates
+	// so that the partition stays available.
+	if writable := m.getLeaderCandidates(candidates); len(writable) > 0 {
+		candidates = writable
+	}
+
+	if m.config.Clustering.LeaderElectionPreference == LeaderElectionRandom {
+		leader = selectRandomReplica(candidates)
+	} else {
+		leader = m.selectMostCaughtUpReplica(partition, candidates)
+	}
+
+	return m.changePartitionLeader(partition, leader)
+}
 
+// changePartitionLeader applies a leader change for the given partition to the
+// Raft group. This will fail if the current broker is not the metadata leader.
+func (m *metadataAPI) changePartitionLeader(partition *partition, leader string) *status.Status {
 	// Replicate leader change through Raft.
 	op := &proto.RaftLog{
 		Op: proto.Op_CHANGE_LEADER,
 		ChangeLeaderOp: &proto.ChangeLeaderOp{
-			Stream: partition.Stream,
-			Leader: leader,
+			Stream:    partition.Stream,
+			Partition: partition.Id,
+			Leader:    leader,
 		},
 	}
 
@@ -824,6 +2016,45 @@ func (m *metadataAPI) propagateCreatePartition(ctx context.Context, req *proto.C
 	return m.propagateRequest(ctx, propagate)
 }
 
+// propagateCreateStream forwards a CreateStream request to the metadata
+// leader. The bool indicates if this server has since become leader and the
+// request should be performed locally. A Status is returned if the propagated
+// request failed.
+func (m *metadataAPI) propagateCreateStream(ctx context.Context, req *proto.CreateStreamOp) (bool, *status.Status) {
+	propagate := &proto.PropagatedRequest{
+		Op:             proto.Op_CREATE_STREAM,
+		CreateStreamOp: req,
+	}
+	return m.propagateRequest(ctx, propagate)
+}
+
+// propagateCreateStreams forwards a CreateStreams request to the metadata
+// leader and returns the result of each stream. The bool indicates if this
+// server has since become leader and the request should be performed
+// locally. A Status is returned if the propagated request failed.
+func (m *metadataAPI) propagateCreateStreams(ctx context.Context, req *proto.CreateStreamsOp) (
+	[]*status.Status, bool, *status.Status) {
+
+	propagate := &proto.PropagatedRequest{
+		Op:              proto.Op_CREATE_STREAMS,
+		CreateStreamsOp: req,
+	}
+	resp, isLeader, st := m.propagate(ctx, propagate)
+	if st != nil || isLeader {
+		return nil, isLeader, st
+	}
+	if resp.CreateStreamsResp == nil || len(resp.CreateStreamsResp.Results) != len(req.Streams) {
+		return nil, false, status.New(codes.Internal, "invalid response")
+	}
+	results := make([]*status.Status, len(req.Streams))
+	for i, result := range resp.CreateStreamsResp.Results {
+		if codes.Code(result.Code) != codes.OK {
+			results[i] = status.New(codes.Code(result.Code), result.Msg)
+		}
+	}
+	return results, false, nil
+}
+
 // propagateDeleteStream forwards a DeleteStream request to the metadata
 // leader. The bool indicates if this server has since become leader and the
 // request should be performed locally. A Status is returned if the propagated
@@ -848,6 +2079,98 @@ func (m *metadataAPI) propagatePauseStream(ctx context.Context, req *proto.Pause
 	return m.propagateRequest(ctx, propagate)
 }
 
+// propagateRenameStream forwards a RenameStream request to the metadata
+// leader. The bool indicates if this server has since become leader and the
+// request should be performed locally. A Status is returned if the propagated
+// request failed.
+func (m *metadataAPI) propagateRenameStream(ctx context.Context, req *proto.RenameStreamOp) (bool, *status.Status) {
+	propagate := &proto.PropagatedRequest{
+		Op:             proto.Op_RENAME_STREAM,
+		RenameStreamOp: req,
+	}
+	return m.propagateRequest(ctx, propagate)
+}
+
+// propagateSwapStreams forwards a SwapStreams request to the metadata leader.
+// The bool indicates if this server has since become leader and the request
+// should be performed locally. A Status is returned if the propagated request
+// failed.
+func (m *metadataAPI) propagateSwapStreams(ctx context.Context, req *proto.SwapStreamsOp) (bool, *status.Status) {
+	propagate := &proto.PropagatedRequest{
+		Op:            proto.Op_SWAP_STREAMS,
+		SwapStreamsOp: req,
+	}
+	return m.propagateRequest(ctx, propagate)
+}
+
+// propagateTrimStream forwards a TrimStream request to the metadata leader.
+// The bool indicates if this server has since become leader and the request
+// should be performed locally. A Status is returned if the propagated request
+// failed.
+func (m *metadataAPI) propagateTrimStream(ctx context.Context, req *proto.TrimStreamOp) (bool, *status.Status) {
+	propagate := &proto.PropagatedRequest{
+		Op:           proto.Op_TRIM_STREAM,
+		TrimStreamOp: req,
+	}
+	return m.propagateRequest(ctx, propagate)
+}
+
+// propagateSetServerReadOnly forwards a SetServerReadOnly request to the
+// metadata leader. The bool indicates if this server has since become leader
+// and the request should be performed locally. A Status is returned if the
+// propagated request failed.
+func (m *metadataAPI) propagateSetServerReadOnly(ctx context.Context, req *proto.SetServerReadOnlyOp) (
+	bool, *status.Status) {
+
+	propagate := &proto.PropagatedRequest{
+		Op:                  proto.Op_SET_SERVER_READ_ONLY,
+		SetServerReadOnlyOp: req,
+	}
+	return m.propagateRequest(ctx, propagate)
+}
+
+// propagateChangeReplicaRole forwards a ChangeReplicaRole request to the
+// metadata leader. The bool indicates if this server has since become leader
+// and the request should be performed locally. A Status is returned if the
+// propagated request failed.
+func (m *metadataAPI) propagateChangeReplicaRole(ctx context.Context, req *proto.ChangeReplicaRoleOp) (
+	bool, *status.Status) {
+
+	propagate := &proto.PropagatedRequest{
+		Op:                  proto.Op_CHANGE_REPLICA_ROLE,
+		ChangeReplicaRoleOp: req,
+	}
+	return m.propagateRequest(ctx, propagate)
+}
+
+// propagateChangeReplicas forwards a ChangeReplicas request to the metadata
+// leader. The bool indicates if this server has since become leader and the
+// request should be performed locally. A Status is returned if the propagated
+// request failed.
+func (m *metadataAPI) propagateChangeReplicas(ctx context.Context, req *proto.ChangeReplicasOp) (
+	bool, *status.Status) {
+
+	propagate := &proto.PropagatedRequest{
+		Op:               proto.Op_CHANGE_REPLICAS,
+		ChangeReplicasOp: req,
+	}
+	return m.propagateRequest(ctx, propagate)
+}
+
+// propagateChangeLeader forwards a ChangeLeader request to the metadata
+// leader. The bool indicates if this server has since become leader and the
+// request should be performed locally. A Status is returned if the propagated
+// request failed.
+func (m *metadataAPI) propagateChangeLeader(ctx context.Context, req *proto.ChangeLeaderOp) (
+	bool, *status.Status) {
+
+	propagate := &proto.PropagatedRequest{
+		Op:             proto.Op_CHANGE_LEADER,
+		ChangeLeaderOp: req,
+	}
+	return m.propagateRequest(ctx, propagate)
+}
+
 // propagateShrinkISR forwards a ShrinkISR request to the metadata leader. The
 // bool indicates if this server has since become leader and the request should
 // be performed locally. A Status is returned if the propagated request failed.
@@ -882,19 +2205,76 @@ func (m *metadataAPI) propagateReportLeader(ctx context.Context, req *proto.Repo
 	return m.propagateRequest(ctx, propagate)
 }
 
+// propagateReportActivity forwards a ReportActivity request to the metadata
+// leader. The bool indicates if this server has since become leader and the
+// request should be performed locally. A Status is returned if the propagated
+// request failed.
+func (m *metadataAPI) propagateReportActivity(ctx context.Context, req *proto.ReportActivityOp) (bool, *status.Status) {
+	propagate := &proto.PropagatedRequest{
+		Op:               proto.Op_REPORT_ACTIVITY,
+		ReportActivityOp: req,
+	}
+	return m.propagateRequest(ctx, propagate)
+}
+
+// propagateConfirmLeader forwards a ConfirmLeader request to the metadata
+// leader. The bool indicates if this server has since become leader and the
+// request should be performed locally. A Status is returned if the propagated
+// request failed.
+func (m *metadataAPI) propagateConfirmLeader(ctx context.Context, req *proto.ConfirmLeaderOp) (bool, *status.Status) {
+	propagate := &proto.PropagatedRequest{
+		Op:              proto.Op_CONFIRM_LEADER,
+		ConfirmLeaderOp: req,
+	}
+	return m.propagateRequest(ctx, propagate)
+}
+
+// propagatedContext returns a Context carrying the fencing token forwarded
+// with a propagated request, if any, as request metadata.
+func propagatedContext(req *proto.PropagatedRequest) context.Context {
+	ctx := context.Background()
+	if req.FencingToken == 0 {
+		return ctx
+	}
+	return grpcMetadata.NewIncomingContext(ctx, grpcMetadata.Pairs(
+		FencingTokenMetadataKey, strconv.FormatUint(req.FencingToken, 10)))
+}
+
 // propagateRequest forwards a metadata request to the metadata leader. The
 // bool indicates if this server has since become leader and the request should
 // be performed locally. A Status is returned if the propagated request failed.
 func (m *metadataAPI) propagateRequest(ctx context.Context, req *proto.PropagatedRequest) (bool, *status.Status) {
+	_, isLeader, st := m.propagate(ctx, req)
+	return isLeader, st
+}
+
+// propagate forwards a metadata request to the metadata leader and returns
+// its response. The bool indicates if this server has since become leader and
+// the request should be performed locally. A Status is returned if the
+// propagated request failed.
+func (m *metadataAPI) propagate(ctx context.Context, req *proto.PropagatedRequest) (
+	*proto.PropagatedResponse, bool, *status.Status) {
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultPropagateTimeout)
+		defer cancel()
+	}
+
+	// Forward the fencing token of the original request, if any.
+	if token, err := getFencingToken(ctx); err == nil {
+		req.FencingToken = token
+	}
+
 	// Check if there is currently a metadata leader.
 	isLeader, err := m.waitForMetadataLeader(ctx)
 	if err != nil {
-		return false, status.New(codes.Internal, err.Error())
+		return nil, false, status.New(codes.Internal, err.Error())
 	}
 	// This server has since become metadata leader, so the request should be
 	// performed locally.
 	if isLeader {
-		return true, nil
+		return nil, true, nil
 	}
 
 	data, err := proto.MarshalPropagatedRequest(req)
@@ -902,27 +2282,50 @@ func (m *metadataAPI) propagateRequest(ctx context.Context, req *proto.Propagate
 		panic(err)
 	}
 
-	if _, ok := ctx.Deadline(); !ok {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, defaultPropagateTimeout)
-		defer cancel()
-	}
+	// Abandon the request if the metadata leader changes while waiting on a
+	// response since the leader it was sent to may be gone.
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go m.cancelOnLeaderChange(reqCtx, cancel, m.getRaft().Leader())
 
-	resp, err := m.nc.RequestWithContext(ctx, m.getPropagateInbox(), data)
+	resp, err := m.nc.RequestWithContext(reqCtx, m.getPropagateInbox(), data)
 	if err != nil {
-		return false, status.New(codes.Internal, err.Error())
+		if ctx.Err() == nil && reqCtx.Err() != nil {
+			return nil, false, status.New(codes.Unavailable, errMetadataLeaderChanged)
+		}
+		return nil, false, status.New(codes.Internal, err.Error())
 	}
 
 	r, err := proto.UnmarshalPropagatedResponse(resp.Data)
 	if err != nil {
 		m.logger.Errorf("metadata: Invalid response for propagated request: %v", err)
-		return false, status.New(codes.Internal, "invalid response")
+		return nil, false, status.New(codes.Internal, "invalid response")
 	}
 	if r.Error != nil {
-		return false, status.New(codes.Code(r.Error.Code), r.Error.Msg)
+		return nil, false, status.New(codes.Code(r.Error.Code), r.Error.Msg)
 	}
 
-	return false, nil
+	return r, false, nil
+}
+
+// cancelOnLeaderChange invokes the cancel function if the metadata leader
+// changes from the given leader before the Context is done.
+func (m *metadataAPI) cancelOnLeaderChange(ctx context.Context, cancel context.CancelFunc,
+	leader raft.ServerAddress) {
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if m.getRaft().Leader() != leader {
+				cancel()
+				return
+			}
+		}
+	}
 }
 
 // waitForMetadataLeader waits up to the deadline specified on the Context
@@ -948,6 +2351,45 @@ func (m *metadataAPI) waitForMetadataLeader(ctx context.Context) (bool, error) {
 	return false, nil
 }
 
+// partitionHighWatermark returns the HW of the given partition and whether it
+// is known. If this server is a replica, its own HW is used, which is
+// conservative since a follower's HW trails the partition leader's. Otherwise
+// the partition leader is asked for its HW.
+func (m *metadataAPI) partitionHighWatermark(ctx context.Context, partition *partition) (int64, bool) {
+	if hw, ok := partition.ReplicaHighWatermark(); ok {
+		return hw, true
+	}
+	leader, _ := partition.GetLeader()
+	if leader == "" {
+		return 0, false
+	}
+	req, err := proto.MarshalPartitionStatusRequest(&proto.PartitionStatusRequest{
+		Stream:    partition.Stream,
+		Partition: partition.Id,
+	})
+	if err != nil {
+		panic(err)
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, partitionStatusTimeout)
+	defer cancel()
+	resp, err := m.ncRaft.RequestWithContext(reqCtx, m.getPartitionStatusInbox(leader), req)
+	if err != nil {
+		m.logger.Warnf("Failed to get status for partition %s from leader %s: %v",
+			partition, leader, err)
+		return 0, false
+	}
+	statusResp, err := proto.UnmarshalPartitionStatusResponse(resp.Data)
+	if err != nil {
+		m.logger.Warnf("Invalid status response for partition %s from leader %s: %v",
+			partition, leader, err)
+		return 0, false
+	}
+	if !statusResp.Exists || !statusResp.IsLeader {
+		return 0, false
+	}
+	return statusResp.HighWatermark, true
+}
+
 // waitForPartitionLeader does a best-effort wait for the leader of the given
 // partition to create and start the partition.
 func (m *metadataAPI) waitForPartitionLeader(ctx context.Context, stream, leader string, partition int32) {
@@ -969,7 +2411,10 @@ func (m *metadataAPI) waitForPartitionLeader(ctx context.Context, stream, leader
 	}
 	inbox := m.getPartitionStatusInbox(leader)
 	for i := 0; i < 5; i++ {
-		resp, err := m.ncRaft.RequestWithContext(ctx, inbox, req)
+		// Bound each request since the leader may be unavailable.
+		reqCtx, cancel := context.WithTimeout(ctx, partitionStatusTimeout)
+		resp, err := m.ncRaft.RequestWithContext(reqCtx, inbox, req)
+		cancel()
 		if err != nil {
 			m.logger.Warnf(
 				"Failed to get status for partition [stream=%s, partition=%d] from leader %s: %v",
@@ -1006,6 +2451,17 @@ func (m *metadataAPI) applyRaftOperation(op *proto.RaftLog) raft.ApplyFuture {
 	return m.getRaft().Apply(data, raftApplyTimeout)
 }
 
+// raftApplyStatus returns the status for a failed Raft operation. If the
+// server was not metadata leader, the operation was not applied, so an
+// Unavailable status is returned to indicate it can be retried.
+func raftApplyStatus(msg string, err error) *status.Status {
+	code := codes.Internal
+	if err == raft.ErrNotLeader {
+		code = codes.Unavailable
+	}
+	return status.Newf(code, "%s: %v", msg, err)
+}
+
 // selectRandomReplica selects a random replica from the list of replicas.
 func selectRandomReplica(replicas []string) string {
 	return replicas[rand.Intn(len(replicas))]
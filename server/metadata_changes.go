@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// metadataChangeBufferSize is the number of changes buffered for each watcher
+// returned by WatchMetadataChanges. A watcher which falls further behind is
+// stopped with ErrMetadataChangesLagged rather than blocking the FSM.
+const metadataChangeBufferSize = 1024
+
+// ErrMetadataChangesLagged is returned on the error channel of
+// WatchMetadataChanges when the receiver did not keep up with the changes
+// being applied and so missed some of them.
+var ErrMetadataChangesLagged = errors.New("metadata change watcher fell behind")
+
+// MetadataChange is an operation applied to the metadata FSM, e.g. creating
+// or deleting a stream or changing a partition's leader.
+type MetadataChange struct {
+	// Index is the index of the operation in the metadata Raft log. It
+	// increases with each change.
+	Index uint64
+
+	// Op is the kind of operation.
+	Op proto.Op
+
+	// Log is the operation as replicated in the Raft log. It must not be
+	// modified.
+	Log *proto.RaftLog
+
+	// Err is set if the operation was rejected when applied, e.g. with
+	// ErrStreamNotFound or ErrFenced, in which case the metadata was not
+	// changed.
+	Err error
+
+	// Recovered indicates the operation was replayed from the Raft log when
+	// the server started rather than newly committed.
+	Recovered bool
+}
+
+// metadataChangeWatcher is a receiver of metadata changes.
+type metadataChangeWatcher struct {
+	changes chan *MetadataChange
+	errCh   chan error
+	done    chan struct{} // Closed when the watcher is removed
+}
+
+// metadataChangeFeed fans out the operations applied to the metadata FSM to
+// the watchers registered with WatchMetadataChanges, in apply order.
+type metadataChangeFeed struct {
+	mu       sync.Mutex
+	watchers map[*metadataChangeWatcher]struct{}
+}
+
+func newMetadataChangeFeed() *metadataChangeFeed {
+	return &metadataChangeFeed{watchers: make(map[*metadataChangeWatcher]struct{})}
+}
+
+// add registers a new watcher.
+func (f *metadataChangeFeed) add() *metadataChangeWatcher {
+	w := &metadataChangeWatcher{
+		changes: make(chan *MetadataChange, metadataChangeBufferSize),
+		errCh:   make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+	f.mu.Lock()
+	f.watchers[w] = struct{}{}
+	f.mu.Unlock()
+	return w
+}
+
+// remove unregisters the watcher, if it's still registered, sending err on
+// its error channel if it's not nil and closing its changes channel.
+func (f *metadataChangeFeed) remove(w *metadataChangeWatcher, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removeLocked(w, err)
+}
+
+func (f *metadataChangeFeed) removeLocked(w *metadataChangeWatcher, err error) {
+	if _, ok := f.watchers[w]; !ok {
+		return
+	}
+	delete(f.watchers, w)
+	if err != nil {
+		w.errCh <- err
+	}
+	close(w.changes)
+	close(w.done)
+}
+
+// publish sends the change to every watcher. Watchers whose buffer is full
+// are removed with ErrMetadataChangesLagged.
+func (f *metadataChangeFeed) publish(change *MetadataChange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for w := range f.watchers {
+		select {
+		case w.changes <- change:
+		default:
+			f.removeLocked(w, ErrMetadataChangesLagged)
+		}
+	}
+}
+
+// WatchMetadataChanges streams every operation applied to this server's
+// metadata FSM, e.g. stream creation and deletion, partition leader changes,
+// and ISR changes, in apply order with increasing Raft log indices. Since
+// every server applies the same operations in the same order, this can be
+// used to maintain an audit trail of metadata changes from any server. Only
+// operations applied after this is called are sent, including those replayed
+// from the Raft log if it's called before the server is started. The changes
+// channel is closed when the context is done, the server is shut down, or
+// the receiver falls too far behind, in which case ErrMetadataChangesLagged
+// is sent on the error channel first.
+func (s *Server) WatchMetadataChanges(ctx context.Context) (<-chan *MetadataChange, <-chan error) {
+	w := s.metadataChanges.add()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.shutdownCh:
+		case <-w.done:
+		}
+		s.metadataChanges.remove(w, nil)
+	}()
+	return w.changes, w.errCh
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// Ensure a watcher which falls behind is stopped with
+// ErrMetadataChangesLagged.
+func TestMetadataChangeFeedLagged(t *testing.T) {
+	feed := newMetadataChangeFeed()
+	w := feed.add()
+	for i := 0; i <= metadataChangeBufferSize; i++ {
+		feed.publish(&MetadataChange{Index: uint64(i + 1)})
+	}
+	require.Equal(t, ErrMetadataChangesLagged, <-w.errCh)
+	for i := 0; i < metadataChangeBufferSize; i++ {
+		change, ok := <-w.changes
+		require.True(t, ok)
+		require.Equal(t, uint64(i+1), change.Index)
+	}
+	_, ok := <-w.changes
+	require.False(t, ok)
+
+	// Removing a watcher twice is a no-op.
+	feed.remove(w, nil)
+	feed.publish(&MetadataChange{})
+}
+
+// Ensure WatchMetadataChanges emits metadata operations in apply order with
+// increasing indices.
+func TestWatchMetadataChanges(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, errCh := s1.WatchMetadataChanges(ctx)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = client.CreateStream(context.Background(), "foo", "foo")
+	require.NoError(t, err)
+	err = client.CreateStream(context.Background(), "bar", "bar")
+	require.NoError(t, err)
+	err = client.DeleteStream(context.Background(), "foo")
+	require.NoError(t, err)
+
+	var (
+		ops       []proto.Op
+		streams   []string
+		lastIndex uint64
+	)
+	for len(ops) == 0 || ops[len(ops)-1] != proto.Op_DELETE_STREAM {
+		select {
+		case change := <-changes:
+			require.Greater(t, change.Index, lastIndex)
+			require.NoError(t, change.Err)
+			require.False(t, change.Recovered)
+			lastIndex = change.Index
+			switch change.Op {
+			case proto.Op_CREATE_STREAM:
+				streams = append(streams, change.Log.CreateStreamOp.Partitions[0].Stream)
+			case proto.Op_DELETE_STREAM:
+				streams = append(streams, change.Log.DeleteStreamOp.Stream)
+			default:
+				continue
+			}
+			ops = append(ops, change.Op)
+		case err := <-errCh:
+			t.Fatalf("Unexpected error: %v", err)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Did not receive metadata changes, got %v", ops)
+		}
+	}
+	require.Equal(t, []proto.Op{
+		proto.Op_CREATE_STREAM,
+		proto.Op_CREATE_STREAM,
+		proto.Op_DELETE_STREAM,
+	}, ops)
+	require.Equal(t, []string{"foo", "bar", "foo"}, streams)
+
+	// The changes channel is closed once the context is canceled.
+	cancel()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Expected changes channel to be closed")
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	atomic_file "github.com/natefinch/atomic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// metadataBootstrapRetryInterval is how long the metadata leader waits before
+// retrying to recreate streams from the bootstrap file which failed, e.g.
+// because not enough servers have joined the cluster yet to place their
+// replicas. This is a var for testing purposes.
+var metadataBootstrapRetryInterval = time.Second
+
+// metadataExporter periodically exports the stream definitions in the
+// metadata store to the configured MetadataExportPath. It only runs on the
+// metadata leader so that a single server writes the export.
+type metadataExporter struct {
+	srv  *Server
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+func newMetadataExporter(s *Server) *metadataExporter {
+	return &metadataExporter{srv: s}
+}
+
+// Start begins exporting the stream definitions if MetadataExportPath is set.
+// They are exported right away and then every MetadataExportInterval.
+func (e *metadataExporter) Start() {
+	if e.srv.config.Clustering.MetadataExportPath == "" {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	e.stop = stop
+	e.srv.startGoroutine(func() { e.loop(stop) })
+}
+
+// Stop stops exporting the stream definitions.
+func (e *metadataExporter) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stop == nil {
+		return
+	}
+	close(e.stop)
+	e.stop = nil
+}
+
+func (e *metadataExporter) loop(stop <-chan struct{}) {
+	ticker := time.NewTicker(e.srv.config.Clustering.MetadataExportInterval)
+	defer ticker.Stop()
+	for {
+		if err := e.srv.exportMetadata(e.srv.config.Clustering.MetadataExportPath); err != nil {
+			e.srv.logger.Errorf("Failed to export metadata: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		case <-e.srv.shutdownCh:
+			return
+		}
+	}
+}
+
+// exportMetadata writes the definitions of all streams in the metadata store
+// to the given file, replacing it atomically. The file contains a
+// MetadataSnapshot with the definition of each stream's partitions, copied
+// under the partition lock since the partitions are updated concurrently.
+func (s *Server) exportMetadata(path string) error {
+	snap := &proto.MetadataSnapshot{}
+	for _, stream := range s.metadata.GetStreams() {
+		for _, partition := range stream.GetPartitions() {
+			partition.mu.RLock()
+			snap.Partitions = append(snap.Partitions, partitionDefinition(partition.Partition))
+			partition.mu.RUnlock()
+		}
+	}
+	data, err := snap.Marshal()
+	if err != nil {
+		return err
+	}
+	return atomic_file.WriteFile(path, bytes.NewReader(data))
+}
+
+// readMetadataExport reads the stream definitions exported to the given file
+// by exportMetadata and returns a CreateStreamOp for each stream. The
+// partitions' replica assignments are cleared so that they're placed on this
+// cluster's servers when the streams are created.
+func readMetadataExport(path string) ([]*proto.CreateStreamOp, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snap := &proto.MetadataSnapshot{}
+	if err := snap.Unmarshal(data); err != nil {
+		return nil, errors.Wrap(err, "invalid metadata export")
+	}
+	var (
+		ops      []*proto.CreateStreamOp
+		byStream = make(map[string]*proto.CreateStreamOp)
+	)
+	for _, partition := range snap.Partitions {
+		op, ok := byStream[partition.Stream]
+		if !ok {
+			op = &proto.CreateStreamOp{}
+			byStream[partition.Stream] = op
+			ops = append(ops, op)
+		}
+		op.Partitions = append(op.Partitions, partitionDefinition(partition))
+	}
+	return ops, nil
+}
+
+// partitionDefinition returns a copy of the given partition's stream
+// definition without its replica assignment, leadership, or other state.
+func partitionDefinition(partition *proto.Partition) *proto.Partition {
+	return &proto.Partition{
+		Subject:              partition.Subject,
+		Stream:               partition.Stream,
+		Id:                   partition.Id,
+		Group:                partition.Group,
+		ReplicationFactor:    partition.ReplicationFactor,
+		ReplicaMaxLagTime:    partition.ReplicaMaxLagTime,
+		ReplicaMaxLagOffsets: partition.ReplicaMaxLagOffsets,
+		ReplicaFetchTimeout:  partition.ReplicaFetchTimeout,
+		RequireKey:           partition.RequireKey,
+		EmptyValue:           partition.EmptyValue,
+		Schema:               partition.Schema,
+		IdleDeleteTime:       partition.IdleDeleteTime,
+		MaxCommitWaiters:     partition.MaxCommitWaiters,
+		RetentionMaxAge:      partition.RetentionMaxAge,
+		QuotaMaxBytes:        partition.QuotaMaxBytes,
+		RetentionMaxSegments: partition.RetentionMaxSegments,
+		CompressionThreshold: partition.CompressionThreshold,
+	}
+}
+
+// bootstrapMetadata recreates the streams defined in the MetadataBootstrapPath
+// file. Streams which fail to be created are retried until they're created,
+// the server loses metadata leadership, or it shuts down. Streams which
+// already exist are left as is.
+func (s *Server) bootstrapMetadata() {
+	path := s.config.Clustering.MetadataBootstrapPath
+	ops, err := readMetadataExport(path)
+	if err != nil {
+		s.logger.Errorf("Failed to bootstrap metadata from %s: %v", path, err)
+		return
+	}
+	s.logger.Infof("Bootstrapping metadata from %s", path)
+	for len(ops) > 0 {
+		if !s.IsLeader() {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		results, st := s.metadata.CreateStreams(ctx, &proto.CreateStreamsOp{Streams: ops})
+		cancel()
+		if st != nil {
+			// None of the streams were created, so retry all of them.
+			s.logger.Errorf("Failed to bootstrap streams: %v", st.Err())
+		} else {
+			var failed []*proto.CreateStreamOp
+			for i, result := range results {
+				if result != nil && result.Code() != codes.AlreadyExists {
+					s.logger.Errorf("Failed to bootstrap stream %s: %v",
+						ops[i].Partitions[0].Stream, result.Err())
+					failed = append(failed, ops[i])
+				}
+			}
+			ops = failed
+		}
+		if len(ops) == 0 {
+			break
+		}
+		select {
+		case <-time.After(metadataBootstrapRetryInterval):
+		case <-s.shutdownCh:
+			return
+		}
+	}
+	s.logger.Infof("Finished bootstrapping metadata from %s", path)
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+	grpcMetadata "google.golang.org/grpc/metadata"
+)
+
+// Ensure the metadata leader exports the stream definitions and a fresh
+// cluster bootstrapped from the export recreates them without their data.
+func TestMetadataExportBootstrap(t *testing.T) {
+	defer cleanupStorage(t)
+
+	dir, err := ioutil.TempDir("", "liftbridge-metadata-export")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metadata")
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server to export metadata.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Clustering.MetadataExportPath = path
+	s1Config.Clustering.MetadataExportInterval = 10 * time.Millisecond
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = client.CreateStream(context.Background(), "foo", "foo", lift.Partitions(3))
+	require.NoError(t, err)
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		RequireKeyMetadataKey, "true",
+		ReplicaMaxLagTimeMetadataKey, "30s",
+	)
+	err = client.CreateStream(ctx, "bar", "bar", lift.Group("group"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err = client.Publish(ctx, "foo", []byte("hello"), lift.AckPolicyAll())
+	cancel()
+	require.NoError(t, err)
+
+	// Wait for both streams to be exported.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ops, err := readMetadataExport(path)
+		if err == nil && len(ops) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected streams to be exported, got %v, %v", ops, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client.Close()
+	s1.Stop()
+	cleanupStorage(t)
+
+	// Start a fresh cluster bootstrapped from the export.
+	s2Config := getTestConfig("b", true, 5051)
+	s2Config.Clustering.MetadataBootstrapPath = path
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s2)
+
+	deadline = time.Now().Add(10 * time.Second)
+	for len(s2.metadata.GetStreams()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected streams to be recreated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	foo := s2.metadata.GetStream("foo")
+	require.NotNil(t, foo)
+	require.Equal(t, "foo", foo.GetSubject())
+	require.Len(t, foo.GetPartitions(), 3)
+	for _, partition := range foo.GetPartitions() {
+		require.Equal(t, []string{"b"}, partition.Replicas)
+		require.Equal(t, "b", partition.Leader)
+		require.Equal(t, int64(-1), partition.log.NewestOffset())
+	}
+
+	bar := s2.metadata.GetStream("bar")
+	require.NotNil(t, bar)
+	partition := bar.GetPartition(0)
+	require.NotNil(t, partition)
+	require.Equal(t, "group", partition.Group)
+	require.True(t, partition.RequireKey)
+	require.Equal(t, int64(30*time.Second), partition.ReplicaMaxLagTime)
+}
@@ -4,14 +4,14 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
-	"path/filepath"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/Workiva/go-datastructures/queue"
 	"github.com/nats-io/nats.go"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
 
 	client "github.com/liftbridge-io/liftbridge-api/go"
 	"github.com/liftbridge-io/liftbridge/server/commitlog"
@@ -22,10 +22,28 @@ import (
 // message processing loop.
 const recvChannelSize = 64 * 1024
 
+// maxCommitBacklog is the number of messages waiting to be committed above
+// which a partition leader is considered busy. This is a var for testing
+// purposes.
+var maxCommitBacklog int64 = recvChannelSize
+
 // timestamp returns the current time in Unix nanoseconds. This function exists
 // for mocking purposes.
 var timestamp = func() int64 { return time.Now().UnixNano() }
 
+// ErrNotPartitionLeader is returned when an operation which must be performed
+// by the partition leader is attempted on another server.
+var ErrNotPartitionLeader = errors.New("server is not partition leader")
+
+// importBatch is a batch of messages to append to the leader's log by the
+// message processing loop, bypassing NATS ingest.
+type importBatch struct {
+	msgs    []*commitlog.Message
+	offsets []int64
+	err     error
+	done    chan struct{}
+}
+
 // replica tracks the latest log offset for a particular partition replica.
 type replica struct {
 	mu     sync.RWMutex
@@ -67,25 +85,40 @@ type partition struct {
 	sub             *nats.Subscription // Subscription to partition NATS subject
 	leaderReplSub   *nats.Subscription // Subscription for replication requests from followers
 	leaderOffsetSub *nats.Subscription // Subscription for leader epoch offset requests from followers
+	followerSyncSub *nats.Subscription // Subscription for requests from followers using this server as sync source
 	recvChan        chan *nats.Msg     // Channel leader places received messages on
+	importChan      chan *importBatch  // Channel leader places imported messages on
 	log             commitlog.CommitLog
 	srv             *Server
 	isLeading       bool
 	isFollowing     bool
 	isClosed        bool
 	replicas        map[string]struct{}
+	observers       map[string]struct{} // Replicas which never join the ISR or lead
 	isr             map[string]*replica
+	isrMu           sync.RWMutex // Guards isr for replicators, which can't take mu
 	replicators     map[string]*replicator
-	commitQueue     *queue.Queue
+	commitQueue     *commitQueue
 	commitCheck     chan struct{}
 	recovered       bool
 	stopFollower    chan struct{}
 	stopLeader      chan struct{}
 	notify          chan struct{}
+	truncateCheck   chan struct{} // Signals the follower to truncate to the leader
 	belowMinISR     bool
-	pause           bool // Pause replication on the leader (for unit testing)
+	replPaused      bool // Replication paused for maintenance
 	shutdown        sync.WaitGroup
 	paused          bool
+	ingestDropped   int64 // Messages NATS dropped on previous ingest subscriptions
+	ingestReported  int64 // Dropped messages already reported
+	changeMu        sync.Mutex
+	changed         chan struct{} // Closed when the leader changes or the log is truncated
+	truncations     int64         // Number of times the log was truncated
+	active          int32         // Set when messages are appended, cleared when reported
+	subscribers     int32         // Number of open subscriptions on the partition
+	electing        int32         // Set when reporting the leader as failed, cleared when a leader is seen
+	consumersMu     sync.Mutex
+	consumers       map[string]*consumer // Durable consumers loaded from disk
 }
 
 // newPartition creates a new stream partition. If the partition is recovered,
@@ -96,56 +129,153 @@ type partition struct {
 // A partitioned stream maps to separate NATS subjects: subject, subject.1,
 // subject.2, etc.
 func (s *Server) newPartition(protoPartition *proto.Partition, recovered bool) (*partition, error) {
+	log, err := s.openCommitLog(protoPartition)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make(map[string]struct{}, len(protoPartition.Replicas))
+	for _, replica := range protoPartition.Replicas {
+		replicas[replica] = struct{}{}
+	}
+
+	observers := make(map[string]struct{}, len(protoPartition.Observers))
+	for _, observer := range protoPartition.Observers {
+		observers[observer] = struct{}{}
+	}
+
+	isr := make(map[string]*replica, len(protoPartition.Isr))
+	for _, rep := range protoPartition.Isr {
+		offset := int64(-1)
+		// For this server, initialize the replica offset to the newest offset.
+		if rep == s.config.Clustering.ServerID {
+			offset = log.NewestOffset()
+		}
+		isr[rep] = &replica{offset: offset}
+	}
+
+	st := &partition{
+		Partition:     protoPartition,
+		log:           log,
+		srv:           s,
+		replicas:      replicas,
+		observers:     observers,
+		isr:           isr,
+		commitCheck:   make(chan struct{}, len(protoPartition.Replicas)),
+		notify:        make(chan struct{}, 1),
+		truncateCheck: make(chan struct{}, 1),
+		recovered:     recovered,
+		changed:       make(chan struct{}),
+	}
+
+	return st, nil
+}
+
+// openCommitLog initializes or recovers the durable commit log backing the
+// given partition.
+func (s *Server) openCommitLog(protoPartition *proto.Partition) (commitlog.CommitLog, error) {
+	if err := s.migrateStreamDataDir(protoPartition.Stream); err != nil {
+		return nil, err
+	}
+	var (
+		maxLogAge     = s.config.Streams.RetentionMaxAge
+		maxSegmentAge = s.config.Streams.SegmentMaxAge
+	)
+	if age := protoPartition.RetentionMaxAge; age > 0 {
+		maxLogAge = time.Duration(age)
+		// Segments are only deleted once they're rolled, so roll them at
+		// least as often as the stream's retention age, but no more than
+		// once a second.
+		if maxSegmentAge == 0 || maxSegmentAge > maxLogAge {
+			maxSegmentAge = maxLogAge
+			if maxSegmentAge < time.Second {
+				maxSegmentAge = time.Second
+			}
+		}
+	}
+	quotaMaxBytes := s.config.Streams.QuotaMaxBytes
+	if quota := protoPartition.QuotaMaxBytes; quota > 0 {
+		quotaMaxBytes = quota
+	}
+	maxSegments := s.config.Streams.RetentionMaxSegments
+	if segments := protoPartition.RetentionMaxSegments; segments > 0 {
+		maxSegments = int(segments)
+	}
 	var (
-		file = filepath.Join(s.config.DataDir, "streams", protoPartition.Stream,
-			strconv.FormatInt(int64(protoPartition.Id), 10))
+		file = s.partitionDataDir(protoPartition.Stream, protoPartition.Id)
 		name = fmt.Sprintf("[subject=%s, stream=%s, partition=%d]",
 			protoPartition.Subject, protoPartition.Stream, protoPartition.Id)
 		log, err = commitlog.New(commitlog.Options{
 			Name:                 name,
 			Path:                 file,
 			MaxSegmentBytes:      s.config.Streams.SegmentMaxBytes,
-			MaxSegmentAge:        s.config.Streams.SegmentMaxAge,
+			MaxSegmentAge:        maxSegmentAge,
 			MaxLogBytes:          s.config.Streams.RetentionMaxBytes,
 			MaxLogMessages:       s.config.Streams.RetentionMaxMessages,
-			MaxLogAge:            s.config.Streams.RetentionMaxAge,
+			MaxLogAge:            maxLogAge,
+			MaxSegments:          maxSegments,
+			QuotaMaxBytes:        quotaMaxBytes,
+			QuotaPolicy:          s.config.Streams.QuotaPolicy,
 			CleanerInterval:      s.config.Streams.CleanerInterval,
 			Compact:              s.config.Streams.Compact,
 			CompactMaxGoroutines: s.config.Streams.CompactMaxGoroutines,
+			CompactWindowStart:   s.config.Streams.CompactWindowStart,
+			CompactWindowEnd:     s.config.Streams.CompactWindowEnd,
+			CompactMinDirtyRatio: s.config.Streams.CompactMinDirtyRatio,
+			SyncWrites:           s.config.Streams.SyncWrites,
+			WriteBufferSize:      s.config.Streams.WriteBufferSize,
+			MaxReaderDelay:       s.config.Streams.RetentionReaderMaxDelay,
+			IndexAccess:          s.config.Streams.IndexAccess,
+			IndexInterval:        s.config.Streams.IndexInterval,
+			OnSegmentRoll:        s.segmentRollHook(protoPartition),
 			Logger:               s.logger,
 		})
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create commit log")
 	}
+	// Apply any trim made while the log was closed.
+	if offset := protoPartition.TrimOffset; offset > 0 && s.isPartitionReplica(protoPartition) {
+		if err := log.TrimBefore(offset); err != nil {
+			log.Close()
+			return nil, errors.Wrap(err, "failed to trim commit log")
+		}
+	}
+	return log, nil
+}
 
-	replicas := make(map[string]struct{}, len(protoPartition.Replicas))
-	for _, replica := range protoPartition.Replicas {
-		replicas[replica] = struct{}{}
+// segmentRollHook returns the commit log OnSegmentRoll hook which passes the
+// partition's rolled segments to the configured OnSegmentRoll hook, or nil if
+// there is none.
+func (s *Server) segmentRollHook(protoPartition *proto.Partition) func(commitlog.RolledSegment) {
+	onRoll := s.config.Streams.OnSegmentRoll
+	if onRoll == nil {
+		return nil
 	}
+	var (
+		stream = protoPartition.Stream
+		id     = protoPartition.Id
+	)
+	return func(rolled commitlog.RolledSegment) {
+		onRoll(SegmentRoll{Stream: stream, Partition: id, RolledSegment: rolled})
+	}
+}
 
-	isr := make(map[string]*replica, len(protoPartition.Isr))
-	for _, rep := range protoPartition.Isr {
-		offset := int64(-1)
-		// For this server, initialize the replica offset to the newest offset.
-		if rep == s.config.Clustering.ServerID {
-			offset = log.NewestOffset()
+// isPartitionReplica indicates if this server is a replica or an observer of
+// the given partition.
+func (s *Server) isPartitionReplica(protoPartition *proto.Partition) bool {
+	id := s.config.Clustering.ServerID
+	for _, replica := range protoPartition.Replicas {
+		if replica == id {
+			return true
 		}
-		isr[rep] = &replica{offset: offset}
 	}
-
-	st := &partition{
-		Partition:   protoPartition,
-		log:         log,
-		srv:         s,
-		replicas:    replicas,
-		isr:         isr,
-		commitCheck: make(chan struct{}, len(protoPartition.Replicas)),
-		notify:      make(chan struct{}, 1),
-		recovered:   recovered,
+	for _, observer := range protoPartition.Observers {
+		if observer == id {
+			return true
+		}
 	}
-
-	return st, nil
+	return false
 }
 
 // String returns a human-readable string representation of the partition.
@@ -153,6 +283,93 @@ func (p *partition) String() string {
 	return fmt.Sprintf("[subject=%s, stream=%s, partition=%d]", p.Subject, p.Stream, p.Id)
 }
 
+// quotaMaxBytes returns the hard limit on the size of the partition's log in
+// bytes, or zero if there is none. This is the stream's setting if it has one,
+// otherwise the server's.
+func (p *partition) quotaMaxBytes() int64 {
+	if quota := p.GetQuotaMaxBytes(); quota > 0 {
+		return quota
+	}
+	return p.srv.config.Streams.QuotaMaxBytes
+}
+
+// retentionMaxSegments returns the max number of log segments the partition
+// keeps. This is the stream's setting if it has one, otherwise the server's.
+func (p *partition) retentionMaxSegments() int {
+	if segments := p.GetRetentionMaxSegments(); segments > 0 {
+		return int(segments)
+	}
+	return p.srv.config.Streams.RetentionMaxSegments
+}
+
+// replicaMaxLagTime returns how long a follower can go without sending a
+// replication request or catching up before it's removed from the ISR. This
+// is the stream's setting if it has one, otherwise the server's.
+func (p *partition) replicaMaxLagTime() time.Duration {
+	if lag := p.GetReplicaMaxLagTime(); lag > 0 {
+		return time.Duration(lag)
+	}
+	return p.srv.config.Clustering.ReplicaMaxLagTime
+}
+
+// replicaMaxLagOffsets returns how many offsets a follower can fall behind
+// the leader's log end offset before it's removed from the ISR, or zero if
+// there is no limit. This is the stream's setting if it has one, otherwise the
+// server's.
+func (p *partition) replicaMaxLagOffsets() int64 {
+	if lag := p.GetReplicaMaxLagOffsets(); lag > 0 {
+		return lag
+	}
+	return p.srv.config.Clustering.ReplicaMaxLagOffsets
+}
+
+// replicaFetchTimeout returns how long a follower waits for a response to a
+// replication request. This is the stream's setting if it has one, otherwise
+// the server's.
+func (p *partition) replicaFetchTimeout() time.Duration {
+	if timeout := p.GetReplicaFetchTimeout(); timeout > 0 {
+		return time.Duration(timeout)
+	}
+	return p.srv.config.Clustering.ReplicaFetchTimeout
+}
+
+// retentionMaxAge returns how long messages are retained in the partition's
+// log before the segments containing them are deleted. This is the stream's
+// setting if it has one, otherwise the server's. Zero means no limit.
+func (p *partition) retentionMaxAge() time.Duration {
+	if age := p.GetRetentionMaxAge(); age > 0 {
+		return time.Duration(age)
+	}
+	return p.srv.config.Streams.RetentionMaxAge
+}
+
+// idleDeleteTime returns how long the partition's stream can go without
+// publishes or subscribers before it's deleted. This is the stream's setting
+// if it has one, otherwise the server's. Zero means it's never deleted.
+func (p *partition) idleDeleteTime() time.Duration {
+	if idle := p.GetIdleDeleteTime(); idle > 0 {
+		return time.Duration(idle)
+	}
+	return p.srv.config.Streams.IdleDeleteTime
+}
+
+// maxCommitWaiters returns the max number of publishes a server can have
+// waiting on commit for the partition's stream. This is the stream's setting
+// if it has one, otherwise the server's. Zero means no limit.
+func (p *partition) maxCommitWaiters() int {
+	if max := p.GetMaxCommitWaiters(); max > 0 {
+		return int(max)
+	}
+	return p.srv.config.Streams.MaxCommitWaiters
+}
+
+// takeActivity indicates if messages were appended to the partition since the
+// last call or if it has open subscriptions.
+func (p *partition) takeActivity() bool {
+	appended := atomic.SwapInt32(&p.active, 0) == 1
+	return appended || atomic.LoadInt32(&p.subscribers) > 0
+}
+
 func (p *partition) close() error {
 	if p.isClosed {
 		return nil
@@ -196,6 +413,25 @@ func (p *partition) IsPaused() bool {
 	return p.paused
 }
 
+// OpenPaused reopens the commit log of a paused partition so subscribers can
+// read the messages committed before it was paused. The partition still
+// neither leads nor follows until it's resumed. This is a no-op if the
+// partition is not paused or its log is already open.
+func (p *partition) OpenPaused() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused || !p.isClosed {
+		return nil
+	}
+	log, err := p.srv.openCommitLog(p.Partition)
+	if err != nil {
+		return err
+	}
+	p.log = log
+	p.isClosed = false
+	return nil
+}
+
 // Delete stops the partition if it is running, closes, and deletes the commit
 // log.
 func (p *partition) Delete() error {
@@ -209,6 +445,45 @@ func (p *partition) Delete() error {
 	return p.stopLeadingOrFollowing()
 }
 
+// TrimBefore deletes all messages before the given offset from the commit log
+// if this server is a replica of the partition. The offset is recorded in the
+// partition so that, if the partition is closed, e.g. because it's paused, the
+// trim is applied when its commit log is opened again.
+func (p *partition) TrimBefore(offset int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if offset > p.TrimOffset {
+		p.TrimOffset = offset
+	}
+	if p.isClosed {
+		return nil
+	}
+	if id := p.srv.config.Clustering.ServerID; !p.inReplicas(id) && !p.inObservers(id) {
+		return nil
+	}
+	oldest := p.log.OldestOffset()
+	if err := p.log.TrimBefore(offset); err != nil {
+		return err
+	}
+	if p.log.OldestOffset() != oldest {
+		p.notifyChanged(true)
+	}
+	return nil
+}
+
+// ReplicaHighWatermark returns the partition's high watermark and true if this
+// server is a replica of the partition. Otherwise, false is returned since
+// the HW is unknown.
+func (p *partition) ReplicaHighWatermark() (int64, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if _, ok := p.replicas[p.srv.config.Clustering.ServerID]; !ok {
+		return 0, false
+	}
+	return p.log.HighWatermark(), true
+}
+
 // Notify is used to short circuit the sleep backoff a partition uses when it
 // has replicated to the end of the leader's log (i.e. the log end offset).
 // When a follower reaches the end of the log, it starts to sleep in between
@@ -228,6 +503,26 @@ func (p *partition) Notify() {
 	}
 }
 
+// NotifyTruncate signals the follower to truncate its log to match the leader's
+// before sending further replication requests. This is done when the leader
+// reports the follower is ahead of it.
+func (p *partition) NotifyTruncate() {
+	if p.IsLeader() {
+		// If we are now the leader, do nothing.
+		return
+	}
+	p.requestTruncate()
+}
+
+// requestTruncate schedules truncation to the leader's log in the replication
+// request loop.
+func (p *partition) requestTruncate() {
+	select {
+	case p.truncateCheck <- struct{}{}:
+	default:
+	}
+}
+
 // SetLeader sets the leader for the partition to the given replica and leader
 // epoch. If the partition's current leader epoch is greater than the given
 // epoch, this returns an error. This will also start the partition as a leader
@@ -240,8 +535,12 @@ func (p *partition) SetLeader(leader string, epoch uint64) error {
 		return fmt.Errorf("proposed leader epoch %d is less than current epoch %d",
 			epoch, p.LeaderEpoch)
 	}
+	// Release publishes waiting on an election once this server has started
+	// leading or following under the new leader.
+	defer p.setElectingLeader(false)
 	p.Leader = leader
 	p.LeaderEpoch = epoch
+	p.notifyChanged(false)
 
 	if p.recovered {
 		// If this partition is being recovered, we will start the
@@ -281,7 +580,7 @@ func (p *partition) startLeadingOrFollowing() error {
 			p.srv.logger.Errorf("Server failed becoming leader for partition %s: %v", p, err)
 			return err
 		}
-	} else if p.inReplicas(p.srv.config.Clustering.ServerID) {
+	} else if id := p.srv.config.Clustering.ServerID; p.inReplicas(id) || p.inObservers(id) {
 		p.srv.logger.Debugf("Server becoming follower for partition %s, epoch: %d", p, p.LeaderEpoch)
 		if err := p.becomeFollower(); err != nil {
 			p.srv.logger.Errorf("Server failed becoming follower for partition %s: %v", p, err)
@@ -341,9 +640,10 @@ func (p *partition) becomeLeader(epoch uint64) error {
 
 	// Start message processing loop.
 	p.recvChan = make(chan *nats.Msg, recvChannelSize)
+	p.importChan = make(chan *importBatch)
 	p.stopLeader = make(chan struct{})
 	p.srv.startGoroutine(func() {
-		p.messageProcessingLoop(p.recvChan, p.stopLeader, epoch)
+		p.messageProcessingLoop(p.recvChan, p.importChan, p.stopLeader, epoch)
 		p.shutdown.Done()
 	})
 
@@ -352,18 +652,13 @@ func (p *partition) becomeLeader(epoch uint64) error {
 
 	// Subscribe to the NATS subject and begin sequencing messages.
 	// TODO: This should be drained on shutdown.
-	sub, err := p.srv.nc.QueueSubscribe(p.getSubject(), p.Group, func(m *nats.Msg) {
-		p.recvChan <- m
-	})
-	if err != nil {
-		return errors.Wrap(err, "failed to subscribe to NATS")
+	if err := p.subscribeIngest(); err != nil {
+		return err
 	}
-	sub.SetPendingLimits(-1, -1)
-	p.sub = sub
 	p.srv.nc.Flush()
 
 	// Subscribe to the partition replication subject.
-	sub, err = p.srv.ncRepl.Subscribe(p.getReplicationRequestInbox(), p.handleReplicationRequest)
+	sub, err := p.srv.ncRepl.Subscribe(p.getReplicationRequestInbox(), p.handleReplicationRequest)
 	if err != nil {
 		return errors.Wrap(err, "failed to subscribe to replication inbox")
 	}
@@ -389,8 +684,8 @@ func (p *partition) becomeLeader(epoch uint64) error {
 // from the NATS subject and replication subject, stopping message processing
 // and replication, and disposing the commit queue.
 func (p *partition) stopLeading() error {
-	// Unsubscribe from NATS subject.
-	if err := p.sub.Unsubscribe(); err != nil {
+	// Unsubscribe from NATS subject unless ingest is paused.
+	if err := p.unsubscribeIngest(); err != nil {
 		return err
 	}
 
@@ -407,9 +702,8 @@ func (p *partition) stopLeading() error {
 	// Stop processing messages and replicating.
 	p.shutdown.Add(1) // Message processing loop
 	p.shutdown.Add(1) // Commit loop
-	if replicas := len(p.replicas); replicas > 1 {
-		p.shutdown.Add(replicas - 1) // Replicator loops (minus one to exclude self)
-	}
+	// Replicator loops, including those for removed replicas.
+	p.shutdown.Add(len(p.replicators))
 	close(p.stopLeader)
 
 	// Wait for loops to shutdown.
@@ -418,10 +712,145 @@ func (p *partition) stopLeading() error {
 	p.commitQueue.Dispose()
 	p.isLeading = false
 	p.recvChan = nil // Nil this out since it's a non-trivial amount of memory
+	p.importChan = nil
+
+	return nil
+}
+
+// subscribeIngest subscribes to the partition's NATS subject, placing received
+// messages on the receive channel to be sequenced. This must be called with
+// the partition lock held.
+func (p *partition) subscribeIngest() error {
+	sub, err := p.srv.nc.QueueSubscribe(p.getSubject(), p.Group, func(m *nats.Msg) {
+		p.recvChan <- m
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to subscribe to NATS")
+	}
+	var (
+		maxMsgs  = p.srv.config.Streams.IngestMaxPendingMessages
+		maxBytes = p.srv.config.Streams.IngestMaxPendingBytes
+	)
+	if maxMsgs <= 0 {
+		maxMsgs = -1
+	}
+	if maxBytes <= 0 {
+		maxBytes = -1
+	}
+	sub.SetPendingLimits(maxMsgs, maxBytes)
+	p.sub = sub
+	return nil
+}
 
+// unsubscribeIngest unsubscribes from the partition's NATS subject, if
+// subscribed, keeping count of the messages NATS dropped on the subscription.
+// This must be called with the partition lock held.
+func (p *partition) unsubscribeIngest() error {
+	if p.sub == nil {
+		return nil
+	}
+	p.ingestDropped += subscriptionDropped(p.sub)
+	if err := p.sub.Unsubscribe(); err != nil {
+		return err
+	}
+	p.sub = nil
 	return nil
 }
 
+// IngestDropped returns the number of messages NATS has dropped on the
+// partition's subscription, while this server was leader, because the leader
+// was a slow consumer.
+func (p *partition) IngestDropped() int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.ingestDroppedLocked()
+}
+
+// ingestDroppedLocked returns the number of messages dropped on the
+// partition's subscription. This must be called with the partition lock held.
+func (p *partition) ingestDroppedLocked() int64 {
+	dropped := p.ingestDropped
+	if p.sub != nil {
+		dropped += subscriptionDropped(p.sub)
+	}
+	return dropped
+}
+
+// handleSlowConsumer is called when NATS reports the given subscription is a
+// slow consumer. If it's the partition's subscription, the dropped messages
+// are reported and, if configured, ingest is paused to let the leader catch
+// up. It returns false if the subscription does not belong to the partition.
+func (p *partition) handleSlowConsumer(sub *nats.Subscription) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sub == nil || p.sub != sub {
+		return false
+	}
+
+	total := p.ingestDroppedLocked()
+	dropped := total - p.ingestReported
+	p.ingestReported = total
+	if dropped > 0 {
+		p.srv.logger.Warnf("NATS dropped %d messages for partition %s because the leader is a slow consumer, "+
+			"%d dropped in total", dropped, p, total)
+		if onDrop := p.srv.config.Streams.OnIngestDrop; onDrop != nil {
+			drop := IngestDrop{
+				Stream:    p.Stream,
+				Partition: p.Id,
+				Dropped:   dropped,
+				Total:     total,
+			}
+			go onDrop(drop)
+		}
+	}
+
+	pause := p.srv.config.Streams.IngestDropPause
+	if pause <= 0 || !p.isLeading {
+		return true
+	}
+	if err := p.unsubscribeIngest(); err != nil {
+		p.srv.logger.Errorf("Failed to pause ingest for partition %s: %v", p, err)
+		return true
+	}
+	p.srv.logger.Warnf("Pausing ingest for partition %s for %s", p, pause)
+	stop := p.stopLeader
+	p.srv.startGoroutine(func() {
+		select {
+		case <-time.After(pause):
+		case <-stop:
+			return
+		}
+		p.resumeIngest(stop)
+	})
+	return true
+}
+
+// resumeIngest resubscribes to the partition's NATS subject after ingest was
+// paused, provided the partition is still leading in the same term, which is
+// identified by the given stop channel.
+func (p *partition) resumeIngest(stop chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.isLeading || p.stopLeader != stop || p.sub != nil {
+		return
+	}
+	if err := p.subscribeIngest(); err != nil {
+		p.srv.logger.Errorf("Failed to resume ingest for partition %s: %v", p, err)
+		return
+	}
+	p.srv.logger.Infof("Resumed ingest for partition %s", p)
+}
+
+// subscriptionDropped returns the number of messages NATS dropped on the given
+// subscription.
+func subscriptionDropped(sub *nats.Subscription) int64 {
+	dropped, err := sub.Dropped()
+	if err != nil {
+		return 0
+	}
+	return int64(dropped)
+}
+
 // becomeFollower is called when the server has become a follower for this
 // partition.
 func (p *partition) becomeFollower() error {
@@ -434,12 +863,17 @@ func (p *partition) becomeFollower() error {
 		return errors.Wrap(err, "failed to truncate log")
 	}
 
-	// Start fetching messages from the leader's log starting at the HW.
-	p.stopFollower = make(chan struct{})
-	p.srv.logger.Debugf("Replicating partition %s from leader %s", p, p.Leader)
-	p.srv.startGoroutine(func() {
-		p.replicationRequestLoop(p.Leader, p.LeaderEpoch, p.stopFollower)
-	})
+	// Start fetching messages from the leader's log starting at the HW unless
+	// replication is paused, in which case it starts once resumed.
+	if !p.replPaused {
+		p.startReplicationRequestLoop()
+	}
+
+	// Serve followers which use this server as their sync source. They fetch
+	// from the leader if this fails, so it's not fatal.
+	if err := p.subscribeSyncRequests(); err != nil {
+		p.srv.logger.Errorf("Failed to serve sync requests for partition %s: %v", p, err)
+	}
 
 	p.isFollowing = true
 	p.isLeading = false
@@ -447,12 +881,39 @@ func (p *partition) becomeFollower() error {
 	return nil
 }
 
+// startReplicationRequestLoop starts fetching messages from the leader. This
+// must be called with the partition lock held.
+func (p *partition) startReplicationRequestLoop() {
+	var (
+		leader = p.Leader
+		epoch  = p.LeaderEpoch
+		stop   = make(chan struct{})
+	)
+	p.stopFollower = stop
+	p.srv.logger.Debugf("Replicating partition %s from leader %s", p, leader)
+	p.srv.startGoroutine(func() {
+		p.replicationRequestLoop(leader, epoch, stop)
+	})
+}
+
+// stopReplicationRequestLoop stops fetching messages from the leader if
+// running. This must be called with the partition lock held.
+func (p *partition) stopReplicationRequestLoop() {
+	// TODO: Do graceful shutdown similar to stopLeading().
+	if p.stopFollower != nil {
+		close(p.stopFollower)
+		p.stopFollower = nil
+	}
+}
+
 // stopFollowing causes the partition to step down as a follower by stopping
 // replication requests and the leader failure detector.
 func (p *partition) stopFollowing() error {
 	// Stop replication request and leader failure detector loop.
-	// TODO: Do graceful shutdown similar to stopLeading().
-	close(p.stopFollower)
+	p.stopReplicationRequestLoop()
+	if err := p.unsubscribeSyncRequests(); err != nil {
+		return err
+	}
 	p.isFollowing = false
 	return nil
 }
@@ -493,7 +954,7 @@ func (p *partition) handleReplicationRequest(msg *nats.Msg) {
 	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if p.pause {
+	if p.replPaused {
 		return
 	}
 	if req.LeaderEpoch != 0 && req.LeaderEpoch != p.LeaderEpoch {
@@ -506,7 +967,7 @@ func (p *partition) handleReplicationRequest(msg *nats.Msg) {
 			p, req.ReplicaID, req.LeaderEpoch, p.LeaderEpoch)
 		return
 	}
-	if _, ok := p.replicas[req.ReplicaID]; !ok {
+	if !p.inReplicas(req.ReplicaID) && !p.inObservers(req.ReplicaID) {
 		p.srv.logger.Warnf("Received replication request for partition %s from non-replica %s",
 			p, req.ReplicaID)
 		return
@@ -522,7 +983,7 @@ func (p *partition) handleReplicationRequest(msg *nats.Msg) {
 // receives a replication response from the leader. This response will contain
 // the leader epoch, leader HW, and (optionally) messages to replicate.
 func (p *partition) handleReplicationResponse(msg *nats.Msg) int {
-	leaderEpoch, hw, data, err := proto.UnmarshalReplicationResponse(msg.Data)
+	leaderEpoch, hw, data, err := p.srv.replicationCodec().DecodeReplicationResponse(msg.Data)
 	if err != nil {
 		p.srv.logger.Warnf("Invalid replication response for partition %s: %s", p, err)
 		return 0
@@ -579,70 +1040,143 @@ func (p *partition) getLeaderOffsetRequestInbox() string {
 
 // messageProcessingLoop is a long-running loop that processes messages
 // received on the given channel until the stop channel is closed. This will
-// attempt to batch messages up before writing them to the commit log. Once
+// attempt to batch messages up before writing them to the commit log, waiting
+// at most BatchMaxTime for the batch to fill. Once
 // written to the write-ahead log, a marker is written to the commit queue to
 // indicate it's pending commit. Once the ISR has replicated the message, the
 // leader commits it by removing it from the queue and sending an
-// acknowledgement to the client.
-func (p *partition) messageProcessingLoop(recvChan <-chan *nats.Msg, stop <-chan struct{},
-	leaderEpoch uint64) {
+// acknowledgement to the client. Imported messages received on importChan are
+// appended as a separate batch.
+func (p *partition) messageProcessingLoop(recvChan <-chan *nats.Msg,
+	importChan <-chan *importBatch, stop <-chan struct{}, leaderEpoch uint64) {
 
 	var (
 		msg       *nats.Msg
 		batchSize = p.srv.config.BatchMaxMessages
 		batchWait = p.srv.config.BatchMaxTime
 		msgBatch  = make([]*commitlog.Message, 0, batchSize)
+		// Timestamps are never assigned earlier than the last message's, even
+		// if the clock jumps backward, so timestamp lookups and time-based
+		// retention stay correct.
+		lastTimestamp = p.log.NewestTimestamp()
 	)
 	for {
 		msgBatch = msgBatch[:0]
 		select {
 		case <-stop:
 			return
+		case req := <-importChan:
+			if !p.appendImported(req, leaderEpoch, &lastTimestamp) {
+				return
+			}
+			continue
 		case msg = <-recvChan:
 		}
+		// Append latency is measured from when the batch's first message is
+		// received, so it includes batching, writing, and syncing the batch.
+		received := time.Now()
 
-		m := natsToProtoMessage(msg, leaderEpoch)
-		msgBatch = append(msgBatch, m)
+		if m := natsToProtoMessage(msg, p.Stream, leaderEpoch); m != nil {
+			msgBatch = append(msgBatch, m)
+		}
 		remaining := batchSize - 1
 
 		// Fill the batch up to the max batch size or until the channel is
-		// empty.
+		// empty. If a max batch time is configured, keep waiting for more
+		// messages until it has elapsed since the first message in the batch
+		// was received. This allows concurrent publishes to share a single
+		// write and, if enabled, a single fsync.
+		var (
+			timer    *time.Timer
+			deadline <-chan time.Time
+		)
+		if batchWait > 0 {
+			timer = time.NewTimer(batchWait)
+			deadline = timer.C
+		}
+	fill:
 		for remaining > 0 {
-			chanLen := len(recvChan)
-			if chanLen == 0 {
-				if batchWait > 0 {
-					time.Sleep(batchWait)
-					chanLen = len(recvChan)
-					if chanLen == 0 {
-						break
-					}
-				} else {
-					break
+			select {
+			case msg = <-recvChan:
+			default:
+				if deadline == nil {
+					break fill
+				}
+				select {
+				case msg = <-recvChan:
+				case <-deadline:
+					deadline = nil
+					break fill
+				case <-stop:
+					timer.Stop()
+					return
 				}
 			}
+			if m := natsToProtoMessage(msg, p.Stream, leaderEpoch); m != nil {
+				msgBatch = append(msgBatch, m)
+			}
+			remaining--
+		}
+		if timer != nil && deadline != nil {
+			timer.Stop()
+		}
+
+		// Messages published directly to NATS bypass the API's key check,
+		// so drop keyless messages here if the stream requires keys.
+		if p.GetRequireKey() {
+			msgBatch = p.dropKeyless(msgBatch)
+		}
+		msgBatch = p.applyEmptyValuePolicy(msgBatch)
+		msgBatch = p.dropInvalid(msgBatch)
+		p.validateDeliverAt(msgBatch)
+		p.compressValues(msgBatch)
 
-			if chanLen > remaining {
-				chanLen = remaining
+		// All messages in the batch may have targeted other streams.
+		if len(msgBatch) == 0 {
+			continue
+		}
+
+		for _, m := range msgBatch {
+			if m.Timestamp < lastTimestamp {
+				m.Timestamp = lastTimestamp
 			}
+			lastTimestamp = m.Timestamp
+		}
 
-			for i := 0; i < chanLen; i++ {
-				msg = <-recvChan
-				m := natsToProtoMessage(msg, leaderEpoch)
-				msgBatch = append(msgBatch, m)
+		// Stamp message IDs, if configured. This loop is the only writer to
+		// the leader's log, so the offset each message will be written at is
+		// known ahead of the append. Followers replicate the stamped messages
+		// as-is.
+		if genID := p.srv.config.Streams.MessageIDFunc; genID != nil {
+			next := p.log.NewestOffset() + 1
+			for i, m := range msgBatch {
+				id := genID(p.Stream, p.Id, next+int64(i), m.Timestamp)
+				m.Headers[MessageIDHeader] = []byte(id)
 			}
-			remaining -= chanLen
 		}
 
 		// Write uncommitted messages to log.
 		offsets, err := p.log.Append(msgBatch)
+		if err == commitlog.ErrQuotaExceeded {
+			// The batch is dropped. Publishers waiting on an ack are sent a
+			// rejection instead.
+			p.srv.logger.Warnf("Dropped %d messages for partition %s: %v", len(msgBatch), p, err)
+			for _, msg := range msgBatch {
+				if msg.AckPolicy != client.AckPolicy_NONE {
+					p.sendRejection(msg, codes.ResourceExhausted, err)
+				}
+			}
+			continue
+		}
 		if err != nil {
 			p.srv.logger.Errorf("Failed to append to log %s: %v", p, err)
 			return
 		}
 
 		for i, msg := range msgBatch {
-			p.processPendingMessage(offsets[i], msg)
+			p.processPendingMessage(offsets[i], msg, received)
 		}
+		atomic.StoreInt32(&p.active, 1)
 
 		// Update this replica's latest offset.
 		p.updateISRLatestOffset(
@@ -652,10 +1186,87 @@ func (p *partition) messageProcessingLoop(recvChan <-chan *nats.Msg, stop <-chan
 	}
 }
 
+// appendImported appends the imported messages of the given request to the
+// log as a single batch and reports the result on the request. The messages
+// keep their keys, values, headers, and timestamps, but are assigned the
+// current leader epoch. Like published messages, timestamps earlier than the
+// last message's are raised to it, which is updated. It returns false if the
+// log failed in a way which should stop the message processing loop.
+func (p *partition) appendImported(req *importBatch, leaderEpoch uint64, lastTimestamp *int64) bool {
+	received := time.Now()
+	req.msgs = p.applyEmptyValuePolicy(req.msgs)
+	p.validateDeliverAt(req.msgs)
+	for _, m := range req.msgs {
+		if m.Timestamp < *lastTimestamp {
+			m.Timestamp = *lastTimestamp
+		}
+		*lastTimestamp = m.Timestamp
+		m.LeaderEpoch = leaderEpoch
+		m.AckInbox = ""
+		m.CorrelationID = ""
+		m.AckPolicy = client.AckPolicy_NONE
+	}
+	req.offsets, req.err = p.log.Append(req.msgs)
+	close(req.done)
+	if req.err == commitlog.ErrQuotaExceeded {
+		p.srv.logger.Warnf("Dropped %d imported messages for partition %s: %v",
+			len(req.msgs), p, req.err)
+		return true
+	}
+	if req.err != nil {
+		p.srv.logger.Errorf("Failed to append to log %s: %v", p, req.err)
+		return false
+	}
+	for i, msg := range req.msgs {
+		p.processPendingMessage(req.offsets[i], msg, received)
+	}
+	atomic.StoreInt32(&p.active, 1)
+	p.updateISRLatestOffset(
+		p.srv.config.Clustering.ServerID,
+		req.offsets[len(req.offsets)-1],
+	)
+	return true
+}
+
+// Import appends the given messages to the partition's log, in order and
+// without interleaving other messages, bypassing NATS ingest. It returns the
+// offsets of the messages once the leader has written them, before they are
+// committed. ErrNotPartitionLeader is returned if this server is not the
+// partition leader. If the context is done before the messages are written,
+// its error is returned, but the messages may still be appended.
+func (p *partition) Import(ctx context.Context, msgs []*commitlog.Message) ([]int64, error) {
+	p.mu.RLock()
+	if !p.isLeading {
+		p.mu.RUnlock()
+		return nil, ErrNotPartitionLeader
+	}
+	var (
+		importChan = p.importChan
+		stop       = p.stopLeader
+	)
+	p.mu.RUnlock()
+
+	req := &importBatch{msgs: msgs, done: make(chan struct{})}
+	select {
+	case importChan <- req:
+	case <-stop:
+		return nil, ErrNotPartitionLeader
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case <-req.done:
+		return req.offsets, req.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // processPendingMessage sends an ack if the message's AckPolicy is LEADER and
-// adds the pending message to the commit queue. Messages are removed from the
-// queue and committed when the entire ISR has replicated them.
-func (p *partition) processPendingMessage(offset int64, msg *commitlog.Message) {
+// adds the pending message, received at the given time, to the commit queue.
+// Messages are removed from the queue and committed when the entire ISR has
+// replicated them.
+func (p *partition) processPendingMessage(offset int64, msg *commitlog.Message, received time.Time) {
 	ack := &client.Ack{
 		Stream:           p.Stream,
 		PartitionSubject: p.Subject,
@@ -670,11 +1281,22 @@ func (p *partition) processPendingMessage(offset int64, msg *commitlog.Message)
 		// leader has written the message to its WAL.
 		p.sendAck(ack)
 	}
-	if err := p.commitQueue.Put(ack); err != nil {
+	if err := p.commitQueue.PutAt(ack, received); err != nil {
 		// This is very bad and should not happen.
 		panic(fmt.Sprintf("Failed to add message to commit queue: %v", err))
 	}
-}
+	if followers := minFollowers(msg.Headers); followers > 0 {
+		// The header may have been set by a publisher bypassing the API's
+		// validation, e.g. directly on NATS. Requiring more followers than
+		// the partition has would keep it from ever committing again.
+		if max := len(p.GetReplicas()) - 1; followers > max {
+			followers = max
+		}
+		if err := p.commitQueue.RequireFollowers(offset, followers); err != nil {
+			panic(fmt.Sprintf("Failed to add message to commit queue: %v", err))
+		}
+	}
+}
 
 // startReplicating starts a long-running goroutine which handles committing
 // messages in the commit queue and a replication goroutine for each replica.
@@ -682,30 +1304,49 @@ func (p *partition) startReplicating(epoch uint64, stop chan struct{}) {
 	if p.ReplicationFactor > 1 {
 		p.srv.logger.Debugf("Replicating partition %s to followers", p)
 	}
-	p.commitQueue = queue.New(100)
+	p.commitQueue = newCommitQueue(100)
 	p.srv.startGoroutine(func() {
 		p.commitLoop(stop)
 		p.shutdown.Done()
 	})
 
-	p.replicators = make(map[string]*replicator, len(p.replicas)-1)
+	p.replicators = make(map[string]*replicator, len(p.replicas)-1+len(p.observers))
 	for replica := range p.replicas {
 		if replica == p.srv.config.Clustering.ServerID {
 			// Don't replicate to ourselves.
 			continue
 		}
-		r := newReplicator(epoch, replica, p)
-		p.replicators[replica] = r
-		p.srv.startGoroutine(func() {
-			r.start(stop)
-			p.shutdown.Done()
-		})
+		p.startReplicator(epoch, replica, false, stop)
+	}
+	for observer := range p.observers {
+		p.startReplicator(epoch, observer, true, stop)
 	}
 }
 
+// startReplicator starts replicating to the given replica until the stop
+// channel is closed. If a replicator for the replica already exists because
+// the replica was removed and added back, it's reused. This must be called
+// with the partition lock held.
+func (p *partition) startReplicator(epoch uint64, replica string, observer bool, stop chan struct{}) {
+	if r, ok := p.replicators[replica]; ok {
+		r.setObserver(observer)
+		r.setRemoved(false)
+		return
+	}
+	r := newReplicator(epoch, replica, p)
+	r.observer = observer
+	p.replicators[replica] = r
+	p.srv.startGoroutine(func() {
+		r.start(stop)
+		p.shutdown.Done()
+	})
+}
+
 // commitLoop is a long-running loop which checks to see if messages in the
 // commit queue can be committed and, if so, removes them from the queue and
-// sends client acks. It runs until the stop channel is closed.
+// sends client acks. If CommitCoalesceInterval is set, it waits that long
+// after a check is signaled so that the replication progress made meanwhile
+// is committed in the same pass. It runs until the stop channel is closed.
 func (p *partition) commitLoop(stop chan struct{}) {
 	for {
 		select {
@@ -714,6 +1355,23 @@ func (p *partition) commitLoop(stop chan struct{}) {
 		case <-p.commitCheck:
 		}
 
+		if interval := p.srv.config.Clustering.CommitCoalesceInterval; interval > 0 {
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+			// This pass handles the checks signaled while waiting.
+		DRAIN:
+			for {
+				select {
+				case <-p.commitCheck:
+				default:
+					break DRAIN
+				}
+			}
+		}
+
 		p.mu.RLock()
 
 		// Check if the ISR size is below the minimum ISR size. If it is, we
@@ -732,7 +1390,9 @@ func (p *partition) commitLoop(stop chan struct{}) {
 
 		// Commit all messages in the queue that have been replicated by all
 		// replicas in the ISR. Do this by taking the min of all latest offsets
-		// in the ISR, updating the HW, and acking queue entries.
+		// in the ISR, updating the HW, and acking queue entries. Messages
+		// requiring more followers than are in the ISR, and the messages
+		// after them, are held back.
 		var (
 			latestOffsets = make([]int64, isrSize)
 			i             = 0
@@ -743,10 +1403,8 @@ func (p *partition) commitLoop(stop chan struct{}) {
 		}
 		p.mu.RUnlock()
 		var (
-			minLatest      = min(latestOffsets)
-			committed, err = p.commitQueue.TakeUntil(func(pending interface{}) bool {
-				return pending.(*client.Ack).Offset <= minLatest
-			})
+			minLatest      = p.commitQueue.CommitLimit(min(latestOffsets), isrSize-1)
+			committed, err = p.commitQueue.TakeThrough(minLatest)
 		)
 
 		p.log.SetHighWatermark(minLatest)
@@ -762,8 +1420,7 @@ func (p *partition) commitLoop(stop chan struct{}) {
 		}
 
 		// Ack any committed entries (if applicable).
-		for _, ackIface := range committed {
-			ack := ackIface.(*client.Ack)
+		for _, ack := range committed {
 			// Only send an ack if the AckPolicy is ALL.
 			if ack.AckPolicy == client.AckPolicy_ALL {
 				p.sendAck(ack)
@@ -785,11 +1442,35 @@ func (p *partition) sendAck(ack *client.Ack) {
 	p.srv.ncAcks.Publish(ack.AckInbox, data)
 }
 
+// sendRejection publishes a rejection of the given message to its ack inbox in
+// place of an ack, if it has one. The code is the gRPC status code Publish
+// fails with.
+func (p *partition) sendRejection(msg *commitlog.Message, code codes.Code, reason error) {
+	if msg.AckInbox == "" {
+		return
+	}
+	data, err := proto.MarshalPublishRejection(&proto.PublishRejection{
+		Stream:        p.Stream,
+		Partition:     p.Id,
+		CorrelationId: msg.CorrelationID,
+		Reason:        reason.Error(),
+		Code:          uint32(code),
+	})
+	if err != nil {
+		panic(err)
+	}
+	p.srv.ncAcks.Publish(msg.AckInbox, data)
+}
+
 // replicationRequestLoop is a long-running loop which sends replication
 // requests to the partition leader, handles replicating messages, and checks
 // the health of the leader.
 func (p *partition) replicationRequestLoop(leader string, epoch uint64, stop <-chan struct{}) {
-	leaderLastSeen := time.Now()
+	var (
+		leaderLastSeen = time.Now()
+		leavingISR     bool      // Requested to leave the ISR to fetch from a sync source
+		lastReported   time.Time // Last reported progress to the leader while fetching from a sync source
+	)
 	for {
 		select {
 		case <-stop:
@@ -797,12 +1478,66 @@ func (p *partition) replicationRequestLoop(leader string, epoch uint64, stop <-c
 		default:
 		}
 
-		replicated, err := p.sendReplicationRequest(epoch)
+		// Truncate to the leader's log before fetching if this replica may
+		// be ahead of it, otherwise the logs would diverge.
+		select {
+		case <-p.truncateCheck:
+			if err := p.truncateToLeader(); err != nil {
+				p.srv.logger.Errorf(
+					"Failed to truncate partition %s to leader %s: %v", p, leader, err)
+				p.requestTruncate()
+				p.checkLeaderHealth(leader, epoch, leaderLastSeen)
+				select {
+				case <-stop:
+					return
+				case <-time.After(p.computeReplicaFetchSleep()):
+				}
+				continue
+			}
+			leaderLastSeen = time.Now()
+		default:
+		}
+
+		var (
+			replicated   int
+			err          error
+			syncFollower = p.isSyncFollower(leader)
+			source       = p.syncSource(leader)
+		)
+		if syncFollower {
+			// Messages from the sync source are committed, so this replica
+			// can't hold up commits from the ISR.
+			if !p.inISR(p.srv.config.Clustering.ServerID) {
+				leavingISR = false
+			} else if !leavingISR {
+				leavingISR = p.leaveISR(leader, epoch)
+			}
+		}
+		if source != "" {
+			replicated, err = p.sendSyncRequest(source, epoch)
+			if err != nil {
+				p.srv.logger.Warnf("Error sending replication request for partition %s "+
+					"to sync source %s, fetching from leader: %v", p, source, err)
+			} else if time.Since(lastReported) >= p.replicaMaxLagTime()/2 {
+				// Report progress to the leader well within its lag time
+				// so that it doesn't consider this replica stalled.
+				if e := p.reportSyncProgress(epoch); e != nil {
+					p.srv.logger.Warnf("Error reporting progress for partition %s to leader: %v", p, e)
+				} else {
+					lastReported = time.Now()
+				}
+			}
+		}
+		if source == "" || err != nil {
+			replicated, err = p.sendReplicationRequest(epoch, syncFollower)
+		}
 		if err != nil {
 			p.srv.logger.Errorf(
 				"Error sending replication request for partition %s: %v", p, err)
 		} else {
+			// The sync source only responds while it follows the leader.
 			leaderLastSeen = time.Now()
+			p.setElectingLeader(false)
 		}
 
 		// Check if leader has exceeded max leader timeout.
@@ -824,6 +1559,10 @@ func (p *partition) replicationRequestLoop(leader string, epoch uint64, stop <-c
 		case <-p.notify:
 			// Leader has signalled more data is available.
 			continue
+		case <-p.truncateCheck:
+			// Leader has signalled this replica is ahead of it.
+			p.requestTruncate()
+			continue
 		}
 	}
 }
@@ -831,6 +1570,10 @@ func (p *partition) replicationRequestLoop(leader string, epoch uint64, stop <-c
 // checkLeaderHealth checks if the leader has responded within
 // ReplicaMaxLeaderTimeout and, if not, reports the leader to the controller.
 func (p *partition) checkLeaderHealth(leader string, epoch uint64, leaderLastSeen time.Time) {
+	// Observers don't take part in leader failure detection.
+	if p.inObservers(p.srv.config.Clustering.ServerID) {
+		return
+	}
 	lastSeenElapsed := time.Since(leaderLastSeen)
 	if lastSeenElapsed > p.srv.config.Clustering.ReplicaMaxLeaderTimeout {
 		// Leader has not sent a response in ReplicaMaxLeaderTimeout, so report
@@ -838,6 +1581,13 @@ func (p *partition) checkLeaderHealth(leader string, epoch uint64, leaderLastSee
 		p.srv.logger.Errorf("Leader %s for partition %s exceeded max leader timeout "+
 			"(last seen: %s), reporting leader to controller",
 			leader, p, lastSeenElapsed)
+		// The partition may have moved on to a new leader concurrently, in
+		// which case there is no election to wait on.
+		p.mu.RLock()
+		if p.Leader == leader && p.LeaderEpoch == epoch {
+			p.setElectingLeader(true)
+		}
+		p.mu.RUnlock()
 		req := &proto.ReportLeaderOp{
 			Stream:      p.Stream,
 			Replica:     p.srv.config.Clustering.ServerID,
@@ -847,6 +1597,7 @@ func (p *partition) checkLeaderHealth(leader string, epoch uint64, leaderLastSee
 		if err := p.srv.metadata.ReportLeader(context.Background(), req); err != nil {
 			p.srv.logger.Errorf("Failed to report leader %s for partition %s: %s",
 				leader, p, err.Err())
+			p.setElectingLeader(false)
 		}
 	}
 }
@@ -855,27 +1606,49 @@ func (p *partition) checkLeaderHealth(leader string, epoch uint64, leaderLastSee
 // another replication request.
 func (p *partition) computeReplicaFetchSleep() time.Duration {
 	sleep := p.srv.config.Clustering.ReplicaMaxIdleWait
-	// Subtract some random jitter from the max wait time.
-	return sleep - time.Duration(rand.Intn(2000))*time.Millisecond
+	// Don't idle long enough to fall out of the ISR if the stream's max lag
+	// time is shorter than the server's max idle wait.
+	if maxLag := p.replicaMaxLagTime(); sleep > maxLag/2 {
+		sleep = maxLag / 2
+	}
+	// Subtract some random jitter, up to a fifth of the wait, so followers
+	// don't all fetch at once. Never go below a millisecond so the loop
+	// doesn't spin.
+	if jitter := int64(sleep / 5); jitter > 0 {
+		sleep -= time.Duration(rand.Int63n(jitter))
+	}
+	if sleep < time.Millisecond {
+		sleep = time.Millisecond
+	}
+	return sleep
 }
 
 // sendReplicationRequest sends a replication request to the partition leader
 // and processes the response. It returns an int indicating the number of
 // messages that were replicated. Zero (without an error) indicates the
 // follower is caught up with the leader.
-func (p *partition) sendReplicationRequest(leaderEpoch uint64) (int, error) {
+func (p *partition) sendReplicationRequest(leaderEpoch uint64, syncFollower bool) (int, error) {
+	return p.requestReplication(p.getReplicationRequestInbox(), leaderEpoch, syncFollower)
+}
+
+// requestReplication sends a replication request to the given NATS inbox and
+// replicates the messages in the response, returning the number of messages
+// replicated. If syncFollower is true, the request tells the leader this
+// replica fetches from a sync source so that it's kept out of the ISR.
+func (p *partition) requestReplication(inbox string, leaderEpoch uint64, syncFollower bool) (int, error) {
 	data, err := proto.MarshalReplicationRequest(&proto.ReplicationRequest{
-		ReplicaID:   p.srv.config.Clustering.ServerID,
-		Offset:      p.log.NewestOffset(),
-		LeaderEpoch: leaderEpoch,
+		ReplicaID:    p.srv.config.Clustering.ServerID,
+		Offset:       p.log.NewestOffset(),
+		LeaderEpoch:  leaderEpoch,
+		SyncFollower: syncFollower,
 	})
 	if err != nil {
 		panic(err)
 	}
 	resp, err := p.srv.ncRepl.Request(
-		p.getReplicationRequestInbox(),
+		inbox,
 		data,
-		p.srv.config.Clustering.ReplicaFetchTimeout,
+		p.replicaFetchTimeout(),
 	)
 	if err != nil {
 		return 0, err
@@ -883,10 +1656,36 @@ func (p *partition) sendReplicationRequest(leaderEpoch uint64) (int, error) {
 	return p.handleReplicationResponse(resp), nil
 }
 
+// TruncateFallback determines how a follower truncates uncommitted messages
+// when it can't fetch the leader's offset for its leader epoch.
+type TruncateFallback int
+
+const (
+	// TruncateFallbackHW truncates the log to the HW. This lets the follower
+	// start replicating without the leader, but it can lose messages or
+	// leave the follower ahead of a new leader. A follower found to be ahead
+	// is still truncated to the leader's log before it replicates further.
+	// This is the default.
+	TruncateFallbackHW TruncateFallback = iota
+
+	// TruncateFallbackLeader keeps the log as is and truncates it against the
+	// leader once the leader is reachable, before replicating from it.
+	TruncateFallbackLeader
+)
+
 // truncateUncommitted truncates the log up to the start offset of the first
 // leader epoch larger than the current epoch. This removes any potentially
-// uncommitted messages in the log.
+// uncommitted messages in the log. If the leader can't be reached, the
+// ReplicaTruncateFallback setting determines whether the log is truncated to
+// the HW or truncated against the leader before replicating from it.
 func (p *partition) truncateUncommitted() error {
+	// Nothing to truncate if the log is empty, e.g. the partition was just
+	// created. This avoids waiting on a leader which may not have created the
+	// partition yet.
+	if p.log.NewestOffset() == -1 {
+		return nil
+	}
+
 	// Request the last offset for the epoch from the leader.
 	var (
 		lastOffset  int64
@@ -906,16 +1705,41 @@ func (p *partition) truncateUncommitted() error {
 		p.srv.logger.Errorf(
 			"Failed to fetch last offset for leader epoch for partition %s: %v",
 			p, err)
+		if p.srv.config.Clustering.ReplicaTruncateFallback == TruncateFallbackLeader {
+			// Truncate against the leader once it's reachable, before
+			// replicating from it.
+			p.requestTruncate()
+			return nil
+		}
 		// Fall back to HW truncation if we fail to fetch last offset for
 		// leader epoch.
-		// TODO: Should this be configurable since there is potential for data
-		// loss or replica divergence?
 		return p.truncateToHW()
 	}
 
 	p.srv.logger.Debugf("Truncating log for partition %s to %d", p, lastOffset)
 	// Add 1 because we don't want to truncate the last offset itself.
-	return p.log.Truncate(lastOffset + 1)
+	return p.truncate(lastOffset + 1)
+}
+
+// truncateToLeader truncates the log to the last offset the leader has for
+// this replica's latest leader epoch, removing any messages the leader does
+// not have so that replication resumes from the leader's lineage.
+func (p *partition) truncateToLeader() error {
+	newest := p.log.NewestOffset()
+	if newest == -1 {
+		return nil
+	}
+	lastOffset, err := p.sendLeaderOffsetRequest(p.log.LastLeaderEpoch())
+	if err != nil {
+		return err
+	}
+	if lastOffset >= newest {
+		return nil
+	}
+	p.srv.logger.Warnf("Truncating log for partition %s from %d to leader offset %d",
+		p, newest, lastOffset)
+	// Add 1 because we don't want to truncate the last offset itself.
+	return p.truncate(lastOffset + 1)
 }
 
 // sendLeaderOffsetRequest sends a request to the leader for the last offset
@@ -956,34 +1780,259 @@ func (p *partition) truncateToHW() error {
 	}
 	p.srv.logger.Debugf("Truncating log for partition %s to HW %d", p, hw)
 	// Add 1 because we don't want to truncate the HW itself.
-	return p.log.Truncate(hw + 1)
+	return p.truncate(hw + 1)
+}
+
+// truncate removes all messages from the log starting at the given offset,
+// notifying subscriptions if any were removed.
+func (p *partition) truncate(offset int64) error {
+	newest := p.log.NewestOffset()
+	if err := p.log.Truncate(offset); err != nil {
+		return err
+	}
+	if p.log.NewestOffset() != newest {
+		p.notifyChanged(true)
+	}
+	return nil
 }
 
 // inISR indicates if the given replica is in the current in-sync replicas set.
+// This only takes the ISR lock since replicators call it and stopping
+// leadership waits on them with the partition lock held.
 func (p *partition) inISR(replica string) bool {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
+	p.isrMu.RLock()
+	defer p.isrMu.RUnlock()
 	_, ok := p.isr[replica]
 	return ok
 }
 
+// inObservers indicates if the given broker is an observer for the partition.
+func (p *partition) inObservers(id string) bool {
+	_, ok := p.observers[id]
+	return ok
+}
+
+// isReplica indicates if the given broker is a replica for the partition.
+func (p *partition) isReplica(id string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.inReplicas(id)
+}
+
+// isObserver indicates if the given broker is an observer for the partition.
+func (p *partition) isObserver(id string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.inObservers(id)
+}
+
+// allowsReplicaOn indicates if a replica of the partition can be placed on
+// the given server, i.e. the partition is not pinned to other servers.
+func (p *partition) allowsReplicaOn(id string) bool {
+	if len(p.PinnedServers) == 0 {
+		return true
+	}
+	for _, server := range p.PinnedServers {
+		if server == id {
+			return true
+		}
+	}
+	return false
+}
+
 // inReplicas indicates if the given broker is a replica for the partition.
 func (p *partition) inReplicas(id string) bool {
 	_, ok := p.replicas[id]
 	return ok
 }
 
+// replicaStateError is returned when a change to a partition's replicas or ISR
+// doesn't apply to its current state, e.g. because its leader or replicas
+// changed between the change being validated and applied through Raft. Every
+// server applies the same changes in the same order, so these are returned to
+// the caller rather than failing the FSM apply.
+type replicaStateError struct {
+	msg string
+}
+
+func (e *replicaStateError) Error() string {
+	return e.msg
+}
+
+func replicaStateErrorf(format string, args ...interface{}) error {
+	return &replicaStateError{msg: fmt.Sprintf(format, args...)}
+}
+
+// isReplicaStateError indicates if the error is a replicaStateError.
+func isReplicaStateError(err error) bool {
+	_, ok := err.(*replicaStateError)
+	return ok
+}
+
+// ChangeReplicaRole demotes the given replica to an observer if observer is
+// true or promotes the given observer to a replica otherwise. A demoted
+// replica is removed from the ISR, so commits no longer wait on it. A promoted
+// observer joins the ISR once it has caught up with the leader. This does
+// nothing if the replica already has the role. A replicaStateError is returned
+// if the replica is the partition leader or is neither a replica nor an
+// observer.
+func (p *partition) ChangeReplicaRole(replica string, observer bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if observer && p.inObservers(replica) || !observer && p.inReplicas(replica) {
+		return nil
+	}
+	if observer {
+		if !p.inReplicas(replica) {
+			return replicaStateErrorf("%s not a replica", replica)
+		}
+		if replica == p.Leader {
+			return replicaStateErrorf("%s is the partition leader", replica)
+		}
+		delete(p.replicas, replica)
+		p.isrMu.Lock()
+		delete(p.isr, replica)
+		p.isrMu.Unlock()
+		p.observers[replica] = struct{}{}
+	} else {
+		if !p.inObservers(replica) {
+			return replicaStateErrorf("%s not an observer", replica)
+		}
+		delete(p.observers, replica)
+		p.replicas[replica] = struct{}{}
+	}
+
+	p.syncReplicaMetadata()
+
+	if r, ok := p.replicators[replica]; ok {
+		r.setObserver(observer)
+	}
+
+	// We may need to commit messages since the ISR shrank.
+	if p.isLeading && observer {
+		select {
+		case p.commitCheck <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// ChangeReplicas sets the partition's replica set. Added replicas join the
+// ISR once they have caught up with the leader. Removed replicas are removed
+// from the ISR, so commits no longer wait on them. If this server is added, it
+// starts following the leader. If it's removed, it stops following and
+// removes the messages from its log. Setting the current replicas again does
+// nothing. A replicaStateError is returned if the partition leader would be
+// removed or an observer added, e.g. because leadership changed after the
+// change was validated.
+func (p *partition) ChangeReplicas(replicas []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	newReplicas := make(map[string]struct{}, len(replicas))
+	for _, replica := range replicas {
+		if p.inObservers(replica) {
+			return replicaStateErrorf("%s is an observer", replica)
+		}
+		newReplicas[replica] = struct{}{}
+	}
+	if _, ok := newReplicas[p.Leader]; !ok && p.Leader != "" {
+		return replicaStateErrorf("%s is the partition leader", p.Leader)
+	}
+
+	var added, removed []string
+	for replica := range newReplicas {
+		if !p.inReplicas(replica) {
+			added = append(added, replica)
+		}
+	}
+	for replica := range p.replicas {
+		if _, ok := newReplicas[replica]; !ok {
+			removed = append(removed, replica)
+			p.isrMu.Lock()
+			delete(p.isr, replica)
+			p.isrMu.Unlock()
+		}
+	}
+	p.replicas = newReplicas
+	p.syncReplicaMetadata()
+
+	if p.isLeading {
+		for _, replica := range added {
+			p.startReplicator(p.LeaderEpoch, replica, false, p.stopLeader)
+		}
+		for _, replica := range removed {
+			if r, ok := p.replicators[replica]; ok {
+				r.setRemoved(true)
+			}
+		}
+		// We may need to commit messages since the ISR shrank.
+		if len(removed) > 0 {
+			select {
+			case p.commitCheck <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	id := p.srv.config.Clustering.ServerID
+	if p.isFollowing && !p.inReplicas(id) {
+		if err := p.stopFollowing(); err != nil {
+			return err
+		}
+		// The replica was moved, so release its disk space while keeping
+		// offsets contiguous in case it's added back later.
+		if err := p.log.TrimBefore(p.log.NewestOffset() + 1); err != nil {
+			return err
+		}
+	} else if !p.isLeading && !p.isFollowing && !p.isClosed && !p.recovered &&
+		p.Leader != "" && p.inReplicas(id) {
+		if err := p.becomeFollower(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncReplicaMetadata updates the partition metadata from its replica,
+// observer, and ISR sets, keeping it in sync for snapshots. This must be
+// called with the partition lock held.
+func (p *partition) syncReplicaMetadata() {
+	p.Replicas = make([]string, 0, len(p.replicas))
+	for rep := range p.replicas {
+		p.Replicas = append(p.Replicas, rep)
+	}
+	p.Observers = make([]string, 0, len(p.observers))
+	for rep := range p.observers {
+		p.Observers = append(p.Observers, rep)
+	}
+	p.Isr = make([]string, 0, len(p.isr))
+	for rep := range p.isr {
+		p.Isr = append(p.Isr, rep)
+	}
+	p.ReplicationFactor = int32(len(p.replicas))
+}
+
 // RemoveFromISR removes the given replica from the in-sync replicas set. It
-// returns an error if the broker is not a partition replica. This will also
-// insert a check to see if pending messages need to be committed since the ISR
-// shrank.
+// returns a replicaStateError if the broker is not a partition replica. This
+// does nothing if the broker is an observer, e.g. because the replica was
+// demoted after the removal was requested, since observers are never in the
+// ISR. This will also insert a check to see if pending messages need to be
+// committed since the ISR shrank.
 func (p *partition) RemoveFromISR(replica string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if p.inObservers(replica) {
+		return nil
+	}
 	if !p.inReplicas(replica) {
-		return fmt.Errorf("%s not a replica", replica)
+		return replicaStateErrorf("%s not a replica", replica)
 	}
+	p.isrMu.Lock()
 	delete(p.isr, replica)
+	p.isrMu.Unlock()
 
 	// Check if ISR went below minimum ISR size. This is important for
 	// operators to be aware of.
@@ -1008,15 +2057,22 @@ func (p *partition) RemoveFromISR(replica string) error {
 	return nil
 }
 
-// AddToISR adds the given replica to the in-sync replicas set. It returns an
-// error if the broker is not a partition replica.
+// AddToISR adds the given replica to the in-sync replicas set. It returns a
+// replicaStateError if the broker is not a partition replica. This does nothing
+// if the broker is an observer, e.g. because the replica was demoted after the
+// addition was requested, since observers never join the ISR.
 func (p *partition) AddToISR(rep string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if p.inObservers(rep) {
+		return nil
+	}
 	if !p.inReplicas(rep) {
-		return fmt.Errorf("%s not a replica", rep)
+		return replicaStateErrorf("%s not a replica", rep)
 	}
+	p.isrMu.Lock()
 	p.isr[rep] = &replica{offset: -1}
+	p.isrMu.Unlock()
 
 	// Check if ISR recovered from being below the minimum ISR size.
 	var (
@@ -1032,6 +2088,102 @@ func (p *partition) AddToISR(rep string) error {
 	return nil
 }
 
+// setElectingLeader marks the partition as electing a new leader or not,
+// waking up publishes waiting on the election when it's cleared.
+func (p *partition) setElectingLeader(electing bool) {
+	if electing {
+		atomic.StoreInt32(&p.electing, 1)
+	} else if atomic.CompareAndSwapInt32(&p.electing, 1, 0) {
+		p.notifyChanged(false)
+	}
+}
+
+// IsElectingLeader indicates if this server has reported the partition's
+// leader as failed and a new leader has not been elected yet. Only replicas
+// which reported the leader know of the election.
+func (p *partition) IsElectingLeader() bool {
+	return atomic.LoadInt32(&p.electing) == 1
+}
+
+// waitForLeaderElection blocks until the partition is no longer electing a
+// new leader or the context is done, returning false in the latter case.
+func (p *partition) waitForLeaderElection(ctx context.Context) bool {
+	for {
+		changed, _ := p.changes()
+		if !p.IsElectingLeader() {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// IsBusy indicates if this server is the partition leader and either its
+// message processing loop is saturated or its backlog of messages waiting to
+// be committed exceeds maxCommitBacklog.
+func (p *partition) IsBusy() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.isLeading {
+		return false
+	}
+	return len(p.recvChan) >= cap(p.recvChan) || p.commitQueue.Len() >= maxCommitBacklog
+}
+
+// QuotaExceeded indicates if this server is the partition leader and the
+// partition's log has no room left under its byte quota with the reject quota
+// policy, so published messages would be dropped.
+func (p *partition) QuotaExceeded() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.isLeading {
+		return false
+	}
+	return p.log.QuotaExceeded()
+}
+
+// PendingAcksFull indicates if this server is the partition leader and the
+// number of AckPolicy_ALL messages waiting to be committed has reached
+// streams.max.pending.acks.
+func (p *partition) PendingAcksFull() bool {
+	max := p.srv.config.Streams.MaxPendingAcks
+	if max <= 0 {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.isLeading {
+		return false
+	}
+	return p.commitQueue.AckAllLen() >= max
+}
+
+// AppendLatency returns how long the oldest message this server holds waiting
+// to be committed has waited since it was received if this server is the
+// partition leader. It's zero if there are no such messages or this server is
+// not the leader.
+func (p *partition) AppendLatency() time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.isLeading {
+		return 0
+	}
+	return p.commitQueue.Latency(time.Now())
+}
+
+// AppendLatencyExceeded indicates if this server is the partition leader and
+// its append latency exceeds streams.max.append.latency.
+func (p *partition) AppendLatencyExceeded() bool {
+	max := p.srv.config.Streams.MaxAppendLatency
+	if max <= 0 {
+		return false
+	}
+	return p.AppendLatency() > max
+}
+
 // GetEpoch returns the current partition epoch. The epoch is a monotonically
 // increasing number which increases when a change is made to the partition. This
 // is used to determine if an operation is outdated.
@@ -1068,6 +2220,14 @@ func (p *partition) ISRSize() int {
 	return size
 }
 
+// IsUnderReplicated indicates if the in-sync replicas set is smaller than the
+// set of all replicas for the partition.
+func (p *partition) IsUnderReplicated() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.isr) < len(p.replicas)
+}
+
 // GetISR returns the in-sync replicas set.
 func (p *partition) GetISR() []string {
 	p.mu.RLock()
@@ -1095,9 +2255,9 @@ func (p *partition) GetReplicas() []string {
 // replica's latest log offset increases, we check to see if anything in the
 // commit queue can be committed.
 func (p *partition) updateISRLatestOffset(replica string, offset int64) {
-	p.mu.RLock()
+	p.isrMu.RLock()
 	rep, ok := p.isr[replica]
-	p.mu.RUnlock()
+	p.isrMu.RUnlock()
 	if !ok {
 		// Replica is not currently in ISR.
 		return
@@ -1114,9 +2274,22 @@ func (p *partition) updateISRLatestOffset(replica string, offset int64) {
 // sendPartitionNotification sends a message to the given partition replica to
 // indicate new data is available in the log.
 func (p *partition) sendPartitionNotification(replica string) {
+	p.publishPartitionNotification(replica, false)
+}
+
+// sendTruncateNotification notifies the given replica that it is ahead of the
+// leader and must truncate its log before replicating further.
+func (p *partition) sendTruncateNotification(replica string) {
+	p.publishPartitionNotification(replica, true)
+}
+
+// publishPartitionNotification sends a partition notification to the given
+// replica.
+func (p *partition) publishPartitionNotification(replica string, truncate bool) {
 	req, err := proto.MarshalPartitionNotification(&proto.PartitionNotification{
 		Stream:    p.Stream,
 		Partition: p.Id,
+		Truncate:  truncate,
 	})
 	if err != nil {
 		panic(err)
@@ -1124,12 +2297,42 @@ func (p *partition) sendPartitionNotification(replica string) {
 	p.srv.ncRepl.Publish(p.srv.getPartitionNotificationInbox(replica), req)
 }
 
-// pauseReplication stops replication on the leader. This is for unit testing
-// purposes only.
-func (p *partition) pauseReplication() {
+// PauseReplication stops replication of the partition on this server. As the
+// leader, it stops serving replication requests, so followers fall out of the
+// ISR and eventually report the leader as failed. As a follower, it stops
+// fetching from the leader. Replication stays paused across leadership
+// changes until ResumeReplication is called.
+func (p *partition) PauseReplication() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.replPaused {
+		return
+	}
+	p.replPaused = true
+	p.stopReplicationRequestLoop()
+}
+
+// ResumeReplication resumes replication of the partition on this server after
+// PauseReplication. As a follower, it restarts fetching from the current
+// leader.
+func (p *partition) ResumeReplication() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.pause = true
+	if !p.replPaused {
+		return
+	}
+	p.replPaused = false
+	if p.isFollowing {
+		p.startReplicationRequestLoop()
+	}
+}
+
+// IsReplicationPaused indicates if replication of the partition is paused on
+// this server.
+func (p *partition) IsReplicationPaused() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.replPaused
 }
 
 // getSubject returns the derived NATS subject the partition should subscribe
@@ -1153,9 +2356,127 @@ func getMessage(data []byte) *client.Message {
 	return msg
 }
 
+// dropKeyless removes the messages without a key from the batch. Publishers
+// waiting on an ack are sent a rejection instead.
+func (p *partition) dropKeyless(msgBatch []*commitlog.Message) []*commitlog.Message {
+	keyed := msgBatch[:0]
+	for _, m := range msgBatch {
+		if len(m.Key) > 0 {
+			keyed = append(keyed, m)
+		} else {
+			p.rejectInvalid(m, errors.Errorf("stream %s requires a key", p.Stream))
+		}
+	}
+	if dropped := len(msgBatch) - len(keyed); dropped > 0 {
+		p.srv.logger.Warnf("Dropped %d messages without a key for partition %s", dropped, p)
+	}
+	return keyed
+}
+
+// rejectInvalid sends a rejection of a message dropped for violating the
+// stream's settings to its publisher if it's waiting on an ack.
+func (p *partition) rejectInvalid(msg *commitlog.Message, reason error) {
+	if msg.AckPolicy != client.AckPolicy_NONE {
+		p.sendRejection(msg, codes.InvalidArgument, reason)
+	}
+}
+
+// validateSchema validates the message key and value with the stream's
+// schema validator, if it has one. An error is returned if the message does
+// not conform to the schema or the validator is not registered on this
+// server.
+func (p *partition) validateSchema(key, value []byte) error {
+	schema := p.GetSchema()
+	if schema == "" {
+		return nil
+	}
+	validate, ok := p.srv.config.Streams.SchemaValidators[schema]
+	if !ok {
+		return fmt.Errorf("schema %q is not registered", schema)
+	}
+	if err := validate(key, value); err != nil {
+		return fmt.Errorf("schema %q: %v", schema, err)
+	}
+	return nil
+}
+
+// dropInvalid removes the messages failing the stream's schema validation
+// from the batch. Publishers waiting on an ack are sent a rejection instead.
+func (p *partition) dropInvalid(msgBatch []*commitlog.Message) []*commitlog.Message {
+	if p.GetSchema() == "" {
+		return msgBatch
+	}
+	valid := msgBatch[:0]
+	for _, m := range msgBatch {
+		if err := p.validateSchema(m.Key, m.Value); err != nil {
+			p.srv.logger.Warnf("Dropped message for partition %s: %v", p, err)
+			p.rejectInvalid(m, err)
+			continue
+		}
+		valid = append(valid, m)
+	}
+	return valid
+}
+
+// validateDeliverAt removes the DeliverAtHeader from the messages in the batch
+// where it is invalid or schedules delivery further out than the configured
+// max delivery delay, so they are delivered right away. Messages published
+// directly to NATS or imported bypass the API's validation of the header.
+func (p *partition) validateDeliverAt(msgBatch []*commitlog.Message) {
+	latest := time.Now().Add(p.srv.config.Streams.MaxDeliveryDelay).UnixNano()
+	for _, m := range msgBatch {
+		deliverAt, ok := m.Headers[DeliverAtHeader]
+		if !ok {
+			continue
+		}
+		nanos, err := strconv.ParseInt(string(deliverAt), 10, 64)
+		if err == nil && nanos > 0 && nanos <= latest {
+			continue
+		}
+		p.srv.logger.Warnf("Ignoring invalid delivery time %q of message for partition %s",
+			deliverAt, p)
+		delete(m.Headers, DeliverAtHeader)
+	}
+}
+
+// applyEmptyValuePolicy applies the stream's EmptyValue policy to the
+// messages in the batch with an empty value. Rejected messages are removed
+// from the batch, and publishers waiting on an ack are sent a rejection
+// instead. Tombstones are marked with the TombstoneHeader header.
+func (p *partition) applyEmptyValuePolicy(msgBatch []*commitlog.Message) []*commitlog.Message {
+	switch p.GetEmptyValue() {
+	case proto.EmptyValue_REJECT:
+		nonEmpty := msgBatch[:0]
+		for _, m := range msgBatch {
+			if len(m.Value) > 0 {
+				nonEmpty = append(nonEmpty, m)
+			} else {
+				p.rejectInvalid(m, errors.Errorf("stream %s rejects empty values", p.Stream))
+			}
+		}
+		if dropped := len(msgBatch) - len(nonEmpty); dropped > 0 {
+			p.srv.logger.Warnf("Dropped %d messages with an empty value for partition %s", dropped, p)
+		}
+		return nonEmpty
+	case proto.EmptyValue_TOMBSTONE:
+		for _, m := range msgBatch {
+			if len(m.Value) == 0 {
+				m.Headers[TombstoneHeader] = []byte("true")
+			}
+		}
+	}
+	return msgBatch
+}
+
 // natsToProtoMessage converts the given NATS message to a commit log Message.
-func natsToProtoMessage(msg *nats.Msg, leaderEpoch uint64) *commitlog.Message {
+// Multiple streams can be attached to the same subject, so a message published
+// with an envelope naming a specific stream is only appended to that stream.
+// If the message names a stream other than the given one, nil is returned.
+func natsToProtoMessage(msg *nats.Msg, stream string, leaderEpoch uint64) *commitlog.Message {
 	message := getMessage(msg.Data)
+	if message != nil && message.Stream != "" && message.Stream != stream {
+		return nil
+	}
 	m := &commitlog.Message{
 		MagicByte:   1,
 		Timestamp:   timestamp(),
@@ -1174,6 +2495,11 @@ func natsToProtoMessage(msg *nats.Msg, leaderEpoch uint64) *commitlog.Message {
 	} else {
 		m.Value = msg.Data
 	}
+	// Store empty values consistently as nil regardless of how they were
+	// published.
+	if len(m.Value) == 0 {
+		m.Value = nil
+	}
 	m.Headers["subject"] = []byte(msg.Subject)
 	m.Headers["reply"] = []byte(msg.Reply)
 	return m
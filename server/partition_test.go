@@ -4,7 +4,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/Workiva/go-datastructures/queue"
 	natsdTest "github.com/nats-io/nats-server/v2/test"
 	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/require"
@@ -60,7 +59,7 @@ func TestPartitionCommitLoopCommitNoAck(t *testing.T) {
 	}, false)
 	require.NoError(t, err)
 	defer p.Close()
-	p.commitQueue = queue.New(5)
+	p.commitQueue = newCommitQueue(5)
 
 	// Subscribe to ack inbox.
 	ackInbox := "ack"
@@ -128,7 +127,7 @@ func TestPartitionCommitLoopCommitAck(t *testing.T) {
 	}, false)
 	require.NoError(t, err)
 	defer p.Close()
-	p.commitQueue = queue.New(5)
+	p.commitQueue = newCommitQueue(5)
 
 	// Subscribe to ack inbox.
 	ackInbox := "ack"
@@ -168,6 +167,73 @@ func TestPartitionCommitLoopCommitAck(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// Ensure commitLoop waits for the CommitCoalesceInterval after a commit check
+// and commits the replication progress made meanwhile in the same pass.
+func TestPartitionCommitLoopCoalesce(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Start NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Create NATS connection.
+	nc, err := nats.GetDefaultOptions().Connect()
+	require.NoError(t, err)
+	defer nc.Close()
+
+	// Start Liftbridge server.
+	server := createServer(false)
+	server.config.Clustering.CommitCoalesceInterval = 500 * time.Millisecond
+	require.NoError(t, server.Start())
+	defer server.Stop()
+
+	p, err := server.newPartition(&proto.Partition{
+		Subject:  "foo",
+		Stream:   "foo",
+		Replicas: []string{"a", "b"},
+		Leader:   "a",
+		Isr:      []string{"a", "b"},
+	}, false)
+	require.NoError(t, err)
+	defer p.Close()
+	p.commitQueue = newCommitQueue(5)
+
+	// Subscribe to ack inbox.
+	ackInbox := "ack"
+	sub, err := nc.SubscribeSync(ackInbox)
+	require.NoError(t, err)
+	nc.Flush()
+
+	// Start commit loop.
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.commitLoop(stop)
+
+	// Replicate the first message and trigger a commit.
+	p.commitQueue.Put(&client.Ack{Offset: 0, AckInbox: ackInbox, AckPolicy: client.AckPolicy_ALL})
+	p.isr["a"].updateLatestOffset(0)
+	p.isr["b"].updateLatestOffset(0)
+	p.commitCheck <- struct{}{}
+
+	// Replicate the second message before the interval passes.
+	p.commitQueue.Put(&client.Ack{Offset: 1, AckInbox: ackInbox, AckPolicy: client.AckPolicy_ALL})
+	p.isr["a"].updateLatestOffset(1)
+	p.isr["b"].updateLatestOffset(1)
+	p.commitCheck <- struct{}{}
+
+	// Nothing is committed until the interval passes.
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, int64(2), p.commitQueue.Len())
+
+	// Both messages are then committed together.
+	waitForCommitQueue(t, 5*time.Second, 0, p)
+	require.Equal(t, int64(1), p.log.HighWatermark())
+	for i := 0; i < 2; i++ {
+		_, err = sub.NextMsg(5 * time.Second)
+		require.NoError(t, err)
+	}
+}
+
 // Ensure commitLoop is a no-op when the commitQueue is empty.
 func TestPartitionCommitLoopEmptyQueue(t *testing.T) {
 	defer cleanupStorage(t)
@@ -195,7 +261,7 @@ func TestPartitionCommitLoopEmptyQueue(t *testing.T) {
 	}, false)
 	require.NoError(t, err)
 	defer p.Close()
-	p.commitQueue = queue.New(5)
+	p.commitQueue = newCommitQueue(5)
 
 	// Subscribe to ack inbox.
 	ackInbox := "ack"
@@ -258,7 +324,7 @@ func TestPartitionCommitLoopDisposedQueue(t *testing.T) {
 	}, false)
 	require.NoError(t, err)
 	defer p.Close()
-	p.commitQueue = queue.New(5)
+	p.commitQueue = newCommitQueue(5)
 
 	// Subscribe to ack inbox.
 	ackInbox := "ack"
@@ -319,7 +385,7 @@ func TestPartitionCommitLoopNoCommitBelowMinISR(t *testing.T) {
 	}, false)
 	require.NoError(t, err)
 	defer p.Close()
-	p.commitQueue = queue.New(5)
+	p.commitQueue = newCommitQueue(5)
 	require.NoError(t, p.RemoveFromISR("b"))
 
 	// Put some messages in the queue.
@@ -461,6 +527,78 @@ func TestPartitionAddToISRNotReplica(t *testing.T) {
 	require.Error(t, p.AddToISR("foo"))
 }
 
+// Ensure ChangeReplicaRole does nothing if the replica already has the role
+// and returns a replicaStateError for changes which no longer apply, and that
+// ISR changes for a demoted replica are ignored.
+func TestPartitionChangeReplicaRole(t *testing.T) {
+	defer cleanupStorage(t)
+	server := createServer(false)
+	p, err := server.newPartition(&proto.Partition{
+		Subject:  "foo",
+		Stream:   "foo",
+		Replicas: []string{"a", "b", "c"},
+		Leader:   "a",
+		Isr:      []string{"a", "b", "c"},
+	}, false)
+	require.NoError(t, err)
+	defer p.Close()
+
+	require.NoError(t, p.ChangeReplicaRole("b", true))
+	require.True(t, p.isObserver("b"))
+	require.NotContains(t, p.GetISR(), "b")
+
+	// Demoting again does nothing.
+	require.NoError(t, p.ChangeReplicaRole("b", true))
+	require.True(t, p.isObserver("b"))
+
+	// ISR changes requested before the demotion are ignored.
+	require.NoError(t, p.RemoveFromISR("b"))
+	require.NoError(t, p.AddToISR("b"))
+	require.NotContains(t, p.GetISR(), "b")
+
+	// The leader can't be demoted.
+	err = p.ChangeReplicaRole("a", true)
+	require.True(t, isReplicaStateError(err))
+	require.False(t, p.isObserver("a"))
+
+	// Unknown servers can't be promoted or demoted.
+	require.True(t, isReplicaStateError(p.ChangeReplicaRole("d", true)))
+	require.True(t, isReplicaStateError(p.ChangeReplicaRole("d", false)))
+
+	// Promoting twice does nothing.
+	require.NoError(t, p.ChangeReplicaRole("b", false))
+	require.NoError(t, p.ChangeReplicaRole("b", false))
+	require.True(t, p.isReplica("b"))
+}
+
+// Ensure ChangeReplicas does nothing if the replicas are unchanged and returns
+// a replicaStateError if the change would remove the leader or add an
+// observer.
+func TestPartitionChangeReplicas(t *testing.T) {
+	defer cleanupStorage(t)
+	server := createServer(false)
+	p, err := server.newPartition(&proto.Partition{
+		Subject:   "foo",
+		Stream:    "foo",
+		Replicas:  []string{"a", "b"},
+		Leader:    "a",
+		Isr:       []string{"a", "b"},
+		Observers: []string{"c"},
+	}, false)
+	require.NoError(t, err)
+	defer p.Close()
+
+	require.NoError(t, p.ChangeReplicas([]string{"a", "b"}))
+	require.NoError(t, p.ChangeReplicas([]string{"a", "b"}))
+	require.ElementsMatch(t, []string{"a", "b"}, p.GetISR())
+
+	err = p.ChangeReplicas([]string{"b"})
+	require.True(t, isReplicaStateError(err))
+	err = p.ChangeReplicas([]string{"a", "c"})
+	require.True(t, isReplicaStateError(err))
+	require.True(t, p.isReplica("b"))
+}
+
 // Ensure AddToISR adds the replica to the ISR.
 func TestPartitionAddToISR(t *testing.T) {
 	defer cleanupStorage(t)
@@ -571,3 +709,30 @@ func TestPartitionReplicationRequestLoopPreempt(t *testing.T) {
 		t.Fatal("Expected replication request")
 	}
 }
+
+// Ensure the replica fetch sleep stays positive and within the max idle wait
+// whatever the wait is.
+func TestPartitionComputeReplicaFetchSleep(t *testing.T) {
+	defer cleanupStorage(t)
+	server := createServer(false)
+	p, err := server.newPartition(&proto.Partition{
+		Subject: "foo",
+		Stream:  "foo",
+	}, false)
+	require.NoError(t, err)
+	defer p.Close()
+
+	for _, wait := range []time.Duration{0, time.Microsecond, 2 * time.Millisecond, time.Second} {
+		server.config.Clustering.ReplicaMaxIdleWait = wait
+		max := wait
+		if max < time.Millisecond {
+			max = time.Millisecond
+		}
+		for i := 0; i < 100; i++ {
+			sleep := p.computeReplicaFetchSleep()
+			require.True(t, sleep >= time.Millisecond, "wait %s slept %s", wait, sleep)
+			require.True(t, sleep <= max, "wait %s slept %s", wait, sleep)
+			require.True(t, sleep >= max*4/5, "wait %s slept %s", wait, sleep)
+		}
+	}
+}
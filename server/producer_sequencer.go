@@ -0,0 +1,252 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// errSequenceTooLow is returned when a producer session publishes a
+	// sequence number which was already published or skipped.
+	errSequenceTooLow = errors.New("sequence number already published or skipped")
+
+	// producerSequenceGapTimeout is how long a publish waits for the
+	// preceding sequence numbers of its producer session before they are
+	// skipped, e.g. because the publishes carrying them failed before
+	// reaching the server.
+	producerSequenceGapTimeout = 5 * time.Second
+
+	// producerSessionTimeout is how long an idle producer session is kept.
+	producerSessionTimeout = 10 * time.Minute
+)
+
+// producerSequencer orders publishes from producer sessions. Each publish in
+// a session carries a sequence number starting at 0, and a publish is only
+// forwarded once every lower sequence number of its session has been. Since
+// publishes are forwarded to the partition leader over a single NATS
+// connection, which preserves order, messages are appended to the log in the
+// order the producer sent them even when the producer publishes concurrently.
+// Publishes of sequence numbers already forwarded are rejected as duplicates.
+//
+// At most maxSessions sessions are tracked. Past that, the least recently used
+// session which has no publish in progress is evicted. The next sequence
+// number of up to maxSessions evicted sessions is kept so that an evicted
+// session which publishes again resumes where it left off rather than waiting
+// out the gap timeout. Once that is dropped too, the next publish of the
+// session starts it over as a new session.
+type producerSequencer struct {
+	mu          sync.Mutex
+	sessions    map[string]*producerSession
+	lru         *list.List // Sessions from most to least recently used
+	evicted     map[string]*list.Element
+	evictedLRU  *list.List // Evicted sessions from most to least recently evicted
+	maxSessions int
+	lastPrune   time.Time
+	duplicates  int64 // Publishes rejected as duplicates
+	evictions   int64 // Sessions evicted to stay within maxSessions
+}
+
+// producerSession tracks the next sequence number to forward for a producer
+// session.
+type producerSession struct {
+	id       string
+	next     uint64
+	skipped  map[uint64]struct{} // Sequence numbers after next which were skipped
+	lastUsed time.Time
+	changed  chan struct{} // Closed when next changes
+	refs     int           // Publishes in progress, which keep it from being evicted
+	elem     *list.Element
+}
+
+// evictedSession is the next sequence number of an evicted producer session.
+type evictedSession struct {
+	id   string
+	next uint64
+}
+
+func newProducerSequencer(maxSessions int) *producerSequencer {
+	return &producerSequencer{
+		sessions:    make(map[string]*producerSession),
+		lru:         list.New(),
+		evicted:     make(map[string]*list.Element),
+		evictedLRU:  list.New(),
+		maxSessions: maxSessions,
+		lastPrune:   time.Now(),
+	}
+}
+
+// acquire blocks until it's the turn of the given sequence number of the
+// producer session. The returned function must be called once the publish has
+// been forwarded to let the next sequence number through. If lower sequence
+// numbers do not arrive within producerSequenceGapTimeout, they are skipped.
+// errSequenceTooLow is returned if the sequence number was already published
+// or skipped, and the context error is returned if the context is done first.
+func (p *producerSequencer) acquire(ctx context.Context, id string, seq uint64) (func(), error) {
+	gap := time.NewTimer(producerSequenceGapTimeout)
+	defer gap.Stop()
+	p.mu.Lock()
+	session := p.getSession(id)
+	session.refs++
+	p.mu.Unlock()
+	for {
+		p.mu.Lock()
+		if seq < session.next {
+			session.refs--
+			p.duplicates++
+			p.mu.Unlock()
+			return nil, errSequenceTooLow
+		}
+		if seq == session.next {
+			p.mu.Unlock()
+			return func() { p.release(session, seq) }, nil
+		}
+		changed := session.changed
+		p.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-gap.C:
+			p.mu.Lock()
+			if session.next < seq {
+				p.advance(session, seq)
+			}
+			p.mu.Unlock()
+		case <-ctx.Done():
+			p.mu.Lock()
+			session.refs--
+			p.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release lets the sequence number after the given one through.
+func (p *producerSequencer) release(session *producerSession, seq uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	session.refs--
+	if session.next == seq {
+		p.advance(session, seq+1)
+	}
+}
+
+// skip marks the given sequence number of the producer session as used without
+// acquiring it, e.g. because its publish was rejected before being forwarded,
+// so that later sequence numbers don't wait on it. It does nothing if the
+// sequence number was already let through or skipped.
+func (p *producerSequencer) skip(id string, seq uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	session := p.getSession(id)
+	switch {
+	case seq < session.next:
+	case seq == session.next:
+		p.advance(session, seq+1)
+	default:
+		if session.skipped == nil {
+			session.skipped = make(map[uint64]struct{})
+		}
+		session.skipped[seq] = struct{}{}
+	}
+}
+
+// advance sets the next sequence number of the producer session to let
+// through, moving past any skipped ones, and wakes up waiting acquires. This
+// must be called with the lock held.
+func (p *producerSequencer) advance(session *producerSession, next uint64) {
+	session.next = next
+	for {
+		if _, ok := session.skipped[session.next]; !ok {
+			break
+		}
+		session.next++
+	}
+	for seq := range session.skipped {
+		if seq < session.next {
+			delete(session.skipped, seq)
+		}
+	}
+	close(session.changed)
+	session.changed = make(chan struct{})
+}
+
+// getSession returns the producer session with the given ID, creating it if
+// needed, and marks it most recently used. A session which was evicted is
+// recreated at its next sequence number. This must be called with the lock
+// held.
+func (p *producerSequencer) getSession(id string) *producerSession {
+	now := time.Now()
+	session, ok := p.sessions[id]
+	if !ok {
+		session = &producerSession{id: id, changed: make(chan struct{})}
+		if elem, ok := p.evicted[id]; ok {
+			session.next = elem.Value.(*evictedSession).next
+			p.evictedLRU.Remove(elem)
+			delete(p.evicted, id)
+		}
+		p.prune(now)
+		p.evict()
+		session.elem = p.lru.PushFront(session)
+		p.sessions[id] = session
+	} else {
+		p.lru.MoveToFront(session.elem)
+	}
+	session.lastUsed = now
+	return session
+}
+
+// evict removes the least recently used producer sessions without publishes
+// in progress until there is room for a new session. If every session has a
+// publish in progress, the limit is exceeded until they finish. This must be
+// called with the lock held.
+func (p *producerSequencer) evict() {
+	elem := p.lru.Back()
+	for len(p.sessions) >= p.maxSessions && elem != nil {
+		session := elem.Value.(*producerSession)
+		elem = elem.Prev()
+		if session.refs > 0 {
+			continue
+		}
+		p.remove(session)
+		p.evictions++
+	}
+}
+
+// remove stops tracking the producer session, keeping its next sequence
+// number in case it publishes again. This must be called with the lock held.
+func (p *producerSequencer) remove(session *producerSession) {
+	delete(p.sessions, session.id)
+	p.lru.Remove(session.elem)
+	p.evicted[session.id] = p.evictedLRU.PushFront(
+		&evictedSession{id: session.id, next: session.next})
+	if p.evictedLRU.Len() > p.maxSessions {
+		oldest := p.evictedLRU.Remove(p.evictedLRU.Back()).(*evictedSession)
+		delete(p.evicted, oldest.id)
+	}
+}
+
+// stats returns the number of publishes rejected as duplicates and the number
+// of producer sessions evicted to stay within maxSessions.
+func (p *producerSequencer) stats() (duplicates, evictions int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.duplicates, p.evictions
+}
+
+// prune removes producer sessions which have been idle for
+// producerSessionTimeout. It scans the sessions at most once per timeout. This
+// must be called with the lock held.
+func (p *producerSequencer) prune(now time.Time) {
+	if now.Sub(p.lastPrune) < producerSessionTimeout {
+		return
+	}
+	p.lastPrune = now
+	for _, session := range p.sessions {
+		if session.refs == 0 && now.Sub(session.lastUsed) >= producerSessionTimeout {
+			p.remove(session)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Ensure the producer sequencer lets concurrent acquires through in sequence
+// order, skips missing sequence numbers after the gap timeout, and rejects
+// sequence numbers which were already let through.
+func TestProducerSequencerOrder(t *testing.T) {
+	defer func(timeout time.Duration) {
+		producerSequenceGapTimeout = timeout
+	}(producerSequenceGapTimeout)
+	producerSequenceGapTimeout = 50 * time.Millisecond
+
+	var (
+		sequencer = newProducerSequencer(defaultProducerMaxSessions)
+		mu        sync.Mutex
+		order     []uint64
+		wg        sync.WaitGroup
+	)
+	for _, seq := range []uint64{4, 2, 0, 3, 1} {
+		wg.Add(1)
+		go func(seq uint64) {
+			defer wg.Done()
+			release, err := sequencer.acquire(context.Background(), "p", seq)
+			require.NoError(t, err)
+			mu.Lock()
+			order = append(order, seq)
+			mu.Unlock()
+			release()
+		}(seq)
+	}
+	wg.Wait()
+	require.Equal(t, []uint64{0, 1, 2, 3, 4}, order)
+
+	// Sequence number 5 is missing, so 6 is let through after the gap timeout.
+	release, err := sequencer.acquire(context.Background(), "p", 6)
+	require.NoError(t, err)
+	release()
+
+	_, err = sequencer.acquire(context.Background(), "p", 5)
+	require.Equal(t, errSequenceTooLow, err)
+
+	// Sessions are independent.
+	release, err = sequencer.acquire(context.Background(), "q", 0)
+	require.NoError(t, err)
+	release()
+
+	// Acquire returns the context error if it's done first.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sequencer.acquire(ctx, "p", 10)
+	require.Equal(t, context.Canceled, err)
+}
+
+// Ensure skipped sequence numbers don't hold up later ones, whether they're
+// skipped before or after the preceding ones are let through.
+func TestProducerSequencerSkip(t *testing.T) {
+	sequencer := newProducerSequencer(defaultProducerMaxSessions)
+
+	acquire := func(seq uint64) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		release, err := sequencer.acquire(ctx, "p", seq)
+		require.NoError(t, err)
+		release()
+	}
+
+	// The next sequence number is skipped.
+	sequencer.skip("p", 0)
+	acquire(1)
+
+	// A later sequence number is skipped before the preceding one.
+	sequencer.skip("p", 3)
+	acquire(2)
+	acquire(4)
+
+	// Skipping a sequence number already let through does nothing.
+	sequencer.skip("p", 4)
+	acquire(5)
+
+	_, err := sequencer.acquire(context.Background(), "p", 3)
+	require.Equal(t, errSequenceTooLow, err)
+}
+
+// Ensure the producer sequencer evicts the least recently used session without
+// publishes in progress once it tracks the maximum number of sessions, and an
+// evicted session resumes where it left off.
+func TestProducerSequencerEviction(t *testing.T) {
+	sequencer := newProducerSequencer(2)
+
+	acquire := func(id string, seq uint64) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		release, err := sequencer.acquire(ctx, id, seq)
+		if err == nil {
+			release()
+		}
+		return err
+	}
+
+	require.NoError(t, acquire("p", 0))
+	require.NoError(t, acquire("q", 0))
+	require.NoError(t, acquire("p", 1))
+
+	// Tracking r evicts q, the least recently used session.
+	require.NoError(t, acquire("r", 0))
+	duplicates, evictions := sequencer.stats()
+	require.Equal(t, int64(0), duplicates)
+	require.Equal(t, int64(1), evictions)
+
+	// Duplicates of tracked sessions are rejected.
+	require.Equal(t, errSequenceTooLow, acquire("r", 0))
+	duplicates, _ = sequencer.stats()
+	require.Equal(t, int64(1), duplicates)
+
+	// The evicted session resumes where it left off, so its duplicate is
+	// still rejected, and tracking it again evicts p.
+	require.Equal(t, errSequenceTooLow, acquire("q", 0))
+	duplicates, evictions = sequencer.stats()
+	require.Equal(t, int64(2), duplicates)
+	require.Equal(t, int64(2), evictions)
+
+	// A session with a publish in progress isn't evicted.
+	release, err := sequencer.acquire(context.Background(), "r", 1)
+	require.NoError(t, err)
+	require.NoError(t, acquire("s", 0))
+	require.Equal(t, errSequenceTooLow, acquire("r", 0))
+	release()
+	require.Equal(t, errSequenceTooLow, acquire("r", 1))
+	duplicates, evictions = sequencer.stats()
+	require.Equal(t, int64(4), duplicates)
+	require.Equal(t, int64(3), evictions)
+}
+
+// Ensure an evicted session which publishes again continues at its next
+// sequence number without waiting out the gap timeout, and starts over once
+// too many other sessions were evicted since.
+func TestProducerSequencerEvictedSessionResumes(t *testing.T) {
+	sequencer := newProducerSequencer(1)
+
+	acquire := func(id string, seq uint64) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		release, err := sequencer.acquire(ctx, id, seq)
+		if err == nil {
+			release()
+		}
+		return err
+	}
+
+	for seq := uint64(0); seq < 5; seq++ {
+		require.NoError(t, acquire("p", seq))
+	}
+
+	// Tracking q evicts p, which resumes at its next sequence number well
+	// within the gap timeout, evicting q.
+	require.NoError(t, acquire("q", 0))
+	require.NoError(t, acquire("p", 5))
+	require.Equal(t, errSequenceTooLow, acquire("p", 4))
+	_, evictions := sequencer.stats()
+	require.Equal(t, int64(2), evictions)
+
+	// Only maxSessions evicted sessions are remembered, so evicting p and
+	// then r drops q's next sequence number, and q starts over.
+	require.NoError(t, acquire("r", 0))
+	require.NoError(t, acquire("q", 0))
+}
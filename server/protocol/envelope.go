@@ -37,6 +37,8 @@ const (
 	msgTypePartitionStatusResponse
 
 	msgTypePartitionNotification
+
+	msgTypePublishRejection
 )
 
 const (
@@ -134,6 +136,12 @@ func MarshalPartitionNotification(req *PartitionNotification) ([]byte, error) {
 	return marshalEnvelope(req, msgTypePartitionNotification)
 }
 
+// MarshalPublishRejection serializes a PublishRejection protobuf into the
+// Liftbridge envelope wire format.
+func MarshalPublishRejection(req *PublishRejection) ([]byte, error) {
+	return marshalEnvelope(req, msgTypePublishRejection)
+}
+
 // MarshalRaftJoinRequest serializes a RaftJoinRequest protobuf into the
 // Liftbridge envelope wire format.
 func MarshalRaftJoinRequest(req *RaftJoinRequest) ([]byte, error) {
@@ -297,6 +305,16 @@ func UnmarshalPartitionNotification(data []byte) (*PartitionNotification, error)
 	return req, err
 }
 
+// UnmarshalPublishRejection deserializes a Liftbridge PublishRejection
+// envelope into a protobuf message.
+func UnmarshalPublishRejection(data []byte) (*PublishRejection, error) {
+	var (
+		req = new(PublishRejection)
+		err = unmarshalEnvelope(data, req, msgTypePublishRejection)
+	)
+	return req, err
+}
+
 // UnmarshalLeaderEpochOffsetRequest deserializes a Liftbridge
 // LeaderEpochOffsetRequest envelope into a protobuf message.
 func UnmarshalLeaderEpochOffsetRequest(data []byte) (*LeaderEpochOffsetRequest, error) {
@@ -235,6 +235,28 @@ func TestMarshalUnmarshalPartitionNotification(t *testing.T) {
 	require.Equal(t, req, unmarshaled)
 }
 
+// Ensure we can marshal a PublishRejection and then unmarshal it.
+func TestMarshalUnmarshalPublishRejection(t *testing.T) {
+	req := &PublishRejection{
+		Stream:        "foo",
+		Partition:     2,
+		CorrelationId: "bar",
+		Reason:        "log quota exceeded",
+		Code:          8,
+	}
+	envelope, err := MarshalPublishRejection(req)
+	require.NoError(t, err)
+
+	unmarshaled, err := UnmarshalPublishRejection(envelope)
+	require.NoError(t, err)
+
+	require.Equal(t, req, unmarshaled)
+
+	// A rejection is not an ack.
+	_, err = UnmarshalAck(envelope)
+	require.Error(t, err)
+}
+
 // Ensure we can marshal a RaftJoinRequest and then unmarshal it.
 func TestMarshalUnmarshalRaftJoinRequest(t *testing.T) {
 	req := &RaftJoinRequest{
@@ -9,13 +9,25 @@
 
 	It has these top-level messages:
 		ServerState
+		ExportState
+		ImportState
 		RaftLog
 		CreatePartitionOp
+		CreateStreamOp
+		CreateStreamsOp
 		ShrinkISROp
 		ExpandISROp
 		DeleteStreamOp
+		RenameStreamOp
+		SwapStreamsOp
+		TrimStreamOp
 		PauseStreamOp
+		ChangeReplicaRoleOp
+		ChangeReplicasOp
 		ReportLeaderOp
+		ReportActivityOp
+		ConfirmLeaderOp
+		SetServerReadOnlyOp
 		ChangeLeaderOp
 		Partition
 		RaftJoinRequest
@@ -27,11 +39,13 @@
 		PropagatedRequest
 		Error
 		PropagatedResponse
+		CreateStreamsResponse
 		ServerInfoRequest
 		ServerInfoResponse
 		PartitionStatusRequest
 		PartitionStatusResponse
 		PartitionNotification
+		PublishRejection
 */
 package protocol
 
@@ -55,32 +69,62 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 type Op int32
 
 const (
-	Op_CREATE_PARTITION Op = 0
-	Op_SHRINK_ISR       Op = 1
-	Op_REPORT_LEADER    Op = 2
-	Op_CHANGE_LEADER    Op = 3
-	Op_EXPAND_ISR       Op = 4
-	Op_DELETE_STREAM    Op = 5
-	Op_PAUSE_STREAM     Op = 6
+	Op_CREATE_PARTITION     Op = 0
+	Op_SHRINK_ISR           Op = 1
+	Op_REPORT_LEADER        Op = 2
+	Op_CHANGE_LEADER        Op = 3
+	Op_EXPAND_ISR           Op = 4
+	Op_DELETE_STREAM        Op = 5
+	Op_PAUSE_STREAM         Op = 6
+	Op_CREATE_STREAM        Op = 7
+	Op_RENAME_STREAM        Op = 8
+	Op_TRIM_STREAM          Op = 9
+	Op_CREATE_STREAMS       Op = 10
+	Op_CHANGE_REPLICA_ROLE  Op = 11
+	Op_REPORT_ACTIVITY      Op = 12
+	Op_SET_SERVER_READ_ONLY Op = 13
+	Op_CHANGE_REPLICAS      Op = 14
+	Op_SWAP_STREAMS         Op = 15
+	Op_CONFIRM_LEADER       Op = 16
 )
 
 var Op_name = map[int32]string{
-	0: "CREATE_PARTITION",
-	1: "SHRINK_ISR",
-	2: "REPORT_LEADER",
-	3: "CHANGE_LEADER",
-	4: "EXPAND_ISR",
-	5: "DELETE_STREAM",
-	6: "PAUSE_STREAM",
+	0:  "CREATE_PARTITION",
+	1:  "SHRINK_ISR",
+	2:  "REPORT_LEADER",
+	3:  "CHANGE_LEADER",
+	4:  "EXPAND_ISR",
+	5:  "DELETE_STREAM",
+	6:  "PAUSE_STREAM",
+	7:  "CREATE_STREAM",
+	8:  "RENAME_STREAM",
+	9:  "TRIM_STREAM",
+	10: "CREATE_STREAMS",
+	11: "CHANGE_REPLICA_ROLE",
+	12: "REPORT_ACTIVITY",
+	13: "SET_SERVER_READ_ONLY",
+	14: "CHANGE_REPLICAS",
+	15: "SWAP_STREAMS",
+	16: "CONFIRM_LEADER",
 }
 var Op_value = map[string]int32{
-	"CREATE_PARTITION": 0,
-	"SHRINK_ISR":       1,
-	"REPORT_LEADER":    2,
-	"CHANGE_LEADER":    3,
-	"EXPAND_ISR":       4,
-	"DELETE_STREAM":    5,
-	"PAUSE_STREAM":     6,
+	"CREATE_PARTITION":     0,
+	"SHRINK_ISR":           1,
+	"REPORT_LEADER":        2,
+	"CHANGE_LEADER":        3,
+	"EXPAND_ISR":           4,
+	"DELETE_STREAM":        5,
+	"PAUSE_STREAM":         6,
+	"CREATE_STREAM":        7,
+	"RENAME_STREAM":        8,
+	"TRIM_STREAM":          9,
+	"CREATE_STREAMS":       10,
+	"CHANGE_REPLICA_ROLE":  11,
+	"REPORT_ACTIVITY":      12,
+	"SET_SERVER_READ_ONLY": 13,
+	"CHANGE_REPLICAS":      14,
+	"SWAP_STREAMS":         15,
+	"CONFIRM_LEADER":       16,
 }
 
 func (x Op) String() string {
@@ -88,6 +132,31 @@ func (x Op) String() string {
 }
 func (Op) EnumDescriptor() ([]byte, []int) { return fileDescriptorInternal, []int{0} }
 
+// EmptyValue determines how a partition handles messages with an empty value.
+type EmptyValue int32
+
+const (
+	EmptyValue_STORE     EmptyValue = 0
+	EmptyValue_REJECT    EmptyValue = 1
+	EmptyValue_TOMBSTONE EmptyValue = 2
+)
+
+var EmptyValue_name = map[int32]string{
+	0: "STORE",
+	1: "REJECT",
+	2: "TOMBSTONE",
+}
+var EmptyValue_value = map[string]int32{
+	"STORE":     0,
+	"REJECT":    1,
+	"TOMBSTONE": 2,
+}
+
+func (x EmptyValue) String() string {
+	return proto.EnumName(EmptyValue_name, int32(x))
+}
+func (EmptyValue) EnumDescriptor() ([]byte, []int) { return fileDescriptorInternal, []int{1} }
+
 type ServerState struct {
 	ServerID string `protobuf:"bytes,1,opt,name=serverID,proto3" json:"serverID,omitempty"`
 }
@@ -104,20 +173,125 @@ func (m *ServerState) GetServerID() string {
 	return ""
 }
 
+type ExportState struct {
+	Name      string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Stream    string `protobuf:"bytes,2,opt,name=stream,proto3" json:"stream,omitempty"`
+	Partition int32  `protobuf:"varint,3,opt,name=partition,proto3" json:"partition,omitempty"`
+	Subject   string `protobuf:"bytes,4,opt,name=subject,proto3" json:"subject,omitempty"`
+	Offset    int64  `protobuf:"varint,5,opt,name=offset,proto3" json:"offset,omitempty"`
+}
+
+func (m *ExportState) Reset()                    { *m = ExportState{} }
+func (m *ExportState) String() string            { return proto.CompactTextString(m) }
+func (*ExportState) ProtoMessage()               {}
+func (*ExportState) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{1} }
+
+func (m *ExportState) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ExportState) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *ExportState) GetPartition() int32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *ExportState) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *ExportState) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+type ImportState struct {
+	Name      string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Subject   string `protobuf:"bytes,2,opt,name=subject,proto3" json:"subject,omitempty"`
+	Stream    string `protobuf:"bytes,3,opt,name=stream,proto3" json:"stream,omitempty"`
+	Partition int32  `protobuf:"varint,4,opt,name=partition,proto3" json:"partition,omitempty"`
+	Position  int64  `protobuf:"varint,5,opt,name=position,proto3" json:"position,omitempty"`
+}
+
+func (m *ImportState) Reset()                    { *m = ImportState{} }
+func (m *ImportState) String() string            { return proto.CompactTextString(m) }
+func (*ImportState) ProtoMessage()               {}
+func (*ImportState) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{2} }
+
+func (m *ImportState) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ImportState) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *ImportState) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *ImportState) GetPartition() int32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *ImportState) GetPosition() int64 {
+	if m != nil {
+		return m.Position
+	}
+	return 0
+}
+
 type RaftLog struct {
-	Op                Op                 `protobuf:"varint,1,opt,name=op,proto3,enum=protocol.Op" json:"op,omitempty"`
-	CreatePartitionOp *CreatePartitionOp `protobuf:"bytes,2,opt,name=createPartitionOp" json:"createPartitionOp,omitempty"`
-	ShrinkISROp       *ShrinkISROp       `protobuf:"bytes,3,opt,name=shrinkISROp" json:"shrinkISROp,omitempty"`
-	ChangeLeaderOp    *ChangeLeaderOp    `protobuf:"bytes,4,opt,name=changeLeaderOp" json:"changeLeaderOp,omitempty"`
-	ExpandISROp       *ExpandISROp       `protobuf:"bytes,5,opt,name=expandISROp" json:"expandISROp,omitempty"`
-	DeleteStreamOp    *DeleteStreamOp    `protobuf:"bytes,6,opt,name=deleteStreamOp" json:"deleteStreamOp,omitempty"`
-	PauseStreamOp     *PauseStreamOp     `protobuf:"bytes,7,opt,name=pauseStreamOp" json:"pauseStreamOp,omitempty"`
+	Op                  Op                   `protobuf:"varint,1,opt,name=op,proto3,enum=protocol.Op" json:"op,omitempty"`
+	CreatePartitionOp   *CreatePartitionOp   `protobuf:"bytes,2,opt,name=createPartitionOp" json:"createPartitionOp,omitempty"`
+	ShrinkISROp         *ShrinkISROp         `protobuf:"bytes,3,opt,name=shrinkISROp" json:"shrinkISROp,omitempty"`
+	ChangeLeaderOp      *ChangeLeaderOp      `protobuf:"bytes,4,opt,name=changeLeaderOp" json:"changeLeaderOp,omitempty"`
+	ExpandISROp         *ExpandISROp         `protobuf:"bytes,5,opt,name=expandISROp" json:"expandISROp,omitempty"`
+	DeleteStreamOp      *DeleteStreamOp      `protobuf:"bytes,6,opt,name=deleteStreamOp" json:"deleteStreamOp,omitempty"`
+	PauseStreamOp       *PauseStreamOp       `protobuf:"bytes,7,opt,name=pauseStreamOp" json:"pauseStreamOp,omitempty"`
+	CreateStreamOp      *CreateStreamOp      `protobuf:"bytes,8,opt,name=createStreamOp" json:"createStreamOp,omitempty"`
+	RenameStreamOp      *RenameStreamOp      `protobuf:"bytes,9,opt,name=renameStreamOp" json:"renameStreamOp,omitempty"`
+	TrimStreamOp        *TrimStreamOp        `protobuf:"bytes,10,opt,name=trimStreamOp" json:"trimStreamOp,omitempty"`
+	CreateStreamsOp     *CreateStreamsOp     `protobuf:"bytes,11,opt,name=createStreamsOp" json:"createStreamsOp,omitempty"`
+	ChangeReplicaRoleOp *ChangeReplicaRoleOp `protobuf:"bytes,12,opt,name=changeReplicaRoleOp" json:"changeReplicaRoleOp,omitempty"`
+	SetServerReadOnlyOp *SetServerReadOnlyOp `protobuf:"bytes,13,opt,name=setServerReadOnlyOp" json:"setServerReadOnlyOp,omitempty"`
+	ChangeReplicasOp    *ChangeReplicasOp    `protobuf:"bytes,14,opt,name=changeReplicasOp" json:"changeReplicasOp,omitempty"`
+	SwapStreamsOp       *SwapStreamsOp       `protobuf:"bytes,15,opt,name=swapStreamsOp" json:"swapStreamsOp,omitempty"`
+	FencingToken        uint64               `protobuf:"varint,16,opt,name=fencingToken,proto3" json:"fencingToken,omitempty"`
 }
 
 func (m *RaftLog) Reset()                    { *m = RaftLog{} }
 func (m *RaftLog) String() string            { return proto.CompactTextString(m) }
 func (*RaftLog) ProtoMessage()               {}
-func (*RaftLog) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{1} }
+func (*RaftLog) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{3} }
 
 func (m *RaftLog) GetOp() Op {
 	if m != nil {
@@ -168,6 +342,69 @@ func (m *RaftLog) GetPauseStreamOp() *PauseStreamOp {
 	return nil
 }
 
+func (m *RaftLog) GetCreateStreamOp() *CreateStreamOp {
+	if m != nil {
+		return m.CreateStreamOp
+	}
+	return nil
+}
+
+func (m *RaftLog) GetRenameStreamOp() *RenameStreamOp {
+	if m != nil {
+		return m.RenameStreamOp
+	}
+	return nil
+}
+
+func (m *RaftLog) GetTrimStreamOp() *TrimStreamOp {
+	if m != nil {
+		return m.TrimStreamOp
+	}
+	return nil
+}
+
+func (m *RaftLog) GetCreateStreamsOp() *CreateStreamsOp {
+	if m != nil {
+		return m.CreateStreamsOp
+	}
+	return nil
+}
+
+func (m *RaftLog) GetChangeReplicaRoleOp() *ChangeReplicaRoleOp {
+	if m != nil {
+		return m.ChangeReplicaRoleOp
+	}
+	return nil
+}
+
+func (m *RaftLog) GetSetServerReadOnlyOp() *SetServerReadOnlyOp {
+	if m != nil {
+		return m.SetServerReadOnlyOp
+	}
+	return nil
+}
+
+func (m *RaftLog) GetChangeReplicasOp() *ChangeReplicasOp {
+	if m != nil {
+		return m.ChangeReplicasOp
+	}
+	return nil
+}
+
+func (m *RaftLog) GetSwapStreamsOp() *SwapStreamsOp {
+	if m != nil {
+		return m.SwapStreamsOp
+	}
+	return nil
+}
+
+func (m *RaftLog) GetFencingToken() uint64 {
+	if m != nil {
+		return m.FencingToken
+	}
+	return 0
+}
+
 type CreatePartitionOp struct {
 	Partition *Partition `protobuf:"bytes,1,opt,name=partition" json:"partition,omitempty"`
 }
@@ -175,7 +412,7 @@ type CreatePartitionOp struct {
 func (m *CreatePartitionOp) Reset()                    { *m = CreatePartitionOp{} }
 func (m *CreatePartitionOp) String() string            { return proto.CompactTextString(m) }
 func (*CreatePartitionOp) ProtoMessage()               {}
-func (*CreatePartitionOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{2} }
+func (*CreatePartitionOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{4} }
 
 func (m *CreatePartitionOp) GetPartition() *Partition {
 	if m != nil {
@@ -184,6 +421,54 @@ func (m *CreatePartitionOp) GetPartition() *Partition {
 	return nil
 }
 
+type CreateStreamOp struct {
+	Partitions []*Partition `protobuf:"bytes,1,rep,name=partitions" json:"partitions,omitempty"`
+	Servers    []string     `protobuf:"bytes,2,rep,name=servers" json:"servers,omitempty"`
+	Observers  []string     `protobuf:"bytes,3,rep,name=observers" json:"observers,omitempty"`
+}
+
+func (m *CreateStreamOp) Reset()                    { *m = CreateStreamOp{} }
+func (m *CreateStreamOp) String() string            { return proto.CompactTextString(m) }
+func (*CreateStreamOp) ProtoMessage()               {}
+func (*CreateStreamOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{5} }
+
+func (m *CreateStreamOp) GetPartitions() []*Partition {
+	if m != nil {
+		return m.Partitions
+	}
+	return nil
+}
+
+func (m *CreateStreamOp) GetServers() []string {
+	if m != nil {
+		return m.Servers
+	}
+	return nil
+}
+
+func (m *CreateStreamOp) GetObservers() []string {
+	if m != nil {
+		return m.Observers
+	}
+	return nil
+}
+
+type CreateStreamsOp struct {
+	Streams []*CreateStreamOp `protobuf:"bytes,1,rep,name=streams" json:"streams,omitempty"`
+}
+
+func (m *CreateStreamsOp) Reset()                    { *m = CreateStreamsOp{} }
+func (m *CreateStreamsOp) String() string            { return proto.CompactTextString(m) }
+func (*CreateStreamsOp) ProtoMessage()               {}
+func (*CreateStreamsOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{6} }
+
+func (m *CreateStreamsOp) GetStreams() []*CreateStreamOp {
+	if m != nil {
+		return m.Streams
+	}
+	return nil
+}
+
 type ShrinkISROp struct {
 	Stream          string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
 	Partition       int32  `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
@@ -195,7 +480,7 @@ type ShrinkISROp struct {
 func (m *ShrinkISROp) Reset()                    { *m = ShrinkISROp{} }
 func (m *ShrinkISROp) String() string            { return proto.CompactTextString(m) }
 func (*ShrinkISROp) ProtoMessage()               {}
-func (*ShrinkISROp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{3} }
+func (*ShrinkISROp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{7} }
 
 func (m *ShrinkISROp) GetStream() string {
 	if m != nil {
@@ -243,7 +528,7 @@ type ExpandISROp struct {
 func (m *ExpandISROp) Reset()                    { *m = ExpandISROp{} }
 func (m *ExpandISROp) String() string            { return proto.CompactTextString(m) }
 func (*ExpandISROp) ProtoMessage()               {}
-func (*ExpandISROp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{4} }
+func (*ExpandISROp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{8} }
 
 func (m *ExpandISROp) GetStream() string {
 	if m != nil {
@@ -287,7 +572,7 @@ type DeleteStreamOp struct {
 func (m *DeleteStreamOp) Reset()                    { *m = DeleteStreamOp{} }
 func (m *DeleteStreamOp) String() string            { return proto.CompactTextString(m) }
 func (*DeleteStreamOp) ProtoMessage()               {}
-func (*DeleteStreamOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{5} }
+func (*DeleteStreamOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{9} }
 
 func (m *DeleteStreamOp) GetStream() string {
 	if m != nil {
@@ -296,6 +581,78 @@ func (m *DeleteStreamOp) GetStream() string {
 	return ""
 }
 
+type RenameStreamOp struct {
+	Stream  string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	NewName string `protobuf:"bytes,2,opt,name=newName,proto3" json:"newName,omitempty"`
+}
+
+func (m *RenameStreamOp) Reset()                    { *m = RenameStreamOp{} }
+func (m *RenameStreamOp) String() string            { return proto.CompactTextString(m) }
+func (*RenameStreamOp) ProtoMessage()               {}
+func (*RenameStreamOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{10} }
+
+func (m *RenameStreamOp) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *RenameStreamOp) GetNewName() string {
+	if m != nil {
+		return m.NewName
+	}
+	return ""
+}
+
+type SwapStreamsOp struct {
+	Stream string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	Other  string `protobuf:"bytes,2,opt,name=other,proto3" json:"other,omitempty"`
+}
+
+func (m *SwapStreamsOp) Reset()                    { *m = SwapStreamsOp{} }
+func (m *SwapStreamsOp) String() string            { return proto.CompactTextString(m) }
+func (*SwapStreamsOp) ProtoMessage()               {}
+func (*SwapStreamsOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{11} }
+
+func (m *SwapStreamsOp) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *SwapStreamsOp) GetOther() string {
+	if m != nil {
+		return m.Other
+	}
+	return ""
+}
+
+type TrimStreamOp struct {
+	Stream string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	Offset int64  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+}
+
+func (m *TrimStreamOp) Reset()                    { *m = TrimStreamOp{} }
+func (m *TrimStreamOp) String() string            { return proto.CompactTextString(m) }
+func (*TrimStreamOp) ProtoMessage()               {}
+func (*TrimStreamOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{12} }
+
+func (m *TrimStreamOp) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *TrimStreamOp) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
 type PauseStreamOp struct {
 	Stream     string  `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
 	Partitions []int32 `protobuf:"varint,2,rep,packed,name=partitions" json:"partitions,omitempty"`
@@ -305,7 +662,7 @@ type PauseStreamOp struct {
 func (m *PauseStreamOp) Reset()                    { *m = PauseStreamOp{} }
 func (m *PauseStreamOp) String() string            { return proto.CompactTextString(m) }
 func (*PauseStreamOp) ProtoMessage()               {}
-func (*PauseStreamOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{6} }
+func (*PauseStreamOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{13} }
 
 func (m *PauseStreamOp) GetStream() string {
 	if m != nil {
@@ -328,6 +685,78 @@ func (m *PauseStreamOp) GetResumeAll() bool {
 	return false
 }
 
+type ChangeReplicaRoleOp struct {
+	Stream    string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	Partition int32  `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	Replica   string `protobuf:"bytes,3,opt,name=replica,proto3" json:"replica,omitempty"`
+	Observer  bool   `protobuf:"varint,4,opt,name=observer,proto3" json:"observer,omitempty"`
+}
+
+func (m *ChangeReplicaRoleOp) Reset()                    { *m = ChangeReplicaRoleOp{} }
+func (m *ChangeReplicaRoleOp) String() string            { return proto.CompactTextString(m) }
+func (*ChangeReplicaRoleOp) ProtoMessage()               {}
+func (*ChangeReplicaRoleOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{14} }
+
+func (m *ChangeReplicaRoleOp) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *ChangeReplicaRoleOp) GetPartition() int32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *ChangeReplicaRoleOp) GetReplica() string {
+	if m != nil {
+		return m.Replica
+	}
+	return ""
+}
+
+func (m *ChangeReplicaRoleOp) GetObserver() bool {
+	if m != nil {
+		return m.Observer
+	}
+	return false
+}
+
+type ChangeReplicasOp struct {
+	Stream    string   `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	Partition int32    `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	Replicas  []string `protobuf:"bytes,3,rep,name=replicas" json:"replicas,omitempty"`
+}
+
+func (m *ChangeReplicasOp) Reset()                    { *m = ChangeReplicasOp{} }
+func (m *ChangeReplicasOp) String() string            { return proto.CompactTextString(m) }
+func (*ChangeReplicasOp) ProtoMessage()               {}
+func (*ChangeReplicasOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{15} }
+
+func (m *ChangeReplicasOp) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *ChangeReplicasOp) GetPartition() int32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *ChangeReplicasOp) GetReplicas() []string {
+	if m != nil {
+		return m.Replicas
+	}
+	return nil
+}
+
 type ReportLeaderOp struct {
 	Stream      string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
 	Partition   int32  `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
@@ -339,7 +768,7 @@ type ReportLeaderOp struct {
 func (m *ReportLeaderOp) Reset()                    { *m = ReportLeaderOp{} }
 func (m *ReportLeaderOp) String() string            { return proto.CompactTextString(m) }
 func (*ReportLeaderOp) ProtoMessage()               {}
-func (*ReportLeaderOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{7} }
+func (*ReportLeaderOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{16} }
 
 func (m *ReportLeaderOp) GetStream() string {
 	if m != nil {
@@ -376,55 +805,158 @@ func (m *ReportLeaderOp) GetLeaderEpoch() uint64 {
 	return 0
 }
 
-type ChangeLeaderOp struct {
+type ReportActivityOp struct {
 	Stream    string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
 	Partition int32  `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
-	Leader    string `protobuf:"bytes,3,opt,name=leader,proto3" json:"leader,omitempty"`
 }
 
-func (m *ChangeLeaderOp) Reset()                    { *m = ChangeLeaderOp{} }
-func (m *ChangeLeaderOp) String() string            { return proto.CompactTextString(m) }
-func (*ChangeLeaderOp) ProtoMessage()               {}
-func (*ChangeLeaderOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{8} }
+func (m *ReportActivityOp) Reset()                    { *m = ReportActivityOp{} }
+func (m *ReportActivityOp) String() string            { return proto.CompactTextString(m) }
+func (*ReportActivityOp) ProtoMessage()               {}
+func (*ReportActivityOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{17} }
 
-func (m *ChangeLeaderOp) GetStream() string {
+func (m *ReportActivityOp) GetStream() string {
 	if m != nil {
 		return m.Stream
 	}
 	return ""
 }
 
-func (m *ChangeLeaderOp) GetPartition() int32 {
+func (m *ReportActivityOp) GetPartition() int32 {
 	if m != nil {
 		return m.Partition
 	}
 	return 0
 }
 
-func (m *ChangeLeaderOp) GetLeader() string {
+type ConfirmLeaderOp struct {
+	Stream      string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	Partition   int32  `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	Leader      string `protobuf:"bytes,3,opt,name=leader,proto3" json:"leader,omitempty"`
+	LeaderEpoch uint64 `protobuf:"varint,4,opt,name=leaderEpoch,proto3" json:"leaderEpoch,omitempty"`
+}
+
+func (m *ConfirmLeaderOp) Reset()                    { *m = ConfirmLeaderOp{} }
+func (m *ConfirmLeaderOp) String() string            { return proto.CompactTextString(m) }
+func (*ConfirmLeaderOp) ProtoMessage()               {}
+func (*ConfirmLeaderOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{18} }
+
+func (m *ConfirmLeaderOp) GetStream() string {
 	if m != nil {
-		return m.Leader
+		return m.Stream
 	}
 	return ""
 }
 
-type Partition struct {
-	Subject           string   `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`
-	Stream            string   `protobuf:"bytes,2,opt,name=stream,proto3" json:"stream,omitempty"`
-	Id                int32    `protobuf:"varint,3,opt,name=id,proto3" json:"id,omitempty"`
-	Group             string   `protobuf:"bytes,4,opt,name=group,proto3" json:"group,omitempty"`
-	ReplicationFactor int32    `protobuf:"varint,5,opt,name=replicationFactor,proto3" json:"replicationFactor,omitempty"`
-	Replicas          []string `protobuf:"bytes,6,rep,name=replicas" json:"replicas,omitempty"`
-	Leader            string   `protobuf:"bytes,7,opt,name=leader,proto3" json:"leader,omitempty"`
-	Isr               []string `protobuf:"bytes,8,rep,name=isr" json:"isr,omitempty"`
-	LeaderEpoch       uint64   `protobuf:"varint,9,opt,name=leaderEpoch,proto3" json:"leaderEpoch,omitempty"`
-	Epoch             uint64   `protobuf:"varint,10,opt,name=epoch,proto3" json:"epoch,omitempty"`
-}
+func (m *ConfirmLeaderOp) GetPartition() int32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *ConfirmLeaderOp) GetLeader() string {
+	if m != nil {
+		return m.Leader
+	}
+	return ""
+}
+
+func (m *ConfirmLeaderOp) GetLeaderEpoch() uint64 {
+	if m != nil {
+		return m.LeaderEpoch
+	}
+	return 0
+}
+
+type SetServerReadOnlyOp struct {
+	Server   string `protobuf:"bytes,1,opt,name=server,proto3" json:"server,omitempty"`
+	ReadOnly bool   `protobuf:"varint,2,opt,name=readOnly,proto3" json:"readOnly,omitempty"`
+}
+
+func (m *SetServerReadOnlyOp) Reset()                    { *m = SetServerReadOnlyOp{} }
+func (m *SetServerReadOnlyOp) String() string            { return proto.CompactTextString(m) }
+func (*SetServerReadOnlyOp) ProtoMessage()               {}
+func (*SetServerReadOnlyOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{19} }
+
+func (m *SetServerReadOnlyOp) GetServer() string {
+	if m != nil {
+		return m.Server
+	}
+	return ""
+}
+
+func (m *SetServerReadOnlyOp) GetReadOnly() bool {
+	if m != nil {
+		return m.ReadOnly
+	}
+	return false
+}
+
+type ChangeLeaderOp struct {
+	Stream    string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	Partition int32  `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	Leader    string `protobuf:"bytes,3,opt,name=leader,proto3" json:"leader,omitempty"`
+}
+
+func (m *ChangeLeaderOp) Reset()                    { *m = ChangeLeaderOp{} }
+func (m *ChangeLeaderOp) String() string            { return proto.CompactTextString(m) }
+func (*ChangeLeaderOp) ProtoMessage()               {}
+func (*ChangeLeaderOp) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{20} }
+
+func (m *ChangeLeaderOp) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *ChangeLeaderOp) GetPartition() int32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *ChangeLeaderOp) GetLeader() string {
+	if m != nil {
+		return m.Leader
+	}
+	return ""
+}
+
+type Partition struct {
+	Subject              string     `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`
+	Stream               string     `protobuf:"bytes,2,opt,name=stream,proto3" json:"stream,omitempty"`
+	Id                   int32      `protobuf:"varint,3,opt,name=id,proto3" json:"id,omitempty"`
+	Group                string     `protobuf:"bytes,4,opt,name=group,proto3" json:"group,omitempty"`
+	ReplicationFactor    int32      `protobuf:"varint,5,opt,name=replicationFactor,proto3" json:"replicationFactor,omitempty"`
+	Replicas             []string   `protobuf:"bytes,6,rep,name=replicas" json:"replicas,omitempty"`
+	Leader               string     `protobuf:"bytes,7,opt,name=leader,proto3" json:"leader,omitempty"`
+	Isr                  []string   `protobuf:"bytes,8,rep,name=isr" json:"isr,omitempty"`
+	LeaderEpoch          uint64     `protobuf:"varint,9,opt,name=leaderEpoch,proto3" json:"leaderEpoch,omitempty"`
+	Epoch                uint64     `protobuf:"varint,10,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	ReplicaMaxLagTime    int64      `protobuf:"varint,11,opt,name=replicaMaxLagTime,proto3" json:"replicaMaxLagTime,omitempty"`
+	ReplicaFetchTimeout  int64      `protobuf:"varint,12,opt,name=replicaFetchTimeout,proto3" json:"replicaFetchTimeout,omitempty"`
+	RequireKey           bool       `protobuf:"varint,13,opt,name=requireKey,proto3" json:"requireKey,omitempty"`
+	EmptyValue           EmptyValue `protobuf:"varint,14,opt,name=emptyValue,proto3,enum=protocol.EmptyValue" json:"emptyValue,omitempty"`
+	Observers            []string   `protobuf:"bytes,15,rep,name=observers" json:"observers,omitempty"`
+	Schema               string     `protobuf:"bytes,16,opt,name=schema,proto3" json:"schema,omitempty"`
+	IdleDeleteTime       int64      `protobuf:"varint,17,opt,name=idleDeleteTime,proto3" json:"idleDeleteTime,omitempty"`
+	ReplicaMaxLagOffsets int64      `protobuf:"varint,18,opt,name=replicaMaxLagOffsets,proto3" json:"replicaMaxLagOffsets,omitempty"`
+	MaxCommitWaiters     int32      `protobuf:"varint,19,opt,name=maxCommitWaiters,proto3" json:"maxCommitWaiters,omitempty"`
+	RetentionMaxAge      int64      `protobuf:"varint,20,opt,name=retentionMaxAge,proto3" json:"retentionMaxAge,omitempty"`
+	QuotaMaxBytes        int64      `protobuf:"varint,21,opt,name=quotaMaxBytes,proto3" json:"quotaMaxBytes,omitempty"`
+	TrimOffset           int64      `protobuf:"varint,22,opt,name=trimOffset,proto3" json:"trimOffset,omitempty"`
+	PinnedServers        []string   `protobuf:"bytes,23,rep,name=pinnedServers" json:"pinnedServers,omitempty"`
+	RetentionMaxSegments int32      `protobuf:"varint,24,opt,name=retentionMaxSegments,proto3" json:"retentionMaxSegments,omitempty"`
+	CompressionThreshold int64      `protobuf:"varint,25,opt,name=compressionThreshold,proto3" json:"compressionThreshold,omitempty"`
+}
 
 func (m *Partition) Reset()                    { *m = Partition{} }
 func (m *Partition) String() string            { return proto.CompactTextString(m) }
 func (*Partition) ProtoMessage()               {}
-func (*Partition) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{9} }
+func (*Partition) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{21} }
 
 func (m *Partition) GetSubject() string {
 	if m != nil {
@@ -496,6 +1028,111 @@ func (m *Partition) GetEpoch() uint64 {
 	return 0
 }
 
+func (m *Partition) GetReplicaMaxLagTime() int64 {
+	if m != nil {
+		return m.ReplicaMaxLagTime
+	}
+	return 0
+}
+
+func (m *Partition) GetReplicaFetchTimeout() int64 {
+	if m != nil {
+		return m.ReplicaFetchTimeout
+	}
+	return 0
+}
+
+func (m *Partition) GetRequireKey() bool {
+	if m != nil {
+		return m.RequireKey
+	}
+	return false
+}
+
+func (m *Partition) GetEmptyValue() EmptyValue {
+	if m != nil {
+		return m.EmptyValue
+	}
+	return EmptyValue_STORE
+}
+
+func (m *Partition) GetObservers() []string {
+	if m != nil {
+		return m.Observers
+	}
+	return nil
+}
+
+func (m *Partition) GetSchema() string {
+	if m != nil {
+		return m.Schema
+	}
+	return ""
+}
+
+func (m *Partition) GetIdleDeleteTime() int64 {
+	if m != nil {
+		return m.IdleDeleteTime
+	}
+	return 0
+}
+
+func (m *Partition) GetReplicaMaxLagOffsets() int64 {
+	if m != nil {
+		return m.ReplicaMaxLagOffsets
+	}
+	return 0
+}
+
+func (m *Partition) GetMaxCommitWaiters() int32 {
+	if m != nil {
+		return m.MaxCommitWaiters
+	}
+	return 0
+}
+
+func (m *Partition) GetRetentionMaxAge() int64 {
+	if m != nil {
+		return m.RetentionMaxAge
+	}
+	return 0
+}
+
+func (m *Partition) GetQuotaMaxBytes() int64 {
+	if m != nil {
+		return m.QuotaMaxBytes
+	}
+	return 0
+}
+
+func (m *Partition) GetTrimOffset() int64 {
+	if m != nil {
+		return m.TrimOffset
+	}
+	return 0
+}
+
+func (m *Partition) GetPinnedServers() []string {
+	if m != nil {
+		return m.PinnedServers
+	}
+	return nil
+}
+
+func (m *Partition) GetRetentionMaxSegments() int32 {
+	if m != nil {
+		return m.RetentionMaxSegments
+	}
+	return 0
+}
+
+func (m *Partition) GetCompressionThreshold() int64 {
+	if m != nil {
+		return m.CompressionThreshold
+	}
+	return 0
+}
+
 // RaftJoinRequest is a request to join a Raft group.
 type RaftJoinRequest struct {
 	NodeID   string `protobuf:"bytes,1,opt,name=nodeID,proto3" json:"nodeID,omitempty"`
@@ -505,7 +1142,7 @@ type RaftJoinRequest struct {
 func (m *RaftJoinRequest) Reset()                    { *m = RaftJoinRequest{} }
 func (m *RaftJoinRequest) String() string            { return proto.CompactTextString(m) }
 func (*RaftJoinRequest) ProtoMessage()               {}
-func (*RaftJoinRequest) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{10} }
+func (*RaftJoinRequest) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{22} }
 
 func (m *RaftJoinRequest) GetNodeID() string {
 	if m != nil {
@@ -529,7 +1166,7 @@ type RaftJoinResponse struct {
 func (m *RaftJoinResponse) Reset()                    { *m = RaftJoinResponse{} }
 func (m *RaftJoinResponse) String() string            { return proto.CompactTextString(m) }
 func (*RaftJoinResponse) ProtoMessage()               {}
-func (*RaftJoinResponse) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{11} }
+func (*RaftJoinResponse) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{23} }
 
 func (m *RaftJoinResponse) GetError() string {
 	if m != nil {
@@ -539,13 +1176,15 @@ func (m *RaftJoinResponse) GetError() string {
 }
 
 type MetadataSnapshot struct {
-	Partitions []*Partition `protobuf:"bytes,1,rep,name=partitions" json:"partitions,omitempty"`
+	Partitions      []*Partition `protobuf:"bytes,1,rep,name=partitions" json:"partitions,omitempty"`
+	ReadOnlyServers []string     `protobuf:"bytes,2,rep,name=readOnlyServers" json:"readOnlyServers,omitempty"`
+	FencingEpoch    uint64       `protobuf:"varint,3,opt,name=fencingEpoch,proto3" json:"fencingEpoch,omitempty"`
 }
 
 func (m *MetadataSnapshot) Reset()                    { *m = MetadataSnapshot{} }
 func (m *MetadataSnapshot) String() string            { return proto.CompactTextString(m) }
 func (*MetadataSnapshot) ProtoMessage()               {}
-func (*MetadataSnapshot) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{12} }
+func (*MetadataSnapshot) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{24} }
 
 func (m *MetadataSnapshot) GetPartitions() []*Partition {
 	if m != nil {
@@ -554,16 +1193,32 @@ func (m *MetadataSnapshot) GetPartitions() []*Partition {
 	return nil
 }
 
+func (m *MetadataSnapshot) GetReadOnlyServers() []string {
+	if m != nil {
+		return m.ReadOnlyServers
+	}
+	return nil
+}
+
+func (m *MetadataSnapshot) GetFencingEpoch() uint64 {
+	if m != nil {
+		return m.FencingEpoch
+	}
+	return 0
+}
+
 type ReplicationRequest struct {
-	ReplicaID   string `protobuf:"bytes,1,opt,name=replicaID,proto3" json:"replicaID,omitempty"`
-	Offset      int64  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
-	LeaderEpoch uint64 `protobuf:"varint,3,opt,name=leaderEpoch,proto3" json:"leaderEpoch,omitempty"`
+	ReplicaID    string `protobuf:"bytes,1,opt,name=replicaID,proto3" json:"replicaID,omitempty"`
+	Offset       int64  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	LeaderEpoch  uint64 `protobuf:"varint,3,opt,name=leaderEpoch,proto3" json:"leaderEpoch,omitempty"`
+	SyncFollower bool   `protobuf:"varint,4,opt,name=syncFollower,proto3" json:"syncFollower,omitempty"`
+	ReportOnly   bool   `protobuf:"varint,5,opt,name=reportOnly,proto3" json:"reportOnly,omitempty"`
 }
 
 func (m *ReplicationRequest) Reset()                    { *m = ReplicationRequest{} }
 func (m *ReplicationRequest) String() string            { return proto.CompactTextString(m) }
 func (*ReplicationRequest) ProtoMessage()               {}
-func (*ReplicationRequest) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{13} }
+func (*ReplicationRequest) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{25} }
 
 func (m *ReplicationRequest) GetReplicaID() string {
 	if m != nil {
@@ -586,6 +1241,20 @@ func (m *ReplicationRequest) GetLeaderEpoch() uint64 {
 	return 0
 }
 
+func (m *ReplicationRequest) GetSyncFollower() bool {
+	if m != nil {
+		return m.SyncFollower
+	}
+	return false
+}
+
+func (m *ReplicationRequest) GetReportOnly() bool {
+	if m != nil {
+		return m.ReportOnly
+	}
+	return false
+}
+
 type LeaderEpochOffsetRequest struct {
 	LeaderEpoch uint64 `protobuf:"varint,1,opt,name=leaderEpoch,proto3" json:"leaderEpoch,omitempty"`
 }
@@ -594,7 +1263,7 @@ func (m *LeaderEpochOffsetRequest) Reset()         { *m = LeaderEpochOffsetReque
 func (m *LeaderEpochOffsetRequest) String() string { return proto.CompactTextString(m) }
 func (*LeaderEpochOffsetRequest) ProtoMessage()    {}
 func (*LeaderEpochOffsetRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptorInternal, []int{14}
+	return fileDescriptorInternal, []int{26}
 }
 
 func (m *LeaderEpochOffsetRequest) GetLeaderEpoch() uint64 {
@@ -612,7 +1281,7 @@ func (m *LeaderEpochOffsetResponse) Reset()         { *m = LeaderEpochOffsetResp
 func (m *LeaderEpochOffsetResponse) String() string { return proto.CompactTextString(m) }
 func (*LeaderEpochOffsetResponse) ProtoMessage()    {}
 func (*LeaderEpochOffsetResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptorInternal, []int{15}
+	return fileDescriptorInternal, []int{27}
 }
 
 func (m *LeaderEpochOffsetResponse) GetEndOffset() int64 {
@@ -623,19 +1292,31 @@ func (m *LeaderEpochOffsetResponse) GetEndOffset() int64 {
 }
 
 type PropagatedRequest struct {
-	Op                Op                 `protobuf:"varint,1,opt,name=op,proto3,enum=protocol.Op" json:"op,omitempty"`
-	CreatePartitionOp *CreatePartitionOp `protobuf:"bytes,2,opt,name=createPartitionOp" json:"createPartitionOp,omitempty"`
-	ShrinkISROp       *ShrinkISROp       `protobuf:"bytes,3,opt,name=shrinkISROp" json:"shrinkISROp,omitempty"`
-	ReportLeaderOp    *ReportLeaderOp    `protobuf:"bytes,4,opt,name=reportLeaderOp" json:"reportLeaderOp,omitempty"`
-	ExpandISROp       *ExpandISROp       `protobuf:"bytes,5,opt,name=expandISROp" json:"expandISROp,omitempty"`
-	DeleteStreamOp    *DeleteStreamOp    `protobuf:"bytes,6,opt,name=deleteStreamOp" json:"deleteStreamOp,omitempty"`
-	PauseStreamOp     *PauseStreamOp     `protobuf:"bytes,7,opt,name=pauseStreamOp" json:"pauseStreamOp,omitempty"`
+	Op                  Op                   `protobuf:"varint,1,opt,name=op,proto3,enum=protocol.Op" json:"op,omitempty"`
+	CreatePartitionOp   *CreatePartitionOp   `protobuf:"bytes,2,opt,name=createPartitionOp" json:"createPartitionOp,omitempty"`
+	ShrinkISROp         *ShrinkISROp         `protobuf:"bytes,3,opt,name=shrinkISROp" json:"shrinkISROp,omitempty"`
+	ReportLeaderOp      *ReportLeaderOp      `protobuf:"bytes,4,opt,name=reportLeaderOp" json:"reportLeaderOp,omitempty"`
+	ExpandISROp         *ExpandISROp         `protobuf:"bytes,5,opt,name=expandISROp" json:"expandISROp,omitempty"`
+	DeleteStreamOp      *DeleteStreamOp      `protobuf:"bytes,6,opt,name=deleteStreamOp" json:"deleteStreamOp,omitempty"`
+	PauseStreamOp       *PauseStreamOp       `protobuf:"bytes,7,opt,name=pauseStreamOp" json:"pauseStreamOp,omitempty"`
+	CreateStreamOp      *CreateStreamOp      `protobuf:"bytes,8,opt,name=createStreamOp" json:"createStreamOp,omitempty"`
+	RenameStreamOp      *RenameStreamOp      `protobuf:"bytes,9,opt,name=renameStreamOp" json:"renameStreamOp,omitempty"`
+	TrimStreamOp        *TrimStreamOp        `protobuf:"bytes,10,opt,name=trimStreamOp" json:"trimStreamOp,omitempty"`
+	CreateStreamsOp     *CreateStreamsOp     `protobuf:"bytes,11,opt,name=createStreamsOp" json:"createStreamsOp,omitempty"`
+	ChangeReplicaRoleOp *ChangeReplicaRoleOp `protobuf:"bytes,12,opt,name=changeReplicaRoleOp" json:"changeReplicaRoleOp,omitempty"`
+	ReportActivityOp    *ReportActivityOp    `protobuf:"bytes,13,opt,name=reportActivityOp" json:"reportActivityOp,omitempty"`
+	SetServerReadOnlyOp *SetServerReadOnlyOp `protobuf:"bytes,14,opt,name=setServerReadOnlyOp" json:"setServerReadOnlyOp,omitempty"`
+	ChangeReplicasOp    *ChangeReplicasOp    `protobuf:"bytes,15,opt,name=changeReplicasOp" json:"changeReplicasOp,omitempty"`
+	ChangeLeaderOp      *ChangeLeaderOp      `protobuf:"bytes,16,opt,name=changeLeaderOp" json:"changeLeaderOp,omitempty"`
+	SwapStreamsOp       *SwapStreamsOp       `protobuf:"bytes,17,opt,name=swapStreamsOp" json:"swapStreamsOp,omitempty"`
+	ConfirmLeaderOp     *ConfirmLeaderOp     `protobuf:"bytes,18,opt,name=confirmLeaderOp" json:"confirmLeaderOp,omitempty"`
+	FencingToken        uint64               `protobuf:"varint,19,opt,name=fencingToken,proto3" json:"fencingToken,omitempty"`
 }
 
 func (m *PropagatedRequest) Reset()                    { *m = PropagatedRequest{} }
 func (m *PropagatedRequest) String() string            { return proto.CompactTextString(m) }
 func (*PropagatedRequest) ProtoMessage()               {}
-func (*PropagatedRequest) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{16} }
+func (*PropagatedRequest) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{28} }
 
 func (m *PropagatedRequest) GetOp() Op {
 	if m != nil {
@@ -686,6 +1367,90 @@ func (m *PropagatedRequest) GetPauseStreamOp() *PauseStreamOp {
 	return nil
 }
 
+func (m *PropagatedRequest) GetCreateStreamOp() *CreateStreamOp {
+	if m != nil {
+		return m.CreateStreamOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetRenameStreamOp() *RenameStreamOp {
+	if m != nil {
+		return m.RenameStreamOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetTrimStreamOp() *TrimStreamOp {
+	if m != nil {
+		return m.TrimStreamOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetCreateStreamsOp() *CreateStreamsOp {
+	if m != nil {
+		return m.CreateStreamsOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetChangeReplicaRoleOp() *ChangeReplicaRoleOp {
+	if m != nil {
+		return m.ChangeReplicaRoleOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetReportActivityOp() *ReportActivityOp {
+	if m != nil {
+		return m.ReportActivityOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetSetServerReadOnlyOp() *SetServerReadOnlyOp {
+	if m != nil {
+		return m.SetServerReadOnlyOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetChangeReplicasOp() *ChangeReplicasOp {
+	if m != nil {
+		return m.ChangeReplicasOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetChangeLeaderOp() *ChangeLeaderOp {
+	if m != nil {
+		return m.ChangeLeaderOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetSwapStreamsOp() *SwapStreamsOp {
+	if m != nil {
+		return m.SwapStreamsOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetConfirmLeaderOp() *ConfirmLeaderOp {
+	if m != nil {
+		return m.ConfirmLeaderOp
+	}
+	return nil
+}
+
+func (m *PropagatedRequest) GetFencingToken() uint64 {
+	if m != nil {
+		return m.FencingToken
+	}
+	return 0
+}
+
 type Error struct {
 	Code uint32 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
 	Msg  string `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
@@ -694,7 +1459,7 @@ type Error struct {
 func (m *Error) Reset()                    { *m = Error{} }
 func (m *Error) String() string            { return proto.CompactTextString(m) }
 func (*Error) ProtoMessage()               {}
-func (*Error) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{17} }
+func (*Error) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{29} }
 
 func (m *Error) GetCode() uint32 {
 	if m != nil {
@@ -713,12 +1478,22 @@ func (m *Error) GetMsg() string {
 type PropagatedResponse struct {
 	Op    Op     `protobuf:"varint,1,opt,name=op,proto3,enum=protocol.Op" json:"op,omitempty"`
 	Error *Error `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	// Reserving = 3 for createPartitionResp if needed.
+	// Reserving = 4 for shrinkISRResp if needed.
+	// Reserving = 5 for reportLeaderResp if needed.
+	// Reserving = 6 for expandISRResp if needed.
+	// Reserving = 7 for deleteStreamResp if needed.
+	// Reserving = 8 for pauseStreamResp if needed.
+	// Reserving = 9 for createStreamResp if needed.
+	// Reserving = 10 for renameStreamResp if needed.
+	// Reserving = 11 for trimStreamResp if needed.
+	CreateStreamsResp *CreateStreamsResponse `protobuf:"bytes,12,opt,name=createStreamsResp" json:"createStreamsResp,omitempty"`
 }
 
 func (m *PropagatedResponse) Reset()                    { *m = PropagatedResponse{} }
 func (m *PropagatedResponse) String() string            { return proto.CompactTextString(m) }
 func (*PropagatedResponse) ProtoMessage()               {}
-func (*PropagatedResponse) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{18} }
+func (*PropagatedResponse) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{30} }
 
 func (m *PropagatedResponse) GetOp() Op {
 	if m != nil {
@@ -734,6 +1509,32 @@ func (m *PropagatedResponse) GetError() *Error {
 	return nil
 }
 
+func (m *PropagatedResponse) GetCreateStreamsResp() *CreateStreamsResponse {
+	if m != nil {
+		return m.CreateStreamsResp
+	}
+	return nil
+}
+
+// CreateStreamsResponse contains the result of each stream in a
+// CreateStreamsOp, in order. A zero error code indicates the stream was
+// created.
+type CreateStreamsResponse struct {
+	Results []*Error `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *CreateStreamsResponse) Reset()                    { *m = CreateStreamsResponse{} }
+func (m *CreateStreamsResponse) String() string            { return proto.CompactTextString(m) }
+func (*CreateStreamsResponse) ProtoMessage()               {}
+func (*CreateStreamsResponse) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{31} }
+
+func (m *CreateStreamsResponse) GetResults() []*Error {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
 type ServerInfoRequest struct {
 	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 }
@@ -741,7 +1542,7 @@ type ServerInfoRequest struct {
 func (m *ServerInfoRequest) Reset()                    { *m = ServerInfoRequest{} }
 func (m *ServerInfoRequest) String() string            { return proto.CompactTextString(m) }
 func (*ServerInfoRequest) ProtoMessage()               {}
-func (*ServerInfoRequest) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{19} }
+func (*ServerInfoRequest) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{32} }
 
 func (m *ServerInfoRequest) GetId() string {
 	if m != nil {
@@ -759,7 +1560,7 @@ type ServerInfoResponse struct {
 func (m *ServerInfoResponse) Reset()                    { *m = ServerInfoResponse{} }
 func (m *ServerInfoResponse) String() string            { return proto.CompactTextString(m) }
 func (*ServerInfoResponse) ProtoMessage()               {}
-func (*ServerInfoResponse) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{20} }
+func (*ServerInfoResponse) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{33} }
 
 func (m *ServerInfoResponse) GetId() string {
 	if m != nil {
@@ -790,7 +1591,7 @@ type PartitionStatusRequest struct {
 func (m *PartitionStatusRequest) Reset()                    { *m = PartitionStatusRequest{} }
 func (m *PartitionStatusRequest) String() string            { return proto.CompactTextString(m) }
 func (*PartitionStatusRequest) ProtoMessage()               {}
-func (*PartitionStatusRequest) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{21} }
+func (*PartitionStatusRequest) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{34} }
 
 func (m *PartitionStatusRequest) GetStream() string {
 	if m != nil {
@@ -807,14 +1608,19 @@ func (m *PartitionStatusRequest) GetPartition() int32 {
 }
 
 type PartitionStatusResponse struct {
-	Exists   bool `protobuf:"varint,1,opt,name=exists,proto3" json:"exists,omitempty"`
-	IsLeader bool `protobuf:"varint,2,opt,name=isLeader,proto3" json:"isLeader,omitempty"`
+	Exists          bool   `protobuf:"varint,1,opt,name=exists,proto3" json:"exists,omitempty"`
+	IsLeader        bool   `protobuf:"varint,2,opt,name=isLeader,proto3" json:"isLeader,omitempty"`
+	NewestOffset    int64  `protobuf:"varint,3,opt,name=newestOffset,proto3" json:"newestOffset,omitempty"`
+	HighWatermark   int64  `protobuf:"varint,4,opt,name=highWatermark,proto3" json:"highWatermark,omitempty"`
+	LastLeaderEpoch uint64 `protobuf:"varint,5,opt,name=lastLeaderEpoch,proto3" json:"lastLeaderEpoch,omitempty"`
 }
 
-func (m *PartitionStatusResponse) Reset()                    { *m = PartitionStatusResponse{} }
-func (m *PartitionStatusResponse) String() string            { return proto.CompactTextString(m) }
-func (*PartitionStatusResponse) ProtoMessage()               {}
-func (*PartitionStatusResponse) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{22} }
+func (m *PartitionStatusResponse) Reset()         { *m = PartitionStatusResponse{} }
+func (m *PartitionStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*PartitionStatusResponse) ProtoMessage()    {}
+func (*PartitionStatusResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptorInternal, []int{35}
+}
 
 func (m *PartitionStatusResponse) GetExists() bool {
 	if m != nil {
@@ -830,15 +1636,37 @@ func (m *PartitionStatusResponse) GetIsLeader() bool {
 	return false
 }
 
+func (m *PartitionStatusResponse) GetNewestOffset() int64 {
+	if m != nil {
+		return m.NewestOffset
+	}
+	return 0
+}
+
+func (m *PartitionStatusResponse) GetHighWatermark() int64 {
+	if m != nil {
+		return m.HighWatermark
+	}
+	return 0
+}
+
+func (m *PartitionStatusResponse) GetLastLeaderEpoch() uint64 {
+	if m != nil {
+		return m.LastLeaderEpoch
+	}
+	return 0
+}
+
 type PartitionNotification struct {
 	Stream    string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
 	Partition int32  `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	Truncate  bool   `protobuf:"varint,3,opt,name=truncate,proto3" json:"truncate,omitempty"`
 }
 
 func (m *PartitionNotification) Reset()                    { *m = PartitionNotification{} }
 func (m *PartitionNotification) String() string            { return proto.CompactTextString(m) }
 func (*PartitionNotification) ProtoMessage()               {}
-func (*PartitionNotification) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{23} }
+func (*PartitionNotification) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{36} }
 
 func (m *PartitionNotification) GetStream() string {
 	if m != nil {
@@ -854,15 +1682,84 @@ func (m *PartitionNotification) GetPartition() int32 {
 	return 0
 }
 
-func init() {
-	proto.RegisterType((*ServerState)(nil), "protocol.ServerState")
-	proto.RegisterType((*RaftLog)(nil), "protocol.RaftLog")
-	proto.RegisterType((*CreatePartitionOp)(nil), "protocol.CreatePartitionOp")
-	proto.RegisterType((*ShrinkISROp)(nil), "protocol.ShrinkISROp")
-	proto.RegisterType((*ExpandISROp)(nil), "protocol.ExpandISROp")
-	proto.RegisterType((*DeleteStreamOp)(nil), "protocol.DeleteStreamOp")
+func (m *PartitionNotification) GetTruncate() bool {
+	if m != nil {
+		return m.Truncate
+	}
+	return false
+}
+
+// PublishRejection is sent to a message's ack inbox in place of an ack when the
+// partition leader rejects the message.
+type PublishRejection struct {
+	Stream        string `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	Partition     int32  `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	CorrelationId string `protobuf:"bytes,3,opt,name=correlationId,proto3" json:"correlationId,omitempty"`
+	Reason        string `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	Code          uint32 `protobuf:"varint,5,opt,name=code,proto3" json:"code,omitempty"`
+}
+
+func (m *PublishRejection) Reset()                    { *m = PublishRejection{} }
+func (m *PublishRejection) String() string            { return proto.CompactTextString(m) }
+func (*PublishRejection) ProtoMessage()               {}
+func (*PublishRejection) Descriptor() ([]byte, []int) { return fileDescriptorInternal, []int{37} }
+
+func (m *PublishRejection) GetStream() string {
+	if m != nil {
+		return m.Stream
+	}
+	return ""
+}
+
+func (m *PublishRejection) GetPartition() int32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *PublishRejection) GetCorrelationId() string {
+	if m != nil {
+		return m.CorrelationId
+	}
+	return ""
+}
+
+func (m *PublishRejection) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *PublishRejection) GetCode() uint32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*ServerState)(nil), "protocol.ServerState")
+	proto.RegisterType((*ExportState)(nil), "protocol.ExportState")
+	proto.RegisterType((*ImportState)(nil), "protocol.ImportState")
+	proto.RegisterType((*RaftLog)(nil), "protocol.RaftLog")
+	proto.RegisterType((*CreatePartitionOp)(nil), "protocol.CreatePartitionOp")
+	proto.RegisterType((*CreateStreamOp)(nil), "protocol.CreateStreamOp")
+	proto.RegisterType((*CreateStreamsOp)(nil), "protocol.CreateStreamsOp")
+	proto.RegisterType((*ShrinkISROp)(nil), "protocol.ShrinkISROp")
+	proto.RegisterType((*ExpandISROp)(nil), "protocol.ExpandISROp")
+	proto.RegisterType((*DeleteStreamOp)(nil), "protocol.DeleteStreamOp")
+	proto.RegisterType((*RenameStreamOp)(nil), "protocol.RenameStreamOp")
+	proto.RegisterType((*SwapStreamsOp)(nil), "protocol.SwapStreamsOp")
+	proto.RegisterType((*TrimStreamOp)(nil), "protocol.TrimStreamOp")
 	proto.RegisterType((*PauseStreamOp)(nil), "protocol.PauseStreamOp")
+	proto.RegisterType((*ChangeReplicaRoleOp)(nil), "protocol.ChangeReplicaRoleOp")
+	proto.RegisterType((*ChangeReplicasOp)(nil), "protocol.ChangeReplicasOp")
 	proto.RegisterType((*ReportLeaderOp)(nil), "protocol.ReportLeaderOp")
+	proto.RegisterType((*ReportActivityOp)(nil), "protocol.ReportActivityOp")
+	proto.RegisterType((*ConfirmLeaderOp)(nil), "protocol.ConfirmLeaderOp")
+	proto.RegisterType((*SetServerReadOnlyOp)(nil), "protocol.SetServerReadOnlyOp")
 	proto.RegisterType((*ChangeLeaderOp)(nil), "protocol.ChangeLeaderOp")
 	proto.RegisterType((*Partition)(nil), "protocol.Partition")
 	proto.RegisterType((*RaftJoinRequest)(nil), "protocol.RaftJoinRequest")
@@ -874,12 +1771,15 @@ func init() {
 	proto.RegisterType((*PropagatedRequest)(nil), "protocol.PropagatedRequest")
 	proto.RegisterType((*Error)(nil), "protocol.Error")
 	proto.RegisterType((*PropagatedResponse)(nil), "protocol.PropagatedResponse")
+	proto.RegisterType((*CreateStreamsResponse)(nil), "protocol.CreateStreamsResponse")
 	proto.RegisterType((*ServerInfoRequest)(nil), "protocol.ServerInfoRequest")
 	proto.RegisterType((*ServerInfoResponse)(nil), "protocol.ServerInfoResponse")
 	proto.RegisterType((*PartitionStatusRequest)(nil), "protocol.PartitionStatusRequest")
 	proto.RegisterType((*PartitionStatusResponse)(nil), "protocol.PartitionStatusResponse")
 	proto.RegisterType((*PartitionNotification)(nil), "protocol.PartitionNotification")
+	proto.RegisterType((*PublishRejection)(nil), "protocol.PublishRejection")
 	proto.RegisterEnum("protocol.Op", Op_name, Op_value)
+	proto.RegisterEnum("protocol.EmptyValue", EmptyValue_name, EmptyValue_value)
 }
 func (m *ServerState) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
@@ -905,6 +1805,98 @@ func (m *ServerState) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *ExportState) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ExportState) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if len(m.Stream) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
+	}
+	if m.Partition != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
+	}
+	if len(m.Subject) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Subject)))
+		i += copy(dAtA[i:], m.Subject)
+	}
+	if m.Offset != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Offset))
+	}
+	return i, nil
+}
+
+func (m *ImportState) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ImportState) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if len(m.Subject) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Subject)))
+		i += copy(dAtA[i:], m.Subject)
+	}
+	if len(m.Stream) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
+	}
+	if m.Partition != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
+	}
+	if m.Position != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Position))
+	}
+	return i, nil
+}
+
 func (m *RaftLog) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -985,6 +1977,93 @@ func (m *RaftLog) MarshalTo(dAtA []byte) (int, error) {
 		}
 		i += n6
 	}
+	if m.CreateStreamOp != nil {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.CreateStreamOp.Size()))
+		n7, err := m.CreateStreamOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n7
+	}
+	if m.RenameStreamOp != nil {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.RenameStreamOp.Size()))
+		n8, err := m.RenameStreamOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n8
+	}
+	if m.TrimStreamOp != nil {
+		dAtA[i] = 0x52
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.TrimStreamOp.Size()))
+		n9, err := m.TrimStreamOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n9
+	}
+	if m.CreateStreamsOp != nil {
+		dAtA[i] = 0x5a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.CreateStreamsOp.Size()))
+		n10, err := m.CreateStreamsOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n10
+	}
+	if m.ChangeReplicaRoleOp != nil {
+		dAtA[i] = 0x62
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ChangeReplicaRoleOp.Size()))
+		n11, err := m.ChangeReplicaRoleOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n11
+	}
+	if m.SetServerReadOnlyOp != nil {
+		dAtA[i] = 0x6a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.SetServerReadOnlyOp.Size()))
+		n12, err := m.SetServerReadOnlyOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n12
+	}
+	if m.ChangeReplicasOp != nil {
+		dAtA[i] = 0x72
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ChangeReplicasOp.Size()))
+		n13, err := m.ChangeReplicasOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n13
+	}
+	if m.SwapStreamsOp != nil {
+		dAtA[i] = 0x7a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.SwapStreamsOp.Size()))
+		n14, err := m.SwapStreamsOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n14
+	}
+	if m.FencingToken != 0 {
+		dAtA[i] = 0x80
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.FencingToken))
+	}
 	return i, nil
 }
 
@@ -1007,11 +2086,101 @@ func (m *CreatePartitionOp) MarshalTo(dAtA []byte) (int, error) {
 		dAtA[i] = 0xa
 		i++
 		i = encodeVarintInternal(dAtA, i, uint64(m.Partition.Size()))
-		n7, err := m.Partition.MarshalTo(dAtA[i:])
+		n15, err := m.Partition.MarshalTo(dAtA[i:])
 		if err != nil {
 			return 0, err
 		}
-		i += n7
+		i += n15
+	}
+	return i, nil
+}
+
+func (m *CreateStreamOp) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CreateStreamOp) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Partitions) > 0 {
+		for _, msg := range m.Partitions {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintInternal(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.Servers) > 0 {
+		for _, s := range m.Servers {
+			dAtA[i] = 0x12
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if len(m.Observers) > 0 {
+		for _, s := range m.Observers {
+			dAtA[i] = 0x1a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	return i, nil
+}
+
+func (m *CreateStreamsOp) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CreateStreamsOp) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Streams) > 0 {
+		for _, msg := range m.Streams {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintInternal(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
 	}
 	return i, nil
 }
@@ -1132,7 +2301,7 @@ func (m *DeleteStreamOp) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
-func (m *PauseStreamOp) Marshal() (dAtA []byte, err error) {
+func (m *RenameStreamOp) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1142,7 +2311,7 @@ func (m *PauseStreamOp) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *PauseStreamOp) MarshalTo(dAtA []byte) (int, error) {
+func (m *RenameStreamOp) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1153,23 +2322,112 @@ func (m *PauseStreamOp) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
 		i += copy(dAtA[i:], m.Stream)
 	}
-	if len(m.Partitions) > 0 {
-		dAtA9 := make([]byte, len(m.Partitions)*10)
-		var j8 int
-		for _, num1 := range m.Partitions {
-			num := uint64(num1)
+	if len(m.NewName) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.NewName)))
+		i += copy(dAtA[i:], m.NewName)
+	}
+	return i, nil
+}
+
+func (m *SwapStreamsOp) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SwapStreamsOp) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Stream) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
+	}
+	if len(m.Other) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Other)))
+		i += copy(dAtA[i:], m.Other)
+	}
+	return i, nil
+}
+
+func (m *TrimStreamOp) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TrimStreamOp) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Stream) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
+	}
+	if m.Offset != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Offset))
+	}
+	return i, nil
+}
+
+func (m *PauseStreamOp) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PauseStreamOp) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Stream) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
+	}
+	if len(m.Partitions) > 0 {
+		dAtA17 := make([]byte, len(m.Partitions)*10)
+		var j16 int
+		for _, num1 := range m.Partitions {
+			num := uint64(num1)
 			for num >= 1<<7 {
-				dAtA9[j8] = uint8(uint64(num)&0x7f | 0x80)
+				dAtA17[j16] = uint8(uint64(num)&0x7f | 0x80)
 				num >>= 7
-				j8++
+				j16++
 			}
-			dAtA9[j8] = uint8(num)
-			j8++
+			dAtA17[j16] = uint8(num)
+			j16++
 		}
 		dAtA[i] = 0x12
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(j8))
-		i += copy(dAtA[i:], dAtA9[:j8])
+		i = encodeVarintInternal(dAtA, i, uint64(j16))
+		i += copy(dAtA[i:], dAtA17[:j16])
 	}
 	if m.ResumeAll {
 		dAtA[i] = 0x18
@@ -1184,7 +2442,7 @@ func (m *PauseStreamOp) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
-func (m *ReportLeaderOp) Marshal() (dAtA []byte, err error) {
+func (m *ChangeReplicaRoleOp) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1194,7 +2452,7 @@ func (m *ReportLeaderOp) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *ReportLeaderOp) MarshalTo(dAtA []byte) (int, error) {
+func (m *ChangeReplicaRoleOp) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1216,21 +2474,20 @@ func (m *ReportLeaderOp) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintInternal(dAtA, i, uint64(len(m.Replica)))
 		i += copy(dAtA[i:], m.Replica)
 	}
-	if len(m.Leader) > 0 {
-		dAtA[i] = 0x22
+	if m.Observer {
+		dAtA[i] = 0x20
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Leader)))
-		i += copy(dAtA[i:], m.Leader)
-	}
-	if m.LeaderEpoch != 0 {
-		dAtA[i] = 0x28
+		if m.Observer {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.LeaderEpoch))
 	}
 	return i, nil
 }
 
-func (m *ChangeLeaderOp) Marshal() (dAtA []byte, err error) {
+func (m *ChangeReplicasOp) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1240,7 +2497,7 @@ func (m *ChangeLeaderOp) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *ChangeLeaderOp) MarshalTo(dAtA []byte) (int, error) {
+func (m *ChangeReplicasOp) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
@@ -1256,16 +2513,25 @@ func (m *ChangeLeaderOp) MarshalTo(dAtA []byte) (int, error) {
 		i++
 		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
 	}
-	if len(m.Leader) > 0 {
-		dAtA[i] = 0x1a
-		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Leader)))
-		i += copy(dAtA[i:], m.Leader)
+	if len(m.Replicas) > 0 {
+		for _, s := range m.Replicas {
+			dAtA[i] = 0x1a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
 	}
 	return i, nil
 }
 
-func (m *Partition) Marshal() (dAtA []byte, err error) {
+func (m *ReportLeaderOp) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1275,89 +2541,43 @@ func (m *Partition) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *Partition) MarshalTo(dAtA []byte) (int, error) {
+func (m *ReportLeaderOp) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if len(m.Subject) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Subject)))
-		i += copy(dAtA[i:], m.Subject)
-	}
 	if len(m.Stream) > 0 {
-		dAtA[i] = 0x12
+		dAtA[i] = 0xa
 		i++
 		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
 		i += copy(dAtA[i:], m.Stream)
 	}
-	if m.Id != 0 {
-		dAtA[i] = 0x18
-		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.Id))
-	}
-	if len(m.Group) > 0 {
-		dAtA[i] = 0x22
+	if m.Partition != 0 {
+		dAtA[i] = 0x10
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Group)))
-		i += copy(dAtA[i:], m.Group)
+		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
 	}
-	if m.ReplicationFactor != 0 {
-		dAtA[i] = 0x28
+	if len(m.Replica) > 0 {
+		dAtA[i] = 0x1a
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.ReplicationFactor))
-	}
-	if len(m.Replicas) > 0 {
-		for _, s := range m.Replicas {
-			dAtA[i] = 0x32
-			i++
-			l = len(s)
-			for l >= 1<<7 {
-				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
-				l >>= 7
-				i++
-			}
-			dAtA[i] = uint8(l)
-			i++
-			i += copy(dAtA[i:], s)
-		}
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Replica)))
+		i += copy(dAtA[i:], m.Replica)
 	}
 	if len(m.Leader) > 0 {
-		dAtA[i] = 0x3a
+		dAtA[i] = 0x22
 		i++
 		i = encodeVarintInternal(dAtA, i, uint64(len(m.Leader)))
 		i += copy(dAtA[i:], m.Leader)
 	}
-	if len(m.Isr) > 0 {
-		for _, s := range m.Isr {
-			dAtA[i] = 0x42
-			i++
-			l = len(s)
-			for l >= 1<<7 {
-				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
-				l >>= 7
-				i++
-			}
-			dAtA[i] = uint8(l)
-			i++
-			i += copy(dAtA[i:], s)
-		}
-	}
 	if m.LeaderEpoch != 0 {
-		dAtA[i] = 0x48
+		dAtA[i] = 0x28
 		i++
 		i = encodeVarintInternal(dAtA, i, uint64(m.LeaderEpoch))
 	}
-	if m.Epoch != 0 {
-		dAtA[i] = 0x50
-		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.Epoch))
-	}
 	return i, nil
 }
 
-func (m *RaftJoinRequest) Marshal() (dAtA []byte, err error) {
+func (m *ReportActivityOp) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1367,27 +2587,26 @@ func (m *RaftJoinRequest) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *RaftJoinRequest) MarshalTo(dAtA []byte) (int, error) {
+func (m *ReportActivityOp) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if len(m.NodeID) > 0 {
+	if len(m.Stream) > 0 {
 		dAtA[i] = 0xa
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.NodeID)))
-		i += copy(dAtA[i:], m.NodeID)
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
 	}
-	if len(m.NodeAddr) > 0 {
-		dAtA[i] = 0x12
+	if m.Partition != 0 {
+		dAtA[i] = 0x10
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.NodeAddr)))
-		i += copy(dAtA[i:], m.NodeAddr)
+		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
 	}
 	return i, nil
 }
 
-func (m *RaftJoinResponse) Marshal() (dAtA []byte, err error) {
+func (m *ConfirmLeaderOp) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1397,21 +2616,37 @@ func (m *RaftJoinResponse) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *RaftJoinResponse) MarshalTo(dAtA []byte) (int, error) {
+func (m *ConfirmLeaderOp) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if len(m.Error) > 0 {
+	if len(m.Stream) > 0 {
 		dAtA[i] = 0xa
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Error)))
-		i += copy(dAtA[i:], m.Error)
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
+	}
+	if m.Partition != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
+	}
+	if len(m.Leader) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Leader)))
+		i += copy(dAtA[i:], m.Leader)
+	}
+	if m.LeaderEpoch != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.LeaderEpoch))
 	}
 	return i, nil
 }
 
-func (m *MetadataSnapshot) Marshal() (dAtA []byte, err error) {
+func (m *SetServerReadOnlyOp) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1421,27 +2656,31 @@ func (m *MetadataSnapshot) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *MetadataSnapshot) MarshalTo(dAtA []byte) (int, error) {
+func (m *SetServerReadOnlyOp) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if len(m.Partitions) > 0 {
-		for _, msg := range m.Partitions {
-			dAtA[i] = 0xa
-			i++
-			i = encodeVarintInternal(dAtA, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(dAtA[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
+	if len(m.Server) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Server)))
+		i += copy(dAtA[i:], m.Server)
+	}
+	if m.ReadOnly {
+		dAtA[i] = 0x10
+		i++
+		if m.ReadOnly {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
 		}
+		i++
 	}
 	return i, nil
 }
 
-func (m *ReplicationRequest) Marshal() (dAtA []byte, err error) {
+func (m *ChangeLeaderOp) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1451,31 +2690,32 @@ func (m *ReplicationRequest) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *ReplicationRequest) MarshalTo(dAtA []byte) (int, error) {
+func (m *ChangeLeaderOp) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if len(m.ReplicaID) > 0 {
+	if len(m.Stream) > 0 {
 		dAtA[i] = 0xa
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.ReplicaID)))
-		i += copy(dAtA[i:], m.ReplicaID)
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
 	}
-	if m.Offset != 0 {
+	if m.Partition != 0 {
 		dAtA[i] = 0x10
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.Offset))
+		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
 	}
-	if m.LeaderEpoch != 0 {
-		dAtA[i] = 0x18
+	if len(m.Leader) > 0 {
+		dAtA[i] = 0x1a
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.LeaderEpoch))
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Leader)))
+		i += copy(dAtA[i:], m.Leader)
 	}
 	return i, nil
 }
 
-func (m *LeaderEpochOffsetRequest) Marshal() (dAtA []byte, err error) {
+func (m *Partition) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1485,126 +2725,210 @@ func (m *LeaderEpochOffsetRequest) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *LeaderEpochOffsetRequest) MarshalTo(dAtA []byte) (int, error) {
+func (m *Partition) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if m.LeaderEpoch != 0 {
-		dAtA[i] = 0x8
+	if len(m.Subject) > 0 {
+		dAtA[i] = 0xa
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.LeaderEpoch))
-	}
-	return i, nil
-}
-
-func (m *LeaderEpochOffsetResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Subject)))
+		i += copy(dAtA[i:], m.Subject)
 	}
-	return dAtA[:n], nil
-}
-
-func (m *LeaderEpochOffsetResponse) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.EndOffset != 0 {
-		dAtA[i] = 0x8
+	if len(m.Stream) > 0 {
+		dAtA[i] = 0x12
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.EndOffset))
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
 	}
-	return i, nil
-}
-
-func (m *PropagatedRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+	if m.Id != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Id))
 	}
-	return dAtA[:n], nil
-}
-
-func (m *PropagatedRequest) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.Op != 0 {
-		dAtA[i] = 0x8
+	if len(m.Group) > 0 {
+		dAtA[i] = 0x22
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.Op))
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Group)))
+		i += copy(dAtA[i:], m.Group)
 	}
-	if m.CreatePartitionOp != nil {
-		dAtA[i] = 0x12
+	if m.ReplicationFactor != 0 {
+		dAtA[i] = 0x28
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.CreatePartitionOp.Size()))
-		n10, err := m.CreatePartitionOp.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
+		i = encodeVarintInternal(dAtA, i, uint64(m.ReplicationFactor))
+	}
+	if len(m.Replicas) > 0 {
+		for _, s := range m.Replicas {
+			dAtA[i] = 0x32
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
 		}
-		i += n10
 	}
-	if m.ShrinkISROp != nil {
-		dAtA[i] = 0x1a
+	if len(m.Leader) > 0 {
+		dAtA[i] = 0x3a
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.ShrinkISROp.Size()))
-		n11, err := m.ShrinkISROp.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Leader)))
+		i += copy(dAtA[i:], m.Leader)
+	}
+	if len(m.Isr) > 0 {
+		for _, s := range m.Isr {
+			dAtA[i] = 0x42
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
 		}
-		i += n11
 	}
-	if m.ReportLeaderOp != nil {
-		dAtA[i] = 0x22
+	if m.LeaderEpoch != 0 {
+		dAtA[i] = 0x48
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.ReportLeaderOp.Size()))
-		n12, err := m.ReportLeaderOp.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n12
+		i = encodeVarintInternal(dAtA, i, uint64(m.LeaderEpoch))
 	}
-	if m.ExpandISROp != nil {
-		dAtA[i] = 0x2a
+	if m.Epoch != 0 {
+		dAtA[i] = 0x50
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.ExpandISROp.Size()))
-		n13, err := m.ExpandISROp.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
+		i = encodeVarintInternal(dAtA, i, uint64(m.Epoch))
+	}
+	if m.ReplicaMaxLagTime != 0 {
+		dAtA[i] = 0x58
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ReplicaMaxLagTime))
+	}
+	if m.ReplicaFetchTimeout != 0 {
+		dAtA[i] = 0x60
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ReplicaFetchTimeout))
+	}
+	if m.RequireKey {
+		dAtA[i] = 0x68
+		i++
+		if m.RequireKey {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
 		}
-		i += n13
+		i++
 	}
-	if m.DeleteStreamOp != nil {
-		dAtA[i] = 0x32
+	if m.EmptyValue != 0 {
+		dAtA[i] = 0x70
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.DeleteStreamOp.Size()))
-		n14, err := m.DeleteStreamOp.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
+		i = encodeVarintInternal(dAtA, i, uint64(m.EmptyValue))
+	}
+	if len(m.Observers) > 0 {
+		for _, s := range m.Observers {
+			dAtA[i] = 0x7a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
 		}
-		i += n14
 	}
-	if m.PauseStreamOp != nil {
-		dAtA[i] = 0x3a
+	if len(m.Schema) > 0 {
+		dAtA[i] = 0x82
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.PauseStreamOp.Size()))
-		n15, err := m.PauseStreamOp.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Schema)))
+		i += copy(dAtA[i:], m.Schema)
+	}
+	if m.IdleDeleteTime != 0 {
+		dAtA[i] = 0x88
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.IdleDeleteTime))
+	}
+	if m.ReplicaMaxLagOffsets != 0 {
+		dAtA[i] = 0x90
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ReplicaMaxLagOffsets))
+	}
+	if m.MaxCommitWaiters != 0 {
+		dAtA[i] = 0x98
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.MaxCommitWaiters))
+	}
+	if m.RetentionMaxAge != 0 {
+		dAtA[i] = 0xa0
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.RetentionMaxAge))
+	}
+	if m.QuotaMaxBytes != 0 {
+		dAtA[i] = 0xa8
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.QuotaMaxBytes))
+	}
+	if m.TrimOffset != 0 {
+		dAtA[i] = 0xb0
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.TrimOffset))
+	}
+	if len(m.PinnedServers) > 0 {
+		for _, s := range m.PinnedServers {
+			dAtA[i] = 0xba
+			i++
+			dAtA[i] = 0x1
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
 		}
-		i += n15
+	}
+	if m.RetentionMaxSegments != 0 {
+		dAtA[i] = 0xc0
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.RetentionMaxSegments))
+	}
+	if m.CompressionThreshold != 0 {
+		dAtA[i] = 0xc8
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.CompressionThreshold))
 	}
 	return i, nil
 }
 
-func (m *Error) Marshal() (dAtA []byte, err error) {
+func (m *RaftJoinRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1614,26 +2938,27 @@ func (m *Error) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *Error) MarshalTo(dAtA []byte) (int, error) {
+func (m *RaftJoinRequest) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if m.Code != 0 {
-		dAtA[i] = 0x8
+	if len(m.NodeID) > 0 {
+		dAtA[i] = 0xa
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.Code))
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.NodeID)))
+		i += copy(dAtA[i:], m.NodeID)
 	}
-	if len(m.Msg) > 0 {
+	if len(m.NodeAddr) > 0 {
 		dAtA[i] = 0x12
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Msg)))
-		i += copy(dAtA[i:], m.Msg)
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.NodeAddr)))
+		i += copy(dAtA[i:], m.NodeAddr)
 	}
 	return i, nil
 }
 
-func (m *PropagatedResponse) Marshal() (dAtA []byte, err error) {
+func (m *RaftJoinResponse) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1643,30 +2968,21 @@ func (m *PropagatedResponse) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *PropagatedResponse) MarshalTo(dAtA []byte) (int, error) {
+func (m *RaftJoinResponse) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if m.Op != 0 {
-		dAtA[i] = 0x8
-		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.Op))
-	}
-	if m.Error != nil {
-		dAtA[i] = 0x12
+	if len(m.Error) > 0 {
+		dAtA[i] = 0xa
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.Error.Size()))
-		n16, err := m.Error.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n16
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Error)))
+		i += copy(dAtA[i:], m.Error)
 	}
 	return i, nil
 }
 
-func (m *ServerInfoRequest) Marshal() (dAtA []byte, err error) {
+func (m *MetadataSnapshot) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1676,21 +2992,47 @@ func (m *ServerInfoRequest) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *ServerInfoRequest) MarshalTo(dAtA []byte) (int, error) {
+func (m *MetadataSnapshot) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if len(m.Id) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Id)))
-		i += copy(dAtA[i:], m.Id)
-	}
+	if len(m.Partitions) > 0 {
+		for _, msg := range m.Partitions {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintInternal(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.ReadOnlyServers) > 0 {
+		for _, s := range m.ReadOnlyServers {
+			dAtA[i] = 0x12
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if m.FencingEpoch != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.FencingEpoch))
+	}
 	return i, nil
 }
 
-func (m *ServerInfoResponse) Marshal() (dAtA []byte, err error) {
+func (m *ReplicationRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1700,32 +3042,51 @@ func (m *ServerInfoResponse) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *ServerInfoResponse) MarshalTo(dAtA []byte) (int, error) {
+func (m *ReplicationRequest) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if len(m.Id) > 0 {
+	if len(m.ReplicaID) > 0 {
 		dAtA[i] = 0xa
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Id)))
-		i += copy(dAtA[i:], m.Id)
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.ReplicaID)))
+		i += copy(dAtA[i:], m.ReplicaID)
 	}
-	if len(m.Host) > 0 {
-		dAtA[i] = 0x12
+	if m.Offset != 0 {
+		dAtA[i] = 0x10
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Host)))
-		i += copy(dAtA[i:], m.Host)
+		i = encodeVarintInternal(dAtA, i, uint64(m.Offset))
 	}
-	if m.Port != 0 {
+	if m.LeaderEpoch != 0 {
 		dAtA[i] = 0x18
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.Port))
+		i = encodeVarintInternal(dAtA, i, uint64(m.LeaderEpoch))
+	}
+	if m.SyncFollower {
+		dAtA[i] = 0x20
+		i++
+		if m.SyncFollower {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.ReportOnly {
+		dAtA[i] = 0x28
+		i++
+		if m.ReportOnly {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
 	}
 	return i, nil
 }
 
-func (m *PartitionStatusRequest) Marshal() (dAtA []byte, err error) {
+func (m *LeaderEpochOffsetRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1735,26 +3096,20 @@ func (m *PartitionStatusRequest) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *PartitionStatusRequest) MarshalTo(dAtA []byte) (int, error) {
+func (m *LeaderEpochOffsetRequest) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if len(m.Stream) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
-		i += copy(dAtA[i:], m.Stream)
-	}
-	if m.Partition != 0 {
-		dAtA[i] = 0x10
+	if m.LeaderEpoch != 0 {
+		dAtA[i] = 0x8
 		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
+		i = encodeVarintInternal(dAtA, i, uint64(m.LeaderEpoch))
 	}
 	return i, nil
 }
 
-func (m *PartitionStatusResponse) Marshal() (dAtA []byte, err error) {
+func (m *LeaderEpochOffsetResponse) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1764,35 +3119,20 @@ func (m *PartitionStatusResponse) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *PartitionStatusResponse) MarshalTo(dAtA []byte) (int, error) {
+func (m *LeaderEpochOffsetResponse) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	if m.Exists {
+	if m.EndOffset != 0 {
 		dAtA[i] = 0x8
 		i++
-		if m.Exists {
-			dAtA[i] = 1
-		} else {
-			dAtA[i] = 0
-		}
-		i++
-	}
-	if m.IsLeader {
-		dAtA[i] = 0x10
-		i++
-		if m.IsLeader {
-			dAtA[i] = 1
-		} else {
-			dAtA[i] = 0
-		}
-		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.EndOffset))
 	}
 	return i, nil
 }
 
-func (m *PartitionNotification) Marshal() (dAtA []byte, err error) {
+func (m *PropagatedRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1802,411 +3142,584 @@ func (m *PartitionNotification) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *PartitionNotification) MarshalTo(dAtA []byte) (int, error) {
+func (m *PropagatedRequest) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
-	var l int
-	_ = l
-	if len(m.Stream) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
-		i += copy(dAtA[i:], m.Stream)
-	}
-	if m.Partition != 0 {
-		dAtA[i] = 0x10
-		i++
-		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
-	}
-	return i, nil
-}
-
-func encodeVarintInternal(dAtA []byte, offset int, v uint64) int {
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
-	}
-	dAtA[offset] = uint8(v)
-	return offset + 1
-}
-func (m *ServerState) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.ServerID)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	return n
-}
-
-func (m *RaftLog) Size() (n int) {
 	var l int
 	_ = l
 	if m.Op != 0 {
-		n += 1 + sovInternal(uint64(m.Op))
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Op))
 	}
 	if m.CreatePartitionOp != nil {
-		l = m.CreatePartitionOp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.CreatePartitionOp.Size()))
+		n18, err := m.CreatePartitionOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n18
 	}
 	if m.ShrinkISROp != nil {
-		l = m.ShrinkISROp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ShrinkISROp.Size()))
+		n19, err := m.ShrinkISROp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n19
 	}
-	if m.ChangeLeaderOp != nil {
-		l = m.ChangeLeaderOp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+	if m.ReportLeaderOp != nil {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ReportLeaderOp.Size()))
+		n20, err := m.ReportLeaderOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n20
 	}
 	if m.ExpandISROp != nil {
-		l = m.ExpandISROp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ExpandISROp.Size()))
+		n21, err := m.ExpandISROp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n21
 	}
 	if m.DeleteStreamOp != nil {
-		l = m.DeleteStreamOp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+		dAtA[i] = 0x32
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.DeleteStreamOp.Size()))
+		n22, err := m.DeleteStreamOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n22
 	}
 	if m.PauseStreamOp != nil {
-		l = m.PauseStreamOp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.PauseStreamOp.Size()))
+		n23, err := m.PauseStreamOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n23
 	}
-	return n
-}
-
-func (m *CreatePartitionOp) Size() (n int) {
-	var l int
-	_ = l
-	if m.Partition != nil {
-		l = m.Partition.Size()
-		n += 1 + l + sovInternal(uint64(l))
+	if m.CreateStreamOp != nil {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.CreateStreamOp.Size()))
+		n24, err := m.CreateStreamOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n24
 	}
-	return n
-}
-
-func (m *ShrinkISROp) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.Stream)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+	if m.RenameStreamOp != nil {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.RenameStreamOp.Size()))
+		n25, err := m.RenameStreamOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n25
 	}
-	if m.Partition != 0 {
-		n += 1 + sovInternal(uint64(m.Partition))
+	if m.TrimStreamOp != nil {
+		dAtA[i] = 0x52
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.TrimStreamOp.Size()))
+		n26, err := m.TrimStreamOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n26
 	}
-	l = len(m.ReplicaToRemove)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+	if m.CreateStreamsOp != nil {
+		dAtA[i] = 0x5a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.CreateStreamsOp.Size()))
+		n27, err := m.CreateStreamsOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n27
 	}
-	l = len(m.Leader)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+	if m.ChangeReplicaRoleOp != nil {
+		dAtA[i] = 0x62
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ChangeReplicaRoleOp.Size()))
+		n28, err := m.ChangeReplicaRoleOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n28
 	}
-	if m.LeaderEpoch != 0 {
-		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	if m.ReportActivityOp != nil {
+		dAtA[i] = 0x6a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ReportActivityOp.Size()))
+		n29, err := m.ReportActivityOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n29
 	}
-	return n
-}
-
-func (m *ExpandISROp) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.Stream)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+	if m.SetServerReadOnlyOp != nil {
+		dAtA[i] = 0x72
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.SetServerReadOnlyOp.Size()))
+		n30, err := m.SetServerReadOnlyOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n30
 	}
-	if m.Partition != 0 {
-		n += 1 + sovInternal(uint64(m.Partition))
+	if m.ChangeReplicasOp != nil {
+		dAtA[i] = 0x7a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ChangeReplicasOp.Size()))
+		n31, err := m.ChangeReplicasOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n31
 	}
-	l = len(m.ReplicaToAdd)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+	if m.ChangeLeaderOp != nil {
+		dAtA[i] = 0x82
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ChangeLeaderOp.Size()))
+		n32, err := m.ChangeLeaderOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n32
 	}
-	l = len(m.Leader)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+	if m.SwapStreamsOp != nil {
+		dAtA[i] = 0x8a
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.SwapStreamsOp.Size()))
+		n33, err := m.SwapStreamsOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n33
 	}
-	if m.LeaderEpoch != 0 {
-		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	if m.ConfirmLeaderOp != nil {
+		dAtA[i] = 0x92
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.ConfirmLeaderOp.Size()))
+		n34, err := m.ConfirmLeaderOp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n34
 	}
-	return n
-}
-
-func (m *DeleteStreamOp) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.Stream)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+	if m.FencingToken != 0 {
+		dAtA[i] = 0x98
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.FencingToken))
 	}
-	return n
+	return i, nil
 }
 
-func (m *PauseStreamOp) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.Stream)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	if len(m.Partitions) > 0 {
-		l = 0
-		for _, e := range m.Partitions {
-			l += sovInternal(uint64(e))
-		}
-		n += 1 + sovInternal(uint64(l)) + l
-	}
-	if m.ResumeAll {
-		n += 2
+func (m *Error) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return dAtA[:n], nil
 }
 
-func (m *ReportLeaderOp) Size() (n int) {
+func (m *Error) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
 	var l int
 	_ = l
-	l = len(m.Stream)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	if m.Partition != 0 {
-		n += 1 + sovInternal(uint64(m.Partition))
-	}
-	l = len(m.Replica)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	l = len(m.Leader)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+	if m.Code != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Code))
 	}
-	if m.LeaderEpoch != 0 {
-		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	if len(m.Msg) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Msg)))
+		i += copy(dAtA[i:], m.Msg)
 	}
-	return n
+	return i, nil
 }
 
-func (m *ChangeLeaderOp) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.Stream)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	if m.Partition != 0 {
-		n += 1 + sovInternal(uint64(m.Partition))
-	}
-	l = len(m.Leader)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+func (m *PropagatedResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return dAtA[:n], nil
 }
 
-func (m *Partition) Size() (n int) {
+func (m *PropagatedResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
 	var l int
 	_ = l
-	l = len(m.Subject)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	l = len(m.Stream)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	if m.Id != 0 {
-		n += 1 + sovInternal(uint64(m.Id))
-	}
-	l = len(m.Group)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	if m.ReplicationFactor != 0 {
-		n += 1 + sovInternal(uint64(m.ReplicationFactor))
+	if m.Op != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Op))
 	}
-	if len(m.Replicas) > 0 {
-		for _, s := range m.Replicas {
-			l = len(s)
-			n += 1 + l + sovInternal(uint64(l))
+	if m.Error != nil {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Error.Size()))
+		n35, err := m.Error.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
 		}
+		i += n35
 	}
-	l = len(m.Leader)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	if len(m.Isr) > 0 {
-		for _, s := range m.Isr {
-			l = len(s)
-			n += 1 + l + sovInternal(uint64(l))
+	if m.CreateStreamsResp != nil {
+		dAtA[i] = 0x62
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.CreateStreamsResp.Size()))
+		n36, err := m.CreateStreamsResp.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
 		}
+		i += n36
 	}
-	if m.LeaderEpoch != 0 {
-		n += 1 + sovInternal(uint64(m.LeaderEpoch))
-	}
-	if m.Epoch != 0 {
-		n += 1 + sovInternal(uint64(m.Epoch))
-	}
-	return n
-}
-
-func (m *RaftJoinRequest) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.NodeID)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	l = len(m.NodeAddr)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	return n
+	return i, nil
 }
 
-func (m *RaftJoinResponse) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.Error)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+func (m *CreateStreamsResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return dAtA[:n], nil
 }
 
-func (m *MetadataSnapshot) Size() (n int) {
+func (m *CreateStreamsResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
 	var l int
 	_ = l
-	if len(m.Partitions) > 0 {
-		for _, e := range m.Partitions {
-			l = e.Size()
-			n += 1 + l + sovInternal(uint64(l))
+	if len(m.Results) > 0 {
+		for _, msg := range m.Results {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintInternal(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
 		}
 	}
-	return n
+	return i, nil
 }
 
-func (m *ReplicationRequest) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.ReplicaID)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
-	}
-	if m.Offset != 0 {
-		n += 1 + sovInternal(uint64(m.Offset))
-	}
-	if m.LeaderEpoch != 0 {
-		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+func (m *ServerInfoRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return dAtA[:n], nil
 }
 
-func (m *LeaderEpochOffsetRequest) Size() (n int) {
+func (m *ServerInfoRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
 	var l int
 	_ = l
-	if m.LeaderEpoch != 0 {
-		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	if len(m.Id) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Id)))
+		i += copy(dAtA[i:], m.Id)
 	}
-	return n
+	return i, nil
 }
 
-func (m *LeaderEpochOffsetResponse) Size() (n int) {
+func (m *ServerInfoResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ServerInfoResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
 	var l int
 	_ = l
-	if m.EndOffset != 0 {
-		n += 1 + sovInternal(uint64(m.EndOffset))
+	if len(m.Id) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Id)))
+		i += copy(dAtA[i:], m.Id)
 	}
-	return n
+	if len(m.Host) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Host)))
+		i += copy(dAtA[i:], m.Host)
+	}
+	if m.Port != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Port))
+	}
+	return i, nil
 }
 
-func (m *PropagatedRequest) Size() (n int) {
+func (m *PartitionStatusRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PartitionStatusRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
 	var l int
 	_ = l
-	if m.Op != 0 {
-		n += 1 + sovInternal(uint64(m.Op))
+	if len(m.Stream) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
 	}
-	if m.CreatePartitionOp != nil {
-		l = m.CreatePartitionOp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+	if m.Partition != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
 	}
-	if m.ShrinkISROp != nil {
-		l = m.ShrinkISROp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+	return i, nil
+}
+
+func (m *PartitionStatusResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
 	}
-	if m.ReportLeaderOp != nil {
-		l = m.ReportLeaderOp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+	return dAtA[:n], nil
+}
+
+func (m *PartitionStatusResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Exists {
+		dAtA[i] = 0x8
+		i++
+		if m.Exists {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
 	}
-	if m.ExpandISROp != nil {
-		l = m.ExpandISROp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+	if m.IsLeader {
+		dAtA[i] = 0x10
+		i++
+		if m.IsLeader {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
 	}
-	if m.DeleteStreamOp != nil {
-		l = m.DeleteStreamOp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+	if m.NewestOffset != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.NewestOffset))
 	}
-	if m.PauseStreamOp != nil {
-		l = m.PauseStreamOp.Size()
-		n += 1 + l + sovInternal(uint64(l))
+	if m.HighWatermark != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.HighWatermark))
 	}
-	return n
+	if m.LastLeaderEpoch != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.LastLeaderEpoch))
+	}
+	return i, nil
 }
 
-func (m *Error) Size() (n int) {
+func (m *PartitionNotification) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PartitionNotification) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
 	var l int
 	_ = l
-	if m.Code != 0 {
-		n += 1 + sovInternal(uint64(m.Code))
+	if len(m.Stream) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
 	}
-	l = len(m.Msg)
-	if l > 0 {
-		n += 1 + l + sovInternal(uint64(l))
+	if m.Partition != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
 	}
-	return n
+	if m.Truncate {
+		dAtA[i] = 0x18
+		i++
+		if m.Truncate {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	return i, nil
 }
 
-func (m *PropagatedResponse) Size() (n int) {
+func (m *PublishRejection) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PublishRejection) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
 	var l int
 	_ = l
-	if m.Op != 0 {
-		n += 1 + sovInternal(uint64(m.Op))
+	if len(m.Stream) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Stream)))
+		i += copy(dAtA[i:], m.Stream)
 	}
-	if m.Error != nil {
-		l = m.Error.Size()
-		n += 1 + l + sovInternal(uint64(l))
+	if m.Partition != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Partition))
 	}
-	return n
+	if len(m.CorrelationId) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.CorrelationId)))
+		i += copy(dAtA[i:], m.CorrelationId)
+	}
+	if len(m.Reason) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(len(m.Reason)))
+		i += copy(dAtA[i:], m.Reason)
+	}
+	if m.Code != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintInternal(dAtA, i, uint64(m.Code))
+	}
+	return i, nil
 }
 
-func (m *ServerInfoRequest) Size() (n int) {
+func encodeVarintInternal(dAtA []byte, offset int, v uint64) int {
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return offset + 1
+}
+func (m *ServerState) Size() (n int) {
 	var l int
 	_ = l
-	l = len(m.Id)
+	l = len(m.ServerID)
 	if l > 0 {
 		n += 1 + l + sovInternal(uint64(l))
 	}
 	return n
 }
 
-func (m *ServerInfoResponse) Size() (n int) {
+func (m *ExportState) Size() (n int) {
 	var l int
 	_ = l
-	l = len(m.Id)
+	l = len(m.Name)
 	if l > 0 {
 		n += 1 + l + sovInternal(uint64(l))
 	}
-	l = len(m.Host)
+	l = len(m.Stream)
 	if l > 0 {
 		n += 1 + l + sovInternal(uint64(l))
 	}
-	if m.Port != 0 {
-		n += 1 + sovInternal(uint64(m.Port))
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	l = len(m.Subject)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Offset != 0 {
+		n += 1 + sovInternal(uint64(m.Offset))
 	}
 	return n
 }
 
-func (m *PartitionStatusRequest) Size() (n int) {
+func (m *ImportState) Size() (n int) {
 	var l int
 	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	l = len(m.Subject)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
 	l = len(m.Stream)
 	if l > 0 {
 		n += 1 + l + sovInternal(uint64(l))
@@ -2214,48 +3727,3426 @@ func (m *PartitionStatusRequest) Size() (n int) {
 	if m.Partition != 0 {
 		n += 1 + sovInternal(uint64(m.Partition))
 	}
+	if m.Position != 0 {
+		n += 1 + sovInternal(uint64(m.Position))
+	}
 	return n
 }
 
-func (m *PartitionStatusResponse) Size() (n int) {
+func (m *RaftLog) Size() (n int) {
 	var l int
 	_ = l
-	if m.Exists {
-		n += 2
+	if m.Op != 0 {
+		n += 1 + sovInternal(uint64(m.Op))
 	}
-	if m.IsLeader {
-		n += 2
+	if m.CreatePartitionOp != nil {
+		l = m.CreatePartitionOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
 	}
-	return n
-}
-
-func (m *PartitionNotification) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.Stream)
-	if l > 0 {
+	if m.ShrinkISROp != nil {
+		l = m.ShrinkISROp.Size()
 		n += 1 + l + sovInternal(uint64(l))
 	}
-	if m.Partition != 0 {
+	if m.ChangeLeaderOp != nil {
+		l = m.ChangeLeaderOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ExpandISROp != nil {
+		l = m.ExpandISROp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.DeleteStreamOp != nil {
+		l = m.DeleteStreamOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.PauseStreamOp != nil {
+		l = m.PauseStreamOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.CreateStreamOp != nil {
+		l = m.CreateStreamOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.RenameStreamOp != nil {
+		l = m.RenameStreamOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.TrimStreamOp != nil {
+		l = m.TrimStreamOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.CreateStreamsOp != nil {
+		l = m.CreateStreamsOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ChangeReplicaRoleOp != nil {
+		l = m.ChangeReplicaRoleOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.SetServerReadOnlyOp != nil {
+		l = m.SetServerReadOnlyOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ChangeReplicasOp != nil {
+		l = m.ChangeReplicasOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.SwapStreamsOp != nil {
+		l = m.SwapStreamsOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.FencingToken != 0 {
+		n += 2 + sovInternal(uint64(m.FencingToken))
+	}
+	return n
+}
+
+func (m *CreatePartitionOp) Size() (n int) {
+	var l int
+	_ = l
+	if m.Partition != nil {
+		l = m.Partition.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	return n
+}
+
+func (m *CreateStreamOp) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Partitions) > 0 {
+		for _, e := range m.Partitions {
+			l = e.Size()
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	if len(m.Servers) > 0 {
+		for _, s := range m.Servers {
+			l = len(s)
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	if len(m.Observers) > 0 {
+		for _, s := range m.Observers {
+			l = len(s)
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *CreateStreamsOp) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Streams) > 0 {
+		for _, e := range m.Streams {
+			l = e.Size()
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *ShrinkISROp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	l = len(m.ReplicaToRemove)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	l = len(m.Leader)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.LeaderEpoch != 0 {
+		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	}
+	return n
+}
+
+func (m *ExpandISROp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	l = len(m.ReplicaToAdd)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	l = len(m.Leader)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.LeaderEpoch != 0 {
+		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	}
+	return n
+}
+
+func (m *DeleteStreamOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	return n
+}
+
+func (m *RenameStreamOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	l = len(m.NewName)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	return n
+}
+
+func (m *SwapStreamsOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	l = len(m.Other)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	return n
+}
+
+func (m *TrimStreamOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Offset != 0 {
+		n += 1 + sovInternal(uint64(m.Offset))
+	}
+	return n
+}
+
+func (m *PauseStreamOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if len(m.Partitions) > 0 {
+		l = 0
+		for _, e := range m.Partitions {
+			l += sovInternal(uint64(e))
+		}
+		n += 1 + sovInternal(uint64(l)) + l
+	}
+	if m.ResumeAll {
+		n += 2
+	}
+	return n
+}
+
+func (m *ChangeReplicaRoleOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	l = len(m.Replica)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Observer {
+		n += 2
+	}
+	return n
+}
+
+func (m *ChangeReplicasOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	if len(m.Replicas) > 0 {
+		for _, s := range m.Replicas {
+			l = len(s)
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *ReportLeaderOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	l = len(m.Replica)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	l = len(m.Leader)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.LeaderEpoch != 0 {
+		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	}
+	return n
+}
+
+func (m *ReportActivityOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
 		n += 1 + sovInternal(uint64(m.Partition))
 	}
-	return n
-}
+	return n
+}
+
+func (m *ConfirmLeaderOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	l = len(m.Leader)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.LeaderEpoch != 0 {
+		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	}
+	return n
+}
+
+func (m *SetServerReadOnlyOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Server)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ReadOnly {
+		n += 2
+	}
+	return n
+}
+
+func (m *ChangeLeaderOp) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	l = len(m.Leader)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	return n
+}
+
+func (m *Partition) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Subject)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Id != 0 {
+		n += 1 + sovInternal(uint64(m.Id))
+	}
+	l = len(m.Group)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ReplicationFactor != 0 {
+		n += 1 + sovInternal(uint64(m.ReplicationFactor))
+	}
+	if len(m.Replicas) > 0 {
+		for _, s := range m.Replicas {
+			l = len(s)
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	l = len(m.Leader)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if len(m.Isr) > 0 {
+		for _, s := range m.Isr {
+			l = len(s)
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	if m.LeaderEpoch != 0 {
+		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	}
+	if m.Epoch != 0 {
+		n += 1 + sovInternal(uint64(m.Epoch))
+	}
+	if m.ReplicaMaxLagTime != 0 {
+		n += 1 + sovInternal(uint64(m.ReplicaMaxLagTime))
+	}
+	if m.ReplicaFetchTimeout != 0 {
+		n += 1 + sovInternal(uint64(m.ReplicaFetchTimeout))
+	}
+	if m.RequireKey {
+		n += 2
+	}
+	if m.EmptyValue != 0 {
+		n += 1 + sovInternal(uint64(m.EmptyValue))
+	}
+	if len(m.Observers) > 0 {
+		for _, s := range m.Observers {
+			l = len(s)
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	l = len(m.Schema)
+	if l > 0 {
+		n += 2 + l + sovInternal(uint64(l))
+	}
+	if m.IdleDeleteTime != 0 {
+		n += 2 + sovInternal(uint64(m.IdleDeleteTime))
+	}
+	if m.ReplicaMaxLagOffsets != 0 {
+		n += 2 + sovInternal(uint64(m.ReplicaMaxLagOffsets))
+	}
+	if m.MaxCommitWaiters != 0 {
+		n += 2 + sovInternal(uint64(m.MaxCommitWaiters))
+	}
+	if m.RetentionMaxAge != 0 {
+		n += 2 + sovInternal(uint64(m.RetentionMaxAge))
+	}
+	if m.QuotaMaxBytes != 0 {
+		n += 2 + sovInternal(uint64(m.QuotaMaxBytes))
+	}
+	if m.TrimOffset != 0 {
+		n += 2 + sovInternal(uint64(m.TrimOffset))
+	}
+	if len(m.PinnedServers) > 0 {
+		for _, s := range m.PinnedServers {
+			l = len(s)
+			n += 2 + l + sovInternal(uint64(l))
+		}
+	}
+	if m.RetentionMaxSegments != 0 {
+		n += 2 + sovInternal(uint64(m.RetentionMaxSegments))
+	}
+	if m.CompressionThreshold != 0 {
+		n += 2 + sovInternal(uint64(m.CompressionThreshold))
+	}
+	return n
+}
+
+func (m *RaftJoinRequest) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.NodeID)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	l = len(m.NodeAddr)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	return n
+}
+
+func (m *RaftJoinResponse) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Error)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	return n
+}
+
+func (m *MetadataSnapshot) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Partitions) > 0 {
+		for _, e := range m.Partitions {
+			l = e.Size()
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	if len(m.ReadOnlyServers) > 0 {
+		for _, s := range m.ReadOnlyServers {
+			l = len(s)
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	if m.FencingEpoch != 0 {
+		n += 1 + sovInternal(uint64(m.FencingEpoch))
+	}
+	return n
+}
+
+func (m *ReplicationRequest) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.ReplicaID)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Offset != 0 {
+		n += 1 + sovInternal(uint64(m.Offset))
+	}
+	if m.LeaderEpoch != 0 {
+		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	}
+	if m.SyncFollower {
+		n += 2
+	}
+	if m.ReportOnly {
+		n += 2
+	}
+	return n
+}
+
+func (m *LeaderEpochOffsetRequest) Size() (n int) {
+	var l int
+	_ = l
+	if m.LeaderEpoch != 0 {
+		n += 1 + sovInternal(uint64(m.LeaderEpoch))
+	}
+	return n
+}
+
+func (m *LeaderEpochOffsetResponse) Size() (n int) {
+	var l int
+	_ = l
+	if m.EndOffset != 0 {
+		n += 1 + sovInternal(uint64(m.EndOffset))
+	}
+	return n
+}
+
+func (m *PropagatedRequest) Size() (n int) {
+	var l int
+	_ = l
+	if m.Op != 0 {
+		n += 1 + sovInternal(uint64(m.Op))
+	}
+	if m.CreatePartitionOp != nil {
+		l = m.CreatePartitionOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ShrinkISROp != nil {
+		l = m.ShrinkISROp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ReportLeaderOp != nil {
+		l = m.ReportLeaderOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ExpandISROp != nil {
+		l = m.ExpandISROp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.DeleteStreamOp != nil {
+		l = m.DeleteStreamOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.PauseStreamOp != nil {
+		l = m.PauseStreamOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.CreateStreamOp != nil {
+		l = m.CreateStreamOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.RenameStreamOp != nil {
+		l = m.RenameStreamOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.TrimStreamOp != nil {
+		l = m.TrimStreamOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.CreateStreamsOp != nil {
+		l = m.CreateStreamsOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ChangeReplicaRoleOp != nil {
+		l = m.ChangeReplicaRoleOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ReportActivityOp != nil {
+		l = m.ReportActivityOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.SetServerReadOnlyOp != nil {
+		l = m.SetServerReadOnlyOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ChangeReplicasOp != nil {
+		l = m.ChangeReplicasOp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.ChangeLeaderOp != nil {
+		l = m.ChangeLeaderOp.Size()
+		n += 2 + l + sovInternal(uint64(l))
+	}
+	if m.SwapStreamsOp != nil {
+		l = m.SwapStreamsOp.Size()
+		n += 2 + l + sovInternal(uint64(l))
+	}
+	if m.ConfirmLeaderOp != nil {
+		l = m.ConfirmLeaderOp.Size()
+		n += 2 + l + sovInternal(uint64(l))
+	}
+	if m.FencingToken != 0 {
+		n += 2 + sovInternal(uint64(m.FencingToken))
+	}
+	return n
+}
+
+func (m *Error) Size() (n int) {
+	var l int
+	_ = l
+	if m.Code != 0 {
+		n += 1 + sovInternal(uint64(m.Code))
+	}
+	l = len(m.Msg)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	return n
+}
+
+func (m *PropagatedResponse) Size() (n int) {
+	var l int
+	_ = l
+	if m.Op != 0 {
+		n += 1 + sovInternal(uint64(m.Op))
+	}
+	if m.Error != nil {
+		l = m.Error.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.CreateStreamsResp != nil {
+		l = m.CreateStreamsResp.Size()
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	return n
+}
+
+func (m *CreateStreamsResponse) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Results) > 0 {
+		for _, e := range m.Results {
+			l = e.Size()
+			n += 1 + l + sovInternal(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *ServerInfoRequest) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Id)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	return n
+}
+
+func (m *ServerInfoResponse) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Id)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	l = len(m.Host)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Port != 0 {
+		n += 1 + sovInternal(uint64(m.Port))
+	}
+	return n
+}
+
+func (m *PartitionStatusRequest) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	return n
+}
+
+func (m *PartitionStatusResponse) Size() (n int) {
+	var l int
+	_ = l
+	if m.Exists {
+		n += 2
+	}
+	if m.IsLeader {
+		n += 2
+	}
+	if m.NewestOffset != 0 {
+		n += 1 + sovInternal(uint64(m.NewestOffset))
+	}
+	if m.HighWatermark != 0 {
+		n += 1 + sovInternal(uint64(m.HighWatermark))
+	}
+	if m.LastLeaderEpoch != 0 {
+		n += 1 + sovInternal(uint64(m.LastLeaderEpoch))
+	}
+	return n
+}
+
+func (m *PartitionNotification) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	if m.Truncate {
+		n += 2
+	}
+	return n
+}
+
+func (m *PublishRejection) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Stream)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Partition != 0 {
+		n += 1 + sovInternal(uint64(m.Partition))
+	}
+	l = len(m.CorrelationId)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	l = len(m.Reason)
+	if l > 0 {
+		n += 1 + l + sovInternal(uint64(l))
+	}
+	if m.Code != 0 {
+		n += 1 + sovInternal(uint64(m.Code))
+	}
+	return n
+}
+
+func sovInternal(x uint64) (n int) {
+	for {
+		n++
+		x >>= 7
+		if x == 0 {
+			break
+		}
+	}
+	return n
+}
+func sozInternal(x uint64) (n int) {
+	return sovInternal(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *ServerState) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ServerState: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ServerState: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ServerID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ServerID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ExportState) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ExportState: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ExportState: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			}
+			m.Partition = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Partition |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Subject", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Subject = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
+			}
+			m.Offset = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Offset |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ImportState) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ImportState: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ImportState: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Subject", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Subject = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			}
+			m.Partition = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Partition |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Position", wireType)
+			}
+			m.Position = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Position |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *RaftLog) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RaftLog: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RaftLog: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Op", wireType)
+			}
+			m.Op = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Op |= (Op(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreatePartitionOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CreatePartitionOp == nil {
+				m.CreatePartitionOp = &CreatePartitionOp{}
+			}
+			if err := m.CreatePartitionOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ShrinkISROp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ShrinkISROp == nil {
+				m.ShrinkISROp = &ShrinkISROp{}
+			}
+			if err := m.ShrinkISROp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChangeLeaderOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ChangeLeaderOp == nil {
+				m.ChangeLeaderOp = &ChangeLeaderOp{}
+			}
+			if err := m.ChangeLeaderOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpandISROp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ExpandISROp == nil {
+				m.ExpandISROp = &ExpandISROp{}
+			}
+			if err := m.ExpandISROp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DeleteStreamOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.DeleteStreamOp == nil {
+				m.DeleteStreamOp = &DeleteStreamOp{}
+			}
+			if err := m.DeleteStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PauseStreamOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.PauseStreamOp == nil {
+				m.PauseStreamOp = &PauseStreamOp{}
+			}
+			if err := m.PauseStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreateStreamOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CreateStreamOp == nil {
+				m.CreateStreamOp = &CreateStreamOp{}
+			}
+			if err := m.CreateStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RenameStreamOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.RenameStreamOp == nil {
+				m.RenameStreamOp = &RenameStreamOp{}
+			}
+			if err := m.RenameStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TrimStreamOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.TrimStreamOp == nil {
+				m.TrimStreamOp = &TrimStreamOp{}
+			}
+			if err := m.TrimStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreateStreamsOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CreateStreamsOp == nil {
+				m.CreateStreamsOp = &CreateStreamsOp{}
+			}
+			if err := m.CreateStreamsOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChangeReplicaRoleOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ChangeReplicaRoleOp == nil {
+				m.ChangeReplicaRoleOp = &ChangeReplicaRoleOp{}
+			}
+			if err := m.ChangeReplicaRoleOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SetServerReadOnlyOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.SetServerReadOnlyOp == nil {
+				m.SetServerReadOnlyOp = &SetServerReadOnlyOp{}
+			}
+			if err := m.SetServerReadOnlyOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 14:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChangeReplicasOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ChangeReplicasOp == nil {
+				m.ChangeReplicasOp = &ChangeReplicasOp{}
+			}
+			if err := m.ChangeReplicasOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SwapStreamsOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.SwapStreamsOp == nil {
+				m.SwapStreamsOp = &SwapStreamsOp{}
+			}
+			if err := m.SwapStreamsOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 16:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FencingToken", wireType)
+			}
+			m.FencingToken = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FencingToken |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *CreatePartitionOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CreatePartitionOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CreatePartitionOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Partition == nil {
+				m.Partition = &Partition{}
+			}
+			if err := m.Partition.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *CreateStreamOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CreateStreamOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CreateStreamOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partitions", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Partitions = append(m.Partitions, &Partition{})
+			if err := m.Partitions[len(m.Partitions)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Servers", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Servers = append(m.Servers, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Observers", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Observers = append(m.Observers, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *CreateStreamsOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CreateStreamsOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CreateStreamsOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Streams", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Streams = append(m.Streams, &CreateStreamOp{})
+			if err := m.Streams[len(m.Streams)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ShrinkISROp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ShrinkISROp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ShrinkISROp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			}
+			m.Partition = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Partition |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplicaToRemove", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ReplicaToRemove = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Leader = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
+			}
+			m.LeaderEpoch = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ExpandISROp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ExpandISROp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ExpandISROp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			}
+			m.Partition = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Partition |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplicaToAdd", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ReplicaToAdd = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Leader = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
+			}
+			m.LeaderEpoch = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DeleteStreamOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DeleteStreamOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DeleteStreamOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *RenameStreamOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RenameStreamOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RenameStreamOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NewName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.NewName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SwapStreamsOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SwapStreamsOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SwapStreamsOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Other", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Other = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TrimStreamOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TrimStreamOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TrimStreamOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
+			}
+			m.Offset = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Offset |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PauseStreamOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PauseStreamOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PauseStreamOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType == 0 {
+				var v int32
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowInternal
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= (int32(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.Partitions = append(m.Partitions, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowInternal
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthInternal
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				for iNdEx < postIndex {
+					var v int32
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowInternal
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= (int32(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.Partitions = append(m.Partitions, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partitions", wireType)
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ResumeAll", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ResumeAll = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ChangeReplicaRoleOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ChangeReplicaRoleOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ChangeReplicaRoleOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			}
+			m.Partition = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Partition |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Replica", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Replica = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Observer", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Observer = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ChangeReplicasOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ChangeReplicasOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ChangeReplicasOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			}
+			m.Partition = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Partition |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Replicas", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Replicas = append(m.Replicas, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ReportLeaderOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ReportLeaderOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ReportLeaderOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			}
+			m.Partition = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Partition |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Replica", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Replica = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Leader = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
+			}
+			m.LeaderEpoch = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
 
-func sovInternal(x uint64) (n int) {
-	for {
-		n++
-		x >>= 7
-		if x == 0 {
-			break
-		}
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	return n
-}
-func sozInternal(x uint64) (n int) {
-	return sovInternal(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+	return nil
 }
-func (m *ServerState) Unmarshal(dAtA []byte) error {
+func (m *ReportActivityOp) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2278,15 +7169,15 @@ func (m *ServerState) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ServerState: wiretype end group for non-group")
+			return fmt.Errorf("proto: ReportActivityOp: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ServerState: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ReportActivityOp: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ServerID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2311,8 +7202,27 @@ func (m *ServerState) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ServerID = string(dAtA[iNdEx:postIndex])
+			m.Stream = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			}
+			m.Partition = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Partition |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipInternal(dAtA[iNdEx:])
@@ -2334,7 +7244,7 @@ func (m *ServerState) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *RaftLog) Unmarshal(dAtA []byte) error {
+func (m *ConfirmLeaderOp) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2357,36 +7267,17 @@ func (m *RaftLog) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: RaftLog: wiretype end group for non-group")
+			return fmt.Errorf("proto: ConfirmLeaderOp: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RaftLog: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ConfirmLeaderOp: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Op", wireType)
-			}
-			m.Op = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowInternal
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Op |= (Op(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field CreatePartitionOp", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2396,30 +7287,26 @@ func (m *RaftLog) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.CreatePartitionOp == nil {
-				m.CreatePartitionOp = &CreatePartitionOp{}
-			}
-			if err := m.CreatePartitionOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ShrinkISROp", wireType)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
 			}
-			var msglen int
+			m.Partition = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2429,30 +7316,16 @@ func (m *RaftLog) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				m.Partition |= (int32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthInternal
-			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.ShrinkISROp == nil {
-				m.ShrinkISROp = &ShrinkISROp{}
-			}
-			if err := m.ShrinkISROp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		case 4:
+		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ChangeLeaderOp", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2462,30 +7335,26 @@ func (m *RaftLog) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.ChangeLeaderOp == nil {
-				m.ChangeLeaderOp = &ChangeLeaderOp{}
-			}
-			if err := m.ChangeLeaderOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Leader = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 5:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ExpandISROp", wireType)
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
 			}
-			var msglen int
+			m.LeaderEpoch = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2495,30 +7364,66 @@ func (m *RaftLog) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
 				return ErrInvalidLengthInternal
 			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.ExpandISROp == nil {
-				m.ExpandISROp = &ExpandISROp{}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SetServerReadOnlyOp) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
 			}
-			if err := m.ExpandISROp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
 			}
-			iNdEx = postIndex
-		case 6:
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SetServerReadOnlyOp: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SetServerReadOnlyOp: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field DeleteStreamOp", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Server", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2528,58 +7433,41 @@ func (m *RaftLog) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.DeleteStreamOp == nil {
-				m.DeleteStreamOp = &DeleteStreamOp{}
-			}
-			if err := m.DeleteStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Server = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 7:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field PauseStreamOp", wireType)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReadOnly", wireType)
 			}
-			var msglen int
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
 				}
 				if iNdEx >= l {
 					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthInternal
-			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if m.PauseStreamOp == nil {
-				m.PauseStreamOp = &PauseStreamOp{}
-			}
-			if err := m.PauseStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			iNdEx = postIndex
+			m.ReadOnly = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipInternal(dAtA[iNdEx:])
@@ -2601,7 +7489,7 @@ func (m *RaftLog) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *CreatePartitionOp) Unmarshal(dAtA []byte) error {
+func (m *ChangeLeaderOp) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2624,17 +7512,17 @@ func (m *CreatePartitionOp) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: CreatePartitionOp: wiretype end group for non-group")
+			return fmt.Errorf("proto: ChangeLeaderOp: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: CreatePartitionOp: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ChangeLeaderOp: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2644,24 +7532,68 @@ func (m *CreatePartitionOp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Partition == nil {
-				m.Partition = &Partition{}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
 			}
-			if err := m.Partition.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.Partition = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Partition |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
 			}
+			m.Leader = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -2684,7 +7616,7 @@ func (m *CreatePartitionOp) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *ShrinkISROp) Unmarshal(dAtA []byte) error {
+func (m *Partition) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2707,13 +7639,42 @@ func (m *ShrinkISROp) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ShrinkISROp: wiretype end group for non-group")
+			return fmt.Errorf("proto: Partition: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ShrinkISROp: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Partition: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Subject", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Subject = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
 			}
@@ -2742,11 +7703,11 @@ func (m *ShrinkISROp) Unmarshal(dAtA []byte) error {
 			}
 			m.Stream = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 2:
+		case 3:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
 			}
-			m.Partition = 0
+			m.Id = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2756,14 +7717,14 @@ func (m *ShrinkISROp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Partition |= (int32(b) & 0x7F) << shift
+				m.Id |= (int32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
+		case 4:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReplicaToRemove", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Group", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2788,11 +7749,30 @@ func (m *ShrinkISROp) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ReplicaToRemove = string(dAtA[iNdEx:postIndex])
+			m.Group = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 4:
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplicationFactor", wireType)
+			}
+			m.ReplicationFactor = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ReplicationFactor |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Replicas", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2817,13 +7797,13 @@ func (m *ShrinkISROp) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Leader = string(dAtA[iNdEx:postIndex])
+			m.Replicas = append(m.Replicas, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
-		case 5:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
 			}
-			m.LeaderEpoch = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2833,64 +7813,24 @@ func (m *ShrinkISROp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipInternal(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *ExpandISROp) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowInternal
-			}
-			if iNdEx >= l {
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: ExpandISROp: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ExpandISROp: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			m.Leader = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 8:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Isr", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2915,13 +7855,51 @@ func (m *ExpandISROp) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Stream = string(dAtA[iNdEx:postIndex])
+			m.Isr = append(m.Isr, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
-		case 2:
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
+			}
+			m.LeaderEpoch = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Epoch", wireType)
+			}
+			m.Epoch = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Epoch |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 11:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplicaMaxLagTime", wireType)
 			}
-			m.Partition = 0
+			m.ReplicaMaxLagTime = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2931,16 +7909,16 @@ func (m *ExpandISROp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Partition |= (int32(b) & 0x7F) << shift
+				m.ReplicaMaxLagTime |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReplicaToAdd", wireType)
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplicaFetchTimeout", wireType)
 			}
-			var stringLen uint64
+			m.ReplicaFetchTimeout = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2950,26 +7928,16 @@ func (m *ExpandISROp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				m.ReplicaFetchTimeout |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthInternal
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.ReplicaToAdd = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 4:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequireKey", wireType)
 			}
-			var stringLen uint64
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -2979,26 +7947,17 @@ func (m *ExpandISROp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthInternal
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Leader = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 5:
+			m.RequireKey = bool(v != 0)
+		case 14:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field EmptyValue", wireType)
 			}
-			m.LeaderEpoch = 0
+			m.EmptyValue = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3008,64 +7967,14 @@ func (m *ExpandISROp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
+				m.EmptyValue |= (EmptyValue(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipInternal(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthInternal
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *DeleteStreamOp) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowInternal
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: DeleteStreamOp: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: DeleteStreamOp: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+		case 15:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Observers", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -3090,61 +7999,11 @@ func (m *DeleteStreamOp) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Stream = string(dAtA[iNdEx:postIndex])
+			m.Observers = append(m.Observers, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipInternal(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthInternal
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *PauseStreamOp) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowInternal
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: PauseStreamOp: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: PauseStreamOp: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+		case 16:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Schema", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -3169,75 +8028,13 @@ func (m *PauseStreamOp) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Stream = string(dAtA[iNdEx:postIndex])
+			m.Schema = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 2:
-			if wireType == 0 {
-				var v int32
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return ErrIntOverflowInternal
-					}
-					if iNdEx >= l {
-						return io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					v |= (int32(b) & 0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				m.Partitions = append(m.Partitions, v)
-			} else if wireType == 2 {
-				var packedLen int
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return ErrIntOverflowInternal
-					}
-					if iNdEx >= l {
-						return io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					packedLen |= (int(b) & 0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				if packedLen < 0 {
-					return ErrInvalidLengthInternal
-				}
-				postIndex := iNdEx + packedLen
-				if postIndex > l {
-					return io.ErrUnexpectedEOF
-				}
-				for iNdEx < postIndex {
-					var v int32
-					for shift := uint(0); ; shift += 7 {
-						if shift >= 64 {
-							return ErrIntOverflowInternal
-						}
-						if iNdEx >= l {
-							return io.ErrUnexpectedEOF
-						}
-						b := dAtA[iNdEx]
-						iNdEx++
-						v |= (int32(b) & 0x7F) << shift
-						if b < 0x80 {
-							break
-						}
-					}
-					m.Partitions = append(m.Partitions, v)
-				}
-			} else {
-				return fmt.Errorf("proto: wrong wireType = %d for field Partitions", wireType)
-			}
-		case 3:
+		case 17:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ResumeAll", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field IdleDeleteTime", wireType)
 			}
-			var v int
+			m.IdleDeleteTime = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3247,67 +8044,16 @@ func (m *PauseStreamOp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= (int(b) & 0x7F) << shift
+				m.IdleDeleteTime |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
-				}
-			}
-			m.ResumeAll = bool(v != 0)
-		default:
-			iNdEx = preIndex
-			skippy, err := skipInternal(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthInternal
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *ReportLeaderOp) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowInternal
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
+				}
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: ReportLeaderOp: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ReportLeaderOp: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+		case 18:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplicaMaxLagOffsets", wireType)
 			}
-			var stringLen uint64
+			m.ReplicaMaxLagOffsets = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3317,26 +8063,35 @@ func (m *ReportLeaderOp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				m.ReplicaMaxLagOffsets |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthInternal
+		case 19:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxCommitWaiters", wireType)
 			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+			m.MaxCommitWaiters = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxCommitWaiters |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			m.Stream = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 2:
+		case 20:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field RetentionMaxAge", wireType)
 			}
-			m.Partition = 0
+			m.RetentionMaxAge = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3346,16 +8101,16 @@ func (m *ReportLeaderOp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Partition |= (int32(b) & 0x7F) << shift
+				m.RetentionMaxAge |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Replica", wireType)
+		case 21:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuotaMaxBytes", wireType)
 			}
-			var stringLen uint64
+			m.QuotaMaxBytes = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3365,24 +8120,33 @@ func (m *ReportLeaderOp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				m.QuotaMaxBytes |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthInternal
+		case 22:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TrimOffset", wireType)
 			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+			m.TrimOffset = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TrimOffset |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			m.Replica = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 4:
+		case 23:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PinnedServers", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -3407,13 +8171,13 @@ func (m *ReportLeaderOp) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Leader = string(dAtA[iNdEx:postIndex])
+			m.PinnedServers = append(m.PinnedServers, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
-		case 5:
+		case 24:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field RetentionMaxSegments", wireType)
 			}
-			m.LeaderEpoch = 0
+			m.RetentionMaxSegments = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3423,7 +8187,26 @@ func (m *ReportLeaderOp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
+				m.RetentionMaxSegments |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 25:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CompressionThreshold", wireType)
+			}
+			m.CompressionThreshold = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CompressionThreshold |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -3449,7 +8232,7 @@ func (m *ReportLeaderOp) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *ChangeLeaderOp) Unmarshal(dAtA []byte) error {
+func (m *RaftJoinRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3472,15 +8255,15 @@ func (m *ChangeLeaderOp) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ChangeLeaderOp: wiretype end group for non-group")
+			return fmt.Errorf("proto: RaftJoinRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ChangeLeaderOp: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: RaftJoinRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field NodeID", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -3505,13 +8288,13 @@ func (m *ChangeLeaderOp) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Stream = string(dAtA[iNdEx:postIndex])
+			m.NodeID = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NodeAddr", wireType)
 			}
-			m.Partition = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3521,14 +8304,74 @@ func (m *ChangeLeaderOp) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Partition |= (int32(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.NodeAddr = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *RaftJoinResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RaftJoinResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RaftJoinResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -3553,7 +8396,7 @@ func (m *ChangeLeaderOp) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Leader = string(dAtA[iNdEx:postIndex])
+			m.Error = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -3576,7 +8419,7 @@ func (m *ChangeLeaderOp) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Partition) Unmarshal(dAtA []byte) error {
+func (m *MetadataSnapshot) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3599,17 +8442,17 @@ func (m *Partition) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Partition: wiretype end group for non-group")
+			return fmt.Errorf("proto: MetadataSnapshot: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Partition: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: MetadataSnapshot: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Subject", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Partitions", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3619,24 +8462,26 @@ func (m *Partition) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Subject = string(dAtA[iNdEx:postIndex])
+			m.Partitions = append(m.Partitions, &Partition{})
+			if err := m.Partitions[len(m.Partitions)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ReadOnlyServers", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -3661,13 +8506,13 @@ func (m *Partition) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Stream = string(dAtA[iNdEx:postIndex])
+			m.ReadOnlyServers = append(m.ReadOnlyServers, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
 		case 3:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field FencingEpoch", wireType)
 			}
-			m.Id = 0
+			m.FencingEpoch = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3677,62 +8522,64 @@ func (m *Partition) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Id |= (int32(b) & 0x7F) << shift
+				m.FencingEpoch |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 4:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Group", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowInternal
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if skippy < 0 {
 				return ErrInvalidLengthInternal
 			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Group = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 5:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReplicationFactor", wireType)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ReplicationRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
 			}
-			m.ReplicationFactor = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowInternal
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.ReplicationFactor |= (int32(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
 			}
-		case 6:
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ReplicationRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ReplicationRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Replicas", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplicaID", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -3757,13 +8604,13 @@ func (m *Partition) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Replicas = append(m.Replicas, string(dAtA[iNdEx:postIndex]))
+			m.ReplicaID = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 7:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Leader", wireType)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
 			}
-			var stringLen uint64
+			m.Offset = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3773,26 +8620,16 @@ func (m *Partition) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				m.Offset |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthInternal
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Leader = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 8:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Isr", wireType)
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
 			}
-			var stringLen uint64
+			m.LeaderEpoch = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3802,26 +8639,16 @@ func (m *Partition) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthInternal
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Isr = append(m.Isr, string(dAtA[iNdEx:postIndex]))
-			iNdEx = postIndex
-		case 9:
+		case 4:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field SyncFollower", wireType)
 			}
-			m.LeaderEpoch = 0
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3831,16 +8658,17 @@ func (m *Partition) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 10:
+			m.SyncFollower = bool(v != 0)
+		case 5:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Epoch", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ReportOnly", wireType)
 			}
-			m.Epoch = 0
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3850,11 +8678,12 @@ func (m *Partition) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Epoch |= (uint64(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			m.ReportOnly = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipInternal(dAtA[iNdEx:])
@@ -3876,7 +8705,7 @@ func (m *Partition) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *RaftJoinRequest) Unmarshal(dAtA []byte) error {
+func (m *LeaderEpochOffsetRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3899,46 +8728,17 @@ func (m *RaftJoinRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: RaftJoinRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: LeaderEpochOffsetRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RaftJoinRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: LeaderEpochOffsetRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field NodeID", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowInternal
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthInternal
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.NodeID = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field NodeAddr", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
 			}
-			var stringLen uint64
+			m.LeaderEpoch = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -3948,21 +8748,11 @@ func (m *RaftJoinRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthInternal
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.NodeAddr = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipInternal(dAtA[iNdEx:])
@@ -3984,7 +8774,7 @@ func (m *RaftJoinRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *RaftJoinResponse) Unmarshal(dAtA []byte) error {
+func (m *LeaderEpochOffsetResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4007,17 +8797,17 @@ func (m *RaftJoinResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: RaftJoinResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: LeaderEpochOffsetResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RaftJoinResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: LeaderEpochOffsetResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EndOffset", wireType)
 			}
-			var stringLen uint64
+			m.EndOffset = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -4027,21 +8817,11 @@ func (m *RaftJoinResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				m.EndOffset |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthInternal
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Error = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipInternal(dAtA[iNdEx:])
@@ -4063,7 +8843,7 @@ func (m *RaftJoinResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *MetadataSnapshot) Unmarshal(dAtA []byte) error {
+func (m *PropagatedRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4073,28 +8853,113 @@ func (m *MetadataSnapshot) Unmarshal(dAtA []byte) error {
 			if shift >= 64 {
 				return ErrIntOverflowInternal
 			}
-			if iNdEx >= l {
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PropagatedRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PropagatedRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Op", wireType)
+			}
+			m.Op = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Op |= (Op(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreatePartitionOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CreatePartitionOp == nil {
+				m.CreatePartitionOp = &CreatePartitionOp{}
+			}
+			if err := m.CreatePartitionOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ShrinkISROp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
+			if m.ShrinkISROp == nil {
+				m.ShrinkISROp = &ShrinkISROp{}
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: MetadataSnapshot: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MetadataSnapshot: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			if err := m.ShrinkISROp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Partitions", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ReportLeaderOp", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4118,66 +8983,51 @@ func (m *MetadataSnapshot) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Partitions = append(m.Partitions, &Partition{})
-			if err := m.Partitions[len(m.Partitions)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if m.ReportLeaderOp == nil {
+				m.ReportLeaderOp = &ReportLeaderOp{}
+			}
+			if err := m.ReportLeaderOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipInternal(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExpandISROp", wireType)
 			}
-			if skippy < 0 {
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			if (iNdEx + skippy) > l {
+			postIndex := iNdEx + msglen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *ReplicationRequest) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowInternal
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
+			if m.ExpandISROp == nil {
+				m.ExpandISROp = &ExpandISROp{}
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
+			if err := m.ExpandISROp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: ReplicationRequest: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ReplicationRequest: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			iNdEx = postIndex
+		case 6:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReplicaID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field DeleteStreamOp", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -4187,26 +9037,30 @@ func (m *ReplicationRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ReplicaID = string(dAtA[iNdEx:postIndex])
+			if m.DeleteStreamOp == nil {
+				m.DeleteStreamOp = &DeleteStreamOp{}
+			}
+			if err := m.DeleteStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
-		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PauseStreamOp", wireType)
 			}
-			m.Offset = 0
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -4216,16 +9070,30 @@ func (m *ReplicationRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Offset |= (int64(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
 			}
-			m.LeaderEpoch = 0
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.PauseStreamOp == nil {
+				m.PauseStreamOp = &PauseStreamOp{}
+			}
+			if err := m.PauseStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreateStreamOp", wireType)
+			}
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -4235,66 +9103,30 @@ func (m *ReplicationRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipInternal(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			if (iNdEx + skippy) > l {
+			postIndex := iNdEx + msglen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *LeaderEpochOffsetRequest) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowInternal
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
+			if m.CreateStreamOp == nil {
+				m.CreateStreamOp = &CreateStreamOp{}
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
+			if err := m.CreateStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: LeaderEpochOffsetRequest: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: LeaderEpochOffsetRequest: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field LeaderEpoch", wireType)
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RenameStreamOp", wireType)
 			}
-			m.LeaderEpoch = 0
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -4304,66 +9136,63 @@ func (m *LeaderEpochOffsetRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.LeaderEpoch |= (uint64(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipInternal(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			if (iNdEx + skippy) > l {
+			postIndex := iNdEx + msglen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *LeaderEpochOffsetResponse) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowInternal
+			if m.RenameStreamOp == nil {
+				m.RenameStreamOp = &RenameStreamOp{}
+			}
+			if err := m.RenameStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TrimStreamOp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if iNdEx >= l {
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
+			if m.TrimStreamOp == nil {
+				m.TrimStreamOp = &TrimStreamOp{}
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: LeaderEpochOffsetResponse: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: LeaderEpochOffsetResponse: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field EndOffset", wireType)
+			if err := m.TrimStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
-			m.EndOffset = 0
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreateStreamsOp", wireType)
+			}
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -4373,66 +9202,30 @@ func (m *LeaderEpochOffsetResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.EndOffset |= (int64(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipInternal(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthInternal
 			}
-			if (iNdEx + skippy) > l {
+			postIndex := iNdEx + msglen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *PropagatedRequest) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowInternal
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
+			if m.CreateStreamsOp == nil {
+				m.CreateStreamsOp = &CreateStreamsOp{}
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
+			if err := m.CreateStreamsOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: PropagatedRequest: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: PropagatedRequest: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Op", wireType)
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChangeReplicaRoleOp", wireType)
 			}
-			m.Op = 0
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowInternal
@@ -4442,14 +9235,28 @@ func (m *PropagatedRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Op |= (Op(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 2:
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.ChangeReplicaRoleOp == nil {
+				m.ChangeReplicaRoleOp = &ChangeReplicaRoleOp{}
+			}
+			if err := m.ChangeReplicaRoleOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 13:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field CreatePartitionOp", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ReportActivityOp", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4473,16 +9280,16 @@ func (m *PropagatedRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.CreatePartitionOp == nil {
-				m.CreatePartitionOp = &CreatePartitionOp{}
+			if m.ReportActivityOp == nil {
+				m.ReportActivityOp = &ReportActivityOp{}
 			}
-			if err := m.CreatePartitionOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.ReportActivityOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 3:
+		case 14:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ShrinkISROp", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field SetServerReadOnlyOp", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4506,16 +9313,16 @@ func (m *PropagatedRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.ShrinkISROp == nil {
-				m.ShrinkISROp = &ShrinkISROp{}
+			if m.SetServerReadOnlyOp == nil {
+				m.SetServerReadOnlyOp = &SetServerReadOnlyOp{}
 			}
-			if err := m.ShrinkISROp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.SetServerReadOnlyOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 4:
+		case 15:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReportLeaderOp", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ChangeReplicasOp", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4539,16 +9346,16 @@ func (m *PropagatedRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.ReportLeaderOp == nil {
-				m.ReportLeaderOp = &ReportLeaderOp{}
+			if m.ChangeReplicasOp == nil {
+				m.ChangeReplicasOp = &ChangeReplicasOp{}
 			}
-			if err := m.ReportLeaderOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.ChangeReplicasOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 5:
+		case 16:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ExpandISROp", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ChangeLeaderOp", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4572,16 +9379,16 @@ func (m *PropagatedRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.ExpandISROp == nil {
-				m.ExpandISROp = &ExpandISROp{}
+			if m.ChangeLeaderOp == nil {
+				m.ChangeLeaderOp = &ChangeLeaderOp{}
 			}
-			if err := m.ExpandISROp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.ChangeLeaderOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 6:
+		case 17:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field DeleteStreamOp", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field SwapStreamsOp", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4605,16 +9412,16 @@ func (m *PropagatedRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.DeleteStreamOp == nil {
-				m.DeleteStreamOp = &DeleteStreamOp{}
+			if m.SwapStreamsOp == nil {
+				m.SwapStreamsOp = &SwapStreamsOp{}
 			}
-			if err := m.DeleteStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.SwapStreamsOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		case 7:
+		case 18:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field PauseStreamOp", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ConfirmLeaderOp", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4638,13 +9445,32 @@ func (m *PropagatedRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.PauseStreamOp == nil {
-				m.PauseStreamOp = &PauseStreamOp{}
+			if m.ConfirmLeaderOp == nil {
+				m.ConfirmLeaderOp = &ConfirmLeaderOp{}
 			}
-			if err := m.PauseStreamOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if err := m.ConfirmLeaderOp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
+		case 19:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FencingToken", wireType)
+			}
+			m.FencingToken = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FencingToken |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipInternal(dAtA[iNdEx:])
@@ -4812,9 +9638,125 @@ func (m *PropagatedResponse) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
-		case 2:
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Error == nil {
+				m.Error = &Error{}
+			}
+			if err := m.Error.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreateStreamsResp", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.CreateStreamsResp == nil {
+				m.CreateStreamsResp = &CreateStreamsResponse{}
+			}
+			if err := m.CreateStreamsResp.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *CreateStreamsResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CreateStreamsResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CreateStreamsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Results", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -4838,10 +9780,8 @@ func (m *PropagatedResponse) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Error == nil {
-				m.Error = &Error{}
-			}
-			if err := m.Error.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			m.Results = append(m.Results, &Error{})
+			if err := m.Results[len(m.Results)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
@@ -5239,6 +10179,63 @@ func (m *PartitionStatusResponse) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.IsLeader = bool(v != 0)
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NewestOffset", wireType)
+			}
+			m.NewestOffset = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.NewestOffset |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field HighWatermark", wireType)
+			}
+			m.HighWatermark = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.HighWatermark |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LastLeaderEpoch", wireType)
+			}
+			m.LastLeaderEpoch = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LastLeaderEpoch |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipInternal(dAtA[iNdEx:])
@@ -5337,6 +10334,201 @@ func (m *PartitionNotification) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Truncate", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Truncate = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipInternal(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthInternal
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PublishRejection) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowInternal
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PublishRejection: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PublishRejection: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stream", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stream = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partition", wireType)
+			}
+			m.Partition = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Partition |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CorrelationId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.CorrelationId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthInternal
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Reason = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Code", wireType)
+			}
+			m.Code = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowInternal
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Code |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipInternal(dAtA[iNdEx:])
@@ -5466,70 +10658,134 @@ var (
 func init() { proto.RegisterFile("server/protocol/internal.proto", fileDescriptorInternal) }
 
 var fileDescriptorInternal = []byte{
-	// 1035 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xd4, 0x56, 0x4f, 0x6f, 0xe3, 0x44,
-	0x14, 0x5f, 0xdb, 0x4d, 0x9a, 0xbc, 0x6c, 0xd3, 0x64, 0xd8, 0xed, 0x1a, 0xa8, 0xa2, 0xc8, 0x08,
-	0x29, 0x20, 0xe8, 0x8a, 0xee, 0x01, 0x21, 0x81, 0x44, 0x68, 0xbd, 0xbb, 0x81, 0x34, 0x89, 0x26,
-	0x41, 0x82, 0x0b, 0x95, 0x37, 0x9e, 0x36, 0x86, 0xd4, 0x63, 0x66, 0x26, 0xab, 0xfd, 0x02, 0x9c,
-	0xb9, 0x22, 0x6e, 0x9c, 0xf6, 0xcc, 0xb7, 0xe0, 0xc8, 0x47, 0x40, 0xe5, 0x8b, 0xa0, 0x19, 0x8f,
-	0xed, 0xb1, 0x43, 0x91, 0xe8, 0x9e, 0xf6, 0x36, 0xef, 0xcd, 0x7b, 0xbf, 0xf7, 0x7b, 0x7f, 0xe6,
-	0xd9, 0xd0, 0xe3, 0x84, 0x3d, 0x27, 0xec, 0x61, 0xc2, 0xa8, 0xa0, 0x4b, 0xba, 0x7e, 0x18, 0xc5,
-	0x82, 0xb0, 0x38, 0x58, 0x1f, 0x29, 0x0d, 0x6a, 0x64, 0x17, 0xde, 0x7b, 0xd0, 0x9a, 0x2b, 0xdb,
-	0xb9, 0x08, 0x04, 0x41, 0x6f, 0x41, 0x23, 0x75, 0x1d, 0x9d, 0xba, 0x56, 0xdf, 0x1a, 0x34, 0x71,
-	0x2e, 0x7b, 0x2f, 0x1d, 0xd8, 0xc5, 0xc1, 0x85, 0x18, 0xd3, 0x4b, 0x74, 0x08, 0x36, 0x4d, 0x94,
-	0x45, 0xfb, 0xf8, 0xee, 0x51, 0x86, 0x76, 0x34, 0x4d, 0xb0, 0x4d, 0x13, 0x34, 0x82, 0xee, 0x92,
-	0x91, 0x40, 0x90, 0x59, 0xc0, 0x44, 0x24, 0x22, 0x1a, 0x4f, 0x13, 0xd7, 0xee, 0x5b, 0x83, 0xd6,
-	0xf1, 0xdb, 0x85, 0xf1, 0x49, 0xd5, 0x04, 0x6f, 0x7b, 0xa1, 0x8f, 0xa1, 0xc5, 0x57, 0x2c, 0x8a,
-	0x7f, 0x18, 0xcd, 0xf1, 0x34, 0x71, 0x1d, 0x05, 0x72, 0xbf, 0x00, 0x99, 0x17, 0x97, 0xd8, 0xb4,
-	0x44, 0x9f, 0x43, 0x7b, 0xb9, 0x0a, 0xe2, 0x4b, 0x32, 0x26, 0x41, 0x48, 0xd8, 0x34, 0x71, 0x77,
-	0x94, 0xaf, 0x6b, 0x10, 0x28, 0xdd, 0xe3, 0x8a, 0xbd, 0x0c, 0x4d, 0x5e, 0x24, 0x41, 0x1c, 0xa6,
-	0xa1, 0x6b, 0xd5, 0xd0, 0x7e, 0x71, 0x89, 0x4d, 0x4b, 0x19, 0x3a, 0x24, 0x6b, 0x22, 0xc8, 0x5c,
-	0x30, 0x12, 0x5c, 0x4d, 0x13, 0xb7, 0x5e, 0x0d, 0x7d, 0x5a, 0xba, 0xc7, 0x15, 0x7b, 0xf4, 0x19,
-	0xec, 0x25, 0xc1, 0x86, 0x17, 0x00, 0xbb, 0x0a, 0xe0, 0x41, 0x01, 0x30, 0x33, 0xaf, 0x71, 0xd9,
-	0xda, 0x7b, 0x0c, 0xdd, 0xad, 0xe2, 0xa2, 0x8f, 0xa0, 0x99, 0x64, 0xa2, 0xea, 0x5c, 0xeb, 0xf8,
-	0x0d, 0x13, 0x4f, 0x5f, 0xe1, 0xc2, 0xca, 0x7b, 0x69, 0x41, 0xcb, 0x28, 0x30, 0x3a, 0x80, 0x3a,
-	0x57, 0x31, 0xf4, 0x6c, 0x68, 0x09, 0x1d, 0x9a, 0xd0, 0xb2, 0xcf, 0x35, 0x03, 0x05, 0x0d, 0x60,
-	0x9f, 0x91, 0x64, 0x1d, 0x2d, 0x83, 0x05, 0xc5, 0xe4, 0x8a, 0x3e, 0x27, 0xaa, 0x8d, 0x4d, 0x5c,
-	0x55, 0x4b, 0xfc, 0xb5, 0xaa, 0xbe, 0xea, 0x55, 0x13, 0x6b, 0x09, 0xf5, 0xa1, 0x95, 0x9e, 0xfc,
-	0x84, 0x2e, 0x57, 0xaa, 0x13, 0x3b, 0xd8, 0x54, 0x79, 0xbf, 0x59, 0xd0, 0x32, 0xfa, 0x71, 0x4b,
-	0xa6, 0x1e, 0xdc, 0xcd, 0x29, 0x0d, 0xc3, 0x50, 0xd3, 0x2c, 0xe9, 0x5e, 0x81, 0xe3, 0x00, 0xda,
-	0xe5, 0xb6, 0xdf, 0xc4, 0xd2, 0x23, 0xb0, 0x57, 0xea, 0xef, 0x8d, 0xe9, 0xf4, 0x00, 0x72, 0xf6,
-	0xdc, 0xb5, 0xfb, 0xce, 0xa0, 0x86, 0x0d, 0x8d, 0x4c, 0x97, 0x11, 0xbe, 0xb9, 0x22, 0xc3, 0xf5,
-	0x5a, 0x65, 0xd3, 0xc0, 0x85, 0xc2, 0xfb, 0xd5, 0x82, 0x36, 0x26, 0x09, 0x65, 0x22, 0x9f, 0xf9,
-	0xdb, 0xd5, 0xcd, 0x85, 0x5d, 0x5d, 0x23, 0x5d, 0xb2, 0x4c, 0x7c, 0x85, 0x6a, 0x7d, 0x07, 0xed,
-	0xf2, 0xfb, 0xbc, 0x25, 0xb7, 0x82, 0x81, 0x63, 0x32, 0xf0, 0x7e, 0xb6, 0xa1, 0x39, 0x33, 0x33,
-	0xe0, 0x9b, 0x67, 0xdf, 0x93, 0xa5, 0xd0, 0xe0, 0x99, 0x68, 0x44, 0xb5, 0x4b, 0x51, 0xdb, 0x60,
-	0x47, 0xe9, 0x84, 0xd4, 0xb0, 0x1d, 0x85, 0xe8, 0x1e, 0xd4, 0x2e, 0x19, 0xdd, 0x24, 0x3a, 0xd1,
-	0x54, 0x40, 0x1f, 0x40, 0x57, 0x97, 0x42, 0x86, 0x79, 0x1c, 0x2c, 0x05, 0x65, 0x2a, 0xdb, 0x1a,
-	0xde, 0xbe, 0x90, 0xdb, 0x57, 0x2b, 0xb9, 0x5b, 0xef, 0x3b, 0x72, 0xfb, 0x66, 0xb2, 0x91, 0xc7,
-	0x6e, 0xa9, 0x92, 0x1d, 0x70, 0x22, 0xce, 0xdc, 0x86, 0x32, 0x97, 0xc7, 0x6a, 0x6d, 0x9b, 0x5b,
-	0xb5, 0x95, 0x5c, 0x89, 0xba, 0x03, 0x75, 0x97, 0x0a, 0x9e, 0x0f, 0xfb, 0x72, 0xbd, 0x7f, 0x49,
-	0xa3, 0x18, 0x93, 0x1f, 0x37, 0x84, 0xab, 0xe4, 0x63, 0x1a, 0x92, 0xfc, 0x63, 0xa0, 0x25, 0x49,
-	0x54, 0x9e, 0x86, 0x61, 0xc8, 0x74, 0x59, 0x72, 0xd9, 0x1b, 0x40, 0xa7, 0x80, 0xe1, 0x09, 0x8d,
-	0x39, 0x51, 0x01, 0x19, 0xa3, 0x4c, 0xc3, 0xa4, 0x82, 0xf7, 0x04, 0x3a, 0x67, 0x44, 0x04, 0x61,
-	0x20, 0x82, 0x79, 0x1c, 0x24, 0x7c, 0x45, 0x05, 0x7a, 0x54, 0x9a, 0x68, 0xab, 0xef, 0xdc, 0xb4,
-	0xa6, 0x0c, 0x33, 0x6f, 0x0d, 0x08, 0x17, 0xc5, 0xcc, 0xc8, 0xab, 0xe1, 0x57, 0xda, 0x9c, 0x7f,
-	0xa1, 0x90, 0xa9, 0xd1, 0x8b, 0x0b, 0x4e, 0x84, 0x4a, 0xc0, 0xc1, 0x5a, 0xaa, 0x56, 0xcf, 0xd9,
-	0x9e, 0xcc, 0x4f, 0xc1, 0x1d, 0x17, 0xe2, 0x54, 0xb9, 0x65, 0x31, 0x2b, 0xde, 0xd6, 0xb6, 0xf7,
-	0x27, 0xf0, 0xe6, 0xbf, 0x78, 0xeb, 0x3a, 0x1d, 0x42, 0x93, 0xc4, 0x61, 0xaa, 0x54, 0xce, 0x0e,
-	0x2e, 0x14, 0xde, 0xef, 0x0e, 0x74, 0x67, 0x8c, 0x26, 0xc1, 0x65, 0x20, 0x48, 0x58, 0xa4, 0xf9,
-	0x1a, 0x7c, 0x8a, 0x59, 0x69, 0xcd, 0x6c, 0x7f, 0x8a, 0xcb, 0x6b, 0x08, 0x57, 0xec, 0x5f, 0xe3,
-	0x4f, 0xf1, 0x87, 0x50, 0xf3, 0xe5, 0xb0, 0x23, 0x04, 0x3b, 0x4b, 0x1a, 0x12, 0xd5, 0xa8, 0x3d,
-	0xac, 0xce, 0xf2, 0xed, 0x5e, 0xf1, 0x4b, 0xfd, 0x82, 0xe4, 0xd1, 0xfb, 0x16, 0x90, 0xd9, 0xe1,
-	0x7c, 0x2c, 0xfe, 0xab, 0xc5, 0xef, 0x66, 0x8f, 0x2b, 0x6d, 0xeb, 0xbe, 0x51, 0x16, 0xa9, 0xce,
-	0x5e, 0xdb, 0x3b, 0xd0, 0x4d, 0xff, 0xf4, 0x46, 0xf1, 0x05, 0xcd, 0x86, 0x27, 0xdd, 0x62, 0xe9,
-	0xe3, 0xb0, 0xa3, 0xd0, 0x1b, 0x03, 0x32, 0x8d, 0x74, 0xfc, 0x8a, 0x95, 0xcc, 0x65, 0x45, 0xb9,
-	0xd0, 0xc4, 0xd5, 0x59, 0xea, 0x64, 0xcb, 0xf4, 0x46, 0x54, 0x67, 0x6f, 0x02, 0x07, 0xf9, 0x00,
-	0xc9, 0xff, 0xcb, 0x0d, 0x37, 0x16, 0xcb, 0xff, 0xdf, 0xe5, 0xde, 0x19, 0x3c, 0xd8, 0xc2, 0xd3,
-	0x14, 0x0f, 0xa0, 0x4e, 0x5e, 0x44, 0x5c, 0x70, 0x05, 0xd8, 0xc0, 0x5a, 0x92, 0x9b, 0x2a, 0xe2,
-	0xe9, 0x1c, 0x29, 0xbc, 0x06, 0xce, 0x65, 0xef, 0x0c, 0xee, 0xe7, 0x70, 0x13, 0x2a, 0xa2, 0x0b,
-	0xbd, 0x40, 0x6e, 0xc7, 0xee, 0xfd, 0x9f, 0x2c, 0xb0, 0xa7, 0x09, 0xba, 0x07, 0x9d, 0x13, 0xec,
-	0x0f, 0x17, 0xfe, 0xf9, 0x6c, 0x88, 0x17, 0xa3, 0xc5, 0x68, 0x3a, 0xe9, 0xdc, 0x41, 0x6d, 0x80,
-	0xf9, 0x53, 0x3c, 0x9a, 0x7c, 0x75, 0x3e, 0x9a, 0xe3, 0x8e, 0x85, 0xba, 0xb0, 0x87, 0xfd, 0xd9,
-	0x14, 0x2f, 0xce, 0xc7, 0xfe, 0xf0, 0xd4, 0xc7, 0x1d, 0x5b, 0xaa, 0x4e, 0x9e, 0x0e, 0x27, 0x4f,
-	0xfc, 0x4c, 0xe5, 0x48, 0x2f, 0xff, 0x9b, 0xd9, 0x70, 0x72, 0xaa, 0xbc, 0x76, 0xa4, 0xc9, 0xa9,
-	0x3f, 0xf6, 0x17, 0xfe, 0xf9, 0x7c, 0x81, 0xfd, 0xe1, 0x59, 0xa7, 0x86, 0x3a, 0x70, 0x77, 0x36,
-	0xfc, 0x7a, 0x9e, 0x6b, 0xea, 0x5f, 0x74, 0xfe, 0xb8, 0xee, 0x59, 0x7f, 0x5e, 0xf7, 0xac, 0xbf,
-	0xae, 0x7b, 0xd6, 0x2f, 0x7f, 0xf7, 0xee, 0x3c, 0xab, 0xab, 0x89, 0x78, 0xf4, 0x4f, 0x00, 0x00,
-	0x00, 0xff, 0xff, 0xc9, 0x13, 0x6c, 0x92, 0x17, 0x0c, 0x00, 0x00,
+	// 2056 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xec, 0x59, 0x4f, 0x6f, 0x23, 0x49,
+	0x15, 0x9f, 0xb6, 0xe3, 0xc4, 0x7e, 0x8e, 0xed, 0x4e, 0x25, 0x93, 0xe9, 0x1d, 0x86, 0x10, 0x35,
+	0x0b, 0xca, 0x8e, 0x60, 0x16, 0xb2, 0x48, 0x08, 0xc4, 0x22, 0x9c, 0xa4, 0xc3, 0x78, 0xd7, 0x71,
+	0x5b, 0xd5, 0x66, 0x86, 0xbd, 0x10, 0xf5, 0xd8, 0x95, 0xb8, 0x19, 0xbb, 0xab, 0xa7, 0xbb, 0x3c,
+	0x93, 0x1c, 0x38, 0x70, 0x41, 0xe2, 0xc0, 0x11, 0x09, 0x71, 0xe3, 0x04, 0x47, 0xbe, 0x02, 0x37,
+	0x8e, 0x08, 0xbe, 0x00, 0x1a, 0x3e, 0x02, 0x27, 0x6e, 0xa8, 0xaa, 0xab, 0xff, 0x54, 0xb7, 0x3d,
+	0xd2, 0x7a, 0xe7, 0xb2, 0xd2, 0xdc, 0xfa, 0xfd, 0xde, 0x9f, 0x7a, 0x55, 0xf5, 0xea, 0xbd, 0x57,
+	0xd5, 0x70, 0x10, 0x91, 0xf0, 0x25, 0x09, 0x3f, 0x0c, 0x42, 0xca, 0xe8, 0x98, 0xce, 0x3e, 0xf4,
+	0x7c, 0x46, 0x42, 0xdf, 0x9d, 0x3d, 0x12, 0x08, 0xaa, 0x27, 0x0c, 0xf3, 0x03, 0x68, 0x3a, 0x42,
+	0xd6, 0x61, 0x2e, 0x23, 0xe8, 0x3e, 0xd4, 0x63, 0xd5, 0xde, 0x99, 0xa1, 0x1d, 0x6a, 0x47, 0x0d,
+	0x9c, 0xd2, 0xe6, 0x6f, 0x35, 0x68, 0x5a, 0x37, 0x01, 0x0d, 0x59, 0x2c, 0x8b, 0x60, 0xc3, 0x77,
+	0xe7, 0x44, 0xca, 0x89, 0x6f, 0xb4, 0x0f, 0x9b, 0x11, 0x0b, 0x89, 0x3b, 0x37, 0x2a, 0x02, 0x95,
+	0x14, 0x7a, 0x00, 0x8d, 0xc0, 0x0d, 0x99, 0xc7, 0x3c, 0xea, 0x1b, 0xd5, 0x43, 0xed, 0xa8, 0x86,
+	0x33, 0x00, 0x19, 0xb0, 0x15, 0x2d, 0x9e, 0xfd, 0x92, 0x8c, 0x99, 0xb1, 0x21, 0xd4, 0x12, 0x92,
+	0xdb, 0xa3, 0x57, 0x57, 0x11, 0x61, 0x46, 0xed, 0x50, 0x3b, 0xaa, 0x62, 0x49, 0x99, 0xbf, 0xd3,
+	0xa0, 0xd9, 0x9b, 0xbf, 0xd9, 0x97, 0x9c, 0xd5, 0x4a, 0xc9, 0xaa, 0xf4, 0xb2, 0xba, 0xda, 0xcb,
+	0x8d, 0xa2, 0x97, 0xf7, 0xa1, 0x1e, 0xd0, 0x28, 0x66, 0xc6, 0xde, 0xa4, 0xb4, 0xf9, 0xbf, 0x2d,
+	0xd8, 0xc2, 0xee, 0x15, 0xeb, 0xd3, 0x6b, 0xf4, 0x00, 0x2a, 0x34, 0x10, 0x9e, 0xb4, 0x8f, 0xb7,
+	0x1f, 0x25, 0x2b, 0xfd, 0xc8, 0x0e, 0x70, 0x85, 0x06, 0xa8, 0x07, 0x3b, 0xe3, 0x90, 0xb8, 0x8c,
+	0x0c, 0x13, 0xc3, 0x76, 0x20, 0xfc, 0x6b, 0x1e, 0x7f, 0x25, 0x13, 0x3e, 0x2d, 0x8a, 0xe0, 0xb2,
+	0x16, 0xfa, 0x3e, 0x34, 0xa3, 0x69, 0xe8, 0xf9, 0xcf, 0x7b, 0x0e, 0xb6, 0x03, 0x31, 0x97, 0xe6,
+	0xf1, 0xdd, 0xcc, 0x88, 0x93, 0x31, 0x71, 0x5e, 0x12, 0xfd, 0x04, 0xda, 0xe3, 0xa9, 0xeb, 0x5f,
+	0x93, 0x3e, 0x71, 0x27, 0x24, 0xb4, 0x03, 0x31, 0xd9, 0xe6, 0xb1, 0x91, 0x73, 0x40, 0xe1, 0xe3,
+	0x82, 0x3c, 0x1f, 0x9a, 0xdc, 0x04, 0xae, 0x3f, 0x89, 0x87, 0xae, 0x15, 0x87, 0xb6, 0x32, 0x26,
+	0xce, 0x4b, 0xf2, 0xa1, 0x27, 0x64, 0x46, 0x18, 0x71, 0xc4, 0x92, 0xdb, 0x81, 0xb1, 0x59, 0x1c,
+	0xfa, 0x4c, 0xe1, 0xe3, 0x82, 0x3c, 0xfa, 0x18, 0x5a, 0x81, 0xbb, 0x88, 0x32, 0x03, 0x5b, 0xc2,
+	0xc0, 0xbd, 0xcc, 0xc0, 0x30, 0xcf, 0xc6, 0xaa, 0xb4, 0x98, 0xbb, 0x58, 0xc9, 0x54, 0xbf, 0x5e,
+	0x9a, 0xbb, 0xc2, 0xc7, 0x05, 0x79, 0x6e, 0x21, 0x24, 0x3c, 0xc2, 0x52, 0x0b, 0x8d, 0xa2, 0x05,
+	0xac, 0xf0, 0x71, 0x41, 0x1e, 0xfd, 0x10, 0xb6, 0x59, 0xe8, 0xcd, 0x53, 0x7d, 0x10, 0xfa, 0xfb,
+	0x99, 0xfe, 0x28, 0xc7, 0xc5, 0x8a, 0x2c, 0x3a, 0x85, 0x4e, 0xde, 0x9f, 0xc8, 0x0e, 0x8c, 0xa6,
+	0x50, 0x7f, 0x6f, 0xf9, 0x04, 0x22, 0x3b, 0xc0, 0x45, 0x0d, 0x64, 0xc3, 0x6e, 0xbc, 0xa1, 0x98,
+	0x04, 0x33, 0x6f, 0xec, 0x62, 0x3a, 0x23, 0x76, 0x60, 0x6c, 0x0b, 0x43, 0x5f, 0x2d, 0x46, 0x81,
+	0x22, 0x84, 0x97, 0x69, 0x72, 0x83, 0x11, 0x61, 0x71, 0x26, 0xc1, 0xc4, 0x9d, 0xd8, 0xfe, 0xec,
+	0xd6, 0x0e, 0x8c, 0x56, 0xd1, 0xa0, 0x53, 0x16, 0xc2, 0xcb, 0x34, 0xd1, 0x39, 0xe8, 0xca, 0x38,
+	0x7c, 0x9e, 0x6d, 0x61, 0xed, 0xfe, 0x0a, 0xf7, 0xf8, 0x44, 0x4b, 0x3a, 0x3c, 0x5a, 0xa2, 0x57,
+	0x6e, 0x90, 0x2d, 0x56, 0xa7, 0x18, 0x2d, 0x4e, 0x9e, 0x8d, 0x55, 0x69, 0x64, 0xc2, 0xf6, 0x15,
+	0xf1, 0xc7, 0x9e, 0x7f, 0x3d, 0xa2, 0xcf, 0x89, 0x6f, 0xe8, 0x87, 0xda, 0xd1, 0x06, 0x56, 0x30,
+	0xf3, 0x1c, 0x76, 0x4a, 0xc7, 0x15, 0x7d, 0x37, 0x9f, 0x4a, 0x34, 0x31, 0xe6, 0x6e, 0x3e, 0x42,
+	0x25, 0x2b, 0x97, 0x5f, 0xcc, 0x5f, 0x41, 0x5b, 0x8d, 0x3c, 0xf4, 0x11, 0x40, 0xca, 0x8e, 0x0c,
+	0xed, 0xb0, 0xba, 0xca, 0x4a, 0x4e, 0x4c, 0xa4, 0x3d, 0xb1, 0x9a, 0x91, 0x51, 0x39, 0xac, 0x8a,
+	0xb4, 0x17, 0x93, 0x3c, 0xbd, 0xd1, 0x67, 0x09, 0xaf, 0x2a, 0x78, 0x19, 0x60, 0x5a, 0xd0, 0x29,
+	0xc4, 0x0d, 0x3a, 0x86, 0xad, 0x38, 0x33, 0x26, 0x83, 0xaf, 0x3e, 0x24, 0x89, 0xa0, 0xf9, 0x67,
+	0x0d, 0x9a, 0xb9, 0xc4, 0x93, 0xcb, 0xb5, 0xda, 0xea, 0x5c, 0x5b, 0x29, 0xe6, 0xda, 0x23, 0xe8,
+	0x84, 0xf1, 0x26, 0x8e, 0x28, 0x26, 0x73, 0xfa, 0x92, 0xc8, 0x54, 0x5d, 0x84, 0xb9, 0xfd, 0x99,
+	0xc8, 0x4a, 0xb2, 0x74, 0x48, 0x0a, 0x1d, 0x42, 0x33, 0xfe, 0xb2, 0x02, 0x3a, 0x9e, 0x8a, 0x0c,
+	0xb5, 0x81, 0xf3, 0x90, 0xf9, 0xa7, 0xb8, 0x9e, 0xa5, 0xa9, 0x69, 0x3d, 0x4f, 0x4d, 0xd8, 0x4e,
+	0x5d, 0xea, 0x4e, 0x26, 0xd2, 0x4d, 0x05, 0xfb, 0x02, 0x3e, 0x1e, 0x41, 0x5b, 0x4d, 0x87, 0xab,
+	0xbc, 0x34, 0x4f, 0xa0, 0xad, 0x66, 0x9d, 0x95, 0xf3, 0x31, 0x60, 0xcb, 0x27, 0xaf, 0x06, 0xbc,
+	0x5c, 0xca, 0xba, 0x28, 0x49, 0xf3, 0x63, 0x68, 0x29, 0xa7, 0x61, 0xa5, 0x89, 0x3d, 0xa8, 0x51,
+	0x36, 0x25, 0xa1, 0x34, 0x10, 0x13, 0xe6, 0x8f, 0x61, 0x3b, 0x9f, 0xb8, 0x56, 0x6a, 0x67, 0x45,
+	0xbd, 0xa2, 0x14, 0x75, 0x02, 0x2d, 0x25, 0x75, 0xaf, 0x34, 0x70, 0xa0, 0x9c, 0x0b, 0x1e, 0xe5,
+	0x35, 0xe5, 0x08, 0x3c, 0x80, 0x46, 0x48, 0xa2, 0xc5, 0x9c, 0x74, 0x67, 0x33, 0xb1, 0x21, 0x75,
+	0x9c, 0x01, 0xe6, 0xaf, 0x35, 0xd8, 0x5d, 0x92, 0xd8, 0xd6, 0xdc, 0x7f, 0x03, 0xb6, 0xe4, 0x5e,
+	0xcb, 0xad, 0x4f, 0x48, 0xde, 0x2f, 0x24, 0xa7, 0x4b, 0xec, 0x7b, 0x1d, 0xa7, 0xb4, 0x39, 0x01,
+	0xbd, 0x98, 0xbc, 0xd6, 0x1c, 0xff, 0x3e, 0xd4, 0xe5, 0x80, 0xc9, 0x99, 0x4e, 0x69, 0xf3, 0x8f,
+	0x1a, 0x0f, 0x8a, 0x80, 0x86, 0x2c, 0x2d, 0xdc, 0x6f, 0x7b, 0x92, 0xeb, 0x87, 0xf6, 0x63, 0xd0,
+	0x63, 0xdf, 0xba, 0x63, 0xe6, 0xbd, 0xf4, 0xd8, 0xed, 0xba, 0xde, 0xf1, 0x0d, 0xed, 0x9c, 0x52,
+	0xff, 0xca, 0x0b, 0xe7, 0x5f, 0x70, 0x9e, 0xd9, 0x6c, 0xaa, 0x6f, 0x9a, 0xcd, 0x46, 0x79, 0x36,
+	0x3d, 0xd8, 0x5d, 0x52, 0xdb, 0x84, 0x1b, 0x02, 0x4b, 0xdd, 0x10, 0x54, 0xbc, 0x6b, 0xb1, 0x94,
+	0xf0, 0xa2, 0x8e, 0x53, 0xda, 0xfc, 0x05, 0xb4, 0xd5, 0xee, 0xeb, 0xed, 0x4e, 0xc6, 0xfc, 0x6f,
+	0x0d, 0x1a, 0xc3, 0x65, 0xbd, 0xb7, 0xb6, 0xaa, 0x4b, 0x56, 0x7b, 0xf9, 0x36, 0x54, 0xbc, 0x89,
+	0x6c, 0xe2, 0x2b, 0xde, 0x84, 0x27, 0x83, 0xeb, 0x90, 0x2e, 0x02, 0x19, 0x01, 0x31, 0x81, 0xbe,
+	0x05, 0x3b, 0x32, 0x46, 0xf8, 0x30, 0xe7, 0xee, 0x98, 0xd1, 0x50, 0x84, 0x41, 0x0d, 0x97, 0x19,
+	0x4a, 0x14, 0x6f, 0xaa, 0x51, 0x9c, 0x9b, 0xc7, 0x96, 0xb2, 0x29, 0x3a, 0x54, 0xbd, 0x28, 0x34,
+	0xea, 0x42, 0x9c, 0x7f, 0x16, 0xb7, 0xa9, 0x51, 0xda, 0x26, 0xee, 0x2b, 0x11, 0x3c, 0x10, 0xbc,
+	0x98, 0xc8, 0xf9, 0x7a, 0xe1, 0xde, 0xf4, 0xdd, 0xeb, 0x91, 0x37, 0x27, 0xa2, 0xab, 0xaa, 0xe2,
+	0x32, 0x03, 0x7d, 0x07, 0x76, 0x25, 0x78, 0x4e, 0xd8, 0x78, 0xca, 0x31, 0xba, 0x60, 0xa2, 0x79,
+	0xaa, 0xe2, 0x65, 0x2c, 0x9e, 0xaf, 0x42, 0xf2, 0x62, 0xe1, 0x85, 0xe4, 0x53, 0x72, 0x2b, 0x9a,
+	0xa2, 0x3a, 0xce, 0x21, 0xe8, 0x7b, 0x00, 0x64, 0x1e, 0xb0, 0xdb, 0x27, 0xee, 0x6c, 0x41, 0x44,
+	0x9b, 0xd3, 0x3e, 0xde, 0xcb, 0x35, 0xd3, 0x29, 0x0f, 0xe7, 0xe4, 0xd4, 0x72, 0xde, 0x29, 0x94,
+	0x73, 0xb1, 0x7b, 0xe3, 0x29, 0x99, 0xbb, 0x86, 0x2e, 0x77, 0x4f, 0x50, 0xe8, 0x9b, 0xd0, 0xf6,
+	0x26, 0x33, 0x12, 0x57, 0x15, 0x31, 0xd1, 0x1d, 0xe1, 0x78, 0x01, 0x45, 0xc7, 0xb0, 0xa7, 0x4c,
+	0xdd, 0x16, 0x39, 0x3a, 0x32, 0x90, 0x90, 0x5e, 0xca, 0x43, 0x0f, 0x41, 0x9f, 0xbb, 0x37, 0xa7,
+	0x74, 0x3e, 0xf7, 0xd8, 0x53, 0xd7, 0x63, 0xdc, 0xb1, 0x5d, 0xb1, 0xe5, 0x25, 0x3c, 0xae, 0xf0,
+	0x8c, 0xf8, 0x3c, 0x08, 0x2e, 0xdc, 0x9b, 0xee, 0x35, 0x31, 0xf6, 0x84, 0xe9, 0x22, 0x8c, 0xde,
+	0x87, 0xd6, 0x8b, 0x05, 0x65, 0x7c, 0xac, 0x93, 0x5b, 0x46, 0x22, 0xe3, 0xae, 0x90, 0x53, 0x41,
+	0xbe, 0xc6, 0xbc, 0x4f, 0x8e, 0x5d, 0x31, 0xf6, 0x85, 0x48, 0x0e, 0xe1, 0xed, 0x0d, 0xbf, 0xa0,
+	0x7d, 0x42, 0x3d, 0x1f, 0x93, 0x17, 0x0b, 0x12, 0x89, 0x00, 0xf7, 0xe9, 0x84, 0xa4, 0x57, 0x5d,
+	0x49, 0xf1, 0x60, 0xe4, 0x5f, 0xdd, 0xc9, 0x24, 0x29, 0x70, 0x29, 0x6d, 0x1e, 0x81, 0x9e, 0x99,
+	0x89, 0x02, 0xea, 0x47, 0x44, 0x04, 0x55, 0x18, 0xd2, 0xe4, 0x8c, 0xc7, 0x84, 0xf9, 0x7b, 0x0d,
+	0xf4, 0x0b, 0xc2, 0xdc, 0x89, 0xcb, 0x5c, 0xc7, 0x77, 0x83, 0x68, 0x4a, 0xd9, 0x7a, 0x1d, 0x9d,
+	0x58, 0xaa, 0x38, 0x39, 0x38, 0x4a, 0x67, 0x57, 0x84, 0x73, 0xed, 0x6a, 0x7c, 0x02, 0xaa, 0x4a,
+	0xbb, 0x1a, 0x67, 0xaa, 0xbf, 0x6a, 0x80, 0x70, 0x76, 0x00, 0x93, 0xc5, 0x10, 0x35, 0x53, 0xa0,
+	0xe9, 0x7a, 0x64, 0xc0, 0xaa, 0x92, 0x5d, 0x3c, 0x71, 0xd5, 0xf2, 0x89, 0x33, 0x61, 0x3b, 0xba,
+	0xf5, 0xc7, 0xe7, 0x74, 0x36, 0xa3, 0xaf, 0xd2, 0x4a, 0xa8, 0x60, 0xf1, 0xf9, 0xe0, 0xa5, 0x40,
+	0xe4, 0xc3, 0x5a, 0x72, 0x3e, 0x12, 0xc4, 0xfc, 0x11, 0x18, 0xfd, 0xcc, 0x64, 0xbc, 0xa1, 0x89,
+	0xdf, 0x05, 0x0f, 0xb4, 0x72, 0x6a, 0xfe, 0x01, 0xbc, 0xb7, 0x44, 0x5b, 0xee, 0xdd, 0x03, 0x68,
+	0x10, 0x7f, 0x12, 0x83, 0x42, 0xb9, 0x8a, 0x33, 0xc0, 0xfc, 0x67, 0x03, 0x76, 0x86, 0x21, 0x0d,
+	0xdc, 0x6b, 0x97, 0x91, 0x49, 0xb6, 0x54, 0x5f, 0x82, 0x0b, 0x7e, 0xa8, 0xd4, 0xfd, 0xf2, 0x05,
+	0x5f, 0xed, 0x0b, 0x70, 0x41, 0xfe, 0xdd, 0x05, 0xff, 0xdd, 0x05, 0x3f, 0x0f, 0xf2, 0xfb, 0x78,
+	0x58, 0xe8, 0xd6, 0x8c, 0x56, 0xf1, 0x3e, 0x5e, 0xec, 0xe7, 0x70, 0x49, 0x67, 0xd5, 0x43, 0x41,
+	0xfb, 0xad, 0x3e, 0x14, 0x74, 0xd6, 0x78, 0x28, 0x28, 0xbf, 0x89, 0xe9, 0x9f, 0xf3, 0x4d, 0xac,
+	0xf4, 0xd4, 0xb0, 0xf3, 0xb9, 0x9e, 0x1a, 0xf8, 0xbe, 0xab, 0x4d, 0xac, 0x81, 0x4a, 0xfb, 0xae,
+	0x0a, 0xe0, 0xa2, 0x46, 0xe9, 0xbd, 0x62, 0x77, 0xc9, 0x7b, 0xc5, 0xb7, 0xa1, 0x66, 0x85, 0x21,
+	0x0d, 0xf9, 0xa3, 0xe9, 0x98, 0x4e, 0xe2, 0x47, 0xd3, 0x16, 0x16, 0xdf, 0xbc, 0xa9, 0x9a, 0x47,
+	0xd7, 0xb2, 0xec, 0xf1, 0x4f, 0xf3, 0x2f, 0x1a, 0xa0, 0x7c, 0x0e, 0x4c, 0x13, 0xe7, 0x9b, 0x92,
+	0xe0, 0x37, 0x92, 0x92, 0x18, 0x27, 0xbe, 0x4e, 0x2e, 0x71, 0x70, 0x58, 0xd6, 0x48, 0x74, 0x91,
+	0xe4, 0x4a, 0xb9, 0x0c, 0xdc, 0xba, 0x0c, 0xd2, 0xaf, 0xad, 0x88, 0xf6, 0xc4, 0x01, 0x5c, 0xd6,
+	0x34, 0x4f, 0xe0, 0xee, 0x52, 0x59, 0xf4, 0x01, 0xbf, 0xbf, 0x44, 0x8b, 0x19, 0x4b, 0x6a, 0x6e,
+	0xc9, 0xa1, 0x84, 0x6f, 0x7e, 0x1d, 0x76, 0xe2, 0x18, 0xeb, 0xf9, 0x57, 0x34, 0xc9, 0xf8, 0x71,
+	0xcb, 0x1b, 0x57, 0xc5, 0x8a, 0x37, 0x31, 0xfb, 0x80, 0xf2, 0x42, 0x72, 0x94, 0x82, 0x14, 0x5f,
+	0xdf, 0x29, 0x8d, 0x92, 0xd7, 0x67, 0xf1, 0xcd, 0x31, 0x7e, 0x22, 0x64, 0xfb, 0x2c, 0xbe, 0xcd,
+	0x01, 0xec, 0xa7, 0x59, 0xdf, 0x61, 0x2e, 0x5b, 0x44, 0xb9, 0x0e, 0x65, 0x8d, 0xfb, 0xd0, 0xdf,
+	0x34, 0xb8, 0x57, 0x32, 0x28, 0x7d, 0xdc, 0x87, 0x4d, 0x72, 0xe3, 0x45, 0x62, 0x21, 0x78, 0x99,
+	0x95, 0x14, 0xef, 0x79, 0xbc, 0x28, 0x0e, 0xa3, 0xe4, 0x42, 0x92, 0xd0, 0x3c, 0xa8, 0x7c, 0xf2,
+	0x8a, 0x44, 0x4c, 0x96, 0xc9, 0xaa, 0x28, 0x93, 0x0a, 0xc6, 0x9b, 0xb4, 0xa9, 0x77, 0x3d, 0x7d,
+	0xea, 0x32, 0x12, 0xce, 0xdd, 0xf0, 0xb9, 0x28, 0x38, 0x55, 0xac, 0x82, 0xbc, 0x93, 0x99, 0xb9,
+	0x11, 0xeb, 0x97, 0x6e, 0x86, 0x45, 0xd8, 0xf4, 0xe0, 0x6e, 0x3a, 0x85, 0x01, 0x65, 0xde, 0x95,
+	0x6c, 0x57, 0xd6, 0xbf, 0x25, 0xb3, 0x70, 0xe1, 0x8f, 0x5d, 0x46, 0xe4, 0x83, 0x40, 0x4a, 0x9b,
+	0xbf, 0xd1, 0x40, 0x1f, 0x2e, 0x9e, 0xcd, 0xbc, 0x68, 0x8a, 0x09, 0xbf, 0xf9, 0xac, 0x3f, 0xcc,
+	0xfb, 0xd0, 0x1a, 0xd3, 0x30, 0x24, 0x33, 0xe1, 0x6b, 0x2f, 0x79, 0x0d, 0x52, 0x41, 0x6e, 0x3b,
+	0x24, 0x6e, 0x24, 0xff, 0x31, 0x34, 0xb0, 0xa4, 0x1e, 0xfe, 0xab, 0x02, 0x15, 0x3b, 0x40, 0x7b,
+	0xa0, 0x9f, 0x62, 0xab, 0x3b, 0xb2, 0x2e, 0x87, 0x5d, 0x3c, 0xea, 0x8d, 0x7a, 0xf6, 0x40, 0xbf,
+	0x83, 0xda, 0x00, 0xce, 0x63, 0xdc, 0x1b, 0x7c, 0x7a, 0xd9, 0x73, 0xb0, 0xae, 0xa1, 0x1d, 0x68,
+	0x61, 0x6b, 0x68, 0xe3, 0xd1, 0x65, 0xdf, 0xea, 0x9e, 0x59, 0x58, 0xaf, 0x70, 0xe8, 0xf4, 0x71,
+	0x77, 0xf0, 0x53, 0x2b, 0x81, 0xaa, 0x5c, 0xcb, 0xfa, 0xf9, 0xb0, 0x3b, 0x38, 0x13, 0x5a, 0x1b,
+	0x5c, 0xe4, 0xcc, 0xea, 0x5b, 0x23, 0xeb, 0xd2, 0x19, 0x61, 0xab, 0x7b, 0xa1, 0xd7, 0x90, 0x0e,
+	0xdb, 0xc3, 0xee, 0xcf, 0x9c, 0x14, 0xd9, 0x14, 0x76, 0x62, 0x07, 0x24, 0xb4, 0x15, 0x8f, 0x36,
+	0xe8, 0x5e, 0xa4, 0x50, 0x1d, 0x75, 0xa0, 0x39, 0xc2, 0xbd, 0x8b, 0x04, 0x68, 0x20, 0x04, 0x6d,
+	0x45, 0xcd, 0xd1, 0x01, 0xdd, 0x83, 0x5d, 0xe9, 0x12, 0xb6, 0x86, 0xfd, 0xde, 0x69, 0xf7, 0x12,
+	0xdb, 0x7d, 0x4b, 0x6f, 0xa2, 0x5d, 0xe8, 0x48, 0xf7, 0xbb, 0xa7, 0xa3, 0xde, 0x93, 0xde, 0xe8,
+	0x33, 0x7d, 0x1b, 0x19, 0xb0, 0xe7, 0x58, 0xa3, 0x4b, 0xc7, 0xc2, 0x4f, 0x2c, 0x7c, 0x89, 0xad,
+	0xee, 0xd9, 0xa5, 0x3d, 0xe8, 0x7f, 0xa6, 0xb7, 0xb8, 0xb8, 0x6a, 0xc7, 0xd1, 0xdb, 0xdc, 0x73,
+	0xe7, 0x69, 0x77, 0x98, 0x0e, 0xd7, 0x11, 0x2e, 0xd8, 0x83, 0xf3, 0x1e, 0xbe, 0x48, 0x96, 0x40,
+	0x7f, 0x78, 0x0c, 0x90, 0x5d, 0xa0, 0x50, 0x03, 0x6a, 0xce, 0xc8, 0xc6, 0x96, 0x7e, 0x07, 0x01,
+	0x6c, 0x62, 0xeb, 0x13, 0xeb, 0x74, 0xa4, 0x6b, 0xa8, 0x05, 0x8d, 0x91, 0x7d, 0x71, 0xe2, 0x8c,
+	0xec, 0x81, 0xa5, 0x57, 0x4e, 0xf4, 0xbf, 0xbf, 0x3e, 0xd0, 0xfe, 0xf1, 0xfa, 0x40, 0xfb, 0xf7,
+	0xeb, 0x03, 0xed, 0x0f, 0xff, 0x39, 0xb8, 0xf3, 0x6c, 0x53, 0xe4, 0x8b, 0x8f, 0xfe, 0x3f, 0x00,
+	0xe2, 0xcd, 0xe2, 0xc3, 0x5a, 0x1b, 0x00, 0x00,
 }
@@ -350,6 +350,25 @@ func (s *Server) createRaftNode() (bool, error) {
 		return false, err
 	}
 
+	// Invalidate the broker cache when servers are added to or removed from
+	// the cluster. Followers also detect this when they see the server IDs
+	// in the Raft configuration change.
+	observations := make(chan raft.Observation, 1)
+	node.RegisterObserver(raft.NewObserver(observations, false, func(o *raft.Observation) bool {
+		_, ok := o.Data.(raft.PeerObservation)
+		return ok
+	}))
+	s.startGoroutine(func() {
+		for {
+			select {
+			case <-observations:
+				s.metadata.invalidateBrokerCache()
+			case <-s.shutdownCh:
+				return
+			}
+		}
+	})
+
 	s.setRaft(&raftNode{
 		Raft:      node,
 		store:     logStore,
@@ -0,0 +1,349 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// replicaMoveCheckInterval is how often a replica move checks whether the new
+// replica has joined the ISR. This is a var for testing purposes.
+var replicaMoveCheckInterval = 100 * time.Millisecond
+
+// replicaMove moves a partition replica from one server to another.
+type replicaMove struct {
+	partition *partition
+	from      string
+	to        string
+}
+
+// RebalanceReplicas evens out the number of stream partition replicas placed
+// on each server by moving replicas from the servers with the most replicas to
+// the servers with the fewest until the difference is at most the configured
+// ReplicaRebalanceThreshold. Each replica is moved by adding the destination
+// server to the partition's replicas, waiting for it to catch up from the
+// leader and join the ISR, and then removing the source server, which drops
+// its copy of the partition. Partition leaders are not moved, nor are paused
+// partitions, and replicas are not moved to read-only servers, servers at
+// ServerMaxReplicas, or servers outside the set a stream was pinned to when it
+// was created. This returns the number of replicas moved. If the context
+// is canceled while waiting for a new replica to join the ISR, the partition
+// is left with both replicas. The replica set changes are forwarded to the
+// metadata leader if this server is not the leader.
+func (s *Server) RebalanceReplicas(ctx context.Context) (int, error) {
+	moved := 0
+	for {
+		move, err := s.nextReplicaMove()
+		if err != nil {
+			return moved, err
+		}
+		if move == nil {
+			return moved, nil
+		}
+		if err := s.moveReplica(ctx, move); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+}
+
+// nextReplicaMove returns the next replica to move to even out the number of
+// replicas placed on each server or nil if the servers are balanced or no
+// replica can be moved.
+func (s *Server) nextReplicaMove() (*replicaMove, error) {
+	ids, err := s.metadata.getClusterServerIDs()
+	if err != nil {
+		return nil, err
+	}
+	counts := s.metadata.getReplicaCounts()
+	for _, id := range ids {
+		if _, ok := counts[id]; !ok {
+			counts[id] = 0
+		}
+	}
+	// Order servers from most to fewest replicas, breaking ties by ID so
+	// moves are deterministic.
+	sort.Slice(ids, func(i, j int) bool {
+		if counts[ids[i]] != counts[ids[j]] {
+			return counts[ids[i]] > counts[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	threshold := s.config.Clustering.ReplicaRebalanceThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	readOnly := make(map[string]struct{})
+	for _, id := range s.metadata.GetReadOnlyServers() {
+		readOnly[id] = struct{}{}
+	}
+	maxReplicas := s.config.Clustering.ServerMaxReplicas
+	for _, from := range ids {
+		for i := len(ids) - 1; i >= 0; i-- {
+			to := ids[i]
+			if counts[from]-counts[to] <= threshold {
+				break
+			}
+			if _, ok := readOnly[to]; ok {
+				continue
+			}
+			if maxReplicas > 0 && counts[to] >= maxReplicas {
+				continue
+			}
+			if partition := s.movablePartition(from, to); partition != nil {
+				return &replicaMove{partition: partition, from: from, to: to}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// movablePartition returns a partition with a replica on the from server
+// which can be moved to the to server or nil if there is none.
+func (s *Server) movablePartition(from, to string) *partition {
+	for _, stream := range s.metadata.GetStreams() {
+		for _, partition := range stream.GetPartitions() {
+			if partition.IsPaused() {
+				continue
+			}
+			if leader, _ := partition.GetLeader(); leader == from {
+				continue
+			}
+			if !partition.isReplica(from) || partition.isReplica(to) || partition.isObserver(to) {
+				continue
+			}
+			if !partition.allowsReplicaOn(to) {
+				continue
+			}
+			return partition
+		}
+	}
+	return nil
+}
+
+// moveReplica adds the destination server to the partition's replicas, waits
+// for it to join the ISR, and then removes the source server.
+func (s *Server) moveReplica(ctx context.Context, move *replicaMove) error {
+	var (
+		partition = move.partition
+		replicas  = partition.GetReplicas()
+	)
+	s.logger.Infof("Moving replica of partition %s from %s to %s", partition, move.from, move.to)
+
+	st := s.metadata.ChangeReplicas(ctx, &proto.ChangeReplicasOp{
+		Stream:    partition.Stream,
+		Partition: partition.Id,
+		Replicas:  append(replicas, move.to),
+	})
+	if st != nil {
+		return st.Err()
+	}
+
+	// Wait for the new replica to catch up.
+	if err := waitForReplicaMove(ctx, func() bool { return partition.inISR(move.to) }); err != nil {
+		return err
+	}
+
+	remaining := make([]string, 0, len(replicas))
+	for _, replica := range replicas {
+		if replica != move.from {
+			remaining = append(remaining, replica)
+		}
+	}
+	remaining = append(remaining, move.to)
+	st = s.metadata.ChangeReplicas(ctx, &proto.ChangeReplicasOp{
+		Stream:    partition.Stream,
+		Partition: partition.Id,
+		Replicas:  remaining,
+	})
+	if st != nil {
+		return st.Err()
+	}
+
+	// Wait for the removal to be applied locally so the next move is based
+	// on the current replica counts.
+	return waitForReplicaMove(ctx, func() bool { return !partition.isReplica(move.from) })
+}
+
+// replaceReplica moves the given replica of the partition, which has failed to
+// catch up the given number of times, to the server with the fewest replicas
+// which isn't a replica or observer of the partition, read-only, at
+// ServerMaxReplicas, or excluded by the servers the partition is pinned to. OnReplicaReplaced is invoked once the replica is moved.
+func (s *Server) replaceReplica(ctx context.Context, partition *partition, replica string, failures int) {
+	to, err := s.replacementServer(partition)
+	if err != nil {
+		s.logger.Errorf("Failed to replace replica %s for partition %s: %v", replica, partition, err)
+		return
+	}
+	if to == "" {
+		s.logger.Warnf("No server to replace replica %s for partition %s with", replica, partition)
+		return
+	}
+	move := &replicaMove{partition: partition, from: replica, to: to}
+	if err := s.moveReplica(ctx, move); err != nil {
+		s.logger.Errorf("Failed to replace replica %s for partition %s: %v", replica, partition, err)
+		return
+	}
+	s.logger.Infof("Replaced replica %s for partition %s with %s", replica, partition, to)
+	if onReplaced := s.config.Clustering.OnReplicaReplaced; onReplaced != nil {
+		go onReplaced(ReplicaReplacement{
+			Stream:      partition.Stream,
+			Partition:   partition.Id,
+			Replaced:    replica,
+			Replacement: to,
+			Failures:    failures,
+		})
+	}
+}
+
+// replacementServer returns the server with the fewest replicas, breaking ties
+// by ID, which can take a new replica of the partition or an empty string if
+// there is none.
+func (s *Server) replacementServer(partition *partition) (string, error) {
+	ids, err := s.metadata.getClusterServerIDs()
+	if err != nil {
+		return "", err
+	}
+	var (
+		counts      = s.metadata.getReplicaCounts()
+		maxReplicas = s.config.Clustering.ServerMaxReplicas
+		readOnly    = make(map[string]struct{})
+		best        string
+	)
+	for _, id := range s.metadata.GetReadOnlyServers() {
+		readOnly[id] = struct{}{}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if partition.isReplica(id) || partition.isObserver(id) || !partition.allowsReplicaOn(id) {
+			continue
+		}
+		if _, ok := readOnly[id]; ok {
+			continue
+		}
+		if maxReplicas > 0 && counts[id] >= maxReplicas {
+			continue
+		}
+		if best == "" || counts[id] < counts[best] {
+			best = id
+		}
+	}
+	return best, nil
+}
+
+// ReassignPartition moves a stream partition to the given replica set and
+// hands off its leadership to the given leader, which must be one of the
+// replicas. This is used when scaling out to move partitions onto new
+// servers. The new replicas are first added alongside the existing ones and
+// the reassignment waits for all of them to catch up from the current leader
+// and join the ISR. If the current leader is not the given leader, leadership
+// is then handed off to it, after which the replicas that are not part of the
+// new set are removed and drop their copy of the partition. Because the new
+// leader is in the ISR when it takes over, no committed messages are lost and
+// the partition remains available throughout. If the context is canceled
+// while waiting, the partition is left with both the old and new replicas.
+// ErrStreamNotFound or ErrPartitionNotFound is returned if the stream or
+// partition does not exist. The changes are forwarded to the metadata leader
+// if this server is not the leader. It fails with Aborted if the Context
+// carries a fencing token, set with FencingTokenMetadataKey, below the fencing
+// epoch.
+func (s *Server) ReassignPartition(ctx context.Context, stream string, partitionID int32,
+	replicas []string, leader string) error {
+
+	if len(replicas) == 0 {
+		return errors.New("no replicas provided")
+	}
+	isReplica := false
+	for _, replica := range replicas {
+		if replica == leader {
+			isReplica = true
+			break
+		}
+	}
+	if !isReplica {
+		return errors.New("leader must be one of the replicas")
+	}
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return err
+	}
+	partition := partitions[0]
+	s.logger.Infof("Reassigning partition %s to replicas %v with leader %s",
+		partition, replicas, leader)
+
+	// Add the new replicas alongside the current ones and wait for them to
+	// catch up.
+	expanded := partition.GetReplicas()
+	for _, replica := range replicas {
+		if !partition.isReplica(replica) {
+			expanded = append(expanded, replica)
+		}
+	}
+	st := s.metadata.ChangeReplicas(ctx, &proto.ChangeReplicasOp{
+		Stream:    partition.Stream,
+		Partition: partition.Id,
+		Replicas:  expanded,
+	})
+	if st != nil {
+		return st.Err()
+	}
+	err = waitForReplicaMove(ctx, func() bool {
+		for _, replica := range replicas {
+			if !partition.inISR(replica) {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	// Hand off leadership now that the new leader is caught up.
+	st = s.metadata.ChangeLeader(ctx, &proto.ChangeLeaderOp{
+		Stream:    partition.Stream,
+		Partition: partition.Id,
+		Leader:    leader,
+	})
+	if st != nil {
+		return st.Err()
+	}
+	err = waitForReplicaMove(ctx, func() bool {
+		current, _ := partition.GetLeader()
+		return current == leader
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remove the replicas which are not part of the new set.
+	st = s.metadata.ChangeReplicas(ctx, &proto.ChangeReplicasOp{
+		Stream:    partition.Stream,
+		Partition: partition.Id,
+		Replicas:  replicas,
+	})
+	if st != nil {
+		return st.Err()
+	}
+	return waitForReplicaMove(ctx, func() bool {
+		return len(partition.GetReplicas()) == len(replicas)
+	})
+}
+
+// waitForReplicaMove waits until the given condition of a replica move holds
+// or the context is canceled.
+func waitForReplicaMove(ctx context.Context, done func() bool) error {
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(replicaMoveCheckInterval):
+		}
+	}
+	return nil
+}
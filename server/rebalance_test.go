@@ -0,0 +1,384 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+	grpcMetadata "google.golang.org/grpc/metadata"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+)
+
+// Ensure rebalancing moves replicas from the servers with the most replicas
+// to the server with none, resyncing the moved partitions to it, until the
+// replica counts are within the threshold.
+func TestRebalanceReplicas(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Clustering.ReplicaMaxLagTime = time.Second
+	s1Config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+	s1Config.Clustering.ReplicaFetchTimeout = 500 * time.Millisecond
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Clustering.ReplicaMaxLagTime = time.Second
+	s2Config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+	s2Config.Clustering.ReplicaFetchTimeout = 500 * time.Millisecond
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	servers := []*Server{s1, s2}
+	leader := getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Place every replica on a and b before c joins so that c has none.
+	// Leaders aren't moved, so make a and b each lead half of the partitions
+	// by making the other read-only while creating them.
+	num := 5
+	streams := []string{"foo", "bar", "baz", "qux"}
+	for i, name := range streams {
+		readOnly := "a"
+		if i%2 == 0 {
+			readOnly = "b"
+		}
+		require.NoError(t, leader.SetServerReadOnly(context.Background(), readOnly, true))
+		require.NoError(t, client.CreateStream(context.Background(), name, name,
+			lift.ReplicationFactor(2)))
+		require.NoError(t, leader.SetServerReadOnly(context.Background(), readOnly, false))
+		waitForISR(t, 10*time.Second, name, 0, 2, servers...)
+		for i := 0; i < num; i++ {
+			_, err := client.Publish(context.Background(), name, []byte(strconv.Itoa(i)),
+				lift.AckPolicyAll())
+			require.NoError(t, err)
+		}
+	}
+	require.Equal(t, map[string]int{"a": 4, "b": 4}, leader.metadata.getReplicaCounts())
+
+	s3Config := getTestConfig("c", false, 5052)
+	s3Config.Clustering.ReplicaMaxLagTime = time.Second
+	s3Config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+	s3Config.Clustering.ReplicaFetchTimeout = 500 * time.Millisecond
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+	servers = append(servers, s3)
+	waitForClusterSize(t, 10*time.Second, len(servers), servers...)
+	for _, name := range streams {
+		waitForPartition(t, 10*time.Second, name, 0, s3)
+	}
+
+	// Rebalance from a server which isn't the metadata leader.
+	var rebalancer *Server
+	for _, s := range servers {
+		if s != leader {
+			rebalancer = s
+			break
+		}
+	}
+	rctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	moved, err := rebalancer.RebalanceReplicas(rctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, moved)
+
+	counts := leader.metadata.getReplicaCounts()
+	require.Len(t, counts, 3)
+	for _, a := range counts {
+		for _, b := range counts {
+			require.True(t, a-b <= 1, "replica counts %v not balanced", counts)
+		}
+	}
+
+	// The moved partitions were resynced to c, and the partitions keep two
+	// replicas which are both in the ISR.
+	onC := 0
+	for _, name := range streams {
+		partition := leader.metadata.GetPartition(name, 0)
+		require.Len(t, partition.GetReplicas(), 2)
+		waitForISR(t, 10*time.Second, name, 0, 2, servers...)
+		if partition.isReplica("c") {
+			onC++
+			waitForHW(t, 5*time.Second, name, 0, int64(num-1), s3)
+			require.Equal(t, int64(num-1), s3.metadata.GetPartition(name, 0).log.NewestOffset())
+		}
+	}
+	require.Equal(t, counts["c"], onC)
+
+	// The servers are now balanced, so there is nothing to move.
+	moved, err = rebalancer.RebalanceReplicas(rctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, moved)
+}
+
+// Ensure replicas of a stream pinned to a set of servers are not moved or
+// replaced onto servers outside that set.
+func TestRebalanceReplicasPinned(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	s3Config := getTestConfig("c", false, 5052)
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := []*Server{s1, s2, s3}
+	leader := getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Pin every replica to a and b so that c has none.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		ReplicaServersMetadataKey, "a", ReplicaServersMetadataKey, "b")
+	require.NoError(t, client.CreateStream(ctx, "foo", "foo",
+		lift.ReplicationFactor(2), lift.Partitions(4)))
+	for i := int32(0); i < 4; i++ {
+		waitForPartition(t, 10*time.Second, "foo", i, servers...)
+		for _, s := range servers {
+			require.Equal(t, []string{"a", "b"}, s.metadata.GetPartition("foo", i).GetPinnedServers())
+		}
+	}
+	require.Equal(t, map[string]int{"a": 4, "b": 4}, leader.metadata.getReplicaCounts())
+
+	// The replicas can't be moved to c.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	moved, err := leader.RebalanceReplicas(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, moved)
+
+	// Nor can a replica be replaced with c.
+	to, err := leader.replacementServer(leader.metadata.GetPartition("foo", 0))
+	require.NoError(t, err)
+	require.Equal(t, "", to)
+}
+
+// Ensure reassigning a partition moves its replicas and leadership to a new
+// set of servers without losing messages and that the partition remains
+// available afterwards.
+func TestReassignPartition(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers.
+	var servers []*Server
+	for i, id := range []string{"a", "b", "c", "d"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxLagTime = time.Second
+		config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+		config.Clustering.ReplicaFetchTimeout = 500 * time.Millisecond
+		s := runServerWithConfig(t, config)
+		defer s.Stop()
+		servers = append(servers, s)
+	}
+	leader := getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051",
+		"localhost:5052", "localhost:5053"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Place both partitions on a and b.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		ReplicaServersMetadataKey, "a", ReplicaServersMetadataKey, "b")
+	require.NoError(t, client.CreateStream(ctx, "foo", "foo", lift.ReplicationFactor(2),
+		lift.Partitions(2)))
+	waitForISR(t, 10*time.Second, "foo", 0, 2, servers...)
+	waitForISR(t, 10*time.Second, "foo", 1, 2, servers...)
+	num := 5
+	for i := 0; i < num; i++ {
+		_, err := client.Publish(context.Background(), "foo", []byte(strconv.Itoa(i)),
+			lift.ToPartition(1), lift.AckPolicyAll())
+		require.NoError(t, err)
+	}
+	oldLeader, _, err := leader.PartitionLeader("foo", 0)
+	require.NoError(t, err)
+
+	// Invalid reassignments are rejected.
+	rctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.Error(t, leader.ReassignPartition(rctx, "foo", 1, nil, "c"))
+	require.Error(t, leader.ReassignPartition(rctx, "foo", 1, []string{"c", "d"}, "a"))
+	require.Equal(t, ErrStreamNotFound,
+		leader.ReassignPartition(rctx, "bar", 1, []string{"c", "d"}, "c"))
+	require.Equal(t, ErrPartitionNotFound,
+		leader.ReassignPartition(rctx, "foo", 2, []string{"c", "d"}, "c"))
+
+	// Reassign partition 1 to c and d from a server which isn't the metadata
+	// leader.
+	var reassigner *Server
+	for _, s := range servers {
+		if s != leader {
+			reassigner = s
+			break
+		}
+	}
+	require.NoError(t, reassigner.ReassignPartition(rctx, "foo", 1, []string{"c", "d"}, "c"))
+
+	// Every server agrees on the new placement and leader, and partition 0
+	// is untouched.
+	for _, s := range servers {
+		waitForPartition(t, 10*time.Second, "foo", 1, s)
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			partition := s.metadata.GetPartition("foo", 1)
+			current, _ := partition.GetLeader()
+			if current == "c" && len(partition.GetReplicas()) == 2 &&
+				partition.isReplica("c") && partition.isReplica("d") {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Partition was not reassigned")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		current, _, err := s.PartitionLeader("foo", 0)
+		require.NoError(t, err)
+		require.Equal(t, oldLeader, current)
+	}
+
+	// The messages were resynced to the new replicas intact.
+	for _, s := range servers[2:] {
+		waitForHW(t, 5*time.Second, "foo", 1, int64(num-1), s)
+		require.Equal(t, int64(num-1), s.metadata.GetPartition("foo", 1).log.NewestOffset())
+	}
+
+	// The partition is still available for publishes and subscribes.
+	_, err = client.Publish(context.Background(), "foo", []byte(strconv.Itoa(num)),
+		lift.ToPartition(1), lift.AckPolicyAll())
+	require.NoError(t, err)
+
+	recv := 0
+	ch := make(chan struct{})
+	sctx, scancel := context.WithCancel(context.Background())
+	defer scancel()
+	err = client.Subscribe(sctx, "foo", func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		require.Equal(t, int64(recv), msg.Offset())
+		require.Equal(t, []byte(strconv.Itoa(recv)), msg.Value())
+		recv++
+		if recv == num+1 {
+			close(ch)
+		}
+	}, lift.Partition(1), lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+
+	select {
+	case <-ch:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Did not receive all expected messages")
+	}
+}
+
+// Ensure a partition leader replaces a follower which repeatedly fails to
+// catch up with a replica on another server.
+func TestReplaceLaggingReplica(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers.
+	replaced := make(chan ReplicaReplacement, 1)
+	var servers []*Server
+	runServer := func(id string, port int) {
+		config := getTestConfig(id, len(servers) == 0, port)
+		config.Clustering.ReplicaMaxLagTime = 200 * time.Millisecond
+		config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+		config.Clustering.ReplicaFetchTimeout = 100 * time.Millisecond
+		config.Clustering.ReplicaMaxCatchUpFailures = 3
+		config.Clustering.OnReplicaReplaced = func(r ReplicaReplacement) {
+			replaced <- r
+		}
+		servers = append(servers, runServerWithConfig(t, config))
+	}
+	defer func() {
+		for _, s := range servers {
+			s.Stop()
+		}
+	}()
+	runServer("a", 5050)
+	runServer("b", 5051)
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Place the partition on a and b before c joins.
+	require.NoError(t, client.CreateStream(context.Background(), "foo", "foo",
+		lift.ReplicationFactor(2)))
+	leader := getPartitionLeader(t, 10*time.Second, "foo", 0, servers...)
+	waitForISR(t, 10*time.Second, "foo", 0, 2, servers...)
+	_, err = client.Publish(context.Background(), "foo", []byte("hello"), lift.AckPolicyAll())
+	require.NoError(t, err)
+
+	runServer("c", 5052)
+	waitForClusterSize(t, 10*time.Second, len(servers), servers...)
+	waitForPartition(t, 10*time.Second, "foo", 0, servers[2])
+
+	var follower *Server
+	for _, s := range servers[:2] {
+		if s != leader {
+			follower = s
+		}
+	}
+	followerID := follower.config.Clustering.ServerID
+
+	// Stop the follower from replicating so it chronically lags.
+	partition := follower.metadata.GetPartition("foo", 0)
+	require.NotNil(t, partition)
+	partition.mu.Lock()
+	require.NoError(t, partition.stopFollowing())
+	partition.mu.Unlock()
+
+	// The lagging follower is replaced with c once it fails to catch up
+	// three times.
+	select {
+	case r := <-replaced:
+		require.Equal(t, ReplicaReplacement{
+			Stream:      "foo",
+			Partition:   0,
+			Replaced:    followerID,
+			Replacement: "c",
+			Failures:    3,
+		}, r)
+	case <-time.After(30 * time.Second):
+		t.Fatal("Expected lagging replica to be replaced")
+	}
+
+	leaderPartition := leader.metadata.GetPartition("foo", 0)
+	require.NotNil(t, leaderPartition)
+	require.ElementsMatch(t, []string{leader.config.Clustering.ServerID, "c"},
+		leaderPartition.GetReplicas())
+	require.True(t, leaderPartition.inISR("c"))
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// ReplicationCodec frames the replication responses partition leaders send to
+// followers. The message data is always in the commit log's message set
+// format, so a codec only controls how responses are encoded on the wire, not
+// the on-disk format. All servers in a cluster must use the same codec, and a
+// codec should not add more than replicationOverhead bytes to the data.
+type ReplicationCodec interface {
+	// EncodeReplicationResponse appends a replication response containing
+	// the leader epoch, HW, and message set data to dst and returns the
+	// extended slice.
+	EncodeReplicationResponse(dst []byte, leaderEpoch uint64, hw int64, data []byte) ([]byte, error)
+
+	// DecodeReplicationResponse returns the leader epoch, HW, and message set
+	// data from a replication response.
+	DecodeReplicationResponse(data []byte) (uint64, int64, []byte, error)
+}
+
+// envelopeReplicationCodec is the default ReplicationCodec which frames
+// replication responses using the Liftbridge envelope protocol.
+type envelopeReplicationCodec struct{}
+
+// EncodeReplicationResponse appends a replication response envelope
+// containing the leader epoch, HW, and message set data to dst.
+func (envelopeReplicationCodec) EncodeReplicationResponse(dst []byte, leaderEpoch uint64, hw int64,
+	data []byte) ([]byte, error) {
+
+	buf := bytes.NewBuffer(dst)
+	proto.WriteReplicationResponseHeader(buf)
+	if err := binary.Write(buf, proto.Encoding, leaderEpoch); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, proto.Encoding, hw); err != nil {
+		return nil, err
+	}
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+// DecodeReplicationResponse deserializes a replication response envelope.
+func (envelopeReplicationCodec) DecodeReplicationResponse(data []byte) (uint64, int64, []byte, error) {
+	return proto.UnmarshalReplicationResponse(data)
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+)
+
+// varintReplicationCodec is a ReplicationCodec which frames replication
+// responses using varints for the leader epoch and HW.
+type varintReplicationCodec struct {
+	decoded int64
+}
+
+func (c *varintReplicationCodec) EncodeReplicationResponse(dst []byte, leaderEpoch uint64, hw int64,
+	data []byte) ([]byte, error) {
+
+	var buf [2 * binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], leaderEpoch)
+	n += binary.PutVarint(buf[n:], hw)
+	dst = append(dst, buf[:n]...)
+	return append(dst, data...), nil
+}
+
+func (c *varintReplicationCodec) DecodeReplicationResponse(data []byte) (uint64, int64, []byte, error) {
+	leaderEpoch, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, 0, nil, errors.New("invalid leader epoch")
+	}
+	hw, m := binary.Varint(data[n:])
+	if m <= 0 {
+		return 0, 0, nil, errors.New("invalid HW")
+	}
+	atomic.AddInt64(&c.decoded, 1)
+	return leaderEpoch, hw, data[n+m:], nil
+}
+
+// Ensure the default envelope codec round trips replication responses.
+func TestEnvelopeReplicationCodec(t *testing.T) {
+	codec := envelopeReplicationCodec{}
+	data, err := codec.EncodeReplicationResponse(nil, 3, 42, []byte("hello"))
+	require.NoError(t, err)
+	require.Len(t, data, replicationOverhead+5)
+
+	leaderEpoch, hw, msgs, err := codec.DecodeReplicationResponse(data)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), leaderEpoch)
+	require.Equal(t, int64(42), hw)
+	require.Equal(t, []byte("hello"), msgs)
+}
+
+// Ensure followers catch up with the leader when using an alternative
+// ReplicationCodec.
+func TestReplicationCustomCodec(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	var (
+		codecs  = make([]*varintReplicationCodec, 3)
+		servers = make([]*Server, 3)
+	)
+	for i, id := range []string{"a", "b", "c"} {
+		codecs[i] = &varintReplicationCodec{}
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxIdleWait = 500 * time.Millisecond
+		config.Clustering.ReplicationCodec = codecs[i]
+		servers[i] = runServerWithConfig(t, config)
+		defer servers[i].Stop()
+	}
+
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	subject := "foo"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = client.CreateStream(ctx, subject, name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+
+	// Publish messages.
+	num := 100
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	// Wait for HW to update on followers.
+	waitForHW(t, 5*time.Second, name, 0, int64(num-1), servers...)
+
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	for i, s := range servers {
+		partition := s.metadata.GetPartition(name, 0)
+		require.Equal(t, int64(num-1), partition.log.NewestOffset())
+		if s != leader {
+			require.True(t, atomic.LoadInt64(&codecs[i].decoded) > 0)
+		}
+	}
+}
@@ -3,8 +3,9 @@ package server
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -20,10 +21,17 @@ const (
 	replicationMaxSize = 1024 * 1024
 
 	// replicationOverhead is the non-data size overhead of replication
-	// messages: 8 bytes for the leader epoch and 8 bytes for the HW.
-	replicationOverhead = 16
+	// messages: 8 bytes for the envelope header, 8 bytes for the leader epoch,
+	// and 8 bytes for the HW. A ReplicationCodec should not exceed this.
+	replicationOverhead = 24
 )
 
+// errCatchUpThrottled is returned by replicate when a batch for a follower
+// which is catching up could not be sent within the catch-up throttle's max
+// wait. The follower is sent a response without messages so that its fetch
+// does not time out.
+var errCatchUpThrottled = errors.New("catch-up replication throttled")
+
 // replicationRequest wraps a ReplicationRequest protobuf and a NATS subject
 // where responses should be sent.
 type replicationRequest struct {
@@ -35,31 +43,48 @@ type replicationRequest struct {
 // replicator handles replication requests from a particular replica and tracks
 // its health. Requests are received on the requests channel and a long-running
 // loop processes them and sends responses. If the replica does not catch up to
-// the leader's log in maxLagTime, it's removed from the ISR until it catches
-// back up.
+// the leader's log in maxLagTime or falls more than maxLagOffsets behind it,
+// it's removed from the ISR until it catches back up.
 type replicator struct {
-	partition    *partition
-	replica      string
-	maxLagTime   time.Duration
-	lastCaughtUp time.Time
-	lastSeen     time.Time
-	requests     chan replicationRequest
-	mu           sync.RWMutex
-	leader       string
-	epoch        uint64
-	headersBuf   [28]byte // scratch buffer for reading message headers
-	writer       replicationProtocolWriter
-	waiter       <-chan struct{}
+	partition     *partition
+	replica       string
+	maxLagTime    time.Duration
+	maxLagOffsets int64
+	lagCheck      chan struct{}
+	lastCaughtUp  time.Time
+	lastSeen      time.Time
+	offset        int64 // latest offset the replica has reported
+	requests      chan replicationRequest
+	mu            sync.RWMutex
+	leader        string
+	epoch         uint64
+	headersBuf    [28]byte // scratch buffer for reading message headers
+	writer        replicationProtocolWriter
+	waiter        <-chan struct{}
+	hwWaiter      <-chan struct{}
+	observer      bool  // Replica is an observer which never joins the ISR
+	removed       bool  // Replica was removed from the partition's replica set
+	syncFollower  bool  // Replica fetches from a sync source and stays out of the ISR
+	syncTarget    int64 // HW when a replica fetching from a sync source last reported its offset
+
+	// catchUpFailures is the number of consecutive maxLagTime periods the
+	// replica has been out of sync. It's only accessed by tick.
+	catchUpFailures int
+	replacing       int32 // Set while the replica is being replaced
 }
 
 func newReplicator(epoch uint64, replica string, p *partition) *replicator {
 	return &replicator{
-		epoch:      epoch,
-		replica:    replica,
-		partition:  p,
-		requests:   make(chan replicationRequest, 1),
-		maxLagTime: p.srv.config.Clustering.ReplicaMaxLagTime,
-		leader:     p.srv.config.Clustering.ServerID,
+		epoch:         epoch,
+		replica:       replica,
+		partition:     p,
+		offset:        -1,
+		syncTarget:    -1,
+		requests:      make(chan replicationRequest, 1),
+		maxLagTime:    p.replicaMaxLagTime(),
+		maxLagOffsets: p.replicaMaxLagOffsets(),
+		lagCheck:      make(chan struct{}, 1),
+		leader:        p.srv.config.Clustering.ServerID,
 	}
 }
 
@@ -91,17 +116,60 @@ func (r *replicator) start(stop <-chan struct{}) {
 
 		r.mu.Lock()
 		r.lastSeen = req.received
+		r.offset = req.Offset
+		r.syncFollower = req.SyncFollower
 		r.mu.Unlock()
 
-		// Update the ISR replica's latest offset for the partition. This is
-		// used by the leader to know when to commit messages.
-		r.partition.updateISRLatestOffset(r.replica, req.Offset)
-
 		var (
 			latest   = r.partition.log.NewestOffset()
 			earliest = r.partition.log.OldestOffset()
 		)
 
+		// A replica ahead of the leader has messages the leader doesn't,
+		// e.g. uncommitted messages left over from a failover. Its offset
+		// can't count towards commits, and it must truncate to the leader's
+		// log before replicating, otherwise the logs would diverge.
+		if req.Offset > latest {
+			r.partition.srv.logger.Warnf("Replica %s for partition %s is ahead of the leader "+
+				"(replica offset %d, leader offset %d), notifying it to truncate",
+				r.replica, r.partition, req.Offset, latest)
+			r.partition.sendTruncateNotification(r.replica)
+			if err := r.sendHW(req.request); err != nil {
+				r.partition.srv.logger.Errorf("Failed to send HW for partition %s to replica %s: %v",
+					r.partition, req.ReplicaID, err)
+			}
+			continue
+		}
+
+		// A replica fetching from a sync source only receives committed
+		// messages, so it can't catch up to the leader's log end offset.
+		// It's caught up if it has the messages which were committed when it
+		// last reported its offset.
+		if req.ReportOnly {
+			hw := r.partition.log.HighWatermark()
+			r.mu.Lock()
+			if req.Offset >= r.syncTarget {
+				r.lastCaughtUp = req.received
+			}
+			r.syncTarget = hw
+			r.mu.Unlock()
+			if err := r.sendHW(req.request); err != nil {
+				r.partition.srv.logger.Errorf("Failed to send HW for partition %s to replica %s: %v",
+					r.partition, req.ReplicaID, err)
+			}
+			continue
+		}
+
+		// Update the ISR replica's latest offset for the partition. This is
+		// used by the leader to know when to commit messages.
+		r.partition.updateISRLatestOffset(r.replica, req.Offset)
+
+		// Check the replica's health now rather than on the next tick if
+		// it has fallen too far behind so that it leaves the ISR promptly.
+		if r.maxLagOffsets > 0 && latest-req.Offset > r.maxLagOffsets {
+			r.checkLag()
+		}
+
 		// Check if we're caught up.
 		if req.Offset >= latest {
 			r.caughtUp(stop, latest, req)
@@ -127,7 +195,9 @@ func (r *replicator) start(stop <-chan struct{}) {
 		}
 
 		// Send a batch of messages to the replica.
-		if err := r.replicate(ctx, reader, req.request, req.Offset); err != nil {
+		err = r.replicate(ctx, stop, reader, req.request, req.Offset)
+		reader.Close()
+		if err != nil {
 			// Send a response to short-circuit request timeout.
 			if err := r.sendHW(req.request); err != nil {
 				r.partition.srv.logger.Errorf("Failed to send HW for partition %s to replica %s: %v",
@@ -138,6 +208,14 @@ func (r *replicator) start(stop <-chan struct{}) {
 	}
 }
 
+// getOffset returns the latest log offset the replica has reported in a
+// replication request, or -1 if it hasn't sent one.
+func (r *replicator) getOffset() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.offset
+}
+
 func (r *replicator) request(req replicationRequest) {
 	select {
 	case r.requests <- req:
@@ -147,44 +225,140 @@ func (r *replicator) request(req replicationRequest) {
 	}
 }
 
+// checkLag wakes the tick loop to check the replica's health immediately.
+func (r *replicator) checkLag() {
+	select {
+	case r.lagCheck <- struct{}{}:
+	default:
+	}
+}
+
 // tick is a long-running call that checks to see if the follower hasn't sent
 // any replication requests or hasn't consumed up to the leader's log end
-// offset for the lag-time duration. If this is the case, the follower is
-// removed from the ISR until it catches back up.
+// offset for the lag-time duration, or if it's more than maxLagOffsets behind
+// the leader's log end offset. If this is the case, the follower is removed
+// from the ISR until it catches back up.
 func (r *replicator) tick(stop <-chan struct{}) {
 	ticker := time.NewTicker(r.maxLagTime)
 	defer ticker.Stop()
-	var now time.Time
+	var (
+		now      time.Time
+		periodic bool
+	)
 	for {
 		select {
 		case <-stop:
 			return
 		case now = <-ticker.C:
+			periodic = true
+		case <-r.lagCheck:
+			now = time.Now()
+			periodic = false
 		}
 		r.mu.RLock()
 		var (
 			lastSeenElapsed     = now.Sub(r.lastSeen)
 			lastCaughtUpElapsed = now.Sub(r.lastCaughtUp)
+			offsetLag           = r.partition.log.NewestOffset() - r.offset
+			observer            = r.observer
+			removed             = r.removed
+			syncFollower        = r.syncFollower
 		)
 		r.mu.RUnlock()
-		outOfSync := lastSeenElapsed > r.maxLagTime || lastCaughtUpElapsed > r.maxLagTime
+		if observer || removed {
+			// Observers never join the ISR and removed replicas no longer
+			// replicate, so their health is not tracked.
+			continue
+		}
+		var (
+			lagging   = lastSeenElapsed > r.maxLagTime || lastCaughtUpElapsed > r.maxLagTime
+			behind    = r.maxLagOffsets > 0 && offsetLag > r.maxLagOffsets
+			outOfSync = lagging || behind
+		)
 		if outOfSync && r.partition.inISR(r.replica) {
 			// Follower has not sent a request or has not caught up in
-			// maxLagTime, so remove it from the ISR.
-			r.partition.srv.logger.Errorf("Replica %s for partition %s exceeded max lag time "+
-				"(last seen: %s, last caught up: %s), removing from ISR",
-				r.replica, r.partition, lastSeenElapsed, lastCaughtUpElapsed)
+			// maxLagTime or is too far behind, so remove it from the ISR.
+			if lagging {
+				r.partition.srv.logger.Errorf("Replica %s for partition %s exceeded max lag time "+
+					"(last seen: %s, last caught up: %s), removing from ISR",
+					r.replica, r.partition, lastSeenElapsed, lastCaughtUpElapsed)
+			} else {
+				r.partition.srv.logger.Errorf("Replica %s for partition %s exceeded max lag offsets "+
+					"(offset lag: %d), removing from ISR",
+					r.replica, r.partition, offsetLag)
+			}
 
 			r.shrinkISR()
-		} else if !outOfSync && !r.partition.inISR(r.replica) {
-			// Add replica back into ISR.
+		} else if !outOfSync && !syncFollower && !r.partition.inISR(r.replica) {
+			// Add replica back into ISR. Replicas fetching from a sync
+			// source stay out of it, even when they fall back to fetching
+			// from the leader, so the ISR doesn't flap.
 			r.partition.srv.logger.Infof("Replica %s for partition %s caught back up with leader, "+
 				"rejoining ISR", r.replica, r.partition)
 			r.expandISR()
 		}
+		// Only count periodic checks so that each failure spans maxLagTime.
+		if periodic {
+			r.trackCatchUp(outOfSync, stop)
+		}
 	}
 }
 
+// trackCatchUp counts the consecutive maxLagTime periods the replica has been
+// out of sync and replaces it with a replica on another server once it has
+// failed to catch up ReplicaMaxCatchUpFailures times.
+func (r *replicator) trackCatchUp(outOfSync bool, stop <-chan struct{}) {
+	maxFailures := r.partition.srv.config.Clustering.ReplicaMaxCatchUpFailures
+	if maxFailures <= 0 {
+		return
+	}
+	if !outOfSync {
+		r.catchUpFailures = 0
+		return
+	}
+	r.catchUpFailures++
+	if r.catchUpFailures < maxFailures {
+		return
+	}
+	failures := r.catchUpFailures
+	r.catchUpFailures = 0
+	if !atomic.CompareAndSwapInt32(&r.replacing, 0, 1) {
+		return
+	}
+	r.partition.srv.logger.Errorf("Replica %s for partition %s failed to catch up %d times, replacing it",
+		r.replica, r.partition, failures)
+	r.partition.srv.startGoroutine(func() {
+		defer atomic.StoreInt32(&r.replacing, 0)
+		// Stop replacing the replica if this server stops leading.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		r.partition.srv.replaceReplica(ctx, r.partition, r.replica, failures)
+	})
+}
+
+// setObserver sets whether the replica is an observer, which never joins the
+// ISR, e.g. when it's demoted to or promoted from an observer.
+func (r *replicator) setObserver(observer bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observer = observer
+}
+
+// setRemoved sets whether the replica was removed from the partition's replica
+// set, e.g. when it's moved to another server, or added back.
+func (r *replicator) setRemoved(removed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removed = removed
+}
+
 // shrinkISR sends a ShrinkISR request to the controller to remove the replica
 // from the ISR.
 func (r *replicator) shrinkISR() {
@@ -220,9 +394,13 @@ func (r *replicator) expandISR() {
 }
 
 // replicate sends a batch of messages to the given NATS inbox along with the
-// leader epoch and HW.
-func (r *replicator) replicate(
-	ctx context.Context, reader *commitlog.Reader, request *nats.Msg, offset int64) error {
+// leader epoch and HW. If the replica is catching up, i.e. it's not in the
+// ISR, this is subject to the server's catch-up throttle. It waits on the
+// throttle for at most half the replica fetch timeout so that the follower
+// gets a response before its fetch times out, returning errCatchUpThrottled
+// if the batch could not be sent in time.
+func (r *replicator) replicate(ctx context.Context, stop <-chan struct{},
+	reader *commitlog.Reader, request *nats.Msg, offset int64) error {
 
 	var (
 		newestOffset = r.partition.log.NewestOffset()
@@ -249,6 +427,20 @@ func (r *replicator) replicate(
 		}
 	}
 
+	// Throttle catch-up replication to followers outside of the ISR.
+	if size := r.writer.Len() - replicationOverhead; size > 0 && !r.partition.inISR(r.replica) {
+		maxWait := r.partition.replicaFetchTimeout() / 2
+		if !r.partition.srv.catchUpThrottle.wait(stop, size, maxWait) {
+			r.writer.Reset()
+			select {
+			case <-stop:
+				return nil
+			default:
+				return errCatchUpThrottled
+			}
+		}
+	}
+
 	// Flush the batch.
 	if err := r.writer.Flush(request.Respond); err != nil {
 		r.partition.srv.logger.Errorf("Failed to flush buffer while replicating: %v", err)
@@ -259,10 +451,16 @@ func (r *replicator) replicate(
 
 // caughtUp is called when the follower has caught up with the leader's log.
 // This will register a data waiter on the log so that the leader can notify
-// the follower when new data is available to replicate.
+// the follower when new data is available to replicate. If the HW sent to the
+// follower is behind its log end offset, this will also register an HW waiter
+// so that the follower can be notified when its messages are committed.
 func (r *replicator) caughtUp(stop <-chan struct{}, leo int64, req replicationRequest) {
+	hw := r.partition.log.HighWatermark()
 	r.mu.Lock()
 	r.lastCaughtUp = req.received
+	if hw < leo {
+		r.waitForHW(stop, hw, req.ReplicaID)
+	}
 	waiter := r.waiter
 	if waiter == nil {
 		// Register a waiter to be notified when new messages are written after
@@ -288,6 +486,37 @@ func (r *replicator) caughtUp(stop <-chan struct{}, leo int64, req replicationRe
 	}
 }
 
+// waitForHW registers a waiter on the log, if one isn't registered already, to
+// notify the idle follower once the HW advances past the given value. HW
+// advances are batched for ReplicaHWUpdateInterval so that the follower
+// fetches the latest HW in a single replication response rather than being
+// notified for each commit. This does nothing if ReplicaHWUpdateInterval is
+// zero. This must be called with the replicator lock held.
+func (r *replicator) waitForHW(stop <-chan struct{}, hw int64, replica string) {
+	interval := r.partition.srv.config.Clustering.ReplicaHWUpdateInterval
+	if interval <= 0 || r.hwWaiter != nil {
+		return
+	}
+	waiter := r.partition.log.NotifyHW(r, hw)
+	r.partition.srv.startGoroutine(func() {
+		select {
+		case <-waiter:
+		case <-stop:
+			return
+		}
+		select {
+		case <-time.After(interval):
+		case <-stop:
+			return
+		}
+		r.mu.Lock()
+		r.hwWaiter = nil
+		r.mu.Unlock()
+		r.partition.sendPartitionNotification(replica)
+	})
+	r.hwWaiter = waiter
+}
+
 // sendHW sends the leader epoch and HW to the given NATS inbox.
 func (r *replicator) sendHW(request *nats.Msg) error {
 	r.writer.Reset()
@@ -304,9 +533,10 @@ type replicationProtocolWriter interface {
 type protocolWriter struct {
 	*replicator
 	buf        *bytes.Buffer
+	out        []byte
+	codec      ReplicationCodec
 	log        commitlog.CommitLog
 	lastOffset int64
-	dataPos    int
 	stop       <-chan struct{}
 }
 
@@ -314,6 +544,7 @@ func newReplicationProtocolWriter(r *replicator, stop <-chan struct{}) replicati
 	w := &protocolWriter{
 		replicator: r,
 		buf:        new(bytes.Buffer),
+		codec:      r.partition.srv.replicationCodec(),
 		log:        r.partition.log,
 		stop:       stop,
 	}
@@ -333,9 +564,14 @@ func (w *protocolWriter) Write(offset int64, headers, message []byte) error {
 }
 
 func (w *protocolWriter) Flush(write func([]byte) error) error {
-	data := w.buf.Bytes()
-	// Replace the HW.
-	proto.Encoding.PutUint64(data[w.dataPos+8:], uint64(w.log.HighWatermark()))
+	// Frame the batch with the leader epoch and the HW at the time of flush.
+	data, err := w.codec.EncodeReplicationResponse(
+		w.out[:0], w.replicator.epoch, w.log.HighWatermark(), w.buf.Bytes())
+	if err != nil {
+		w.Reset()
+		return err
+	}
+	w.out = data
 
 	if err := write(data); err != nil {
 		w.Reset()
@@ -347,19 +583,10 @@ func (w *protocolWriter) Flush(write func([]byte) error) error {
 }
 
 func (w *protocolWriter) Len() int {
-	return w.buf.Len()
+	return w.buf.Len() + replicationOverhead
 }
 
 func (w *protocolWriter) Reset() {
 	w.buf.Reset()
 	w.lastOffset = -1
-
-	// Write envelope header.
-	w.dataPos = proto.WriteReplicationResponseHeader(w.buf)
-
-	// Write the leader epoch.
-	binary.Write(w.buf, proto.Encoding, w.replicator.epoch)
-	// Reserve space for the HW. This will be replaced with the HW at the time
-	// of flush.
-	binary.Write(w.buf, proto.Encoding, int64(0))
 }
@@ -2,15 +2,23 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	natsdTest "github.com/nats-io/nats-server/v2/test"
 	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcMetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	lift "github.com/liftbridge-io/go-liftbridge"
+	liftApi "github.com/liftbridge-io/liftbridge-api/go"
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
 	proto "github.com/liftbridge-io/liftbridge/server/protocol"
 )
 
@@ -280,6 +288,335 @@ func TestCommitOnISRShrink(t *testing.T) {
 	}
 }
 
+// Ensure a message published requiring a number of followers is not committed
+// or acked while fewer followers are in the ISR, even though the ISR has
+// replicated it.
+func TestCommitAckMinFollowers(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Clustering.ReplicaMaxLagTime = time.Second
+	s1Config.Clustering.ReplicaFetchTimeout = 100 * time.Millisecond
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Clustering.ReplicaMaxLagTime = time.Second
+	s2Config.Clustering.ReplicaFetchTimeout = 100 * time.Millisecond
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Configure third server.
+	s3Config := getTestConfig("c", false, 5052)
+	s3Config.Clustering.ReplicaMaxLagTime = time.Second
+	s3Config.Clustering.ReplicaFetchTimeout = 100 * time.Millisecond
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := []*Server{s1, s2, s3}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+
+	// Requiring more followers than the stream has fails.
+	ctx, cancel := context.WithTimeout(AckMinFollowers(context.Background(), 3), 5*time.Second)
+	_, err = client.Publish(ctx, name, []byte("hello"), lift.AckPolicyAll())
+	cancel()
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// With both followers in the ISR, the message is committed and acked.
+	ctx, cancel = context.WithTimeout(AckMinFollowers(context.Background(), 2), 5*time.Second)
+	_, err = client.Publish(ctx, name, []byte("hello"), lift.AckPolicyAll())
+	cancel()
+	require.NoError(t, err)
+
+	// Kill a stream follower and wait for the ISR to shrink.
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	var follower *Server
+	for i, server := range servers {
+		if server != leader {
+			follower = server
+			servers = append(servers[:i], servers[i+1:]...)
+			break
+		}
+	}
+	follower.Stop()
+	waitForISR(t, 10*time.Second, name, 0, 2, servers...)
+
+	// The leader and remaining follower have the message, but it requires
+	// two followers, so it's not committed or acked.
+	ctx, cancel = context.WithTimeout(AckMinFollowers(context.Background(), 2), 2*time.Second)
+	_, err = client.Publish(ctx, name, []byte("world"), lift.AckPolicyAll())
+	cancel()
+	require.Error(t, err)
+
+	partition := leader.metadata.GetPartition(name, 0)
+	require.Equal(t, int64(1), partition.log.NewestOffset())
+	require.Equal(t, int64(0), partition.log.HighWatermark())
+}
+
+// Ensure a minFollowers header set by the publisher rather than from
+// AckMinFollowersMetadataKey can't require more followers than the partition
+// has and hold back its commits.
+func TestCommitMinFollowersHeaderBounded(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), "foo", name))
+	waitForPartition(t, 5*time.Second, name, 0, s1)
+
+	// Set the header on a publish through the API, which removes it.
+	conn, err := grpc.Dial("localhost:5050", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err = liftApi.NewAPIClient(conn).Publish(ctx, &liftApi.PublishRequest{
+		Stream:    name,
+		Value:     []byte("api"),
+		Headers:   map[string][]byte{MinFollowersHeader: []byte("5")},
+		AckPolicy: liftApi.AckPolicy_ALL,
+	})
+	cancel()
+	require.NoError(t, err)
+
+	// Set the header on a message published directly to NATS, which the
+	// leader bounds to the partition's followers.
+	nc, err := nats.Connect(nats.DefaultURL)
+	require.NoError(t, err)
+	defer nc.Close()
+	data, err := proto.MarshalPublish(&liftApi.Message{
+		Value:   []byte("nats"),
+		Headers: map[string][]byte{MinFollowersHeader: []byte("5")},
+	})
+	require.NoError(t, err)
+	require.NoError(t, nc.Publish("foo", data))
+	require.NoError(t, nc.Flush())
+
+	// Later messages are still committed.
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	_, err = client.Publish(ctx, name, []byte("after"), lift.AckPolicyAll())
+	cancel()
+	require.NoError(t, err)
+	waitForHW(t, 5*time.Second, name, 0, 2, s1)
+
+	// The header set through the API was not stored.
+	reader, err := s1.metadata.GetPartition(name, 0).log.NewReader(0, false)
+	require.NoError(t, err)
+	defer reader.Close()
+	m, _, _, _, err := reader.ReadMessage(context.Background(), make([]byte, 28))
+	require.NoError(t, err)
+	require.Equal(t, []byte("api"), m.Value())
+	_, ok := m.Headers()[MinFollowersHeader]
+	require.False(t, ok)
+}
+
+// Ensure a stream's replica max lag time overrides the server's, so a
+// follower is removed from the ISR of a stream with a short lag time while it
+// remains in the ISR of a stream using the server's.
+func TestStreamReplicaMaxLagTime(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Configure third server.
+	s3Config := getTestConfig("c", false, 5052)
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := []*Server{s1, s2, s3}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// An invalid lag time fails.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	badCtx := grpcMetadata.AppendToOutgoingContext(ctx, ReplicaMaxLagTimeMetadataKey, "-1s")
+	err = client.CreateStream(badCtx, "fast", "fast", lift.ReplicationFactor(3))
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Create a stream with a short lag time and one using the server's.
+	fastCtx := grpcMetadata.AppendToOutgoingContext(ctx,
+		ReplicaMaxLagTimeMetadataKey, "1s",
+		ReplicaFetchTimeoutMetadataKey, "100ms")
+	err = client.CreateStream(fastCtx, "fast", "fast", lift.ReplicationFactor(3))
+	require.NoError(t, err)
+	err = client.CreateStream(ctx, "slow", "slow", lift.ReplicationFactor(3))
+	require.NoError(t, err)
+
+	fast := s1.metadata.GetPartition("fast", 0)
+	require.NotNil(t, fast)
+	require.Equal(t, time.Second, fast.replicaMaxLagTime())
+	require.Equal(t, 100*time.Millisecond, fast.replicaFetchTimeout())
+	slow := s1.metadata.GetPartition("slow", 0)
+	require.NotNil(t, slow)
+	require.Equal(t, s1Config.Clustering.ReplicaMaxLagTime, slow.replicaMaxLagTime())
+
+	// Kill a server which is a follower of both streams.
+	fastLeader := getPartitionLeader(t, 10*time.Second, "fast", 0, servers...)
+	slowLeader := getPartitionLeader(t, 10*time.Second, "slow", 0, servers...)
+	var follower *Server
+	for i, server := range servers {
+		if server != fastLeader && server != slowLeader {
+			follower = server
+			servers = append(servers[:i], servers[i+1:]...)
+			break
+		}
+	}
+	follower.Stop()
+
+	// The follower should drop out of the fast stream's ISR well before the
+	// server's lag time while staying in the slow stream's ISR.
+	waitForISR(t, 10*time.Second, "fast", 0, 2, servers...)
+	for _, server := range servers {
+		require.Equal(t, 3, server.metadata.GetPartition("slow", 0).ISRSize())
+	}
+}
+
+// Ensure a follower which keeps sending replication requests but falls more
+// than the stream's max lag offsets behind the leader is removed from the ISR
+// even though it's within the max lag time.
+func TestStreamReplicaMaxLagOffsets(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Clustering.ReplicaMaxLagTime = time.Minute
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Clustering.ReplicaMaxLagTime = time.Minute
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Configure third server.
+	s3Config := getTestConfig("c", false, 5052)
+	s3Config.Clustering.ReplicaMaxLagTime = time.Minute
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := []*Server{s1, s2, s3}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// An invalid lag fails.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	badCtx := grpcMetadata.AppendToOutgoingContext(ctx, ReplicaMaxLagOffsetsMetadataKey, "0")
+	err = client.CreateStream(badCtx, "foo", "foo", lift.ReplicationFactor(2))
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	lagCtx := grpcMetadata.AppendToOutgoingContext(ctx, ReplicaMaxLagOffsetsMetadataKey, "10")
+	err = client.CreateStream(lagCtx, "foo", "foo", lift.ReplicationFactor(2))
+	require.NoError(t, err)
+	waitForISR(t, 10*time.Second, "foo", 0, 2, servers...)
+
+	leader := getPartitionLeader(t, 10*time.Second, "foo", 0, servers...)
+	var follower *Server
+	for _, s := range servers {
+		if s != leader && s.metadata.GetPartition("foo", 0).isReplica(s.config.Clustering.ServerID) {
+			follower = s
+			break
+		}
+	}
+	require.NotNil(t, follower)
+	leaderPartition := leader.metadata.GetPartition("foo", 0)
+	require.Equal(t, int64(10), leaderPartition.replicaMaxLagOffsets())
+
+	// Stop the follower from fetching and send replication requests on its
+	// behalf which never advance its offset.
+	followerPartition := follower.metadata.GetPartition("foo", 0)
+	followerPartition.PauseReplication()
+	nc, err := nats.Connect(nats.DefaultURL)
+	require.NoError(t, err)
+	defer nc.Close()
+	_, epoch := leaderPartition.GetLeader()
+	data, err := proto.MarshalReplicationRequest(&proto.ReplicationRequest{
+		ReplicaID:   follower.config.Clustering.ServerID,
+		Offset:      followerPartition.log.NewestOffset(),
+		LeaderEpoch: epoch,
+	})
+	require.NoError(t, err)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			nc.Request(leaderPartition.getReplicationRequestInbox(), data, 100*time.Millisecond)
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	// Publish more messages than the follower is allowed to lag.
+	for i := 0; i < 50; i++ {
+		require.NoError(t, nc.Publish("foo", []byte(strconv.Itoa(i))))
+	}
+	require.NoError(t, nc.Flush())
+
+	// The follower should drop out of the ISR well before the max lag time.
+	waitForISR(t, 10*time.Second, "foo", 0, 1, servers...)
+}
+
 // Ensure an ack is received even if there is a server not responding in the
 // ISR if AckPolicy_LEADER is set.
 func TestAckPolicyLeader(t *testing.T) {
@@ -577,7 +914,7 @@ func TestTruncateFastLeaderElection(t *testing.T) {
 	// Stop replication on the leader to force a leader election.
 	partition := leader.metadata.GetPartition(name, 0)
 	require.NotNil(t, partition)
-	partition.pauseReplication()
+	partition.PauseReplication()
 
 	// Wait for stream leader to be elected.
 	leader = getPartitionLeader(t, 10*time.Second, name, 0, follower1, follower2)
@@ -708,24 +1045,47 @@ func TestTruncatePreventReplicaDivergence(t *testing.T) {
 		follower2Config = s2Config
 	}
 
-	// Stop replication on the leader to force a leader election.
+	// Stop replication on the leader so the followers can't catch up again.
 	partition := leader.metadata.GetPartition(name, 0)
 	require.NotNil(t, partition)
-	partition.pauseReplication()
+	partition.PauseReplication()
 
 	// Restart the first follower (this will truncate uncommitted messages).
+	// Use a long leader timeout so that neither follower reports the paused
+	// leader on its own. Otherwise the election could run while the other
+	// follower is restarting and unable to report how caught up it is.
 	follower1.Stop()
 	follower1Config.Clustering.ReplicaMaxLagTime = 2 * time.Second
+	follower1Config.Clustering.ReplicaMaxLeaderTimeout = time.Minute
 	follower1 = runServerWithConfig(t, follower1Config)
 	defer follower1.Stop()
 
 	// Restart the second follower (this will truncate uncommitted messages).
 	follower2.Stop()
 	follower2Config.Clustering.ReplicaMaxLagTime = 2 * time.Second
+	follower2Config.Clustering.ReplicaMaxLeaderTimeout = time.Minute
 	follower2 = runServerWithConfig(t, follower2Config)
 	defer follower2.Stop()
 
-	// Wait for stream leader to be elected.
+	// Wait for both followers to have truncated to the HW, leaving them
+	// equally caught up.
+	for _, follower := range []*Server{follower1, follower2} {
+		waitForPartition(t, 10*time.Second, name, 0, follower)
+		followerPartition := follower.metadata.GetPartition(name, 0)
+		deadline := time.Now().Add(10 * time.Second)
+		for followerPartition.log.NewestOffset() != 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("Follower did not truncate uncommitted messages")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Force a leader election, which must elect one of the followers since
+	// the failed leader is not a candidate.
+	metadataLeader := getMetadataLeader(t, 10*time.Second, leader, follower1, follower2)
+	require.Nil(t, metadataLeader.metadata.electNewPartitionLeader(
+		metadataLeader.metadata.GetPartition(name, 0)))
 	getPartitionLeader(t, 10*time.Second, name, 0, follower1, follower2)
 
 	// Stop the old leader.
@@ -784,6 +1144,101 @@ func TestTruncatePreventReplicaDivergence(t *testing.T) {
 	}
 }
 
+// Ensure a follower which is ahead of the leader, e.g. with uncommitted
+// messages left over from a failover, truncates its log to the leader's when
+// it rejoins rather than diverging, and its extra offsets don't count towards
+// commits.
+func TestTruncateFollowerAheadOfLeader(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Clustering.ReplicaMaxIdleWait = 100 * time.Millisecond
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Clustering.ReplicaMaxIdleWait = 100 * time.Millisecond
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	servers := []*Server{s1, s2}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name,
+		lift.ReplicationFactor(2))
+	require.NoError(t, err)
+
+	// Publish two messages.
+	for _, value := range []string{"hello", "world"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte(value), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+	waitForHW(t, 5*time.Second, name, 0, 1, servers...)
+
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	follower := s1
+	if leader == s1 {
+		follower = s2
+	}
+	leaderPartition := leader.metadata.GetPartition(name, 0)
+	followerPartition := follower.metadata.GetPartition(name, 0)
+	_, epoch := followerPartition.GetLeader()
+
+	// Stop the follower from replicating and write messages the leader
+	// doesn't have to its log.
+	followerPartition.PauseReplication()
+	_, err = followerPartition.log.Append([]*commitlog.Message{
+		{Value: []byte("stale"), Timestamp: time.Now().UnixNano(), LeaderEpoch: epoch},
+		{Value: []byte("stale"), Timestamp: time.Now().UnixNano(), LeaderEpoch: epoch},
+		{Value: []byte("stale"), Timestamp: time.Now().UnixNano(), LeaderEpoch: epoch},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), followerPartition.log.NewestOffset())
+
+	// Resume replication. The follower truncates to the leader's log.
+	followerPartition.ResumeReplication()
+	deadline := time.Now().Add(5 * time.Second)
+	for followerPartition.log.NewestOffset() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Follower did not truncate, newest offset: %d",
+				followerPartition.log.NewestOffset())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, int64(1), leaderPartition.log.HighWatermark())
+
+	// New messages are replicated from the leader's lineage.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ack, err := client.Publish(ctx, name, []byte("again"), lift.AckPolicyAll())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), ack.Offset())
+	waitForHW(t, 5*time.Second, name, 0, 2, servers...)
+
+	reader, err := followerPartition.log.NewReader(2, true)
+	require.NoError(t, err)
+	defer reader.Close()
+	msg, offset, _, _, err := reader.ReadMessage(ctx, make([]byte, 28))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), offset)
+	require.Equal(t, []byte("again"), msg.Value())
+}
+
 // Ensure when a follower has caught up with the leader's log, the leader
 // notifies the follower when new messages are written to the partition.
 func TestReplicatorNotifyNewData(t *testing.T) {
@@ -829,14 +1284,36 @@ func TestReplicatorNotifyNewData(t *testing.T) {
 		follower = s1
 	}
 
+	// Wait for the leader to handle a replication request from the follower.
 	// At this point, the follower is caught up with the leader since there
-	// aren't any messages. Set up a NATS subscription to intercept
-	// notifications.
-	var (
-		notifications = make(chan *proto.PartitionNotification)
-		inbox         = follower.getPartitionNotificationInbox(
-			follower.config.Clustering.ServerID)
-	)
+	// aren't any messages, so the leader is waiting to notify it of new data.
+	partition := leader.metadata.GetPartition(name, 0)
+	require.NotNil(t, partition)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		partition.mu.RLock()
+		replicator := partition.replicators[follower.config.Clustering.ServerID]
+		partition.mu.RUnlock()
+		if replicator != nil {
+			replicator.mu.RLock()
+			waiting := replicator.waiter != nil
+			replicator.mu.RUnlock()
+			if waiting {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Follower did not catch up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Set up a NATS subscription to intercept notifications.
+	var (
+		notifications = make(chan *proto.PartitionNotification)
+		inbox         = follower.getPartitionNotificationInbox(
+			follower.config.Clustering.ServerID)
+	)
 	_, err = nc.Subscribe(inbox, func(msg *nats.Msg) {
 		req, err := proto.UnmarshalPartitionNotification(msg.Data)
 		if err != nil {
@@ -860,6 +1337,91 @@ func TestReplicatorNotifyNewData(t *testing.T) {
 	}
 }
 
+// Ensure idle followers reach the leader's final HW after a publish burst
+// without waiting for their next replication request and that the leader
+// batches the HW advances rather than notifying them for each commit.
+func TestReplicatorBatchHWUpdates(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Create NATS connection.
+	nc, err := nats.GetDefaultOptions().Connect()
+	require.NoError(t, err)
+	defer nc.Close()
+
+	// Configure servers so that caught-up followers idle longer than the
+	// test, so they only learn the HW when the leader notifies them.
+	configure := func(config *Config) {
+		config.Clustering.ReplicaMaxIdleWait = time.Minute
+		config.Clustering.ReplicaMaxLagTime = 2 * time.Minute
+		config.Clustering.ReplicaHWUpdateInterval = 20 * time.Millisecond
+	}
+	s1Config := getTestConfig("a", true, 5050)
+	configure(s1Config)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	s2Config := getTestConfig("b", false, 5051)
+	configure(s2Config)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	s3Config := getTestConfig("c", false, 5052)
+	configure(s3Config)
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := []*Server{s1, s2, s3}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = client.CreateStream(ctx, subject, name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+
+	// Count the notifications sent to the followers.
+	var (
+		leader        = getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+		notifications int32
+	)
+	for _, s := range servers {
+		if s == leader {
+			continue
+		}
+		inbox := s.getPartitionNotificationInbox(s.config.Clustering.ServerID)
+		_, err = nc.Subscribe(inbox, func(*nats.Msg) {
+			atomic.AddInt32(&notifications, 1)
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, nc.Flush())
+
+	// Publish a burst of messages directly to the stream subject.
+	num := 1000
+	for i := 0; i < num; i++ {
+		require.NoError(t, nc.Publish(subject, []byte(strconv.Itoa(i))))
+	}
+	require.NoError(t, nc.Flush())
+
+	// Followers reach the final HW without idling for their next replication
+	// request.
+	waitForHW(t, 5*time.Second, name, 0, int64(num-1), servers...)
+
+	// Notifications are batched rather than sent for each message.
+	require.Less(t, int(atomic.LoadInt32(&notifications)), num/10)
+}
+
 // Ensure when a follower dies, it is removed from the ISR. When it restarts
 // and catches up, it is added back into the ISR.
 func TestShrinkExpandISR(t *testing.T) {
@@ -939,3 +1501,526 @@ func TestShrinkExpandISR(t *testing.T) {
 	// Wait for ISR to expand to 3.
 	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
 }
+
+// Ensure pausing replication on a follower stops it from fetching, dropping it
+// from the ISR, and resuming replication lets it catch back up and rejoin.
+func TestPauseResumeReplication(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	servers := make([]*Server, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxLagTime = time.Second
+		config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+		servers[i] = runServerWithConfig(t, config)
+		defer servers[i].Stop()
+	}
+
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name,
+		lift.ReplicationFactor(3))
+	require.NoError(t, err)
+
+	leader := getPartitionLeader(t, 5*time.Second, name, 0, servers...)
+	var (
+		follower *Server
+		others   []*Server
+	)
+	for _, s := range servers {
+		if s != leader && follower == nil {
+			follower = s
+		} else {
+			others = append(others, s)
+		}
+	}
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+
+	// Pausing replication on an unknown stream or partition fails.
+	require.Equal(t, ErrStreamNotFound, follower.PauseReplication("bar", nil))
+	require.Equal(t, ErrPartitionNotFound, follower.PauseReplication(name, []int32{1}))
+
+	// Pause replication on the follower and wait for it to leave the ISR.
+	require.NoError(t, follower.PauseReplication(name, nil))
+	partition := follower.metadata.GetPartition(name, 0)
+	require.True(t, partition.IsReplicationPaused())
+	waitForISR(t, 10*time.Second, name, 0, 2, others...)
+
+	// Publish some messages which the follower does not fetch.
+	num := 5
+	for i := 0; i < num; i++ {
+		_, err = client.Publish(context.Background(), name, []byte("hello"),
+			lift.AckPolicyAll())
+		require.NoError(t, err)
+	}
+	waitForHW(t, 5*time.Second, name, 0, int64(num-1), others...)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int64(-1), partition.log.NewestOffset())
+
+	// Resume replication and wait for the follower to catch up and rejoin the
+	// ISR.
+	require.NoError(t, follower.ResumeReplication(name, nil))
+	require.False(t, partition.IsReplicationPaused())
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+	waitForHW(t, 5*time.Second, name, 0, int64(num-1), servers...)
+	require.Equal(t, int64(num-1), partition.log.NewestOffset())
+}
+
+// Ensure IDs generated by the MessageIDFunc are stored on the leader,
+// replicated identically to followers, and delivered to subscribers.
+func TestMessageIDFuncReplicated(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	genID := func(stream string, partition int32, offset, timestamp int64) string {
+		return fmt.Sprintf("%s-%d-%d", stream, partition, offset)
+	}
+
+	servers := make([]*Server, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxIdleWait = 500 * time.Millisecond
+		config.Streams.MessageIDFunc = genID
+		servers[i] = runServerWithConfig(t, config)
+		defer servers[i].Stop()
+	}
+
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	subject := "foo"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = client.CreateStream(ctx, subject, name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+
+	// Publish messages.
+	num := 10
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	waitForHW(t, 5*time.Second, name, 0, int64(num-1), servers...)
+
+	// Each replica stores the same ID for each offset.
+	for _, s := range servers {
+		partition := s.metadata.GetPartition(name, 0)
+		reader, err := partition.log.NewReader(0, false)
+		require.NoError(t, err)
+		headers := make([]byte, 28)
+		for i := 0; i < num; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			msg, offset, _, _, err := reader.ReadMessage(ctx, headers)
+			cancel()
+			require.NoError(t, err)
+			require.Equal(t, int64(i), offset)
+			require.Equal(t, genID(name, 0, offset, 0), string(msg.Headers()[MessageIDHeader]))
+		}
+	}
+
+	// Subscribers receive the ID.
+	recv := make(chan lift.Message, num)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		recv <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	for i := 0; i < num; i++ {
+		select {
+		case msg := <-recv:
+			require.Equal(t, genID(name, 0, msg.Offset(), 0), string(msg.Headers()[MessageIDHeader]))
+		case <-time.After(5 * time.Second):
+			t.Fatal("Did not receive all expected messages")
+		}
+	}
+}
+
+// Ensure trimming a stream removes messages before the offset on every
+// replica while newer messages are retained and replication continues.
+func TestTrimStream(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	servers := make([]*Server, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxIdleWait = 500 * time.Millisecond
+		servers[i] = runServerWithConfig(t, config)
+		defer servers[i].Stop()
+	}
+
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	subject := "foo"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = client.CreateStream(ctx, subject, name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+
+	// Publish messages.
+	num := 100
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	waitForHW(t, 5*time.Second, name, 0, int64(num-1), servers...)
+
+	err = servers[1].TrimStream(context.Background(), "bar", 50)
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// Uncommitted offsets cannot be trimmed.
+	err = servers[1].TrimStream(context.Background(), name, int64(num+1))
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+
+	err = servers[1].TrimStream(context.Background(), name, 50)
+	require.NoError(t, err)
+
+	// The trim is applied by every replica.
+	for _, s := range servers {
+		partition := s.metadata.GetPartition(name, 0)
+		require.NotNil(t, partition)
+		waitForTrim := time.Now().Add(5 * time.Second)
+		for partition.log.OldestOffset() != 50 && time.Now().Before(waitForTrim) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.Equal(t, int64(50), partition.log.OldestOffset())
+		require.Equal(t, int64(num-1), partition.log.NewestOffset())
+	}
+
+	// Newer messages are still replicated.
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	_, err = client.Publish(ctx, name, []byte(strconv.Itoa(num)), lift.AckPolicyAll())
+	cancel()
+	require.NoError(t, err)
+	waitForHW(t, 5*time.Second, name, 0, int64(num), servers...)
+
+	// Subscribers start at the new oldest offset.
+	recv := make(chan lift.Message, 1)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		select {
+		case recv <- msg:
+		default:
+		}
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	select {
+	case msg := <-recv:
+		require.Equal(t, int64(50), msg.Offset())
+		require.Equal(t, []byte("50"), msg.Value())
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not receive expected message")
+	}
+}
+
+// Ensure trimming a stream is checked against the partition leader's high
+// watermark when the metadata leader is not a replica of the partition.
+func TestTrimStreamMetadataLeaderNotReplica(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	servers := make([]*Server, 2)
+	for i, id := range []string{"a", "b"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		servers[i] = runServerWithConfig(t, config)
+		defer servers[i].Stop()
+	}
+
+	metadataLeader := getMetadataLeader(t, 10*time.Second, servers...)
+	replica := servers[0]
+	if replica == metadataLeader {
+		replica = servers[1]
+	}
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Pin the stream to the server which isn't the metadata leader.
+	name := "foo"
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		ReplicaServersMetadataKey, replica.config.Clustering.ServerID)
+	err = client.CreateStream(ctx, "foo", name)
+	require.NoError(t, err)
+	_, ok := metadataLeader.metadata.GetPartition(name, 0).ReplicaHighWatermark()
+	require.False(t, ok)
+
+	// Publish messages.
+	num := 10
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+	waitForHW(t, 5*time.Second, name, 0, int64(num-1), replica)
+
+	// Uncommitted offsets cannot be trimmed.
+	err = metadataLeader.TrimStream(context.Background(), name, int64(num+1))
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+
+	err = metadataLeader.TrimStream(context.Background(), name, 5)
+	require.NoError(t, err)
+
+	partition := replica.metadata.GetPartition(name, 0)
+	waitForTrim := time.Now().Add(5 * time.Second)
+	for partition.log.OldestOffset() != 5 && time.Now().Before(waitForTrim) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, int64(5), partition.log.OldestOffset())
+	require.Equal(t, int64(num-1), partition.log.HighWatermark())
+}
+
+// Ensure a trim applied while a partition is paused takes effect when the
+// partition is resumed.
+func TestTrimPausedPartition(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	// Publish messages.
+	num := 10
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	err = client.PauseStream(context.Background(), name)
+	require.NoError(t, err)
+	checkPartitionPaused(t, name, 0, true, s1)
+
+	// Trim the closed partition.
+	partition := s1.metadata.GetPartition(name, 0)
+	require.NotNil(t, partition)
+	require.NoError(t, partition.TrimBefore(5))
+
+	// Publishing resumes the partition, which applies the trim.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err = client.Publish(ctx, name, []byte(strconv.Itoa(num)), lift.AckPolicyAll())
+	cancel()
+	require.NoError(t, err)
+	checkPartitionPaused(t, name, 0, false, s1)
+
+	partition = s1.metadata.GetPartition(name, 0)
+	require.NotNil(t, partition)
+	require.Equal(t, int64(5), partition.log.OldestOffset())
+	require.Equal(t, int64(num), partition.log.NewestOffset())
+}
+
+// Ensure an observer replicates a partition without joining the ISR, so
+// publishes don't wait on it, and is never elected leader.
+func TestObserverReplica(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers.
+	servers := make([]*Server, 4)
+	for i, id := range []string{"a", "b", "c", "d"} {
+		servers[i] = runServerWithConfig(t, getTestConfig(id, i == 0, 5050+i))
+		defer servers[i].Stop()
+	}
+	observer := servers[3]
+
+	metadataLeader := getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create an RF=3 stream with server d as an observer.
+	name := "foo"
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), ObserverServersMetadataKey, "d")
+	err = client.CreateStream(ctx, "foo", name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	require.NotEqual(t, observer, leader)
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+
+	partition := metadataLeader.metadata.GetPartition(name, 0)
+	require.Equal(t, []string{"d"}, partition.GetObservers())
+	require.NotContains(t, partition.GetReplicas(), "d")
+	require.NotContains(t, partition.GetISR(), "d")
+
+	publish := func(value string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := client.Publish(ctx, name, []byte(value), lift.AckPolicyAll())
+		require.NoError(t, err)
+	}
+
+	// The observer replicates published messages.
+	publish("hello")
+	observerPartition := observer.metadata.GetPartition(name, 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for observerPartition.log.NewestOffset() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Observer did not replicate message")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Publishes don't wait on the observer.
+	require.NoError(t, observer.PauseReplication(name, nil))
+	publish("world")
+	require.Equal(t, int64(0), observerPartition.log.NewestOffset())
+	require.NoError(t, observer.ResumeReplication(name, nil))
+
+	// The observer is never elected leader.
+	for i := 0; i < 3; i++ {
+		waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+		_, epoch := partition.GetLeader()
+		require.Nil(t, metadataLeader.metadata.electNewPartitionLeader(partition))
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			leader, newEpoch := partition.GetLeader()
+			if newEpoch > epoch {
+				require.NotEqual(t, "d", leader)
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Partition leader was not changed")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.NotContains(t, partition.GetISR(), "d")
+	}
+}
+
+// Ensure a replica demoted to an observer no longer holds up commits and can
+// be promoted back to a replica which rejoins the ISR.
+func TestDemoteAndPromoteReplica(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers.
+	servers := make([]*Server, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxLagTime = 3 * time.Second
+		servers[i] = runServerWithConfig(t, config)
+		defer servers[i].Stop()
+	}
+
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+
+	var follower *Server
+	for _, s := range servers {
+		if s != leader {
+			follower = s
+			break
+		}
+	}
+	followerID := follower.config.Clustering.ServerID
+
+	publish := func(value string, timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		_, err := client.Publish(ctx, name, []byte(value), lift.AckPolicyAll())
+		return err
+	}
+
+	require.NoError(t, publish("hello", 5*time.Second))
+
+	// The leader cannot be demoted.
+	err = servers[0].DemoteReplica(context.Background(), name, 0, leader.config.Clustering.ServerID)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// While the follower is stalled, commits wait on it.
+	require.NoError(t, follower.PauseReplication(name, nil))
+	require.Error(t, publish("stalled", 500*time.Millisecond))
+
+	// Once demoted, commits no longer wait on it.
+	require.NoError(t, servers[0].DemoteReplica(context.Background(), name, 0, followerID))
+	waitForISR(t, 10*time.Second, name, 0, 2, servers...)
+	require.NoError(t, publish("demoted", 2*time.Second))
+	partition := leader.metadata.GetPartition(name, 0)
+	require.Equal(t, []string{followerID}, partition.GetObservers())
+	require.NotContains(t, partition.GetReplicas(), followerID)
+
+	// Only observers can be promoted.
+	err = servers[0].PromoteObserver(context.Background(), name, 0, leader.config.Clustering.ServerID)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// Once promoted, it rejoins the ISR after catching up.
+	require.NoError(t, follower.ResumeReplication(name, nil))
+	require.NoError(t, servers[0].PromoteObserver(context.Background(), name, 0, followerID))
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+	require.Empty(t, partition.GetObservers())
+	require.Contains(t, partition.GetReplicas(), followerID)
+	require.NoError(t, publish("promoted", 5*time.Second))
+	require.Equal(t, int64(3), follower.metadata.GetPartition(name, 0).log.NewestOffset())
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
+)
+
+// reprocessBatchSize is the max number of transformed messages
+// ReprocessStream appends to the partition at once.
+const reprocessBatchSize = 100
+
+// ErrPublishedDuringReprocess is returned by ReprocessStream when trimming was
+// requested but messages were published to the partition after the messages
+// being reprocessed and before their transformed copies. Trimming would remove
+// those messages, so nothing is trimmed.
+var ErrPublishedDuringReprocess = errors.New("messages were published during reprocessing")
+
+// ReprocessStream rewrites the committed messages of the given stream
+// partition with the transform registered under the given name in
+// Transforms, e.g. to backfill a header or reformat values. The messages
+// committed when it's called are read in order from the oldest, transformed,
+// and appended to the partition as new messages, keeping their original
+// timestamps, while messages published meanwhile are left as is. It returns
+// the offsets of the first and last messages appended, which are -1 if the
+// partition has no committed messages. If trim is true, the old messages are
+// removed once the transformed ones are committed. Since trimming applies to
+// every partition of a stream, this is only allowed for streams with a single
+// partition. Trimming also requires that no messages are published to the
+// partition before the first transformed message is appended, since they
+// would be trimmed along with the old messages, otherwise
+// ErrPublishedDuringReprocess is returned. If that's detected before anything
+// is appended, nothing is appended. Otherwise the messages are still rewritten
+// but not trimmed. This must be called on the partition leader, otherwise
+// ErrNotPartitionLeader is returned. If it fails midway, the messages
+// appended so far are kept and nothing is trimmed.
+func (s *Server) ReprocessStream(ctx context.Context, stream string, partitionID int32,
+	transform string, trim bool) (int64, int64, error) {
+
+	fn, ok := s.config.Streams.Transforms[transform]
+	if !ok {
+		return -1, -1, fmt.Errorf("no transform named %q", transform)
+	}
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return -1, -1, err
+	}
+	partition := partitions[0]
+	if !partition.IsLeader() {
+		return -1, -1, ErrNotPartitionLeader
+	}
+	if trim {
+		if all, err := s.getStreamPartitions(stream, nil); err != nil {
+			return -1, -1, err
+		} else if len(all) > 1 {
+			return -1, -1, fmt.Errorf("cannot trim stream %s with multiple partitions", stream)
+		}
+	}
+
+	var (
+		oldest = partition.log.OldestOffset()
+		end    = partition.log.HighWatermark()
+	)
+	if end < 0 || end < oldest {
+		return -1, -1, nil
+	}
+	reader, err := partition.log.NewReader(oldest, false)
+	if err != nil {
+		return -1, -1, err
+	}
+	defer reader.Close()
+
+	var (
+		first, last = int64(-1), int64(-1)
+		batch       = make([]*commitlog.Message, 0, reprocessBatchSize)
+		headersBuf  = make([]byte, 28)
+		interleaved = false
+	)
+	for offset := int64(-1); offset < end; {
+		m, msgOffset, timestamp, _, err := reader.ReadMessage(ctx, headersBuf)
+		if err != nil {
+			return first, last, err
+		}
+		offset = msgOffset
+		msg := &commitlog.Message{
+			MagicByte: m.MagicByte(),
+			Key:       append([]byte(nil), m.Key()...),
+			Value:     append([]byte(nil), m.Value()...),
+			Headers:   m.Headers(),
+			Timestamp: timestamp,
+		}
+		if err := fn(msg); err != nil {
+			return first, last, errors.Wrapf(err, "failed to transform message %d", offset)
+		}
+		batch = append(batch, msg)
+		if len(batch) < reprocessBatchSize && offset < end {
+			continue
+		}
+		if err := validateImported(partition, batch); err != nil {
+			return first, last, err
+		}
+		// Messages published after the ones being reprocessed would land
+		// before the transformed ones and be lost when trimming.
+		if trim && first == -1 && partition.log.NewestOffset() > end {
+			return first, last, ErrPublishedDuringReprocess
+		}
+		offsets, err := partition.Import(ctx, batch)
+		if err != nil {
+			return first, last, err
+		}
+		// The stream's empty value policy may drop some of the messages.
+		if len(offsets) > 0 {
+			if first == -1 {
+				first = offsets[0]
+				// A publish may have raced with the check above.
+				interleaved = first != end+1
+			}
+			last = offsets[len(offsets)-1]
+		}
+		batch = make([]*commitlog.Message, 0, reprocessBatchSize)
+	}
+
+	if !trim || first == -1 {
+		return first, last, nil
+	}
+	if interleaved {
+		return first, last, ErrPublishedDuringReprocess
+	}
+	// Only remove the old messages once the transformed ones are committed.
+	for hw := partition.log.HighWatermark(); hw < last; hw = partition.log.HighWatermark() {
+		select {
+		case <-partition.log.NotifyHW(reader, hw):
+		case <-ctx.Done():
+			return first, last, ctx.Err()
+		case <-s.shutdownCh:
+			return first, last, errors.New("server is shutting down")
+		}
+	}
+	return first, last, s.TrimStream(ctx, stream, first)
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
+)
+
+// Ensure ReprocessStream appends transformed copies of the committed messages
+// and trims the old ones when requested.
+func TestReprocessStream(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server with a transform uppercasing values.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.Transforms = map[string]MessageTransform{
+		"upper": func(msg *commitlog.Message) error {
+			msg.Value = bytes.ToUpper(msg.Value)
+			msg.Headers["reprocessed"] = []byte("true")
+			return nil
+		},
+	}
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name)
+	require.NoError(t, err)
+	err = client.CreateStream(context.Background(), "bar", "bar", lift.Partitions(2))
+	require.NoError(t, err)
+	waitForPartition(t, 5*time.Second, name, 0, s1)
+	waitForPartition(t, 5*time.Second, "bar", 1, s1)
+
+	// Unknown transforms are rejected.
+	_, _, err = s1.ReprocessStream(context.Background(), name, 0, "lower", false)
+	require.Error(t, err)
+
+	// Streams with multiple partitions can't be trimmed.
+	_, _, err = s1.ReprocessStream(context.Background(), "bar", 0, "upper", true)
+	require.Error(t, err)
+
+	// Reprocessing a partition without committed messages appends nothing.
+	first, last, err := s1.ReprocessStream(context.Background(), name, 0, "upper", true)
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), first)
+	require.Equal(t, int64(-1), last)
+
+	values := []string{"a", "b", "c"}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, value := range values {
+		_, err = client.Publish(ctx, name, []byte(value), lift.AckPolicyAll())
+		require.NoError(t, err)
+	}
+
+	first, last, err = s1.ReprocessStream(ctx, name, 0, "upper", true)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), first)
+	require.Equal(t, int64(5), last)
+
+	// The old messages were trimmed.
+	partition := s1.metadata.GetPartition(name, 0)
+	require.Equal(t, int64(3), partition.log.OldestOffset())
+	require.Equal(t, int64(5), partition.log.HighWatermark())
+
+	// The new tail contains the transformed messages.
+	recv := make(chan lift.Message, len(values))
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		if err != nil {
+			return
+		}
+		recv <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	for i, value := range values {
+		select {
+		case msg := <-recv:
+			require.Equal(t, int64(i+3), msg.Offset())
+			require.Equal(t, []byte(value), bytes.ToLower(msg.Value()))
+			require.Equal(t, bytes.ToUpper([]byte(value)), msg.Value())
+			require.Equal(t, []byte("true"), msg.Headers()["reprocessed"])
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Did not receive message %d", i)
+		}
+	}
+}
+
+// Ensure ReprocessStream doesn't trim messages published while it runs.
+func TestReprocessStreamConcurrentPublish(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server with a transform which publishes a message the first
+	// time it's called and one which leaves messages as is.
+	var (
+		client    lift.Client
+		published int32
+	)
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.Transforms = map[string]MessageTransform{
+		"publish": func(msg *commitlog.Message) error {
+			if !atomic.CompareAndSwapInt32(&published, 0, 1) {
+				return nil
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := client.Publish(ctx, "foo", []byte("concurrent"), lift.AckPolicyLeader())
+			return err
+		},
+		"identity": func(msg *commitlog.Message) error {
+			return nil
+		},
+	}
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	var err error
+	client, err = lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name)
+	require.NoError(t, err)
+	waitForPartition(t, 5*time.Second, name, 0, s1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		_, err = client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		require.NoError(t, err)
+	}
+
+	// A message published before the transformed ones are appended makes the
+	// reprocess fail without appending or trimming anything.
+	partition := s1.metadata.GetPartition(name, 0)
+	first, last, err := s1.ReprocessStream(ctx, name, 0, "publish", true)
+	require.Equal(t, ErrPublishedDuringReprocess, err)
+	require.Equal(t, int64(-1), first)
+	require.Equal(t, int64(-1), last)
+	require.Equal(t, int64(0), partition.log.OldestOffset())
+	require.Equal(t, int64(3), partition.log.NewestOffset())
+
+	// Messages published throughout a trimming reprocess are never trimmed.
+	var (
+		stop  = make(chan struct{})
+		done  = make(chan struct{})
+		acked []int64
+	)
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			ack, err := client.Publish(ctx, name, []byte("concurrent"), lift.AckPolicyLeader())
+			if err != nil {
+				continue
+			}
+			acked = append(acked, ack.Offset())
+		}
+	}()
+	_, _, err = s1.ReprocessStream(ctx, name, 0, "identity", true)
+	close(stop)
+	<-done
+	if err != nil {
+		require.Equal(t, ErrPublishedDuringReprocess, err)
+	}
+	oldest := partition.log.OldestOffset()
+	for _, offset := range acked {
+		require.True(t, offset >= oldest, "message %d was trimmed, oldest offset %d", offset, oldest)
+	}
+}
@@ -20,10 +20,14 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 
 	lift "github.com/liftbridge-io/go-liftbridge"
 	client "github.com/liftbridge-io/liftbridge-api/go"
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
 	"github.com/liftbridge-io/liftbridge/server/health"
 	"github.com/liftbridge-io/liftbridge/server/logger"
 	proto "github.com/liftbridge-io/liftbridge/server/protocol"
@@ -65,6 +69,17 @@ type Server struct {
 	running              bool
 	goroutineWait        sync.WaitGroup
 	activityStreamClient lift.Client
+	catchUpThrottle      *catchUpThrottle
+	autoCreator          *streamAutoCreator
+	idleDeleter          *streamIdleDeleter
+	metadataExporter     *metadataExporter
+	exportsMu            sync.Mutex
+	exports              map[string]*exporter
+	importsMu            sync.Mutex
+	imports              map[string]*subjectImporter
+	producerSequencer    *producerSequencer
+	commitWaiters        *commitWaiters
+	metadataChanges      *metadataChangeFeed
 }
 
 // RunServerWithConfig creates and starts a new Server with the given
@@ -87,11 +102,20 @@ func New(config *Config) *Server {
 		logger.SetWriter(ioutil.Discard)
 	}
 	s := &Server{
-		config:     config,
-		logger:     logger,
-		shutdownCh: make(chan struct{}),
+		config:          config,
+		logger:          logger,
+		shutdownCh:      make(chan struct{}),
+		catchUpThrottle: newCatchUpThrottle(config.Clustering.ReplicaCatchUpMaxBytesPerSec),
+		exports:         make(map[string]*exporter),
+		imports:         make(map[string]*subjectImporter),
 	}
 	s.metadata = newMetadataAPI(s)
+	s.autoCreator = newStreamAutoCreator(s)
+	s.idleDeleter = newStreamIdleDeleter(s)
+	s.metadataExporter = newMetadataExporter(s)
+	s.producerSequencer = newProducerSequencer(config.ProducerMaxSessions)
+	s.commitWaiters = newCommitWaiters()
+	s.metadataChanges = newMetadataChangeFeed()
 	return s
 }
 
@@ -174,6 +198,16 @@ func (s *Server) Start() (err error) {
 		return errors.Wrap(err, "failed to subscribe to partition notification subject")
 	}
 
+	s.startGoroutine(s.reportStreamActivity)
+
+	if err := s.resumeExports(); err != nil {
+		return errors.Wrap(err, "failed to resume exports")
+	}
+
+	if err := s.resumeImports(); err != nil {
+		return errors.Wrap(err, "failed to resume imports")
+	}
+
 	s.handleSignals()
 
 	return errors.Wrap(s.startAPIServer(), "failed to start API server")
@@ -200,17 +234,23 @@ func (s *Server) Stop() error {
 		s.listener.Close()
 	}
 
+	// Keep shutting down if a step fails so that the server doesn't keep
+	// running, e.g. its Raft node or NATS connections, and return the first
+	// error.
+	var stopErr error
 	if s.metadata != nil {
 		if err := s.metadata.Reset(); err != nil {
-			s.mu.Unlock()
-			return err
+			s.logger.Errorf("Failed to close streams: %v", err)
+			stopErr = err
 		}
 	}
 
 	if raft := s.getRaft(); raft != nil {
 		if err := raft.shutdown(); err != nil {
-			s.mu.Unlock()
-			return err
+			s.logger.Errorf("Failed to shut down Raft: %v", err)
+			if stopErr == nil {
+				stopErr = err
+			}
 		}
 	}
 
@@ -226,7 +266,7 @@ func (s *Server) Stop() error {
 	// Wait for goroutines to stop.
 	s.goroutineWait.Wait()
 
-	return nil
+	return stopErr
 }
 
 // IsLeader indicates if the server is currently the metadata leader or not. If
@@ -245,6 +285,473 @@ func (s *Server) IsRunning() bool {
 	return s.running
 }
 
+// UnderReplicatedStreams returns the number of streams which have at least
+// one partition whose in-sync replicas set is smaller than its replication
+// factor. A non-zero value indicates the cluster is not fully healthy.
+func (s *Server) UnderReplicatedStreams() int {
+	return s.metadata.UnderReplicatedStreams()
+}
+
+// IngestDroppedMessages returns the number of messages NATS has dropped on the
+// subscriptions of partitions led by this server because the server was a
+// slow consumer. Dropped messages are never written to their streams.
+func (s *Server) IngestDroppedMessages() int64 {
+	var dropped int64
+	for _, stream := range s.metadata.GetStreams() {
+		for _, partition := range stream.GetPartitions() {
+			dropped += partition.IngestDropped()
+		}
+	}
+	return dropped
+}
+
+// DuplicatePublishes returns the number of publishes of producer sessions this
+// server rejected because their sequence numbers were already published or
+// skipped.
+func (s *Server) DuplicatePublishes() int64 {
+	duplicates, _ := s.producerSequencer.stats()
+	return duplicates
+}
+
+// EvictedProducerSessions returns the number of producer sessions this server
+// stopped tracking because it was tracking the maximum number of sessions set
+// by ProducerMaxSessions. The next publish of an evicted session starts it
+// over, so its duplicates are no longer detected.
+func (s *Server) EvictedProducerSessions() int64 {
+	_, evictions := s.producerSequencer.stats()
+	return evictions
+}
+
+// RenameStream renames the given stream while preserving its partitions'
+// commit logs and offsets. Subscriptions on the old name stop receiving
+// messages and clients must resubscribe using the new name. This is forwarded to the
+// metadata leader if this server is not the leader.
+func (s *Server) RenameStream(ctx context.Context, stream, newName string) error {
+	st := s.metadata.RenameStream(ctx, &proto.RenameStreamOp{
+		Stream:  stream,
+		NewName: newName,
+	})
+	if st != nil {
+		return st.Err()
+	}
+	return nil
+}
+
+// SwapStreams atomically exchanges the names and subjects of two streams along
+// with their data, e.g. to replace a stream with a copy rebuilt under another
+// name for a schema migration. Every replica swaps the streams at the same
+// point. Afterwards, publishes to a stream's name or subject go to the other
+// stream's log and subscriptions on either name stop receiving messages, so
+// clients must resubscribe to read the swapped-in data. The streams' offsets
+// are not carried over, so consumers should resubscribe from a position rather
+// than their last offset. This is forwarded to the metadata leader if this
+// server is not the leader.
+func (s *Server) SwapStreams(ctx context.Context, stream, other string) error {
+	st := s.metadata.SwapStreams(ctx, &proto.SwapStreamsOp{
+		Stream: stream,
+		Other:  other,
+	})
+	if st != nil {
+		return st.Err()
+	}
+	return nil
+}
+
+// DemoteReplica demotes the given replica of a stream partition to an
+// observer. It is removed from the ISR, so commits no longer wait on it, and it
+// can no longer be elected leader, but it keeps replicating the partition.
+// The partition leader cannot be demoted. This is forwarded to the metadata
+// leader if this server is not the leader.
+func (s *Server) DemoteReplica(ctx context.Context, stream string, partitionID int32, replica string) error {
+	st := s.metadata.ChangeReplicaRole(ctx, &proto.ChangeReplicaRoleOp{
+		Stream:    stream,
+		Partition: partitionID,
+		Replica:   replica,
+		Observer:  true,
+	})
+	if st != nil {
+		return st.Err()
+	}
+	return nil
+}
+
+// PromoteObserver promotes the given observer of a stream partition to a full
+// replica. It joins the ISR once it has caught up with the partition leader.
+// This is forwarded to the metadata leader if this server is not the leader.
+func (s *Server) PromoteObserver(ctx context.Context, stream string, partitionID int32, observer string) error {
+	st := s.metadata.ChangeReplicaRole(ctx, &proto.ChangeReplicaRoleOp{
+		Stream:    stream,
+		Partition: partitionID,
+		Replica:   observer,
+	})
+	if st != nil {
+		return st.Err()
+	}
+	return nil
+}
+
+// SetServerReadOnly marks the server with the given ID read-only for
+// maintenance, or writable again if readOnly is false. A read-only server
+// keeps replicating and serving reads for its partitions, including those it
+// currently leads, but is not selected to lead new partitions and is only
+// elected leader after a failure when no other ISR replica is available. This
+// lets operators prepare a server for shutdown without draining it
+// immediately. New streams fail to be created if every server they could be
+// placed on is read-only. This is forwarded to the metadata leader if this
+// server is not the leader.
+func (s *Server) SetServerReadOnly(ctx context.Context, serverID string, readOnly bool) error {
+	st := s.metadata.SetServerReadOnly(ctx, &proto.SetServerReadOnlyOp{
+		Server:   serverID,
+		ReadOnly: readOnly,
+	})
+	if st != nil {
+		return st.Err()
+	}
+	return nil
+}
+
+// VerifyStream scans this server's commit logs for each partition of the
+// given stream, checking message checksums and offset continuity. It returns
+// a report for each partition keyed by partition ID. This only verifies the
+// local replicas, so it should be run against each server hosting the stream.
+// ErrStreamNotFound is returned if the stream does not exist.
+func (s *Server) VerifyStream(stream string) (map[int32]*commitlog.VerifyReport, error) {
+	st := s.metadata.GetStream(stream)
+	if st == nil {
+		return nil, ErrStreamNotFound
+	}
+	partitions := st.GetPartitions()
+	reports := make(map[int32]*commitlog.VerifyReport, len(partitions))
+	for id, partition := range partitions {
+		report, err := partition.log.Verify()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to verify partition %d", id)
+		}
+		reports[id] = report
+	}
+	return reports, nil
+}
+
+// MessagePositions returns the segment file and byte position of each message
+// from start through end, inclusive, in this server's commit log for the given
+// stream partition, e.g. for building external indexes. The positions only
+// apply to this server's replica.
+func (s *Server) MessagePositions(stream string, partitionID int32, start, end int64) (
+	[]*commitlog.OffsetPosition, error) {
+
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return nil, err
+	}
+	return partitions[0].log.Positions(start, end)
+}
+
+// PartitionLeader returns the ID of the current leader of the given stream
+// partition and its leader epoch according to this server's metadata, e.g.
+// for callers that already know which partition to publish to and want to
+// talk to its leader directly. ErrStreamNotFound or ErrPartitionNotFound is
+// returned if the stream or partition does not exist.
+func (s *Server) PartitionLeader(stream string, partitionID int32) (string, uint64, error) {
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return "", 0, err
+	}
+	leader, epoch := partitions[0].GetLeader()
+	return leader, epoch, nil
+}
+
+// CheckpointStream forces each partition of the given stream to fsync its
+// commit log and high watermark, regardless of streams.sync.writes, and
+// returns the offset through which each partition is durable keyed by
+// partition ID. This gives external tooling a consistent point to snapshot
+// the data directory at for backups. Only committed messages are included, so
+// this must be called on the leader of every partition of the stream,
+// otherwise ErrNotPartitionLeader is returned. ErrStreamNotFound is returned
+// if the stream does not exist.
+func (s *Server) CheckpointStream(stream string) (map[int32]int64, error) {
+	partitions, err := s.getStreamPartitions(stream, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, partition := range partitions {
+		if !partition.IsLeader() {
+			return nil, ErrNotPartitionLeader
+		}
+	}
+	offsets := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		offset, err := partition.log.Sync()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to checkpoint partition %d", partition.Id)
+		}
+		offsets[partition.Id] = offset
+	}
+	return offsets, nil
+}
+
+// replicationCodec returns the ReplicationCodec used to frame replication
+// responses.
+func (s *Server) replicationCodec() ReplicationCodec {
+	if s.config.Clustering.ReplicationCodec != nil {
+		return s.config.Clustering.ReplicationCodec
+	}
+	return envelopeReplicationCodec{}
+}
+
+// CreateStreams creates the streams for the given requests in a single Raft
+// operation, which is much faster than calling CreateStream for each when
+// provisioning many streams. The request metadata of the Context applies to
+// every stream, e.g. to set ReplicaServersMetadataKey. Each stream is created
+// or fails on its own, so an error is returned for each request, in order,
+// which is nil if the stream was created. Like CreateStream, the error has an
+// AlreadyExists status code if the stream already exists. The second error is
+// returned if the operation failed as a whole, in which case the streams may
+// or may not have been created. This is forwarded to the metadata leader if
+// this server is not the leader.
+func (s *Server) CreateStreams(ctx context.Context, reqs []*client.CreateStreamRequest) ([]error, error) {
+	var (
+		errs    = make([]error, len(reqs))
+		op      = &proto.CreateStreamsOp{}
+		indexes []int
+	)
+	for i, req := range reqs {
+		setCreateStreamDefaults(req)
+		streamOp, st := newCreateStreamOp(ctx, req)
+		if st != nil {
+			errs[i] = st.Err()
+			continue
+		}
+		op.Streams = append(op.Streams, streamOp)
+		indexes = append(indexes, i)
+	}
+	if len(op.Streams) == 0 {
+		return errs, nil
+	}
+
+	var (
+		results []*status.Status
+		unknown bool
+	)
+	if st := retryMetadataOp(ctx, nil, func() *status.Status {
+		var st *status.Status
+		results, st = s.metadata.CreateStreams(ctx, op)
+		unknown = unknown || isOutcomeUnknown(st)
+		return st
+	}); st != nil {
+		s.logger.Errorf("Failed to create streams: %v", st.Err())
+		return nil, st.Err()
+	}
+	for j, result := range results {
+		// A stream which already exists after an attempt which may have been
+		// applied was created by that attempt.
+		if result != nil && !(unknown && result.Code() == codes.AlreadyExists) {
+			errs[indexes[j]] = result.Err()
+		}
+	}
+	return errs, nil
+}
+
+// TrimStream deletes all committed messages before the given offset from each
+// partition of the given stream, on every replica, without touching newer
+// messages. Afterwards, the oldest offset of each partition is the given
+// offset. This is intended for removing old data from the head of a stream,
+// e.g. for privacy requirements. This is forwarded to the metadata leader if
+// this server is not the leader. It fails with OutOfRange if the offset is
+// past a partition's high watermark, or with FailedPrecondition if the high
+// watermark can't be determined, e.g. because the partition leader is
+// unavailable. It fails with Aborted if the Context carries a fencing token,
+// set with FencingTokenMetadataKey, below the fencing epoch.
+func (s *Server) TrimStream(ctx context.Context, stream string, beforeOffset int64) error {
+	st := s.metadata.TrimStream(ctx, &proto.TrimStreamOp{
+		Stream: stream,
+		Offset: beforeOffset,
+	})
+	if st != nil {
+		return st.Err()
+	}
+	return nil
+}
+
+// ImportMessages appends pre-framed messages to the given stream partition,
+// bypassing NATS ingest, e.g. to bulk-load historical data when migrating
+// from another log system. The data must be in the log's message set format,
+// as produced by commitlog.EncodeMessageSet, and is validated before anything
+// is appended. The messages keep their original keys, values, headers, and
+// timestamps and are appended in order as a single batch, while their offsets
+// and leader epochs are assigned by the partition leader. Timestamps earlier
+// than the partition's latest are raised to it so timestamps stay monotonic.
+// Server-generated message IDs are not added to imported messages. It returns
+// the offsets of the imported messages once the leader has written them,
+// before they are committed. The batch is all-or-nothing: if any message
+// violates the stream's key, empty value, or schema settings, nothing is
+// appended. If the Context carries BatchBestEffortMetadataKey, the valid
+// messages are appended anyway, the offsets of the rejected ones are -1, and a
+// *BatchError reports why they were rejected. This must be called on the
+// partition leader, otherwise ErrNotPartitionLeader is returned.
+// ErrStreamNotFound or ErrPartitionNotFound is returned if the stream or
+// partition does not exist.
+func (s *Server) ImportMessages(ctx context.Context, stream string, partitionID int32,
+	data []byte) ([]int64, error) {
+
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return nil, err
+	}
+	partition := partitions[0]
+	msgs, err := commitlog.DecodeMessageSet(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid message set")
+	}
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	if getMetadataFlag(ctx, BatchBestEffortMetadataKey) {
+		return importBestEffort(ctx, partition, msgs)
+	}
+	if err := validateImported(partition, msgs); err != nil {
+		return nil, err
+	}
+	return partition.Import(ctx, msgs)
+}
+
+// BatchError is returned by ImportMessages in best-effort mode when some of
+// the messages of the batch were rejected and the others appended.
+type BatchError struct {
+	// Errors maps the index of each rejected message in the batch to why it
+	// was rejected.
+	Errors map[int]error
+}
+
+// Error implements the error interface.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d messages of the batch rejected", len(e.Errors))
+}
+
+// importBestEffort imports the messages to the partition which don't violate
+// the stream's settings. It returns the offsets of the messages, -1 for the
+// rejected ones, and a *BatchError if any were rejected.
+func importBestEffort(ctx context.Context, partition *partition, msgs []*commitlog.Message) ([]int64, error) {
+	var (
+		valid    = make([]*commitlog.Message, 0, len(msgs))
+		indexes  = make([]int, 0, len(msgs))
+		rejected = make(map[int]error)
+		offsets  = make([]int64, len(msgs))
+	)
+	for i, msg := range msgs {
+		offsets[i] = -1
+		if err := validateImportedMessage(partition, i, msg); err != nil {
+			rejected[i] = err
+			continue
+		}
+		valid = append(valid, msg)
+		indexes = append(indexes, i)
+	}
+	if len(valid) > 0 {
+		imported, err := partition.Import(ctx, valid)
+		if err != nil {
+			return nil, err
+		}
+		for i, offset := range imported {
+			offsets[indexes[i]] = offset
+		}
+	}
+	if len(rejected) > 0 {
+		return offsets, &BatchError{Errors: rejected}
+	}
+	return offsets, nil
+}
+
+// validateImported returns an error if any of the messages to import to the
+// partition violates the stream's key, empty value, or schema settings.
+func validateImported(partition *partition, msgs []*commitlog.Message) error {
+	for i, msg := range msgs {
+		if err := validateImportedMessage(partition, i, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateImportedMessage returns an error if the message at the given index
+// of the batch to import to the partition violates the stream's key, empty
+// value, or schema settings.
+func validateImportedMessage(partition *partition, i int, msg *commitlog.Message) error {
+	if partition.GetRequireKey() && len(msg.Key) == 0 {
+		return errors.Errorf("message %d has no key but stream %s requires one", i, partition.Stream)
+	}
+	if partition.GetEmptyValue() == proto.EmptyValue_REJECT && len(msg.Value) == 0 {
+		return errors.Errorf("message %d has an empty value but stream %s rejects them", i, partition.Stream)
+	}
+	if err := partition.validateSchema(msg.Key, msg.Value); err != nil {
+		return errors.Wrapf(err, "message %d rejected by stream %s", i, partition.Stream)
+	}
+	return nil
+}
+
+// PauseReplication pauses replication of the given partitions of a stream on
+// this server, or all of its partitions if none are given. Partitions this
+// server leads stop serving replication requests, so their followers fall out
+// of the ISR and eventually report the leader as failed, forcing an election.
+// Partitions this server follows stop fetching from their leader. This is
+// intended for controlled maintenance and only affects this server, so it
+// should be called on each server whose replication should be paused.
+// ErrStreamNotFound or ErrPartitionNotFound is returned if the stream or a
+// partition does not exist.
+func (s *Server) PauseReplication(stream string, partitions []int32) error {
+	toPause, err := s.getStreamPartitions(stream, partitions)
+	if err != nil {
+		return err
+	}
+	for _, partition := range toPause {
+		partition.PauseReplication()
+		s.logger.Infof("Paused replication for partition %s", partition)
+	}
+	return nil
+}
+
+// ResumeReplication resumes replication of the given partitions of a stream
+// on this server, or all of its partitions if none are given, after
+// PauseReplication. Followers restart fetching from the current leader and
+// rejoin the ISR once caught up. ErrStreamNotFound or ErrPartitionNotFound is
+// returned if the stream or a partition does not exist.
+func (s *Server) ResumeReplication(stream string, partitions []int32) error {
+	toResume, err := s.getStreamPartitions(stream, partitions)
+	if err != nil {
+		return err
+	}
+	for _, partition := range toResume {
+		partition.ResumeReplication()
+		s.logger.Infof("Resumed replication for partition %s", partition)
+	}
+	return nil
+}
+
+// getStreamPartitions returns the given partitions of a stream or all of its
+// partitions if none are given.
+func (s *Server) getStreamPartitions(stream string, ids []int32) ([]*partition, error) {
+	st := s.metadata.GetStream(stream)
+	if st == nil {
+		return nil, ErrStreamNotFound
+	}
+	if len(ids) == 0 {
+		all := st.GetPartitions()
+		partitions := make([]*partition, 0, len(all))
+		for _, partition := range all {
+			partitions = append(partitions, partition)
+		}
+		return partitions, nil
+	}
+	partitions := make([]*partition, 0, len(ids))
+	for _, id := range ids {
+		partition := st.GetPartition(id)
+		if partition == nil {
+			return nil, ErrPartitionNotFound
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions, nil
+}
+
 // recoverAndPersistState recovers any existing server metadata state from disk
 // to initialize the server then writes the metadata back to disk.
 func (s *Server) recoverAndPersistState() error {
@@ -333,7 +840,10 @@ func (s *Server) closeNATSConns() {
 
 // startAPIServer configures and starts the gRPC API server.
 func (s *Server) startAPIServer() error {
-	opts := []grpc.ServerOption{}
+	if err := s.config.GRPC.validate(); err != nil {
+		return errors.Wrap(err, "invalid gRPC configuration")
+	}
+	opts := grpcServerOptions(s.config.GRPC)
 
 	// Setup TLS if key/cert is set.
 	if s.config.TLSKey != "" && s.config.TLSCert != "" {
@@ -398,6 +908,37 @@ func (s *Server) startAPIServer() error {
 	return nil
 }
 
+// grpcServerOptions returns the gRPC server options for the given settings,
+// leaving gRPC's defaults in place for those which aren't set.
+func grpcServerOptions(config GRPCConfig) []grpc.ServerOption {
+	opts := []grpc.ServerOption{}
+	if config.MaxRecvMessageSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(config.MaxRecvMessageSize))
+	}
+	if config.MaxSendMessageSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(config.MaxSendMessageSize))
+	}
+	if config.KeepaliveTime > 0 || config.KeepaliveTimeout > 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    config.KeepaliveTime,
+			Timeout: config.KeepaliveTimeout,
+		}))
+	}
+	if config.KeepaliveMinTime > 0 || config.KeepalivePermitWithoutStream {
+		minTime := config.KeepaliveMinTime
+		if minTime == 0 {
+			// Keep gRPC's default when only permitting pings without
+			// streams.
+			minTime = defaultGRPCKeepaliveMinTime
+		}
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             minTime,
+			PermitWithoutStream: config.KeepalivePermitWithoutStream,
+		}))
+	}
+	return opts
+}
+
 // createNATSConn creates a new NATS connection with the given name.
 func (s *Server) createNATSConn(name string) (*nats.Conn, error) {
 	var err error
@@ -537,11 +1078,26 @@ func (s *Server) leadershipAcquired() error {
 	}
 	s.leaderSub = sub
 
+	// Only bootstrap the stream definitions into a cluster without streams,
+	// which is checked before the activity stream is created.
+	bootstrap := s.config.Clustering.MetadataBootstrapPath != "" && len(s.metadata.GetStreams()) == 0
+
 	if err := s.createActivityStream(); err != nil {
 		return err
 	}
 
+	if err := s.autoCreator.Start(); err != nil {
+		return err
+	}
+
+	s.idleDeleter.Start()
+	s.metadataExporter.Start()
+
 	atomic.StoreInt64(&(s.getRaft().leader), 1)
+
+	if bootstrap {
+		s.startGoroutine(s.bootstrapMetadata)
+	}
 	return nil
 }
 
@@ -559,6 +1115,10 @@ func (s *Server) leadershipLost() error {
 
 	s.metadata.LostLeadership()
 
+	s.autoCreator.Stop()
+	s.idleDeleter.Stop()
+	s.metadataExporter.Stop()
+
 	// Close any activity stream client
 	if s.activityStreamClient != nil {
 		s.activityStreamClient.Close()
@@ -603,6 +1163,28 @@ func (s *Server) handlePropagatedRequest(m *nats.Msg) {
 		resp = s.handleDeleteStream(req)
 	case proto.Op_PAUSE_STREAM:
 		resp = s.handlePauseStream(req)
+	case proto.Op_CREATE_STREAM:
+		resp = s.handleCreateStream(req)
+	case proto.Op_RENAME_STREAM:
+		resp = s.handleRenameStream(req)
+	case proto.Op_SWAP_STREAMS:
+		resp = s.handleSwapStreams(req)
+	case proto.Op_TRIM_STREAM:
+		resp = s.handleTrimStream(req)
+	case proto.Op_CREATE_STREAMS:
+		resp = s.handleCreateStreams(req)
+	case proto.Op_CHANGE_REPLICA_ROLE:
+		resp = s.handleChangeReplicaRole(req)
+	case proto.Op_CHANGE_REPLICAS:
+		resp = s.handleChangeReplicas(req)
+	case proto.Op_CHANGE_LEADER:
+		resp = s.handleChangeLeader(req)
+	case proto.Op_REPORT_ACTIVITY:
+		resp = s.handleReportActivity(req)
+	case proto.Op_SET_SERVER_READ_ONLY:
+		resp = s.handleSetServerReadOnly(req)
+	case proto.Op_CONFIRM_LEADER:
+		resp = s.handleConfirmLeader(req)
 	default:
 		s.logger.Warnf("Unknown propagated request operation: %s", req.Op)
 		return
@@ -656,11 +1238,31 @@ func (s *Server) handleReportLeader(req *proto.PropagatedRequest) *proto.Propaga
 	return resp
 }
 
+func (s *Server) handleReportActivity(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	if err := s.metadata.ReportActivity(context.Background(), req.ReportActivityOp); err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+	}
+	return resp
+}
+
+func (s *Server) handleConfirmLeader(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	if err := s.metadata.ConfirmLeader(context.Background(), req.ConfirmLeaderOp); err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+	}
+	return resp
+}
+
 func (s *Server) handleDeleteStream(req *proto.PropagatedRequest) *proto.PropagatedResponse {
 	resp := &proto.PropagatedResponse{
 		Op: req.Op,
 	}
-	if err := s.metadata.DeleteStream(context.Background(), req.DeleteStreamOp); err != nil {
+	if err := s.metadata.DeleteStream(propagatedContext(req), req.DeleteStreamOp); err != nil {
 		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
 	}
 	return resp
@@ -676,6 +1278,107 @@ func (s *Server) handlePauseStream(req *proto.PropagatedRequest) *proto.Propagat
 	return resp
 }
 
+func (s *Server) handleCreateStream(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	if err := s.metadata.CreateStream(context.Background(), req.CreateStreamOp); err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+	}
+	return resp
+}
+
+func (s *Server) handleCreateStreams(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	results, err := s.metadata.CreateStreams(context.Background(), req.CreateStreamsOp)
+	if err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+		return resp
+	}
+	resp.CreateStreamsResp = &proto.CreateStreamsResponse{
+		Results: make([]*proto.Error, len(results)),
+	}
+	for i, result := range results {
+		resp.CreateStreamsResp.Results[i] = &proto.Error{}
+		if result != nil {
+			resp.CreateStreamsResp.Results[i] = &proto.Error{Code: uint32(result.Code()), Msg: result.Message()}
+		}
+	}
+	return resp
+}
+
+func (s *Server) handleRenameStream(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	if err := s.metadata.RenameStream(context.Background(), req.RenameStreamOp); err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+	}
+	return resp
+}
+
+func (s *Server) handleSwapStreams(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	if err := s.metadata.SwapStreams(context.Background(), req.SwapStreamsOp); err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+	}
+	return resp
+}
+
+func (s *Server) handleTrimStream(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	if err := s.metadata.TrimStream(propagatedContext(req), req.TrimStreamOp); err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+	}
+	return resp
+}
+
+func (s *Server) handleChangeReplicaRole(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	if err := s.metadata.ChangeReplicaRole(context.Background(), req.ChangeReplicaRoleOp); err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+	}
+	return resp
+}
+
+func (s *Server) handleSetServerReadOnly(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	if err := s.metadata.SetServerReadOnly(context.Background(), req.SetServerReadOnlyOp); err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+	}
+	return resp
+}
+
+func (s *Server) handleChangeReplicas(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	if err := s.metadata.ChangeReplicas(propagatedContext(req), req.ChangeReplicasOp); err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+	}
+	return resp
+}
+
+func (s *Server) handleChangeLeader(req *proto.PropagatedRequest) *proto.PropagatedResponse {
+	resp := &proto.PropagatedResponse{
+		Op: req.Op,
+	}
+	if err := s.metadata.ChangeLeader(context.Background(), req.ChangeLeaderOp); err != nil {
+		resp.Error = &proto.Error{Code: uint32(err.Code()), Msg: err.Message()}
+	}
+	return resp
+}
+
 func (s *Server) isShutdown() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -720,10 +1423,27 @@ func (s *Server) natsClosedHandler(nc *nats.Conn) {
 // natsErrorHandler fires when there is an asynchronous error on the NATS
 // connection.
 func (s *Server) natsErrorHandler(nc *nats.Conn, sub *nats.Subscription, err error) {
+	if err == nats.ErrSlowConsumer && sub != nil && s.handleIngestSlowConsumer(sub) {
+		return
+	}
 	s.logger.Errorf("Asynchronous error on connection %s, subject %s: %s",
 		nc.Opts.Name, sub.Subject, err)
 }
 
+// handleIngestSlowConsumer reports messages dropped on the given subscription
+// if it's a partition leader's subscription to the partition's NATS subject.
+// It returns false if the subscription does not belong to a partition.
+func (s *Server) handleIngestSlowConsumer(sub *nats.Subscription) bool {
+	for _, stream := range s.metadata.GetStreams() {
+		for _, partition := range stream.GetPartitions() {
+			if partition.handleSlowConsumer(sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // handleServerInfoRequest is a NATS handler used to process requests for
 // server information used in the metadata API.
 func (s *Server) handleServerInfoRequest(m *nats.Msg) {
@@ -755,7 +1475,8 @@ func (s *Server) handleServerInfoRequest(m *nats.Msg) {
 
 // handlePartitionStatusRequest is a NATS handler used to process requests
 // querying the status of a partition. This is used as a readiness check to
-// determine if a created partition has actually started.
+// determine if a created partition has actually started and by the metadata
+// leader to find the most caught-up replica when electing a partition leader.
 func (s *Server) handlePartitionStatusRequest(m *nats.Msg) {
 	req, err := proto.UnmarshalPartitionStatusRequest(m.Data)
 	if err != nil {
@@ -768,6 +1489,9 @@ func (s *Server) handlePartitionStatusRequest(m *nats.Msg) {
 	resp := &proto.PartitionStatusResponse{Exists: partition != nil}
 	if partition != nil {
 		resp.IsLeader = partition.IsLeader()
+		resp.NewestOffset = partition.log.NewestOffset()
+		resp.HighWatermark = partition.log.HighWatermark()
+		resp.LastLeaderEpoch = partition.log.LastLeaderEpoch()
 	}
 
 	data, err := proto.MarshalPartitionStatusResponse(resp)
@@ -789,7 +1513,9 @@ func (s *Server) handlePartitionStatusRequest(m *nats.Msg) {
 // added commit latency when new messages are published to the log since the
 // follower is idle. As a result, the leader will note when a follower is
 // caught up and send a notification in order to wake an idle follower back up
-// when new data is written to the log.
+// when new data is written to the log. The leader also sends a notification
+// when a follower is ahead of it, in which case the follower must truncate its
+// log to match the leader's before replicating further.
 func (s *Server) handlePartitionNotification(m *nats.Msg) {
 	req, err := proto.UnmarshalPartitionNotification(m.Data)
 	if err != nil {
@@ -804,6 +1530,11 @@ func (s *Server) handlePartitionNotification(m *nats.Msg) {
 		return
 	}
 
+	if req.Truncate {
+		partition.NotifyTruncate()
+		return
+	}
+
 	// Wake the follower up.
 	partition.Notify()
 }
@@ -6,7 +6,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -14,11 +16,16 @@ import (
 	lift "github.com/liftbridge-io/go-liftbridge"
 	liftApi "github.com/liftbridge-io/liftbridge-api/go"
 	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	grpcMetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
 	proto "github.com/liftbridge-io/liftbridge/server/protocol"
 )
 
@@ -106,6 +113,22 @@ func waitForNoMetadataLeader(t *testing.T, timeout time.Duration, servers ...*Se
 	stackFatalf(t, "Metadata leader found")
 }
 
+func waitForClusterSize(t *testing.T, timeout time.Duration, size int, servers ...*Server) {
+	deadline := time.Now().Add(timeout)
+LOOP:
+	for time.Now().Before(deadline) {
+		for _, s := range servers {
+			ids, err := s.metadata.getClusterServerIDs()
+			if err != nil || len(ids) != size {
+				time.Sleep(15 * time.Millisecond)
+				continue LOOP
+			}
+		}
+		return
+	}
+	stackFatalf(t, "Cluster did not reach size %d", size)
+}
+
 func checkPartitionPaused(t *testing.T, stream string, partitionID int32, paused bool, server *Server) {
 	partition := server.metadata.GetPartition(stream, partitionID)
 	if partition == nil {
@@ -849,6 +872,125 @@ func TestStreamRetentionAge(t *testing.T) {
 	}
 }
 
+// Ensure a stream's retention age set at creation overrides the server's.
+func TestStreamRetentionAgeOverride(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.SegmentMaxBytes = 1
+	s1Config.BatchMaxMessages = 1
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Invalid retention ages are rejected.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		RetentionMaxAgeMetadataKey, "-1s")
+	err = client.CreateStream(ctx, "bar", "bar")
+	require.Error(t, err)
+
+	// Create a stream with a retention age and one using the server's.
+	ctx = grpcMetadata.AppendToOutgoingContext(context.Background(),
+		RetentionMaxAgeMetadataKey, "1ns")
+	err = client.CreateStream(ctx, "foo", "foo")
+	require.NoError(t, err)
+	err = client.CreateStream(context.Background(), "bar", "bar")
+	require.NoError(t, err)
+
+	// Publish some messages to both.
+	num := 10
+	for _, name := range []string{"foo", "bar"} {
+		for i := 0; i < num; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err = client.Publish(ctx, name, []byte("hello"))
+			require.NoError(t, err)
+		}
+	}
+
+	forceLogClean(t, "foo", "foo", s1)
+	forceLogClean(t, "bar", "bar", s1)
+
+	// All segments but the last are deleted from the stream with a retention
+	// age, while the other stream keeps its messages.
+	require.Equal(t, int64(num-1), s1.metadata.GetPartition("foo", 0).log.OldestOffset())
+	require.Equal(t, int64(0), s1.metadata.GetPartition("bar", 0).log.OldestOffset())
+}
+
+// Ensure a stream's max number of log segments set at creation overrides the
+// server's.
+func TestStreamRetentionSegmentsOverride(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.SegmentMaxBytes = 1
+	s1Config.Streams.RetentionMaxSegments = 5
+	s1Config.BatchMaxMessages = 1
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Invalid segment limits are rejected.
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		RetentionMaxSegmentsMetadataKey, "0")
+	err = client.CreateStream(ctx, "foo", "foo")
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Create a stream with a segment limit and one using the server's.
+	ctx = grpcMetadata.AppendToOutgoingContext(context.Background(),
+		RetentionMaxSegmentsMetadataKey, "2")
+	err = client.CreateStream(ctx, "foo", "foo")
+	require.NoError(t, err)
+	err = client.CreateStream(context.Background(), "bar", "bar")
+	require.NoError(t, err)
+
+	config, err := s1.GetStreamConfig("foo")
+	require.NoError(t, err)
+	require.Equal(t, 2, config.RetentionMaxSegments)
+
+	// Publish some messages to both.
+	num := 10
+	for _, name := range []string{"foo", "bar"} {
+		for i := 0; i < num; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err = client.Publish(ctx, name, []byte("hello"))
+			require.NoError(t, err)
+		}
+	}
+
+	forceLogClean(t, "foo", "foo", s1)
+	forceLogClean(t, "bar", "bar", s1)
+
+	// Each segment holds one message, and each stream keeps only its own
+	// number of segments.
+	require.Equal(t, int64(num-2), s1.metadata.GetPartition("foo", 0).log.OldestOffset())
+	require.Equal(t, int64(num-5), s1.metadata.GetPartition("bar", 0).log.OldestOffset())
+}
+
 // Ensure when StartPosition_EARLIEST is used with Subscribe, messages are read
 // starting at the oldest offset.
 func TestSubscribeEarliest(t *testing.T) {
@@ -1094,191 +1236,159 @@ func TestSubscribeStartTime(t *testing.T) {
 	}
 }
 
-// Ensure clients can connect with TLS when enabled.
-func TestTLS(t *testing.T) {
+// Ensure messages published directly to NATS on a wildcard stream are
+// delivered with the NATS subject and reply subject they were published with.
+func TestSubscribeNATSSubject(t *testing.T) {
 	defer cleanupStorage(t)
 
 	// Use a central NATS server.
 	ns := natsdTest.RunDefaultServer()
 	defer ns.Shutdown()
 
-	// Configure server with TLS.
-	s1Config, err := NewConfig("./configs/tls.yaml")
-	require.NoError(t, err)
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
 	s1 := runServerWithConfig(t, s1Config)
 	defer s1.Stop()
 
 	// Wait for server to elect itself leader.
 	getMetadataLeader(t, 10*time.Second, s1)
 
-	// Connect with TLS.
-	client, err := lift.Connect([]string{"localhost:5050"}, lift.TLSCert("./configs/certs/server.crt"))
+	client, err := lift.Connect([]string{"localhost:5050"})
 	require.NoError(t, err)
 	defer client.Close()
 
-	// Connecting without a cert should fail.
-	_, err = lift.Connect([]string{"localhost:5050"})
-	require.Error(t, err)
-}
-
-// Ensure that the host address is the same as the listen address when
-// specifying only the latter
-func TestListen(t *testing.T) {
-	config, err := NewConfig("./configs/listen.yaml")
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo.*", name)
 	require.NoError(t, err)
 
-	ex := HostPort{
-		Host: "192.168.0.1",
-		Port: 4222,
-	}
-
-	r := config.GetListenAddress()
-	if r != ex {
-		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
-	}
-
-	r = config.GetConnectionAddress()
-	if r != ex {
-		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
-	}
-}
-
-// Ensure that the listen address is the same as the host address when
-// specifying only the latter
-func TestHost(t *testing.T) {
-	config, err := NewConfig("./configs/host.yaml")
+	nc, err := nats.Connect(nats.DefaultURL)
 	require.NoError(t, err)
+	defer nc.Close()
 
-	ex := HostPort{
-		Host: "192.168.0.1",
-		Port: 4222,
-	}
-
-	r := config.GetListenAddress()
-	if r != ex {
-		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
-	}
-
-	r = config.GetConnectionAddress()
-	if r != ex {
-		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
-	}
-}
+	require.NoError(t, nc.PublishRequest("foo.bar", "baz", []byte("hello")))
+	require.NoError(t, nc.Publish("foo.qux", []byte("world")))
+	require.NoError(t, nc.Flush())
 
-// Ensure that the listen and connection addresses have the expected values
-// when specifying both
-func TestListenHost(t *testing.T) {
-	config, err := NewConfig("./configs/listen-host.yaml")
+	msgs := make(chan lift.Message, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		msgs <- msg
+	}, lift.StartAtEarliestReceived())
 	require.NoError(t, err)
 
-	ex := HostPort{
-		Host: "192.168.0.1",
-		Port: 4222,
-	}
-
-	r := config.GetListenAddress()
-	if r != ex {
-		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
-	}
-
-	ex = HostPort{
-		Host: "my-host",
-		Port: 4333,
-	}
-
-	r = config.GetConnectionAddress()
-	if r != ex {
-		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
-	}
-}
-
-// Ensure that the listen and connection addresses have the expected default
-// values
-func TestDefaultListenHost(t *testing.T) {
-	config := NewDefaultConfig()
-
-	ex := HostPort{
-		Host: defaultListenAddress,
-		Port: DefaultPort,
-	}
-
-	r := config.GetListenAddress()
-	if r != ex {
-		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
-	}
-
-	ex = HostPort{
-		Host: defaultConnectionAddress,
-		Port: DefaultPort,
+	expected := []struct {
+		subject string
+		reply   string
+		value   string
+	}{
+		{"foo.bar", "baz", "hello"},
+		{"foo.qux", "", "world"},
 	}
-
-	r = config.GetConnectionAddress()
-	if r != ex {
-		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
+	for _, exp := range expected {
+		select {
+		case msg := <-msgs:
+			require.Equal(t, exp.subject, msg.Subject())
+			require.Equal(t, exp.reply, msg.ReplySubject())
+			require.Equal(t, []byte(exp.value), msg.Value())
+		case <-time.After(5 * time.Second):
+			t.Fatal("Did not receive expected message")
+		}
 	}
 }
 
-// Ensure the leader flag is set when the server is elected metadata leader and
-// unset when it loses leadership.
-func TestMetadataLeadershipLifecycle(t *testing.T) {
+// Ensure the partition leader never assigns a message a timestamp earlier
+// than the previous message's when the clock jumps backward.
+func TestMonotonicTimestamps(t *testing.T) {
 	defer cleanupStorage(t)
+	timestampBefore := timestamp
+	var (
+		mu    sync.Mutex
+		clock = []int64{100, 200, 50, 60, 300}
+	)
+	timestamp = func() int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		now := clock[0]
+		if len(clock) > 1 {
+			clock = clock[1:]
+		}
+		return now
+	}
+	defer func() {
+		timestamp = timestampBefore
+	}()
 
 	// Use a central NATS server.
 	ns := natsdTest.RunDefaultServer()
 	defer ns.Shutdown()
 
-	// Configure first server.
-	s1Config := getTestConfig("a", true, 0)
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
 	s1 := runServerWithConfig(t, s1Config)
 	defer s1.Stop()
 
-	// Configure second server.
-	s2Config := getTestConfig("b", false, 0)
-	s2 := runServerWithConfig(t, s2Config)
-	defer s2.Stop()
-
 	// Wait for server to elect itself leader.
 	getMetadataLeader(t, 10*time.Second, s1)
 
-	// Check leader flag is set.
-	require.Equal(t, int64(1), atomic.LoadInt64(&(s1.getRaft().leader)))
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
 
-	// Kill the follower.
-	s2.Stop()
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name)
+	require.NoError(t, err)
 
-	deadline := time.Now().Add(5 * time.Second)
-	for time.Now().Before(deadline) {
-		leader := atomic.LoadInt64(&(s1.getRaft().leader))
-		if leader == 0 {
-			return
-		}
-		time.Sleep(10 * time.Millisecond)
-		continue
+	// Publish messages while the clock jumps backward after the second.
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyLeader())
+		cancel()
+		require.NoError(t, err)
 	}
-	t.Fatal("Expected leader flag to be 0")
+
+	partition := s1.metadata.GetPartition(name, 0)
+	reader, err := partition.log.NewReader(0, true)
+	require.NoError(t, err)
+	defer reader.Close()
+	headersBuf := make([]byte, 28)
+	timestamps := make([]int64, 5)
+	for i := range timestamps {
+		_, _, ts, _, err := reader.ReadMessage(context.Background(), headersBuf)
+		require.NoError(t, err)
+		timestamps[i] = ts
+	}
+	require.Equal(t, []int64{100, 200, 200, 200, 300}, timestamps)
 }
 
-// Ensure propagation handlers for shrinking and expanding the ISR work
-// correctly.
-func TestPropagatedShrinkExpandISR(t *testing.T) {
+// Ensure when StartTimeDeltaMetadataKey is set, the start time is resolved
+// relative to the server's current time. If no messages fall within the
+// window, the subscription waits for new messages.
+func TestSubscribeStartTimeRelative(t *testing.T) {
 	defer cleanupStorage(t)
+	timestampBefore := timestamp
+	mockTimestamp := int64(0)
+	timestamp = func() int64 {
+		time := mockTimestamp
+		mockTimestamp += 10
+		return time
+	}
+	defer func() {
+		timestamp = timestampBefore
+	}()
 
 	// Use a central NATS server.
 	ns := natsdTest.RunDefaultServer()
 	defer ns.Shutdown()
 
-	// Configure first server.
+	// Configure server.
 	s1Config := getTestConfig("a", true, 5050)
 	s1 := runServerWithConfig(t, s1Config)
 	defer s1.Stop()
 
-	// Configure second server.
-	s2Config := getTestConfig("b", false, 0)
-	s2 := runServerWithConfig(t, s2Config)
-	defer s2.Stop()
-
 	// Wait for server to elect itself leader.
-	controller := getMetadataLeader(t, 10*time.Second, s1)
+	getMetadataLeader(t, 10*time.Second, s1)
 
 	client, err := lift.Connect([]string{"localhost:5050"})
 	require.NoError(t, err)
@@ -1287,7 +1397,1314 @@ func TestPropagatedShrinkExpandISR(t *testing.T) {
 	// Create stream.
 	name := "foo"
 	subject := "foo"
-	err = client.CreateStream(context.Background(), subject, name,
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	// Publish some messages with timestamps 0 through 40.
+	num := 5
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = client.Publish(ctx, name, []byte("hello"))
+		require.NoError(t, err)
+	}
+
+	// Subscribe starting 25 before the server's current time of 50. This
+	// should start reading from offset 3.
+	gotMsg := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	subCtx := grpcMetadata.AppendToOutgoingContext(ctx, StartTimeDeltaMetadataKey, "25ns")
+	client.Subscribe(subCtx, name, func(msg lift.Message, err error) {
+		select {
+		case <-gotMsg:
+			return
+		default:
+		}
+		require.NoError(t, err)
+		require.Equal(t, int64(3), msg.Offset())
+		require.Equal(t, int64(30), msg.Timestamp().UnixNano())
+		close(gotMsg)
+		cancel()
+	})
+
+	// Wait to get the message.
+	select {
+	case <-gotMsg:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not receive expected message")
+	}
+
+	// Subscribe starting 5 before the server's current time of 60. There are
+	// no messages in this window, so this should wait for new messages
+	// starting at offset 5.
+	gotMsg = make(chan struct{})
+	ctx, cancel = context.WithCancel(context.Background())
+	subCtx = grpcMetadata.AppendToOutgoingContext(ctx, StartTimeDeltaMetadataKey, "5ns")
+	client.Subscribe(subCtx, name, func(msg lift.Message, err error) {
+		select {
+		case <-gotMsg:
+			return
+		default:
+		}
+		require.NoError(t, err)
+		require.Equal(t, int64(5), msg.Offset())
+		close(gotMsg)
+		cancel()
+	})
+
+	// Publish one more message.
+	_, err = client.Publish(context.Background(), name, []byte("test"))
+	require.NoError(t, err)
+
+	// Wait to get the new message.
+	select {
+	case <-gotMsg:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not receive expected message")
+	}
+}
+
+// Ensure a subscription with a key filter only receives messages with the
+// given key.
+func TestSubscribeKeyFilter(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	// Publish messages with mixed keys.
+	keys := []string{"a", "b", "a", "c", "b"}
+	for _, key := range keys {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = client.Publish(ctx, name, []byte(key), lift.Key([]byte(key)))
+		require.NoError(t, err)
+	}
+
+	// Subscribe from the beginning filtering on key "b". This should only
+	// receive offsets 1 and 4.
+	expected := []int64{1, 4}
+	recv := 0
+	gotMsgs := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = grpcMetadata.AppendToOutgoingContext(ctx, KeyFilterMetadataKey, "b")
+	client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		require.Equal(t, expected[recv], msg.Offset())
+		require.Equal(t, []byte("b"), msg.Key())
+		recv++
+		if recv == len(expected) {
+			close(gotMsgs)
+		}
+	}, lift.StartAtEarliestReceived())
+
+	// Wait to get the messages.
+	select {
+	case <-gotMsgs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not receive all expected messages")
+	}
+}
+
+// Ensure subscriptions requesting their position receive the delivered offset
+// and partition HW with each message.
+func TestSubscribePosition(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	// Publish some messages.
+	num := 5
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	// Subscribe from the beginning requesting the subscription position.
+	recv := 0
+	gotMsgs := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = grpcMetadata.AppendToOutgoingContext(ctx, PositionMetadataKey, "true")
+	client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		delivered, err := strconv.ParseInt(string(msg.Headers()[DeliveredOffsetHeader]), 10, 64)
+		require.NoError(t, err)
+		hw, err := strconv.ParseInt(string(msg.Headers()[HighWatermarkHeader]), 10, 64)
+		require.NoError(t, err)
+		require.Equal(t, int64(recv), delivered)
+		require.Equal(t, int64(num-1), hw)
+		require.True(t, hw >= delivered)
+		recv++
+		if recv == num {
+			close(gotMsgs)
+		}
+	}, lift.StartAtEarliestReceived())
+
+	// Wait to get the messages.
+	select {
+	case <-gotMsgs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not receive all expected messages")
+	}
+}
+
+// Ensure subscriptions requesting the catch-up signal receive a marker after
+// the messages which existed when subscribing and before new ones.
+func TestSubscribeCatchUp(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	// Publish some messages.
+	num := 5
+	publish := func(i int) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		require.NoError(t, err)
+	}
+	for i := 0; i < num; i++ {
+		publish(i)
+	}
+
+	// Subscribe from the beginning requesting the catch-up signal.
+	msgs := make(chan lift.Message, 2*num)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = grpcMetadata.AppendToOutgoingContext(ctx, CatchUpMetadataKey, "true")
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		msgs <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+
+	next := func() lift.Message {
+		select {
+		case msg := <-msgs:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("Did not receive expected message")
+		}
+		return nil
+	}
+
+	// The existing messages are followed by the marker.
+	for i := 0; i < num; i++ {
+		msg := next()
+		require.Equal(t, int64(i), msg.Offset())
+		require.Nil(t, msg.Headers()[CaughtUpHeader])
+	}
+	marker := next()
+	require.Equal(t, []byte("true"), marker.Headers()[CaughtUpHeader])
+	require.Equal(t, int64(num-1), marker.Offset())
+	require.Empty(t, marker.Value())
+
+	// New messages are delivered after the marker without another one.
+	publish(num)
+	msg := next()
+	require.Equal(t, int64(num), msg.Offset())
+	require.Nil(t, msg.Headers()[CaughtUpHeader])
+}
+
+// Ensure a snapshot subscription on a compacted stream delivers only the
+// latest message per key of the history, signaling when the snapshot is
+// complete, and then delivers new messages as they are written.
+func TestSubscribeSnapshot(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.Compact = true
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	publish := func(key, value string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		opts := []lift.MessageOption{lift.AckPolicyAll()}
+		if key != "" {
+			opts = append(opts, lift.Key([]byte(key)))
+		}
+		_, err := client.Publish(ctx, name, []byte(value), opts...)
+		require.NoError(t, err)
+	}
+
+	// Publish a message without a key followed by several updates to each
+	// key.
+	keys := []string{"a", "b", "c"}
+	publish("", "none")
+	for i := 0; i < 3; i++ {
+		for _, key := range keys {
+			publish(key, key+strconv.Itoa(i))
+		}
+	}
+
+	// Subscribe to a snapshot from the beginning.
+	msgs := make(chan lift.Message, 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = grpcMetadata.AppendToOutgoingContext(ctx,
+		SnapshotMetadataKey, "true",
+		CatchUpMetadataKey, "true")
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		msgs <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+
+	next := func() lift.Message {
+		select {
+		case msg := <-msgs:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("Did not receive expected message")
+		}
+		return nil
+	}
+
+	// The snapshot has the message without a key and one message per key.
+	msg := next()
+	require.Equal(t, int64(0), msg.Offset())
+	require.Equal(t, []byte("none"), msg.Value())
+	for i, key := range keys {
+		msg := next()
+		require.Equal(t, int64(7+i), msg.Offset())
+		require.Equal(t, []byte(key), msg.Key())
+		require.Equal(t, []byte(key+"2"), msg.Value())
+	}
+	marker := next()
+	require.Equal(t, []byte("true"), marker.Headers()[CaughtUpHeader])
+	require.Equal(t, int64(9), marker.Offset())
+
+	// Live updates are delivered even for keys already in the snapshot.
+	publish("a", "a3")
+	msg = next()
+	require.Equal(t, int64(10), msg.Offset())
+	require.Equal(t, []byte("a3"), msg.Value())
+}
+
+// Ensure a subscription requesting control messages receives a leader change
+// signal when the partition fails over and continues delivering messages.
+func TestSubscribeControlLeaderChange(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers.
+	servers := make([]*Server, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		servers[i] = runServerWithConfig(t, getTestConfig(id, i == 0, 5050+i))
+		defer servers[i].Stop()
+	}
+
+	metadataLeader := getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+
+	publish := func(i int) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		require.NoError(t, err)
+	}
+	publish(0)
+
+	// Subscribe requesting control messages.
+	msgs := make(chan lift.Message, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = grpcMetadata.AppendToOutgoingContext(ctx, ControlMetadataKey, "true")
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		msgs <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+
+	next := func() lift.Message {
+		select {
+		case msg := <-msgs:
+			return msg
+		case <-time.After(10 * time.Second):
+			t.Fatal("Did not receive expected message")
+		}
+		return nil
+	}
+
+	msg := next()
+	require.Equal(t, int64(0), msg.Offset())
+	require.Nil(t, msg.Headers()[ControlHeader])
+
+	// Fail over the partition to another replica.
+	partition := metadataLeader.metadata.GetPartition(name, 0)
+	_, epoch := partition.GetLeader()
+	require.Nil(t, metadataLeader.metadata.electNewPartitionLeader(partition))
+	var followers []*Server
+	for _, s := range servers {
+		if s != leader {
+			followers = append(followers, s)
+		}
+	}
+	newLeader := getPartitionLeader(t, 10*time.Second, name, 0, followers...)
+
+	// The subscription receives the leader change.
+	control := next()
+	require.Equal(t, []byte(ControlLeaderChanged), control.Headers()[ControlHeader])
+	require.Equal(t, []byte(newLeader.config.Clustering.ServerID), control.Headers()[LeaderHeader])
+	newEpoch, err := strconv.ParseUint(string(control.Headers()[LeaderEpochHeader]), 10, 64)
+	require.NoError(t, err)
+	require.True(t, newEpoch > epoch)
+	require.Equal(t, int64(0), control.Offset())
+	require.Empty(t, control.Value())
+
+	// The subscription continues delivering new messages.
+	publish(1)
+	msg = next()
+	require.Equal(t, int64(1), msg.Offset())
+	require.Equal(t, []byte("1"), msg.Value())
+}
+
+// Ensure a subscription following multiple streams receives messages from
+// each of them tagged with their source stream and in order per stream.
+func TestSubscribeMultipleStreams(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create streams and publish some messages to each.
+	streams := []string{"foo", "bar", "baz"}
+	num := 5
+	for _, name := range streams {
+		err = client.CreateStream(context.Background(), name, name)
+		require.NoError(t, err)
+		for i := 0; i < num; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_, err = client.Publish(ctx, name, []byte(fmt.Sprintf("%s-%d", name, i)), lift.AckPolicyAll())
+			cancel()
+			require.NoError(t, err)
+		}
+	}
+
+	// Following a stream which does not exist fails.
+	errC := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = grpcMetadata.AppendToOutgoingContext(ctx, SubscribeStreamsMetadataKey, "qux")
+	err = client.Subscribe(ctx, "foo", func(msg lift.Message, err error) {
+		errC <- err
+	})
+	if err == nil {
+		select {
+		case err = <-errC:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected subscription error")
+		}
+	}
+	require.Error(t, err)
+
+	// Subscribe to all three streams at once.
+	var (
+		mu      sync.Mutex
+		next    = make(map[string]int)
+		recv    = 0
+		gotMsgs = make(chan struct{})
+	)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	ctx = grpcMetadata.AppendToOutgoingContext(ctx,
+		SubscribeStreamsMetadataKey, "bar", SubscribeStreamsMetadataKey, "baz")
+	err = client.Subscribe(ctx, "foo", func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		stream := string(msg.Headers()[StreamHeader])
+		expected := next[stream]
+		require.Equal(t, int64(expected), msg.Offset())
+		require.Equal(t, []byte(fmt.Sprintf("%s-%d", stream, expected)), msg.Value())
+		next[stream]++
+		recv++
+		if recv == num*len(streams) {
+			close(gotMsgs)
+		}
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+
+	// Wait to get the messages.
+	select {
+	case <-gotMsgs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Did not receive all expected messages")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range streams {
+		require.Equal(t, num, next[name])
+	}
+}
+
+// Ensure scheduled messages are not delivered to subscribers until their
+// delivery time and that later messages are held back behind them.
+func TestSubscribeDeliverAt(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	// Invalid delivery times are rejected.
+	for _, deliverAt := range []string{"tomorrow", "-1"} {
+		ctx := grpcMetadata.AppendToOutgoingContext(context.Background(), DeliverAtMetadataKey, deliverAt)
+		_, err = client.Publish(ctx, name, []byte("x"))
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	// Publish a message, a message scheduled 2 seconds out, and another
+	// message.
+	deliverAt := time.Now().Add(2 * time.Second)
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if i == 1 {
+			ctx = DeliverAt(ctx, deliverAt)
+		}
+		_, err = client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	received := make(chan time.Time, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		received <- time.Now()
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+
+	// The first message is delivered right away.
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("Did not receive expected message")
+	}
+
+	// The scheduled message and the one after it are delivered only once
+	// the delivery time has passed.
+	for i := 1; i < 3; i++ {
+		select {
+		case at := <-received:
+			require.True(t, at.UnixNano() >= deliverAt.UnixNano(), "message %d delivered early", i)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Did not receive expected message")
+		}
+	}
+}
+
+// Ensure delivery can't be scheduled further out than the configured max
+// delivery delay, whether through the API or directly on NATS, and that such
+// messages don't hold up the messages after them.
+func TestSubscribeDeliverAtMaxDelay(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.MaxDeliveryDelay = time.Hour
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	// Delivery times beyond the max delay are rejected by the API.
+	deliverAt := time.Now().Add(24 * time.Hour)
+	_, err = client.Publish(DeliverAt(context.Background(), deliverAt), name, []byte("x"))
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Publish a message scheduled beyond the max delay directly to NATS,
+	// followed by another message.
+	nc, err := nats.Connect(nats.DefaultURL)
+	require.NoError(t, err)
+	defer nc.Close()
+	data, err := proto.MarshalPublish(&liftApi.Message{
+		Value:   []byte("0"),
+		Headers: map[string][]byte{DeliverAtHeader: []byte(strconv.FormatInt(deliverAt.UnixNano(), 10))},
+	})
+	require.NoError(t, err)
+	require.NoError(t, nc.Publish(subject, data))
+	require.NoError(t, nc.Flush())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err = client.Publish(ctx, name, []byte("1"), lift.AckPolicyLeader())
+	cancel()
+	require.NoError(t, err)
+
+	// The leader ignored the schedule, so both messages are delivered right
+	// away and in order.
+	received := make(chan lift.Message, 2)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		received <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-received:
+			require.Equal(t, []byte(strconv.Itoa(i)), msg.Value())
+			_, ok := msg.Headers()[DeliverAtHeader]
+			require.False(t, ok)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Did not receive expected message")
+		}
+	}
+}
+
+// Ensure VerifyStream reports corrupt offsets in a stream's commit log.
+func TestVerifyStream(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Verifying a nonexistent stream fails.
+	_, err = s1.VerifyStream("foo")
+	require.Equal(t, ErrStreamNotFound, err)
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	// Publish some messages.
+	num := 5
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	// The log should be healthy.
+	reports, err := s1.VerifyStream(name)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	require.True(t, reports[0].Healthy())
+	require.Equal(t, int64(num), reports[0].Messages)
+
+	// Corrupt the last message by flipping the last byte of the segment.
+	file, err := os.OpenFile(filepath.Join(s1Config.DataDir, "streams", name, "0",
+		"00000000000000000000.log"), os.O_RDWR, 0666)
+	require.NoError(t, err)
+	info, err := file.Stat()
+	require.NoError(t, err)
+	b := make([]byte, 1)
+	_, err = file.ReadAt(b, info.Size()-1)
+	require.NoError(t, err)
+	_, err = file.WriteAt([]byte{^b[0]}, info.Size()-1)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reports, err = s1.VerifyStream(name)
+	require.NoError(t, err)
+	require.False(t, reports[0].Healthy())
+	require.Equal(t, []commitlog.OffsetRange{{Start: 4, End: 4}}, reports[0].Corrupt)
+	require.Empty(t, reports[0].Missing)
+	require.Equal(t, int64(num-1), reports[0].Messages)
+}
+
+// Ensure CheckpointStream makes the messages through the returned offset
+// durable, so a snapshot of the partition's data taken afterwards recovers
+// them even when writes are buffered.
+func TestCheckpointStream(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server with buffered writes.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.WriteBufferSize = commitlog.MaxWriteBufferSize
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Checkpointing a nonexistent stream fails.
+	_, err = s1.CheckpointStream("foo")
+	require.Equal(t, ErrStreamNotFound, err)
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+
+	// Publish some messages.
+	num := 5
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	offsets, err := s1.CheckpointStream(name)
+	require.NoError(t, err)
+	require.Equal(t, map[int32]int64{0: int64(num - 1)}, offsets)
+
+	// Copy the partition's files as they are on disk, as if the server had
+	// crashed, and recover a log from the copy.
+	dir := filepath.Join(s1Config.DataDir, "streams", name, "0")
+	snapshot := filepath.Join(storagePath, "snapshot")
+	require.NoError(t, os.MkdirAll(snapshot, os.ModePerm))
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(snapshot, file.Name()), data, 0666))
+	}
+	recovered, err := commitlog.New(commitlog.Options{Path: snapshot})
+	require.NoError(t, err)
+	defer recovered.Close()
+	require.Equal(t, offsets[0], recovered.NewestOffset())
+	require.Equal(t, offsets[0], recovered.HighWatermark())
+
+	reader, err := recovered.NewReader(0, false)
+	require.NoError(t, err)
+	defer reader.Close()
+	headers := make([]byte, 28)
+	for i := 0; i < num; i++ {
+		msg, offset, _, _, err := reader.ReadMessage(context.Background(), headers)
+		require.NoError(t, err)
+		require.Equal(t, int64(i), offset)
+		require.Equal(t, []byte(strconv.Itoa(i)), msg.Value())
+	}
+}
+
+// Ensure PartitionLeader reports the leader of a specific partition and that
+// publishing directly to that partition only writes to it.
+func TestPartitionLeaderTargetedPublish(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1, s2)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Querying a nonexistent stream fails.
+	_, _, err = s1.PartitionLeader("foo", 1)
+	require.Equal(t, ErrStreamNotFound, err)
+
+	// Create stream with three partitions.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name, lift.Partitions(3))
+	require.NoError(t, err)
+
+	// Querying a nonexistent partition fails.
+	_, _, err = s1.PartitionLeader(name, 3)
+	require.Equal(t, ErrPartitionNotFound, err)
+
+	// Both servers agree on the leader of partition 1.
+	leader := getPartitionLeader(t, 10*time.Second, name, 1, s1, s2)
+	for _, s := range []*Server{s1, s2} {
+		id, epoch, err := s.PartitionLeader(name, 1)
+		require.NoError(t, err)
+		require.Equal(t, leader.config.Clustering.ServerID, id)
+		require.NotZero(t, epoch)
+	}
+
+	// Publish directly to partition 1.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ack, err := client.Publish(ctx, name, []byte("hello"), lift.ToPartition(1),
+		lift.AckPolicyLeader())
+	require.NoError(t, err)
+	require.Equal(t, int64(0), ack.Offset())
+
+	// Only partition 1 has the message.
+	for id := int32(0); id < 3; id++ {
+		partitionLeader := getPartitionLeader(t, 10*time.Second, name, id, s1, s2)
+		partition := partitionLeader.metadata.GetPartition(name, id)
+		expected := int64(-1)
+		if id == 1 {
+			expected = 0
+		}
+		require.Equal(t, expected, partition.log.NewestOffset())
+	}
+}
+
+// Ensure ImportMessages appends pre-framed messages in order with their keys,
+// headers, and timestamps intact and rejects malformed data.
+func TestImportMessages(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Importing into a nonexistent stream fails.
+	_, err = s1.ImportMessages(context.Background(), "foo", 0, nil)
+	require.Equal(t, ErrStreamNotFound, err)
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+	waitForPartition(t, 5*time.Second, name, 0, s1)
+
+	// Import a batch of historical messages.
+	num := 10
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	msgs := make([]*commitlog.Message, num)
+	for i := 0; i < num; i++ {
+		msgs[i] = &commitlog.Message{
+			MagicByte: 1,
+			Key:       []byte("key-" + strconv.Itoa(i)),
+			Value:     []byte(strconv.Itoa(i)),
+			Headers:   map[string][]byte{"source": []byte("legacy")},
+			Timestamp: start.Add(time.Duration(i) * time.Second).UnixNano(),
+		}
+	}
+	data, err := commitlog.EncodeMessageSet(msgs)
+	require.NoError(t, err)
+
+	// Malformed data is rejected without appending anything.
+	_, err = s1.ImportMessages(context.Background(), name, 0, data[:len(data)-1])
+	require.Error(t, err)
+
+	offsets, err := s1.ImportMessages(context.Background(), name, 0, data)
+	require.NoError(t, err)
+	require.Len(t, offsets, num)
+	for i, offset := range offsets {
+		require.Equal(t, int64(i), offset)
+	}
+
+	// Read the messages back.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	recv := make(chan lift.Message, num)
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		recv <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+	for i := 0; i < num; i++ {
+		select {
+		case msg := <-recv:
+			require.Equal(t, int64(i), msg.Offset())
+			require.Equal(t, msgs[i].Key, msg.Key())
+			require.Equal(t, msgs[i].Value, msg.Value())
+			require.Equal(t, []byte("legacy"), msg.Headers()["source"])
+			require.Equal(t, msgs[i].Timestamp, msg.Timestamp().UnixNano())
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Did not receive message %d", i)
+		}
+	}
+}
+
+// Ensure ImportMessages raises timestamps earlier than the partition's latest
+// to it so timestamps stay monotonic and timestamp lookups stay correct.
+func TestImportMessagesEarlierTimestamp(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name)
+	require.NoError(t, err)
+	waitForPartition(t, 5*time.Second, name, 0, s1)
+	partition := s1.metadata.GetPartition(name, 0)
+
+	publish := func() int64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := client.Publish(ctx, name, []byte("published"), lift.AckPolicyLeader())
+		require.NoError(t, err)
+		return partition.log.NewestTimestamp()
+	}
+
+	// Import a historical message between two published ones.
+	first := publish()
+	data, err := commitlog.EncodeMessageSet([]*commitlog.Message{{
+		MagicByte: 1,
+		Value:     []byte("imported"),
+		Timestamp: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano(),
+	}})
+	require.NoError(t, err)
+	offsets, err := s1.ImportMessages(context.Background(), name, 0, data)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1}, offsets)
+	require.Equal(t, first, partition.log.NewestTimestamp())
+	last := publish()
+	require.True(t, last > first)
+
+	// Lookups find the first message at or after the timestamp.
+	offset, err := partition.log.OffsetForTimestamp(first)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), offset)
+	offset, err = partition.log.OffsetForTimestamp(first + 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), offset)
+}
+
+// Ensure ImportMessages rejects a batch containing an invalid message as a
+// whole by default and appends the valid messages in best-effort mode.
+func TestImportMessagesBestEffort(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		RequireKeyMetadataKey, "true")
+	require.NoError(t, client.CreateStream(ctx, "foo", name))
+	waitForPartition(t, 5*time.Second, name, 0, s1)
+
+	// The second message has no key.
+	data, err := commitlog.EncodeMessageSet([]*commitlog.Message{
+		{MagicByte: 1, Key: []byte("a"), Value: []byte("0")},
+		{MagicByte: 1, Value: []byte("1")},
+		{MagicByte: 1, Key: []byte("c"), Value: []byte("2")},
+	})
+	require.NoError(t, err)
+
+	// By default, nothing is appended.
+	_, err = s1.ImportMessages(context.Background(), name, 0, data)
+	require.Error(t, err)
+	partition := s1.metadata.GetPartition(name, 0)
+	require.Equal(t, int64(-1), partition.log.NewestOffset())
+
+	// In best-effort mode, the valid messages are appended.
+	bestEffortCtx := grpcMetadata.NewIncomingContext(context.Background(),
+		grpcMetadata.Pairs(BatchBestEffortMetadataKey, "true"))
+	offsets, err := s1.ImportMessages(bestEffortCtx, name, 0, data)
+	require.Equal(t, []int64{0, -1, 1}, offsets)
+	batchErr, ok := err.(*BatchError)
+	require.True(t, ok)
+	require.Len(t, batchErr.Errors, 1)
+	require.Contains(t, batchErr.Errors[1].Error(), "has no key")
+	require.Equal(t, int64(1), partition.log.NewestOffset())
+
+	// A valid batch imported in best-effort mode doesn't return an error.
+	data, err = commitlog.EncodeMessageSet([]*commitlog.Message{
+		{MagicByte: 1, Key: []byte("d"), Value: []byte("3")},
+	})
+	require.NoError(t, err)
+	offsets, err = s1.ImportMessages(bestEffortCtx, name, 0, data)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2}, offsets)
+}
+
+// Ensure clients can connect with TLS when enabled.
+func TestTLS(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server with TLS.
+	s1Config, err := NewConfig("./configs/tls.yaml")
+	require.NoError(t, err)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	// Connect with TLS.
+	client, err := lift.Connect([]string{"localhost:5050"}, lift.TLSCert("./configs/certs/server.crt"))
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Connecting without a cert should fail.
+	_, err = lift.Connect([]string{"localhost:5050"})
+	require.Error(t, err)
+}
+
+// Ensure that the host address is the same as the listen address when
+// specifying only the latter
+func TestListen(t *testing.T) {
+	config, err := NewConfig("./configs/listen.yaml")
+	require.NoError(t, err)
+
+	ex := HostPort{
+		Host: "192.168.0.1",
+		Port: 4222,
+	}
+
+	r := config.GetListenAddress()
+	if r != ex {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
+	}
+
+	r = config.GetConnectionAddress()
+	if r != ex {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
+	}
+}
+
+// Ensure that the listen address is the same as the host address when
+// specifying only the latter
+func TestHost(t *testing.T) {
+	config, err := NewConfig("./configs/host.yaml")
+	require.NoError(t, err)
+
+	ex := HostPort{
+		Host: "192.168.0.1",
+		Port: 4222,
+	}
+
+	r := config.GetListenAddress()
+	if r != ex {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
+	}
+
+	r = config.GetConnectionAddress()
+	if r != ex {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
+	}
+}
+
+// Ensure that the listen and connection addresses have the expected values
+// when specifying both
+func TestListenHost(t *testing.T) {
+	config, err := NewConfig("./configs/listen-host.yaml")
+	require.NoError(t, err)
+
+	ex := HostPort{
+		Host: "192.168.0.1",
+		Port: 4222,
+	}
+
+	r := config.GetListenAddress()
+	if r != ex {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
+	}
+
+	ex = HostPort{
+		Host: "my-host",
+		Port: 4333,
+	}
+
+	r = config.GetConnectionAddress()
+	if r != ex {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
+	}
+}
+
+// Ensure that the listen and connection addresses have the expected default
+// values
+func TestDefaultListenHost(t *testing.T) {
+	config := NewDefaultConfig()
+
+	ex := HostPort{
+		Host: defaultListenAddress,
+		Port: DefaultPort,
+	}
+
+	r := config.GetListenAddress()
+	if r != ex {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
+	}
+
+	ex = HostPort{
+		Host: defaultConnectionAddress,
+		Port: DefaultPort,
+	}
+
+	r = config.GetConnectionAddress()
+	if r != ex {
+		t.Fatalf("Not Equal:\nReceived: '%+v'\nExpected: '%+v'\n", r, ex)
+	}
+}
+
+// Ensure the leader flag is set when the server is elected metadata leader and
+// unset when it loses leadership.
+func TestMetadataLeadershipLifecycle(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 0)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 0)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	// Check leader flag is set.
+	require.Equal(t, int64(1), atomic.LoadInt64(&(s1.getRaft().leader)))
+
+	// Kill the follower.
+	s2.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		leader := atomic.LoadInt64(&(s1.getRaft().leader))
+		if leader == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		continue
+	}
+	t.Fatal("Expected leader flag to be 0")
+}
+
+// Ensure propagation handlers for shrinking and expanding the ISR work
+// correctly.
+func TestPropagatedShrinkExpandISR(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 0)
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Wait for server to elect itself leader.
+	controller := getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	err = client.CreateStream(context.Background(), subject, name,
 		lift.ReplicationFactor(2))
 	require.NoError(t, err)
 
@@ -1332,6 +2749,91 @@ func TestPropagatedShrinkExpandISR(t *testing.T) {
 	waitForISR(t, 10*time.Second, name, 0, 2, s1, s2)
 }
 
+// Ensure the under-replicated stream count increases when a follower fails
+// and the ISR shrinks.
+func TestUnderReplicatedStreams(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure first server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Clustering.ReplicaMaxLagTime = time.Second
+	s1Config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Configure second server.
+	s2Config := getTestConfig("b", false, 5051)
+	s2Config.Clustering.ReplicaMaxLagTime = time.Second
+	s2Config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+	s2 := runServerWithConfig(t, s2Config)
+	defer s2.Stop()
+
+	// Configure third server.
+	s3Config := getTestConfig("c", false, 5052)
+	s3Config.Clustering.ReplicaMaxLagTime = time.Second
+	s3Config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+	s3 := runServerWithConfig(t, s3Config)
+	defer s3.Stop()
+
+	servers := []*Server{s1, s2, s3}
+	getMetadataLeader(t, 10*time.Second, servers...)
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Create stream.
+	name := "foo"
+	subject := "foo"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err = client.CreateStream(ctx, subject, name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+
+	// The count is also reported in the FetchMetadata response headers.
+	fetchUnderReplicated := func(s *Server) []string {
+		conn, err := grpc.Dial(fmt.Sprintf("localhost:%d", s.config.Port), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+		md := grpcMetadata.MD{}
+		_, err = liftApi.NewAPIClient(conn).FetchMetadata(context.Background(),
+			&liftApi.FetchMetadataRequest{}, grpc.Header(&md))
+		require.NoError(t, err)
+		return md.Get(UnderReplicatedStreamsMetadataKey)
+	}
+
+	waitForPartition(t, 10*time.Second, name, 0, servers...)
+	waitForISR(t, 10*time.Second, name, 0, 3, servers...)
+	for _, s := range servers {
+		require.Equal(t, 0, s.UnderReplicatedStreams())
+		require.Equal(t, []string{"0"}, fetchUnderReplicated(s))
+	}
+
+	// Kill a stream follower.
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	var follower *Server
+	for i, server := range servers {
+		if server != leader {
+			follower = server
+			servers = append(servers[:i], servers[i+1:]...)
+			break
+		}
+	}
+	follower.Stop()
+
+	// Eventually, the ISR should shrink and the stream should be reported as
+	// under-replicated.
+	waitForISR(t, 10*time.Second, name, 0, 2, servers...)
+	for _, s := range servers {
+		require.Equal(t, 1, s.UnderReplicatedStreams())
+		require.Equal(t, []string{"1"}, fetchUnderReplicated(s))
+	}
+}
+
 // Ensure activity stream partition creation event occurs
 func TestActivityStreamCreatePartition(t *testing.T) {
 	defer cleanupStorage(t)
@@ -1608,3 +3110,283 @@ func TestPublishNoSuchPartition(t *testing.T) {
 	_, err = client.Publish(context.Background(), name, []byte("hello"), lift.ToPartition(42))
 	require.Error(t, err)
 }
+
+// Ensure messages NATS drops on a partition leader's subscription because the
+// leader is a slow consumer are reported and ingest is paused for the
+// configured time.
+func TestIngestSlowConsumerDrop(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server with a tiny pending limit on ingest subscriptions so
+	// that a burst of messages overflows it.
+	drops := make(chan IngestDrop, 100)
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.IngestMaxPendingMessages = 1
+	s1Config.Streams.IngestDropPause = 200 * time.Millisecond
+	s1Config.Streams.OnIngestDrop = func(drop IngestDrop) {
+		drops <- drop
+	}
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = client.CreateStream(context.Background(), "foo", "foo")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), s1.IngestDroppedMessages())
+
+	nc, err := nats.Connect(nats.DefaultURL)
+	require.NoError(t, err)
+	defer nc.Close()
+
+	// Publish bursts directly to NATS until messages are dropped.
+	var drop IngestDrop
+	deadline := time.Now().Add(10 * time.Second)
+LOOP:
+	for {
+		for i := 0; i < 10000; i++ {
+			require.NoError(t, nc.Publish("foo", []byte("hello")))
+		}
+		require.NoError(t, nc.Flush())
+		select {
+		case drop = <-drops:
+			break LOOP
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected messages to be dropped")
+		}
+	}
+
+	require.Equal(t, "foo", drop.Stream)
+	require.Equal(t, int32(0), drop.Partition)
+	require.True(t, drop.Dropped > 0)
+	require.True(t, drop.Total >= drop.Dropped)
+	require.True(t, s1.IngestDroppedMessages() >= drop.Total)
+
+	desc, err := s1.DescribeStream("foo")
+	require.NoError(t, err)
+	require.True(t, desc.Partitions[0].IngestDropped >= drop.Total)
+
+	// Ingest is paused and then resumed.
+	partition := s1.metadata.GetPartition("foo", 0)
+	partition.mu.RLock()
+	paused := partition.sub == nil
+	partition.mu.RUnlock()
+	require.True(t, paused)
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		partition.mu.RLock()
+		resumed := partition.sub != nil
+		partition.mu.RUnlock()
+		if resumed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected ingest to resume")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Ensure stream data is spread across shard directories when DirShards is set
+// and every stream is recovered on restart, including after the number of
+// shards changes.
+func TestStreamDirShards(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.DirShards = 4
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	num := 20
+	for i := 0; i < num; i++ {
+		name := fmt.Sprintf("stream-%d", i)
+		err = client.CreateStream(context.Background(), name, name)
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte("hello"), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	// Returns the number of streams in each shard directory.
+	shardCounts := func() map[string]int {
+		dirs, err := ioutil.ReadDir(s1.streamShardsDir())
+		require.NoError(t, err)
+		counts := make(map[string]int)
+		for _, dir := range dirs {
+			streams, err := ioutil.ReadDir(filepath.Join(s1.streamShardsDir(), dir.Name()))
+			require.NoError(t, err)
+			counts[dir.Name()] = len(streams)
+		}
+		return counts
+	}
+	// Ensures every stream was recovered with its message.
+	checkRecovered := func(s *Server) {
+		for i := 0; i < num; i++ {
+			name := fmt.Sprintf("stream-%d", i)
+			waitForPartition(t, 10*time.Second, name, 0, s)
+			partition := s.metadata.GetPartition(name, 0)
+			require.Equal(t, int64(0), partition.log.NewestOffset())
+		}
+	}
+
+	counts := shardCounts()
+	require.Len(t, counts, 4)
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	require.Equal(t, num, total)
+
+	// Restart the server.
+	s1.Stop()
+	s1 = runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+	getMetadataLeader(t, 10*time.Second, s1)
+	checkRecovered(s1)
+
+	// Restart the server with more shards. The streams are moved to their
+	// new shards.
+	s1.Stop()
+	s1Config.Streams.DirShards = 8
+	s1 = runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+	getMetadataLeader(t, 10*time.Second, s1)
+	checkRecovered(s1)
+
+	counts = shardCounts()
+	total = 0
+	for _, count := range counts {
+		total += count
+	}
+	require.Equal(t, num, total)
+	for i := 0; i < num; i++ {
+		_, err := os.Stat(s1.streamDataDir(fmt.Sprintf("stream-%d", i)))
+		require.NoError(t, err)
+	}
+}
+
+// Ensure the data of a stream is never mistaken for the data of another one
+// when looking for it under a different layout, whatever the streams are
+// named.
+func TestStreamDirShardsNameCollision(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server without sharding.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// A stream whose data directory looks like a shard directory and a
+	// stream named like one of its partition directories.
+	for _, name := range []string{"shard-0", "0"} {
+		err = client.CreateStream(context.Background(), name, name)
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err = client.Publish(ctx, name, []byte(name), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+	}
+
+	// Restart the server with sharding, then without it again.
+	for _, shards := range []int{1, 0} {
+		s1.Stop()
+		s1Config.Streams.DirShards = shards
+		s1 = runServerWithConfig(t, s1Config)
+		defer s1.Stop()
+		getMetadataLeader(t, 10*time.Second, s1)
+		for _, name := range []string{"shard-0", "0"} {
+			waitForPartition(t, 10*time.Second, name, 0, s1)
+			partition := s1.metadata.GetPartition(name, 0)
+			require.Equal(t, int64(0), partition.log.NewestOffset(), name)
+			_, err := os.Stat(filepath.Join(s1.streamDataDir(name), "0"))
+			require.NoError(t, err, name)
+		}
+	}
+}
+
+// Ensure the OnSegmentRoll hook is invoked with the stream and partition of
+// each rolled segment, in roll order.
+func TestOnSegmentRoll(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server to roll a segment for every message.
+	rolls := make(chan SegmentRoll, 10)
+	s1Config := getTestConfig("a", true, 5050)
+	s1Config.Streams.SegmentMaxBytes = 1
+	s1Config.BatchMaxMessages = 1
+	s1Config.Streams.OnSegmentRoll = func(roll SegmentRoll) {
+		rolls <- roll
+	}
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = client.CreateStream(context.Background(), "foo", "foo")
+	require.NoError(t, err)
+
+	// Publish some messages.
+	num := 3
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = client.Publish(ctx, "foo", []byte("hello"))
+		require.NoError(t, err)
+	}
+
+	// Each message but the last one is in a rolled segment.
+	for i := int64(0); i < int64(num-1); i++ {
+		select {
+		case roll := <-rolls:
+			require.Equal(t, "foo", roll.Stream)
+			require.Equal(t, int32(0), roll.Partition)
+			require.Equal(t, i, roll.FirstOffset)
+			require.Equal(t, i, roll.LastOffset)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected segment roll")
+		}
+	}
+}
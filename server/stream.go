@@ -80,12 +80,14 @@ func (s *stream) SetPartition(id int32, p *partition) {
 func (s *stream) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	// Close every partition even if one fails so none are left running.
+	var closeErr error
 	for _, partition := range s.partitions {
-		if err := partition.Close(); err != nil {
-			return err
+		if err := partition.Close(); err != nil && closeErr == nil {
+			closeErr = err
 		}
 	}
-	return nil
+	return closeErr
 }
 
 // Pause some or all the partitions of this stream.
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// moveMarkerFile is the file in a stream data directory recording the index of
+// the last Raft entry which moved the directory to its current name, i.e. a
+// rename or an exchange with another stream's directory. It's used to tell
+// which moves were already done when the Raft log is replayed.
+const moveMarkerFile = "moved"
+
+// swapTagFile is the file in a stream data directory recording the index of the
+// Raft entry exchanging it with another stream's while the exchange is in
+// progress.
+const swapTagFile = "swapping"
+
+// streamsDataDir returns the root directory containing stream data.
+func (s *Server) streamsDataDir() string {
+	return filepath.Join(s.config.DataDir, "streams")
+}
+
+// streamShardsDir returns the root directory containing the shard directories
+// streams are spread across when Streams.DirShards is set. It's outside the
+// streams data directory so that a shard directory can't be mistaken for the
+// data of a stream or the other way around.
+func (s *Server) streamShardsDir() string {
+	return filepath.Join(s.config.DataDir, "stream-shards")
+}
+
+// streamSwapDir returns the directory a stream's data is moved through while
+// exchanging it with another stream's. It's outside the streams data directory
+// so that it can't be mistaken for the data of a stream.
+func (s *Server) streamSwapDir() string {
+	return filepath.Join(s.config.DataDir, "streams-swap")
+}
+
+// streamDataDir returns the directory containing the data for the given
+// stream. If Streams.DirShards is set, streams are spread across that many
+// shard directories by the hash of their name to keep directories small.
+func (s *Server) streamDataDir(stream string) string {
+	root := s.streamsDataDir()
+	if shards := s.config.Streams.DirShards; shards > 0 {
+		h := fnv.New32a()
+		h.Write([]byte(stream))
+		root = filepath.Join(s.streamShardsDir(), strconv.FormatUint(uint64(h.Sum32()%uint32(shards)), 10))
+	}
+	return filepath.Join(root, stream)
+}
+
+// migrateStreamDataDir moves the data for the given stream to its directory
+// under the configured layout if it was written under a different one, e.g.
+// because Streams.DirShards was changed on an existing data directory.
+func (s *Server) migrateStreamDataDir(stream string) error {
+	dir := s.streamDataDir(stream)
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	candidates := []string{filepath.Join(s.streamsDataDir(), stream)}
+	entries, err := ioutil.ReadDir(s.streamShardsDir())
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to read stream shards directory")
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			candidates = append(candidates, filepath.Join(s.streamShardsDir(), entry.Name(), stream))
+		}
+	}
+	for _, old := range candidates {
+		if old == dir {
+			continue
+		}
+		if _, err := os.Stat(old); err != nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dir), os.ModePerm); err != nil {
+			return errors.Wrap(err, "failed to create stream shard directory")
+		}
+		if err := os.Rename(old, dir); err != nil {
+			return errors.Wrap(err, "failed to move stream data directory")
+		}
+		s.logger.Infof("Moved data for stream %s from %s to %s", stream, old, dir)
+		return nil
+	}
+	return nil
+}
+
+// renameStreamDataDir moves the given stream data directory to the new one and
+// records the index of the Raft entry doing so in it. The caller must ensure no
+// stream has the new name, so anything already there is left over, e.g. from
+// replaying the creation of a stream which was later renamed away, and is
+// removed. If recovered is true, the rename may have been done before the Raft
+// log was replayed, and replaying earlier entries may have created either
+// directory again, so the rename is skipped unless the data is still only
+// under the old name.
+func renameStreamDataDir(oldDir, newDir string, index uint64, recovered bool) error {
+	if recovered {
+		if moveIndex(newDir) >= index {
+			return nil
+		}
+		if _, err := os.Stat(oldDir); err != nil {
+			return nil
+		}
+		if _, err := os.Stat(newDir); err == nil {
+			return nil
+		}
+	} else if err := os.RemoveAll(newDir); err != nil {
+		return errors.Wrap(err, "failed to remove stale stream data directory")
+	}
+	if err := os.MkdirAll(filepath.Dir(newDir), os.ModePerm); err != nil {
+		return errors.Wrap(err, "failed to create stream shard directory")
+	}
+	if err := os.Rename(oldDir, newDir); err != nil {
+		return errors.Wrap(err, "failed to rename stream data directory")
+	}
+	return writeMoveIndex(newDir, index)
+}
+
+// swapStreamDataDirs exchanges the given stream data directories and records
+// the index of the Raft entry doing so in both. The exchange takes three
+// renames, moving dir through the tmp directory, so dir is first tagged with
+// the index to tell where a crash interrupted it. When the entry is replayed,
+// an interrupted exchange is finished. If recovered is true and either
+// directory already records the index or a later one, the exchange was
+// already done before the Raft log was replayed and is skipped.
+func swapStreamDataDirs(dir, otherDir, tmp string, index uint64, recovered bool) error {
+	if recovered && (moveIndex(dir) >= index || moveIndex(otherDir) >= index) {
+		return removeSwapTags(dir, otherDir)
+	}
+	switch {
+	case readIndexFile(filepath.Join(tmp, swapTagFile)) == index:
+		// Interrupted after moving dir out of the way.
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err := os.Rename(otherDir, dir); err != nil {
+				return errors.Wrap(err, "failed to move stream data directory")
+			}
+		}
+		if err := os.Rename(tmp, otherDir); err != nil {
+			return errors.Wrap(err, "failed to move stream data directory")
+		}
+	case readIndexFile(filepath.Join(otherDir, swapTagFile)) == index:
+		// Interrupted after the exchange but before recording it.
+	default:
+		data := []byte(strconv.FormatUint(index, 10))
+		if err := ioutil.WriteFile(filepath.Join(dir, swapTagFile), data, 0666); err != nil {
+			return errors.Wrap(err, "failed to write stream swap tag")
+		}
+		if err := os.MkdirAll(filepath.Dir(tmp), os.ModePerm); err != nil {
+			return errors.Wrap(err, "failed to create stream swap directory")
+		}
+		if err := os.Rename(dir, tmp); err != nil {
+			return errors.Wrap(err, "failed to move stream data directory")
+		}
+		if err := os.Rename(otherDir, dir); err != nil {
+			return errors.Wrap(err, "failed to move stream data directory")
+		}
+		if err := os.Rename(tmp, otherDir); err != nil {
+			return errors.Wrap(err, "failed to move stream data directory")
+		}
+	}
+	for _, d := range []string{dir, otherDir} {
+		if err := writeMoveIndex(d, index); err != nil {
+			return err
+		}
+	}
+	return removeSwapTags(dir, otherDir)
+}
+
+// removeSwapTags removes the tag left by swapStreamDataDirs, if any, from the
+// given stream data directories.
+func removeSwapTags(dirs ...string) error {
+	for _, d := range dirs {
+		if err := os.Remove(filepath.Join(d, swapTagFile)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "failed to remove stream swap tag")
+		}
+	}
+	return nil
+}
+
+// writeMoveIndex records the index of the Raft entry which moved the given
+// stream data directory to its current name.
+func writeMoveIndex(dir string, index uint64) error {
+	data := []byte(strconv.FormatUint(index, 10))
+	if err := ioutil.WriteFile(filepath.Join(dir, moveMarkerFile), data, 0666); err != nil {
+		return errors.Wrap(err, "failed to write stream move marker")
+	}
+	return nil
+}
+
+// moveIndex returns the index of the last Raft entry which moved the given
+// stream data directory to its current name or 0 if there is none.
+func moveIndex(dir string) uint64 {
+	return readIndexFile(filepath.Join(dir, moveMarkerFile))
+}
+
+// readIndexFile returns the Raft index recorded in the given file or 0 if
+// there is none.
+func readIndexFile(file string) uint64 {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	index, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return index
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Ensure swapStreamDataDirs finishes an exchange interrupted at any step when
+// its Raft entry is replayed.
+func TestSwapStreamDataDirsInterrupted(t *testing.T) {
+	const index = 5
+	tag := []byte(strconv.Itoa(index))
+
+	// Each step leaves the directories as a crash after it would.
+	steps := map[string]func(dir, otherDir, tmp string){
+		"not started": func(dir, otherDir, tmp string) {},
+		"tagged": func(dir, otherDir, tmp string) {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, swapTagFile), tag, 0666))
+		},
+		"moved out": func(dir, otherDir, tmp string) {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, swapTagFile), tag, 0666))
+			require.NoError(t, os.Rename(dir, tmp))
+		},
+		"moved in": func(dir, otherDir, tmp string) {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, swapTagFile), tag, 0666))
+			require.NoError(t, os.Rename(dir, tmp))
+			require.NoError(t, os.Rename(otherDir, dir))
+		},
+		"exchanged": func(dir, otherDir, tmp string) {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, swapTagFile), tag, 0666))
+			require.NoError(t, os.Rename(dir, tmp))
+			require.NoError(t, os.Rename(otherDir, dir))
+			require.NoError(t, os.Rename(tmp, otherDir))
+		},
+	}
+	for name, step := range steps {
+		t.Run(name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "liftbridge_test")
+			require.NoError(t, err)
+			defer os.RemoveAll(root)
+
+			var (
+				dir      = filepath.Join(root, "streams", "foo")
+				otherDir = filepath.Join(root, "streams", "bar")
+				tmp      = filepath.Join(root, "streams-swap")
+			)
+			require.NoError(t, os.MkdirAll(dir, os.ModePerm))
+			require.NoError(t, os.MkdirAll(otherDir, os.ModePerm))
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "data"), []byte("foo"), 0666))
+			require.NoError(t, ioutil.WriteFile(filepath.Join(otherDir, "data"), []byte("bar"), 0666))
+
+			step(dir, otherDir, tmp)
+			require.NoError(t, swapStreamDataDirs(dir, otherDir, tmp, index, true))
+
+			for d, expected := range map[string]string{dir: "bar", otherDir: "foo"} {
+				data, err := ioutil.ReadFile(filepath.Join(d, "data"))
+				require.NoError(t, err)
+				require.Equal(t, expected, string(data))
+				require.Equal(t, uint64(index), moveIndex(d))
+				_, err = os.Stat(filepath.Join(d, swapTagFile))
+				require.True(t, os.IsNotExist(err))
+			}
+			_, err = os.Stat(tmp)
+			require.True(t, os.IsNotExist(err))
+
+			// Replaying the entry again does nothing.
+			require.NoError(t, swapStreamDataDirs(dir, otherDir, tmp, index, true))
+			data, err := ioutil.ReadFile(filepath.Join(dir, "data"))
+			require.NoError(t, err)
+			require.Equal(t, "bar", string(data))
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+
+	client "github.com/liftbridge-io/liftbridge-api/go"
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// importsDir is the directory in the data directory containing the state of
+// the server's subject imports, one file per import.
+const importsDir = "imports"
+
+var (
+	// ErrImportExists is returned by StartImport when an import with the
+	// same name already exists on the server.
+	ErrImportExists = errors.New("import already exists")
+
+	// ErrImportNotFound is returned by StopImport when there is no import
+	// with the given name on the server.
+	ErrImportNotFound = errors.New("import does not exist")
+)
+
+// importPublishTimeout is how long an import waits for a message to be acked
+// by the stream before retrying it. This is a var for testing purposes.
+var importPublishTimeout = 5 * time.Second
+
+// subjectImporter publishes the messages received on a NATS subject to a
+// stream partition, in the order they are received, counting the messages
+// imported. It is persisted so that it resumes when the server restarts.
+type subjectImporter struct {
+	srv      *Server
+	mu       sync.Mutex
+	state    *proto.ImportState
+	imported int64 // Number of messages imported
+	sub      *nats.Subscription
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// StartImport starts importing the messages published to the NATS subject,
+// which may contain wildcards, into the given stream partition through this
+// server. Each message is published to the stream like a Publish call with
+// AckPolicy ALL, keeping its value and reply subject, with the subject it
+// was received on in the SourceSubjectHeader. Messages are imported one at a
+// time in the order received and retried until the stream acks them. The
+// import is persisted and resumes when the server restarts, but since NATS
+// does not retain messages, messages published while the import isn't
+// running are not imported. The name identifies the import on this server.
+// ErrImportExists is returned if there is already an import with the name.
+func (s *Server) StartImport(name, subject, stream string, partitionID int32) error {
+	if !validConnectorName(name) {
+		return fmt.Errorf("invalid import name %q", name)
+	}
+	if subject == "" {
+		return errors.New("import subject cannot be empty")
+	}
+	partitions, err := s.getStreamPartitions(stream, []int32{partitionID})
+	if err != nil {
+		return err
+	}
+	if _, ok := subjectWildcards(subject, partitions[0].getSubject()); ok {
+		return fmt.Errorf("import subject %s includes the subject of partition %s",
+			subject, partitions[0])
+	}
+
+	s.importsMu.Lock()
+	defer s.importsMu.Unlock()
+	if _, ok := s.imports[name]; ok {
+		return ErrImportExists
+	}
+	state := &proto.ImportState{
+		Name:      name,
+		Subject:   subject,
+		Stream:    stream,
+		Partition: partitionID,
+	}
+	if err := s.persistImport(state); err != nil {
+		return err
+	}
+	return s.startImport(state)
+}
+
+// StopImport stops the import with the given name on this server and removes
+// its persisted state. ErrImportNotFound is returned if there is no such
+// import.
+func (s *Server) StopImport(name string) error {
+	s.importsMu.Lock()
+	defer s.importsMu.Unlock()
+	i, ok := s.imports[name]
+	if !ok {
+		return ErrImportNotFound
+	}
+	i.stop()
+	delete(s.imports, name)
+	err := os.Remove(s.importFile(name))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove import state")
+	}
+	return nil
+}
+
+// resumeImports starts the imports persisted in the data directory.
+func (s *Server) resumeImports() error {
+	files, err := ioutil.ReadDir(filepath.Join(s.config.DataDir, importsDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read imports directory")
+	}
+	s.importsMu.Lock()
+	defer s.importsMu.Unlock()
+	for _, file := range files {
+		data, err := ioutil.ReadFile(s.importFile(file.Name()))
+		if err != nil {
+			return errors.Wrap(err, "failed to read import state")
+		}
+		state := &proto.ImportState{}
+		if err := state.Unmarshal(data); err != nil {
+			s.logger.Errorf("Skipping invalid import state %s: %v", file.Name(), err)
+			continue
+		}
+		s.logger.Infof("Resuming import %s from subject %s into partition [stream=%s, partition=%d]",
+			state.Name, state.Subject, state.Stream, state.Partition)
+		if err := s.startImport(state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startImport subscribes to the import's subject. This must be called with
+// the imports lock held.
+func (s *Server) startImport(state *proto.ImportState) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &subjectImporter{
+		srv:      s,
+		state:    state,
+		imported: state.Position,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	sub, err := s.nc.Subscribe(state.Subject, func(msg *nats.Msg) {
+		i.importMessage(ctx, msg)
+	})
+	if err != nil {
+		cancel()
+		return errors.Wrap(err, "failed to subscribe to import subject")
+	}
+	sub.SetPendingLimits(-1, -1)
+	i.sub = sub
+	s.imports[state.Name] = i
+	s.startGoroutine(func() { i.checkpointLoop(ctx) })
+	return nil
+}
+
+// importFile returns the path of the file containing the state of the import
+// with the given name.
+func (s *Server) importFile(name string) string {
+	return filepath.Join(s.config.DataDir, importsDir, name)
+}
+
+// persistImport writes the import state to disk.
+func (s *Server) persistImport(state *proto.ImportState) error {
+	data, err := state.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	return s.writeStateFile(importsDir, state.Name, data)
+}
+
+// stop unsubscribes from the import's subject and waits for it to persist its
+// position.
+func (i *subjectImporter) stop() {
+	i.sub.Unsubscribe() // nolint: errcheck
+	i.cancel()
+	select {
+	case <-i.done:
+	case <-i.srv.shutdownCh:
+	}
+}
+
+// importMessage publishes the message to the import's stream partition,
+// retrying until it's acked or the Context is canceled. A message which was
+// stored but whose ack was lost is imported again.
+func (i *subjectImporter) importMessage(ctx context.Context, msg *nats.Msg) {
+	api := &apiServer{i.srv}
+	req := &client.PublishRequest{
+		Stream:       i.state.Stream,
+		Partition:    i.state.Partition,
+		Value:        msg.Data,
+		ReplySubject: msg.Reply,
+		Headers:      map[string][]byte{SourceSubjectHeader: []byte(msg.Subject)},
+		AckPolicy:    client.AckPolicy_ALL,
+	}
+	for {
+		pubCtx, cancel := context.WithTimeout(ctx, importPublishTimeout)
+		_, err := api.Publish(pubCtx, req)
+		cancel()
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		i.srv.logger.Warnf("Import %s failed to publish message, retrying: %v", i.state.Name, err)
+		select {
+		case <-time.After(connectorRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+		// Publish assigns an ack inbox, so clear it to wait on a new one.
+		req.AckInbox = ""
+	}
+	i.mu.Lock()
+	i.imported++
+	i.mu.Unlock()
+}
+
+// checkpointLoop periodically persists the import's position until the
+// Context is canceled, then persists it a final time. It also stops the
+// import when the server shuts down.
+func (i *subjectImporter) checkpointLoop(ctx context.Context) {
+	defer close(i.done)
+	defer i.checkpoint()
+	ticker := time.NewTicker(connectorCheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			i.checkpoint()
+		case <-ctx.Done():
+			return
+		case <-i.srv.shutdownCh:
+			i.cancel()
+			return
+		}
+	}
+}
+
+// checkpoint persists the number of messages imported.
+func (i *subjectImporter) checkpoint() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.imported == i.state.Position {
+		return
+	}
+	state := *i.state
+	state.Position = i.imported
+	if err := i.srv.persistImport(&state); err != nil {
+		i.srv.logger.Errorf("Failed to persist import %s: %v", i.state.Name, err)
+		return
+	}
+	i.state.Position = i.imported
+}
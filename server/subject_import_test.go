@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+)
+
+// Ensure an import publishes the messages received on its NATS subject to the
+// stream in order, keeping the subject they were received on, and resumes
+// after a restart.
+func TestImportSubject(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure server.
+	s1Config := getTestConfig("a", true, 5050)
+	s1 := runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+
+	// Wait for server to elect itself leader.
+	getMetadataLeader(t, 10*time.Second, s1)
+
+	client, err := lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	name := "foo"
+	require.NoError(t, client.CreateStream(context.Background(), "foo", name))
+
+	nc, err := nats.Connect(nats.DefaultURL)
+	require.NoError(t, err)
+	defer nc.Close()
+
+	publish := func(start, end int) {
+		for i := start; i < end; i++ {
+			subject := "source." + strconv.Itoa(i%2)
+			require.NoError(t, nc.Publish(subject, []byte(strconv.Itoa(i))))
+		}
+		require.NoError(t, nc.Flush())
+	}
+
+	// Importing from a subject the stream ingests from fails.
+	require.Error(t, s1.StartImport("loop", ">", name, 0))
+
+	require.NoError(t, s1.StartImport("import", "source.*", name, 0))
+	require.Equal(t, ErrImportExists, s1.StartImport("import", "source.*", name, 0))
+	publish(0, 5)
+
+	// Restart the server. The import resumes.
+	waitForHW(t, 5*time.Second, name, 0, 4, s1)
+	s1.Stop()
+	s1 = runServerWithConfig(t, s1Config)
+	defer s1.Stop()
+	getMetadataLeader(t, 10*time.Second, s1)
+	waitForPartition(t, 10*time.Second, name, 0, s1)
+	publish(5, 8)
+	waitForHW(t, 5*time.Second, name, 0, 7, s1)
+
+	// Once stopped, messages are no longer imported.
+	require.NoError(t, s1.StopImport("import"))
+	require.Equal(t, ErrImportNotFound, s1.StopImport("import"))
+	publish(8, 10)
+
+	client.Close()
+	client, err = lift.Connect([]string{"localhost:5050"})
+	require.NoError(t, err)
+
+	msgs := make(chan lift.Message, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = client.Subscribe(ctx, name, func(msg lift.Message, err error) {
+		require.NoError(t, err)
+		msgs <- msg
+	}, lift.StartAtEarliestReceived())
+	require.NoError(t, err)
+
+	for i := 0; i < 8; i++ {
+		select {
+		case msg := <-msgs:
+			require.Equal(t, int64(i), msg.Offset())
+			require.Equal(t, []byte(strconv.Itoa(i)), msg.Value())
+			require.Equal(t, []byte("source."+strconv.Itoa(i%2)),
+				msg.Headers()[SourceSubjectHeader])
+		case <-time.After(5 * time.Second):
+			t.Fatal("Did not receive expected message")
+		}
+	}
+	select {
+	case msg := <-msgs:
+		t.Fatalf("Received unexpected message at offset %d", msg.Offset())
+	case <-time.After(200 * time.Millisecond):
+	}
+}
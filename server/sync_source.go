@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+
+	"github.com/liftbridge-io/liftbridge/server/commitlog"
+	proto "github.com/liftbridge-io/liftbridge/server/protocol"
+)
+
+// getSyncRequestInbox returns the NATS subject to send replication requests to
+// when fetching from the given follower acting as a sync source.
+func (p *partition) getSyncRequestInbox(source string) string {
+	return fmt.Sprintf("%s.%s.%d.sync.%s",
+		p.srv.config.Clustering.Namespace, p.Stream, p.Id, source)
+}
+
+// subscribeSyncRequests subscribes to replication requests from followers
+// using this server as their sync source. This must be called with the
+// partition lock held.
+func (p *partition) subscribeSyncRequests() error {
+	sub, err := p.srv.ncRepl.Subscribe(
+		p.getSyncRequestInbox(p.srv.config.Clustering.ServerID), p.handleSyncRequest)
+	if err != nil {
+		return errors.Wrap(err, "failed to subscribe to sync inbox")
+	}
+	sub.SetPendingLimits(-1, -1)
+	p.followerSyncSub = sub
+	return nil
+}
+
+// unsubscribeSyncRequests stops serving replication requests from followers
+// using this server as their sync source. This must be called with the
+// partition lock held.
+func (p *partition) unsubscribeSyncRequests() error {
+	if p.followerSyncSub == nil {
+		return nil
+	}
+	err := p.followerSyncSub.Unsubscribe()
+	p.followerSyncSub = nil
+	return err
+}
+
+// isSyncFollower indicates if this server fetches the partition from a sync
+// source rather than the given leader. Such a follower stays out of the ISR,
+// even while it falls back to fetching from the leader, so that the ISR
+// doesn't change each time it switches between them.
+func (p *partition) isSyncFollower(leader string) bool {
+	source := p.srv.config.Clustering.ReplicaSyncSource
+	return source != "" && source != leader && source != p.srv.config.Clustering.ServerID
+}
+
+// syncSource returns the server to fetch the partition from instead of the
+// given leader or an empty string to fetch from the leader. The configured
+// ReplicaSyncSource is only used while it's an in-sync follower of the
+// partition, so the messages it serves are never behind what the leader has
+// committed for long.
+func (p *partition) syncSource(leader string) string {
+	if !p.isSyncFollower(leader) {
+		return ""
+	}
+	source := p.srv.config.Clustering.ReplicaSyncSource
+	if !p.inISR(source) {
+		return ""
+	}
+	return source
+}
+
+// sendSyncRequest sends a replication request to the given sync source and
+// replicates the messages it responds with, returning the number of messages
+// replicated.
+func (p *partition) sendSyncRequest(source string, leaderEpoch uint64) (int, error) {
+	return p.requestReplication(p.getSyncRequestInbox(source), leaderEpoch, true)
+}
+
+// reportSyncProgress reports the offset of this follower to the partition
+// leader without fetching messages from it. A follower fetching from a sync
+// source doesn't otherwise send the leader requests, so this lets the leader
+// tell it's still replicating rather than replace it for failing to catch up.
+func (p *partition) reportSyncProgress(leaderEpoch uint64) error {
+	data, err := proto.MarshalReplicationRequest(&proto.ReplicationRequest{
+		ReplicaID:    p.srv.config.Clustering.ServerID,
+		Offset:       p.log.NewestOffset(),
+		LeaderEpoch:  leaderEpoch,
+		SyncFollower: true,
+		ReportOnly:   true,
+	})
+	if err != nil {
+		panic(err)
+	}
+	resp, err := p.srv.ncRepl.Request(
+		p.getReplicationRequestInbox(),
+		data,
+		p.replicaFetchTimeout(),
+	)
+	if err != nil {
+		return err
+	}
+	p.handleReplicationResponse(resp)
+	return nil
+}
+
+// leaveISR asks the metadata leader to remove this server from the partition's
+// ISR and returns whether it was removed. A follower fetching from a sync
+// source only receives committed messages, so it can't count towards commits
+// or be elected leader. The leader doesn't add it back to the ISR.
+func (p *partition) leaveISR(leader string, epoch uint64) bool {
+	req := &proto.ShrinkISROp{
+		Stream:          p.Stream,
+		Partition:       p.Id,
+		ReplicaToRemove: p.srv.config.Clustering.ServerID,
+		Leader:          leader,
+		LeaderEpoch:     epoch,
+	}
+	if err := p.srv.metadata.ShrinkISR(context.Background(), req); err != nil {
+		p.srv.logger.Errorf("Failed to remove replica %s for partition %s from ISR: %v",
+			req.ReplicaToRemove, p, err.Err())
+		return false
+	}
+	p.srv.logger.Infof("Replica %s for partition %s left ISR to fetch from sync source",
+		req.ReplicaToRemove, p)
+	return true
+}
+
+// handleSyncRequest is a NATS handler that's invoked when a follower using
+// this server as its sync source sends a replication request. It responds
+// like the leader would, except only with messages up to this server's HW so
+// that uncommitted messages are never passed along the chain.
+func (p *partition) handleSyncRequest(msg *nats.Msg) {
+	req, err := proto.UnmarshalReplicationRequest(msg.Data)
+	if err != nil {
+		p.srv.logger.Errorf("Invalid sync request for partition %s: %v", p, err)
+		return
+	}
+	p.mu.RLock()
+	var (
+		following = p.isFollowing && !p.replPaused
+		epoch     = p.LeaderEpoch
+		replica   = p.inReplicas(req.ReplicaID) || p.inObservers(req.ReplicaID)
+	)
+	p.mu.RUnlock()
+	if !following || req.LeaderEpoch != epoch {
+		// The follower fetches from the leader if this server doesn't
+		// respond.
+		return
+	}
+	if !replica {
+		p.srv.logger.Warnf("Received sync request for partition %s from non-replica %s",
+			p, req.ReplicaID)
+		return
+	}
+
+	hw := p.log.HighWatermark()
+	data, err := p.readCommittedBatch(req.Offset, hw)
+	if err != nil {
+		p.srv.logger.Errorf("Failed to read partition %s for sync request from replica %s: %v",
+			p, req.ReplicaID, err)
+		data = nil
+	}
+	resp, err := p.srv.replicationCodec().EncodeReplicationResponse(nil, epoch, hw, data)
+	if err != nil {
+		p.srv.logger.Errorf("Failed to encode sync response for partition %s: %v", p, err)
+		return
+	}
+	if err := msg.Respond(resp); err != nil {
+		p.srv.logger.Errorf("Failed to respond to sync request for partition %s: %v", p, err)
+	}
+}
+
+// readCommittedBatch reads a batch of messages in replication format from the
+// log after the given offset up to and including the given HW.
+func (p *partition) readCommittedBatch(offset, hw int64) ([]byte, error) {
+	if offset >= hw {
+		return nil, nil
+	}
+	reader, err := p.log.NewReader(offset+1, true)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var (
+		buf     bytes.Buffer
+		headers [28]byte
+		message commitlog.SerializedMessage
+	)
+	for offset < hw {
+		ctx, cancel := context.WithTimeout(context.Background(), p.replicaFetchTimeout())
+		message, offset, _, _, err = reader.ReadMessage(ctx, headers[:])
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if len(message)+len(headers)+buf.Len()+replicationOverhead > replicationMaxSize {
+			break
+		}
+		buf.Write(headers[:])
+		buf.Write(message)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	lift "github.com/liftbridge-io/go-liftbridge"
+	natsdTest "github.com/nats-io/nats-server/v2/test"
+	"github.com/stretchr/testify/require"
+	grpcMetadata "google.golang.org/grpc/metadata"
+)
+
+// Ensure a follower configured with a sync source leaves the ISR and catches
+// up on committed messages from the sync source rather than the leader, and
+// falls back to the leader, without rejoining the ISR, when the sync source
+// stops.
+func TestReplicaSyncSource(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers. e fetches from d.
+	var servers []*Server
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxLagTime = time.Second
+		config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+		config.Clustering.ReplicaFetchTimeout = 500 * time.Millisecond
+		if id == "e" {
+			config.Clustering.ReplicaSyncSource = "d"
+		}
+		s := runServerWithConfig(t, config)
+		defer s.Stop()
+		servers = append(servers, s)
+	}
+	metadataLeader := getMetadataLeader(t, 10*time.Second, servers...)
+	d, e := servers[3], servers[4]
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051", "localhost:5052"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Keep d and e from leading the partition.
+	for _, id := range []string{"d", "e"} {
+		require.NoError(t, metadataLeader.SetServerReadOnly(context.Background(), id, true))
+	}
+	name := "foo"
+	err = client.CreateStream(context.Background(), "foo", name, lift.ReplicationFactor(5))
+	require.NoError(t, err)
+	for _, id := range []string{"d", "e"} {
+		require.NoError(t, metadataLeader.SetServerReadOnly(context.Background(), id, false))
+	}
+
+	// e leaves the ISR since it only receives committed messages.
+	waitForISR(t, 10*time.Second, name, 0, 4, servers...)
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	require.NotEqual(t, d, leader)
+	require.NotEqual(t, e, leader)
+	partition := e.metadata.GetPartition(name, 0)
+	require.False(t, partition.inISR("e"))
+
+	publish := func(start, end int) {
+		for i := start; i < end; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+			cancel()
+			require.NoError(t, err)
+		}
+	}
+
+	// e catches up from d.
+	num := 5
+	publish(0, num)
+	waitForHW(t, 5*time.Second, name, 0, int64(num-1), e)
+	require.Equal(t, int64(num-1), partition.log.NewestOffset())
+	desc, err := leader.DescribeStream(name)
+	require.NoError(t, err)
+	for _, replica := range desc.Partitions[0].Replicas {
+		if replica.ID == "e" {
+			require.False(t, replica.InISR)
+		}
+	}
+
+	// With d stopped, e fetches from the leader but stays out of the ISR so
+	// that it doesn't flap as d comes and goes.
+	d.Stop()
+	publish(num, 2*num)
+	waitForHW(t, 10*time.Second, name, 0, int64(2*num-1), e)
+	require.Equal(t, int64(2*num-1), partition.log.NewestOffset())
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); {
+		require.False(t, leader.metadata.GetPartition(name, 0).inISR("e"))
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Ensure a follower fetching from a sync source reports its progress to the
+// leader so that it isn't replaced for failing to catch up while it keeps
+// replicating committed messages.
+func TestReplicaSyncSourceNotReplaced(t *testing.T) {
+	defer cleanupStorage(t)
+
+	// Use a central NATS server.
+	ns := natsdTest.RunDefaultServer()
+	defer ns.Shutdown()
+
+	// Configure servers. c fetches from b and d is available to replace it.
+	replaced := make(chan ReplicaReplacement, 1)
+	var servers []*Server
+	for i, id := range []string{"a", "b", "c", "d"} {
+		config := getTestConfig(id, i == 0, 5050+i)
+		config.Clustering.ReplicaMaxLagTime = 200 * time.Millisecond
+		config.Clustering.ReplicaMaxIdleWait = 2 * time.Millisecond
+		config.Clustering.ReplicaFetchTimeout = 100 * time.Millisecond
+		config.Clustering.ReplicaMaxCatchUpFailures = 2
+		config.Clustering.OnReplicaReplaced = func(r ReplicaReplacement) {
+			select {
+			case replaced <- r:
+			default:
+			}
+		}
+		if id == "c" {
+			config.Clustering.ReplicaSyncSource = "b"
+		}
+		s := runServerWithConfig(t, config)
+		defer s.Stop()
+		servers = append(servers, s)
+	}
+	metadataLeader := getMetadataLeader(t, 10*time.Second, servers...)
+	c := servers[2]
+
+	client, err := lift.Connect([]string{"localhost:5050", "localhost:5051"})
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Place the partition on a, b, and c, keeping b and c from leading it.
+	for _, id := range []string{"b", "c"} {
+		require.NoError(t, metadataLeader.SetServerReadOnly(context.Background(), id, true))
+	}
+	name := "foo"
+	ctx := grpcMetadata.AppendToOutgoingContext(context.Background(),
+		ReplicaServersMetadataKey, "a", ReplicaServersMetadataKey, "b",
+		ReplicaServersMetadataKey, "c")
+	err = client.CreateStream(ctx, "foo", name, lift.ReplicationFactor(3))
+	require.NoError(t, err)
+	for _, id := range []string{"b", "c"} {
+		require.NoError(t, metadataLeader.SetServerReadOnly(context.Background(), id, false))
+	}
+	leader := getPartitionLeader(t, 10*time.Second, name, 0, servers...)
+	require.Equal(t, "a", leader.config.Clustering.ServerID)
+
+	// Keep publishing for many more lag time periods than it takes to
+	// replace a replica.
+	num := 40
+	for i := 0; i < num; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err := client.Publish(ctx, name, []byte(strconv.Itoa(i)), lift.AckPolicyAll())
+		cancel()
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+	}
+	waitForHW(t, 5*time.Second, name, 0, int64(num-1), c)
+
+	select {
+	case r := <-replaced:
+		t.Fatalf("Replica %s was replaced with %s", r.Replaced, r.Replacement)
+	default:
+	}
+	partition := leader.metadata.GetPartition(name, 0)
+	require.ElementsMatch(t, []string{"a", "b", "c"}, partition.GetReplicas())
+	require.False(t, partition.inISR("c"))
+}